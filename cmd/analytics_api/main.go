@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/api"
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+	"github.com/gregor-tokarev/hoe_parser/internal/logger"
+	"github.com/joho/godotenv"
+)
+
+// log is the component logger of the analytics API process
+var log = logger.Component("analytics_api")
+
+// analytics_api serves the read endpoints of the HTTP API (listing queries, history, stats,
+// aggregates and the change feed) from ClickHouse, without scraping. It shares the adapter and
+// the API keys of the parser but runs and scales apart from it, so analytics queries do not take
+// CPU, memory or connections from the scrapers.
+func main() {
+	addr := flag.String("addr", "", "listen address, HOST:PORT from the config when empty")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		log.Warn("Failed to load .env file", "error", err)
+	}
+
+	cfg := config.Load()
+	if err := logger.FromConfig(cfg); err != nil {
+		log.Warn("Falling back to default logging", "error", err)
+	}
+	if *addr == "" {
+		*addr = net.JoinHostPort(cfg.Host, cfg.Port)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// The parser owns the schema: migrations are applied by its startup, never by a reader
+	chConfig := clickhouse.FromMainConfig(cfg, cfg.Debug)
+	chConfig.AutoMigrate = false
+	adapter, err := clickhouse.NewAdapter(chConfig)
+	if err != nil {
+		log.Error("Failed to create ClickHouse adapter", "error", err)
+		os.Exit(1)
+	}
+	defer adapter.Close()
+
+	keys, err := api.LoadKeyStore(cfg.APIKeysFile, cfg.APIKey)
+	if err != nil {
+		log.Error("Failed to load API keys", "error", err)
+		os.Exit(1)
+	}
+
+	server := api.NewServer(adapter, keys)
+	server.SetMinBucket(cfg.AggregateMinBucket)
+	mode := api.ModeRead
+	if cfg.APIMode == api.ModeAggregate {
+		mode = api.ModeAggregate
+	}
+	server.SetMode(mode)
+	server.SetMiddleware(api.MiddlewareOptionsFromConfig(cfg))
+	if location, err := time.LoadLocation(cfg.DisplayTimezone); err != nil {
+		log.Warn("Invalid display time zone, showing UTC", "timezone", cfg.DisplayTimezone, "error", err)
+	} else {
+		server.SetLocation(location)
+	}
+
+	log.Info("Analytics API listening", "url", "http://"+*addr, "mode", mode)
+	if err := server.Serve(ctx, *addr); err != nil {
+		log.Error("Analytics API stopped", "error", err)
+		os.Exit(1)
+	}
+}
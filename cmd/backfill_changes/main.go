@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+	"github.com/gregor-tokarev/hoe_parser/pkg/format"
+	"github.com/joho/godotenv"
+)
+
+// source marks the change log entries written by this job
+const source = "backfill_changes"
+
+func main() {
+	batch := flag.Int("batch", 500, "number of listings whose versions are read per query")
+	until := flag.String("until", "", "only backfill changes before this RFC3339 time (default: the first change logged by the scraper)")
+	dryRun := flag.Bool("dry-run", false, "compute changes but do not write to ClickHouse")
+	force := flag.Bool("force", false, "run even though an earlier backfill already wrote changes")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Error loading .env file: %v", err)
+	}
+
+	cfg := config.Load()
+	locale, _ := format.ParseLocale(cfg.DisplayLocale)
+
+	adapter, err := clickhouse.NewAdapter(clickhouse.FromMainConfig(cfg, cfg.Debug))
+	if err != nil {
+		log.Fatalf("Failed to create ClickHouse adapter: %v", err)
+	}
+	defer adapter.Close()
+
+	ctx := context.Background()
+
+	// A second run would log every change twice
+	if _, count, err := adapter.ChangeLogRange(ctx, source); err != nil {
+		log.Fatalf("Failed to check for an earlier backfill: %v", err)
+	} else if count > 0 && !*force {
+		log.Fatalf("An earlier backfill already wrote %d changes; rerun with -force to add them again", count)
+	}
+
+	// Changes from the moment the scraper started logging them are already in listing_changes
+	cutoff, err := backfillCutoff(ctx, adapter, *until)
+	if err != nil {
+		log.Fatalf("Failed to determine the backfill cutoff: %v", err)
+	}
+	fmt.Printf("Backfilling changes before %s\n", cutoff.Format(time.RFC3339))
+
+	var listings, written int
+	after := ""
+	for {
+		ids, err := adapter.ListingIDs(ctx, after, *batch)
+		if err != nil {
+			log.Fatalf("Failed to load listing ids after %q: %v", after, err)
+		}
+		if len(ids) == 0 {
+			break
+		}
+		after = ids[len(ids)-1]
+
+		versions, err := adapter.ListingVersions(ctx, ids)
+		if err != nil {
+			log.Fatalf("Failed to load versions of %d listings: %v", len(ids), err)
+		}
+
+		var records []clickhouse.ChangeRecord
+		for _, id := range ids {
+			for _, record := range clickhouse.VersionChangeRecords(versions[id], source) {
+				if record.ChangedAt.Before(cutoff) {
+					records = append(records, record)
+				}
+			}
+		}
+		listings += len(ids)
+
+		if !*dryRun {
+			opCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			err = adapter.LogChanges(opCtx, records)
+			cancel()
+			if err != nil {
+				log.Fatalf("Failed to log %d changes of listings up to %s: %v", len(records), after, err)
+			}
+		}
+		written += len(records)
+		fmt.Printf("Processed %s listings, %s changes\n", locale.Int(int64(listings)), locale.Int(int64(written)))
+	}
+
+	fmt.Printf("Backfill complete: %s changes from %s listings\n", locale.Int(int64(written)), locale.Int(int64(listings)))
+}
+
+// backfillCutoff parses until, defaulting to the first change logged by the scraper, or now when
+// the scraper has not logged any
+func backfillCutoff(ctx context.Context, adapter *clickhouse.Adapter, until string) (time.Time, error) {
+	if until != "" {
+		cutoff, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid -until: %w", err)
+		}
+		return cutoff, nil
+	}
+
+	earliest, _, err := adapter.ChangeLogRange(ctx, clickhouse.ChangeSourceScraper)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if earliest.IsZero() {
+		return clock.Now(), nil
+	}
+	return earliest, nil
+}
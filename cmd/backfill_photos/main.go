@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+	"github.com/gregor-tokarev/hoe_parser/internal/modules/request_client"
+	"github.com/gregor-tokarev/hoe_parser/internal/scraper"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	limit := flag.Int("limit", 1000, "maximum number of listings to process")
+	delay := flag.Duration("delay", time.Second, "delay between image requests")
+	dryRun := flag.Bool("dry-run", false, "fetch photos but do not write to ClickHouse")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Error loading .env file: %v", err)
+	}
+
+	cfg := config.Load()
+
+	// Image requests go through the same proxies as regular scraping
+	request_client.InitGlobalClient(cfg)
+	fmt.Printf("Initialized proxy client with %d proxies\n", len(cfg.Proxies))
+
+	adapter, err := clickhouse.NewAdapter(clickhouse.FromMainConfig(cfg, cfg.Debug))
+	if err != nil {
+		log.Fatalf("Failed to create ClickHouse adapter: %v", err)
+	}
+	defer adapter.Close()
+
+	ctx := context.Background()
+
+	listings, err := adapter.GetListingsWithoutPhotos(ctx, *limit)
+	if err != nil {
+		log.Fatalf("Failed to load listings without photos: %v", err)
+	}
+
+	fmt.Printf("Found %d listings without photos\n", len(listings))
+
+	var recovered, empty, failed int
+	var changes []clickhouse.ChangeRecord
+	for i, flattened := range listings {
+		if i > 0 && *delay > 0 {
+			time.Sleep(*delay)
+		}
+
+		if flattened.SourceURL == "" {
+			fmt.Printf("Skipping listing %s: no source URL\n", flattened.ID)
+			failed++
+			continue
+		}
+
+		photos, err := scraper.FetchPhotoURLs(ctx, flattened.SourceURL)
+		if err != nil {
+			fmt.Printf("Failed to fetch photos for listing %s: %v\n", flattened.ID, err)
+			failed++
+			continue
+		}
+
+		if len(photos) == 0 {
+			empty++
+			continue
+		}
+
+		fmt.Printf("Recovered %d photos for listing %s\n", len(photos), flattened.ID)
+
+		if *dryRun {
+			recovered++
+			continue
+		}
+
+		// Write a new row version; ReplacingMergeTree keeps the one with the latest updated_at
+		flattened.Photos = photos
+		flattened.PhotosCount = uint16(len(photos))
+		flattened.UpdatedAt = clock.Now()
+
+		opCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		err = adapter.InsertFlattenedListing(opCtx, flattened)
+		cancel()
+
+		if err != nil {
+			fmt.Printf("Failed to update listing %s: %v\n", flattened.ID, err)
+			failed++
+			continue
+		}
+
+		changes = append(changes, clickhouse.ChangeRecord{
+			ListingID:  flattened.ID,
+			ChangedAt:  flattened.UpdatedAt,
+			ChangeType: "update",
+			FieldName:  "photos_count",
+			OldValue:   "0",
+			NewValue:   fmt.Sprintf("%d", len(photos)),
+			Source:     "backfill_photos",
+		})
+		recovered++
+	}
+
+	// The change log is written in one batch once every listing is updated
+	opCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	if err := adapter.LogChanges(opCtx, changes); err != nil {
+		fmt.Printf("Failed to log %d photo changes: %v\n", len(changes), err)
+	}
+	cancel()
+
+	fmt.Printf("Backfill complete: %d recovered, %d still without photos, %d failed\n", recovered, empty, failed)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+	"github.com/joho/godotenv"
+)
+
+const usage = `Usage:
+  exclusions list
+  exclusions add -id <listing id> -reason <text>
+  exclusions remove -id <listing id>
+
+Listing IDs are composite ("<site>:<source id>", e.g. intimcity.gold:12345).
+Excluded listings are soft-deleted, never scraped again and rejected on insert.`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+
+	command := os.Args[1]
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	id := flags.String("id", "", "composite listing ID")
+	reason := flags.String("reason", "", "why the listing is excluded")
+	flags.Parse(os.Args[2:])
+
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Error loading .env file: %v", err)
+	}
+
+	cfg := config.Load()
+
+	adapter, err := clickhouse.NewAdapter(clickhouse.FromMainConfig(cfg, cfg.Debug))
+	if err != nil {
+		log.Fatalf("Failed to create ClickHouse adapter: %v", err)
+	}
+	defer adapter.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	switch command {
+	case "list":
+		exclusions, err := adapter.ListExclusions(ctx)
+		if err != nil {
+			log.Fatalf("Failed to list exclusions: %v", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "LISTING\tEXCLUDED AT\tBY\tREASON")
+		for _, exclusion := range exclusions {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", exclusion.ListingID,
+				exclusion.CreatedAt.Format(time.RFC3339), exclusion.CreatedBy, exclusion.Reason)
+		}
+		w.Flush()
+
+	case "add":
+		if *id == "" {
+			log.Fatal("-id is required")
+		}
+
+		err := adapter.AddExclusion(ctx, clickhouse.Exclusion{
+			ListingID: *id,
+			Reason:    *reason,
+			CreatedBy: operator(),
+		})
+		if err != nil {
+			log.Fatalf("Failed to exclude listing %s: %v", *id, err)
+		}
+		fmt.Printf("Listing %s excluded and soft-deleted\n", *id)
+
+	case "remove":
+		if *id == "" {
+			log.Fatal("-id is required")
+		}
+
+		if err := adapter.RemoveExclusion(ctx, *id, operator()); err != nil {
+			log.Fatalf("Failed to remove exclusion for %s: %v", *id, err)
+		}
+		fmt.Printf("Listing %s removed from the exclusion list; it will reappear once scraped again\n", *id)
+
+	default:
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+}
+
+// operator identifies who made a change from the CLI
+func operator() string {
+	if current, err := user.Current(); err == nil {
+		return "cli:" + current.Username
+	}
+	return "cli"
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	listing "github.com/gregor-tokarev/hoe_parser/proto"
+)
+
+// cityWeight is a city and its share of generated listings
+type cityWeight struct {
+	name   string
+	weight float64
+}
+
+// GeneratorConfig describes the shape of the generated data
+type GeneratorConfig struct {
+	Site        string       // source site the listings are attributed to
+	Cities      []cityWeight // cities weighted by share of listings
+	PriceMedian float64      // median apartments day hour price, RUB
+	PriceSpread float64      // sigma of the log-normal price distribution
+	MinPhotos   int
+	MaxPhotos   int
+	FirstID     int // source ID of the first listing
+}
+
+// Generator produces synthetic listings shaped like scraped ones
+type Generator struct {
+	cfg    GeneratorConfig
+	rng    *rand.Rand
+	total  float64
+	nextID int
+}
+
+var (
+	names       = []string{"Анна", "Мария", "Екатерина", "Алина", "Виктория", "Ольга", "Дарья", "Полина", "Юлия", "Кристина"}
+	hairColors  = []string{"блондинка", "брюнетка", "шатенка", "рыжая"}
+	eyeColors   = []string{"голубые", "зеленые", "карие", "серые"}
+	bodyTypes   = []string{"стройная", "спортивная", "худая", "пышная"}
+	services    = []string{"классический секс", "минет", "массаж классический", "эротический массаж", "стриптиз", "ролевые игры", "душ вдвоем", "поцелуи"}
+	extras      = []string{"анальный секс", "фото/видео", "выезд в сауну"}
+	districts   = []string{"ЦАО", "САО", "ЮАО", "ЗАО", "ВАО"}
+	metroByCity = map[string][]string{
+		"Москва":          {"Арбатская", "Смоленская", "Тверская", "Курская", "Белорусская", "Таганская", "Сокол", "Митино"},
+		"Санкт-Петербург": {"Невский проспект", "Гостиный двор", "Площадь Восстания", "Василеостровская", "Московская"},
+	}
+)
+
+// ParseCities parses "Москва=0.7,Санкт-Петербург=0.3"; a city without a weight counts as 1
+func ParseCities(value string) ([]cityWeight, error) {
+	var cities []cityWeight
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, weightValue, hasWeight := strings.Cut(entry, "=")
+		weight := 1.0
+		if hasWeight {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(weightValue), 64)
+			if err != nil || parsed <= 0 {
+				return nil, fmt.Errorf("invalid weight for city %q: %s", name, weightValue)
+			}
+			weight = parsed
+		}
+		cities = append(cities, cityWeight{name: strings.TrimSpace(name), weight: weight})
+	}
+
+	if len(cities) == 0 {
+		return nil, fmt.Errorf("no cities given")
+	}
+	return cities, nil
+}
+
+// NewGenerator creates a generator; the same seed always produces the same listings
+func NewGenerator(cfg GeneratorConfig, seed int64) *Generator {
+	g := &Generator{
+		cfg:    cfg,
+		rng:    rand.New(rand.NewSource(seed)),
+		nextID: cfg.FirstID,
+	}
+	for _, city := range cfg.Cities {
+		g.total += city.weight
+	}
+	return g
+}
+
+// Next returns the next listing and the source URL it is attributed to
+func (g *Generator) Next() (*listing.Listing, string) {
+	id := strconv.Itoa(g.nextID)
+	g.nextID++
+	city := g.city()
+
+	dayHour := g.price()
+	durationPrices := map[string]int32{
+		"apartments_day_hour":    dayHour,
+		"apartments_day_2hour":   roundPrice(float64(dayHour) * (1.7 + 0.2*g.rng.Float64())),
+		"apartments_night_hour":  roundPrice(float64(dayHour) * 1.2),
+		"apartments_night_2hour": roundPrice(float64(dayHour) * 2.2),
+	}
+	if g.rng.Float64() < 0.6 {
+		outcall := roundPrice(float64(dayHour) * 1.3)
+		durationPrices["outcall_day_hour"] = outcall
+		durationPrices["outcall_day_2hour"] = roundPrice(float64(outcall) * 1.8)
+		durationPrices["outcall_night_hour"] = roundPrice(float64(outcall) * 1.2)
+		durationPrices["outcall_night_2hour"] = roundPrice(float64(outcall) * 2.2)
+	}
+
+	photos := make([]string, g.photoCount())
+	for i := range photos {
+		photos[i] = fmt.Sprintf("https://%s/photos/%s/%d.jpg", g.cfg.Site, id, i+1)
+	}
+
+	return &listing.Listing{
+		Id: id,
+		PersonalInfo: &listing.PersonalInfo{
+			Name:       g.pick(names),
+			Age:        int32(18 + g.rng.Intn(23)),
+			Height:     int32(155 + g.rng.Intn(26)),
+			Weight:     int32(45 + g.rng.Intn(26)),
+			BreastSize: int32(1 + g.rng.Intn(5)),
+			HairColor:  g.pick(hairColors),
+			EyeColor:   g.pick(eyeColors),
+			BodyType:   g.pick(bodyTypes),
+			Gender:     "female",
+		},
+		ContactInfo: &listing.ContactInfo{
+			Phone:             fmt.Sprintf("+79%09d", g.rng.Intn(1_000_000_000)),
+			WhatsappAvailable: g.rng.Float64() < 0.5,
+			ViberAvailable:    g.rng.Float64() < 0.2,
+		},
+		PricingInfo: &listing.PricingInfo{
+			DurationPrices: durationPrices,
+			Currency:       "RUB",
+		},
+		ServiceInfo: &listing.ServiceInfo{
+			AvailableServices:  g.sample(services, 2+g.rng.Intn(len(services)-1)),
+			AdditionalServices: g.sample(extras, g.rng.Intn(len(extras)+1)),
+			MeetingType:        "apartment",
+		},
+		LocationInfo: &listing.LocationInfo{
+			MetroStations:    g.sample(metroByCity[city], 1+g.rng.Intn(2)),
+			District:         g.pick(districts),
+			City:             city,
+			IncallAvailable:  true,
+			OutcallAvailable: durationPrices["outcall_day_hour"] > 0,
+		},
+		Description: fmt.Sprintf("Синтетическая анкета %s для нагрузочного тестирования", id),
+		Photos:      photos,
+	}, fmt.Sprintf("https://%s/anketa%s.htm", g.cfg.Site, id)
+}
+
+// city picks a city by weight
+func (g *Generator) city() string {
+	r := g.rng.Float64() * g.total
+	for _, city := range g.cfg.Cities {
+		if r < city.weight {
+			return city.name
+		}
+		r -= city.weight
+	}
+	return g.cfg.Cities[len(g.cfg.Cities)-1].name
+}
+
+// price draws from a log-normal distribution around the median, which matches the long right tail of real prices
+func (g *Generator) price() int32 {
+	return roundPrice(g.cfg.PriceMedian * math.Exp(g.rng.NormFloat64()*g.cfg.PriceSpread))
+}
+
+// photoCount draws a photo count uniformly from [MinPhotos, MaxPhotos]
+func (g *Generator) photoCount() int {
+	if g.cfg.MaxPhotos <= g.cfg.MinPhotos {
+		return g.cfg.MinPhotos
+	}
+	return g.cfg.MinPhotos + g.rng.Intn(g.cfg.MaxPhotos-g.cfg.MinPhotos+1)
+}
+
+func (g *Generator) pick(values []string) string {
+	return values[g.rng.Intn(len(values))]
+}
+
+// sample returns n distinct values in random order
+func (g *Generator) sample(values []string, n int) []string {
+	if n > len(values) {
+		n = len(values)
+	}
+	result := make([]string, 0, n)
+	for _, i := range g.rng.Perm(len(values))[:n] {
+		result = append(result, values[i])
+	}
+	return result
+}
+
+// roundPrice rounds to the nearest 500 RUB, as prices are listed on the site
+func roundPrice(price float64) int32 {
+	rounded := int32(math.Round(price/500) * 500)
+	if rounded < 500 {
+		return 500
+	}
+	return rounded
+}
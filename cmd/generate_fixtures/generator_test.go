@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+)
+
+func testGeneratorConfig() GeneratorConfig {
+	return GeneratorConfig{
+		Site:        "fixtures.test",
+		Cities:      []cityWeight{{"Москва", 3}, {"Санкт-Петербург", 1}},
+		PriceMedian: 8000,
+		PriceSpread: 0.35,
+		MinPhotos:   1,
+		MaxPhotos:   4,
+		FirstID:     100,
+	}
+}
+
+func TestGeneratorIsDeterministic(t *testing.T) {
+	first := NewGenerator(testGeneratorConfig(), 42)
+	second := NewGenerator(testGeneratorConfig(), 42)
+
+	for i := 0; i < 50; i++ {
+		a, urlA := first.Next()
+		b, urlB := second.Next()
+		if urlA != urlB || a.ContactInfo.Phone != b.ContactInfo.Phone ||
+			a.PricingInfo.DurationPrices["apartments_day_hour"] != b.PricingInfo.DurationPrices["apartments_day_hour"] {
+			t.Fatalf("Expected identical listings for the same seed at %d", i)
+		}
+	}
+}
+
+func TestGeneratorShapesListings(t *testing.T) {
+	generator := NewGenerator(testGeneratorConfig(), 1)
+
+	cities := map[string]int{}
+	for i := 0; i < 2000; i++ {
+		l, sourceURL := generator.Next()
+		if i == 0 && (l.Id != "100" || sourceURL != "https://fixtures.test/anketa100.htm") {
+			t.Errorf("Expected the first listing to be 100, got %s at %s", l.Id, sourceURL)
+		}
+		if n := len(l.Photos); n < 1 || n > 4 {
+			t.Errorf("Expected 1-4 photos, got %d", n)
+		}
+		if price := l.PricingInfo.DurationPrices["apartments_day_hour"]; price <= 0 || price%500 != 0 {
+			t.Errorf("Expected a positive price rounded to 500, got %d", price)
+		}
+		if len(l.LocationInfo.MetroStations) == 0 {
+			t.Errorf("Expected metro stations for %s", l.LocationInfo.City)
+		}
+		cities[l.LocationInfo.City]++
+	}
+
+	if share := float64(cities["Москва"]) / 2000; share < 0.7 || share > 0.8 {
+		t.Errorf("Expected about 75%% of listings in Москва, got %.2f", share)
+	}
+}
+
+func TestParseCities(t *testing.T) {
+	cities, err := ParseCities("Москва=0.7, Казань")
+	if err != nil {
+		t.Fatalf("Failed to parse cities: %v", err)
+	}
+	if len(cities) != 2 || cities[0].weight != 0.7 || cities[1].name != "Казань" || cities[1].weight != 1 {
+		t.Errorf("Expected weighted and default cities, got %v", cities)
+	}
+
+	if _, err := ParseCities("Москва=abc"); err == nil {
+		t.Errorf("Expected an error for an invalid weight")
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+	"github.com/gregor-tokarev/hoe_parser/internal/storage"
+	"github.com/gregor-tokarev/hoe_parser/pkg/format"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	count := flag.Int("count", 10000, "number of listings to generate")
+	batchSize := flag.Int("batch", 1000, "listings per batch insert")
+	cities := flag.String("cities", "Москва=0.8,Санкт-Петербург=0.2", "cities weighted by share of listings")
+	priceMedian := flag.Float64("price-median", 8000, "median hourly price, RUB")
+	priceSpread := flag.Float64("price-spread", 0.35, "sigma of the log-normal price distribution")
+	minPhotos := flag.Int("min-photos", 0, "minimum photos per listing")
+	maxPhotos := flag.Int("max-photos", 8, "maximum photos per listing")
+	site := flag.String("site", "fixtures.test", "source site the listings are attributed to")
+	firstID := flag.Int("first-id", 1, "source ID of the first listing")
+	seed := flag.Int64("seed", 1, "random seed; the same seed generates the same listings")
+	dryRun := flag.Bool("dry-run", false, "generate listings but do not write to the store")
+	flag.Parse()
+
+	cityWeights, err := ParseCities(*cities)
+	if err != nil {
+		log.Fatalf("Invalid -cities: %v", err)
+	}
+	if *batchSize <= 0 {
+		log.Fatalf("Invalid -batch: must be positive")
+	}
+
+	generator := NewGenerator(GeneratorConfig{
+		Site:        *site,
+		Cities:      cityWeights,
+		PriceMedian: *priceMedian,
+		PriceSpread: *priceSpread,
+		MinPhotos:   *minPhotos,
+		MaxPhotos:   *maxPhotos,
+		FirstID:     *firstID,
+	}, *seed)
+
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Error loading .env file: %v", err)
+	}
+
+	cfg := config.Load()
+	locale, _ := format.ParseLocale(cfg.DisplayLocale)
+
+	ctx := context.Background()
+
+	// Fixtures go to the store selected by STORAGE_BACKEND
+	var store storage.Store
+	if !*dryRun {
+		store, err = storage.Open(ctx, cfg)
+		if err != nil {
+			log.Fatalf("Failed to open %s storage: %v", cfg.Storage.Backend, err)
+		}
+		defer store.Close()
+	}
+
+	start := time.Now()
+	var inserted int
+
+	for inserted < *count {
+		size := *batchSize
+		if remaining := *count - inserted; remaining < size {
+			size = remaining
+		}
+
+		listings := make([]*clickhouse.FlattenedListing, size)
+		for i := range listings {
+			listings[i] = clickhouse.Flatten(generator.Next())
+		}
+
+		if !*dryRun {
+			batchStart := time.Now()
+			if err := store.BatchInsertFlattenedListings(ctx, listings); err != nil {
+				log.Fatalf("Failed to insert batch at listing %d: %v", inserted, err)
+			}
+			fmt.Printf("Inserted %s listings in %v\n", locale.Int(int64(size)), time.Since(batchStart))
+		}
+
+		inserted += size
+	}
+
+	elapsed := time.Since(start)
+	fmt.Printf("Generated %s listings for %s in %v (%s listings/s)\n",
+		locale.Int(int64(inserted)), *site, elapsed, locale.Decimal(float64(inserted)/elapsed.Seconds(), 0))
+}
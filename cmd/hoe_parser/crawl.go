@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+	"github.com/gregor-tokarev/hoe_parser/internal/lifecycle"
+)
+
+const crawlUsage = `Usage:
+  hoe_parser crawl status
+  hoe_parser crawl pause [-reason <text>]
+  hoe_parser crawl resume
+
+Pauses and resumes discovery and scraping of a running parser through its API, which keeps
+serving while the crawl is paused. Needs an admin API key (-key, API_KEY by default).`
+
+// runCrawl implements `hoe_parser crawl`, which pauses, resumes or shows the crawl of a running
+// parser through the admin endpoints of its API
+func runCrawl(args []string) {
+	cfg := config.Load()
+
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, crawlUsage)
+		os.Exit(2)
+	}
+	command := args[0]
+
+	flags := flag.NewFlagSet("crawl "+command, flag.ExitOnError)
+	addr := flags.String("addr", net.JoinHostPort(cfg.Host, cfg.Port), "API address (host:port)")
+	key := flags.String("key", cfg.APIKey, "admin API key")
+	reason := flags.String("reason", "", "why the crawl is paused, shown by crawl status")
+	flags.Parse(args[1:])
+
+	var method, path string
+	var body io.Reader
+	switch command {
+	case "status":
+		method, path = http.MethodGet, "/api/v1/admin/crawl"
+	case "pause":
+		data, _ := json.Marshal(map[string]string{"reason": *reason})
+		method, path, body = http.MethodPost, "/api/v1/admin/crawl/pause", bytes.NewReader(data)
+	case "resume":
+		method, path = http.MethodPost, "/api/v1/admin/crawl/resume"
+	default:
+		fmt.Fprintln(os.Stderr, crawlUsage)
+		os.Exit(2)
+	}
+
+	baseURL := *addr
+	if !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+		baseURL = "http://" + baseURL
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	state, err := requestCrawl(ctx, method, baseURL+path, *key, body)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to %s the crawl: %v\n", command, err)
+		os.Exit(1)
+	}
+
+	if !state.Paused {
+		fmt.Println("Crawl running")
+		return
+	}
+	fmt.Printf("Crawl paused since %s by %s", state.Since.Local().Format(time.RFC3339), state.By)
+	if state.Reason != "" {
+		fmt.Printf(": %s", state.Reason)
+	}
+	fmt.Println()
+}
+
+// requestCrawl sends a request to a crawl endpoint and decodes the crawl state it returns
+func requestCrawl(ctx context.Context, method, url, key string, body io.Reader) (*lifecycle.PauseState, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-API-Key", key)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach the API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiError struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiError)
+		return nil, fmt.Errorf("api returned %s: %s", resp.Status, apiError.Error)
+	}
+
+	var state lifecycle.PauseState
+	if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+		return nil, fmt.Errorf("failed to decode crawl state: %w", err)
+	}
+	return &state, nil
+}
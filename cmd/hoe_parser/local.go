@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/api"
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+	"github.com/gregor-tokarev/hoe_parser/internal/logger"
+	"github.com/gregor-tokarev/hoe_parser/internal/modules/request_client"
+	"github.com/gregor-tokarev/hoe_parser/internal/scheduler"
+	"github.com/gregor-tokarev/hoe_parser/internal/scraper"
+	"github.com/gregor-tokarev/hoe_parser/internal/service"
+	"github.com/gregor-tokarev/hoe_parser/internal/storage"
+)
+
+// runLocal runs the pipeline on a storage.Store instead of ClickHouse, for small deployments with
+// STORAGE_BACKEND=postgres and development with STORAGE_BACKEND=sqlite: the index crawls send
+// links, workers scrape and store the listings with change detection, and the API serves listing
+// queries and statistics. Exclusions, scrape attempts, photo hashing, sinks and the dashboard need
+// ClickHouse and are not run.
+func runLocal(cfg *config.Config) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	store, err := storage.Open(ctx, cfg)
+	if err != nil {
+		log.Error("Failed to open local storage", "backend", cfg.Storage.Backend, "error", err)
+		os.Exit(1)
+	}
+	defer store.Close()
+	log.Info("Running without ClickHouse", "backend", cfg.Storage.Backend)
+
+	if keys, err := api.LoadKeyStore(cfg.APIKeysFile, cfg.APIKey); err != nil {
+		log.Warn("API disabled", "error", err)
+	} else {
+		go func() {
+			apiAddr := net.JoinHostPort(cfg.Host, cfg.Port)
+			log.Info("API listening", "url", "http://"+apiAddr)
+			server := api.NewStoreServer(store, keys)
+			server.SetMiddleware(api.MiddlewareOptionsFromConfig(cfg))
+			if err := server.Serve(ctx, apiAddr); err != nil {
+				log.Error("API server stopped", "error", err)
+			}
+		}()
+	}
+
+	// The index crawls of every site are the only senders on linkChan, so it is closed once they stop
+	linkChan := make(chan scraper.ListingLink, 25)
+	crawls := scheduler.NewScheduler(nil)
+	for _, siteAdapter := range scraper.DefaultRegistry.Adapters() {
+		crawler := scraper.NewDiscovery(siteAdapter)
+		addCrawl(crawls, cfg.Scheduler, siteAdapter.Name(), "index", cfg.Scheduler.Index, func(ctx context.Context) error {
+			return crawler.RunDiscoveryCycle(ctx, linkChan)
+		})
+		addCrawl(crawls, cfg.Scheduler, siteAdapter.Name(), "rescrape", cfg.Scheduler.Rescrape, func(ctx context.Context) error {
+			return crawler.RunRescrapeCycle(ctx, linkChan)
+		})
+	}
+	go func() {
+		defer close(linkChan)
+		crawls.Run(ctx)
+	}()
+
+	var wg sync.WaitGroup
+	for range max(cfg.Parser.Workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for link := range linkChan {
+				if ctx.Err() == nil {
+					storeLocal(ctx, store, link)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	log.Info("Local pipeline stopped")
+}
+
+// storeLocal scrapes a listing and stores it when it is new or changed
+func storeLocal(ctx context.Context, store storage.Store, link scraper.ListingLink) {
+	link.URL = scraper.CanonicalListingURL(link.URL)
+	listingID := clickhouse.CompositeID(clickhouse.SourceSiteFromURL(link.URL), link.ID)
+	ctx = logger.WithListingID(ctx, listingID)
+	ctx = request_client.WithSessionKey(ctx, listingID)
+
+	siteAdapter, err := scraper.AdapterForURL(link.URL)
+	if err != nil {
+		log.WarnContext(ctx, "Failed to scrape listing", "url", link.URL, "error", err)
+		return
+	}
+	listing, err := siteAdapter.ScrapeListing(ctx, link.URL)
+	if service.IsGone(err) {
+		log.InfoContext(ctx, "Listing removed from site", "url", link.URL)
+		return
+	}
+	if err != nil {
+		log.WarnContext(ctx, "Failed to scrape listing", "url", link.URL, "error", err)
+		return
+	}
+
+	changes, written, err := storage.UpsertIfChanged(ctx, store, clickhouse.Flatten(listing, link.URL))
+	switch {
+	case err != nil:
+		log.WarnContext(ctx, "Failed to store listing", "url", link.URL, "error", err)
+	case len(changes) > 0:
+		log.InfoContext(ctx, "Listing changed", "fields", len(changes))
+	case written:
+		log.InfoContext(ctx, "Listing stored", "url", link.URL)
+	}
+}
@@ -3,77 +3,771 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
+	"net"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
+	"github.com/gregor-tokarev/hoe_parser/internal/alerting"
+	"github.com/gregor-tokarev/hoe_parser/internal/api"
+	"github.com/gregor-tokarev/hoe_parser/internal/archive"
+	"github.com/gregor-tokarev/hoe_parser/internal/autoscale"
 	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
 	"github.com/gregor-tokarev/hoe_parser/internal/config"
+	"github.com/gregor-tokarev/hoe_parser/internal/dedup"
+	"github.com/gregor-tokarev/hoe_parser/internal/diagnostics"
+	"github.com/gregor-tokarev/hoe_parser/internal/events"
+	"github.com/gregor-tokarev/hoe_parser/internal/kafka"
+	"github.com/gregor-tokarev/hoe_parser/internal/lifecycle"
+	"github.com/gregor-tokarev/hoe_parser/internal/logger"
+	"github.com/gregor-tokarev/hoe_parser/internal/metrics"
 	"github.com/gregor-tokarev/hoe_parser/internal/modules/request_client"
+	"github.com/gregor-tokarev/hoe_parser/internal/scheduler"
 	"github.com/gregor-tokarev/hoe_parser/internal/scraper"
+	"github.com/gregor-tokarev/hoe_parser/internal/service"
+	"github.com/gregor-tokarev/hoe_parser/internal/sink"
+	"github.com/gregor-tokarev/hoe_parser/internal/storage"
+	"github.com/gregor-tokarev/hoe_parser/internal/telemetry"
+	"github.com/gregor-tokarev/hoe_parser/internal/translate"
+	"github.com/gregor-tokarev/hoe_parser/internal/webhook"
+	"github.com/gregor-tokarev/hoe_parser/pkg/format"
 	listing "github.com/gregor-tokarev/hoe_parser/proto"
 	"github.com/joho/godotenv"
 )
 
+// log is the component logger of the parser process
+var log = logger.Component("main")
+
 func main() {
 	if err := godotenv.Load(); err != nil {
-		log.Printf("Error loading .env file: %v", err)
+		log.Warn("Failed to load .env file", "error", err)
 	}
 
-	fmt.Println("Starting ClickHouse Adapter Example...")
+	if len(os.Args) > 1 && os.Args[1] == "top" {
+		runTop(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "verify-site" {
+		runVerifySite(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "record-fixture" {
+		runRecordFixture(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "crawl" {
+		runCrawl(os.Args[2:])
+		return
+	}
 
 	// Load configuration from environment variables
 	cfg := config.Load()
-	fmt.Printf("Loaded configuration: ClickHouse Host=%s, Port=%d, Database=%s\n",
-		cfg.ClickHouse.Host, cfg.ClickHouse.Port, cfg.ClickHouse.Database)
+	if err := logger.FromConfig(cfg); err != nil {
+		log.Warn("Falling back to default logging", "error", err)
+	}
+	log.Info("Loaded configuration", "clickhouse_host", cfg.ClickHouse.Host,
+		"clickhouse_port", cfg.ClickHouse.Port, "clickhouse_database", cfg.ClickHouse.Database)
 
 	// Initialize global proxy client
 	request_client.InitGlobalClient(cfg)
-	fmt.Printf("Initialized proxy client with %d proxies\n", len(cfg.Proxies))
+	log.Info("Initialized proxy client", "proxies", len(cfg.Proxies))
+	request_client.GetGlobalClient().SetBurnHandler(func(event request_client.BurnEvent) {
+		log.Warn("Proxy burned", "proxy", request_client.RedactProxy(event.Proxy), "site", event.Site, "until", event.Until.Format(time.RFC3339), "status", event.StatusCode)
+		metrics.ProxyBurns.WithLabelValues(event.Site, strconv.Itoa(event.StatusCode)).Inc()
+	})
+	request_client.GetGlobalClient().SetQuarantineHandler(func(request_client.QuarantineEvent) {
+		metrics.ProxyQuarantines.Inc()
+	})
+	service.SetFetchRetryPolicy(service.FetchRetryPolicy{
+		MaxAttempts: cfg.Parser.FetchMaxAttempts,
+		BaseDelay:   cfg.Parser.FetchBackoffBase,
+		MaxDelay:    cfg.Parser.FetchBackoffMax,
+	})
+	service.SetBlockPageMinSize(cfg.Parser.BlockPageMinSize)
+	if budget := request_client.GetGlobalClient().RetryBudget(); budget != nil {
+		budget.SetTripHandler(func(trip request_client.RetryBudgetTrip) {
+			log.Warn("Retry budget exhausted, pausing all requests", "retries", trip.Retries,
+				"requests", trip.Requests, "until", trip.Until.Format(time.RFC3339))
+			metrics.RetryBudgetTrips.Inc()
+		})
+	}
+
+	// PostgreSQL and SQLite run the pipeline on a storage.Store, without ClickHouse
+	switch cfg.Storage.Backend {
+	case storage.BackendPostgres, storage.BackendSQLite:
+		runLocal(cfg)
+		return
+	case storage.BackendClickHouse, "":
+	default:
+		log.Error("Unknown storage backend", "backend", cfg.Storage.Backend)
+		os.Exit(1)
+	}
 
 	// Create ClickHouse adapter using configuration
 	chConfig := clickhouse.FromMainConfig(cfg, cfg.Debug)
 
 	adapter, err := clickhouse.NewAdapter(chConfig)
 	if err != nil {
-		log.Fatalf("Failed to create ClickHouse adapter: %v", err)
+		log.Error("Failed to create ClickHouse adapter", "error", err)
+		os.Exit(1)
 	}
-	defer adapter.Close()
 
-	fmt.Println("Connected to ClickHouse successfully!")
+	log.Info("Connected to ClickHouse")
 
-	// Create scrapers
-	goldScraper := scraper.NewHomePageScraper()
+	// Components register their shutdown steps as they are created; Ctrl+C runs them stage by stage
+	shutdown := lifecycle.NewCoordinator(cfg.Shutdown.Timeout)
+
+	// Every registered site has its own index crawl reading the index through its adapter; the
+	// index-only mode reads the price cards of intimcity.gold's index pages
+	var crawlers []*scraper.HomePageScraper
+	if cfg.Parser.Mode == config.ParserModeIndexOnly {
+		crawlers = append(crawlers, scraper.NewHomePageScraper())
+	} else {
+		for _, siteAdapter := range scraper.DefaultRegistry.Adapters() {
+			crawlers = append(crawlers, scraper.NewDiscovery(siteAdapter))
+		}
+	}
+
+	// Index crawls run on schedules, which admin keys can pause and resume through the API
+	var scheduleStore scheduler.StateStore
+	if cfg.Scheduler.StatePath != "" {
+		scheduleStore = scheduler.NewFileStateStore(cfg.Scheduler.StatePath)
+	}
+	crawls := scheduler.NewScheduler(scheduleStore)
+
+	// Admin keys can also pause the whole crawl, discovery and scraping, e.g. while the site is in
+	// maintenance; the API and storage keep serving
+	crawlPause := lifecycle.NewPause()
+	for _, crawler := range crawlers {
+		crawler.SetGate(crawlPause)
+	}
 
 	// Create channel for shutdown signals
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
 
-	// Channel to receive new listing URLs
-	linkChan := make(chan string, 25)
+	// Channel to receive new listing links, stamped with their discovery time
+	linkChan := make(chan scraper.ListingLink, 25)
 
 	// Context for the entire application (no timeout)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Start gold scraper monitoring in a goroutine
+	// Pipeline diagnostics, read by `hoe_parser top`
+	tracker := diagnostics.NewTracker(0)
+	for _, crawler := range crawlers {
+		site := crawler.Site()
+		crawler.SetProgressFunc(func(cycle, page, totalPages, links int, err error) {
+			tracker.SetSiteProgress(site, cycle, page, totalPages)
+			tracker.AddLinksDiscovered(site, links)
+			tracker.RecordError("index", err)
+		})
+	}
+	linkQueue := func() (int, int) { return len(linkChan), cap(linkChan) }
+	tracker.RegisterQueue("links", linkQueue)
+	metrics.RegisterQueue("links", linkQueue)
+
+	// Pipeline stages publish typed events; metrics, diagnostics and webhooks subscribe
+	bus := events.NewBus()
+	bus.SetDropHandler(func(subscriber string, event events.Event) {
+		metrics.EventsDropped.WithLabelValues(subscriber).Inc()
+	})
+	for _, crawler := range crawlers {
+		crawler.SetEventBus(bus)
+	}
+
+	// Carry counters and the last crawl position over restarts
+	persisted := make(chan struct{})
+	if store := metricsSnapshotStore(ctx, cfg); store != nil {
+		state, err := store.Load(ctx)
+		if err == nil {
+			err = tracker.RestoreState(state)
+		}
+		if err != nil {
+			log.Warn("Failed to restore metrics snapshot", "error", err)
+		} else if state != nil {
+			log.Info("Restored metrics snapshot", "saved_at", state.SavedAt.Format(time.RFC3339))
+		}
+		go func() {
+			defer close(persisted)
+			diagnostics.RunStatePersistence(ctx, tracker, store, cfg.MetricsSnapshot.Interval)
+		}()
+	} else {
+		close(persisted)
+	}
+
+	// Share crawl progress with the other instances for the fleet view of `hoe_parser top -fleet`
+	if cfg.FleetProgress.Enabled {
+		if client, err := dedup.NewRedisClient(ctx, cfg); err != nil {
+			log.Warn("Fleet progress disabled", "error", err)
+		} else {
+			fleet := diagnostics.NewRedisFleetStore(client, cfg.FleetProgress.RedisKey, cfg.FleetProgress.StaleAfter)
+			tracker.SetFleetStore(fleet)
+			go diagnostics.RunFleetReporter(ctx, tracker, fleet, cfg.FleetProgress.Instance, cfg.FleetProgress.Interval)
+		}
+	}
+
+	// Carry the cookies of the proxy jars over restarts
+	cookiesSaved := make(chan struct{})
+	if store := cookieStore(ctx, cfg); store != nil {
+		client := request_client.GetGlobalClient()
+		state, err := store.Load(ctx)
+		if err != nil {
+			log.Warn("Failed to restore cookies", "error", err)
+		} else if state != nil {
+			client.RestoreCookies(state)
+			log.Info("Restored cookies", "saved_at", state.SavedAt.Format(time.RFC3339), "jars", len(state.Jars))
+		}
+		go func() {
+			defer close(cookiesSaved)
+			request_client.RunCookiePersistence(ctx, client, store, cfg.Proxy.CookieStoreInterval)
+		}()
+	} else {
+		close(cookiesSaved)
+	}
+
+	// Servers and background jobs run on ctx; the final metrics snapshot and cookies are saved
+	// once they stop
+	shutdown.Register(lifecycle.StageBackground, "background jobs", func(stopCtx context.Context) error {
+		cancel()
+		if err := lifecycle.WaitFor(persisted)(stopCtx); err != nil {
+			return err
+		}
+		return lifecycle.WaitFor(cookiesSaved)(stopCtx)
+	})
+
+	// Remember emitted links so every monitoring cycle only sends listings not seen within the TTL
+	if cfg.Dedup.Enabled {
+		seen, err := dedup.FromConfig(ctx, cfg)
+		if err != nil {
+			log.Warn("Link dedup falling back to memory", "error", err)
+			memory := dedup.NewMemorySeenSet(cfg.Dedup.TTL)
+			for _, crawler := range crawlers {
+				crawler.SetSeenSet(memory)
+			}
+		} else {
+			shutdown.Register(lifecycle.StageClose, "dedup", lifecycle.Close(seen.Close))
+			for _, crawler := range crawlers {
+				crawler.SetSeenSet(seen)
+			}
+		}
+	}
+
+	// Skip link extraction on index pages that did not change since the previous cycle; every site
+	// keeps the fingerprints of its pages under its own key prefix
+	if cfg.IndexFingerprint.Enabled {
+		client, err := dedup.NewRedisClient(ctx, cfg)
+		if err != nil {
+			log.Warn("Index page fingerprints falling back to memory", "error", err)
+		} else {
+			shutdown.Register(lifecycle.StageClose, "index fingerprints", lifecycle.Close(client.Close))
+		}
+		for _, crawler := range crawlers {
+			if client == nil {
+				crawler.SetPageFingerprints(dedup.NewMemoryPageFingerprints(cfg.IndexFingerprint.TTL), cfg.IndexFingerprint.Selector)
+				continue
+			}
+			prefix := cfg.IndexFingerprint.KeyPrefix + crawler.Site() + ":"
+			crawler.SetPageFingerprints(dedup.NewRedisPageFingerprints(client, prefix, cfg.IndexFingerprint.TTL), cfg.IndexFingerprint.Selector)
+		}
+	}
+
+	// Randomized politeness delay between index page requests, recorded in crawl_audit
+	var auditChan chan clickhouse.CrawlAudit
+	if cfg.Parser.CrawlAudit {
+		auditChan = make(chan clickhouse.CrawlAudit, 100)
+		go runCrawlAudit(ctx, adapter, auditChan)
+	}
+	for _, crawler := range crawlers {
+		site := crawler.Site()
+		crawler.SetDelaySchedule(scraper.DelaySchedule{Base: cfg.Parser.PageDelay, Jitter: cfg.Parser.PageJitter})
+		crawler.SetPagination(sitePagination(cfg.Parser, site))
+		if auditChan == nil {
+			continue
+		}
+		crawler.SetAuditFunc(func(request scraper.CrawlRequest) {
+			select {
+			case auditChan <- crawlAuditEntry(site, request):
+			default:
+				log.Warn("Crawl audit queue full, dropping record", "url", request.URL)
+			}
+		})
+	}
+
+	// Notify operators when a site is paused after a site-wide ban and when it resumes
+	notifier := webhook.FromConfig(cfg)
+	if guard := request_client.GetGlobalClient().SiteGuard(); guard != nil {
+		guard.SetChangeHandler(func(event request_client.SiteBanEvent) {
+			log.Warn("Site state changed", "site", event.Site, "state", event.State, "reason", event.Reason)
+			tracker.SetSiteState(clickhouse.SourceSiteFromURL("https://"+event.Site), string(event.State))
+			if event.State == request_client.SitePaused {
+				metrics.SitePauses.WithLabelValues(event.Site).Inc()
+				tracker.RecordError("ban", fmt.Errorf("%s paused until %s: %s", event.Site, event.Until.Format(time.RFC3339), event.Reason))
+			}
+
+			go func() {
+				sendCtx, sendCancel := context.WithTimeout(ctx, cfg.Webhook.Timeout)
+				defer sendCancel()
+				if err := notifier.Send(sendCtx, "site."+string(event.State), event); err != nil {
+					log.Warn("Failed to send site state webhook", "error", err)
+				}
+			}()
+		})
+	}
+
+	if notifier.Enabled() && len(cfg.Webhook.Events) > 0 {
+		forwardEvents(ctx, bus, notifier, cfg.Webhook.Events, cfg.Webhook.Timeout)
+	}
+
 	go func() {
-		fmt.Println("Starting continuous gold scraper monitoring...")
-		err := goldScraper.StartContinuousMonitoring(linkChan)
+		log.Info("Diagnostics available", "url", "http://"+cfg.DiagnosticsAddr+diagnostics.PipelinePath)
+		if err := tracker.Serve(ctx, cfg.DiagnosticsAddr); err != nil {
+			log.Error("Diagnostics server stopped", "error", err)
+		}
+	}()
+
+	if cfg.EnableMetrics {
+		go func() {
+			if err := metrics.Serve(ctx, ":"+cfg.MetricsPort); err != nil {
+				log.Error("Metrics server stopped", "error", err)
+			}
+		}()
+		go runProxyGeoMetrics(ctx, request_client.GetGlobalClient())
+	}
+
+	// HTTP API, each key restricted to its configured scope
+	if keys, err := api.LoadKeyStore(cfg.APIKeysFile, cfg.APIKey); err != nil {
+		log.Warn("API disabled", "error", err)
+	} else {
+		go func() {
+			apiAddr := net.JoinHostPort(cfg.Host, cfg.Port)
+			log.Info("API listening", "url", "http://"+apiAddr)
+			server := api.NewServer(adapter, keys)
+			server.SetMinBucket(cfg.AggregateMinBucket)
+			server.SetScheduler(crawls)
+			server.SetCrawlController(crawlPause)
+			server.SetScraping(scrapeCache(ctx, cfg))
+			server.SetMiddleware(api.MiddlewareOptionsFromConfig(cfg))
+			if err := server.SetMode(cfg.APIMode); err != nil {
+				log.Warn("Invalid API mode, serving aggregate statistics only", "error", err)
+				server.SetMode(api.ModeAggregate)
+			}
+			if location, err := time.LoadLocation(cfg.DisplayTimezone); err != nil {
+				log.Warn("Invalid display time zone, showing UTC", "timezone", cfg.DisplayTimezone, "error", err)
+			} else {
+				server.SetLocation(location)
+			}
+			locale, err := format.ParseLocale(cfg.DisplayLocale)
+			if err != nil {
+				log.Warn("Invalid display locale, using English", "error", err)
+			}
+			server.SetLocale(locale)
+			if cfg.Stream.Enabled {
+				server.SetEventStream(bus, api.StreamOptionsFromConfig(cfg))
+				log.Info("Event stream available", "url", "http://"+apiAddr+"/api/v1/events/stream")
+			}
+			if cfg.DashboardEnabled {
+				server.SetDashboard(api.DashboardSources{
+					Pipeline: tracker.Snapshot,
+					Proxies:  request_client.GetGlobalClient().GetProxyStats,
+				})
+				log.Info("Dashboard available", "url", "http://"+apiAddr+"/dashboard")
+			}
+			if err := server.Serve(ctx, apiAddr); err != nil {
+				log.Error("API server stopped", "error", err)
+			}
+		}()
+	}
+
+	// Keep the exclusion list in sync with changes made through the API or CLI
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := adapter.RefreshExclusions(ctx); err != nil {
+					log.Warn("Failed to refresh exclusions", "error", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Keep dashboard_stats fresh so dashboard reads never scan the listings table
+	go runDashboardStats(ctx, adapter, cfg.DashboardStatsInterval)
+
+	// Anonymous usage reports, only when opted in with TELEMETRY_ENABLED
+	if reporter := telemetry.FromConfig(cfg); reporter != nil {
+		reporter.Subscribe(bus)
+		go reporter.Run(ctx)
+		log.Info("Sending anonymous usage reports", "endpoint", cfg.Telemetry.Endpoint, "interval", cfg.Telemetry.Interval,
+			"version", telemetry.Version(), "subsystems", telemetry.Subsystems(cfg))
+	}
+
+	if cfg.Parser.Mode == config.ParserModeIndexOnly {
+		indexCtx, stopIndex := context.WithCancel(ctx)
+		indexed := make(chan struct{})
+		go func() {
+			defer close(indexed)
+			runIndexOnly(indexCtx, crawlers[0], adapter, tracker, crawls, cfg.Scheduler)
+		}()
+		shutdown.Register(lifecycle.StageIntake, "price monitoring", func(stopCtx context.Context) error {
+			stopIndex()
+			return lifecycle.WaitFor(indexed)(stopCtx)
+		})
+	} else {
+		translator, err := translate.FromConfig(cfg.Translation)
+		if err != nil {
+			log.Error("Failed to configure description translation", "error", err)
+			os.Exit(1)
+		}
+		// Alerts go to the webhooks and the errors topic
+		channels := []alerting.Notifier{notifier}
+		if cfg.KafkaEnabled && (cfg.CoverageAlert.Enabled || cfg.GapAlert.Enabled) {
+			producer := kafka.NewProducer(cfg.KafkaBrokers, cfg.KafkaTopics.Errors)
+			shutdown.Register(lifecycle.StageClose, "kafka", lifecycle.Close(producer.Close))
+			channels = append(channels, producer)
+		}
+
+		// Alert when critical fields stop being parsed
+		var coverage *alerting.CoverageMonitor
+		if cfg.CoverageAlert.Enabled {
+			alertCfg := cfg.CoverageAlert
+			coverage = alerting.NewCoverageMonitor(alertCfg.Window, alertCfg.Thresholds, alertCfg.MinSamples, alertCfg.SampleURLs)
+			go runCoverageAlerts(ctx, coverage, alertCfg.CheckInterval, channels)
+		}
+
+		// Alert when discovery misses listings, judged by the gaps in the listing ID sequence;
+		// the gaps job sends the missing IDs to be scraped
+		var gaps *alerting.GapMonitor
+		if cfg.GapAlert.Enabled {
+			gaps = alerting.NewGapMonitor(cfg.GapAlert.Span, cfg.GapAlert.JumpThreshold, cfg.GapAlert.DensityDelta)
+			observeListingIDs(ctx, bus, gaps, channels)
+			go runGapAlerts(ctx, gaps, cfg.GapAlert.CheckInterval, channels)
+		}
+
+		// Copy every stored listing to the configured sinks and a share of them to the staging
+		// mirrors; their queues drain before the bus closes
+		sinks, err := sink.FromConfig(cfg)
 		if err != nil {
-			log.Printf("Gold scraper monitoring failed: %v", err)
+			log.Error("Failed to configure storage sinks", "error", err)
+			os.Exit(1)
 		}
+		mirrors, err := sink.MirrorsFromConfig(cfg)
+		if err != nil {
+			log.Error("Failed to configure staging mirrors", "error", err)
+			os.Exit(1)
+		}
+		if len(sinks)+len(mirrors) > 0 {
+			fanout := sink.NewFanout(append(sinks, mirrors...), cfg.Sinks.WriteTimeout)
+			fanout.Subscribe(bus, cfg.Sinks.Buffer)
+			shutdown.Register(lifecycle.StageNotify, "sinks", lifecycle.Close(fanout.Close))
+			log.Info("Copying stored listings to sinks", "sinks", cfg.Sinks.Enabled, "mirrors", len(mirrors), "mirror_percent", cfg.Mirror.Percent)
+		}
+
+		// Hash the photos of stored listings for duplicate profile detection; queued listings are
+		// hashed before the bus closes
+		if cfg.PhotoHash.Enabled {
+			hasher := dedup.NewPhotoHasherFromConfig(adapter, cfg)
+			if cfg.PhotoHash.Queue {
+				setupPhotoQueue(ctx, cfg, hasher, shutdown)
+			}
+			hasher.Subscribe(bus, cfg.PhotoHash.Buffer)
+			shutdown.Register(lifecycle.StageNotify, "photo hashes", lifecycle.Close(hasher.Close))
+			log.Info("Hashing listing photos for duplicate detection", "max_photos", cfg.PhotoHash.MaxPhotos, "parallelism", cfg.PhotoHash.Parallelism)
+		}
+
+		// Batch scraped listings into ClickHouse; buffered rows are flushed once more on shutdown,
+		// after the workers have stopped adding rows
+		var writer *clickhouse.BufferedWriter
+		if cfg.ClickHouse.InsertBuffer.Enabled {
+			writer = clickhouse.NewBufferedWriter(adapter, clickhouse.BufferFromConfig(cfg.ClickHouse.InsertBuffer))
+			writer.SetFlushHandler(func(event clickhouse.FlushEvent) {
+				metrics.ObserveBufferFlush(event.Rows, event.Buffered, event.Err)
+				if event.Err != nil {
+					log.Error("Insert buffer dropped rows", "rows", event.Rows, "error", event.Err)
+				}
+			})
+			writerCtx, stopWriter := context.WithCancel(ctx)
+			flushed := make(chan struct{})
+			go func() {
+				defer close(flushed)
+				writer.Run(writerCtx)
+			}()
+			shutdown.Register(lifecycle.StageFlush, "insert buffer", func(stopCtx context.Context) error {
+				stopWriter()
+				return lifecycle.WaitFor(flushed)(stopCtx)
+			})
+		}
+
+		// Raw listing pages are kept for re-parsing after extractor fixes
+		pageArchive, err := archive.NewStoreFromConfig(cfg)
+		if err != nil {
+			log.Error("Failed to set up the HTML archive", "error", err)
+			os.Exit(1)
+		}
+		if pageArchive != nil {
+			log.Info("Archiving listing pages", "backend", cfg.HTMLArchive.Backend)
+		}
+
+		// Selectors and labels come from the extraction config file when one is set, reloaded as it changes
+		if cfg.Parser.ExtractionConfig != "" {
+			profiles, err := scraper.ConfigureExtraction(cfg.Parser.ExtractionConfig)
+			if err != nil {
+				log.Error("Failed to load the extraction config", "path", cfg.Parser.ExtractionConfig, "error", err)
+				os.Exit(1)
+			}
+			log.Info("Loaded extraction config", "path", cfg.Parser.ExtractionConfig)
+			if cfg.Parser.ExtractionReloadInterval > 0 {
+				go profiles.Watch(ctx, cfg.Parser.ExtractionReloadInterval)
+			}
+		}
+
+		// Sites behind JavaScript challenges are rendered in a headless browser, one pool for all of them
+		if len(cfg.Browser.Sites) > 0 {
+			browser := service.NewBrowserFetcher(cfg.Browser.Binary, cfg.Browser.MaxInstances, cfg.Browser.Timeout)
+			shutdown.Register(lifecycle.StageClose, "browser", lifecycle.Close(browser.Close))
+			for _, site := range cfg.Browser.Sites {
+				if _, ok := scraper.AdapterByName(site); !ok {
+					log.Warn("No site adapter for browser fallback", "site", site)
+					continue
+				}
+				service.SetBrowserFallback(site, browser)
+			}
+			metrics.RegisterQueue("browser", browser.InUse)
+			log.Info("Browser fallback enabled", "sites", cfg.Browser.Sites, "binary", cfg.Browser.Binary, "max_instances", cfg.Browser.MaxInstances)
+		}
+
+		runFull(ctx, pipeline{
+			crawlers:     crawlers,
+			adapter:      adapter,
+			linkChan:     linkChan,
+			tracker:      tracker,
+			bus:          bus,
+			shutdown:     shutdown,
+			crawls:       crawls,
+			crawlPause:   crawlPause,
+			schedulerCfg: cfg.Scheduler,
+			parserCfg:    cfg.Parser,
+			drainTimeout: cfg.Shutdown.DrainTimeout,
+			freshnessSLO: cfg.FreshnessSLO,
+			telegramCfg:  cfg.Telegram,
+			translator:   translator,
+			coverage:     coverage,
+			gaps:         gaps,
+			gapBatch:     cfg.GapAlert.BackfillBatch,
+			writer:       writer,
+			pageArchive:  pageArchive,
+		})
+	}
+
+	// Queued events reach the diagnostics, metrics and webhooks before the background jobs stop
+	shutdown.Register(lifecycle.StageNotify, "events", func(context.Context) error {
+		bus.Close()
+		return nil
+	})
+	// ClickHouse closes last, after every component writing to it has stopped
+	shutdown.Register(lifecycle.StageClose, "clickhouse", lifecycle.Close(adapter.Close))
+
+	log.Info("Parser is running, press Ctrl+C to stop")
+	<-signalChan
+
+	log.Info("Shutdown signal received, stopping", "timeout", cfg.Shutdown.Timeout)
+	go func() {
+		<-signalChan
+		log.Warn("Second shutdown signal received, exiting immediately")
+		os.Exit(1)
 	}()
 
+	if err := shutdown.Shutdown(context.Background()); err != nil {
+		log.Error("Shutdown incomplete", "error", err)
+		os.Exit(1)
+	}
+	log.Info("Shutdown complete")
+}
+
+// setupPhotoQueue queues the listings awaiting photo downloads in Redis, so they survive restarts,
+// and drains the queue with PHOTO_QUEUE_WORKERS workers; without Redis the photos are hashed as
+// listings are stored
+func setupPhotoQueue(ctx context.Context, cfg *config.Config, hasher *dedup.PhotoHasher, shutdown *lifecycle.Coordinator) {
+	client, err := dedup.NewRedisClient(ctx, cfg)
+	if err != nil {
+		log.Warn("Photo queue disabled, hashing photos as listings are stored", "error", err)
+		return
+	}
+	shutdown.Register(lifecycle.StageClose, "photo queue", lifecycle.Close(client.Close))
+
+	hasher.SetQueue(dedup.NewRedisPhotoQueue(client, cfg.PhotoHash.QueueKey), dedup.PhotoQueueOptionsFromConfig(cfg))
+	if cfg.PhotoHash.QueueWorkers <= 0 {
+		log.Info("Queueing listing photos for photo_worker", "key", cfg.PhotoHash.QueueKey)
+		return
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		hasher.Drain(ctx, cfg.PhotoHash.QueueWorkers)
+	}()
+	shutdown.Register(lifecycle.StageBackground, "photo queue workers", lifecycle.WaitFor(drained))
+	log.Info("Draining the photo queue", "key", cfg.PhotoHash.QueueKey, "workers", cfg.PhotoHash.QueueWorkers)
+}
+
+// metricsSnapshotStore returns the configured store for the metrics snapshot, or nil when disabled
+func metricsSnapshotStore(ctx context.Context, cfg *config.Config) diagnostics.StateStore {
+	snapshotCfg := cfg.MetricsSnapshot
+	if !snapshotCfg.Enabled {
+		return nil
+	}
+
+	if snapshotCfg.Backend == "redis" {
+		client, err := dedup.NewRedisClient(ctx, cfg)
+		if err != nil {
+			log.Warn("Metrics snapshot falling back to file", "path", snapshotCfg.Path, "error", err)
+			return diagnostics.NewFileStateStore(snapshotCfg.Path)
+		}
+		return diagnostics.NewRedisStateStore(client, snapshotCfg.RedisKey)
+	}
+	return diagnostics.NewFileStateStore(snapshotCfg.Path)
+}
+
+// scrapeCache returns the Redis cache of the on-demand scrapes of the API, or nil when disabled or
+// Redis is unreachable
+func scrapeCache(ctx context.Context, cfg *config.Config) api.ScrapeCache {
+	if !cfg.ScrapeCache.Enabled {
+		return nil
+	}
+
+	client, err := dedup.NewRedisClient(ctx, cfg)
+	if err != nil {
+		log.Warn("Scrape cache disabled", "error", err)
+		return nil
+	}
+	log.Info("Caching API scrapes", "ttl", cfg.ScrapeCache.TTL)
+	return api.NewRedisScrapeCache(client, cfg.ScrapeCache.KeyPrefix, cfg.ScrapeCache.TTL)
+}
+
+// cookieStore returns the configured store for the cookies of the proxy jars, or nil when they are
+// not persisted
+func cookieStore(ctx context.Context, cfg *config.Config) request_client.CookieStore {
+	proxyCfg := cfg.Proxy
+	if !proxyCfg.CookieJars || proxyCfg.CookieStore == "" {
+		return nil
+	}
+
+	if proxyCfg.CookieStore == "redis" {
+		client, err := dedup.NewRedisClient(ctx, cfg)
+		if err != nil {
+			log.Warn("Cookie store falling back to file", "path", proxyCfg.CookieStorePath, "error", err)
+			return request_client.NewFileCookieStore(proxyCfg.CookieStorePath)
+		}
+		return request_client.NewRedisCookieStore(client, proxyCfg.CookieStoreRedisKey)
+	}
+	return request_client.NewFileCookieStore(proxyCfg.CookieStorePath)
+}
+
+// pipeline is what runFull wires into the discovery, scrape and insert pipeline. The optional parts
+// are nil when their feature is disabled.
+type pipeline struct {
+	crawlers     []*scraper.HomePageScraper // one index crawl per site
+	adapter      *clickhouse.Adapter
+	linkChan     chan scraper.ListingLink // closed by runFull once discovery stops
+	tracker      *diagnostics.Tracker
+	bus          *events.Bus
+	shutdown     *lifecycle.Coordinator
+	crawls       *scheduler.Scheduler
+	crawlPause   *lifecycle.Pause
+	schedulerCfg config.SchedulerConfig
+	parserCfg    config.ParserConfig
+	drainTimeout time.Duration // how long the workers drain the link queue on shutdown
+	freshnessSLO time.Duration
+	telegramCfg  config.TelegramConfig
+	translator   *translate.Enricher       // optional
+	coverage     *alerting.CoverageMonitor // optional
+	gaps         *alerting.GapMonitor      // optional
+	gapBatch     int
+	writer       *clickhouse.BufferedWriter // optional, listings are inserted directly without it
+	pageArchive  archive.Store              // optional
+}
+
+// runFull discovers listing links on index pages and scrapes every listing into ClickHouse. On
+// shutdown discovery stops first, then the workers scrape the queued links for up to p.drainTimeout.
+func runFull(ctx context.Context, p pipeline) {
+	// Telegram handles are confirmed through the Bot API only when a token is configured
+	var telegramResolver scraper.TelegramResolver
+	if p.telegramCfg.BotToken != "" {
+		telegramResolver = scraper.NewBotAPIResolver(p.telegramCfg.BotToken, p.telegramCfg.LookupTimeout)
+	}
+	for _, siteAdapter := range scraper.DefaultRegistry.Adapters() {
+		if configurable, ok := siteAdapter.(scraper.TelegramConfigurable); ok {
+			configurable.SetTelegramResolver(telegramResolver)
+			configurable.SetTelegramMinConfidence(p.telegramCfg.MinConfidence)
+		}
+		if configurable, ok := siteAdapter.(scraper.MobileFallbackConfigurable); ok {
+			configurable.SetMobileFallback(p.parserCfg.MobileFallback)
+		}
+		if configurable, ok := siteAdapter.(scraper.PaginationConfigurable); ok {
+			configurable.SetPagination(sitePagination(p.parserCfg, siteAdapter.Name()))
+		}
+		if configurable, ok := siteAdapter.(scraper.PageArchiveConfigurable); ok {
+			configurable.SetPageArchive(p.pageArchive)
+		}
+	}
+
+	// Scrapes and inserts run until the link queue is drained or the drain deadline cancels them
+	ctx, stopWork := context.WithCancel(ctx)
+
+	// Every site's crawls run on their own schedules. The index job sends the new links of the
+	// index. The fresh job sends the new links of the first pages only. The rescrape job sends
+	// every listed link, so all listings are scraped again. The stale job sends stored listings
+	// that went unscraped, e.g. after dropping off the index. The gaps job sends the IDs discovery
+	// missed. The scheduler is the only sender on linkChan, so the channel is closed once it stops
+	// and the workers drain what is left.
+	for _, crawler := range p.crawlers {
+		site := crawler.Site()
+		addCrawl(p.crawls, p.schedulerCfg, site, "index", p.schedulerCfg.Index, func(ctx context.Context) error {
+			return crawler.RunDiscoveryCycle(ctx, p.linkChan)
+		})
+		addCrawl(p.crawls, p.schedulerCfg, site, "fresh", p.schedulerCfg.Fresh, func(ctx context.Context) error {
+			return crawler.RunFreshCycle(ctx, p.linkChan, p.schedulerCfg.FreshPages, p.schedulerCfg.FreshMaxPages)
+		})
+		addCrawl(p.crawls, p.schedulerCfg, site, "rescrape", p.schedulerCfg.Rescrape, func(ctx context.Context) error {
+			return crawler.RunRescrapeCycle(ctx, p.linkChan)
+		})
+		addCrawl(p.crawls, p.schedulerCfg, site, "stale", p.schedulerCfg.Stale, func(ctx context.Context) error {
+			return queueStaleListings(ctx, p.adapter, site, p.schedulerCfg.StaleAfter, p.schedulerCfg.StaleBatchSize, p.linkChan)
+		})
+		// Listings are only known by ID to adapters that can build their link
+		siteAdapter, _ := scraper.AdapterByName(site)
+		if linker, ok := siteAdapter.(scraper.ListingLinker); ok && p.gaps != nil {
+			addCrawl(p.crawls, p.schedulerCfg, site, "gaps", p.schedulerCfg.Gaps, func(ctx context.Context) error {
+				return queueGapListings(ctx, linker, p.gaps, site, p.gapBatch, p.linkChan)
+			})
+		}
+	}
+	discoveryCtx, stopDiscovery := context.WithCancel(ctx)
+	discovered := make(chan struct{})
+	go func() {
+		defer close(discovered)
+		defer close(p.linkChan)
+		p.crawls.Run(discoveryCtx)
+	}()
+	p.shutdown.Register(lifecycle.StageIntake, "discovery", func(stopCtx context.Context) error {
+		stopDiscovery()
+		return lifecycle.WaitFor(discovered)(stopCtx)
+	})
+
 	// Function to retry ClickHouse operations
-	retryInsert := func(listing *listing.Listing, sourceURL string, maxRetries int) error {
+	retry := func(listingID string, maxRetries int, op func(ctx context.Context) error) error {
 		for attempt := 1; attempt <= maxRetries; attempt++ {
 			// Create a context with timeout for this specific operation
 			opCtx, opCancel := context.WithTimeout(ctx, 30*time.Second)
 
-			err := adapter.InsertListing(opCtx, listing, sourceURL)
+			err := op(opCtx)
 			opCancel()
 
 			if err == nil {
@@ -81,8 +775,8 @@ func main() {
 			}
 
 			if attempt < maxRetries {
-				log.Printf("Attempt %d/%d failed for listing %s, retrying in %ds: %v",
-					attempt, maxRetries, listing.Id, attempt*2, err)
+				log.Warn("ClickHouse operation failed, retrying", "listing_id", listingID,
+					"attempt", attempt, "max_attempts", maxRetries, "retry_in", time.Duration(attempt*2)*time.Second, "error", err)
 				time.Sleep(time.Duration(attempt*2) * time.Second)
 			} else {
 				return fmt.Errorf("failed after %d attempts: %w", maxRetries, err)
@@ -91,42 +785,579 @@ func main() {
 		return nil
 	}
 
-	// Process incoming links and save to ClickHouse
-	go func() {
-		for {
-			select {
-			case link := <-linkChan:
-				go func(link string) {
-					intimcityScraper := scraper.NewListingScraper(link)
-					// Scrape the individual listing
-					listing, err := intimcityScraper.ScrapeListing()
-
-					if err != nil {
-						log.Printf("Failed to scrape listing %s: %v", link, err)
-						return
-					}
-
-					// Insert into ClickHouse with retry logic
-					err = retryInsert(listing, link, 3)
-					if err != nil {
-						return
-					}
-				}(link)
+	// Diagnostics, metrics and coverage alerts follow the scrape and insert events
+	p.bus.Subscribe("pipeline", 1024, func(event events.Event) {
+		observePipelineEvent(event, p.tracker, p.coverage)
+	})
 
-			case <-ctx.Done():
-				fmt.Println("Processing stopped")
-				return
+	// Record the outcome of storing a listing; rows is 0 when an unchanged listing was not rewritten,
+	// and changes lists what differs from the previous version
+	finishInsert := func(attempt *clickhouse.ScrapeAttempt, flattened *clickhouse.FlattenedListing, rows int, changes []clickhouse.FieldChange, err error) {
+		if err != nil {
+			p.bus.Publish(events.NewScrapeFailed(attempt.ListingID, attempt.SourceURL, events.StageInsert, err))
+			attempt.Status = clickhouse.AttemptInsertFailed
+			attempt.Error = err.Error()
+			return
+		}
+		attempt.StoredAt = clock.Now()
+		attempt.Status = clickhouse.AttemptStored
+
+		inserted := events.ListingInserted{
+			ListingID: attempt.ListingID,
+			URL:       attempt.SourceURL,
+			Rows:      rows,
+			StoredAt:  attempt.StoredAt,
+			Listing:   flattened,
+		}
+		for _, change := range changes {
+			inserted.ChangedFields = append(inserted.ChangedFields, change.Field)
+		}
+		p.bus.Publish(inserted)
+		if len(changes) > 0 {
+			p.bus.Publish(events.NewListingUpdated(flattened, changes))
+		}
+	}
+
+	// Scrape a listing and save it to ClickHouse; the returned error feeds the autoscaler
+	processLink := func(ctx context.Context, link scraper.ListingLink) error {
+		// Queued links wait while the crawl is paused
+		if err := p.crawlPause.Wait(ctx); err != nil {
+			return err
+		}
+		p.tracker.WorkerStarted()
+		defer p.tracker.WorkerFinished()
+
+		// Mobile and desktop URLs of one anketa are stored under the desktop URL
+		link.URL = scraper.CanonicalListingURL(link.URL)
+
+		attempt := &clickhouse.ScrapeAttempt{
+			ListingID:    clickhouse.CompositeID(clickhouse.SourceSiteFromURL(link.URL), link.ID),
+			SourceURL:    link.URL,
+			DiscoveredAt: link.DiscoveredAt,
+		}
+		ctx = logger.WithListingID(ctx, attempt.ListingID)
+		// With sticky sessions, every request of the listing goes through the same proxy
+		ctx = request_client.WithSessionKey(ctx, attempt.ListingID)
+		if p.adapter.IsExcluded(attempt.ListingID) {
+			return nil
+		}
+
+		// A buffered listing records its attempt once the buffer has flushed it
+		buffered := false
+		defer func() {
+			if !buffered {
+				recordAttempt(ctx, p.adapter, attempt, p.freshnessSLO)
+			}
+		}()
+
+		// Scrape the individual listing with the adapter of its site
+		siteAdapter, err := scraper.AdapterForURL(link.URL)
+		var listing *listing.Listing
+		if err == nil {
+			listing, err = siteAdapter.ScrapeListing(ctx, link.URL)
+		}
+		if service.IsGone(err) {
+			// The page was taken down: retire the stored listing instead of counting a failure
+			attempt.Status = clickhouse.AttemptRemoved
+			removed, err := p.adapter.MarkListingRemoved(ctx, attempt.ListingID)
+			if err != nil {
+				log.WarnContext(ctx, "Failed to mark listing removed", "url", link.URL, "error", err)
+				attempt.Error = err.Error()
+				return nil
 			}
+			if removed {
+				log.InfoContext(ctx, "Listing removed from site", "url", link.URL)
+				p.bus.Publish(events.ListingRemoved{ListingID: attempt.ListingID, URL: link.URL, RemovedAt: clock.Now()})
+			}
+			return nil
+		}
+		if err != nil {
+			log.WarnContext(ctx, "Failed to scrape listing", "url", link.URL, "error", err)
+			p.bus.Publish(events.NewScrapeFailed(attempt.ListingID, link.URL, events.StageScrape, err))
+			attempt.Status = clickhouse.AttemptScrapeFailed
+			attempt.Error = err.Error()
+			return err
+		}
+		attempt.ScrapedAt = clock.Now()
+
+		// Validation flags are computed on flattening; the shared phone check needs the stored listings
+		flattened := p.adapter.FlattenListing(listing, link.URL)
+		if err := p.adapter.FlagSharedPhoneIfCommon(ctx, flattened, p.parserCfg.SharedPhoneLimit); err != nil {
+			log.WarnContext(ctx, "Failed to check shared phone", "error", err)
 		}
+		fields := clickhouse.KeyFields(flattened)
+		fields[clickhouse.FieldQuality] = flattened.QualityScore >= clickhouse.QualityPassScore
+
+		p.bus.Publish(events.ListingScraped{
+			ListingID:    attempt.ListingID,
+			URL:          link.URL,
+			ScrapedAt:    attempt.ScrapedAt,
+			Fields:       fields,
+			QualityScore: flattened.QualityScore,
+			QualityFlags: flattened.QualityFlags,
+		})
+
+		// Translation is best effort: a failed translation never blocks the insert
+		if p.translator != nil {
+			translated, err := p.translator.Translate(ctx, listing.Description)
+			if err != nil {
+				log.WarnContext(ctx, "Failed to translate description", "error", err)
+				p.tracker.RecordError("translate", err)
+			}
+			flattened.DescriptionEn = translated
+		}
+
+		// Insert into ClickHouse with retry logic, directly or through the insert buffer
+		if p.writer == nil {
+			var written bool
+			var changes []clickhouse.FieldChange
+			err = retry(listing.Id, 3, func(opCtx context.Context) error {
+				var err error
+				changes, written, err = p.adapter.UpsertFlattenedIfChanged(opCtx, flattened)
+				if len(changes) > 0 {
+					log.InfoContext(ctx, "Listing changed", "fields", len(changes))
+				}
+				return err
+			})
+			finishInsert(attempt, flattened, rowsWritten(written, err), changes, err)
+			return err
+		}
+
+		var changes []clickhouse.FieldChange
+		var write bool
+		err = retry(listing.Id, 3, func(opCtx context.Context) error {
+			var err error
+			changes, write, err = p.adapter.DetectChanges(opCtx, flattened)
+			return err
+		})
+		if err == nil && write {
+			if len(changes) > 0 {
+				log.InfoContext(ctx, "Listing changed", "fields", len(changes))
+			}
+			err = p.writer.Add(clickhouse.BufferedRow{
+				Listing: flattened,
+				Changes: changes,
+				Done: func(err error) {
+					finishInsert(attempt, flattened, 1, changes, err)
+					recordAttempt(context.WithoutCancel(ctx), p.adapter, attempt, p.freshnessSLO)
+				},
+			})
+			if err == nil {
+				buffered = true
+				return nil
+			}
+			metrics.InsertBufferDroppedRows.WithLabelValues("full").Inc()
+		}
+		finishInsert(attempt, flattened, rowsWritten(write, err), changes, err)
+		return err
+	}
+
+	// Process incoming links on a worker pool sized by queue depth, error rate and block rate
+	pool := autoscale.NewPool(autoscale.FromConfig(p.parserCfg.Autoscale), p.linkChan, processLink, service.IsBlocked)
+	pool.SetScaleHandler(func(event autoscale.Event) {
+		log.Info("Scaled scrape workers", "from", event.From, "to", event.To, "reason", event.Reason,
+			"queue_ratio", event.QueueRatio, "error_rate", event.ErrorRate, "block_rate", event.BlockRate)
+		metrics.ObserveWorkerScaling(event.From, event.To, event.Reason)
+	})
+	processed := make(chan struct{})
+	go func() {
+		defer close(processed)
+		pool.Run(ctx, p.parserCfg.Workers)
+		log.Info("Processing stopped")
 	}()
+	p.shutdown.Register(lifecycle.StageDrain, "workers", func(stopCtx context.Context) error {
+		drainCtx, drainCancel := context.WithTimeout(stopCtx, p.drainTimeout)
+		defer drainCancel()
+		if lifecycle.WaitFor(processed)(drainCtx) == nil {
+			return nil
+		}
 
-	fmt.Println("🚀 ClickHouse adapter is running. Press Ctrl+C to stop...")
-	<-signalChan
+		log.Warn("Link queue not drained in time, cancelling in-flight scrapes", "queued", len(p.linkChan))
+		stopWork()
+		return lifecycle.WaitFor(processed)(stopCtx)
+	})
+}
+
+// addCrawl schedules a crawl of site as the job site:kind, on its override from SCHEDULE_OVERRIDES
+// when one is set and on spec otherwise. Crawls scheduled "off" are not added.
+func addCrawl(crawls *scheduler.Scheduler, schedulerCfg config.SchedulerConfig, site, kind, spec string, run func(ctx context.Context) error) {
+	name := site + ":" + kind
+	if override, ok := schedulerCfg.Overrides[name]; ok {
+		spec = override
+	}
+	if spec == config.ScheduleOff {
+		log.Info("Crawl disabled", "job", name)
+		return
+	}
+
+	schedule, err := scheduler.ParseSchedule(spec)
+	if err == nil {
+		err = crawls.Add(scheduler.Job{Name: name, Group: site, Schedule: schedule, Run: run})
+	}
+	if err != nil {
+		log.Error("Failed to schedule crawl", "job", name, "error", err)
+		os.Exit(1)
+	}
+	log.Info("Scheduled crawl", "job", name, "schedule", spec)
+}
+
+// sitePagination returns the index pagination of site from INDEX_PAGINATION, auto when the site
+// is not listed, exiting on an invalid spec
+func sitePagination(parserCfg config.ParserConfig, site string) scraper.Pagination {
+	pagination, err := scraper.ParsePagination(parserCfg.Pagination[site])
+	if err != nil {
+		log.Error("Invalid index pagination", "site", site, "error", err)
+		os.Exit(1)
+	}
+	pagination.MaxPages = parserCfg.PaginationMaxPages
+	return pagination
+}
+
+// queueStaleListings sends the live listings of site not scraped within staleAfter to be scraped
+// again, at most limit per run. Listings whose page is gone are marked removed by the workers.
+func queueStaleListings(ctx context.Context, adapter *clickhouse.Adapter, site string, staleAfter time.Duration, limit int, linkChan chan<- scraper.ListingLink) error {
+	stale, err := adapter.GetStaleListings(ctx, site, clock.Now().Add(-staleAfter), limit)
+	if err != nil {
+		return err
+	}
+
+	for _, listing := range stale {
+		_, sourceID := clickhouse.SplitCompositeID(listing.ID)
+		link := scraper.ListingLink{URL: listing.SourceURL, ID: sourceID, DiscoveredAt: clock.Now()}
+		select {
+		case linkChan <- link:
+			metrics.StaleListingsQueued.WithLabelValues(site).Inc()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	log.InfoContext(ctx, "Queued stale listings", "site", site, "listings", len(stale), "stale_after", staleAfter)
+	return nil
+}
+
+// queueGapListings sends up to limit IDs of site that discovery never saw to be scraped, each once.
+// Gaps without a listing behind them answer 404 and are skipped by the workers.
+func queueGapListings(ctx context.Context, linker scraper.ListingLinker, gaps *alerting.GapMonitor, site string, limit int, linkChan chan<- scraper.ListingLink) error {
+	ids := gaps.TakeGaps(site, limit)
+	for _, id := range ids {
+		select {
+		case linkChan <- linker.ListingLink(id):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if len(ids) > 0 {
+		log.InfoContext(ctx, "Queued listing ID gaps", "site", site, "listings", len(ids))
+	}
+	return nil
+}
+
+// observeListingIDs feeds the IDs of discovered and scraped listings to the gap monitor and
+// alerts on IDs jumping far past the highest one seen
+func observeListingIDs(ctx context.Context, bus *events.Bus, gaps *alerting.GapMonitor, channels []alerting.Notifier) {
+	bus.Subscribe("id_gaps", 1024, func(event events.Event) {
+		var jump *alerting.IDJump
+		switch event := event.(type) {
+		case events.LinkDiscovered:
+			jump = gaps.Observe(clickhouse.SourceSiteFromURL(event.URL), event.SourceID, event.DiscoveredAt)
+		case events.ListingScraped:
+			site, sourceID := clickhouse.SplitCompositeID(event.ListingID)
+			jump = gaps.Observe(site, sourceID, event.ScrapedAt)
+		}
+		if jump == nil {
+			return
+		}
+
+		log.Warn("Listing ID jumped past the highest one seen", "site", jump.Site, "previous_max", jump.PreviousMax, "id", jump.ID, "jump", jump.Jump)
+		notifyAll(ctx, channels, alerting.EventDiscoveryIDJump, jump)
+	})
+}
+
+// observePipelineEvent records a scrape or insert event in the diagnostics tracker, the metrics
+// and the coverage monitor
+func observePipelineEvent(event events.Event, tracker *diagnostics.Tracker, coverage *alerting.CoverageMonitor) {
+	switch event := event.(type) {
+	case events.ListingScraped:
+		tracker.ListingScraped(nil)
+		metrics.ObserveScrape(nil)
+		metrics.ObserveFields(event.Fields)
+		metrics.ObserveQuality(event.QualityScore, event.QualityFlags)
+		if coverage != nil {
+			coverage.Observe(event.URL, event.Fields, event.ScrapedAt)
+		}
+	case events.ListingInserted:
+		tracker.RowsInserted(event.Rows, nil)
+		metrics.ObserveInsert(event.Rows, nil)
+	case events.ListingRemoved:
+		metrics.ListingsRemoved.WithLabelValues(clickhouse.SourceSiteFromURL(event.URL)).Inc()
+	case events.ScrapeFailed:
+		tracker.RecordError(event.Stage, event.Err)
+		switch event.Stage {
+		case events.StageScrape:
+			tracker.ListingScraped(event.Err)
+			metrics.ObserveScrape(event.Err)
+		case events.StageInsert:
+			tracker.RowsInserted(1, event.Err)
+			metrics.ObserveInsert(1, event.Err)
+		}
+	}
+}
+
+// forwardEvents delivers the pipeline events of the given types to the webhooks
+func forwardEvents(ctx context.Context, bus *events.Bus, notifier *webhook.Dispatcher, types []string, timeout time.Duration) {
+	forwarded := make(map[string]bool, len(types))
+	for _, eventType := range types {
+		forwarded[eventType] = true
+	}
+
+	bus.Subscribe("webhook", 0, func(event events.Event) {
+		if !forwarded[event.Type()] {
+			return
+		}
+		sendCtx, sendCancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		defer sendCancel()
+		if err := notifier.Send(sendCtx, event.Type(), event); err != nil {
+			log.Warn("Failed to send pipeline event webhook", "type", event.Type(), "error", err)
+		}
+	})
+}
+
+// rowsWritten returns the number of rows a failed or completed store counts for: a failed store
+// counts its row as failed, a successful one only when the listing was actually rewritten
+func rowsWritten(written bool, err error) int {
+	if err != nil || written {
+		return 1
+	}
+	return 0
+}
+
+// recordAttempt exports the latency of a listing through the pipeline and stores it in scrape_attempts
+func recordAttempt(ctx context.Context, adapter *clickhouse.Adapter, attempt *clickhouse.ScrapeAttempt, freshnessSLO time.Duration) {
+	if !attempt.ScrapedAt.IsZero() {
+		metrics.ObserveListingLatency(metrics.StageScraped, attempt.TimeToScraped())
+	}
+	if !attempt.StoredAt.IsZero() {
+		metrics.ObserveStored(attempt.TimeToStored(), freshnessSLO)
+	}
 
-	fmt.Println("\nShutdown signal received. Stopping...")
-	cancel()
+	opCtx, opCancel := context.WithTimeout(ctx, 10*time.Second)
+	defer opCancel()
+	if err := adapter.InsertScrapeAttempt(opCtx, attempt); err != nil {
+		log.WarnContext(ctx, "Failed to record scrape attempt", "error", err)
+	}
+}
+
+// runCoverageAlerts exports field coverage every interval and publishes new regressions to every channel
+func runCoverageAlerts(ctx context.Context, coverage *alerting.CoverageMonitor, interval time.Duration, channels []alerting.Notifier) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, status := range coverage.Check(now) {
+				metrics.FieldCoverage.WithLabelValues(status.Field).Set(status.Coverage)
+				if status.Regression == nil {
+					continue
+				}
+
+				log.Warn("Field coverage dropped below threshold", "field", status.Field,
+					"coverage", status.Coverage, "threshold", status.Threshold, "samples", status.Samples)
+				notifyAll(ctx, channels, alerting.EventCoverageRegression, status.Regression)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runGapAlerts exports the listing ID gaps every interval and publishes density changes to every channel
+func runGapAlerts(ctx context.Context, gaps *alerting.GapMonitor, interval time.Duration, channels []alerting.Notifier) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, status := range gaps.Check(now) {
+				metrics.ListingIDMax.WithLabelValues(status.Site).Set(float64(status.MaxID))
+				metrics.ListingIDGapRatio.WithLabelValues(status.Site).Set(status.Density)
+				if status.Change == nil {
+					continue
+				}
+
+				log.Warn("Listing ID gap density changed", "site", status.Site, "density", status.Density,
+					"previous", status.Change.Previous, "gaps", status.Gaps, "max_id", status.MaxID)
+				notifyAll(ctx, channels, alerting.EventDiscoveryGapChange, status.Change)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// notifyAll sends an alert to every channel, logging the channels that fail
+func notifyAll(ctx context.Context, channels []alerting.Notifier, eventType string, data interface{}) {
+	for _, channel := range channels {
+		sendCtx, sendCancel := context.WithTimeout(ctx, 10*time.Second)
+		if err := channel.Send(sendCtx, eventType, data); err != nil {
+			log.Warn("Failed to publish alert", "event", eventType, "error", err)
+		}
+		sendCancel()
+	}
+}
 
-	// Give goroutines a moment to clean up
-	time.Sleep(2 * time.Second)
-	fmt.Println("Shutdown complete")
+// runProxyGeoMetrics exports per-country proxy health every 30 seconds
+func runProxyGeoMetrics(ctx context.Context, client *request_client.ProxyClient) {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		for _, stats := range client.GetGeoStats() {
+			metrics.SetProxyGeoHealth(stats.Geo, stats.Proxies, stats.Active, stats.FailureRatio)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runDashboardStats recomputes dashboard_stats immediately and then every interval
+func runDashboardStats(ctx context.Context, adapter *clickhouse.Adapter, interval time.Duration) {
+	proxies := request_client.GetGlobalClient().ActiveProxyCount
+
+	refresh := func() {
+		start := time.Now()
+		stats, err := adapter.RefreshDashboardStats(ctx, proxies)
+		if err != nil {
+			log.Warn("Failed to refresh dashboard stats", "error", err)
+			return
+		}
+		log.Info("Dashboard stats refreshed", "duration", time.Since(start).Round(time.Millisecond), "listings", stats.TotalListings, "new_today", stats.NewListingsToday)
+	}
+
+	refresh()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			refresh()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// crawlAuditEntry converts an index page request into its crawl_audit row
+func crawlAuditEntry(site string, request scraper.CrawlRequest) clickhouse.CrawlAudit {
+	entry := clickhouse.CrawlAudit{
+		SourceSite:     site,
+		Cycle:          uint32(request.Cycle),
+		CycleStartedAt: request.CycleStartedAt,
+		Page:           uint32(request.Page),
+		URL:            request.URL,
+		BaseDelay:      request.Schedule.Base,
+		MaxJitter:      request.Schedule.Jitter,
+		PlannedDelay:   request.PlannedDelay,
+		Jitter:         request.Jitter,
+		Slept:          request.Slept,
+		RequestedAt:    request.RequestedAt,
+		CompletedAt:    request.CompletedAt,
+		Links:          uint32(request.Links),
+	}
+	if request.Err != nil {
+		entry.Error = request.Err.Error()
+	}
+	return entry
+}
+
+// runCrawlAudit batches crawl audit rows into ClickHouse, flushing every 50 rows or 30 seconds
+func runCrawlAudit(ctx context.Context, adapter *clickhouse.Adapter, auditChan <-chan clickhouse.CrawlAudit) {
+	const batchSize = 50
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	var pending []clickhouse.CrawlAudit
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		// Use a fresh context so the last batch is still written during shutdown
+		opCtx, opCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer opCancel()
+		if err := adapter.InsertCrawlAudit(opCtx, pending); err != nil {
+			log.Warn("Failed to store crawl audit records", "records", len(pending), "error", err)
+		}
+		pending = pending[:0]
+	}
+
+	for {
+		select {
+		case entry := <-auditChan:
+			pending = append(pending, entry)
+			if len(pending) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-ctx.Done():
+			flush()
+			return
+		}
+	}
+}
+
+// runIndexOnly records card-level prices from index pages into price_observations
+func runIndexOnly(ctx context.Context, priceScraper *scraper.HomePageScraper, adapter *clickhouse.Adapter, tracker *diagnostics.Tracker, crawls *scheduler.Scheduler, schedulerCfg config.SchedulerConfig) {
+	observationChan := make(chan []scraper.CardObservation, 10)
+	observationQueue := func() (int, int) { return len(observationChan), cap(observationChan) }
+	tracker.RegisterQueue("observations", observationQueue)
+	metrics.RegisterQueue("observations", observationQueue)
+
+	site := priceScraper.Site()
+	addCrawl(crawls, schedulerCfg, site, "prices", schedulerCfg.Index, func(ctx context.Context) error {
+		return priceScraper.RunPriceObservationCycle(ctx, observationChan)
+	})
+	go crawls.Run(ctx)
+
+	for {
+		select {
+		case cards := <-observationChan:
+			observations := make([]clickhouse.PriceObservation, 0, len(cards))
+			for _, card := range cards {
+				listingID := clickhouse.CompositeID(clickhouse.SourceSiteFromURL(card.URL), card.ListingID)
+				if adapter.IsExcluded(listingID) {
+					continue
+				}
+				observations = append(observations, clickhouse.PriceObservation{
+					ListingID:  listingID,
+					SourceURL:  card.URL,
+					Price:      uint32(card.Price),
+					Currency:   card.Currency,
+					Page:       uint16(card.Page),
+					ObservedAt: card.ObservedAt,
+				})
+			}
+
+			opCtx, opCancel := context.WithTimeout(ctx, 30*time.Second)
+			err := adapter.InsertPriceObservations(opCtx, observations)
+			opCancel()
+			tracker.RowsInserted(len(observations), err)
+			metrics.ObserveInsert(len(observations), err)
+			if err != nil {
+				log.Warn("Failed to store price observations", "observations", len(observations), "error", err)
+				tracker.RecordError("insert", err)
+			}
+
+		case <-ctx.Done():
+			log.Info("Price observation processing stopped")
+			return
+		}
+	}
 }
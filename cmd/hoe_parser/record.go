@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+	"github.com/gregor-tokarev/hoe_parser/internal/conformance"
+	"github.com/gregor-tokarev/hoe_parser/internal/modules/request_client"
+	"github.com/gregor-tokarev/hoe_parser/internal/scraper"
+)
+
+// runRecordFixture implements `hoe_parser record-fixture <url>`, which saves a live listing page
+// as fixture of its site with a golden listing and golden fields from the current extractor
+func runRecordFixture(args []string) {
+	flags := flag.NewFlagSet("record-fixture", flag.ExitOnError)
+	dir := flags.String("fixtures", "internal/scraper/testdata/conformance", "directory with one fixture directory per site")
+	name := flags.String("name", "", "fixture name (default: the page name from the url)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: hoe_parser record-fixture [-fixtures dir] [-name name] <url>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	rawURL := flags.Arg(0)
+
+	adapter, err := scraper.AdapterForURL(rawURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "No site handles %s\n", rawURL)
+		os.Exit(2)
+	}
+	parser, ok := adapter.(scraper.PageParser)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Site %s cannot parse stored pages\n", adapter.Name())
+		os.Exit(2)
+	}
+	if *name == "" {
+		*name = strings.TrimSuffix(path.Base(rawURL), path.Ext(rawURL))
+	}
+	siteDir := filepath.Join(*dir, adapter.Name())
+
+	// The page is fetched through the proxies, like a scrape
+	request_client.InitGlobalClient(config.Load())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	fixture, err := scraper.RecordFixture(ctx, siteDir, *name, rawURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to record fixture: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The golden fields of verify-site come from the same extractor output
+	goldenPath := filepath.Join(siteDir, *name+".golden.json")
+	data, _ := json.Marshal(conformance.Golden{URL: rawURL, Fields: map[string]any{}})
+	if err := os.WriteFile(goldenPath, data, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write golden fields: %v\n", err)
+		os.Exit(1)
+	}
+	fixtures, err := conformance.LoadFixtures(siteDir)
+	if err == nil {
+		for _, stored := range fixtures {
+			if stored.Name == *name {
+				err = conformance.Update(ctx, parser, []conformance.Fixture{stored})
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write golden fields: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Recorded %s with %s and %s; review them against the page before committing\n",
+		fixture.PagePath, fixture.GoldenPath, goldenPath)
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+	"github.com/gregor-tokarev/hoe_parser/internal/diagnostics"
+	"github.com/gregor-tokarev/hoe_parser/pkg/format"
+)
+
+// topSort is the order of the site rows in the view
+type topSort int
+
+const (
+	sortBySite topSort = iota
+	sortByState
+	sortByProgress // furthest crawl first
+	sortByUpdated  // latest update first
+	topSorts
+)
+
+var topSortNames = [topSorts]string{"site", "state", "progress", "updated"}
+
+// runTop implements `hoe_parser top`, a live terminal view of a running pipeline, or with -fleet
+// of the crawl progress of every instance reporting to Redis. The view runs on the alternate
+// screen, so the terminal is restored on exit.
+func runTop(args []string) {
+	cfg := config.Load()
+
+	flags := flag.NewFlagSet("top", flag.ExitOnError)
+	addr := flags.String("addr", cfg.DiagnosticsAddr, "diagnostics endpoint address (host:port)")
+	interval := flags.Duration("interval", time.Second, "refresh interval")
+	fleet := flags.Bool("fleet", false, "show the combined progress of every instance (needs FLEET_PROGRESS_ENABLED)")
+	flags.Parse(args)
+
+	// An unknown DISPLAY_LOCALE falls back to English
+	locale, _ := format.ParseLocale(cfg.DisplayLocale)
+
+	baseURL := *addr
+	if !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+		baseURL = "http://" + baseURL
+	}
+
+	model := &topModel{baseURL: baseURL, fleet: *fleet, interval: *interval, locale: locale, sortBy: sortBySite}
+	if _, err := tea.NewProgram(model, tea.WithAltScreen()).Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "top: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// topFetchedMsg carries the result of one poll of the diagnostics endpoint
+type topFetchedMsg struct {
+	snapshot *diagnostics.Snapshot
+	fleet    *diagnostics.FleetProgress
+	err      error
+}
+
+// topTickMsg asks for the next poll
+type topTickMsg struct{}
+
+// topModel is the state of the live view: the last poll, the row order and the scroll position
+type topModel struct {
+	baseURL  string
+	fleet    bool
+	interval time.Duration
+	locale   format.Locale
+
+	snapshot *diagnostics.Snapshot
+	progress *diagnostics.FleetProgress
+	err      error
+
+	sortBy topSort
+	offset int // first line shown
+	height int // terminal rows, 0 until the first window size
+}
+
+// Init starts polling the diagnostics endpoint
+func (m *topModel) Init() tea.Cmd {
+	return m.poll
+}
+
+// poll fetches the snapshot or the fleet progress once
+func (m *topModel) poll() tea.Msg {
+	ctx, cancel := context.WithTimeout(context.Background(), m.interval)
+	defer cancel()
+
+	if m.fleet {
+		progress, err := diagnostics.FetchFleetProgress(ctx, m.baseURL)
+		return topFetchedMsg{fleet: progress, err: err}
+	}
+	snapshot, err := diagnostics.FetchSnapshot(ctx, m.baseURL)
+	return topFetchedMsg{snapshot: snapshot, err: err}
+}
+
+// Update handles polls, key presses and terminal resizes
+func (m *topModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case topFetchedMsg:
+		// A failed poll keeps the last state on screen under the error
+		m.err = msg.err
+		if msg.err == nil {
+			m.snapshot, m.progress = msg.snapshot, msg.fleet
+		}
+		m.scroll(0)
+		return m, tea.Tick(m.interval, func(time.Time) tea.Msg { return topTickMsg{} })
+	case topTickMsg:
+		return m, m.poll
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+		m.scroll(0)
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "esc", "ctrl+c":
+			return m, tea.Quit
+		case "s":
+			m.sortBy = (m.sortBy + 1) % topSorts
+		case "S":
+			m.sortBy = (m.sortBy + topSorts - 1) % topSorts
+		case "up", "k":
+			m.scroll(-1)
+		case "down", "j":
+			m.scroll(1)
+		case "pgup", "b":
+			m.scroll(-m.pageSize())
+		case "pgdown", " ", "f":
+			m.scroll(m.pageSize())
+		case "home", "g":
+			m.offset = 0
+		case "end", "G":
+			m.offset = len(m.lines())
+			m.scroll(0)
+		}
+	}
+	return m, nil
+}
+
+// View renders the visible part of the screen above a line of key hints
+func (m *topModel) View() string {
+	lines := m.lines()
+	end := len(lines)
+	if m.height > 0 {
+		end = min(m.offset+m.pageSize(), len(lines))
+	}
+
+	footer := fmt.Sprintf("q quit  s/S sort: %s  ↑/↓ pgup/pgdn scroll  lines %d-%d of %d",
+		topSortNames[m.sortBy], min(m.offset+1, end), end, len(lines))
+	return strings.Join(lines[m.offset:end], "\n") + "\n" + footer
+}
+
+// lines renders the whole screen as lines
+func (m *topModel) lines() []string {
+	var out strings.Builder
+	switch {
+	case m.fleet && m.progress != nil:
+		renderFleet(&out, m.baseURL, m.progress, m.sortBy, m.locale)
+	case !m.fleet && m.snapshot != nil:
+		renderSnapshot(&out, m.baseURL, m.snapshot, m.sortBy, m.locale)
+	case m.fleet:
+		fmt.Fprintf(&out, "hoe_parser top — fleet via %s\n", m.baseURL)
+	default:
+		fmt.Fprintf(&out, "hoe_parser top — %s\n", m.baseURL)
+	}
+
+	if m.err != nil {
+		waiting := "pipeline"
+		if m.fleet {
+			waiting = "fleet progress"
+		}
+		fmt.Fprintf(&out, "\nWaiting for %s: %v\n", waiting, m.err)
+	}
+	return strings.Split(strings.TrimRight(out.String(), "\n"), "\n")
+}
+
+// pageSize is the number of lines shown above the key hints
+func (m *topModel) pageSize() int {
+	return max(m.height-1, 1)
+}
+
+// scroll moves the view by delta lines, keeping the last page full
+func (m *topModel) scroll(delta int) {
+	last := 0
+	if m.height > 0 {
+		last = max(len(m.lines())-m.pageSize(), 0)
+	}
+	m.offset = min(max(m.offset+delta, 0), last)
+}
+
+// renderSnapshot writes a one-screen summary of the pipeline state, with the sites in sortBy
+// order and numbers in locale
+func renderSnapshot(out io.Writer, baseURL string, snapshot *diagnostics.Snapshot, sortBy topSort, locale format.Locale) {
+	fmt.Fprintf(out, "hoe_parser top — %s — up %s — %s\n\n", baseURL, snapshot.Uptime, time.Now().Format("15:04:05"))
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	sites := append([]diagnostics.SiteProgress(nil), snapshot.Sites...)
+	sort.SliceStable(sites, func(i, j int) bool { return siteLess(sites[i], sites[j], sortBy) })
+
+	fmt.Fprintln(w, "SITE\tSTATE\tCYCLE\tPAGE\tPROGRESS\tLINKS\tUPDATED")
+	for _, site := range sites {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d/%d\t%s\t%s\t%s ago\n",
+			site.Site, site.State, site.Cycle, site.Page, site.TotalPages,
+			progressBar(site.Page, site.TotalPages, 20), locale.Int(int64(site.LinksDiscovered)),
+			time.Since(site.UpdatedAt).Round(time.Second))
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "QUEUE\tDEPTH\tCAPACITY\tFILL")
+	for _, queue := range snapshot.Queues {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", queue.Name, locale.Int(int64(queue.Depth)), locale.Int(int64(queue.Capacity)),
+			progressBar(queue.Depth, queue.Capacity, 20))
+	}
+	fmt.Fprintln(w)
+	w.Flush()
+
+	if snapshot.Workers.Max > 0 {
+		fmt.Fprintf(out, "Workers:  %d/%d busy (%s)\n", snapshot.Workers.Active, snapshot.Workers.Max, locale.Percent(snapshot.Workers.Utilization, 0))
+	} else {
+		fmt.Fprintf(out, "Workers:  %d busy (unbounded)\n", snapshot.Workers.Active)
+	}
+
+	listings := snapshot.Listings
+	fmt.Fprintf(out, "Scraped:  %s ok, %s failed\n", locale.Int(int64(listings.Scraped)), locale.Int(int64(listings.ScrapeFailed)))
+	fmt.Fprintf(out, "Inserted: %s ok, %s failed, %s rows/min\n\n", locale.Int(int64(listings.Inserted)), locale.Int(int64(listings.InsertFailed)),
+		locale.Decimal(listings.InsertsPerMinute, 1))
+
+	fmt.Fprintln(out, "Recent errors:")
+	if len(snapshot.RecentErrors) == 0 {
+		fmt.Fprintln(out, "  none")
+	}
+	// Newest first; the view scrolls, so every error the endpoint keeps is listed
+	for i := len(snapshot.RecentErrors) - 1; i >= 0; i-- {
+		entry := snapshot.RecentErrors[i]
+		fmt.Fprintf(out, "  %s  %-6s  %s\n", entry.Time.Format("15:04:05"), entry.Stage, truncate(entry.Message, 100))
+	}
+}
+
+// renderFleet writes the combined cycle progress and one line per instance and site, in sortBy
+// order and with numbers in locale
+func renderFleet(out io.Writer, baseURL string, fleet *diagnostics.FleetProgress, sortBy topSort, locale format.Locale) {
+	fmt.Fprintf(out, "hoe_parser top — fleet via %s — %d instances — %s\n\n", baseURL, len(fleet.Instances), time.Now().Format("15:04:05"))
+
+	eta := fleet.ETA
+	if eta == "" {
+		eta = "unknown"
+	}
+	fmt.Fprintf(out, "Cycle:    %s %s (%s/%s pages), ETA %s\n", progressBar(fleet.PagesDone, fleet.TotalPages, 40),
+		locale.Percent(fleet.Completion, 0), locale.Int(int64(fleet.PagesDone)), locale.Int(int64(fleet.TotalPages)), eta)
+	fmt.Fprintf(out, "Scraped:  %s listings\n\n", locale.Int(int64(fleet.ListingsScraped)))
+
+	type fleetRow struct {
+		instance *diagnostics.InstanceProgress
+		site     diagnostics.SiteProgress
+	}
+	var rows []fleetRow
+	for i := range fleet.Instances {
+		for _, site := range fleet.Instances[i].Sites {
+			rows = append(rows, fleetRow{instance: &fleet.Instances[i], site: site})
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return siteLess(rows[i].site, rows[j].site, sortBy) })
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INSTANCE\tSITE\tSTATE\tCYCLE\tPAGE\tPROGRESS\tSCRAPED\tREPORTED")
+	for _, row := range rows {
+		site := row.site
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d/%d\t%s\t%s\t%s ago\n",
+			row.instance.Instance, site.Site, site.State, site.Cycle, site.Page, site.TotalPages,
+			progressBar(site.Page, site.TotalPages, 20), locale.Int(int64(row.instance.Listings.Scraped)),
+			time.Since(row.instance.ReportedAt).Round(time.Second))
+	}
+	w.Flush()
+}
+
+// siteLess orders site rows by sortBy, then by site name
+func siteLess(a, b diagnostics.SiteProgress, sortBy topSort) bool {
+	switch sortBy {
+	case sortByState:
+		if a.State != b.State {
+			return a.State < b.State
+		}
+	case sortByProgress:
+		if pa, pb := completion(a), completion(b); pa != pb {
+			return pa > pb
+		}
+	case sortByUpdated:
+		if !a.UpdatedAt.Equal(b.UpdatedAt) {
+			return a.UpdatedAt.After(b.UpdatedAt)
+		}
+	}
+	return a.Site < b.Site
+}
+
+// completion is the share of the cycle's pages a site has crawled, 0 without a page count
+func completion(site diagnostics.SiteProgress) float64 {
+	if site.TotalPages <= 0 {
+		return 0
+	}
+	return float64(site.Page) / float64(site.TotalPages)
+}
+
+// progressBar renders value/total as a fixed-width bar
+func progressBar(value, total, width int) string {
+	if total <= 0 {
+		return "[" + strings.Repeat(" ", width) + "]"
+	}
+
+	filled := value * width / total
+	if filled > width {
+		filled = width
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(" ", width-filled) + "]"
+}
+
+// truncate shortens s to at most n runes
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
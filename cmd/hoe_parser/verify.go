@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/conformance"
+	"github.com/gregor-tokarev/hoe_parser/internal/scraper"
+)
+
+// runVerifySite implements `hoe_parser verify-site <site>`, which checks a site's extractor against
+// its stored fixture pages and exits non-zero when any golden field does not match
+func runVerifySite(args []string) {
+	flags := flag.NewFlagSet("verify-site", flag.ExitOnError)
+	dir := flags.String("fixtures", "internal/scraper/testdata/conformance", "directory with one fixture directory per site")
+	update := flags.Bool("update", false, "rewrite the golden files from the current extractor output")
+	extraction := flags.String("extraction", "", "parse with the selectors of an extraction config file instead of the built-in ones")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: hoe_parser verify-site [-fixtures dir] [-extraction file] [-update] <site>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	site := flags.Arg(0)
+
+	adapter, ok := scraper.AdapterByName(site)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown site %q\n", site)
+		os.Exit(2)
+	}
+	parser, ok := adapter.(scraper.PageParser)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Site %s cannot parse stored pages\n", site)
+		os.Exit(2)
+	}
+
+	if *extraction != "" {
+		if _, err := scraper.ConfigureExtraction(*extraction); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load extraction config: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	fixtures, err := conformance.LoadFixtures(filepath.Join(*dir, site))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load fixtures: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	if *update {
+		if err := conformance.Update(ctx, parser, fixtures); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to update golden files: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Updated %d golden files of %s\n", len(fixtures), site)
+		return
+	}
+
+	report := conformance.Run(ctx, site, parser, fixtures)
+	report.Write(os.Stdout)
+	if !report.Passed() {
+		os.Exit(1)
+	}
+}
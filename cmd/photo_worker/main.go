@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+	"github.com/gregor-tokarev/hoe_parser/internal/dedup"
+	"github.com/gregor-tokarev/hoe_parser/internal/modules/request_client"
+	"github.com/joho/godotenv"
+)
+
+// photo_worker drains the persistent photo queue filled by the parser with PHOTO_QUEUE_ENABLED,
+// downloading and hashing the queued photos. Any number of workers can run next to each other.
+func main() {
+	workers := flag.Int("workers", 4, "jobs processed at the same time")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Error loading .env file: %v", err)
+	}
+
+	cfg := config.Load()
+
+	// Photo requests go through the same proxies as regular scraping
+	request_client.InitGlobalClient(cfg)
+	fmt.Printf("Initialized proxy client with %d proxies\n", len(cfg.Proxies))
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	adapter, err := clickhouse.NewAdapter(clickhouse.FromMainConfig(cfg, cfg.Debug))
+	if err != nil {
+		log.Fatalf("Failed to create ClickHouse adapter: %v", err)
+	}
+	defer adapter.Close()
+
+	client, err := dedup.NewRedisClient(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to the photo queue: %v", err)
+	}
+	defer client.Close()
+
+	queue := dedup.NewRedisPhotoQueue(client, cfg.PhotoHash.QueueKey)
+	if queued, err := queue.Len(ctx); err == nil {
+		fmt.Printf("%d listings queued for photo hashing\n", queued)
+	}
+
+	hasher := dedup.NewPhotoHasherFromConfig(adapter, cfg)
+	hasher.SetQueue(queue, dedup.PhotoQueueOptionsFromConfig(cfg))
+
+	fmt.Printf("Draining photo queue %s with %d workers, press Ctrl+C to stop\n", cfg.PhotoHash.QueueKey, *workers)
+	hasher.Drain(ctx, *workers)
+	fmt.Println("Photo worker stopped")
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/archive"
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+	"github.com/gregor-tokarev/hoe_parser/internal/scraper"
+	"github.com/gregor-tokarev/hoe_parser/internal/storage"
+	"github.com/gregor-tokarev/hoe_parser/pkg/format"
+	"github.com/joho/godotenv"
+)
+
+// source marks the change log entries written by this job
+const source = "reparse"
+
+// reparse parses archived listing pages again with the current extractors and stores the listings
+// that come out different, for backfills after an extractor fix without fetching the pages again
+func main() {
+	dir := flag.String("dir", "", "directory of archived snapshots or saved pages (default: HTML_ARCHIVE_DIR)")
+	urlPrefix := flag.String("url-prefix", "https://b.intimcity.gold/", "listing URL of saved .html pages, followed by the file name")
+	insertNew := flag.Bool("insert-new", false, "also store listings that are not live in storage, removed ones included")
+	dryRun := flag.Bool("dry-run", false, "parse and compare but do not write to the store")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Error loading .env file: %v", err)
+	}
+
+	cfg := config.Load()
+	locale, _ := format.ParseLocale(cfg.DisplayLocale)
+	if *dir == "" {
+		*dir = cfg.HTMLArchive.Dir
+	}
+
+	// Pages are parsed with the selectors the scraper uses
+	if cfg.Parser.ExtractionConfig != "" {
+		if _, err := scraper.ConfigureExtraction(cfg.Parser.ExtractionConfig); err != nil {
+			log.Fatalf("Failed to load the extraction config: %v", err)
+		}
+	}
+
+	snapshots, err := LatestSnapshots(*dir, *urlPrefix)
+	if err != nil {
+		log.Fatalf("Failed to read pages under %s: %v", *dir, err)
+	}
+	fmt.Printf("Re-parsing the latest pages of %s listings from %s\n", locale.Int(int64(len(snapshots))), *dir)
+
+	ctx := context.Background()
+
+	// Listings go to the store selected by STORAGE_BACKEND
+	store, err := storage.Open(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to open %s storage: %v", cfg.Storage.Backend, err)
+	}
+	defer store.Close()
+
+	var changed, unchanged, skipped, failed int
+	for _, snapshot := range snapshots {
+		result, err := reparse(ctx, store, snapshot, *insertNew, *dryRun)
+		switch {
+		case err != nil:
+			log.Printf("Failed to re-parse %s: %v", snapshot.URL, err)
+			failed++
+		case result == resultChanged:
+			changed++
+		case result == resultUnchanged:
+			unchanged++
+		default:
+			skipped++
+		}
+	}
+
+	fmt.Printf("Re-parse complete: %s changed, %s unchanged, %s skipped, %s failed\n",
+		locale.Int(int64(changed)), locale.Int(int64(unchanged)), locale.Int(int64(skipped)), locale.Int(int64(failed)))
+}
+
+// Outcomes of re-parsing one listing
+const (
+	resultChanged   = "changed"
+	resultUnchanged = "unchanged"
+	resultSkipped   = "skipped"
+)
+
+// reparse parses the page of snapshot and stores the listing when it differs from the stored one.
+// Listings not live in store are skipped unless insertNew is set, so removed listings stay
+// removed, and so are listings scraped again since the page was fetched.
+func reparse(ctx context.Context, store storage.Store, snapshot archive.Snapshot, insertNew, dryRun bool) (string, error) {
+	siteAdapter, err := scraper.AdapterForURL(snapshot.URL)
+	if err != nil {
+		return "", err
+	}
+	parser, ok := siteAdapter.(scraper.PageParser)
+	if !ok {
+		return "", fmt.Errorf("the %s adapter cannot parse stored pages", siteAdapter.Name())
+	}
+
+	parsed, err := parser.ParseListingPage(ctx, snapshot.URL, snapshot.Body)
+	if err != nil {
+		return "", err
+	}
+	flattened := clickhouse.Flatten(parsed, snapshot.URL)
+	if flattened.SourceID == "" {
+		return "", fmt.Errorf("no listing id in the url")
+	}
+	flattened.LastScraped = snapshot.FetchedAt
+
+	opCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	stored, err := store.GetListingByID(opCtx, flattened.ID)
+	switch {
+	case errors.Is(err, clickhouse.ErrListingNotFound):
+		if !insertNew {
+			return resultSkipped, nil
+		}
+	case err != nil:
+		return "", fmt.Errorf("failed to get stored listing: %w", err)
+	case scrapedSince(stored, snapshot):
+		return resultSkipped, nil
+	default:
+		carryOver(stored, flattened)
+	}
+
+	if dryRun {
+		if stored == nil || len(clickhouse.DiffListings(stored, flattened)) > 0 {
+			return resultChanged, nil
+		}
+		return resultUnchanged, nil
+	}
+
+	changes, written, err := storage.UpsertIfChangedFrom(opCtx, store, flattened, source)
+	if err != nil {
+		return "", err
+	}
+	if !written {
+		return resultUnchanged, nil
+	}
+	if len(changes) > 0 {
+		log.Printf("Listing %s changed: %d fields", flattened.ID, len(changes))
+	}
+	return resultChanged, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/archive"
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/scraper"
+)
+
+// LatestSnapshots walks dir for listing pages and returns the newest page of every listing,
+// ordered by URL. Archived snapshots (.html.gz) carry their URL and fetch time; saved pages
+// (.html, .htm) get urlPrefix followed by their file name as URL and their modification time.
+// Pages of one listing, desktop and mobile alike, are told apart by their canonical URL.
+func LatestSnapshots(dir, urlPrefix string) ([]archive.Snapshot, error) {
+	latest := make(map[string]archive.Snapshot)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		snapshot, ok, err := readSnapshot(path, urlPrefix)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+
+		snapshot.URL = scraper.CanonicalListingURL(snapshot.URL)
+		if previous, seen := latest[snapshot.URL]; !seen || snapshot.FetchedAt.After(previous.FetchedAt) {
+			latest[snapshot.URL] = snapshot
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]archive.Snapshot, 0, len(latest))
+	for _, snapshot := range latest {
+		snapshots = append(snapshots, snapshot)
+	}
+	slices.SortFunc(snapshots, func(a, b archive.Snapshot) int {
+		return strings.Compare(a.URL, b.URL)
+	})
+	return snapshots, nil
+}
+
+// readSnapshot reads one page file, reporting false for files that are not pages
+func readSnapshot(path, urlPrefix string) (archive.Snapshot, bool, error) {
+	name := filepath.Base(path)
+	switch {
+	case strings.HasSuffix(name, ".html.gz"):
+		file, err := os.Open(path)
+		if err != nil {
+			return archive.Snapshot{}, false, fmt.Errorf("failed to open %s: %w", path, err)
+		}
+		defer file.Close()
+
+		snapshot, err := archive.Decode(file)
+		if err != nil {
+			return archive.Snapshot{}, false, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if snapshot.URL == "" {
+			return archive.Snapshot{}, false, fmt.Errorf("snapshot %s has no URL", path)
+		}
+		return snapshot, true, nil
+
+	case strings.HasSuffix(name, ".html"), strings.HasSuffix(name, ".htm"):
+		body, err := os.ReadFile(path)
+		if err != nil {
+			return archive.Snapshot{}, false, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return archive.Snapshot{}, false, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		return archive.Snapshot{URL: urlPrefix + name, FetchedAt: info.ModTime(), Body: body}, true, nil
+	}
+	return archive.Snapshot{}, false, nil
+}
+
+// scrapeGrace is how long after its page was fetched a scraped listing is stored at most
+const scrapeGrace = 5 * time.Minute
+
+// scrapedSince reports whether stored was scraped after the page of snapshot was fetched, when
+// the page no longer describes the listing as stored
+func scrapedSince(stored *clickhouse.FlattenedListing, snapshot archive.Snapshot) bool {
+	return stored.LastScraped.After(snapshot.FetchedAt.Add(scrapeGrace))
+}
+
+// carryOver keeps in parsed what a stored page does not hold: the photos come from a separate
+// endpoint, the translation and the confirmed Telegram handle from lookups a re-parse does not
+// repeat. The translation and handle are kept while the text they were derived from is the same.
+func carryOver(stored, parsed *clickhouse.FlattenedListing) {
+	parsed.Photos = stored.Photos
+	parsed.PhotosCount = stored.PhotosCount
+	if parsed.Description == stored.Description {
+		parsed.DescriptionEn = stored.DescriptionEn
+	}
+	if slices.Equal(parsed.ContactTelegramCandidates, stored.ContactTelegramCandidates) {
+		parsed.ContactTelegram = stored.ContactTelegram
+		parsed.ContactTelegramConfidence = stored.ContactTelegramConfidence
+	}
+	parsed.Completeness = clickhouse.CompletenessScore(parsed)
+}
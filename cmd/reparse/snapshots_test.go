@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/archive"
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+)
+
+func TestLatestSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	store := archive.NewDirStore(dir)
+	fetchedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// An older desktop page and a newer mobile page of one listing, and a page of another
+	for _, snapshot := range []archive.Snapshot{
+		{Site: "intimcity.gold", ListingID: "1", URL: "https://b.intimcity.gold/anketa1.htm", FetchedAt: fetchedAt, Body: []byte("old")},
+		{Site: "intimcity.gold", ListingID: "1", URL: "https://m.intimcity.gold/anketa1.htm", FetchedAt: fetchedAt.Add(time.Hour), Body: []byte("new")},
+		{Site: "intimcity.gold", ListingID: "2", URL: "https://b.intimcity.gold/anketa2.htm", FetchedAt: fetchedAt, Body: []byte("other")},
+	} {
+		if err := store.Put(context.Background(), snapshot); err != nil {
+			t.Fatalf("Failed to store snapshot: %v", err)
+		}
+	}
+	saved := filepath.Join(dir, "saved", "anketa3.htm")
+	os.MkdirAll(filepath.Dir(saved), 0o755)
+	os.WriteFile(saved, []byte("saved"), 0o644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a page"), 0o644)
+
+	snapshots, err := LatestSnapshots(dir, "https://b.intimcity.gold/")
+	if err != nil {
+		t.Fatalf("Failed to read snapshots: %v", err)
+	}
+	if len(snapshots) != 3 {
+		t.Fatalf("Expected the pages of 3 listings, got %d", len(snapshots))
+	}
+	if snapshots[0].URL != "https://b.intimcity.gold/anketa1.htm" || string(snapshots[0].Body) != "new" {
+		t.Errorf("Expected the newer mobile page under the desktop URL, got %s %q", snapshots[0].URL, snapshots[0].Body)
+	}
+	if snapshots[2].URL != "https://b.intimcity.gold/anketa3.htm" || string(snapshots[2].Body) != "saved" {
+		t.Errorf("Expected the saved page under the URL prefix, got %s %q", snapshots[2].URL, snapshots[2].Body)
+	}
+}
+
+func TestCarryOver(t *testing.T) {
+	stored := &clickhouse.FlattenedListing{
+		Description:               "Привет",
+		DescriptionEn:             "Hello",
+		Photos:                    []string{"https://example.com/1.jpg"},
+		PhotosCount:               1,
+		ContactTelegram:           "anna",
+		ContactTelegramCandidates: []string{"anna"},
+		ContactTelegramConfidence: 0.9,
+	}
+	parsed := &clickhouse.FlattenedListing{
+		Description:               "Привет!",
+		ContactTelegramCandidates: []string{"anna"},
+	}
+
+	carryOver(stored, parsed)
+	if parsed.PhotosCount != 1 || len(parsed.Photos) != 1 {
+		t.Errorf("Expected the stored photos, got %v", parsed.Photos)
+	}
+	if parsed.DescriptionEn != "" {
+		t.Errorf("Expected no translation of a changed description, got %q", parsed.DescriptionEn)
+	}
+	if parsed.ContactTelegram != "anna" {
+		t.Errorf("Expected the confirmed handle of the same candidates, got %q", parsed.ContactTelegram)
+	}
+}
+
+func TestScrapedSince(t *testing.T) {
+	fetchedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	snapshot := archive.Snapshot{FetchedAt: fetchedAt}
+
+	if scrapedSince(&clickhouse.FlattenedListing{LastScraped: fetchedAt.Add(10 * time.Second)}, snapshot) {
+		t.Errorf("Expected the scrape that fetched the page not to count")
+	}
+	if !scrapedSince(&clickhouse.FlattenedListing{LastScraped: fetchedAt.Add(time.Hour)}, snapshot) {
+		t.Errorf("Expected a later scrape to count")
+	}
+}
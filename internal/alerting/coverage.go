@@ -0,0 +1,174 @@
+package alerting
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// EventCoverageRegression is the event type published when a field's coverage drops below its threshold
+const EventCoverageRegression = "coverage.regression"
+
+// Notifier delivers an event to an alert channel (webhooks, Kafka)
+type Notifier interface {
+	Send(ctx context.Context, eventType string, data interface{}) error
+}
+
+// CoverageRegression is the payload of a coverage.regression event
+type CoverageRegression struct {
+	Field       string    `json:"field"`
+	Coverage    float64   `json:"coverage"`
+	Threshold   float64   `json:"threshold"`
+	Samples     int       `json:"samples"`
+	Window      string    `json:"window"`
+	FailingURLs []string  `json:"failing_urls"`
+	DetectedAt  time.Time `json:"detected_at"`
+}
+
+// FieldCoverage is the coverage of one tracked field over the window
+type FieldCoverage struct {
+	Field     string
+	Coverage  float64 // populated share of the samples, 1 when there are none
+	Threshold float64
+	Samples   int
+
+	// Regression is set only on the check where coverage first drops below the threshold;
+	// the field has to recover before it can fire again
+	Regression *CoverageRegression
+}
+
+// fieldSample is one listing's result for a tracked field
+type fieldSample struct {
+	at        time.Time
+	url       string
+	populated bool
+}
+
+// CoverageMonitor tracks how often critical fields are parsed over a rolling window
+type CoverageMonitor struct {
+	mu         sync.Mutex
+	window     time.Duration
+	thresholds map[string]float64
+	minSamples int
+	sampleURLs int
+	samples    map[string][]fieldSample
+	firing     map[string]bool
+}
+
+// NewCoverageMonitor creates a monitor for the fields in thresholds. A field alerts once its coverage over
+// window falls below its threshold with at least minSamples listings seen; sampleURLs failing URLs are attached.
+func NewCoverageMonitor(window time.Duration, thresholds map[string]float64, minSamples, sampleURLs int) *CoverageMonitor {
+	if window <= 0 {
+		window = time.Hour
+	}
+	if minSamples < 1 {
+		minSamples = 1
+	}
+
+	return &CoverageMonitor{
+		window:     window,
+		thresholds: thresholds,
+		minSamples: minSamples,
+		sampleURLs: sampleURLs,
+		samples:    make(map[string][]fieldSample),
+		firing:     make(map[string]bool),
+	}
+}
+
+// Observe records which fields were populated on a scraped listing; untracked fields are ignored
+func (m *CoverageMonitor) Observe(url string, fields map[string]bool, at time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for field := range m.thresholds {
+		populated, ok := fields[field]
+		if !ok {
+			continue
+		}
+		m.samples[field] = append(m.prune(field, at), fieldSample{at: at, url: url, populated: populated})
+	}
+}
+
+// Check computes the coverage of every tracked field at now and flags new regressions
+func (m *CoverageMonitor) Check(now time.Time) []FieldCoverage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fields := make([]string, 0, len(m.thresholds))
+	for field := range m.thresholds {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	result := make([]FieldCoverage, 0, len(fields))
+	for _, field := range fields {
+		samples := m.prune(field, now)
+		m.samples[field] = samples
+
+		status := FieldCoverage{
+			Field:     field,
+			Coverage:  1,
+			Threshold: m.thresholds[field],
+			Samples:   len(samples),
+		}
+
+		var failing []string
+		populated := 0
+		for _, sample := range samples {
+			if sample.populated {
+				populated++
+			} else {
+				failing = append(failing, sample.url)
+			}
+		}
+		if len(samples) > 0 {
+			status.Coverage = float64(populated) / float64(len(samples))
+		}
+
+		switch {
+		case status.Coverage >= status.Threshold:
+			m.firing[field] = false
+		case len(samples) >= m.minSamples && !m.firing[field]:
+			m.firing[field] = true
+			status.Regression = &CoverageRegression{
+				Field:       field,
+				Coverage:    status.Coverage,
+				Threshold:   status.Threshold,
+				Samples:     status.Samples,
+				Window:      m.window.String(),
+				FailingURLs: latest(failing, m.sampleURLs),
+				DetectedAt:  now,
+			}
+		}
+
+		result = append(result, status)
+	}
+
+	return result
+}
+
+// prune drops samples of field older than the window; the caller holds the lock
+func (m *CoverageMonitor) prune(field string, now time.Time) []fieldSample {
+	samples := m.samples[field]
+	cutoff := now.Add(-m.window)
+
+	i := 0
+	for i < len(samples) && samples[i].at.Before(cutoff) {
+		i++
+	}
+	return samples[i:]
+}
+
+// latest returns up to n of the most recent urls, newest first
+func latest(urls []string, n int) []string {
+	if n > len(urls) {
+		n = len(urls)
+	}
+
+	result := make([]string, 0, n)
+	for i := len(urls) - 1; i >= 0 && len(result) < n; i-- {
+		result = append(result, urls[i])
+	}
+	return result
+}
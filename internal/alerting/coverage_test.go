@@ -0,0 +1,75 @@
+package alerting
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCoverageMonitorFiresOnceBelowThreshold(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	monitor := NewCoverageMonitor(time.Hour, map[string]float64{"price": 0.8}, 4, 2)
+
+	for i := 0; i < 5; i++ {
+		url := fmt.Sprintf("https://example.com/anketa%d.htm", i)
+		monitor.Observe(url, map[string]bool{"price": i < 2, "phone": true}, start.Add(time.Duration(i)*time.Minute))
+	}
+
+	statuses := monitor.Check(start.Add(10 * time.Minute))
+	if len(statuses) != 1 {
+		t.Fatalf("Expected only the tracked field, got %d statuses", len(statuses))
+	}
+
+	regression := statuses[0].Regression
+	if regression == nil {
+		t.Fatalf("Expected a regression at coverage %v", statuses[0].Coverage)
+	}
+	if regression.Coverage != 0.4 || regression.Samples != 5 {
+		t.Errorf("Expected coverage 0.4 over 5 samples, got %v over %d", regression.Coverage, regression.Samples)
+	}
+	expected := []string{"https://example.com/anketa4.htm", "https://example.com/anketa3.htm"}
+	if fmt.Sprint(regression.FailingURLs) != fmt.Sprint(expected) {
+		t.Errorf("Expected failing URLs %v, got %v", expected, regression.FailingURLs)
+	}
+
+	if again := monitor.Check(start.Add(11 * time.Minute)); again[0].Regression != nil {
+		t.Errorf("Expected no repeated alert while the field stays below threshold")
+	}
+}
+
+func TestCoverageMonitorWindowAndRecovery(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	monitor := NewCoverageMonitor(time.Hour, map[string]float64{"phone": 0.5}, 2, 5)
+
+	monitor.Observe("a", map[string]bool{"phone": false}, start)
+	monitor.Observe("b", map[string]bool{"phone": false}, start)
+	if statuses := monitor.Check(start); statuses[0].Regression == nil {
+		t.Fatalf("Expected a regression with no phones parsed")
+	}
+
+	// The failing samples age out of the window and good listings replace them
+	later := start.Add(2 * time.Hour)
+	monitor.Observe("c", map[string]bool{"phone": true}, later)
+	monitor.Observe("d", map[string]bool{"phone": true}, later)
+	status := monitor.Check(later)[0]
+	if status.Samples != 2 || status.Coverage != 1 {
+		t.Errorf("Expected 2 samples at full coverage, got %d at %v", status.Samples, status.Coverage)
+	}
+
+	monitor.Observe("e", map[string]bool{"phone": false}, later)
+	monitor.Observe("f", map[string]bool{"phone": false}, later)
+	monitor.Observe("g", map[string]bool{"phone": false}, later)
+	if status := monitor.Check(later)[0]; status.Regression == nil {
+		t.Errorf("Expected the field to alert again after recovering, coverage %v", status.Coverage)
+	}
+}
+
+func TestCoverageMonitorNeedsMinSamples(t *testing.T) {
+	now := time.Now()
+	monitor := NewCoverageMonitor(time.Hour, map[string]float64{"price": 0.9}, 10, 5)
+
+	monitor.Observe("a", map[string]bool{"price": false}, now)
+	if status := monitor.Check(now)[0]; status.Regression != nil {
+		t.Errorf("Expected no alert below the minimum sample count")
+	}
+}
@@ -0,0 +1,185 @@
+package alerting
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Event types published by the GapMonitor
+const (
+	EventDiscoveryIDJump    = "discovery.id_jump"     // a new listing ID far ahead of the highest one seen
+	EventDiscoveryGapChange = "discovery.gap_density" // the share of unseen IDs below the highest one changed
+)
+
+// IDJump is the payload of a discovery.id_jump event: listings between PreviousMax and ID were
+// never discovered, or the site skipped a block of IDs
+type IDJump struct {
+	Site        string    `json:"site"`
+	PreviousMax int64     `json:"previous_max"`
+	ID          int64     `json:"id"`
+	Jump        int64     `json:"jump"`
+	DetectedAt  time.Time `json:"detected_at"`
+}
+
+// GapDensityChange is the payload of a discovery.gap_density event
+type GapDensityChange struct {
+	Site       string    `json:"site"`
+	MaxID      int64     `json:"max_id"`
+	Span       int64     `json:"span"` // IDs checked below MaxID
+	Gaps       int       `json:"gaps"`
+	Density    float64   `json:"density"`
+	Previous   float64   `json:"previous"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// SiteGaps is the discovery completeness of one site at a check
+type SiteGaps struct {
+	Site    string
+	MaxID   int64
+	Gaps    int     // IDs within the span below MaxID never seen
+	Density float64 // Gaps over the span
+
+	// Change is set when the density moved by at least the configured delta since the last check
+	Change *GapDensityChange
+}
+
+// siteIDs are the listing IDs seen on one site
+type siteIDs struct {
+	max     int64
+	seen    map[int64]bool // within the span below max
+	probed  map[int64]bool // gaps handed out by TakeGaps
+	density float64        // at the last check
+	checked bool
+}
+
+// GapMonitor tracks the numeric listing IDs seen per site. Anketa IDs are assigned roughly in
+// sequence, so unseen IDs below the highest one seen point at listings discovery missed.
+type GapMonitor struct {
+	mu            sync.Mutex
+	span          int64
+	jumpThreshold int64
+	densityDelta  float64
+	sites         map[string]*siteIDs
+}
+
+// NewGapMonitor creates a monitor checking the span IDs below the highest ID of each site. A new
+// ID more than jumpThreshold above the highest one is a jump; a density moving by densityDelta or
+// more between checks is a change.
+func NewGapMonitor(span, jumpThreshold int64, densityDelta float64) *GapMonitor {
+	if span <= 0 {
+		span = 5000
+	}
+	if jumpThreshold <= 0 {
+		jumpThreshold = 1000
+	}
+	if densityDelta <= 0 {
+		densityDelta = 0.1
+	}
+	return &GapMonitor{
+		span:          span,
+		jumpThreshold: jumpThreshold,
+		densityDelta:  densityDelta,
+		sites:         make(map[string]*siteIDs),
+	}
+}
+
+// Observe records a listing ID seen on site, returning the jump it makes past the highest ID seen
+// so far, if any. IDs that are not numbers are ignored.
+func (m *GapMonitor) Observe(site, sourceID string, at time.Time) *IDJump {
+	id, err := strconv.ParseInt(sourceID, 10, 64)
+	if err != nil || id <= 0 {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids, exists := m.sites[site]
+	if !exists {
+		ids = &siteIDs{seen: make(map[int64]bool), probed: make(map[int64]bool)}
+		m.sites[site] = ids
+	}
+
+	var jump *IDJump
+	if id > ids.max {
+		if ids.max > 0 && id-ids.max > m.jumpThreshold {
+			jump = &IDJump{Site: site, PreviousMax: ids.max, ID: id, Jump: id - ids.max, DetectedAt: at}
+		}
+		ids.max = id
+		ids.prune(id - m.span)
+	}
+	if id > ids.max-m.span {
+		ids.seen[id] = true
+	}
+	return jump
+}
+
+// prune forgets the IDs at or below floor
+func (s *siteIDs) prune(floor int64) {
+	for id := range s.seen {
+		if id <= floor {
+			delete(s.seen, id)
+		}
+	}
+	for id := range s.probed {
+		if id <= floor {
+			delete(s.probed, id)
+		}
+	}
+}
+
+// Check returns the gaps of every site, sorted by site, with the density changes since the last check
+func (m *GapMonitor) Check(now time.Time) []SiteGaps {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var result []SiteGaps
+	for site, ids := range m.sites {
+		span := min(m.span, ids.max)
+		gaps := int(span) - len(ids.seen)
+		status := SiteGaps{Site: site, MaxID: ids.max, Gaps: gaps, Density: float64(gaps) / float64(span)}
+
+		if ids.checked && math.Abs(status.Density-ids.density) >= m.densityDelta {
+			status.Change = &GapDensityChange{
+				Site:       site,
+				MaxID:      ids.max,
+				Span:       span,
+				Gaps:       gaps,
+				Density:    status.Density,
+				Previous:   ids.density,
+				DetectedAt: now,
+			}
+		}
+		ids.density = status.Density
+		ids.checked = true
+		result = append(result, status)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Site < result[j].Site })
+	return result
+}
+
+// TakeGaps returns up to limit unseen IDs of site, highest first, that no earlier call returned,
+// so a backfill probes every gap once
+func (m *GapMonitor) TakeGaps(site string, limit int) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids, exists := m.sites[site]
+	if !exists {
+		return nil
+	}
+
+	var gaps []string
+	for id := ids.max - 1; id > ids.max-m.span && id > 0 && len(gaps) < limit; id-- {
+		if ids.seen[id] || ids.probed[id] {
+			continue
+		}
+		ids.probed[id] = true
+		gaps = append(gaps, strconv.FormatInt(id, 10))
+	}
+	return gaps
+}
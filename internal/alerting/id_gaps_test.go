@@ -0,0 +1,75 @@
+package alerting
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestGapMonitorDetectsJumps(t *testing.T) {
+	at := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	monitor := NewGapMonitor(100, 50, 0.1)
+
+	if jump := monitor.Observe("site", "1000", at); jump != nil {
+		t.Errorf("Expected no jump for the first ID, got %+v", jump)
+	}
+	if jump := monitor.Observe("site", "1040", at); jump != nil {
+		t.Errorf("Expected no jump within the threshold, got %+v", jump)
+	}
+	if jump := monitor.Observe("site", "anketa", at); jump != nil {
+		t.Errorf("Expected non-numeric IDs ignored, got %+v", jump)
+	}
+
+	jump := monitor.Observe("site", "1200", at)
+	if jump == nil || jump.PreviousMax != 1040 || jump.Jump != 160 {
+		t.Errorf("Expected a jump of 160 past 1040, got %+v", jump)
+	}
+}
+
+func TestGapMonitorReportsDensityChanges(t *testing.T) {
+	at := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	monitor := NewGapMonitor(10, 100, 0.2)
+
+	for id := 91; id <= 100; id++ {
+		monitor.Observe("site", strconv.Itoa(id), at)
+	}
+	statuses := monitor.Check(at)
+	if len(statuses) != 1 || statuses[0].Gaps != 0 || statuses[0].Change != nil {
+		t.Fatalf("Expected a complete first check without a change, got %+v", statuses)
+	}
+
+	// Every other ID of the next ten is missed
+	for id := 102; id <= 110; id += 2 {
+		monitor.Observe("site", strconv.Itoa(id), at)
+	}
+	status := monitor.Check(at)[0]
+	if status.MaxID != 110 || status.Gaps != 5 || status.Density != 0.5 {
+		t.Errorf("Expected 5 gaps below 110, got %+v", status)
+	}
+	if status.Change == nil || status.Change.Previous != 0 || status.Change.Density != 0.5 {
+		t.Errorf("Expected a density change from 0 to 0.5, got %+v", status.Change)
+	}
+
+	if status := monitor.Check(at)[0]; status.Change != nil {
+		t.Errorf("Expected no change on an unchanged density, got %+v", status.Change)
+	}
+}
+
+func TestGapMonitorTakesEachGapOnce(t *testing.T) {
+	at := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	monitor := NewGapMonitor(10, 100, 0.1)
+	for _, id := range []string{"100", "98", "95"} {
+		monitor.Observe("site", id, at)
+	}
+
+	if gaps := monitor.TakeGaps("site", 3); !reflect.DeepEqual(gaps, []string{"99", "97", "96"}) {
+		t.Errorf("Expected the highest gaps first, got %v", gaps)
+	}
+	if gaps := monitor.TakeGaps("site", 10); !reflect.DeepEqual(gaps, []string{"94", "93", "92", "91"}) {
+		t.Errorf("Expected the remaining gaps within the span, got %v", gaps)
+	}
+	if gaps := monitor.TakeGaps("other", 10); gaps != nil {
+		t.Errorf("Expected no gaps for an unseen site, got %v", gaps)
+	}
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+)
+
+// API modes
+const (
+	ModeFull      = "full"      // every endpoint
+	ModeRead      = "read"      // only the endpoints reading listings and statistics, for the analytics API
+	ModeAggregate = "aggregate" // only the k-anonymous aggregate statistics, for public research dashboards
+)
+
+// aggregatesPath is the path of the aggregate statistics endpoint
+const aggregatesPath = "/api/v1/aggregates"
+
+// analyticsPath is the prefix of the analytics endpoints, the aggregate statistics of a fixed
+// grouping for dashboards
+const analyticsPath = "/api/v1/analytics/"
+
+// analyticsGroupings maps the analytics endpoints to the grouping they report
+var analyticsGroupings = map[string]string{
+	"cities":    clickhouse.AggregateByCity,     // price percentiles per city
+	"metro":     clickhouse.AggregateByMetro,    // listings per metro station
+	"districts": clickhouse.AggregateByDistrict, // average age per district
+}
+
+// isAggregatePath reports whether a path serves only k-anonymous aggregate statistics
+func isAggregatePath(path string) bool {
+	return path == aggregatesPath || strings.HasPrefix(path, analyticsPath)
+}
+
+// DefaultMinBucket is the k-anonymity threshold used when none is configured
+const DefaultMinBucket = 10
+
+// SetMode switches between the full API, the read-only API and the aggregate-only API
+func (s *Server) SetMode(mode string) error {
+	switch mode {
+	case "", ModeFull:
+		s.aggregateOnly, s.readOnly = false, false
+	case ModeRead:
+		s.aggregateOnly, s.readOnly = false, true
+	case ModeAggregate:
+		s.aggregateOnly, s.readOnly = true, true
+	default:
+		return fmt.Errorf("unknown api mode %q", mode)
+	}
+	return nil
+}
+
+// SetMinBucket sets the smallest number of listings an aggregate bucket may describe; requests
+// can only raise it
+func (s *Server) SetMinBucket(k int) {
+	s.minBucket = max(k, 1)
+}
+
+// restrictAggregateKeys refuses every endpoint but the aggregate statistics and analytics to
+// aggregate-only keys. Must run after Authenticate.
+func restrictAggregateKeys(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := KeyFromContext(r.Context()); key != nil && key.AggregateOnly && !isAggregatePath(r.URL.Path) {
+			writeError(w, http.StatusForbidden, "api key is limited to aggregate statistics")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleAggregates serves listing counts, hourly price distributions and average ages per city,
+// metro station, district or date, leaving out buckets with fewer than the minimum number of listings
+func (s *Server) handleAggregates(w http.ResponseWriter, r *http.Request) {
+	query, err := parseAggregateQuery(r, s.minBucket)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.serveAggregates(w, r, query)
+}
+
+// handleAnalytics serves the aggregate statistics of the grouping of an analytics endpoint, with
+// the same filters and k-anonymity as the aggregates endpoint
+func (s *Server) handleAnalytics(w http.ResponseWriter, r *http.Request) {
+	groupBy, ok := analyticsGroupings[r.PathValue("report")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown analytics report")
+		return
+	}
+
+	query, err := parseAggregateValues(groupBy, r.URL.Query(), s.minBucket)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.serveAggregates(w, r, query)
+}
+
+// serveAggregates writes the k-anonymous report of an aggregate query
+func (s *Server) serveAggregates(w http.ResponseWriter, r *http.Request, query clickhouse.AggregateQuery) {
+	report, err := s.reader(r).AggregateListings(r.Context(), query)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// parseAggregateQuery reads the grouping and filters of an aggregate query from the query string.
+// Dates are whole days, so two overlapping queries cannot be subtracted to isolate a single listing
+// by the time it was stored.
+func parseAggregateQuery(r *http.Request, minBucket int) (clickhouse.AggregateQuery, error) {
+	values := r.URL.Query()
+	return parseAggregateValues(values.Get("group_by"), values, minBucket)
+}
+
+// parseAggregateValues reads the filters of an aggregate query with the given grouping
+func parseAggregateValues(groupBy string, values url.Values, minBucket int) (clickhouse.AggregateQuery, error) {
+	query := clickhouse.AggregateQuery{
+		GroupBy:   groupBy,
+		Interval:  values.Get("interval"),
+		City:      values.Get("city"),
+		MinBucket: max(minBucket, 1),
+	}
+
+	switch query.GroupBy {
+	case clickhouse.AggregateByCity, clickhouse.AggregateByMetro, clickhouse.AggregateByDistrict:
+		if query.Interval != "" {
+			return query, fmt.Errorf("interval only applies to group_by=%s", clickhouse.AggregateByDate)
+		}
+	case clickhouse.AggregateByDate:
+		switch query.Interval {
+		case "", clickhouse.IntervalDay, clickhouse.IntervalWeek, clickhouse.IntervalMonth:
+		default:
+			return query, fmt.Errorf("interval must be %s, %s or %s", clickhouse.IntervalDay, clickhouse.IntervalWeek, clickhouse.IntervalMonth)
+		}
+	default:
+		return query, fmt.Errorf("group_by must be %s, %s, %s or %s", clickhouse.AggregateByCity, clickhouse.AggregateByMetro,
+			clickhouse.AggregateByDistrict, clickhouse.AggregateByDate)
+	}
+
+	var err error
+	if query.Since, err = parseDate(values.Get("since"), "since"); err != nil {
+		return query, err
+	}
+	if query.Until, err = parseDate(values.Get("until"), "until"); err != nil {
+		return query, err
+	}
+	if !query.Since.IsZero() && !query.Until.IsZero() && !query.Since.Before(query.Until) {
+		return query, fmt.Errorf("since must be before until")
+	}
+
+	if value := values.Get("min_bucket"); value != "" {
+		k, err := strconv.Atoi(value)
+		if err != nil || k < query.MinBucket {
+			return query, fmt.Errorf("min_bucket must be an integer of at least %d", query.MinBucket)
+		}
+		query.MinBucket = k
+	}
+	return query, nil
+}
+
+// parseDate reads an optional YYYY-MM-DD date, the zero time when missing
+func parseDate(value, name string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	date, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be a date (YYYY-MM-DD)", name)
+	}
+	return date, nil
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseAggregateQuery(t *testing.T) {
+	query, err := parseAggregateQuery(httptest.NewRequest(http.MethodGet, "/api/v1/aggregates?group_by=date&interval=week&since=2025-01-01&until=2025-02-01", nil), 10)
+	if err != nil {
+		t.Fatalf("Expected a valid query, got %v", err)
+	}
+	if query.GroupBy != "date" || query.Interval != "week" || query.MinBucket != 10 {
+		t.Errorf("Unexpected grouping: %+v", query)
+	}
+	if !query.Since.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) || !query.Until.Equal(time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected date range: %v - %v", query.Since, query.Until)
+	}
+
+	query, err = parseAggregateQuery(httptest.NewRequest(http.MethodGet, "/api/v1/aggregates?group_by=city&min_bucket=25", nil), 10)
+	if err != nil || query.MinBucket != 25 {
+		t.Errorf("Expected min_bucket to be raised to 25, got %d, %v", query.MinBucket, err)
+	}
+
+	query, err = parseAggregateQuery(httptest.NewRequest(http.MethodGet, "/api/v1/aggregates?group_by=district", nil), 10)
+	if err != nil || query.GroupBy != "district" {
+		t.Errorf("Expected grouping by district, got %q, %v", query.GroupBy, err)
+	}
+
+	for _, target := range []string{
+		"/api/v1/aggregates",
+		"/api/v1/aggregates?group_by=phone",
+		"/api/v1/aggregates?group_by=city&interval=day",
+		"/api/v1/aggregates?group_by=date&interval=hour",
+		"/api/v1/aggregates?group_by=city&min_bucket=2",
+		"/api/v1/aggregates?group_by=city&since=2025-01-01T10:00:00Z",
+		"/api/v1/aggregates?group_by=city&since=2025-02-01&until=2025-01-01",
+	} {
+		if _, err := parseAggregateQuery(httptest.NewRequest(http.MethodGet, target, nil), 10); err == nil {
+			t.Errorf("Expected an error for %s", target)
+		}
+	}
+}
+
+func TestAggregateOnlyKeyIsRestricted(t *testing.T) {
+	store, err := NewKeyStore(&APIKey{Key: "research", Name: "research", AggregateOnly: true, Admin: true})
+	if err != nil {
+		t.Fatalf("Failed to create key store: %v", err)
+	}
+	handler := NewServer(nil, store).Handler()
+
+	for _, target := range []string{"/api/v1/listings", "/api/v1/stats", "/api/v1/exclusions"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("X-API-Key", "research")
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusForbidden {
+			t.Errorf("%s: expected %d for an aggregate-only key, got %d", target, http.StatusForbidden, recorder.Code)
+		}
+	}
+
+	for _, target := range []string{"/api/v1/aggregates?group_by=phone", "/api/v1/analytics/cities?interval=week"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("X-API-Key", "research")
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected the endpoint to be reachable, got %d", target, recorder.Code)
+		}
+	}
+}
+
+func TestAnalyticsReports(t *testing.T) {
+	store, err := NewKeyStore(&APIKey{Key: "secret", Name: "ops", Admin: true})
+	if err != nil {
+		t.Fatalf("Failed to create key store: %v", err)
+	}
+	server := NewServer(nil, store)
+	if err := server.SetMode(ModeAggregate); err != nil {
+		t.Fatalf("Failed to set mode: %v", err)
+	}
+
+	tests := map[string]int{
+		"/api/v1/analytics/phones":                     http.StatusNotFound,
+		"/api/v1/analytics/districts?min_bucket=2":     http.StatusBadRequest,
+		"/api/v1/analytics/metro?since=2025-13-01":     http.StatusBadRequest,
+		"/api/v1/analytics/cities?interval=month&city": http.StatusBadRequest,
+	}
+	for target, expected := range tests {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("X-API-Key", "secret")
+		recorder := httptest.NewRecorder()
+		server.Handler().ServeHTTP(recorder, req)
+
+		if recorder.Code != expected {
+			t.Errorf("%s: expected %d, got %d", target, expected, recorder.Code)
+		}
+	}
+}
+
+func TestAggregateModeServesOnlyAggregates(t *testing.T) {
+	store, err := NewKeyStore(&APIKey{Key: "secret", Name: "ops", Admin: true})
+	if err != nil {
+		t.Fatalf("Failed to create key store: %v", err)
+	}
+	server := NewServer(nil, store)
+	if err := server.SetMode(ModeAggregate); err != nil {
+		t.Fatalf("Failed to set mode: %v", err)
+	}
+	server.SetDashboard(DashboardSources{})
+
+	for _, target := range []string{"/api/v1/listings", "/api/v1/listings/site:1", "/dashboard"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("X-API-Key", "secret")
+		recorder := httptest.NewRecorder()
+		server.Handler().ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("%s: expected %d in aggregate mode, got %d", target, http.StatusNotFound, recorder.Code)
+		}
+	}
+
+	if err := server.SetMode("public"); err == nil {
+		t.Errorf("Expected an error for an unknown mode")
+	}
+}
+
+func TestReadModeLeavesOutAdminEndpoints(t *testing.T) {
+	store, err := NewKeyStore(&APIKey{Key: "secret", Name: "ops", Admin: true})
+	if err != nil {
+		t.Fatalf("Failed to create key store: %v", err)
+	}
+	server := NewServer(nil, store)
+	if err := server.SetMode(ModeRead); err != nil {
+		t.Fatalf("Failed to set mode: %v", err)
+	}
+
+	for _, target := range []string{"/api/v1/exclusions", "/api/v1/schedules"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("X-API-Key", "secret")
+		recorder := httptest.NewRecorder()
+		server.Handler().ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("%s: expected %d in read mode, got %d", target, http.StatusNotFound, recorder.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/changes?limit=0", nil)
+	req.Header.Set("X-API-Key", "secret")
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected the change feed to be reachable in read mode, got %d", recorder.Code)
+	}
+}
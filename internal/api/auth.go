@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+)
+
+// APIKey is a credential together with the data it may access
+type APIKey struct {
+	Key           string   `json:"key"`
+	Name          string   `json:"name"`
+	Cities        []string `json:"cities,omitempty"`         // allowed cities, empty means all
+	Sites         []string `json:"sites,omitempty"`          // allowed source sites, empty means all
+	HiddenFields  []string `json:"hidden_fields,omitempty"`  // field groups removed from responses, e.g. "contact"
+	Admin         bool     `json:"admin,omitempty"`          // may manage exclusions and crawl schedules; only honored for unrestricted keys
+	AggregateOnly bool     `json:"aggregate_only,omitempty"` // may only read the k-anonymous aggregate statistics
+}
+
+// IsAdmin reports whether the key may use administrative endpoints
+func (k *APIKey) IsAdmin() bool {
+	return k.Admin && !k.AggregateOnly && k.Scope().IsUnrestricted()
+}
+
+// Scope returns the query restrictions of the key
+func (k *APIKey) Scope() clickhouse.Scope {
+	return clickhouse.Scope{
+		Cities:       k.Cities,
+		Sites:        k.Sites,
+		HiddenFields: k.HiddenFields,
+	}
+}
+
+// KeyStore holds the API keys accepted by the server
+type KeyStore struct {
+	keys []*APIKey
+}
+
+// NewKeyStore creates a key store, validating every key's scope
+func NewKeyStore(keys ...*APIKey) (*KeyStore, error) {
+	store := &KeyStore{}
+	for _, key := range keys {
+		if key.Key == "" {
+			return nil, fmt.Errorf("api key %q has an empty key", key.Name)
+		}
+		if err := key.Scope().Validate(); err != nil {
+			return nil, fmt.Errorf("invalid scope for api key %q: %w", key.Name, err)
+		}
+		store.keys = append(store.keys, key)
+	}
+	return store, nil
+}
+
+// LoadKeyStore reads a JSON array of API keys from path. If fullAccessKey is not empty it is
+// added as an unrestricted admin key named "default".
+func LoadKeyStore(path, fullAccessKey string) (*KeyStore, error) {
+	var keys []*APIKey
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read api keys file: %w", err)
+		}
+		if err := json.Unmarshal(data, &keys); err != nil {
+			return nil, fmt.Errorf("failed to parse api keys file: %w", err)
+		}
+	}
+
+	if fullAccessKey != "" {
+		keys = append(keys, &APIKey{Key: fullAccessKey, Name: "default", Admin: true})
+	}
+
+	return NewKeyStore(keys...)
+}
+
+// Lookup returns the key matching the presented credential, or nil
+func (s *KeyStore) Lookup(presented string) *APIKey {
+	if presented == "" {
+		return nil
+	}
+
+	for _, key := range s.keys {
+		if subtle.ConstantTimeCompare([]byte(key.Key), []byte(presented)) == 1 {
+			return key
+		}
+	}
+	return nil
+}
+
+// keyContextKey is the context key under which the authenticated APIKey is stored
+type keyContextKey struct{}
+
+// KeyFromContext returns the API key that authenticated the request
+func KeyFromContext(ctx context.Context) *APIKey {
+	key, _ := ctx.Value(keyContextKey{}).(*APIKey)
+	return key
+}
+
+// Authenticate rejects requests without a valid key and stores the key in the request context.
+// The key is read from the X-API-Key header, an "Authorization: Bearer" header or, for
+// browsers opening the dashboard, the password of HTTP basic auth.
+func (s *KeyStore) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		presented := r.Header.Get("X-API-Key")
+		if _, password, ok := r.BasicAuth(); presented == "" && ok {
+			presented = password
+		}
+		if presented == "" {
+			presented = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+
+		key := s.Lookup(presented)
+		if key == nil {
+			if strings.HasPrefix(r.URL.Path, "/dashboard") {
+				w.Header().Set("WWW-Authenticate", `Basic realm="hoe_parser dashboard"`)
+			}
+			writeError(w, http.StatusUnauthorized, "invalid or missing api key")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), keyContextKey{}, key)))
+	})
+}
+
+// RequireAdmin rejects requests whose key is not an admin key. Must run after Authenticate.
+func RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if key := KeyFromContext(r.Context()); key == nil || !key.IsAdmin() {
+			writeError(w, http.StatusForbidden, "admin api key required")
+			return
+		}
+		next(w, r)
+	}
+}
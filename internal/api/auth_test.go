@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthenticateScopesRequest(t *testing.T) {
+	store, err := NewKeyStore(
+		&APIKey{Key: "partner", Name: "partner", Cities: []string{"Москва"}, HiddenFields: []string{"contact"}},
+		&APIKey{Key: "internal", Name: "internal"},
+	)
+	if err != nil {
+		t.Fatalf("Failed to create key store: %v", err)
+	}
+
+	var seen *APIKey
+	handler := store.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = KeyFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/stats", nil)
+	req.Header.Set("X-API-Key", "partner")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if seen == nil || seen.Name != "partner" {
+		t.Fatalf("Expected partner key in context, got %+v", seen)
+	}
+	if scope := seen.Scope(); len(scope.Cities) != 1 || scope.HiddenFields[0] != "contact" {
+		t.Errorf("Expected partner scope, got %+v", scope)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/stats", nil)
+	req.Header.Set("Authorization", "Bearer internal")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if seen.Name != "internal" || !seen.Scope().IsUnrestricted() {
+		t.Errorf("Expected unrestricted internal key, got %+v", seen)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/stats", nil)
+	req.Header.Set("X-API-Key", "wrong")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for unknown key, got %d", recorder.Code)
+	}
+}
+
+func TestNewKeyStoreRejectsUnknownFieldGroup(t *testing.T) {
+	if _, err := NewKeyStore(&APIKey{Key: "k", Name: "bad", HiddenFields: []string{"everything"}}); err == nil {
+		t.Errorf("Expected error for unknown field group")
+	}
+}
+
+func TestRequireAdminRejectsScopedKeys(t *testing.T) {
+	store, err := LoadKeyStore("", "root")
+	if err != nil {
+		t.Fatalf("Failed to load key store: %v", err)
+	}
+	store.keys = append(store.keys, &APIKey{Key: "scoped", Name: "scoped", Admin: true, Sites: []string{"intimcity.gold"}})
+
+	handler := store.Authenticate(RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for key, expected := range map[string]int{"root": http.StatusNoContent, "scoped": http.StatusForbidden} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/exclusions", nil)
+		req.Header.Set("X-API-Key", key)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		if recorder.Code != expected {
+			t.Errorf("Expected %d for key %s, got %d", expected, key, recorder.Code)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/lifecycle"
+)
+
+// CrawlController pauses and resumes the whole crawl, discovery and scraping alike
+type CrawlController interface {
+	Pause(reason, by string) lifecycle.PauseState
+	Resume(by string) lifecycle.PauseState
+	State() lifecycle.PauseState
+}
+
+// pauseCrawlRequest is the optional body of POST /api/v1/admin/crawl/pause
+type pauseCrawlRequest struct {
+	Reason string `json:"reason"`
+}
+
+// SetCrawlController lets admin keys pause and resume the crawl of controller under
+// /api/v1/admin/crawl while the API keeps serving
+func (s *Server) SetCrawlController(controller CrawlController) {
+	s.crawl = controller
+}
+
+// registerCrawl adds the crawl pause routes to mux
+func (s *Server) registerCrawl(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/admin/crawl", RequireAdmin(s.handleCrawlState))
+	mux.HandleFunc("POST /api/v1/admin/crawl/pause", RequireAdmin(s.handlePauseCrawl))
+	mux.HandleFunc("POST /api/v1/admin/crawl/resume", RequireAdmin(s.handleResumeCrawl))
+}
+
+// handleCrawlState serves whether the crawl is paused
+func (s *Server) handleCrawlState(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.localize(s.crawl.State()))
+}
+
+// handlePauseCrawl pauses discovery and scraping. Requests in flight finish; queued links wait.
+func (s *Server) handlePauseCrawl(w http.ResponseWriter, r *http.Request) {
+	var request pauseCrawlRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+
+	key := KeyFromContext(r.Context()).Name
+	state := s.crawl.Pause(request.Reason, "api:"+key)
+	log.Info("Crawl paused through the API", "reason", request.Reason, "key", key)
+	writeJSON(w, http.StatusOK, s.localize(state))
+}
+
+// handleResumeCrawl resumes a paused crawl
+func (s *Server) handleResumeCrawl(w http.ResponseWriter, r *http.Request) {
+	key := KeyFromContext(r.Context()).Name
+	state := s.crawl.Resume("api:" + key)
+	log.Info("Crawl resumed through the API", "key", key)
+	writeJSON(w, http.StatusOK, s.localize(state))
+}
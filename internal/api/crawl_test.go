@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/lifecycle"
+)
+
+func TestCrawlRoutes(t *testing.T) {
+	store, err := NewKeyStore(&APIKey{Key: "secret", Name: "ops", Admin: true}, &APIKey{Key: "reader", Name: "reader"})
+	if err != nil {
+		t.Fatalf("Failed to create key store: %v", err)
+	}
+	pause := lifecycle.NewPause()
+	server := NewServer(nil, store)
+	server.SetCrawlController(pause)
+	handler := server.Handler()
+
+	serve := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", key)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	if recorder := serve(http.MethodPost, "/api/v1/admin/crawl/pause", "reader", ""); recorder.Code != http.StatusForbidden {
+		t.Errorf("Expected %d for a non-admin key, got %d", http.StatusForbidden, recorder.Code)
+	}
+	if pause.State().Paused {
+		t.Fatalf("Expected a non-admin key not to pause the crawl")
+	}
+
+	recorder := serve(http.MethodPost, "/api/v1/admin/crawl/pause", "secret", `{"reason":"site maintenance"}`)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+	}
+	var state lifecycle.PauseState
+	if err := json.NewDecoder(recorder.Body).Decode(&state); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !state.Paused || state.Reason != "site maintenance" || state.By != "api:ops" {
+		t.Errorf("Expected the crawl paused by api:ops for site maintenance, got %+v", state)
+	}
+
+	if recorder := serve(http.MethodPost, "/api/v1/admin/crawl/resume", "secret", ""); recorder.Code != http.StatusOK {
+		t.Errorf("Expected %d, got %d", http.StatusOK, recorder.Code)
+	}
+	if pause.State().Paused {
+		t.Errorf("Expected the crawl to run again after resume")
+	}
+
+	if recorder := serve(http.MethodPost, "/api/v1/admin/crawl/pause", "secret", "{"); recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected %d for an invalid body, got %d", http.StatusBadRequest, recorder.Code)
+	}
+}
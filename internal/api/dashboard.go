@@ -0,0 +1,295 @@
+package api
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"reflect"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+	"github.com/gregor-tokarev/hoe_parser/internal/diagnostics"
+	"github.com/gregor-tokarev/hoe_parser/internal/modules/request_client"
+	"github.com/gregor-tokarev/hoe_parser/pkg/format"
+)
+
+//go:embed templates/*.html
+var dashboardFS embed.FS
+
+// dashboardTemplates holds one template set per locale and page, each combined with the shared layout
+var dashboardTemplates = map[format.Locale]map[string]*template.Template{
+	format.English: parseDashboardTemplates(format.English, "overview", "listings", "coverage", "proxies"),
+	format.Russian: parseDashboardTemplates(format.Russian, "overview", "listings", "coverage", "proxies"),
+}
+
+// dashboardListings is the number of listings shown on the recent listings page
+const dashboardListings = 50
+
+// DashboardSources provides the live pipeline state shown next to the stored data; nil
+// functions leave their section out
+type DashboardSources struct {
+	Pipeline func() diagnostics.Snapshot
+	Proxies  func() []request_client.ProxyStats
+}
+
+// dashboardPage is the data every dashboard template receives
+type dashboardPage struct {
+	Title       string
+	Page        string
+	GeneratedAt time.Time
+	Error       string
+	Data        any
+}
+
+// coverageBar is one bar of the parse coverage chart
+type coverageBar struct {
+	Label string
+	Count uint64
+	Share float64
+}
+
+// SetDashboard enables the HTML dashboard at /dashboard, showing stored data from the adapter
+// and live state from sources
+func (s *Server) SetDashboard(sources DashboardSources) {
+	s.dashboard = &sources
+}
+
+// SetLocale sets the locale numbers, prices and shares are written in on dashboard pages; JSON
+// responses keep raw numbers
+func (s *Server) SetLocale(locale format.Locale) {
+	if _, ok := dashboardTemplates[locale]; !ok {
+		locale = format.English
+	}
+	s.locale = locale
+}
+
+// registerDashboard adds the dashboard pages to mux
+func (s *Server) registerDashboard(mux *http.ServeMux) {
+	mux.HandleFunc("GET /dashboard", s.requireUnrestricted(s.handleDashboardOverview))
+	mux.HandleFunc("GET /dashboard/listings", s.requireUnrestricted(s.handleDashboardListings))
+	mux.HandleFunc("GET /dashboard/coverage", s.requireUnrestricted(s.handleDashboardCoverage))
+	mux.HandleFunc("GET /dashboard/proxies", s.requireUnrestricted(s.handleDashboardProxies))
+}
+
+// requireUnrestricted rejects keys restricted to a part of the data: the dashboard shows
+// pipeline and proxy state across every site
+func (s *Server) requireUnrestricted(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !KeyFromContext(r.Context()).Scope().IsUnrestricted() {
+			http.Error(w, "dashboard requires an unrestricted api key", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handleDashboardOverview renders the crawl cycle status and the precomputed dashboard numbers
+func (s *Server) handleDashboardOverview(w http.ResponseWriter, r *http.Request) {
+	page := dashboardPage{Title: "Overview", Page: "overview"}
+
+	data := struct {
+		Stats    *clickhouse.DashboardStats
+		Pipeline *diagnostics.Snapshot
+	}{}
+
+	stats, err := s.adapter.GetDashboardStats(r.Context())
+	if err != nil && !errors.Is(err, clickhouse.ErrNoDashboardStats) {
+		page.Error = err.Error()
+	}
+	data.Stats = stats
+
+	if s.dashboard.Pipeline != nil {
+		snapshot := s.dashboard.Pipeline()
+		data.Pipeline = &snapshot
+	}
+
+	page.Data = data
+	s.renderDashboard(w, page)
+}
+
+// handleDashboardListings renders the most recently scraped listings
+func (s *Server) handleDashboardListings(w http.ResponseWriter, r *http.Request) {
+	page := dashboardPage{Title: "Recent listings", Page: "listings"}
+
+	listings, err := s.adapter.QueryListings(r.Context(), clickhouse.ListingQuery{Limit: dashboardListings})
+	if err != nil {
+		page.Error = err.Error()
+	}
+
+	page.Data = listings
+	s.renderDashboard(w, page)
+}
+
+// handleDashboardCoverage renders how many stored listings have each key field parsed
+func (s *Server) handleDashboardCoverage(w http.ResponseWriter, r *http.Request) {
+	page := dashboardPage{Title: "Parse coverage", Page: "coverage"}
+
+	stats, err := s.adapter.GetStats(r.Context())
+	if err != nil {
+		page.Error = err.Error()
+		s.renderDashboard(w, page)
+		return
+	}
+
+	data := struct {
+		Total        uint64
+		Fields       []coverageBar
+		Completeness []coverageBar
+		Quality      float64
+		QualityFlags []coverageBar
+	}{}
+	data.Total, _ = stats["total_listings"].(uint64)
+	data.Fields = coverageBars(stats, data.Total, []string{"age", "price", "phone", "photos"})
+
+	for _, name := range []string{"p10", "p25", "p50", "p75", "p90"} {
+		if value, ok := stats["completeness_"+name].(float64); ok {
+			data.Completeness = append(data.Completeness, coverageBar{Label: name, Share: value})
+		}
+	}
+
+	data.Quality, _ = stats["avg_quality_score"].(float64)
+	byFlag, _ := stats["listings_by_quality_flag"].(map[string]uint64)
+	for _, flag := range clickhouse.QualityFlags {
+		bar := coverageBar{Label: flag, Count: byFlag[flag]}
+		if data.Total > 0 {
+			bar.Share = float64(bar.Count) / float64(data.Total)
+		}
+		data.QualityFlags = append(data.QualityFlags, bar)
+	}
+
+	page.Data = data
+	s.renderDashboard(w, page)
+}
+
+// coverageBars builds coverage bars from the listings_with_<field> counts of GetStats
+func coverageBars(stats map[string]interface{}, total uint64, fields []string) []coverageBar {
+	bars := make([]coverageBar, 0, len(fields))
+	for _, field := range fields {
+		count, _ := stats["listings_with_"+field].(uint64)
+		bar := coverageBar{Label: field, Count: count}
+		if total > 0 {
+			bar.Share = float64(count) / float64(total)
+		}
+		bars = append(bars, bar)
+	}
+	return bars
+}
+
+// handleDashboardProxies renders per-proxy request counts, failure ratios and latency
+func (s *Server) handleDashboardProxies(w http.ResponseWriter, r *http.Request) {
+	page := dashboardPage{Title: "Proxy health", Page: "proxies"}
+
+	var proxies []request_client.ProxyStats
+	if s.dashboard.Proxies != nil {
+		proxies = s.dashboard.Proxies()
+	}
+
+	page.Data = proxies
+	s.renderDashboard(w, page)
+}
+
+// renderDashboard executes the page template into a buffer, so a template error never leaves
+// a half-written page. Times are shown in the display time zone.
+func (s *Server) renderDashboard(w http.ResponseWriter, page dashboardPage) {
+	page.GeneratedAt = clock.Now()
+	page = s.localize(page).(dashboardPage)
+
+	var buf bytes.Buffer
+	if err := dashboardTemplates[s.locale][page.Page].ExecuteTemplate(&buf, "layout", page); err != nil {
+		log.Error("Failed to render dashboard page", "page", page.Page, "error", err)
+		http.Error(w, "failed to render page", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
+}
+
+// redactProxy removes the credentials from a proxy URL before it is shown
+func redactProxy(proxy string) string {
+	parsed, err := url.Parse(proxy)
+	if err != nil || parsed.User == nil {
+		return proxy
+	}
+	parsed.User = nil
+	return parsed.String()
+}
+
+// parseDashboardTemplates parses every page together with the layout, writing numbers in locale
+func parseDashboardTemplates(locale format.Locale, pages ...string) map[string]*template.Template {
+	funcs := template.FuncMap{
+		"lang": func() string { return string(locale) },
+		"percent": func(share any) string {
+			if v, ok := floatValue(share); ok {
+				return locale.Percent(v, 0)
+			}
+			return ""
+		},
+		// barWidth is a share as a CSS width, which must not be localized
+		"barWidth": func(share any) string {
+			if v, ok := floatValue(share); ok {
+				return fmt.Sprintf("%.0f%%", v*100)
+			}
+			return ""
+		},
+		"number": func(n any) string {
+			value := reflect.ValueOf(n)
+			switch {
+			case value.CanInt():
+				return locale.Int(value.Int())
+			case value.CanUint():
+				return locale.Uint(value.Uint())
+			}
+			return fmt.Sprint(n)
+		},
+		"decimal": func(v any, decimals int) string {
+			if f, ok := floatValue(v); ok {
+				return locale.Decimal(f, decimals)
+			}
+			return ""
+		},
+		"money": func(amount any, currency string) string {
+			value := reflect.ValueOf(amount)
+			switch {
+			case value.CanInt():
+				return locale.Money(value.Int(), currency)
+			case value.CanUint():
+				return locale.Money(int64(value.Uint()), currency)
+			}
+			return fmt.Sprint(amount)
+		},
+		"proxyHost": redactProxy,
+		"ms": func(d time.Duration) string {
+			return fmt.Sprintf("%d ms", d.Milliseconds())
+		},
+		"since": func(at time.Time) string {
+			if at.IsZero() {
+				return "never"
+			}
+			return time.Since(at).Round(time.Second).String() + " ago"
+		},
+	}
+
+	templates := make(map[string]*template.Template, len(pages))
+	for _, page := range pages {
+		templates[page] = template.Must(template.New(page).Funcs(funcs).ParseFS(dashboardFS,
+			"templates/layout.html", "templates/"+page+".html"))
+	}
+	return templates
+}
+
+// floatValue returns a float32 or float64 as float64
+func floatValue(v any) (float64, bool) {
+	switch f := v.(type) {
+	case float32:
+		return float64(f), true
+	case float64:
+		return f, true
+	}
+	return 0, false
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	listing "github.com/gregor-tokarev/hoe_parser/proto"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// Response media types offered by the listing endpoints
+const (
+	ContentTypeJSON    = "application/json"
+	ContentTypeMsgPack = "application/msgpack"
+	ContentTypeCBOR    = "application/cbor"
+
+	// ContentTypeProtobuf is offered for listings only: a listing.Listing for a single listing,
+	// a listing.ListingList for a page of them
+	ContentTypeProtobuf = "application/x-protobuf"
+)
+
+// formats are the values of the format query parameter, which overrides the Accept header for
+// clients that cannot set it
+var formats = map[string]string{
+	"json":    ContentTypeJSON,
+	"msgpack": ContentTypeMsgPack,
+	"cbor":    ContentTypeCBOR,
+	"proto":   ContentTypeProtobuf,
+}
+
+// errNotProtobuf is returned when a response has no protobuf message
+var errNotProtobuf = errors.New("response has no protobuf encoding")
+
+// msgpackAliases are the other media types clients send for MessagePack
+var msgpackAliases = map[string]bool{
+	"application/msgpack":     true,
+	"application/x-msgpack":   true,
+	"application/vnd.msgpack": true,
+}
+
+// cborEncMode writes times as tagged epoch numbers, which keeps sub-second precision without RFC 3339 strings
+var cborEncMode, _ = cbor.EncOptions{
+	Time:    cbor.TimeUnixDynamic,
+	TimeTag: cbor.EncTagRequired,
+}.EncMode()
+
+// negotiate picks the response media type from the Accept header, in the client's order of preference.
+// Anything unrecognized falls back to JSON.
+func negotiate(accept string) string {
+	best, bestQ := ContentTypeJSON, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		var candidate string
+		switch {
+		case msgpackAliases[mediaType]:
+			candidate = ContentTypeMsgPack
+		case mediaType == ContentTypeCBOR:
+			candidate = ContentTypeCBOR
+		case mediaType == ContentTypeProtobuf, mediaType == "application/protobuf":
+			candidate = ContentTypeProtobuf
+		case mediaType == ContentTypeJSON, mediaType == "*/*", mediaType == "application/*":
+			candidate = ContentTypeJSON
+		default:
+			continue
+		}
+
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if q > bestQ {
+			best, bestQ = candidate, q
+		}
+	}
+	return best
+}
+
+// encode serializes v in the given media type. MessagePack and CBOR use the JSON field names;
+// MessagePack also leaves out empty fields. Protobuf only encodes listings and fails with
+// errNotProtobuf for anything else.
+func encode(contentType string, v interface{}) ([]byte, error) {
+	switch contentType {
+	case ContentTypeProtobuf:
+		message, ok := protoMessage(v)
+		if !ok {
+			return nil, errNotProtobuf
+		}
+		return proto.Marshal(message)
+	case ContentTypeMsgPack:
+		var buf bytes.Buffer
+		encoder := msgpack.NewEncoder(&buf)
+		encoder.SetCustomStructTag("json")
+		encoder.SetOmitEmpty(true)
+		if err := encoder.Encode(v); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case ContentTypeCBOR:
+		return cborEncMode.Marshal(v)
+	default:
+		return json.Marshal(v)
+	}
+}
+
+// protoMessage returns the protobuf message of a listing or a page of listings
+func protoMessage(v interface{}) (proto.Message, bool) {
+	switch value := v.(type) {
+	case *clickhouse.FlattenedListing:
+		return clickhouse.Unflatten(value), true
+	case []*clickhouse.FlattenedListing:
+		page := &listing.ListingList{Listings: make([]*listing.Listing, len(value))}
+		for i, flattened := range value {
+			page.Listings[i] = clickhouse.Unflatten(flattened)
+		}
+		return page, true
+	default:
+		return nil, false
+	}
+}
+
+// writeNegotiated writes v in the encoding requested by the format query parameter, else by the
+// Accept header
+func writeNegotiated(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	contentType := negotiate(r.Header.Get("Accept"))
+	if format := r.URL.Query().Get("format"); format != "" {
+		var ok bool
+		if contentType, ok = formats[format]; !ok {
+			writeError(w, http.StatusBadRequest, "unknown format "+strconv.Quote(format))
+			return
+		}
+	}
+
+	body, err := encode(contentType, v)
+	if errors.Is(err, errNotProtobuf) {
+		writeError(w, http.StatusNotAcceptable, "protobuf is only available for listings")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to encode response: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(status)
+	w.Write(body)
+}
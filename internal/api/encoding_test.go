@@ -0,0 +1,159 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	listing "github.com/gregor-tokarev/hoe_parser/proto"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept   string
+		expected string
+	}{
+		{"", ContentTypeJSON},
+		{"*/*", ContentTypeJSON},
+		{"text/html", ContentTypeJSON},
+		{"application/msgpack", ContentTypeMsgPack},
+		{"application/x-msgpack", ContentTypeMsgPack},
+		{"application/cbor", ContentTypeCBOR},
+		{"application/x-protobuf", ContentTypeProtobuf},
+		{"application/json;q=0.5, application/cbor", ContentTypeCBOR},
+		{"application/msgpack;q=0.2, application/json;q=0.9", ContentTypeJSON},
+	}
+
+	for _, tt := range tests {
+		if got := negotiate(tt.accept); got != tt.expected {
+			t.Errorf("Accept %q: expected %s, got %s", tt.accept, tt.expected, got)
+		}
+	}
+}
+
+func TestWriteNegotiatedMsgPackUsesJSONNames(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/listings", nil)
+	req.Header.Set("Accept", "application/msgpack")
+	recorder := httptest.NewRecorder()
+
+	writeNegotiated(recorder, req, http.StatusOK, sampleListings(1))
+
+	if contentType := recorder.Header().Get("Content-Type"); contentType != ContentTypeMsgPack {
+		t.Fatalf("Expected %s, got %s", ContentTypeMsgPack, contentType)
+	}
+
+	var decoded []map[string]interface{}
+	if err := msgpack.Unmarshal(recorder.Body.Bytes(), &decoded); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(decoded) != 1 || decoded[0]["id"] != "intimcity.gold:0" {
+		t.Errorf("Expected the listing keyed by its json name, got %v", decoded)
+	}
+}
+
+func TestWriteNegotiatedProtobuf(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/listings?format=proto", nil)
+	recorder := httptest.NewRecorder()
+
+	writeNegotiated(recorder, req, http.StatusOK, sampleListings(2))
+
+	if contentType := recorder.Header().Get("Content-Type"); contentType != ContentTypeProtobuf {
+		t.Fatalf("Expected %s, got %s", ContentTypeProtobuf, contentType)
+	}
+	var page listing.ListingList
+	if err := proto.Unmarshal(recorder.Body.Bytes(), &page); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(page.Listings) != 2 || page.Listings[1].Id != "intimcity.gold:1" || page.Listings[1].PersonalInfo.Age != 25 {
+		t.Errorf("Expected both listings with their fields, got %v", page.Listings)
+	}
+
+	// Responses without a protobuf message are refused, unknown formats rejected
+	recorder = httptest.NewRecorder()
+	writeNegotiated(recorder, req, http.StatusOK, duplicateGroup{ListingID: "intimcity.gold:1"})
+	if recorder.Code != http.StatusNotAcceptable {
+		t.Errorf("Expected status %d, got %d", http.StatusNotAcceptable, recorder.Code)
+	}
+	recorder = httptest.NewRecorder()
+	writeNegotiated(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/listings?format=xml", nil), http.StatusOK, sampleListings(1))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, recorder.Code)
+	}
+}
+
+func TestCompactEncodingsAreSmaller(t *testing.T) {
+	listings := sampleListings(100)
+
+	jsonBody, err := encode(ContentTypeJSON, listings)
+	if err != nil {
+		t.Fatalf("Failed to encode JSON: %v", err)
+	}
+	for _, contentType := range []string{ContentTypeMsgPack, ContentTypeCBOR} {
+		body, err := encode(contentType, listings)
+		if err != nil {
+			t.Fatalf("Failed to encode %s: %v", contentType, err)
+		}
+		if len(body) >= len(jsonBody) {
+			t.Errorf("Expected %s (%d bytes) to be smaller than JSON (%d bytes)", contentType, len(body), len(jsonBody))
+		}
+	}
+}
+
+// BenchmarkEncodeListings compares encoding time and payload size of a 1000 listing page, e.g.
+//
+//	go test ./internal/api -run '^$' -bench EncodeListings -benchmem
+func BenchmarkEncodeListings(b *testing.B) {
+	listings := sampleListings(1000)
+
+	for _, contentType := range []string{ContentTypeJSON, ContentTypeMsgPack, ContentTypeCBOR} {
+		b.Run(contentType, func(b *testing.B) {
+			var size int
+			for i := 0; i < b.N; i++ {
+				body, err := encode(contentType, listings)
+				if err != nil {
+					b.Fatal(err)
+				}
+				size = len(body)
+			}
+			b.ReportMetric(float64(size), "payload-bytes")
+		})
+	}
+}
+
+// sampleListings returns n listings populated like typical scraped rows
+func sampleListings(n int) []*clickhouse.FlattenedListing {
+	scraped := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	listings := make([]*clickhouse.FlattenedListing, n)
+	for i := range listings {
+		listings[i] = &clickhouse.FlattenedListing{
+			ID:                    fmt.Sprintf("intimcity.gold:%d", i),
+			SourceSite:            "intimcity.gold",
+			SourceID:              fmt.Sprint(i),
+			CreatedAt:             scraped,
+			UpdatedAt:             scraped,
+			LastScraped:           scraped,
+			SourceURL:             fmt.Sprintf("https://intimcity.gold/anketa%d.htm", i),
+			PersonalName:          "Анна",
+			PersonalAge:           25,
+			PersonalHeight:        168,
+			PersonalWeight:        55,
+			PersonalBreastSize:    3,
+			ContactPhone:          "+79991234567",
+			PricingCurrency:       "RUB",
+			PriceHour:             8000,
+			Price2Hours:           15000,
+			PriceNight:            40000,
+			ServiceAvailable:      []string{"massage", "classic"},
+			LocationMetroStations: []string{"Арбатская", "Смоленская"},
+			LocationCity:          "Москва",
+			Photos:                []string{fmt.Sprintf("https://intimcity.gold/photos/%d/1.jpg", i)},
+			Description:           "Описание анкеты",
+		}
+	}
+	return listings
+}
@@ -0,0 +1,247 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+	"github.com/gregor-tokarev/hoe_parser/internal/metrics"
+	"golang.org/x/time/rate"
+)
+
+// MiddlewareOptions configure the middleware every API request passes through before
+// authentication: access logs, panic recovery, CORS and per-client rate limiting
+type MiddlewareOptions struct {
+	AccessLog   bool     // log every request with its status and latency
+	CORSOrigins []string // origins browsers may call the API from, "*" for any; empty disables CORS
+	RateLimit   float64  // requests per second per client IP, 0 disables rate limiting
+	RateBurst   int      // requests a client may send at once before the rate applies
+	TrustProxy  bool     // take the client IP from the last X-Forwarded-For address, when behind a reverse proxy
+}
+
+// MiddlewareOptionsFromConfig returns the middleware options of the main application config
+func MiddlewareOptionsFromConfig(cfg *config.Config) MiddlewareOptions {
+	return MiddlewareOptions{
+		AccessLog:   cfg.APIMiddleware.AccessLog,
+		CORSOrigins: cfg.APIMiddleware.CORSOrigins,
+		RateLimit:   cfg.APIMiddleware.RateLimit,
+		RateBurst:   cfg.APIMiddleware.RateBurst,
+		TrustProxy:  cfg.APIMiddleware.TrustProxy,
+	}
+}
+
+// clientIdleAfter is how long a client's rate limiter is kept after its last request
+const clientIdleAfter = 10 * time.Minute
+
+// withMiddleware wraps handler in the middleware stack of options. Recovery is outermost, so a
+// panic anywhere, the other middleware included, is answered with 500.
+func withMiddleware(handler http.Handler, options MiddlewareOptions) http.Handler {
+	if options.RateLimit > 0 {
+		handler = newRateLimiter(options).wrap(handler)
+	}
+	if len(options.CORSOrigins) > 0 {
+		handler = cors(handler, options.CORSOrigins)
+	}
+	if options.AccessLog {
+		handler = accessLog(handler, options.TrustProxy)
+	}
+	return recoverPanics(handler)
+}
+
+// statusRecorder remembers the status and size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+// WriteHeader records the status
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the size, and the implicit 200 of a body written without a status
+func (r *statusRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(data)
+	r.bytes += n
+	return n, err
+}
+
+// Unwrap returns the wrapped writer, so http.ResponseController reaches its Flush and deadlines
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// recoverPanics answers a request whose handler panicked with a 500 JSON error, unless the
+// response was already started, and logs the panic with its stack
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			log.ErrorContext(r.Context(), "API handler panicked", "method", r.Method, "path", r.URL.Path,
+				"panic", fmt.Sprint(recovered), "stack", string(debug.Stack()))
+			metrics.APIPanics.Inc()
+			if recorder.status == 0 {
+				writeError(recorder, http.StatusInternalServerError, "internal server error")
+			}
+		}()
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+// accessLog logs every request once it is served, with its status, size and latency
+func accessLog(next http.Handler, trustProxy bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			status := recorder.status
+			if status == 0 {
+				// A panic leaves the status to the recovery middleware around this one
+				status = http.StatusInternalServerError
+			}
+			log.InfoContext(r.Context(), "API request", "method", r.Method, "path", r.URL.Path,
+				"status", status, "bytes", recorder.bytes, "duration", time.Since(started),
+				"client", clientIP(r, trustProxy), "user_agent", r.UserAgent())
+		}()
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+// cors lets browsers on the allowed origins call the API. Preflight requests are answered here,
+// since they carry no API key.
+func cors(next http.Handler, origins []string) http.Handler {
+	anyOrigin := slices.Contains(origins, "*")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || (!anyOrigin && !slices.Contains(origins, origin)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		header := w.Header()
+		header.Set("Access-Control-Allow-Origin", origin)
+		header.Add("Vary", "Origin")
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			header.Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+			header.Set("Access-Control-Allow-Headers", "Authorization, Content-Type, X-API-Key")
+			header.Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimiter gives every client IP its own token bucket
+type rateLimiter struct {
+	limit      rate.Limit
+	burst      int
+	trustProxy bool
+
+	mutex   sync.Mutex
+	clients map[string]*clientLimiter
+	now     func() time.Time
+}
+
+// clientLimiter is the token bucket of one client
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// newRateLimiter creates the rate limiter of options; the burst is at least one request
+func newRateLimiter(options MiddlewareOptions) *rateLimiter {
+	return &rateLimiter{
+		limit:      rate.Limit(options.RateLimit),
+		burst:      max(options.RateBurst, 1),
+		trustProxy: options.TrustProxy,
+		clients:    make(map[string]*clientLimiter),
+		now:        time.Now,
+	}
+}
+
+// allow takes a token from the bucket of client and, when there is none, returns how long until
+// the next one
+func (l *rateLimiter) allow(client string) (bool, time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	entry, exists := l.clients[client]
+	if !exists {
+		entry = &clientLimiter{limiter: rate.NewLimiter(l.limit, l.burst), lastSeen: now}
+		l.clients[client] = entry
+		if len(l.clients)%1000 == 0 {
+			for key, other := range l.clients {
+				if now.Sub(other.lastSeen) > clientIdleAfter {
+					delete(l.clients, key)
+				}
+			}
+		}
+	}
+	entry.lastSeen = now
+
+	reservation := entry.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// wrap answers clients over their rate with 429 and a Retry-After header
+func (l *rateLimiter) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ok, retryAfter := l.allow(clientIP(r, l.trustProxy)); !ok {
+			metrics.APIRateLimited.Inc()
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP returns the IP a request came from: the last address of X-Forwarded-For when the
+// proxy in front of the API is trusted, otherwise the remote address. The proxy appends the address
+// it saw to the header, so the last one is the only one a client cannot forge.
+func clientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if values := r.Header.Values("X-Forwarded-For"); len(values) > 0 {
+			forwarded := values[len(values)-1]
+			if i := strings.LastIndex(forwarded, ","); i >= 0 {
+				forwarded = forwarded[i+1:]
+			}
+			if last := strings.TrimSpace(forwarded); last != "" {
+				return last
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
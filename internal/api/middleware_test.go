@@ -0,0 +1,101 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecoverPanicsAnswers500(t *testing.T) {
+	handler := withMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}), MiddlewareOptions{AccessLog: true})
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/stats", nil))
+
+	if recorder.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected %d, got %d", http.StatusInternalServerError, recorder.Code)
+	}
+	var body map[string]string
+	if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil || body["error"] == "" {
+		t.Errorf("Expected a JSON error body, got %q (%v)", recorder.Body.String(), err)
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	handler := withMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusUnauthorized, "invalid or missing api key")
+	}), MiddlewareOptions{CORSOrigins: []string{"https://dashboard.example"}})
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/listings", nil)
+	req.Header.Set("Origin", "https://dashboard.example")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusNoContent {
+		t.Errorf("Expected preflight to be answered with %d before authentication, got %d", http.StatusNoContent, recorder.Code)
+	}
+	if origin := recorder.Header().Get("Access-Control-Allow-Origin"); origin != "https://dashboard.example" {
+		t.Errorf("Expected the origin to be allowed, got %q", origin)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/listings", nil)
+	req.Header.Set("Origin", "https://other.example")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	if origin := recorder.Header().Get("Access-Control-Allow-Origin"); origin != "" {
+		t.Errorf("Expected no CORS headers for another origin, got %q", origin)
+	}
+}
+
+func TestRateLimitPerClient(t *testing.T) {
+	handler := withMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), MiddlewareOptions{RateLimit: 0.001, RateBurst: 2})
+
+	serve := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/stats", nil)
+		req.RemoteAddr = remoteAddr
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	for i := 0; i < 2; i++ {
+		if recorder := serve("10.0.0.1:5000"); recorder.Code != http.StatusOK {
+			t.Fatalf("Expected request %d within the burst to pass, got %d", i+1, recorder.Code)
+		}
+	}
+	recorder := serve("10.0.0.1:5001")
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected %d over the burst, got %d", http.StatusTooManyRequests, recorder.Code)
+	}
+	if recorder.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected a Retry-After header")
+	}
+	if recorder := serve("10.0.0.2:5000"); recorder.Code != http.StatusOK {
+		t.Errorf("Expected another client to have its own limit, got %d", recorder.Code)
+	}
+}
+
+func TestClientIPTakesTheLastForwardedAddress(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/stats", nil)
+	req.RemoteAddr = "10.0.0.1:5000"
+	req.Header.Add("X-Forwarded-For", "1.2.3.4, 5.6.7.8")
+	req.Header.Add("X-Forwarded-For", "9.9.9.9, 203.0.113.7")
+
+	if ip := clientIP(req, true); ip != "203.0.113.7" {
+		t.Errorf("Expected the address the proxy appended, got %q", ip)
+	}
+	if ip := clientIP(req, false); ip != "10.0.0.1" {
+		t.Errorf("Expected the remote address without a trusted proxy, got %q", ip)
+	}
+
+	req.Header.Set("X-Forwarded-For", "1.2.3.4, ")
+	if ip := clientIP(req, true); ip != "10.0.0.1" {
+		t.Errorf("Expected the remote address for an empty last hop, got %q", ip)
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/scheduler"
+)
+
+// ScheduleController lists, pauses and resumes the scheduled crawls
+type ScheduleController interface {
+	Jobs() []scheduler.JobStatus
+	Pause(name string) error
+	Resume(name string) error
+}
+
+// SetScheduler serves the crawl schedules of controller under /api/v1/schedules for admin keys
+func (s *Server) SetScheduler(controller ScheduleController) {
+	s.schedules = controller
+}
+
+// registerSchedules adds the schedule routes to mux
+func (s *Server) registerSchedules(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/schedules", RequireAdmin(s.handleListSchedules))
+	mux.HandleFunc("POST /api/v1/schedules/{name}/pause", RequireAdmin(s.handlePauseSchedule))
+	mux.HandleFunc("POST /api/v1/schedules/{name}/resume", RequireAdmin(s.handleResumeSchedule))
+}
+
+// handleListSchedules serves the state of every scheduled crawl
+func (s *Server) handleListSchedules(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.localize(s.schedules.Jobs()))
+}
+
+// handlePauseSchedule pauses a scheduled crawl, stopping its current run
+func (s *Server) handlePauseSchedule(w http.ResponseWriter, r *http.Request) {
+	s.setSchedulePaused(w, r, s.schedules.Pause)
+}
+
+// handleResumeSchedule resumes a paused crawl
+func (s *Server) handleResumeSchedule(w http.ResponseWriter, r *http.Request) {
+	s.setSchedulePaused(w, r, s.schedules.Resume)
+}
+
+// setSchedulePaused applies pause or resume to the job named in the path and serves its new state
+func (s *Server) setSchedulePaused(w http.ResponseWriter, r *http.Request, apply func(name string) error) {
+	name := r.PathValue("name")
+	if err := apply(name); err != nil {
+		if errors.Is(err, scheduler.ErrJobNotFound) {
+			writeError(w, http.StatusNotFound, "schedule not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	log.Info("Schedule changed through the API", "job", name, "key", KeyFromContext(r.Context()).Name)
+
+	for _, job := range s.schedules.Jobs() {
+		if job.Name == name {
+			writeJSON(w, http.StatusOK, s.localize(job))
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/scheduler"
+)
+
+type fakeSchedules struct {
+	jobs []scheduler.JobStatus
+}
+
+func (f *fakeSchedules) Jobs() []scheduler.JobStatus { return f.jobs }
+func (f *fakeSchedules) Pause(name string) error     { return f.setPaused(name, true) }
+func (f *fakeSchedules) Resume(name string) error    { return f.setPaused(name, false) }
+
+func (f *fakeSchedules) setPaused(name string, paused bool) error {
+	for i := range f.jobs {
+		if f.jobs[i].Name == name {
+			f.jobs[i].Paused = paused
+			return nil
+		}
+	}
+	return scheduler.ErrJobNotFound
+}
+
+func TestScheduleRoutes(t *testing.T) {
+	store, err := NewKeyStore(&APIKey{Key: "secret", Name: "ops", Admin: true}, &APIKey{Key: "reader", Name: "reader"})
+	if err != nil {
+		t.Fatalf("Failed to create key store: %v", err)
+	}
+	server := NewServer(nil, store)
+	server.SetScheduler(&fakeSchedules{jobs: []scheduler.JobStatus{{Name: "intimcity.gold:index", Schedule: "10m"}}})
+	handler := server.Handler()
+
+	serve := func(method, path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-API-Key", key)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	if recorder := serve(http.MethodGet, "/api/v1/schedules", "reader"); recorder.Code != http.StatusForbidden {
+		t.Errorf("Expected %d for a non-admin key, got %d", http.StatusForbidden, recorder.Code)
+	}
+
+	recorder := serve(http.MethodPost, "/api/v1/schedules/intimcity.gold:index/pause", "secret")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+	}
+	var status scheduler.JobStatus
+	if err := json.NewDecoder(recorder.Body).Decode(&status); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !status.Paused {
+		t.Errorf("Expected the job to be paused")
+	}
+
+	if recorder := serve(http.MethodPost, "/api/v1/schedules/other:index/resume", "secret"); recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected %d for an unknown job, got %d", http.StatusNotFound, recorder.Code)
+	}
+}
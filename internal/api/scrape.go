@@ -0,0 +1,247 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/scraper"
+	"github.com/gregor-tokarev/hoe_parser/internal/service"
+	"github.com/redis/go-redis/v9"
+)
+
+// scrapePath scrapes a listing page on demand
+const scrapePath = "/api/v1/scrape"
+
+// scrapeTimeout bounds an on-demand scrape, retries and fallbacks included
+const scrapeTimeout = 2 * time.Minute
+
+// Values of the X-Cache header of scrape responses
+const (
+	cacheHit    = "HIT"    // served from the cache
+	cacheMiss   = "MISS"   // scraped and cached
+	cacheBypass = "BYPASS" // scraped on request of the client, the cached entry replaced
+)
+
+// ScrapeCache keeps the listings scraped through POST /api/v1/scrape by canonical listing URL
+type ScrapeCache interface {
+	// Get returns the cached listing of url, nil when there is none
+	Get(ctx context.Context, url string) (*clickhouse.FlattenedListing, error)
+	// Set caches the listing of url
+	Set(ctx context.Context, url string, listing *clickhouse.FlattenedListing) error
+	// Delete drops the cached listing of url and reports whether there was one
+	Delete(ctx context.Context, url string) (bool, error)
+}
+
+// scrapeRequest is the body of POST /api/v1/scrape
+type scrapeRequest struct {
+	URL string `json:"url"`
+}
+
+// SetScraping serves POST /api/v1/scrape, which scrapes a listing page with the adapter of its
+// site. With a cache, repeated scrapes of a listing are answered from it until its entries expire,
+// and admin keys can drop an entry with DELETE /api/v1/scrape/cache. Only the parser enables it:
+// the analytics API never reaches the source sites.
+func (s *Server) SetScraping(cache ScrapeCache) {
+	s.scraping = true
+	s.scrapeCache = cache
+}
+
+// registerScrape adds the scrape routes to mux
+func (s *Server) registerScrape(mux *http.ServeMux) {
+	mux.HandleFunc("POST "+scrapePath, s.handleScrape)
+	if s.scrapeCache != nil && !s.readOnly {
+		mux.HandleFunc("DELETE "+scrapePath+"/cache", RequireAdmin(s.handleInvalidateScrape))
+	}
+}
+
+// handleScrape scrapes a listing page and serves it like GET /api/v1/listings/{id}, without
+// storing it
+func (s *Server) handleScrape(w http.ResponseWriter, r *http.Request) {
+	var request scrapeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+	pageURL, siteAdapter, err := scrapeTarget(request.URL)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// A key scoped to other sites cannot make the parser fetch pages of this one
+	scope := KeyFromContext(r.Context()).Scope()
+	if len(scope.Sites) > 0 && !slices.Contains(scope.Sites, clickhouse.SourceSiteFromURL(pageURL)) {
+		writeError(w, http.StatusForbidden, "site outside the scope of the api key")
+		return
+	}
+
+	cacheStatus := cacheMiss
+	if s.scrapeCache != nil {
+		if skipScrapeCache(r) {
+			cacheStatus = cacheBypass
+		} else if cached, err := s.scrapeCache.Get(r.Context(), pageURL); err != nil {
+			log.WarnContext(r.Context(), "Failed to read the scrape cache", "url", pageURL, "error", err)
+		} else if cached != nil {
+			s.writeScraped(w, r, cached, cacheHit)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), scrapeTimeout)
+	defer cancel()
+	scraped, err := siteAdapter.ScrapeListing(ctx, pageURL)
+	if err != nil {
+		writeScrapeError(w, err)
+		return
+	}
+
+	flattened := clickhouse.Flatten(scraped, pageURL)
+	if s.scrapeCache == nil {
+		s.writeScraped(w, r, flattened, "")
+		return
+	}
+	if err := s.scrapeCache.Set(r.Context(), pageURL, flattened); err != nil {
+		log.WarnContext(r.Context(), "Failed to cache scraped listing", "url", pageURL, "error", err)
+	}
+	s.writeScraped(w, r, flattened, cacheStatus)
+}
+
+// writeScraped serves a scraped listing within the scope of the key; cacheStatus is set as X-Cache
+// when not empty. Excluded listings and listings outside the scope are not found, as for reads.
+func (s *Server) writeScraped(w http.ResponseWriter, r *http.Request, listing *clickhouse.FlattenedListing, cacheStatus string) {
+	scope := KeyFromContext(r.Context()).Scope()
+	if !scope.Allows(listing) || (s.adapter != nil && s.adapter.IsExcluded(listing.ID)) {
+		writeStoreError(w, clickhouse.ErrListingNotFound)
+		return
+	}
+
+	// The cached entry stays complete; only the response is redacted
+	redacted := *listing
+	scope.Apply(&redacted)
+	if cacheStatus != "" {
+		w.Header().Set("X-Cache", cacheStatus)
+	}
+	writeNegotiated(w, r, http.StatusOK, s.localize(&redacted))
+}
+
+// handleInvalidateScrape drops the cached listing of the url query parameter
+func (s *Server) handleInvalidateScrape(w http.ResponseWriter, r *http.Request) {
+	pageURL, _, err := scrapeTarget(r.URL.Query().Get("url"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	deleted, err := s.scrapeCache.Delete(r.Context(), pageURL)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !deleted {
+		writeError(w, http.StatusNotFound, "url not cached")
+		return
+	}
+	log.Info("Scrape cache entry dropped through the API", "url", pageURL, "key", KeyFromContext(r.Context()).Name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// scrapeTarget returns the canonical form of a listing URL, under which it is cached, and the
+// adapter of its site
+func scrapeTarget(rawURL string) (string, scraper.SiteAdapter, error) {
+	if strings.TrimSpace(rawURL) == "" {
+		return "", nil, fmt.Errorf("url is required")
+	}
+	pageURL := scraper.CanonicalListingURL(rawURL)
+	siteAdapter, err := scraper.AdapterForURL(pageURL)
+	if err != nil {
+		return "", nil, fmt.Errorf("no site adapter for url")
+	}
+	return pageURL, siteAdapter, nil
+}
+
+// skipScrapeCache reports whether the client asked for a fresh scrape, with ?cache=false or
+// Cache-Control: no-cache
+func skipScrapeCache(r *http.Request) bool {
+	if r.URL.Query().Get("cache") == "false" {
+		return true
+	}
+	for _, directive := range strings.Split(r.Header.Get("Cache-Control"), ",") {
+		if strings.EqualFold(strings.TrimSpace(directive), "no-cache") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeScrapeError maps scrape errors to HTTP statuses: pages taken down are 404, pages the site
+// refused are 503 and other failures of the site are 502
+func writeScrapeError(w http.ResponseWriter, err error) {
+	switch {
+	case service.IsGone(err):
+		writeError(w, http.StatusNotFound, "listing removed from site")
+	case service.IsBlocked(err):
+		writeError(w, http.StatusServiceUnavailable, "source site refused the scrape")
+	case errors.Is(err, context.DeadlineExceeded):
+		writeError(w, http.StatusGatewayTimeout, "scrape timed out")
+	default:
+		writeError(w, http.StatusBadGateway, fmt.Sprintf("failed to scrape listing: %v", err))
+	}
+}
+
+// RedisScrapeCache keeps scraped listings as JSON under a key per canonical listing URL, expiring
+// after a TTL
+type RedisScrapeCache struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+// NewRedisScrapeCache creates a cache on an existing Redis client
+func NewRedisScrapeCache(client *redis.Client, prefix string, ttl time.Duration) *RedisScrapeCache {
+	return &RedisScrapeCache{client: client, prefix: prefix, ttl: ttl}
+}
+
+// Get implements ScrapeCache
+func (c *RedisScrapeCache) Get(ctx context.Context, url string) (*clickhouse.FlattenedListing, error) {
+	data, err := c.client.Get(ctx, c.prefix+url).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached listing: %w", err)
+	}
+
+	var listing clickhouse.FlattenedListing
+	if err := json.Unmarshal(data, &listing); err != nil {
+		return nil, fmt.Errorf("failed to parse cached listing: %w", err)
+	}
+	return &listing, nil
+}
+
+// Set implements ScrapeCache
+func (c *RedisScrapeCache) Set(ctx context.Context, url string, listing *clickhouse.FlattenedListing) error {
+	data, err := json.Marshal(listing)
+	if err != nil {
+		return fmt.Errorf("failed to encode listing: %w", err)
+	}
+	if err := c.client.Set(ctx, c.prefix+url, data, c.ttl).Err(); err != nil {
+		return fmt.Errorf("failed to cache listing: %w", err)
+	}
+	return nil
+}
+
+// Delete implements ScrapeCache
+func (c *RedisScrapeCache) Delete(ctx context.Context, url string) (bool, error) {
+	deleted, err := c.client.Del(ctx, c.prefix+url).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to drop cached listing: %w", err)
+	}
+	return deleted > 0, nil
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/scraper"
+	listing "github.com/gregor-tokarev/hoe_parser/proto"
+)
+
+// countingAdapter is a site adapter for scrape.test counting its scrapes
+type countingAdapter struct {
+	scrapes int
+}
+
+func (a *countingAdapter) Name() string { return "scrape.test" }
+
+func (a *countingAdapter) MatchesURL(rawURL string) bool {
+	return strings.HasPrefix(rawURL, "https://scrape.test/")
+}
+
+func (a *countingAdapter) ScrapeListing(ctx context.Context, rawURL string) (*listing.Listing, error) {
+	a.scrapes++
+	return &listing.Listing{Id: "1", ContactInfo: &listing.ContactInfo{Phone: "+79991234567"}}, nil
+}
+
+func (a *countingAdapter) ScrapeIndex(ctx context.Context, page int) ([]scraper.ListingLink, error) {
+	return nil, nil
+}
+
+// memoryScrapeCache is a ScrapeCache in a map
+type memoryScrapeCache map[string]*clickhouse.FlattenedListing
+
+func (c memoryScrapeCache) Get(ctx context.Context, url string) (*clickhouse.FlattenedListing, error) {
+	return c[url], nil
+}
+
+func (c memoryScrapeCache) Set(ctx context.Context, url string, listing *clickhouse.FlattenedListing) error {
+	c[url] = listing
+	return nil
+}
+
+func (c memoryScrapeCache) Delete(ctx context.Context, url string) (bool, error) {
+	_, cached := c[url]
+	delete(c, url)
+	return cached, nil
+}
+
+// scrapeAdapter is registered once, the default registry has no way to remove adapters
+var scrapeAdapter = &countingAdapter{}
+
+func init() {
+	scraper.Register(scrapeAdapter)
+}
+
+func TestScrapeCache(t *testing.T) {
+	store, err := NewKeyStore(&APIKey{Key: "secret", Name: "ops", Admin: true}, &APIKey{Key: "reader", Name: "reader"},
+		&APIKey{Key: "other", Name: "other", Sites: []string{"intimcity.gold"}},
+		&APIKey{Key: "nocontact", Name: "nocontact", HiddenFields: []string{clickhouse.FieldGroupContact}})
+	if err != nil {
+		t.Fatalf("Failed to create key store: %v", err)
+	}
+	cache := memoryScrapeCache{}
+	server := NewServer(nil, store)
+	server.SetScraping(cache)
+	handler := server.Handler()
+
+	serve := func(method, path, key, body string, headers ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", key)
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+	scrape := `{"url": "https://scrape.test/anketa1.htm"}`
+	scrapes := scrapeAdapter.scrapes
+
+	recorder := serve(http.MethodPost, "/api/v1/scrape", "reader", scrape)
+	if recorder.Code != http.StatusOK || recorder.Header().Get("X-Cache") != cacheMiss {
+		t.Fatalf("Expected a scraped listing, got %d %q: %s", recorder.Code, recorder.Header().Get("X-Cache"), recorder.Body.String())
+	}
+	var scraped clickhouse.FlattenedListing
+	if err := json.NewDecoder(recorder.Body).Decode(&scraped); err != nil || scraped.ID != "scrape.test:1" {
+		t.Errorf("Expected listing scrape.test:1, got %+v (%v)", scraped, err)
+	}
+
+	if recorder := serve(http.MethodPost, "/api/v1/scrape", "reader", scrape); recorder.Header().Get("X-Cache") != cacheHit {
+		t.Errorf("Expected the second scrape from the cache, got %q", recorder.Header().Get("X-Cache"))
+	}
+	if recorder := serve(http.MethodPost, "/api/v1/scrape?cache=false", "reader", scrape); recorder.Header().Get("X-Cache") != cacheBypass {
+		t.Errorf("Expected ?cache=false to bypass the cache, got %q", recorder.Header().Get("X-Cache"))
+	}
+	if recorder := serve(http.MethodPost, "/api/v1/scrape", "reader", scrape, "Cache-Control", "no-cache"); recorder.Header().Get("X-Cache") != cacheBypass {
+		t.Errorf("Expected Cache-Control: no-cache to bypass the cache, got %q", recorder.Header().Get("X-Cache"))
+	}
+	if got := scrapeAdapter.scrapes - scrapes; got != 3 {
+		t.Errorf("Expected 3 scrapes, got %d", got)
+	}
+
+	// Hidden fields are blanked in the response, not in the cache
+	recorder = serve(http.MethodPost, "/api/v1/scrape", "nocontact", scrape)
+	if err := json.NewDecoder(recorder.Body).Decode(&scraped); err != nil || scraped.ContactPhone != "" {
+		t.Errorf("Expected the phone hidden, got %+v (%v)", scraped, err)
+	}
+	if cached := cache["https://scrape.test/anketa1.htm"]; cached == nil || cached.ContactPhone == "" {
+		t.Errorf("Expected the complete listing cached, got %+v", cached)
+	}
+
+	if recorder := serve(http.MethodPost, "/api/v1/scrape", "other", scrape); recorder.Code != http.StatusForbidden {
+		t.Errorf("Expected %d for a key scoped to another site, got %d", http.StatusForbidden, recorder.Code)
+	}
+	if recorder := serve(http.MethodPost, "/api/v1/scrape", "reader", `{"url": "http://unknown.test/1.htm"}`); recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected %d for a url without adapter, got %d", http.StatusBadRequest, recorder.Code)
+	}
+
+	invalidate := "/api/v1/scrape/cache?url=https://scrape.test/anketa1.htm"
+	if recorder := serve(http.MethodDelete, invalidate, "reader", ""); recorder.Code != http.StatusForbidden {
+		t.Errorf("Expected %d for a non-admin key, got %d", http.StatusForbidden, recorder.Code)
+	}
+	// Entries are keyed by the canonical URL, whatever variant of it is passed
+	if recorder := serve(http.MethodDelete, "/api/v1/scrape/cache?url=http://m.scrape.test/anketa1.htm%23photos", "secret", ""); recorder.Code != http.StatusNoContent {
+		t.Errorf("Expected %d, got %d", http.StatusNoContent, recorder.Code)
+	}
+	if recorder := serve(http.MethodDelete, invalidate, "secret", ""); recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected %d once the entry is gone, got %d", http.StatusNotFound, recorder.Code)
+	}
+}
@@ -0,0 +1,469 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+	"github.com/gregor-tokarev/hoe_parser/internal/logger"
+	"github.com/gregor-tokarev/hoe_parser/pkg/format"
+)
+
+// log is the component logger of the package
+var log = logger.Component("api")
+
+// Server exposes stored listings over HTTP, restricted per API key
+type Server struct {
+	adapter   *clickhouse.Adapter
+	keys      *KeyStore
+	dashboard *DashboardSources  // nil when the HTML dashboard is disabled
+	stream    *streamHub         // nil when the event stream is disabled
+	schedules ScheduleController // nil when the crawl schedules are not managed through the API
+	crawl     CrawlController    // nil when the crawl cannot be paused through the API
+
+	// On-demand scrapes, see scrape.go
+	scraping    bool        // serve POST /api/v1/scrape
+	scrapeCache ScrapeCache // nil when scrapes are not cached
+
+	// Aggregate statistics, see aggregates.go
+	aggregateOnly bool // serve nothing but the aggregate statistics
+	readOnly      bool // leave out the admin endpoints changing exclusions and schedules
+	minBucket     int  // k-anonymity threshold of the aggregate statistics
+
+	location *time.Location // time zone of the times in responses, see timezone.go
+	locale   format.Locale  // number format of the dashboard pages
+
+	middleware MiddlewareOptions // see middleware.go
+}
+
+// maxQueryLimit caps the page size of listing queries
+const maxQueryLimit = 5000
+
+// NewServer creates an API server
+func NewServer(adapter *clickhouse.Adapter, keys *KeyStore) *Server {
+	return &Server{
+		adapter:   adapter,
+		keys:      keys,
+		minBucket: DefaultMinBucket,
+		location:  time.UTC,
+		locale:    format.English,
+	}
+}
+
+// SetMiddleware sets the access log, CORS and rate limit middleware of the server; panics are
+// always recovered
+func (s *Server) SetMiddleware(options MiddlewareOptions) {
+	s.middleware = options
+}
+
+// Handler returns the HTTP handler with all routes registered, without the admin routes in read
+// mode, or only the aggregate statistics and analytics in aggregate mode, behind the middleware
+func (s *Server) Handler() http.Handler {
+	return withMiddleware(s.routes(), s.middleware)
+}
+
+// routes returns the authenticated routes of the server
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+aggregatesPath, s.handleAggregates)
+	mux.HandleFunc("GET "+analyticsPath+"{report}", s.handleAnalytics)
+	if s.aggregateOnly {
+		return s.keys.Authenticate(mux)
+	}
+
+	mux.HandleFunc("GET /api/v1/listings", s.handleQueryListings)
+	mux.HandleFunc("GET /api/v1/listings/{id}", s.handleGetListing)
+	mux.HandleFunc("GET /api/v1/listings/{id}/history", s.handleListingHistory)
+	mux.HandleFunc("GET /api/v1/listings/{id}/prices", s.handleListingPrices)
+	mux.HandleFunc("GET /api/v1/listings/{id}/duplicates", s.handleListingDuplicates)
+	mux.HandleFunc("GET /api/v1/stats", s.handleStats)
+	mux.HandleFunc("GET /api/v1/dashboard", s.handleDashboard)
+	mux.HandleFunc("GET /api/v1/changes", s.handleRecentChanges)
+
+	if s.dashboard != nil {
+		s.registerDashboard(mux)
+	}
+	if s.stream != nil {
+		mux.HandleFunc("GET "+streamPath, s.requireUnrestricted(s.handleStream))
+		mux.HandleFunc("GET "+listingStreamPath, s.handleListingStream)
+	}
+	if s.scraping {
+		s.registerScrape(mux)
+	}
+	if s.readOnly {
+		return s.keys.Authenticate(restrictAggregateKeys(mux))
+	}
+
+	mux.HandleFunc("GET /api/v1/exclusions", RequireAdmin(s.handleListExclusions))
+	mux.HandleFunc("POST /api/v1/exclusions", RequireAdmin(s.handleAddExclusion))
+	mux.HandleFunc("DELETE /api/v1/exclusions/{id}", RequireAdmin(s.handleRemoveExclusion))
+
+	if s.schedules != nil {
+		s.registerSchedules(mux)
+	}
+	if s.crawl != nil {
+		s.registerCrawl(mux)
+	}
+
+	return s.keys.Authenticate(restrictAggregateKeys(mux))
+}
+
+// Serve starts the API server and blocks until ctx is cancelled
+func (s *Server) Serve(ctx context.Context, addr string) error {
+	return serve(ctx, addr, s.Handler())
+}
+
+// serve listens on addr with handler until ctx is cancelled
+func serve(ctx context.Context, addr string, handler http.Handler) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("api server failed: %w", err)
+	}
+	return nil
+}
+
+// reader returns the adapter view restricted to the scope of the authenticated key
+func (s *Server) reader(r *http.Request) *clickhouse.ScopedAdapter {
+	return s.adapter.WithScope(KeyFromContext(r.Context()).Scope())
+}
+
+// handleGetListing serves a single listing by composite ID
+func (s *Server) handleGetListing(w http.ResponseWriter, r *http.Request) {
+	listing, err := s.reader(r).GetListingByID(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeNegotiated(w, r, http.StatusOK, s.localize(listing))
+}
+
+// handleListingHistory serves every stored version of a listing and its change log, oldest first
+func (s *Server) handleListingHistory(w http.ResponseWriter, r *http.Request) {
+	history, err := s.reader(r).GetListingHistory(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeNegotiated(w, r, http.StatusOK, s.localize(history))
+}
+
+// handleListingPrices serves the price history of a listing, oldest first
+func (s *Server) handleListingPrices(w http.ResponseWriter, r *http.Request) {
+	points, err := s.reader(r).GetPriceHistory(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeNegotiated(w, r, http.StatusOK, s.localize(points))
+}
+
+// duplicateGroup is a listing and the listings likely reposting its photos
+type duplicateGroup struct {
+	ListingID  string                        `json:"listing_id"`
+	Duplicates []clickhouse.DuplicateListing `json:"duplicates"`
+}
+
+// handleListingDuplicates serves the listings sharing near-identical photos with a listing
+func (s *Server) handleListingDuplicates(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	duplicates, err := s.reader(r).FindDuplicateListings(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeNegotiated(w, r, http.StatusOK, duplicateGroup{ListingID: id, Duplicates: duplicates})
+}
+
+// handleQueryListings serves the latest versions of listings visible to the key, most recently scraped first
+func (s *Server) handleQueryListings(w http.ResponseWriter, r *http.Request) {
+	query, err := parseListingQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	listings, err := s.reader(r).QueryListings(r.Context(), query)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeNegotiated(w, r, http.StatusOK, s.localize(listings))
+}
+
+// parseListingQuery reads the filters, sort and page of a listing query from the query string
+func parseListingQuery(r *http.Request) (clickhouse.ListingQuery, error) {
+	var query clickhouse.ListingQuery
+	values := r.URL.Query()
+
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 || limit > maxQueryLimit {
+			return query, fmt.Errorf("limit must be between 0 and %d", maxQueryLimit)
+		}
+		query.Limit = limit
+	}
+	if value := values.Get("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return query, fmt.Errorf("offset must be a non-negative integer")
+		}
+		query.Offset = offset
+	}
+	if value := values.Get("min_completeness"); value != "" {
+		completeness, err := strconv.ParseFloat(value, 32)
+		if err != nil || completeness < 0 || completeness > 1 {
+			return query, fmt.Errorf("min_completeness must be between 0 and 1")
+		}
+		query.MinCompleteness = float32(completeness)
+	}
+	if value := values.Get("min_quality"); value != "" {
+		quality, err := strconv.ParseFloat(value, 32)
+		if err != nil || quality < 0 || quality > 1 {
+			return query, fmt.Errorf("min_quality must be between 0 and 1")
+		}
+		query.MinQuality = float32(quality)
+	}
+
+	query.City = values.Get("city")
+	query.Metro = values.Get("metro")
+
+	var err error
+	if query.MinPriceHour, err = parseUint32(values, "min_price_hour"); err != nil {
+		return query, err
+	}
+	if query.MaxPriceHour, err = parseUint32(values, "max_price_hour"); err != nil {
+		return query, err
+	}
+	if query.MinPriceHour > 0 && query.MaxPriceHour > 0 && query.MinPriceHour > query.MaxPriceHour {
+		return query, fmt.Errorf("min_price_hour must not exceed max_price_hour")
+	}
+
+	if query.MinAge, err = parseAge(values, "min_age"); err != nil {
+		return query, err
+	}
+	if query.MaxAge, err = parseAge(values, "max_age"); err != nil {
+		return query, err
+	}
+	if query.MinAge > 0 && query.MaxAge > 0 && query.MinAge > query.MaxAge {
+		return query, fmt.Errorf("min_age must not exceed max_age")
+	}
+
+	if value := values.Get("has_photos"); value != "" {
+		hasPhotos, err := strconv.ParseBool(value)
+		if err != nil {
+			return query, fmt.Errorf("has_photos must be true or false")
+		}
+		query.HasPhotos = &hasPhotos
+	}
+
+	if value := values.Get("sort"); value != "" {
+		if !clickhouse.IsListingSort(value) {
+			return query, fmt.Errorf("sort must be one of %s, optionally prefixed with -", strings.Join(clickhouse.ListingSorts, ", "))
+		}
+		query.Sort = value
+	}
+	return query, nil
+}
+
+// parseUint32 reads an optional non-negative integer parameter, 0 when missing
+func parseUint32(values url.Values, name string) (uint32, error) {
+	value := values.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	parsed, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a non-negative integer", name)
+	}
+	return uint32(parsed), nil
+}
+
+// parseAge reads an optional age parameter between 18 and 99, 0 when missing
+func parseAge(values url.Values, name string) (uint8, error) {
+	value := values.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	age, err := strconv.Atoi(value)
+	if err != nil || age < 18 || age > 99 {
+		return 0, fmt.Errorf("%s must be between 18 and 99", name)
+	}
+	return uint8(age), nil
+}
+
+// handleStats serves aggregate statistics over the listings visible to the key
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.reader(r).GetStats(r.Context())
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// handleDashboard serves the latest precomputed dashboard numbers. They cover every site and city,
+// so scoped keys are refused.
+func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if !KeyFromContext(r.Context()).Scope().IsUnrestricted() {
+		writeError(w, http.StatusForbidden, "dashboard requires an unrestricted api key")
+		return
+	}
+
+	stats, err := s.adapter.GetDashboardStats(r.Context())
+	if err != nil {
+		if errors.Is(err, clickhouse.ErrNoDashboardStats) {
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.localize(stats))
+}
+
+// handleRecentChanges serves the change log feed, newest first. Change entries carry raw field
+// values of every site, so scoped keys are refused.
+func (s *Server) handleRecentChanges(w http.ResponseWriter, r *http.Request) {
+	if !KeyFromContext(r.Context()).Scope().IsUnrestricted() {
+		writeError(w, http.StatusForbidden, "change feed requires an unrestricted api key")
+		return
+	}
+
+	since, limit, err := parseChangesQuery(r, clock.Now().In(s.location))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	changes, err := s.adapter.GetRecentChanges(r.Context(), since, limit)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeNegotiated(w, r, http.StatusOK, s.localize(changes))
+}
+
+// parseChangesQuery reads since (RFC 3339 time or duration back from now, default start of
+// today in the time zone of now) and limit (default 100) from the query string
+func parseChangesQuery(r *http.Request, now time.Time) (time.Time, int, error) {
+	values := r.URL.Query()
+
+	since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if value := values.Get("since"); value != "" {
+		if ago, err := time.ParseDuration(value); err == nil && ago > 0 {
+			since = now.Add(-ago)
+		} else if at, err := time.Parse(time.RFC3339, value); err == nil {
+			since = at
+		} else {
+			return since, 0, fmt.Errorf("since must be an RFC 3339 time or a positive duration")
+		}
+	}
+
+	limit := 100
+	if value := values.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > maxQueryLimit {
+			return since, 0, fmt.Errorf("limit must be between 1 and %d", maxQueryLimit)
+		}
+		limit = parsed
+	}
+	return since, limit, nil
+}
+
+// handleListExclusions serves the active exclusion list
+func (s *Server) handleListExclusions(w http.ResponseWriter, r *http.Request) {
+	exclusions, err := s.adapter.ListExclusions(r.Context())
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.localize(exclusions))
+}
+
+// handleAddExclusion excludes a listing from ingestion and soft-deletes it
+func (s *Server) handleAddExclusion(w http.ResponseWriter, r *http.Request) {
+	var exclusion clickhouse.Exclusion
+	if err := json.NewDecoder(r.Body).Decode(&exclusion); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+	if exclusion.ListingID == "" {
+		writeError(w, http.StatusBadRequest, "listing_id is required")
+		return
+	}
+
+	exclusion.CreatedBy = "api:" + KeyFromContext(r.Context()).Name
+	exclusion.CreatedAt = clock.Now()
+
+	if err := s.adapter.AddExclusion(r.Context(), exclusion); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, s.localize(exclusion))
+}
+
+// handleRemoveExclusion takes a listing off the exclusion list
+func (s *Server) handleRemoveExclusion(w http.ResponseWriter, r *http.Request) {
+	removedBy := "api:" + KeyFromContext(r.Context()).Name
+	if err := s.adapter.RemoveExclusion(r.Context(), r.PathValue("id"), removedBy); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeStoreError maps adapter errors to HTTP statuses: missing listings are 404 and
+// queries that ran out of time are 504
+func writeStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, clickhouse.ErrListingNotFound):
+		writeError(w, http.StatusNotFound, "listing not found")
+	case errors.Is(err, clickhouse.ErrQueryTimeout):
+		writeError(w, http.StatusGatewayTimeout, "query timed out")
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a JSON error response
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+)
+
+func TestWriteStoreErrorStatus(t *testing.T) {
+	tests := map[string]struct {
+		err    error
+		status int
+	}{
+		"not found": {fmt.Errorf("%w: site:1", clickhouse.ErrListingNotFound), http.StatusNotFound},
+		"timeout":   {fmt.Errorf("failed to get stats: %w", &clickhouse.QueryTimeoutError{Operation: clickhouse.OperationAnalytics}), http.StatusGatewayTimeout},
+		"other":     {errors.New("connection refused"), http.StatusInternalServerError},
+	}
+
+	for name, tt := range tests {
+		recorder := httptest.NewRecorder()
+		writeStoreError(recorder, tt.err)
+		if recorder.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d", name, tt.status, recorder.Code)
+		}
+	}
+}
+
+func TestDashboardRequiresUnrestrictedKey(t *testing.T) {
+	store, err := NewKeyStore(&APIKey{Key: "moscow", Name: "moscow", Cities: []string{"Москва"}})
+	if err != nil {
+		t.Fatalf("Failed to create key store: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/dashboard", nil)
+	req.Header.Set("X-API-Key", "moscow")
+	recorder := httptest.NewRecorder()
+	NewServer(nil, store).Handler().ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("Expected %d for a scoped key, got %d", http.StatusForbidden, recorder.Code)
+	}
+}
+
+func TestParseChangesQuery(t *testing.T) {
+	now := time.Date(2025, 3, 14, 15, 30, 0, 0, time.UTC)
+
+	since, limit, err := parseChangesQuery(httptest.NewRequest(http.MethodGet, "/api/v1/changes", nil), now)
+	if err != nil || !since.Equal(time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC)) || limit != 100 {
+		t.Errorf("Expected start of today and limit 100, got %v, %d, %v", since, limit, err)
+	}
+
+	since, limit, err = parseChangesQuery(httptest.NewRequest(http.MethodGet, "/api/v1/changes?since=2h&limit=10", nil), now)
+	if err != nil || !since.Equal(now.Add(-2*time.Hour)) || limit != 10 {
+		t.Errorf("Expected 2h ago and limit 10, got %v, %d, %v", since, limit, err)
+	}
+
+	since, _, err = parseChangesQuery(httptest.NewRequest(http.MethodGet, "/api/v1/changes?since=2025-03-01T00:00:00Z", nil), now)
+	if err != nil || !since.Equal(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected 2025-03-01, got %v, %v", since, err)
+	}
+
+	if _, _, err := parseChangesQuery(httptest.NewRequest(http.MethodGet, "/api/v1/changes?since=yesterday", nil), now); err == nil {
+		t.Errorf("Expected an error for an invalid since")
+	}
+}
+
+func TestParseListingQueryFilters(t *testing.T) {
+	target := "/api/v1/listings?city=%D0%9C%D0%BE%D1%81%D0%BA%D0%B2%D0%B0&metro=Arbatskaya&min_price_hour=5000" +
+		"&max_price_hour=10000&min_age=20&max_age=30&has_photos=true&sort=-price_hour&limit=20"
+	query, err := parseListingQuery(httptest.NewRequest(http.MethodGet, target, nil))
+	if err != nil {
+		t.Fatalf("Expected a valid query, got %v", err)
+	}
+	if query.City != "Москва" || query.Metro != "Arbatskaya" || query.MinPriceHour != 5000 || query.MaxPriceHour != 10000 {
+		t.Errorf("Unexpected location or price filters: %+v", query)
+	}
+	if query.MinAge != 20 || query.MaxAge != 30 || query.HasPhotos == nil || !*query.HasPhotos {
+		t.Errorf("Unexpected age or photo filters: %+v", query)
+	}
+	if query.Sort != "-price_hour" || query.Limit != 20 {
+		t.Errorf("Unexpected sort or page: %+v", query)
+	}
+
+	for _, invalid := range []string{"sort=description", "min_price_hour=-1", "min_age=12", "min_age=30&max_age=20", "has_photos=maybe"} {
+		if _, err := parseListingQuery(httptest.NewRequest(http.MethodGet, "/api/v1/listings?"+invalid, nil)); err == nil {
+			t.Errorf("Expected an error for %s", invalid)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/storage"
+)
+
+// StoreServer serves the listing queries and statistics of a storage.Store, for local runs
+// without ClickHouse. The store cannot restrict results to a key scope, so only unrestricted keys
+// are served.
+type StoreServer struct {
+	store      storage.Store
+	keys       *KeyStore
+	middleware MiddlewareOptions // see middleware.go
+}
+
+// NewStoreServer creates an API server reading from store
+func NewStoreServer(store storage.Store, keys *KeyStore) *StoreServer {
+	return &StoreServer{store: store, keys: keys}
+}
+
+// SetMiddleware sets the access log, CORS and rate limit middleware of the server
+func (s *StoreServer) SetMiddleware(options MiddlewareOptions) {
+	s.middleware = options
+}
+
+// Handler returns the HTTP handler with the read routes registered, behind the middleware
+func (s *StoreServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/listings", s.handleQueryListings)
+	mux.HandleFunc("GET /api/v1/listings/{id}", s.handleGetListing)
+	mux.HandleFunc("GET /api/v1/stats", s.handleStats)
+	return withMiddleware(s.keys.Authenticate(requireUnrestrictedKey(mux)), s.middleware)
+}
+
+// Serve starts the API server and blocks until ctx is cancelled
+func (s *StoreServer) Serve(ctx context.Context, addr string) error {
+	return serve(ctx, addr, s.Handler())
+}
+
+// requireUnrestrictedKey refuses keys limited to a scope or to aggregate statistics
+func requireUnrestrictedKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := KeyFromContext(r.Context()); key == nil || key.AggregateOnly || !key.Scope().IsUnrestricted() {
+			writeError(w, http.StatusForbidden, "local storage requires an unrestricted api key")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleGetListing serves a single listing by composite ID
+func (s *StoreServer) handleGetListing(w http.ResponseWriter, r *http.Request) {
+	listing, err := s.store.GetListingByID(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeNegotiated(w, r, http.StatusOK, listing)
+}
+
+// handleQueryListings serves the latest versions of listings, most recently scraped first
+func (s *StoreServer) handleQueryListings(w http.ResponseWriter, r *http.Request) {
+	query, err := parseListingQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	listings, err := s.store.QueryListings(r.Context(), query)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeNegotiated(w, r, http.StatusOK, listings)
+}
+
+// handleStats serves aggregate statistics over the stored listings
+func (s *StoreServer) handleStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.store.GetStats(r.Context())
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
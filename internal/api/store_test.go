@@ -0,0 +1,23 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStoreServerRequiresUnrestrictedKey(t *testing.T) {
+	store, err := NewKeyStore(&APIKey{Key: "moscow", Name: "moscow", Cities: []string{"Москва"}})
+	if err != nil {
+		t.Fatalf("Failed to create key store: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/listings", nil)
+	req.Header.Set("X-API-Key", "moscow")
+	recorder := httptest.NewRecorder()
+	NewStoreServer(nil, store).Handler().ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("Expected %d for a scoped key, got %d", http.StatusForbidden, recorder.Code)
+	}
+}
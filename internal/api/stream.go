@@ -0,0 +1,349 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+	"github.com/gregor-tokarev/hoe_parser/internal/events"
+	"github.com/gregor-tokarev/hoe_parser/internal/metrics"
+)
+
+// Paths of the server-sent event streams
+const (
+	streamPath        = "/api/v1/events/stream" // every pipeline event, for unrestricted keys
+	listingStreamPath = "/api/v1/stream"        // new listings in the scope of the key
+)
+
+// listingStreamEvent is the event name of a new listing on the listing stream
+const listingStreamEvent = "listing"
+
+// What happens when a stream client's buffer is full
+const (
+	OverflowDropOldest = "drop_oldest" // drop the oldest buffered event and send a gap notice
+	OverflowDisconnect = "disconnect"  // disconnect the client
+)
+
+// Reasons a stream client is disconnected, as counted in the stream metrics
+const (
+	disconnectOverflow = "overflow"      // its buffer overflowed under OverflowDisconnect
+	disconnectSlow     = "slow"          // its buffer stayed full for longer than SlowAfter
+	disconnectWrite    = "write_failed"  // a write failed or did not finish within WriteTimeout
+	disconnectClient   = "client_closed" // the client went away
+)
+
+// StreamOptions control how the event stream buffers events for its clients
+type StreamOptions struct {
+	Buffer       int           // events buffered per client
+	Overflow     string        // OverflowDropOldest or OverflowDisconnect
+	SlowAfter    time.Duration // a client whose buffer stays full this long is disconnected, 0 never
+	WriteTimeout time.Duration // a write a client does not accept within this disconnects it
+	Heartbeat    time.Duration // comment sent to idle clients to keep the connection open
+}
+
+// StreamOptionsFromConfig returns the stream options of the main application config
+func StreamOptionsFromConfig(cfg *config.Config) StreamOptions {
+	return StreamOptions{
+		Buffer:       cfg.Stream.Buffer,
+		Overflow:     cfg.Stream.Overflow,
+		SlowAfter:    cfg.Stream.SlowAfter,
+		WriteTimeout: cfg.Stream.WriteTimeout,
+		Heartbeat:    cfg.Stream.Heartbeat,
+	}
+}
+
+// streamEvent is a pipeline event encoded once for every client
+type streamEvent struct {
+	id        uint64
+	eventType string
+	data      []byte
+}
+
+// streamHub fans the events of the bus out to the connected clients. Publishing never waits for
+// a client: every client has its own bounded buffer, drained by its request goroutine.
+type streamHub struct {
+	options StreamOptions
+
+	mutex   sync.Mutex
+	clients map[*streamClient]struct{}
+	nextID  uint64
+}
+
+// streamSelector picks the events a client receives and encodes them for it. It gets the event
+// and its JSON, and returns the event name and data to send, or false to skip the event.
+type streamSelector func(event events.Event, data []byte) (eventType string, payload []byte, ok bool)
+
+// streamedListing is a new listing on the listing stream
+type streamedListing struct {
+	ID       string                       `json:"id"`
+	URL      string                       `json:"url"`
+	StoredAt time.Time                    `json:"stored_at"`
+	Listing  *clickhouse.FlattenedListing `json:"listing,omitempty"` // with ?full=true
+}
+
+// streamClient is the buffer of one connected client
+type streamClient struct {
+	selector streamSelector
+
+	mutex     sync.Mutex
+	queue     []streamEvent
+	dropped   int       // events dropped since the last gap notice
+	fullSince time.Time // when the buffer last became full, zero while it has room
+	reason    string    // why the hub disconnected the client
+
+	notify chan struct{} // signalled when events were queued
+	done   chan struct{} // closed when the hub disconnects the client
+}
+
+// SetEventStream serves the events published on bus as server-sent events at
+// /api/v1/events/stream and the new listings among them at /api/v1/stream, buffering each
+// client's events as options say
+func (s *Server) SetEventStream(bus *events.Bus, options StreamOptions) {
+	if options.Buffer <= 0 {
+		options.Buffer = 256
+	}
+	if options.Overflow != OverflowDisconnect {
+		options.Overflow = OverflowDropOldest
+	}
+	if options.WriteTimeout <= 0 {
+		options.WriteTimeout = 10 * time.Second
+	}
+	if options.Heartbeat <= 0 {
+		options.Heartbeat = 15 * time.Second
+	}
+
+	hub := &streamHub{options: options, clients: make(map[*streamClient]struct{})}
+	bus.Subscribe("api_stream", 0, hub.publish)
+	s.stream = hub
+}
+
+// publish queues event for every client whose selector takes it
+func (h *streamHub) publish(event events.Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Warn("Failed to encode stream event", "type", event.Type(), "error", err)
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.nextID++
+	for client := range h.clients {
+		eventType, payload, ok := client.selector(event, data)
+		if !ok {
+			continue
+		}
+		if reason := client.push(streamEvent{id: h.nextID, eventType: eventType, data: payload}, h.options); reason != "" {
+			h.disconnect(client, reason)
+		}
+	}
+}
+
+// add connects a client receiving the events selector picks
+func (h *streamHub) add(selector streamSelector) *streamClient {
+	client := &streamClient{
+		selector: selector,
+		queue:    make([]streamEvent, 0, h.options.Buffer),
+		notify:   make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.clients[client] = struct{}{}
+	metrics.StreamClients.Inc()
+	return client
+}
+
+// remove forgets a client whose request ended, counting why it ended
+func (h *streamHub) remove(client *streamClient, reason string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+	delete(h.clients, client)
+	metrics.StreamClients.Dec()
+	metrics.StreamDisconnects.WithLabelValues(reason).Inc()
+}
+
+// disconnect drops a client that cannot keep up. Must be called with the hub locked.
+func (h *streamHub) disconnect(client *streamClient, reason string) {
+	delete(h.clients, client)
+	metrics.StreamClients.Dec()
+	metrics.StreamDisconnects.WithLabelValues(reason).Inc()
+
+	client.mutex.Lock()
+	client.reason = reason
+	client.mutex.Unlock()
+	close(client.done)
+}
+
+// push buffers an event for the client. It returns the reason to disconnect the client, or ""
+// while it keeps up: a full buffer drops the oldest event or, under OverflowDisconnect, ends the
+// client, and a buffer that stayed full for SlowAfter ends it too.
+func (c *streamClient) push(event streamEvent, options StreamOptions) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.queue) >= options.Buffer {
+		if options.Overflow == OverflowDisconnect {
+			return disconnectOverflow
+		}
+		if c.fullSince.IsZero() {
+			c.fullSince = clock.Now()
+		} else if options.SlowAfter > 0 && clock.Now().Sub(c.fullSince) >= options.SlowAfter {
+			return disconnectSlow
+		}
+		c.queue = append(c.queue[:0], c.queue[1:]...)
+		c.dropped++
+		metrics.StreamEventsDropped.Inc()
+	}
+	c.queue = append(c.queue, event)
+
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+	return ""
+}
+
+// take returns the buffered events and the number dropped before them, emptying the buffer
+func (c *streamClient) take() ([]streamEvent, int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	queued, dropped := c.queue, c.dropped
+	c.queue = make([]streamEvent, 0, cap(queued))
+	c.dropped = 0
+	c.fullSince = time.Time{}
+	return queued, dropped
+}
+
+// selectTypes selects the events of the given types, all when types is nil, as they are
+func selectTypes(types map[string]bool) streamSelector {
+	return func(event events.Event, data []byte) (string, []byte, bool) {
+		if types != nil && !types[event.Type()] {
+			return "", nil, false
+		}
+		return event.Type(), data, true
+	}
+}
+
+// selectNewListings selects the new and restored listings visible in scope, with the fields the
+// scope hides blanked; only their ID, URL and storage time unless full
+func (s *Server) selectNewListings(scope clickhouse.Scope, full bool) streamSelector {
+	return func(event events.Event, _ []byte) (string, []byte, bool) {
+		inserted, ok := event.(events.ListingInserted)
+		// New and restored listings come without changed fields
+		if !ok || inserted.Rows == 0 || len(inserted.ChangedFields) > 0 || inserted.Listing == nil || !scope.Allows(inserted.Listing) {
+			return "", nil, false
+		}
+
+		listing := *inserted.Listing
+		scope.Apply(&listing)
+		item := streamedListing{ID: listing.ID, URL: listing.SourceURL, StoredAt: inserted.StoredAt}
+		if full {
+			item.Listing = &listing
+		}
+
+		data, err := json.Marshal(s.localize(item))
+		if err != nil {
+			log.Warn("Failed to encode streamed listing", "listing_id", listing.ID, "error", err)
+			return "", nil, false
+		}
+		return listingStreamEvent, data, true
+	}
+}
+
+// handleStream serves pipeline events as server-sent events. ?types= limits the stream to a
+// comma-separated list of event types.
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	var types map[string]bool
+	if value := r.URL.Query().Get("types"); value != "" {
+		types = make(map[string]bool)
+		for _, eventType := range strings.Split(value, ",") {
+			types[strings.TrimSpace(eventType)] = true
+		}
+	}
+	s.serveStream(w, r, selectTypes(types))
+}
+
+// handleListingStream serves the new listings visible to the key as server-sent events, so
+// consumers can react to them without polling. ?full=true sends whole listings instead of their
+// ID and URL.
+func (s *Server) handleListingStream(w http.ResponseWriter, r *http.Request) {
+	full := false
+	if value := r.URL.Query().Get("full"); value != "" {
+		var err error
+		if full, err = strconv.ParseBool(value); err != nil {
+			writeError(w, http.StatusBadRequest, "full must be true or false")
+			return
+		}
+	}
+	s.serveStream(w, r, s.selectNewListings(KeyFromContext(r.Context()).Scope(), full))
+}
+
+// serveStream sends the events selector picks until the client goes away or falls too far
+// behind. A gap event tells the client how many of its events were dropped.
+func (s *Server) serveStream(w http.ResponseWriter, r *http.Request, selector streamSelector) {
+	hub := s.stream
+	client := hub.add(selector)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	controller := http.NewResponseController(w)
+	write := func(message string) bool {
+		// Not every ResponseWriter supports deadlines; such writes are only bounded by the client
+		controller.SetWriteDeadline(time.Now().Add(hub.options.WriteTimeout))
+		if _, err := fmt.Fprint(w, message); err != nil {
+			return false
+		}
+		return controller.Flush() == nil
+	}
+	if !write(": connected\n\n") {
+		hub.remove(client, disconnectWrite)
+		return
+	}
+
+	heartbeat := time.NewTicker(hub.options.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			hub.remove(client, disconnectClient)
+			return
+		case <-client.done:
+			write(fmt.Sprintf("event: disconnect\ndata: {\"reason\":%q}\n\n", client.reason))
+			return
+		case <-heartbeat.C:
+			if !write(": heartbeat\n\n") {
+				hub.remove(client, disconnectWrite)
+				return
+			}
+		case <-client.notify:
+			queued, dropped := client.take()
+			var message strings.Builder
+			if dropped > 0 {
+				fmt.Fprintf(&message, "event: gap\ndata: {\"dropped\":%d}\n\n", dropped)
+			}
+			for _, event := range queued {
+				fmt.Fprintf(&message, "id: %d\nevent: %s\ndata: %s\n\n", event.id, event.eventType, event.data)
+			}
+			if !write(message.String()) {
+				hub.remove(client, disconnectWrite)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+	"github.com/gregor-tokarev/hoe_parser/internal/events"
+)
+
+func TestStreamClientDropsOldest(t *testing.T) {
+	fixed := clock.NewFixed(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC))
+	defer clock.SetClock(fixed)()
+
+	hub := &streamHub{options: StreamOptions{Buffer: 2, Overflow: OverflowDropOldest, SlowAfter: time.Minute}, clients: map[*streamClient]struct{}{}}
+	client := hub.add(selectTypes(nil))
+	for _, id := range []string{"1", "2", "3"} {
+		hub.publish(events.ListingRemoved{ListingID: "intimcity.gold:" + id})
+	}
+
+	queued, dropped := client.take()
+	if dropped != 1 || len(queued) != 2 || queued[0].id != 2 {
+		t.Fatalf("Expected events 2 and 3 after a gap of 1, got %d events from id %d, %d dropped", len(queued), queued[0].id, dropped)
+	}
+
+	// A buffer that stays full for SlowAfter disconnects the client
+	for range 3 {
+		hub.publish(events.ListingRemoved{ListingID: "intimcity.gold:4"})
+	}
+	fixed.Advance(time.Minute)
+	hub.publish(events.ListingRemoved{ListingID: "intimcity.gold:5"})
+
+	select {
+	case <-client.done:
+		if client.reason != disconnectSlow {
+			t.Errorf("Expected a slow client disconnect, got %q", client.reason)
+		}
+	default:
+		t.Errorf("Expected the slow client to be disconnected")
+	}
+	if len(hub.clients) != 0 {
+		t.Errorf("Expected no clients left, got %d", len(hub.clients))
+	}
+}
+
+func TestStreamClientDisconnectsOnOverflow(t *testing.T) {
+	hub := &streamHub{options: StreamOptions{Buffer: 1, Overflow: OverflowDisconnect}, clients: map[*streamClient]struct{}{}}
+	client := hub.add(selectTypes(map[string]bool{events.TypeListingRemoved: true}))
+
+	hub.publish(events.ListingRemoved{ListingID: "intimcity.gold:1"})
+	hub.publish(events.ScrapeFailed{ListingID: "intimcity.gold:2"}) // not subscribed
+	hub.publish(events.ListingRemoved{ListingID: "intimcity.gold:3"})
+
+	select {
+	case <-client.done:
+		if client.reason != disconnectOverflow {
+			t.Errorf("Expected an overflow disconnect, got %q", client.reason)
+		}
+	default:
+		t.Errorf("Expected the client to be disconnected when its buffer overflowed")
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	store, err := NewKeyStore(&APIKey{Key: "secret", Name: "ops"})
+	if err != nil {
+		t.Fatalf("Failed to create key store: %v", err)
+	}
+	bus := events.NewBus()
+	defer bus.Close()
+
+	server := NewServer(nil, store)
+	server.SetEventStream(bus, StreamOptions{})
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/v1/events/stream?types=listing.removed", nil)
+	req.Header.Set("X-API-Key", "secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %q", resp.Header.Get("Content-Type"))
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	if line, _ := reader.ReadString('\n'); line != ": connected\n" {
+		t.Fatalf("Expected the connected comment, got %q", line)
+	}
+
+	bus.Publish(events.ScrapeFailed{ListingID: "intimcity.gold:1"})
+	bus.Publish(events.ListingRemoved{ListingID: "intimcity.gold:2"})
+
+	var received []string
+	for len(received) < 3 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read the stream: %v", err)
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			received = append(received, line)
+		}
+	}
+	if received[1] != "event: listing.removed" || !strings.Contains(received[2], `"listing_id":"intimcity.gold:2"`) {
+		t.Errorf("Expected only the listing.removed event, got %q", received)
+	}
+}
+
+func TestSelectNewListings(t *testing.T) {
+	server := NewServer(nil, nil)
+	scope := clickhouse.Scope{Cities: []string{"Москва"}, HiddenFields: []string{clickhouse.FieldGroupContact}}
+	moscow := &clickhouse.FlattenedListing{ID: "intimcity.gold:1", SourceURL: "https://a.intimcity.gold/anketa1.htm", LocationCity: "Москва", ContactPhone: "+79990000000"}
+	kazan := &clickhouse.FlattenedListing{ID: "intimcity.gold:2", LocationCity: "Казань"}
+
+	selector := server.selectNewListings(scope, false)
+	for _, event := range []events.Event{
+		events.ListingInserted{ListingID: kazan.ID, Rows: 1, Listing: kazan},
+		events.ListingInserted{ListingID: moscow.ID, Rows: 1, ChangedFields: []string{"price_hour"}, Listing: moscow},
+		events.ListingInserted{ListingID: moscow.ID, Rows: 0, Listing: moscow},
+		events.ListingRemoved{ListingID: moscow.ID},
+	} {
+		if _, _, ok := selector(event, nil); ok {
+			t.Errorf("Expected %+v to be skipped", event)
+		}
+	}
+
+	eventType, data, ok := selector(events.ListingInserted{ListingID: moscow.ID, Rows: 1, Listing: moscow}, nil)
+	if !ok || eventType != "listing" {
+		t.Fatalf("Expected a listing event, got %q, %v", eventType, ok)
+	}
+	if !strings.Contains(string(data), `"url":"https://a.intimcity.gold/anketa1.htm"`) || strings.Contains(string(data), `"listing"`) {
+		t.Errorf("Expected only the ID and URL of the listing, got %s", data)
+	}
+
+	_, data, _ = server.selectNewListings(scope, true)(events.ListingInserted{ListingID: moscow.ID, Rows: 1, Listing: moscow}, nil)
+	if !strings.Contains(string(data), `"location_city":"Москва"`) || strings.Contains(string(data), "+7999") {
+		t.Errorf("Expected the whole listing without its contacts, got %s", data)
+	}
+	if moscow.ContactPhone == "" {
+		t.Errorf("Expected the published listing to be left unchanged")
+	}
+}
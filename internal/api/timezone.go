@@ -0,0 +1,72 @@
+package api
+
+import (
+	"reflect"
+	"time"
+)
+
+// timeType is the reflected type of time.Time
+var timeType = reflect.TypeOf(time.Time{})
+
+// SetLocation sets the time zone the times in responses and dashboard pages are shown in. Stored
+// times are UTC; the default is to show them as stored.
+func (s *Server) SetLocation(location *time.Location) {
+	if location == nil {
+		location = time.UTC
+	}
+	s.location = location
+}
+
+// localize returns v with every non-zero time.Time it holds, directly or through exported fields,
+// pointers, slices and maps, converted to the display time zone
+func (s *Server) localize(v any) any {
+	if v == nil || s.location == nil || s.location == time.UTC {
+		return v
+	}
+
+	value := reflect.New(reflect.TypeOf(v)).Elem()
+	value.Set(reflect.ValueOf(v))
+	localizeValue(value, s.location)
+	return value.Interface()
+}
+
+// localizeValue converts the times reachable from the settable value v to location in place
+func localizeValue(v reflect.Value, location *time.Location) {
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == timeType {
+			if t := v.Interface().(time.Time); !t.IsZero() && v.CanSet() {
+				v.Set(reflect.ValueOf(t.In(location)))
+			}
+			return
+		}
+		for i := range v.NumField() {
+			if v.Type().Field(i).IsExported() {
+				localizeValue(v.Field(i), location)
+			}
+		}
+	case reflect.Pointer:
+		if !v.IsNil() {
+			localizeValue(v.Elem(), location)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			localizeValue(v.Index(i), location)
+		}
+	case reflect.Interface:
+		// The value inside an interface cannot be set, so it is converted as a copy
+		if !v.IsNil() && v.CanSet() {
+			inner := reflect.New(v.Elem().Type()).Elem()
+			inner.Set(v.Elem())
+			localizeValue(inner, location)
+			v.Set(inner)
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			element := reflect.New(v.Type().Elem()).Elem()
+			element.Set(v.MapIndex(key))
+			localizeValue(element, location)
+			v.SetMapIndex(key, element)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/modules/request_client"
+)
+
+func TestLocalize(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	server := NewServer(nil, nil)
+	server.SetLocation(moscow)
+
+	listing := &clickhouse.FlattenedListing{CreatedAt: at, UpdatedAt: at}
+	history := []clickhouse.ChangeRecord{{ChangedAt: at}}
+	stats := map[string]any{"computed_at": at}
+
+	server.localize(listing)
+	localizedHistory := server.localize(history).([]clickhouse.ChangeRecord)
+	localizedStats := server.localize(stats).(map[string]any)
+
+	for name, got := range map[string]time.Time{
+		"pointer field": listing.CreatedAt,
+		"slice element": localizedHistory[0].ChangedAt,
+		"map value":     localizedStats["computed_at"].(time.Time),
+	} {
+		if got.Location() != moscow || !got.Equal(at) {
+			t.Errorf("%s: expected %v in MSK, got %v", name, at, got)
+		}
+	}
+	if !listing.LastScraped.IsZero() {
+		t.Errorf("Expected zero times to stay zero, got %v", listing.LastScraped)
+	}
+}
+
+func TestDashboardUsesDisplayTimezone(t *testing.T) {
+	store, err := NewKeyStore(&APIKey{Key: "secret", Name: "ops"})
+	if err != nil {
+		t.Fatalf("Failed to create key store: %v", err)
+	}
+
+	server := NewServer(nil, store)
+	server.SetLocation(time.FixedZone("MSK", 3*60*60))
+	server.SetDashboard(DashboardSources{
+		Proxies: func() []request_client.ProxyStats {
+			return []request_client.ProxyStats{{
+				Proxy:            "http://10.0.0.1:8080",
+				QuarantinedUntil: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+			}}
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/dashboard/proxies", nil)
+	req.SetBasicAuth("", "secret")
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, req)
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, "15:00:00") {
+		t.Errorf("Expected the quarantine end in MSK, got %s", body)
+	}
+	if !strings.Contains(body, " MSK</footer>") {
+		t.Errorf("Expected the generation time in MSK")
+	}
+}
@@ -0,0 +1,180 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+)
+
+// Snapshot is the raw HTML of a listing page as the site served it, before any decoding, so it
+// can be parsed again offline after an extractor fix
+type Snapshot struct {
+	Site      string    // source site key, e.g. intimcity.gold
+	ListingID string    // source-local listing ID
+	URL       string    // URL the page was fetched from, which may be the mobile variant
+	FetchedAt time.Time // when the page was fetched
+	Body      []byte
+}
+
+// Store keeps page snapshots
+type Store interface {
+	// Put stores a snapshot under its Key
+	Put(ctx context.Context, snapshot Snapshot) error
+}
+
+// snapshotTimeFormat names snapshots by fetch time in UTC, so they sort chronologically
+const snapshotTimeFormat = "20060102T150405.000Z"
+
+// Key returns where a snapshot is stored: site/listing ID/fetch time.html.gz
+func Key(snapshot Snapshot) string {
+	return path.Join(safeSegment(snapshot.Site), safeSegment(snapshot.ListingID),
+		snapshot.FetchedAt.UTC().Format(snapshotTimeFormat)+".html.gz")
+}
+
+// safeSegment keeps a key segment from escaping its directory
+func safeSegment(segment string) string {
+	segment = strings.NewReplacer("/", "_", "\\", "_").Replace(segment)
+	if segment == "" || segment == "." || segment == ".." {
+		return "_"
+	}
+	return segment
+}
+
+// Encode compresses a snapshot with gzip. The gzip header keeps the fetched URL as its name and
+// the fetch time as its modification time, so a snapshot file describes itself.
+func Encode(snapshot Snapshot) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	writer.Name = snapshot.URL
+	writer.ModTime = snapshot.FetchedAt
+	if _, err := writer.Write(snapshot.Body); err != nil {
+		return nil, fmt.Errorf("failed to compress snapshot: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress snapshot: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode reads a snapshot compressed by Encode. Site and ListingID are not part of the file and
+// are left empty.
+func Decode(data io.Reader) (Snapshot, error) {
+	reader, err := gzip.NewReader(data)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer reader.Close()
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("failed to decompress snapshot: %w", err)
+	}
+	return Snapshot{URL: reader.Name, FetchedAt: reader.ModTime, Body: body}, nil
+}
+
+// DirStore keeps snapshots as files under a directory
+type DirStore struct {
+	dir string
+}
+
+// NewDirStore creates a store writing under dir
+func NewDirStore(dir string) *DirStore {
+	return &DirStore{dir: dir}
+}
+
+// Put writes the snapshot to a temporary file and renames it into place, so a crash mid-write
+// never leaves a truncated snapshot
+func (s *DirStore) Put(ctx context.Context, snapshot Snapshot) error {
+	data, err := Encode(snapshot)
+	if err != nil {
+		return err
+	}
+
+	file := filepath.Join(s.dir, filepath.FromSlash(Key(snapshot)))
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return fmt.Errorf("failed to rename snapshot: %w", err)
+	}
+	return nil
+}
+
+// HTTPStore uploads snapshots with PUT requests under a base URL, as object stores and their
+// gateways accept, e.g. a pre-authorized bucket URL
+type HTTPStore struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// NewHTTPStore creates a store uploading under baseURL, with token as bearer token when set
+func NewHTTPStore(baseURL, token string) *HTTPStore {
+	return &HTTPStore{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Put uploads the snapshot to the base URL followed by its Key
+func (s *HTTPStore) Put(ctx context.Context, snapshot Snapshot) error {
+	data, err := Encode(snapshot)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.baseURL+"/"+Key(snapshot), bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create upload request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/gzip")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload snapshot: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to upload snapshot: store returned %s", resp.Status)
+	}
+	return nil
+}
+
+// NewStoreFromConfig returns the configured snapshot store, or nil when archiving is disabled
+func NewStoreFromConfig(cfg *config.Config) (Store, error) {
+	archiveCfg := cfg.HTMLArchive
+	if !archiveCfg.Enabled {
+		return nil, nil
+	}
+
+	switch archiveCfg.Backend {
+	case "dir":
+		return NewDirStore(archiveCfg.Dir), nil
+	case "http":
+		if archiveCfg.URL == "" {
+			return nil, fmt.Errorf("HTML_ARCHIVE_URL is required by the http backend")
+		}
+		return NewHTTPStore(archiveCfg.URL, archiveCfg.Token), nil
+	}
+	return nil, fmt.Errorf("unknown HTML archive backend %q, expected dir or http", archiveCfg.Backend)
+}
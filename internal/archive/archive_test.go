@@ -0,0 +1,99 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testSnapshot() Snapshot {
+	return Snapshot{
+		Site:      "intimcity.gold",
+		ListingID: "12345",
+		URL:       "https://m.intimcity.gold/anketa12345.htm",
+		FetchedAt: time.Date(2025, 3, 1, 12, 30, 5, 0, time.UTC),
+		Body:      []byte("<html><body>\xcf\xf0\xe8\xe2\xe5\xf2</body></html>"),
+	}
+}
+
+func TestKey(t *testing.T) {
+	if key := Key(testSnapshot()); key != "intimcity.gold/12345/20250301T123005.000Z.html.gz" {
+		t.Errorf("Expected the key of the snapshot, got %s", key)
+	}
+
+	snapshot := testSnapshot()
+	snapshot.ListingID = ".."
+	if key := Key(snapshot); key != "intimcity.gold/_/20250301T123005.000Z.html.gz" {
+		t.Errorf("Expected the listing ID to stay inside the site, got %s", key)
+	}
+}
+
+func TestDirStorePut(t *testing.T) {
+	dir := t.TempDir()
+	snapshot := testSnapshot()
+	if err := NewDirStore(dir).Put(context.Background(), snapshot); err != nil {
+		t.Fatalf("Failed to store snapshot: %v", err)
+	}
+
+	file, err := os.Open(filepath.Join(dir, filepath.FromSlash(Key(snapshot))))
+	if err != nil {
+		t.Fatalf("Expected the snapshot file: %v", err)
+	}
+	defer file.Close()
+
+	decoded, err := Decode(file)
+	if err != nil {
+		t.Fatalf("Failed to decode snapshot: %v", err)
+	}
+	if !bytes.Equal(decoded.Body, snapshot.Body) {
+		t.Errorf("Expected the page as served, got %q", decoded.Body)
+	}
+	if decoded.URL != snapshot.URL {
+		t.Errorf("Expected URL %s, got %s", snapshot.URL, decoded.URL)
+	}
+	if !decoded.FetchedAt.Equal(snapshot.FetchedAt) {
+		t.Errorf("Expected fetch time %v, got %v", snapshot.FetchedAt, decoded.FetchedAt)
+	}
+}
+
+func TestHTTPStorePut(t *testing.T) {
+	var path, auth string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("Authorization")
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	snapshot := testSnapshot()
+	if err := NewHTTPStore(server.URL+"/pages/", "secret").Put(context.Background(), snapshot); err != nil {
+		t.Fatalf("Failed to upload snapshot: %v", err)
+	}
+	if path != "/pages/"+Key(snapshot) {
+		t.Errorf("Expected upload to /pages/%s, got %s", Key(snapshot), path)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("Expected bearer token, got %q", auth)
+	}
+	if decoded, err := Decode(bytes.NewReader(body)); err != nil || !bytes.Equal(decoded.Body, snapshot.Body) {
+		t.Errorf("Expected the compressed page to be uploaded, got %v", err)
+	}
+}
+
+func TestHTTPStorePutFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	if err := NewHTTPStore(server.URL, "").Put(context.Background(), testSnapshot()); err == nil {
+		t.Errorf("Expected an error when the store refuses the upload")
+	}
+}
@@ -0,0 +1,281 @@
+package autoscale
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+)
+
+// Scaling reasons reported in events
+const (
+	ReasonStart      = "start"
+	ReasonQueueDepth = "queue_depth" // the queue is filling up
+	ReasonIdle       = "idle"        // the queue is nearly empty
+	ReasonBlockRate  = "block_rate"  // the site refuses too many requests
+	ReasonErrorRate  = "error_rate"  // too many jobs fail
+)
+
+// minRateSamples is the number of finished jobs in an interval needed before error and block rates are trusted
+const minRateSamples = 5
+
+// Config holds the pool size bounds and scaling thresholds
+type Config struct {
+	MinWorkers     int
+	MaxWorkers     int
+	Interval       time.Duration // how often the pool size is re-evaluated
+	ScaleUpQueue   float64       // queue fill ratio at or above which a worker is added
+	ScaleDownQueue float64       // queue fill ratio at or below which a worker is removed
+	MaxErrorRate   float64       // failed share of jobs at or above which a worker is removed
+	MaxBlockRate   float64       // blocked share of jobs at or above which a worker is removed
+}
+
+// FromConfig converts the parser autoscaling settings
+func FromConfig(cfg config.AutoscaleConfig) Config {
+	return Config{
+		MinWorkers:     cfg.MinWorkers,
+		MaxWorkers:     cfg.MaxWorkers,
+		Interval:       cfg.Interval,
+		ScaleUpQueue:   cfg.ScaleUpQueue,
+		ScaleDownQueue: cfg.ScaleDownQueue,
+		MaxErrorRate:   cfg.MaxErrorRate,
+		MaxBlockRate:   cfg.MaxBlockRate,
+	}
+}
+
+// Sample is what the pool observed over one interval
+type Sample struct {
+	Workers    int
+	Busy       int     // workers running a job when the sample was taken
+	QueueRatio float64 // queued jobs divided by queue capacity
+	Finished   int
+	Errors     int
+	Blocked    int
+}
+
+// ErrorRate returns the failed share of finished jobs
+func (s Sample) ErrorRate() float64 {
+	if s.Finished == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Finished)
+}
+
+// BlockRate returns the blocked share of finished jobs
+func (s Sample) BlockRate() float64 {
+	if s.Finished == 0 {
+		return 0
+	}
+	return float64(s.Blocked) / float64(s.Finished)
+}
+
+// Event describes a change of the pool size
+type Event struct {
+	At         time.Time `json:"at"`
+	From       int       `json:"from"`
+	To         int       `json:"to"`
+	Reason     string    `json:"reason"`
+	QueueRatio float64   `json:"queue_ratio"`
+	ErrorRate  float64   `json:"error_rate"`
+	BlockRate  float64   `json:"block_rate"`
+}
+
+// Decide returns the pool size for the next interval and why it changed.
+// Blocks shrink the pool first, since more workers only make a ban worse.
+func (c Config) Decide(s Sample) (int, string) {
+	trusted := s.Finished >= minRateSamples
+
+	switch {
+	case trusted && c.MaxBlockRate > 0 && s.BlockRate() >= c.MaxBlockRate:
+		return c.clamp(s.Workers - 1), ReasonBlockRate
+	case trusted && c.MaxErrorRate > 0 && s.ErrorRate() >= c.MaxErrorRate:
+		return c.clamp(s.Workers - 1), ReasonErrorRate
+	case s.QueueRatio >= c.ScaleUpQueue:
+		return c.clamp(s.Workers + 1), ReasonQueueDepth
+	case s.QueueRatio <= c.ScaleDownQueue && s.Busy < s.Workers:
+		return c.clamp(s.Workers - 1), ReasonIdle
+	}
+	return s.Workers, ""
+}
+
+// clamp keeps n within the configured bounds
+func (c Config) clamp(n int) int {
+	if n > c.MaxWorkers {
+		n = c.MaxWorkers
+	}
+	if n < c.MinWorkers {
+		n = c.MinWorkers
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// Pool runs jobs from a channel on a number of workers that follows the queue depth and failure rates
+type Pool[T any] struct {
+	cfg       Config
+	jobs      <-chan T
+	handle    func(context.Context, T) error
+	isBlocked func(error) bool
+	onScale   func(Event)
+
+	mu       sync.Mutex
+	stops    []chan struct{} // one per running worker
+	busy     int
+	finished int
+	errors   int
+	blocked  int
+	wg       sync.WaitGroup
+
+	drained   chan struct{} // closed once a worker finds the jobs channel closed
+	drainOnce sync.Once
+}
+
+// NewPool creates a pool running handle for every job; isBlocked classifies errors as blocks and may be nil
+func NewPool[T any](cfg Config, jobs <-chan T, handle func(context.Context, T) error, isBlocked func(error) bool) *Pool[T] {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 30 * time.Second
+	}
+	if cfg.MaxWorkers < cfg.MinWorkers {
+		cfg.MaxWorkers = cfg.MinWorkers
+	}
+
+	return &Pool[T]{
+		cfg:       cfg,
+		jobs:      jobs,
+		handle:    handle,
+		isBlocked: isBlocked,
+		drained:   make(chan struct{}),
+	}
+}
+
+// SetScaleHandler sets a callback receiving every change of the pool size
+func (p *Pool[T]) SetScaleHandler(handler func(Event)) {
+	p.onScale = handler
+}
+
+// Workers returns the number of running workers
+func (p *Pool[T]) Workers() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.stops)
+}
+
+// Run starts initial workers and re-evaluates the pool size every interval until ctx is cancelled
+// or the jobs channel is closed and empty, then waits for running jobs to finish. Closing the jobs
+// channel drains the pool: every queued job is still handled.
+func (p *Pool[T]) Run(ctx context.Context, initial int) {
+	p.resize(ctx, Event{At: clock.Now(), To: p.cfg.clamp(initial), Reason: ReasonStart})
+
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			sample := p.sample()
+			target, reason := p.cfg.Decide(sample)
+			if target != sample.Workers {
+				p.resize(ctx, Event{
+					At:         clock.Now(),
+					From:       sample.Workers,
+					To:         target,
+					Reason:     reason,
+					QueueRatio: sample.QueueRatio,
+					ErrorRate:  sample.ErrorRate(),
+					BlockRate:  sample.BlockRate(),
+				})
+			}
+		case <-p.drained:
+			p.wg.Wait()
+			return
+		case <-ctx.Done():
+			p.wg.Wait()
+			return
+		}
+	}
+}
+
+// sample returns the observations since the previous call and resets the counters
+func (p *Pool[T]) sample() Sample {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s := Sample{
+		Workers:  len(p.stops),
+		Busy:     p.busy,
+		Finished: p.finished,
+		Errors:   p.errors,
+		Blocked:  p.blocked,
+	}
+	if capacity := cap(p.jobs); capacity > 0 {
+		s.QueueRatio = float64(len(p.jobs)) / float64(capacity)
+	}
+
+	p.finished, p.errors, p.blocked = 0, 0, 0
+	return s
+}
+
+// resize starts or stops workers to reach event.To and reports the event
+func (p *Pool[T]) resize(ctx context.Context, event Event) {
+	p.mu.Lock()
+	for len(p.stops) < event.To {
+		stop := make(chan struct{})
+		p.stops = append(p.stops, stop)
+		p.wg.Add(1)
+		go p.work(ctx, stop)
+	}
+	for len(p.stops) > event.To {
+		last := len(p.stops) - 1
+		close(p.stops[last])
+		p.stops = p.stops[:last]
+	}
+	p.mu.Unlock()
+
+	if p.onScale != nil {
+		p.onScale(event)
+	}
+}
+
+// work runs jobs until the worker is stopped, ctx is cancelled or the jobs channel is drained; a
+// stopped worker finishes its current job
+func (p *Pool[T]) work(ctx context.Context, stop <-chan struct{}) {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case job, ok := <-p.jobs:
+			if !ok {
+				p.drainOnce.Do(func() { close(p.drained) })
+				return
+			}
+			p.mu.Lock()
+			p.busy++
+			p.mu.Unlock()
+
+			p.record(p.handle(ctx, job))
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// record counts a finished job and frees its worker
+func (p *Pool[T]) record(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.busy--
+	p.finished++
+	if err != nil {
+		p.errors++
+		if p.isBlocked != nil && p.isBlocked(err) {
+			p.blocked++
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package autoscale
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDecide(t *testing.T) {
+	cfg := Config{MinWorkers: 2, MaxWorkers: 4, ScaleUpQueue: 0.5, ScaleDownQueue: 0.1, MaxErrorRate: 0.5, MaxBlockRate: 0.2}
+
+	tests := []struct {
+		name   string
+		sample Sample
+		want   int
+		reason string
+	}{
+		{"full queue grows", Sample{Workers: 3, Busy: 3, QueueRatio: 0.8}, 4, ReasonQueueDepth},
+		{"growth stops at max", Sample{Workers: 4, Busy: 4, QueueRatio: 1}, 4, ReasonQueueDepth},
+		{"blocks shrink despite full queue", Sample{Workers: 3, Busy: 3, QueueRatio: 1, Finished: 10, Errors: 3, Blocked: 3}, 2, ReasonBlockRate},
+		{"errors shrink", Sample{Workers: 3, Busy: 3, QueueRatio: 0.3, Finished: 10, Errors: 6}, 2, ReasonErrorRate},
+		{"few samples ignore rates", Sample{Workers: 3, Busy: 3, QueueRatio: 0.3, Finished: 2, Errors: 2, Blocked: 2}, 3, ""},
+		{"idle shrinks", Sample{Workers: 3, Busy: 1, QueueRatio: 0}, 2, ReasonIdle},
+		{"busy workers are kept", Sample{Workers: 3, Busy: 3, QueueRatio: 0}, 3, ""},
+		{"shrinking stops at min", Sample{Workers: 2, Busy: 0, QueueRatio: 0}, 2, ReasonIdle},
+	}
+
+	for _, tt := range tests {
+		got, reason := cfg.Decide(tt.sample)
+		if got != tt.want || (got != tt.sample.Workers && reason != tt.reason) {
+			t.Errorf("%s: expected %d (%s), got %d (%s)", tt.name, tt.want, tt.reason, got, reason)
+		}
+	}
+}
+
+func TestPoolScalesAndRecordsBlocks(t *testing.T) {
+	errBlocked := errors.New("blocked")
+	jobs := make(chan int, 10)
+
+	var mu sync.Mutex
+	var events []Event
+
+	pool := NewPool(Config{MinWorkers: 1, MaxWorkers: 3, Interval: 10 * time.Millisecond, ScaleUpQueue: 0.5, ScaleDownQueue: 0.1, MaxBlockRate: 0.5},
+		jobs, func(ctx context.Context, job int) error { return errBlocked },
+		func(err error) bool { return errors.Is(err, errBlocked) })
+	pool.SetScaleHandler(func(event Event) {
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		pool.Run(ctx, 3)
+		close(done)
+	}()
+
+	for i := 0; i < 10; i++ {
+		jobs <- i
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for pool.Workers() != 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if pool.Workers() != 1 {
+		t.Fatalf("Expected blocks to shrink the pool to 1 worker, got %d", pool.Workers())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) < 2 || events[0].Reason != ReasonStart || events[0].To != 3 {
+		t.Fatalf("Expected a start event to 3 workers followed by scale downs, got %+v", events)
+	}
+	if events[1].Reason != ReasonBlockRate || events[1].BlockRate != 1 {
+		t.Errorf("Expected the first scale down to be caused by blocks, got %+v", events[1])
+	}
+}
+
+func TestPoolDrainsClosedJobs(t *testing.T) {
+	jobs := make(chan int, 10)
+	for i := 0; i < 10; i++ {
+		jobs <- i
+	}
+	close(jobs)
+
+	var mu sync.Mutex
+	handled := 0
+	pool := NewPool(Config{MinWorkers: 1, MaxWorkers: 2, Interval: time.Hour}, jobs, func(ctx context.Context, job int) error {
+		mu.Lock()
+		handled++
+		mu.Unlock()
+		return nil
+	}, nil)
+
+	done := make(chan struct{})
+	go func() {
+		pool.Run(context.Background(), 2)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the pool to stop once the closed jobs channel was drained")
+	}
+	if handled != 10 {
+		t.Errorf("Expected 10 handled jobs, got %d", handled)
+	}
+}
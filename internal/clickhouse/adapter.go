@@ -3,14 +3,22 @@ package clickhouse
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
 	mainConfig "github.com/gregor-tokarev/hoe_parser/internal/config"
 	listing "github.com/gregor-tokarev/hoe_parser/proto"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/logger"
 )
 
+// log is the component logger of the package
+var log = logger.Component("clickhouse")
+
 // Config holds ClickHouse connection configuration
 // This is compatible with the main config.ClickHouseConfig but adds Debug option
 type Config struct {
@@ -21,6 +29,27 @@ type Config struct {
 	Password       string
 	MaxConnections int
 	Debug          bool
+
+	// Insert tuning, applied to every INSERT issued by the adapter
+	AsyncInsert        bool
+	WaitForAsyncInsert bool
+	MaxInsertBlockSize uint64 // 0 keeps the server default
+	InsertQuorum       int    // 0 disables quorum writes
+
+	// Per-operation timeouts, 0 uses the Default*Timeout values
+	InsertTimeout    time.Duration
+	QueryTimeout     time.Duration
+	AnalyticsTimeout time.Duration
+
+	// SchemaCompat leaves listing columns the table lacks out of every statement, so the
+	// adapter runs against a schema whose migrations are not applied yet
+	SchemaCompat bool
+
+	// AutoMigrate applies the pending schema migrations when the adapter is created
+	AutoMigrate bool
+	// MigrateBaseline is the last migration applied by hand to a database created before
+	// migrations were tracked; 0 when unset
+	MigrateBaseline int
 }
 
 // FromMainConfig creates a ClickHouse adapter Config from the main application config
@@ -33,6 +62,20 @@ func FromMainConfig(mainCfg *mainConfig.Config, debug bool) Config {
 		Password:       mainCfg.ClickHouse.Password,
 		MaxConnections: mainCfg.ClickHouse.MaxConnections,
 		Debug:          debug,
+
+		AsyncInsert:        mainCfg.ClickHouse.AsyncInsert,
+		WaitForAsyncInsert: mainCfg.ClickHouse.WaitForAsyncInsert,
+		MaxInsertBlockSize: mainCfg.ClickHouse.MaxInsertBlockSize,
+		InsertQuorum:       mainCfg.ClickHouse.InsertQuorum,
+
+		InsertTimeout:    mainCfg.ClickHouse.InsertTimeout,
+		QueryTimeout:     mainCfg.ClickHouse.QueryTimeout,
+		AnalyticsTimeout: mainCfg.ClickHouse.AnalyticsTimeout,
+
+		SchemaCompat: mainCfg.ClickHouse.SchemaCompat,
+
+		AutoMigrate:     mainCfg.ClickHouse.AutoMigrate,
+		MigrateBaseline: mainCfg.ClickHouse.MigrateBaseline,
 	}
 }
 
@@ -40,76 +83,104 @@ func FromMainConfig(mainCfg *mainConfig.Config, debug bool) Config {
 type Adapter struct {
 	conn   clickhouse.Conn
 	config Config
+
+	exclusionsMutex sync.RWMutex
+	exclusions      map[string]bool // cached listing_exclusions, see RefreshExclusions
+
+	listingSchema *listingSchema // nil reads and writes every listing column, see loadListingSchema
 }
 
 // FlattenedListing represents a flattened listing structure for ClickHouse
 type FlattenedListing struct {
 	// Primary identification
-	ID          string
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	LastScraped time.Time
-	SourceURL   string
+	ID          string    `json:"id"` // composite of SourceSite and SourceID, unique across sites
+	SourceSite  string    `json:"source_site"`
+	SourceID    string    `json:"source_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	LastScraped time.Time `json:"last_scraped"`
+	SourceURL   string    `json:"source_url"`
 
 	// Personal information
-	PersonalName       string
-	PersonalAge        uint8
-	PersonalHeight     uint16
-	PersonalWeight     uint16
-	PersonalBreastSize uint8
-	PersonalHairColor  string
-	PersonalEyeColor   string
-	PersonalBodyType   string
+	PersonalName        string `json:"personal_name"`
+	PersonalAge         uint8  `json:"personal_age"`
+	PersonalHeight      uint16 `json:"personal_height"`
+	PersonalWeight      uint16 `json:"personal_weight"`
+	PersonalBreastSize  uint8  `json:"personal_breast_size"`
+	PersonalHairColor   string `json:"personal_hair_color"`
+	PersonalEyeColor    string `json:"personal_eye_color"`
+	PersonalBodyType    string `json:"personal_body_type"`
+	PersonalGender      string `json:"personal_gender"`
+	PersonalOrientation string `json:"personal_orientation"`
 
 	// Contact information
-	ContactPhone    string
-	ContactTelegram string
-	ContactEmail    string
+	ContactPhone              string   `json:"contact_phone"`               // as written on the page
+	ContactPhoneNormalized    string   `json:"contact_phone_normalized"`    // E.164, empty when the phone is not a valid number
+	ContactTelegram           string   `json:"contact_telegram"`            // validated handle
+	ContactTelegramCandidates []string `json:"contact_telegram_candidates"` // raw handles found on the page
+	ContactTelegramConfidence float32  `json:"contact_telegram_confidence"`
+	ContactEmail              string   `json:"contact_email"`
 
 	// Pricing information
-	PricingCurrency string
+	PricingCurrency string `json:"pricing_currency"`
 
 	// Structured pricing - Apartments/Incall rates
-	PriceApartmentsDayHour    uint32
-	PriceApartmentsDay2Hour   uint32
-	PriceApartmentsNightHour  uint32
-	PriceApartmentsNight2Hour uint32
+	PriceApartmentsDayHour    uint32 `json:"price_apartments_day_hour"`
+	PriceApartmentsDay2Hour   uint32 `json:"price_apartments_day_2hour"`
+	PriceApartmentsNightHour  uint32 `json:"price_apartments_night_hour"`
+	PriceApartmentsNight2Hour uint32 `json:"price_apartments_night_2hour"`
 
 	// Structured pricing - Outcall rates
-	PriceOutcallDayHour    uint32
-	PriceOutcallDay2Hour   uint32
-	PriceOutcallNightHour  uint32
-	PriceOutcallNight2Hour uint32
+	PriceOutcallDayHour    uint32 `json:"price_outcall_day_hour"`
+	PriceOutcallDay2Hour   uint32 `json:"price_outcall_day_2hour"`
+	PriceOutcallNightHour  uint32 `json:"price_outcall_night_hour"`
+	PriceOutcallNight2Hour uint32 `json:"price_outcall_night_2hour"`
 
 	// Legacy/computed pricing fields for compatibility
-	PriceHour   uint32
-	Price2Hours uint32
-	PriceNight  uint32
-	PriceDay    uint32
-	PriceBase   uint32
+	PriceHour   uint32 `json:"price_hour"`
+	Price2Hours uint32 `json:"price_2_hours"`
+	PriceNight  uint32 `json:"price_night"`
+	PriceDay    uint32 `json:"price_day"`
+	PriceBase   uint32 `json:"price_base"`
 
 	// Additional pricing data (for any other price types)
-	PricingDurationPrices map[string]uint32
-	PricingServicePrices  map[string]uint32
+	PricingDurationPrices map[string]uint32 `json:"pricing_duration_prices"`
+	PricingServicePrices  map[string]uint32 `json:"pricing_service_prices"`
 
 	// Service information
-	ServiceAvailable    []string
-	ServiceAdditional   []string
-	ServiceRestrictions []string
-	ServiceMeetingType  string
+	ServiceAvailable    []string `json:"service_available"`
+	ServiceAdditional   []string `json:"service_additional"`
+	ServiceRestrictions []string `json:"service_restrictions"`
+	ServiceMeetingType  string   `json:"service_meeting_type"`
 
 	// Location information
-	LocationMetroStations    []string
-	LocationDistrict         string
-	LocationCity             string
-	LocationOutcallAvailable bool
-	LocationIncallAvailable  bool
+	LocationMetroStations      []string `json:"location_metro_stations"`
+	LocationDistrict           string   `json:"location_district"`
+	LocationCity               string   `json:"location_city"`
+	LocationOutcallAvailable   bool     `json:"location_outcall_available"`
+	LocationIncallAvailable    bool     `json:"location_incall_available"`
+	LocationAvailabilitySource string   `json:"location_availability_source"` // pricing_table or page_text
+	LocationServiceArea        []string `json:"location_service_area"`
+	LocationWorksInSalon       bool     `json:"location_works_in_salon"`
+	LocationSalonAddress       string   `json:"location_salon_address"`
 
 	// General information
-	Description string
-	LastUpdated string
-	Photos      []string
-	PhotosCount uint16
+	Description   string   `json:"description"`
+	DescriptionEn string   `json:"description_en"` // translation of Description, empty when translation is disabled
+	LastUpdated   string   `json:"last_updated"`
+	Photos        []string `json:"photos"`
+	PhotosCount   uint16   `json:"photos_count"`
+	Completeness  float32  `json:"completeness"` // fraction of key fields populated, see CompletenessScore
+	IsDeleted     bool     `json:"-"`            // soft-deleted versions are hidden from every read path
+	Status        string   `json:"status"`       // lifecycle status: StatusActive, StatusRemoved or StatusBanned
+
+	// AvailabilityStatus is read from the status banner of a listing that stays listed:
+	// AvailabilityActive, AvailabilityTemporarilyUnavailable or AvailabilityVacation
+	AvailabilityStatus string `json:"availability_status"`
+
+	// Parse quality: 1 minus the penalties of the quality flags, see ValidateListing
+	QualityScore float32  `json:"quality_score"`
+	QualityFlags []string `json:"quality_flags"`
 }
 
 // NewAdapter creates a new ClickHouse adapter
@@ -130,12 +201,9 @@ func NewAdapter(config Config) (*Adapter, error) {
 		Debug: config.Debug,
 		Debugf: func(format string, v ...interface{}) {
 			if config.Debug {
-				fmt.Printf("[ClickHouse Debug] "+format+"\n", v...)
+				log.Debug(fmt.Sprintf(format, v...))
 			}
 		},
-		Settings: clickhouse.Settings{
-			"max_execution_time": 60,
-		},
 		DialTimeout:      30 * time.Second,
 		MaxOpenConns:     maxConns,
 		MaxIdleConns:     maxConns / 2,
@@ -151,10 +219,57 @@ func NewAdapter(config Config) (*Adapter, error) {
 		return nil, fmt.Errorf("failed to ping ClickHouse: %w", err)
 	}
 
-	return &Adapter{
-		conn:   conn,
-		config: config,
-	}, nil
+	adapter := &Adapter{
+		conn:       conn,
+		config:     config,
+		exclusions: make(map[string]bool),
+	}
+
+	if config.AutoMigrate {
+		err := adapter.Migrate(context.Background())
+		switch {
+		case errors.Is(err, ErrUntrackedSchema):
+			log.Warn("Not migrating the ClickHouse schema, set CLICKHOUSE_MIGRATE_BASELINE to the last migration applied by hand", "error", err)
+		case err != nil:
+			return nil, fmt.Errorf("failed to migrate ClickHouse schema: %w", err)
+		}
+	}
+
+	if config.SchemaCompat {
+		if err := adapter.loadListingSchema(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := adapter.RefreshExclusions(context.Background()); err != nil {
+		log.Warn("Failed to load listing exclusions", "error", err)
+	}
+
+	return adapter, nil
+}
+
+// insertSettings returns the ClickHouse settings applied to INSERT queries
+func (a *Adapter) insertSettings() clickhouse.Settings {
+	settings := clickhouse.Settings{}
+
+	if a.config.AsyncInsert {
+		settings["async_insert"] = 1
+		if a.config.WaitForAsyncInsert {
+			settings["wait_for_async_insert"] = 1
+		} else {
+			settings["wait_for_async_insert"] = 0
+		}
+	}
+
+	if a.config.MaxInsertBlockSize > 0 {
+		settings["max_insert_block_size"] = a.config.MaxInsertBlockSize
+	}
+
+	if a.config.InsertQuorum > 0 {
+		settings["insert_quorum"] = a.config.InsertQuorum
+	}
+
+	return settings
 }
 
 // Close closes the ClickHouse connection
@@ -164,18 +279,31 @@ func (a *Adapter) Close() error {
 
 // FlattenListing converts a protobuf Listing to FlattenedListing
 func (a *Adapter) FlattenListing(listing *listing.Listing, sourceURL string) *FlattenedListing {
-	now := time.Now()
+	return Flatten(listing, sourceURL)
+}
+
+// Flatten converts a scraped listing into the listings table row scraped from sourceURL
+func Flatten(listing *listing.Listing, sourceURL string) *FlattenedListing {
+	now := clock.Now()
+
+	sourceSite := SourceSiteFromURL(sourceURL)
 
 	flattened := &FlattenedListing{
-		ID:          listing.Id,
-		CreatedAt:   now,
-		UpdatedAt:   now,
-		LastScraped: now,
-		SourceURL:   sourceURL,
-		Description: listing.Description,
-		LastUpdated: listing.LastUpdated,
-		Photos:      listing.Photos,
-		PhotosCount: uint16(len(listing.Photos)),
+		ID:            CompositeID(sourceSite, listing.Id),
+		SourceSite:    sourceSite,
+		SourceID:      listing.Id,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		LastScraped:   now,
+		SourceURL:     sourceURL,
+		Description:   listing.Description,
+		DescriptionEn: listing.DescriptionEn,
+		LastUpdated:   listing.LastUpdated,
+		Photos:        listing.Photos,
+		PhotosCount:   uint16(len(listing.Photos)),
+		Status:        StatusActive,
+
+		AvailabilityStatus: listing.AvailabilityStatus,
 	}
 
 	// Flatten personal info
@@ -188,12 +316,17 @@ func (a *Adapter) FlattenListing(listing *listing.Listing, sourceURL string) *Fl
 		flattened.PersonalHairColor = listing.PersonalInfo.HairColor
 		flattened.PersonalEyeColor = listing.PersonalInfo.EyeColor
 		flattened.PersonalBodyType = listing.PersonalInfo.BodyType
+		flattened.PersonalGender = listing.PersonalInfo.Gender
+		flattened.PersonalOrientation = listing.PersonalInfo.Orientation
 	}
 
 	// Flatten contact info
 	if listing.ContactInfo != nil {
 		flattened.ContactPhone = listing.ContactInfo.Phone
+		flattened.ContactPhoneNormalized = NormalizePhone(listing.ContactInfo.Phone)
 		flattened.ContactTelegram = listing.ContactInfo.Telegram
+		flattened.ContactTelegramCandidates = listing.ContactInfo.TelegramCandidates
+		flattened.ContactTelegramConfidence = listing.ContactInfo.TelegramConfidence
 		flattened.ContactEmail = listing.ContactInfo.Email
 	}
 
@@ -308,6 +441,10 @@ func (a *Adapter) FlattenListing(listing *listing.Listing, sourceURL string) *Fl
 		flattened.LocationCity = listing.LocationInfo.City
 		flattened.LocationOutcallAvailable = listing.LocationInfo.OutcallAvailable
 		flattened.LocationIncallAvailable = listing.LocationInfo.IncallAvailable
+		flattened.LocationAvailabilitySource = listing.LocationInfo.AvailabilitySource
+		flattened.LocationServiceArea = listing.LocationInfo.ServiceArea
+		flattened.LocationWorksInSalon = listing.LocationInfo.WorksInSalon
+		flattened.LocationSalonAddress = listing.LocationInfo.SalonAddress
 	}
 
 	// Set default city if empty
@@ -315,6 +452,14 @@ func (a *Adapter) FlattenListing(listing *listing.Listing, sourceURL string) *Fl
 		flattened.LocationCity = "Unknown"
 	}
 
+	// Parsers that do not read status banners leave the availability empty
+	if flattened.AvailabilityStatus == "" {
+		flattened.AvailabilityStatus = AvailabilityActive
+	}
+
+	flattened.Completeness = CompletenessScore(flattened)
+	flattened.setQuality(ValidateListing(flattened))
+
 	return flattened
 }
 
@@ -324,57 +469,23 @@ func (a *Adapter) InsertListing(ctx context.Context, listing *listing.Listing, s
 	return a.InsertFlattenedListing(ctx, flattened)
 }
 
-// InsertFlattenedListing inserts a flattened listing into ClickHouse
+// InsertFlattenedListing inserts a flattened listing into ClickHouse.
+// Listings on the exclusion list are rejected with ErrListingExcluded.
 func (a *Adapter) InsertFlattenedListing(ctx context.Context, flattened *FlattenedListing) error {
+	if !flattened.IsDeleted && a.IsExcluded(flattened.ID) {
+		return fmt.Errorf("%w: %s", ErrListingExcluded, flattened.ID)
+	}
+
 	query := `
-		INSERT INTO listings (
-			id, created_at, updated_at, last_scraped, source_url,
-			personal_name, personal_age, personal_height, personal_weight, personal_breast_size,
-			personal_hair_color, personal_eye_color, personal_body_type,
-			contact_phone, contact_telegram, contact_email,
-			pricing_currency,
-			price_apartments_day_hour, price_apartments_day_2hour, price_apartments_night_hour, price_apartments_night_2hour,
-			price_outcall_day_hour, price_outcall_day_2hour, price_outcall_night_hour, price_outcall_night_2hour,
-			price_hour, price_2_hours, price_night, price_day, price_base,
-			pricing_duration_prices, pricing_service_prices,
-			service_available, service_additional, service_restrictions, service_meeting_type,
-			location_metro_stations, location_district, location_city, 
-			location_outcall_available, location_incall_available,
-			description, last_updated, photos, photos_count
-		) VALUES (
-			?, ?, ?, ?, ?,
-			?, ?, ?, ?, ?,
-			?, ?, ?,
-			?, ?, ?,
-			?,
-			?, ?, ?, ?,
-			?, ?, ?, ?,
-			?, ?, ?, ?, ?,
-			?, ?,
-			?, ?, ?, ?,
-			?, ?, ?,
-			?, ?,
-			?, ?, ?, ?
-		)`
-
-	err := a.conn.Exec(ctx, query,
-		flattened.ID, flattened.CreatedAt, flattened.UpdatedAt, flattened.LastScraped, flattened.SourceURL,
-		flattened.PersonalName, flattened.PersonalAge, flattened.PersonalHeight, flattened.PersonalWeight, flattened.PersonalBreastSize,
-		flattened.PersonalHairColor, flattened.PersonalEyeColor, flattened.PersonalBodyType,
-		flattened.ContactPhone, flattened.ContactTelegram, flattened.ContactEmail,
-		flattened.PricingCurrency,
-		flattened.PriceApartmentsDayHour, flattened.PriceApartmentsDay2Hour, flattened.PriceApartmentsNightHour, flattened.PriceApartmentsNight2Hour,
-		flattened.PriceOutcallDayHour, flattened.PriceOutcallDay2Hour, flattened.PriceOutcallNightHour, flattened.PriceOutcallNight2Hour,
-		flattened.PriceHour, flattened.Price2Hours, flattened.PriceNight, flattened.PriceDay, flattened.PriceBase,
-		flattened.PricingDurationPrices, flattened.PricingServicePrices,
-		flattened.ServiceAvailable, flattened.ServiceAdditional, flattened.ServiceRestrictions, flattened.ServiceMeetingType,
-		flattened.LocationMetroStations, flattened.LocationDistrict, flattened.LocationCity,
-		flattened.LocationOutcallAvailable, flattened.LocationIncallAvailable,
-		flattened.Description, flattened.LastUpdated, flattened.Photos, flattened.PhotosCount,
-	)
+		INSERT INTO listings (` + a.schema().columns + `
+		) VALUES (` + a.schema().placeholders + `)`
 
+	ctx, cancel := a.begin(ctx, OperationInsert)
+	defer cancel()
+
+	err := a.conn.Exec(ctx, query, a.schema().values(flattened)...)
 	if err != nil {
-		return fmt.Errorf("failed to insert listing %s: %w", flattened.ID, err)
+		return fmt.Errorf("failed to insert listing %s: %w", flattened.ID, a.queryError(ctx, OperationInsert, err))
 	}
 
 	return nil
@@ -382,54 +493,41 @@ func (a *Adapter) InsertFlattenedListing(ctx context.Context, flattened *Flatten
 
 // BatchInsertListings inserts multiple listings in a batch
 func (a *Adapter) BatchInsertListings(ctx context.Context, listings []*listing.Listing, sourceURLs []string) error {
+	if len(sourceURLs) != len(listings) {
+		return fmt.Errorf("sourceURLs length (%d) must match listings length (%d)", len(sourceURLs), len(listings))
+	}
+
+	flattened := make([]*FlattenedListing, len(listings))
+	for i, listing := range listings {
+		flattened[i] = a.FlattenListing(listing, sourceURLs[i])
+	}
+	return a.BatchInsertFlattenedListings(ctx, flattened)
+}
+
+// BatchInsertFlattenedListings inserts flattened listings in one batch, skipping excluded ones
+func (a *Adapter) BatchInsertFlattenedListings(ctx context.Context, listings []*FlattenedListing) error {
 	if len(listings) == 0 {
 		return nil
 	}
 
-	if len(sourceURLs) != len(listings) {
-		return fmt.Errorf("sourceURLs length (%d) must match listings length (%d)", len(sourceURLs), len(listings))
-	}
+	ctx, cancel := a.begin(ctx, OperationInsert)
+	defer cancel()
 
 	batch, err := a.conn.PrepareBatch(ctx, `
-		INSERT INTO listings (
-			id, created_at, updated_at, last_scraped, source_url,
-			personal_name, personal_age, personal_height, personal_weight, personal_breast_size,
-			personal_hair_color, personal_eye_color, personal_body_type,
-			contact_phone, contact_telegram, contact_email,
-			pricing_currency,
-			price_apartments_day_hour, price_apartments_day_2hour, price_apartments_night_hour, price_apartments_night_2hour,
-			price_outcall_day_hour, price_outcall_day_2hour, price_outcall_night_hour, price_outcall_night_2hour,
-			price_hour, price_2_hours, price_night, price_day, price_base,
-			pricing_duration_prices, pricing_service_prices,
-			service_available, service_additional, service_restrictions, service_meeting_type,
-			location_metro_stations, location_district, location_city, 
-			location_outcall_available, location_incall_available,
-			description, last_updated, photos, photos_count
+		INSERT INTO listings (`+a.schema().columns+`
 		)
 	`)
 
 	if err != nil {
-		return fmt.Errorf("failed to prepare batch: %w", err)
+		return fmt.Errorf("failed to prepare batch: %w", a.queryError(ctx, OperationInsert, err))
 	}
 
-	for i, listing := range listings {
-		flattened := a.FlattenListing(listing, sourceURLs[i])
-
-		err := batch.Append(
-			flattened.ID, flattened.CreatedAt, flattened.UpdatedAt, flattened.LastScraped, flattened.SourceURL,
-			flattened.PersonalName, flattened.PersonalAge, flattened.PersonalHeight, flattened.PersonalWeight, flattened.PersonalBreastSize,
-			flattened.PersonalHairColor, flattened.PersonalEyeColor, flattened.PersonalBodyType,
-			flattened.ContactPhone, flattened.ContactTelegram, flattened.ContactEmail,
-			flattened.PricingCurrency,
-			flattened.PriceApartmentsDayHour, flattened.PriceApartmentsDay2Hour, flattened.PriceApartmentsNightHour, flattened.PriceApartmentsNight2Hour,
-			flattened.PriceOutcallDayHour, flattened.PriceOutcallDay2Hour, flattened.PriceOutcallNightHour, flattened.PriceOutcallNight2Hour,
-			flattened.PriceHour, flattened.Price2Hours, flattened.PriceNight, flattened.PriceDay, flattened.PriceBase,
-			flattened.PricingDurationPrices, flattened.PricingServicePrices,
-			flattened.ServiceAvailable, flattened.ServiceAdditional, flattened.ServiceRestrictions, flattened.ServiceMeetingType,
-			flattened.LocationMetroStations, flattened.LocationDistrict, flattened.LocationCity,
-			flattened.LocationOutcallAvailable, flattened.LocationIncallAvailable,
-			flattened.Description, flattened.LastUpdated, flattened.Photos, flattened.PhotosCount,
-		)
+	for _, flattened := range listings {
+		if !flattened.IsDeleted && a.IsExcluded(flattened.ID) {
+			continue
+		}
+
+		err := batch.Append(a.schema().values(flattened)...)
 
 		if err != nil {
 			return fmt.Errorf("failed to append listing %s to batch: %w", flattened.ID, err)
@@ -438,7 +536,7 @@ func (a *Adapter) BatchInsertListings(ctx context.Context, listings []*listing.L
 
 	err = batch.Send()
 	if err != nil {
-		return fmt.Errorf("failed to send batch: %w", err)
+		return fmt.Errorf("failed to send batch: %w", a.queryError(ctx, OperationInsert, err))
 	}
 
 	return nil
@@ -447,20 +545,20 @@ func (a *Adapter) BatchInsertListings(ctx context.Context, listings []*listing.L
 // UpdateListing updates an existing listing or inserts if not exists
 func (a *Adapter) UpdateListing(ctx context.Context, listing *listing.Listing, sourceURL string) error {
 	flattened := a.FlattenListing(listing, sourceURL)
-	flattened.UpdatedAt = time.Now()
+	flattened.UpdatedAt = clock.Now()
 
 	// ClickHouse ReplacingMergeTree will automatically handle updates based on the sorting key
 	return a.InsertFlattenedListing(ctx, flattened)
 }
 
-// GetListingByID retrieves a listing by ID
-func (a *Adapter) GetListingByID(ctx context.Context, id string) (*FlattenedListing, error) {
-	query := `
-		SELECT 
-			id, created_at, updated_at, last_scraped, source_url,
+// listingColumns is every column of listing INSERT and SELECT queries, in FlattenedListing field order;
+// the queries use the columns of the adapter's listingSchema
+const listingColumns = `
+			id, source_site, source_id, created_at, updated_at, last_scraped, source_url,
 			personal_name, personal_age, personal_height, personal_weight, personal_breast_size,
 			personal_hair_color, personal_eye_color, personal_body_type,
-			contact_phone, contact_telegram, contact_email,
+			personal_gender, personal_orientation,
+			contact_phone, contact_phone_normalized, contact_telegram, contact_telegram_candidates, contact_telegram_confidence, contact_email,
 			pricing_currency,
 			price_apartments_day_hour, price_apartments_day_2hour, price_apartments_night_hour, price_apartments_night_2hour,
 			price_outcall_day_hour, price_outcall_day_2hour, price_outcall_night_hour, price_outcall_night_2hour,
@@ -468,45 +566,160 @@ func (a *Adapter) GetListingByID(ctx context.Context, id string) (*FlattenedList
 			pricing_duration_prices, pricing_service_prices,
 			service_available, service_additional, service_restrictions, service_meeting_type,
 			location_metro_stations, location_district, location_city,
-			location_outcall_available, location_incall_available,
-			description, last_updated, photos, photos_count
+			location_outcall_available, location_incall_available, location_availability_source,
+			location_service_area, location_works_in_salon, location_salon_address,
+			description, description_en, last_updated, photos, photos_count, completeness, is_deleted, status,
+			availability_status, quality_score, quality_flags`
+
+// rowScanner is implemented by both driver.Row and driver.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// pointers returns pointers to the listing fields in listingColumns order for scanning rows
+func (f *FlattenedListing) pointers() []any {
+	return []any{
+		&f.ID, &f.SourceSite, &f.SourceID, &f.CreatedAt, &f.UpdatedAt, &f.LastScraped, &f.SourceURL,
+		&f.PersonalName, &f.PersonalAge, &f.PersonalHeight, &f.PersonalWeight, &f.PersonalBreastSize,
+		&f.PersonalHairColor, &f.PersonalEyeColor, &f.PersonalBodyType,
+		&f.PersonalGender, &f.PersonalOrientation,
+		&f.ContactPhone, &f.ContactPhoneNormalized, &f.ContactTelegram, &f.ContactTelegramCandidates, &f.ContactTelegramConfidence, &f.ContactEmail,
+		&f.PricingCurrency,
+		&f.PriceApartmentsDayHour, &f.PriceApartmentsDay2Hour, &f.PriceApartmentsNightHour, &f.PriceApartmentsNight2Hour,
+		&f.PriceOutcallDayHour, &f.PriceOutcallDay2Hour, &f.PriceOutcallNightHour, &f.PriceOutcallNight2Hour,
+		&f.PriceHour, &f.Price2Hours, &f.PriceNight, &f.PriceDay, &f.PriceBase,
+		&f.PricingDurationPrices, &f.PricingServicePrices,
+		&f.ServiceAvailable, &f.ServiceAdditional, &f.ServiceRestrictions, &f.ServiceMeetingType,
+		&f.LocationMetroStations, &f.LocationDistrict, &f.LocationCity,
+		&f.LocationOutcallAvailable, &f.LocationIncallAvailable, &f.LocationAvailabilitySource,
+		&f.LocationServiceArea, &f.LocationWorksInSalon, &f.LocationSalonAddress,
+		&f.Description, &f.DescriptionEn, &f.LastUpdated, &f.Photos, &f.PhotosCount, &f.Completeness, &f.IsDeleted, &f.Status,
+		&f.AvailabilityStatus, &f.QualityScore, &f.QualityFlags,
+	}
+}
+
+// values returns the listing fields in listingColumns order for INSERT statements
+func (f *FlattenedListing) values() []any {
+	return []any{
+		f.ID, f.SourceSite, f.SourceID, f.CreatedAt, f.UpdatedAt, f.LastScraped, f.SourceURL,
+		f.PersonalName, f.PersonalAge, f.PersonalHeight, f.PersonalWeight, f.PersonalBreastSize,
+		f.PersonalHairColor, f.PersonalEyeColor, f.PersonalBodyType,
+		f.PersonalGender, f.PersonalOrientation,
+		f.ContactPhone, f.ContactPhoneNormalized, f.ContactTelegram, f.ContactTelegramCandidates, f.ContactTelegramConfidence, f.ContactEmail,
+		f.PricingCurrency,
+		f.PriceApartmentsDayHour, f.PriceApartmentsDay2Hour, f.PriceApartmentsNightHour, f.PriceApartmentsNight2Hour,
+		f.PriceOutcallDayHour, f.PriceOutcallDay2Hour, f.PriceOutcallNightHour, f.PriceOutcallNight2Hour,
+		f.PriceHour, f.Price2Hours, f.PriceNight, f.PriceDay, f.PriceBase,
+		f.PricingDurationPrices, f.PricingServicePrices,
+		f.ServiceAvailable, f.ServiceAdditional, f.ServiceRestrictions, f.ServiceMeetingType,
+		f.LocationMetroStations, f.LocationDistrict, f.LocationCity,
+		f.LocationOutcallAvailable, f.LocationIncallAvailable, f.LocationAvailabilitySource,
+		f.LocationServiceArea, f.LocationWorksInSalon, f.LocationSalonAddress,
+		f.Description, f.DescriptionEn, f.LastUpdated, f.Photos, f.PhotosCount, f.Completeness, f.IsDeleted, f.Status,
+		f.AvailabilityStatus, f.QualityScore, f.QualityFlags,
+	}
+}
+
+// ErrListingNotFound is returned when a listing does not exist or is outside the reader's scope
+var ErrListingNotFound = errors.New("listing not found")
+
+// GetListingByID retrieves a listing by ID
+func (a *Adapter) GetListingByID(ctx context.Context, id string) (*FlattenedListing, error) {
+	return a.getListing(ctx, id, Scope{})
+}
+
+// getListing retrieves the latest version of a listing visible in scope, hiding soft-deleted listings
+func (a *Adapter) getListing(ctx context.Context, id string, scope Scope) (*FlattenedListing, error) {
+	flattened, err := a.latestVersion(ctx, id, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	if flattened.IsDeleted {
+		return nil, fmt.Errorf("%w: %s", ErrListingNotFound, id)
+	}
+
+	scope.Apply(flattened)
+	return flattened, nil
+}
+
+// latestVersion retrieves the latest stored version of a listing in scope, including soft-deleted ones
+func (a *Adapter) latestVersion(ctx context.Context, id string, scope Scope) (*FlattenedListing, error) {
+	where, args := scope.where("id = ?", id)
+	query := `
+		SELECT ` + a.schema().columns + `
 		FROM listings 
-		WHERE id = ? 
+		` + where + ` 
 		ORDER BY updated_at DESC 
 		LIMIT 1
 	`
 
-	row := a.conn.QueryRow(ctx, query, id)
+	ctx, cancel := a.begin(ctx, OperationQuery)
+	defer cancel()
 
-	var flattened FlattenedListing
-	err := row.Scan(
-		&flattened.ID, &flattened.CreatedAt, &flattened.UpdatedAt, &flattened.LastScraped, &flattened.SourceURL,
-		&flattened.PersonalName, &flattened.PersonalAge, &flattened.PersonalHeight, &flattened.PersonalWeight, &flattened.PersonalBreastSize,
-		&flattened.PersonalHairColor, &flattened.PersonalEyeColor, &flattened.PersonalBodyType,
-		&flattened.ContactPhone, &flattened.ContactTelegram, &flattened.ContactEmail,
-		&flattened.PricingCurrency,
-		&flattened.PriceApartmentsDayHour, &flattened.PriceApartmentsDay2Hour, &flattened.PriceApartmentsNightHour, &flattened.PriceApartmentsNight2Hour,
-		&flattened.PriceOutcallDayHour, &flattened.PriceOutcallDay2Hour, &flattened.PriceOutcallNightHour, &flattened.PriceOutcallNight2Hour,
-		&flattened.PriceHour, &flattened.Price2Hours, &flattened.PriceNight, &flattened.PriceDay, &flattened.PriceBase,
-		&flattened.PricingDurationPrices, &flattened.PricingServicePrices,
-		&flattened.ServiceAvailable, &flattened.ServiceAdditional, &flattened.ServiceRestrictions, &flattened.ServiceMeetingType,
-		&flattened.LocationMetroStations, &flattened.LocationDistrict, &flattened.LocationCity,
-		&flattened.LocationOutcallAvailable, &flattened.LocationIncallAvailable,
-		&flattened.Description, &flattened.LastUpdated, &flattened.Photos, &flattened.PhotosCount,
-	)
+	row := a.conn.QueryRow(ctx, query, args...)
 
+	flattened, err := a.schema().scan(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("listing with ID %s not found", id)
+			return nil, fmt.Errorf("%w: %s", ErrListingNotFound, id)
 		}
-		return nil, fmt.Errorf("failed to get listing %s: %w", id, err)
+		return nil, fmt.Errorf("failed to get listing %s: %w", id, a.queryError(ctx, OperationQuery, err))
 	}
 
-	return &flattened, nil
+	return flattened, nil
+}
+
+// GetListingBySource retrieves the latest version of a listing by its source site and source-local ID
+func (a *Adapter) GetListingBySource(ctx context.Context, sourceSite, sourceID string) (*FlattenedListing, error) {
+	return a.GetListingByID(ctx, CompositeID(sourceSite, sourceID))
+}
+
+// GetListingsWithoutPhotos returns up to limit latest listing versions that have no photos stored
+func (a *Adapter) GetListingsWithoutPhotos(ctx context.Context, limit int) ([]*FlattenedListing, error) {
+	query := `
+		SELECT ` + a.schema().columns + `
+		FROM listings
+		FINAL
+		WHERE photos_count = 0 AND ` + a.schema().live() + `
+		ORDER BY last_scraped DESC
+		LIMIT ?
+	`
+
+	ctx, cancel := a.begin(ctx, OperationAnalytics)
+	defer cancel()
+
+	rows, err := a.conn.Query(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query listings without photos: %w", a.queryError(ctx, OperationAnalytics, err))
+	}
+	defer rows.Close()
+
+	var listings []*FlattenedListing
+	for rows.Next() {
+		flattened, err := a.schema().scan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan listing: %w", err)
+		}
+		listings = append(listings, flattened)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate listings without photos: %w", a.queryError(ctx, OperationAnalytics, err))
+	}
+
+	return listings, nil
 }
 
 // GetStats returns basic statistics about listings in the database
 func (a *Adapter) GetStats(ctx context.Context) (map[string]interface{}, error) {
+	return a.getStats(ctx, Scope{})
+}
+
+// getStats returns statistics over the listings visible in scope
+func (a *Adapter) getStats(ctx context.Context, scope Scope) (map[string]interface{}, error) {
+	schema := a.schema()
+	where, args := scope.where(schema.live())
 	query := `
 		SELECT 
 			count() as total_listings,
@@ -516,12 +729,20 @@ func (a *Adapter) GetStats(ctx context.Context) (map[string]interface{}, error)
 			countIf(length(photos) > 0) as listings_with_photos,
 			avg(personal_age) as avg_age,
 			avg(price_hour) as avg_price_hour,
-			uniqExact(location_city) as unique_cities
+			uniqExact(location_city) as unique_cities,
+			uniqExact(source_site) as unique_sites,
+			avg(` + schema.column("completeness") + `) as avg_completeness,
+			quantiles(0.1, 0.25, 0.5, 0.75, 0.9)(toFloat64(` + schema.column("completeness") + `)) as completeness_quantiles,
+			avg(` + schema.column("quality_score") + `) as avg_quality_score
 		FROM listings
 		FINAL
+		` + where + `
 	`
 
-	row := a.conn.QueryRow(ctx, query)
+	ctx, cancel := a.begin(ctx, OperationAnalytics)
+	defer cancel()
+
+	row := a.conn.QueryRow(ctx, query, args...)
 
 	var stats struct {
 		TotalListings      uint64
@@ -532,6 +753,10 @@ func (a *Adapter) GetStats(ctx context.Context) (map[string]interface{}, error)
 		AvgAge             float64
 		AvgPriceHour       float64
 		UniqueCities       uint64
+		UniqueSites        uint64
+		AvgCompleteness    float64
+		CompletenessQs     []float64
+		AvgQualityScore    float64
 	}
 
 	err := row.Scan(
@@ -543,10 +768,14 @@ func (a *Adapter) GetStats(ctx context.Context) (map[string]interface{}, error)
 		&stats.AvgAge,
 		&stats.AvgPriceHour,
 		&stats.UniqueCities,
+		&stats.UniqueSites,
+		&stats.AvgCompleteness,
+		&stats.CompletenessQs,
+		&stats.AvgQualityScore,
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to get stats: %w", err)
+		return nil, fmt.Errorf("failed to get stats: %w", a.queryError(ctx, OperationAnalytics, err))
 	}
 
 	result := map[string]interface{}{
@@ -558,11 +787,85 @@ func (a *Adapter) GetStats(ctx context.Context) (map[string]interface{}, error)
 		"avg_age":              stats.AvgAge,
 		"avg_price_hour":       stats.AvgPriceHour,
 		"unique_cities":        stats.UniqueCities,
+		"unique_sites":         stats.UniqueSites,
+		"avg_completeness":     stats.AvgCompleteness,
+		"avg_quality_score":    stats.AvgQualityScore,
+	}
+
+	// Completeness percentiles, in the order requested from quantiles()
+	for i, name := range []string{"completeness_p10", "completeness_p25", "completeness_p50", "completeness_p75", "completeness_p90"} {
+		if i < len(stats.CompletenessQs) {
+			result[name] = stats.CompletenessQs[i]
+		}
 	}
 
+	byStatus, err := a.countByStatus(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+	result["listings_by_status"] = byStatus
+
+	byFlag, err := a.countByQualityFlag(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+	result["listings_by_quality_flag"] = byFlag
+
 	return result, nil
 }
 
+// countByStatus counts the listings visible in scope by ReportedStatus, including the
+// soft-deleted ones the other statistics leave out. Every status is reported, 0 when unused.
+// Rows without a status, or a table without the column, count by is_deleted like statusOf.
+func (a *Adapter) countByStatus(ctx context.Context, scope Scope) (map[string]uint64, error) {
+	deleted := a.schema().column("is_deleted")
+	statusExpr := "if(status = '', if(" + deleted + ", ?, ?), status)"
+	if a.schema().missing["status"] {
+		statusExpr = "if(" + deleted + ", ?, ?)"
+	}
+	availabilityExpr := "availability_status"
+	if a.schema().missing["availability_status"] {
+		availabilityExpr = "''"
+	}
+
+	where, args := scope.where("")
+	query := `
+		SELECT ` + statusExpr + ` AS listing_status, ` + availabilityExpr + ` AS availability, count()
+		FROM listings
+		FINAL
+		` + where + `
+		GROUP BY listing_status, availability
+	`
+
+	ctx, cancel := a.begin(ctx, OperationAnalytics)
+	defer cancel()
+
+	rows, err := a.conn.Query(ctx, query, append([]any{StatusRemoved, StatusActive}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count listings by status: %w", a.queryError(ctx, OperationAnalytics, err))
+	}
+	defer rows.Close()
+
+	counts := make(map[string]uint64, len(ReportedStatuses))
+	for _, status := range ReportedStatuses {
+		counts[status] = 0
+	}
+	for rows.Next() {
+		var status, availability string
+		var count uint64
+		if err := rows.Scan(&status, &availability, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan status count: %w", err)
+		}
+		counts[ReportedStatus(status, availability)] += count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate status counts: %w", a.queryError(ctx, OperationAnalytics, err))
+	}
+
+	return counts, nil
+}
+
 // LogChange logs a change to the listing_changes table
 func (a *Adapter) LogChange(ctx context.Context, listingID, changeType, oldValue, newValue, fieldName, source string) error {
 	query := `
@@ -570,9 +873,12 @@ func (a *Adapter) LogChange(ctx context.Context, listingID, changeType, oldValue
 		VALUES (?, ?, ?, ?, ?, ?)
 	`
 
+	ctx, cancel := a.begin(ctx, OperationInsert)
+	defer cancel()
+
 	err := a.conn.Exec(ctx, query, listingID, changeType, oldValue, newValue, fieldName, source)
 	if err != nil {
-		return fmt.Errorf("failed to log change for listing %s: %w", listingID, err)
+		return fmt.Errorf("failed to log change for listing %s: %w", listingID, a.queryError(ctx, OperationInsert, err))
 	}
 
 	return nil
@@ -0,0 +1,196 @@
+package clickhouse
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+	listing "github.com/gregor-tokarev/hoe_parser/proto"
+)
+
+func TestListingColumnsMatchValues(t *testing.T) {
+	columns := strings.Split(listingColumns, ",")
+	values := (&FlattenedListing{}).values()
+
+	if len(columns) != len(values) {
+		t.Errorf("Expected %d values to match listingColumns, got %d", len(columns), len(values))
+	}
+
+	if pointers := (&FlattenedListing{}).pointers(); len(pointers) != len(columns) {
+		t.Errorf("Expected %d pointers to match listingColumns, got %d", len(columns), len(pointers))
+	}
+
+	placeholders := strings.Split(fullListingSchema.placeholders, ",")
+	if len(placeholders) != len(columns) {
+		t.Errorf("Expected %d placeholders, got %d", len(columns), len(placeholders))
+	}
+}
+
+func TestFlattenListingPricing(t *testing.T) {
+	adapter := &Adapter{}
+
+	flattened := adapter.FlattenListing(&listing.Listing{
+		Id: "123",
+		PricingInfo: &listing.PricingInfo{
+			DurationPrices: map[string]int32{
+				"apartments_day_hour": 0,
+				"outcall_day_hour":    7000,
+				"outcall_day_2hour":   12000,
+			},
+		},
+	}, "https://example.com/anketa123.htm")
+
+	if flattened.PriceHour != 7000 {
+		t.Errorf("Expected price_hour to fall back to outcall rate 7000, got %d", flattened.PriceHour)
+	}
+
+	if flattened.Price2Hours != 12000 {
+		t.Errorf("Expected price_2_hours 12000, got %d", flattened.Price2Hours)
+	}
+
+	if flattened.PricingCurrency != "RUB" {
+		t.Errorf("Expected default currency RUB, got %s", flattened.PricingCurrency)
+	}
+
+	if flattened.LocationCity != "Unknown" {
+		t.Errorf("Expected default city Unknown, got %s", flattened.LocationCity)
+	}
+
+	if flattened.Status != StatusActive {
+		t.Errorf("Expected a scraped listing to be active, got %s", flattened.Status)
+	}
+
+	if flattened.AvailabilityStatus != AvailabilityActive {
+		t.Errorf("Expected a listing without a status banner to be available, got %s", flattened.AvailabilityStatus)
+	}
+}
+
+func TestStatusOf(t *testing.T) {
+	tests := []struct {
+		listing  FlattenedListing
+		expected string
+	}{
+		{FlattenedListing{Status: StatusBanned, IsDeleted: true}, StatusBanned},
+		{FlattenedListing{IsDeleted: true}, StatusRemoved},
+		{FlattenedListing{}, StatusActive},
+	}
+
+	for _, tt := range tests {
+		if status := statusOf(&tt.listing); status != tt.expected {
+			t.Errorf("Expected status %s for %+v, got %s", tt.expected, tt.listing, status)
+		}
+	}
+}
+
+func TestReportedStatus(t *testing.T) {
+	tests := []struct {
+		status, availability, expected string
+	}{
+		{StatusActive, AvailabilityActive, StatusActive},
+		{StatusActive, "", StatusActive},
+		{StatusActive, AvailabilityTemporarilyUnavailable, AvailabilityTemporarilyUnavailable},
+		{StatusActive, AvailabilityVacation, AvailabilityVacation},
+		{StatusRemoved, AvailabilityVacation, StatusRemoved},
+	}
+
+	for _, tt := range tests {
+		if reported := ReportedStatus(tt.status, tt.availability); reported != tt.expected {
+			t.Errorf("Expected %s for %s/%s, got %s", tt.expected, tt.status, tt.availability, reported)
+		}
+	}
+}
+
+func TestFlattenTimestampsAreUTC(t *testing.T) {
+	scrapedAt := time.Date(2024, 3, 1, 2, 30, 0, 0, time.FixedZone("MSK", 3*60*60))
+	defer clock.SetClock(clock.NewFixed(scrapedAt))()
+
+	flattened := Flatten(&listing.Listing{Id: "123"}, "https://example.com/anketa123.htm")
+
+	for name, at := range map[string]time.Time{
+		"created_at":   flattened.CreatedAt,
+		"updated_at":   flattened.UpdatedAt,
+		"last_scraped": flattened.LastScraped,
+	} {
+		if !at.Equal(scrapedAt) || at.Location() != time.UTC {
+			t.Errorf("Expected %s to be %v in UTC, got %v", name, scrapedAt, at)
+		}
+	}
+}
+
+func TestInsertSettings(t *testing.T) {
+	adapter := &Adapter{}
+	if settings := adapter.insertSettings(); len(settings) != 0 {
+		t.Errorf("Expected no insert settings by default, got %v", settings)
+	}
+
+	adapter = &Adapter{config: Config{
+		AsyncInsert:        true,
+		WaitForAsyncInsert: false,
+		MaxInsertBlockSize: 100000,
+		InsertQuorum:       2,
+	}}
+
+	settings := adapter.insertSettings()
+	if settings["async_insert"] != 1 {
+		t.Errorf("Expected async_insert=1, got %v", settings["async_insert"])
+	}
+	if settings["wait_for_async_insert"] != 0 {
+		t.Errorf("Expected wait_for_async_insert=0, got %v", settings["wait_for_async_insert"])
+	}
+	if settings["max_insert_block_size"] != uint64(100000) {
+		t.Errorf("Expected max_insert_block_size=100000, got %v", settings["max_insert_block_size"])
+	}
+	if settings["insert_quorum"] != 2 {
+		t.Errorf("Expected insert_quorum=2, got %v", settings["insert_quorum"])
+	}
+}
+
+func TestCompositeListingID(t *testing.T) {
+	adapter := &Adapter{}
+
+	first := adapter.FlattenListing(&listing.Listing{Id: "12345"}, "https://b.intimcity.gold/anketa12345.htm")
+	second := adapter.FlattenListing(&listing.Listing{Id: "12345"}, "https://www.other-site.com/profile12345")
+
+	if first.ID == second.ID {
+		t.Errorf("Expected listings from different sites to get different IDs, both got %s", first.ID)
+	}
+
+	if first.ID != "intimcity.gold:12345" {
+		t.Errorf("Expected composite ID intimcity.gold:12345, got %s", first.ID)
+	}
+
+	if first.SourceSite != "intimcity.gold" || first.SourceID != "12345" {
+		t.Errorf("Expected source intimcity.gold/12345, got %s/%s", first.SourceSite, first.SourceID)
+	}
+
+	site, id := SplitCompositeID(first.ID)
+	if site != "intimcity.gold" || id != "12345" {
+		t.Errorf("Expected SplitCompositeID to return intimcity.gold/12345, got %s/%s", site, id)
+	}
+}
+
+func TestCompletenessScore(t *testing.T) {
+	adapter := &Adapter{}
+
+	empty := adapter.FlattenListing(&listing.Listing{Id: "1"}, "https://example.com/anketa1.htm")
+	if empty.Completeness != 0 {
+		t.Errorf("Expected completeness 0 for an empty listing, got %v", empty.Completeness)
+	}
+
+	partial := adapter.FlattenListing(&listing.Listing{
+		Id:           "2",
+		PersonalInfo: &listing.PersonalInfo{Age: 25},
+		ContactInfo:  &listing.ContactInfo{Phone: "+79990000000"},
+		PricingInfo:  &listing.PricingInfo{DurationPrices: map[string]int32{"outcall_night_hour": 20000}},
+	}, "https://example.com/anketa2.htm")
+	if partial.Completeness != 0.6 {
+		t.Errorf("Expected completeness 0.6 for age, price and phone, got %v", partial.Completeness)
+	}
+
+	partial.Photos = []string{"a.jpg"}
+	partial.LocationMetroStations = []string{"Арбатская"}
+	if score := CompletenessScore(partial); score != 1 {
+		t.Errorf("Expected completeness 1 with every key field, got %v", score)
+	}
+}
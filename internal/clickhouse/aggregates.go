@@ -0,0 +1,216 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Groupings of the aggregate statistics
+const (
+	AggregateByCity     = "city"
+	AggregateByMetro    = "metro" // a listing counts once for every station it lists
+	AggregateByDistrict = "district"
+	AggregateByDate     = "date" // by the time a listing was first stored
+)
+
+// Date intervals of AggregateByDate
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week" // weeks start on Monday
+	IntervalMonth = "month"
+)
+
+// aggregateKeys maps groupings and date intervals to the expression of the bucket key. Dates are
+// UTC days, whatever the time zone of the ClickHouse server.
+var aggregateKeys = map[string]string{
+	AggregateByCity:                       "location_city",
+	AggregateByMetro:                      "arrayJoin(location_metro_stations)",
+	AggregateByDistrict:                   "location_district",
+	AggregateByDate + "/" + IntervalDay:   "toString(toDate(created_at, 'UTC'))",
+	AggregateByDate + "/" + IntervalWeek:  "toString(toMonday(created_at, 'UTC'))",
+	AggregateByDate + "/" + IntervalMonth: "toString(toStartOfMonth(created_at, 'UTC'))",
+}
+
+// AggregateQuery selects the listings and the buckets of aggregate statistics
+type AggregateQuery struct {
+	GroupBy  string    // AggregateByCity, AggregateByMetro, AggregateByDistrict or AggregateByDate
+	Interval string    // bucket width of AggregateByDate, IntervalDay when empty
+	City     string    // only listings in this city, empty means all
+	Since    time.Time // only listings first stored at or after this time, zero means no limit
+	Until    time.Time // only listings first stored before this time, zero means no limit
+
+	// MinBucket is the k of k-anonymity: buckets with fewer listings are left out, and price
+	// distributions and average ages over fewer listings stating them are not reported
+	MinBucket int
+}
+
+// keyExpression returns the SQL expression of the bucket key of the query
+func (q AggregateQuery) keyExpression() (string, error) {
+	key := q.GroupBy
+	if q.GroupBy == AggregateByDate {
+		interval := q.Interval
+		if interval == "" {
+			interval = IntervalDay
+		}
+		key += "/" + interval
+	} else if q.Interval != "" {
+		return "", fmt.Errorf("interval only applies to grouping by %s", AggregateByDate)
+	}
+
+	expression, ok := aggregateKeys[key]
+	if !ok {
+		return "", fmt.Errorf("unknown aggregate grouping %q", strings.TrimSuffix(key, "/"))
+	}
+	return expression, nil
+}
+
+// PriceDistribution summarizes the hourly prices of the listings in a bucket. It has no minimum
+// or maximum, since those are the prices of single listings.
+type PriceDistribution struct {
+	Listings uint64  `json:"listings"` // listings with an hourly price
+	Avg      float64 `json:"avg"`
+	P10      float64 `json:"p10"`
+	P25      float64 `json:"p25"`
+	Median   float64 `json:"median"`
+	P75      float64 `json:"p75"`
+	P90      float64 `json:"p90"`
+}
+
+// AgeSummary is the average age of the listings in a bucket that state one
+type AgeSummary struct {
+	Listings uint64  `json:"listings"` // listings with an age
+	Avg      float64 `json:"avg"`
+}
+
+// AggregateBucket holds the statistics of the listings sharing a key
+type AggregateBucket struct {
+	Key       string             `json:"key"`
+	Listings  uint64             `json:"listings"`
+	PriceHour *PriceDistribution `json:"price_hour,omitempty"` // nil when too few listings have a price
+	Age       *AgeSummary        `json:"age,omitempty"`        // nil when too few listings state an age
+}
+
+// AggregateReport is the k-anonymous result of an aggregate query
+type AggregateReport struct {
+	GroupBy           string            `json:"group_by"`
+	Interval          string            `json:"interval,omitempty"`
+	MinBucket         int               `json:"min_bucket"`
+	Buckets           []AggregateBucket `json:"buckets"`
+	SuppressedBuckets int               `json:"suppressed_buckets"` // buckets left out for having too few listings
+}
+
+// AggregateListings returns per-bucket listing counts, hourly price distributions and average ages
+func (a *Adapter) AggregateListings(ctx context.Context, query AggregateQuery) (*AggregateReport, error) {
+	return a.aggregateListings(ctx, query, Scope{})
+}
+
+// AggregateListings returns the aggregate statistics of the listings visible in the scope
+func (s *ScopedAdapter) AggregateListings(ctx context.Context, query AggregateQuery) (*AggregateReport, error) {
+	return s.adapter.aggregateListings(ctx, query, s.scope)
+}
+
+// aggregateListings returns the aggregate statistics of the listings visible in scope
+func (a *Adapter) aggregateListings(ctx context.Context, query AggregateQuery, scope Scope) (*AggregateReport, error) {
+	key, err := query.keyExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := []string{a.schema().live()}
+	var args []any
+	if query.City != "" {
+		conditions = append(conditions, "location_city = ?")
+		args = append(args, query.City)
+	}
+	if !query.Since.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, query.Since)
+	}
+	if !query.Until.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, query.Until)
+	}
+	where, args := scope.where(strings.Join(conditions, " AND "), args...)
+
+	sql := `
+		SELECT
+			key,
+			count() AS listings,
+			countIf(price_hour > 0) AS priced,
+			avgIf(price_hour, price_hour > 0) AS avg_price,
+			quantilesIf(0.1, 0.25, 0.5, 0.75, 0.9)(toFloat64(price_hour), price_hour > 0) AS price_quantiles,
+			countIf(personal_age > 0) AS aged,
+			avgIf(personal_age, personal_age > 0) AS avg_age
+		FROM (
+			SELECT ` + key + ` AS key, price_hour, personal_age
+			FROM listings
+			FINAL
+			` + where + `
+		)
+		WHERE key != ''
+		GROUP BY key
+		ORDER BY key
+	`
+
+	ctx, cancel := a.begin(ctx, OperationAnalytics)
+	defer cancel()
+
+	rows, err := a.conn.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query aggregates: %w", a.queryError(ctx, OperationAnalytics, err))
+	}
+	defer rows.Close()
+
+	var buckets []AggregateBucket
+	for rows.Next() {
+		var bucket AggregateBucket
+		var price PriceDistribution
+		var age AgeSummary
+		var quantiles []float64
+		if err := rows.Scan(&bucket.Key, &bucket.Listings, &price.Listings, &price.Avg, &quantiles, &age.Listings, &age.Avg); err != nil {
+			return nil, fmt.Errorf("failed to scan aggregate bucket: %w", err)
+		}
+		if len(quantiles) == 5 {
+			price.P10, price.P25, price.Median, price.P75, price.P90 = quantiles[0], quantiles[1], quantiles[2], quantiles[3], quantiles[4]
+		}
+		bucket.PriceHour, bucket.Age = &price, &age
+		buckets = append(buckets, bucket)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read aggregate buckets: %w", a.queryError(ctx, OperationAnalytics, err))
+	}
+
+	report := &AggregateReport{GroupBy: query.GroupBy, MinBucket: query.MinBucket}
+	if query.GroupBy == AggregateByDate {
+		report.Interval = query.Interval
+		if report.Interval == "" {
+			report.Interval = IntervalDay
+		}
+	}
+	report.Buckets, report.SuppressedBuckets = Anonymize(buckets, query.MinBucket)
+	return report, nil
+}
+
+// Anonymize enforces k-anonymity with k = minBucket: buckets with fewer listings are removed, and
+// price distributions and average ages over fewer listings with a price or an age are dropped. It returns the remaining buckets,
+// never nil, and the number of removed ones.
+func Anonymize(buckets []AggregateBucket, minBucket int) ([]AggregateBucket, int) {
+	k := uint64(max(minBucket, 1))
+
+	kept := make([]AggregateBucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		if bucket.Listings < k {
+			continue
+		}
+		if bucket.PriceHour != nil && bucket.PriceHour.Listings < k {
+			bucket.PriceHour = nil
+		}
+		if bucket.Age != nil && bucket.Age.Listings < k {
+			bucket.Age = nil
+		}
+		kept = append(kept, bucket)
+	}
+	return kept, len(buckets) - len(kept)
+}
@@ -0,0 +1,57 @@
+package clickhouse
+
+import "testing"
+
+func TestAnonymize(t *testing.T) {
+	buckets := []AggregateBucket{
+		{Key: "Москва", Listings: 40, PriceHour: &PriceDistribution{Listings: 30, Median: 7000}, Age: &AgeSummary{Listings: 6, Avg: 27}},
+		{Key: "Казань", Listings: 12, PriceHour: &PriceDistribution{Listings: 4, Median: 5000}, Age: &AgeSummary{Listings: 12, Avg: 25}},
+		{Key: "Тверь", Listings: 3, PriceHour: &PriceDistribution{Listings: 3, Median: 4000}},
+	}
+
+	kept, suppressed := Anonymize(buckets, 10)
+	if suppressed != 1 || len(kept) != 2 {
+		t.Fatalf("Expected 2 buckets and 1 suppressed, got %d and %d", len(kept), suppressed)
+	}
+	if kept[0].PriceHour == nil || kept[0].PriceHour.Median != 7000 {
+		t.Errorf("Expected the Москва price distribution to be kept, got %+v", kept[0].PriceHour)
+	}
+	if kept[1].PriceHour != nil {
+		t.Errorf("Expected the price distribution over 4 listings to be dropped, got %+v", kept[1].PriceHour)
+	}
+	if kept[0].Age != nil || kept[1].Age == nil || kept[1].Age.Avg != 25 {
+		t.Errorf("Expected only the Казань average age to be kept, got %+v and %+v", kept[0].Age, kept[1].Age)
+	}
+	if buckets[1].PriceHour == nil {
+		t.Errorf("Expected the input buckets to be left unchanged")
+	}
+
+	if kept, _ := Anonymize(nil, 10); kept == nil {
+		t.Errorf("Expected an empty slice, got nil")
+	}
+}
+
+func TestAggregateKeyExpression(t *testing.T) {
+	tests := map[string]struct {
+		query    AggregateQuery
+		expected string
+	}{
+		"city":          {AggregateQuery{GroupBy: AggregateByCity}, "location_city"},
+		"metro":         {AggregateQuery{GroupBy: AggregateByMetro}, "arrayJoin(location_metro_stations)"},
+		"district":      {AggregateQuery{GroupBy: AggregateByDistrict}, "location_district"},
+		"date defaults": {AggregateQuery{GroupBy: AggregateByDate}, "toString(toDate(created_at, 'UTC'))"},
+		"month":         {AggregateQuery{GroupBy: AggregateByDate, Interval: IntervalMonth}, "toString(toStartOfMonth(created_at, 'UTC'))"},
+	}
+	for name, tt := range tests {
+		expression, err := tt.query.keyExpression()
+		if err != nil || expression != tt.expected {
+			t.Errorf("%s: expected %q, got %q, %v", name, tt.expected, expression, err)
+		}
+	}
+
+	for _, query := range []AggregateQuery{{GroupBy: "phone"}, {GroupBy: AggregateByCity, Interval: IntervalDay}, {GroupBy: AggregateByDate, Interval: "hour"}} {
+		if _, err := query.keyExpression(); err == nil {
+			t.Errorf("Expected an error for %+v", query)
+		}
+	}
+}
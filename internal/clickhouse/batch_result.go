@@ -0,0 +1,137 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	listing "github.com/gregor-tokarev/hoe_parser/proto"
+)
+
+// RowError is a listing left out of a batch because it could not be appended
+type RowError struct {
+	Index     int    `json:"index"` // position of the listing in the batch
+	ListingID string `json:"listing_id"`
+	Reason    string `json:"reason"`
+	Err       error  `json:"-"`
+}
+
+// Error implements error
+func (e RowError) Error() string {
+	return fmt.Sprintf("listing %s (row %d): %s", e.ListingID, e.Index, e.Reason)
+}
+
+// Unwrap returns the append error
+func (e RowError) Unwrap() error {
+	return e.Err
+}
+
+// BatchResult reports what a partial batch insert did with each listing
+type BatchResult struct {
+	Inserted int        `json:"inserted"` // listings sent to ClickHouse
+	Excluded int        `json:"excluded"` // listings skipped because their ID is excluded
+	Failed   []RowError `json:"failed,omitempty"`
+}
+
+// FailedIndexes returns the positions of the listings that were not inserted because they failed,
+// so callers can retry just those
+func (r *BatchResult) FailedIndexes() []int {
+	indexes := make([]int, len(r.Failed))
+	for i, failure := range r.Failed {
+		indexes[i] = failure.Index
+	}
+	return indexes
+}
+
+// BatchInsertListingsPartial inserts multiple listings in a batch like BatchInsertListings, but
+// leaves out the listings that fail to append instead of aborting the batch
+func (a *Adapter) BatchInsertListingsPartial(ctx context.Context, listings []*listing.Listing, sourceURLs []string) (*BatchResult, error) {
+	if len(sourceURLs) != len(listings) {
+		return nil, fmt.Errorf("sourceURLs length (%d) must match listings length (%d)", len(sourceURLs), len(listings))
+	}
+
+	flattened := make([]*FlattenedListing, len(listings))
+	for i, listing := range listings {
+		flattened[i] = a.FlattenListing(listing, sourceURLs[i])
+	}
+	return a.BatchInsertFlattenedListingsPartial(ctx, flattened)
+}
+
+// BatchInsertFlattenedListingsPartial inserts flattened listings in one batch, skipping excluded
+// ones. A listing that fails to append is reported in the result and the rest are still sent; an
+// error means nothing was inserted, because the batch could not be prepared or sent.
+func (a *Adapter) BatchInsertFlattenedListingsPartial(ctx context.Context, listings []*FlattenedListing) (*BatchResult, error) {
+	if len(listings) == 0 {
+		return &BatchResult{}, nil
+	}
+
+	ctx, cancel := a.begin(ctx, OperationInsert)
+	defer cancel()
+
+	prepare := func() (driver.Batch, error) {
+		batch, err := a.conn.PrepareBatch(ctx, `
+			INSERT INTO listings (`+a.schema().columns+`
+			)
+		`)
+		if err != nil {
+			return nil, fmt.Errorf("failed to prepare batch: %w", a.queryError(ctx, OperationInsert, err))
+		}
+		return batch, nil
+	}
+
+	batch, result, err := a.appendPartial(listings, prepare)
+	if err != nil {
+		return nil, err
+	}
+	if result.Inserted == 0 {
+		batch.Abort()
+		return result, nil
+	}
+
+	if err := batch.Send(); err != nil {
+		return nil, fmt.Errorf("failed to send batch: %w", a.queryError(ctx, OperationInsert, err))
+	}
+	return result, nil
+}
+
+// appendPartial appends the listings to a batch from prepare and returns it unsent. The driver
+// discards a batch once an append fails, so after a failure the listings appended so far are
+// appended again to a new batch.
+func (a *Adapter) appendPartial(listings []*FlattenedListing, prepare func() (driver.Batch, error)) (driver.Batch, *BatchResult, error) {
+	batch, err := prepare()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := &BatchResult{}
+	var appended [][]any
+	for i, flattened := range listings {
+		if !flattened.IsDeleted && a.IsExcluded(flattened.ID) {
+			result.Excluded++
+			continue
+		}
+
+		values := a.schema().values(flattened)
+		err := batch.Append(values...)
+		if err == nil {
+			appended = append(appended, values)
+			continue
+		}
+
+		result.Failed = append(result.Failed, RowError{Index: i, ListingID: flattened.ID, Reason: err.Error(), Err: err})
+		log.Warn("Skipping listing that failed to append to batch", "listing_id", flattened.ID, "error", err)
+
+		if batch, err = prepare(); err != nil {
+			return nil, nil, err
+		}
+		for _, values := range appended {
+			if err := batch.Append(values...); err != nil {
+				batch.Abort()
+				return nil, nil, fmt.Errorf("failed to append listings again after a failed row: %w", err)
+			}
+		}
+	}
+
+	result.Inserted = len(appended)
+	return batch, result, nil
+}
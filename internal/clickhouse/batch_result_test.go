@@ -0,0 +1,71 @@
+package clickhouse
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// fakeBatch collects appended rows and, like the driver, is unusable once an append failed
+type fakeBatch struct {
+	driver.Batch
+	failID  map[string]bool
+	rows    []string
+	invalid bool
+	aborted bool
+}
+
+func (b *fakeBatch) Append(values ...any) error {
+	if b.invalid {
+		return errors.New("batch is invalid")
+	}
+	id := values[0].(string)
+	if b.failID[id] {
+		b.invalid = true
+		return errors.New("bad value")
+	}
+	b.rows = append(b.rows, id)
+	return nil
+}
+
+func (b *fakeBatch) Abort() error {
+	b.aborted = true
+	return nil
+}
+
+func TestAppendPartialSkipsFailedRows(t *testing.T) {
+	adapter := &Adapter{exclusions: map[string]bool{"excluded": true}}
+	listings := []*FlattenedListing{{ID: "1"}, {ID: "bad"}, {ID: "excluded"}, {ID: "2"}, {ID: "worse"}, {ID: "3"}}
+
+	var prepared []*fakeBatch
+	prepare := func() (driver.Batch, error) {
+		batch := &fakeBatch{failID: map[string]bool{"bad": true, "worse": true}}
+		prepared = append(prepared, batch)
+		return batch, nil
+	}
+
+	batch, result, err := adapter.appendPartial(listings, prepare)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	rows := batch.(*fakeBatch).rows
+	if len(rows) != 3 || rows[0] != "1" || rows[1] != "2" || rows[2] != "3" {
+		t.Errorf("Expected rows [1 2 3] in the batch to send, got %v", rows)
+	}
+	if len(prepared) != 3 {
+		t.Errorf("Expected a new batch after each failed row, got %d batches", len(prepared))
+	}
+	if result.Inserted != 3 || result.Excluded != 1 {
+		t.Errorf("Expected 3 inserted and 1 excluded, got %d and %d", result.Inserted, result.Excluded)
+	}
+
+	indexes := result.FailedIndexes()
+	if len(indexes) != 2 || indexes[0] != 1 || indexes[1] != 4 {
+		t.Errorf("Expected failed indexes [1 4], got %v", indexes)
+	}
+	if failure := result.Failed[0]; failure.ListingID != "bad" || failure.Reason != "bad value" {
+		t.Errorf("Expected listing bad failing with bad value, got %+v", failure)
+	}
+}
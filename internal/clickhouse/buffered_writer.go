@@ -0,0 +1,236 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	mainConfig "github.com/gregor-tokarev/hoe_parser/internal/config"
+)
+
+// ErrBufferFull is returned by BufferedWriter.Add when the buffer holds Capacity rows
+var ErrBufferFull = errors.New("insert buffer full")
+
+// BufferConfig controls when a BufferedWriter flushes and how it handles failed flushes
+type BufferConfig struct {
+	MaxRows       int           // flush as soon as this many rows are buffered
+	FlushInterval time.Duration // flush buffered rows at least this often
+	MaxRetries    int           // flush attempts before the rows are dropped
+	RetryDelay    time.Duration // wait before the first retry, doubled on every further retry
+	Capacity      int           // rows held at most, including rows of a flush being retried
+}
+
+// BufferFromConfig creates a BufferConfig from the main application config
+func BufferFromConfig(cfg mainConfig.InsertBufferConfig) BufferConfig {
+	return BufferConfig{
+		MaxRows:       cfg.MaxRows,
+		FlushInterval: cfg.FlushInterval,
+		MaxRetries:    cfg.MaxRetries,
+		RetryDelay:    cfg.RetryDelay,
+		Capacity:      cfg.Capacity,
+	}
+}
+
+// BufferedRow is a listing version waiting to be written together with its change-log entries
+type BufferedRow struct {
+	Listing *FlattenedListing
+	Changes []FieldChange   // written to listing_changes once the listing is stored; nil for a new listing
+	Done    func(err error) // called once the row is stored (nil) or dropped; may be nil
+}
+
+// FlushEvent describes one flush of a BufferedWriter
+type FlushEvent struct {
+	Rows     int
+	Attempts int
+	Duration time.Duration
+	Buffered int   // rows left in the buffer after the flush
+	Err      error // set when every attempt failed and the rows were dropped
+}
+
+// BufferedWriter accumulates listings and writes them to ClickHouse in batches, flushing when
+// MaxRows are buffered, every FlushInterval and once more on shutdown
+type BufferedWriter struct {
+	config BufferConfig
+
+	mutex    sync.Mutex
+	rows     []BufferedRow
+	inFlight int // rows taken by the running flush
+	onFlush  func(FlushEvent)
+
+	flushMutex sync.Mutex // serializes flushes
+	flushNow   chan struct{}
+
+	insert     func(ctx context.Context, listings []*FlattenedListing) error
+	logChanges func(ctx context.Context, records []ChangeRecord) error
+	logPrices  func(ctx context.Context, points []PricePoint) error
+}
+
+// NewBufferedWriter creates a writer inserting through adapter. Call Run to start flushing.
+func NewBufferedWriter(adapter *Adapter, config BufferConfig) *BufferedWriter {
+	if config.MaxRows <= 0 {
+		config.MaxRows = 500
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 10 * time.Second
+	}
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = 1
+	}
+	if config.Capacity < config.MaxRows {
+		config.Capacity = config.MaxRows
+	}
+
+	return &BufferedWriter{
+		config:     config,
+		flushNow:   make(chan struct{}, 1),
+		insert:     adapter.BatchInsertFlattenedListings,
+		logChanges: adapter.LogChanges,
+		logPrices:  adapter.InsertPriceHistory,
+	}
+}
+
+// SetFlushHandler sets a callback receiving every flush
+func (w *BufferedWriter) SetFlushHandler(handler func(FlushEvent)) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.onFlush = handler
+}
+
+// Add buffers a row. It returns ErrBufferFull without buffering when Capacity rows are waiting,
+// which happens when ClickHouse has been failing for a while.
+func (w *BufferedWriter) Add(row BufferedRow) error {
+	w.mutex.Lock()
+	if len(w.rows)+w.inFlight >= w.config.Capacity {
+		w.mutex.Unlock()
+		return fmt.Errorf("%w: %d rows waiting", ErrBufferFull, w.config.Capacity)
+	}
+	w.rows = append(w.rows, row)
+	full := len(w.rows) >= w.config.MaxRows
+	w.mutex.Unlock()
+
+	if full {
+		select {
+		case w.flushNow <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Buffered returns the number of rows waiting to be written, including a flush in progress
+func (w *BufferedWriter) Buffered() int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return len(w.rows) + w.inFlight
+}
+
+// Run flushes on size and interval until ctx is cancelled, then flushes what is left. A flush
+// already retrying when ctx is cancelled runs to completion instead of dropping its rows.
+func (w *BufferedWriter) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.config.FlushInterval)
+	defer ticker.Stop()
+
+	flushCtx := context.WithoutCancel(ctx)
+	for {
+		select {
+		case <-ticker.C:
+			w.Flush(flushCtx)
+		case <-w.flushNow:
+			w.Flush(flushCtx)
+		case <-ctx.Done():
+			// ctx is already cancelled; the final flush gets its own deadline
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			w.Flush(shutdownCtx)
+			cancel()
+			return
+		}
+	}
+}
+
+// Flush writes every buffered row, retrying failed inserts. Rows that still fail after MaxRetries
+// attempts are dropped and their Done callbacks receive the error.
+func (w *BufferedWriter) Flush(ctx context.Context) error {
+	w.flushMutex.Lock()
+	defer w.flushMutex.Unlock()
+
+	w.mutex.Lock()
+	rows := w.rows
+	w.rows = nil
+	w.inFlight = len(rows)
+	w.mutex.Unlock()
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	start := time.Now()
+	attempts, err := w.write(ctx, rows)
+
+	w.mutex.Lock()
+	w.inFlight = 0
+	handler := w.onFlush
+	buffered := len(w.rows)
+	w.mutex.Unlock()
+
+	for _, row := range rows {
+		if row.Done != nil {
+			row.Done(err)
+		}
+	}
+	if handler != nil {
+		handler(FlushEvent{Rows: len(rows), Attempts: attempts, Duration: time.Since(start), Buffered: buffered, Err: err})
+	}
+	return err
+}
+
+// write inserts the rows in one batch with retries, then logs their changes in another batch
+func (w *BufferedWriter) write(ctx context.Context, rows []BufferedRow) (int, error) {
+	listings := make([]*FlattenedListing, len(rows))
+	for i, row := range rows {
+		listings[i] = row.Listing
+	}
+
+	delay := w.config.RetryDelay
+	var err error
+	for attempt := 1; attempt <= w.config.MaxRetries; attempt++ {
+		if err = w.insert(ctx, listings); err == nil {
+			w.logRowChanges(ctx, rows)
+			return attempt, nil
+		}
+		if attempt == w.config.MaxRetries {
+			return attempt, fmt.Errorf("failed to flush %d rows after %d attempts: %w", len(rows), attempt, err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, fmt.Errorf("failed to flush %d rows: %w", len(rows), err)
+		}
+		delay *= 2
+	}
+	return w.config.MaxRetries, err
+}
+
+// logRowChanges writes the change log entries and price history points of stored rows. The rows
+// are already stored, so a failure is only reported.
+func (w *BufferedWriter) logRowChanges(ctx context.Context, rows []BufferedRow) {
+	var records []ChangeRecord
+	listings := make([]*FlattenedListing, len(rows))
+	changes := make([][]FieldChange, len(rows))
+	for i, row := range rows {
+		records = append(records, changeRecords(row.Listing.ID, row.Changes)...)
+		listings[i], changes[i] = row.Listing, row.Changes
+	}
+	if err := w.logChanges(ctx, records); err != nil {
+		log.WarnContext(ctx, "Failed to log changes of flushed rows", "changes", len(records), "error", err)
+	}
+
+	points := pricePoints(listings, changes)
+	if err := w.logPrices(ctx, points); err != nil {
+		log.WarnContext(ctx, "Failed to record price history of flushed rows", "points", len(points), "error", err)
+	}
+}
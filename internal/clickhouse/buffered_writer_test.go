@@ -0,0 +1,109 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSink records the batches a BufferedWriter inserts, failing the first failures calls
+type fakeSink struct {
+	mutex    sync.Mutex
+	failures int
+	batches  [][]*FlattenedListing
+	logged   []string
+}
+
+func (s *fakeSink) insert(ctx context.Context, listings []*FlattenedListing) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("connection refused")
+	}
+	s.batches = append(s.batches, listings)
+	return nil
+}
+
+func (s *fakeSink) logChanges(ctx context.Context, records []ChangeRecord) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, record := range records {
+		s.logged = append(s.logged, record.ListingID)
+	}
+	return nil
+}
+
+func newTestWriter(sink *fakeSink, config BufferConfig) *BufferedWriter {
+	writer := NewBufferedWriter(&Adapter{}, config)
+	writer.insert = sink.insert
+	writer.logChanges = sink.logChanges
+	writer.logPrices = func(ctx context.Context, points []PricePoint) error { return nil }
+	return writer
+}
+
+func TestBufferedWriterFlushesOnSize(t *testing.T) {
+	sink := &fakeSink{}
+	writer := newTestWriter(sink, BufferConfig{MaxRows: 2, FlushInterval: time.Hour})
+
+	flushed := make(chan FlushEvent, 1)
+	writer.SetFlushHandler(func(event FlushEvent) { flushed <- event })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go writer.Run(ctx)
+
+	writer.Add(BufferedRow{Listing: &FlattenedListing{ID: "site:1"}})
+	writer.Add(BufferedRow{Listing: &FlattenedListing{ID: "site:2"}, Changes: []FieldChange{{Field: "price_hour"}}})
+
+	select {
+	case event := <-flushed:
+		if event.Rows != 2 || event.Err != nil {
+			t.Errorf("Expected 2 rows flushed without error, got %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a flush once MaxRows rows were buffered")
+	}
+
+	if len(sink.batches) != 1 || len(sink.batches[0]) != 2 {
+		t.Errorf("Expected one batch of 2 rows, got %v", sink.batches)
+	}
+	if len(sink.logged) != 1 || sink.logged[0] != "site:2" {
+		t.Errorf("Expected changes logged for site:2 only, got %v", sink.logged)
+	}
+}
+
+func TestBufferedWriterRetriesAndDrops(t *testing.T) {
+	sink := &fakeSink{failures: 1}
+	writer := newTestWriter(sink, BufferConfig{MaxRows: 10, MaxRetries: 2, RetryDelay: time.Millisecond})
+
+	var doneErr error
+	writer.Add(BufferedRow{Listing: &FlattenedListing{ID: "site:1"}, Done: func(err error) { doneErr = err }})
+	if err := writer.Flush(context.Background()); err != nil || doneErr != nil {
+		t.Fatalf("Expected the retry to succeed, got %v / %v", err, doneErr)
+	}
+
+	sink.failures = 2
+	writer.Add(BufferedRow{Listing: &FlattenedListing{ID: "site:2"}, Done: func(err error) { doneErr = err }})
+	if err := writer.Flush(context.Background()); err == nil || doneErr == nil {
+		t.Errorf("Expected rows to be dropped after MaxRetries failures")
+	}
+	if writer.Buffered() != 0 {
+		t.Errorf("Expected dropped rows to leave the buffer, got %d", writer.Buffered())
+	}
+}
+
+func TestBufferedWriterCapacity(t *testing.T) {
+	writer := newTestWriter(&fakeSink{}, BufferConfig{MaxRows: 2, Capacity: 2})
+
+	for i := 0; i < 2; i++ {
+		if err := writer.Add(BufferedRow{Listing: &FlattenedListing{}}); err != nil {
+			t.Fatalf("Expected row %d to be buffered, got %v", i, err)
+		}
+	}
+	if err := writer.Add(BufferedRow{Listing: &FlattenedListing{}}); !errors.Is(err, ErrBufferFull) {
+		t.Errorf("Expected ErrBufferFull, got %v", err)
+	}
+}
@@ -0,0 +1,293 @@
+package clickhouse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+	listing "github.com/gregor-tokarev/hoe_parser/proto"
+)
+
+// FieldChange is one column whose value differs between two versions of a listing
+type FieldChange struct {
+	Field    string `json:"field"` // listings column name
+	OldValue string `json:"old_value"`
+	NewValue string `json:"new_value"`
+}
+
+// untrackedColumns are bookkeeping columns that differ on every scrape or are derived from
+// other columns, so they never count as a change. Status transitions are reported by
+// DetectChanges and the methods changing the status instead.
+var untrackedColumns = map[string]bool{
+	"id":                       true,
+	"source_site":              true,
+	"source_id":                true,
+	"created_at":               true,
+	"updated_at":               true,
+	"last_scraped":             true,
+	"completeness":             true,
+	"quality_score":            true,
+	"quality_flags":            true,
+	"contact_phone_normalized": true,
+	"is_deleted":               true,
+	"status":                   true,
+}
+
+// DiffListings compares two versions of a listing column by column and returns the changed
+// columns in listingColumns order. Empty and missing arrays and maps compare equal.
+func DiffListings(previous, current *FlattenedListing) []FieldChange {
+	columns := strings.Split(listingColumns, ",")
+	oldValues, newValues := previous.values(), current.values()
+
+	var changes []FieldChange
+	for i, column := range columns {
+		column = strings.TrimSpace(column)
+		if untrackedColumns[column] {
+			continue
+		}
+
+		oldValue, newValue := formatChangeValue(oldValues[i]), formatChangeValue(newValues[i])
+		if oldValue != newValue {
+			changes = append(changes, FieldChange{Field: column, OldValue: oldValue, NewValue: newValue})
+		}
+	}
+	return changes
+}
+
+// formatChangeValue renders a column value as stored in listing_changes: strings as is,
+// empty arrays and maps as "", everything else as JSON
+func formatChangeValue(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+
+	v := reflect.ValueOf(value)
+	if (v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.Len() == 0 {
+		return ""
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// maxSummaryValue is the longest value quoted in a change summary; longer text is only reported as changed
+const maxSummaryValue = 60
+
+// columnKinds maps every listings column to the reflect.Kind of its Go value
+var columnKinds = func() map[string]reflect.Kind {
+	columns := strings.Split(listingColumns, ",")
+	values := (&FlattenedListing{}).values()
+
+	kinds := make(map[string]reflect.Kind, len(columns))
+	for i, column := range columns {
+		kinds[strings.TrimSpace(column)] = reflect.TypeOf(values[i]).Kind()
+	}
+	return kinds
+}()
+
+// SummarizeChange describes a change for people reading a notification: "price_hour: 7000 → 8000
+// (+1000)" for numbers, "photos: 3 → 5 (+2)" for arrays, and "description changed" for long text
+func SummarizeChange(change FieldChange) string {
+	switch columnKinds[change.Field] {
+	case reflect.Slice:
+		oldCount, newCount := arrayLength(change.OldValue), arrayLength(change.NewValue)
+		return fmt.Sprintf("%s: %d → %d (%+d)", change.Field, oldCount, newCount, newCount-oldCount)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		oldNumber, oldErr := strconv.ParseFloat(change.OldValue, 64)
+		newNumber, newErr := strconv.ParseFloat(change.NewValue, 64)
+		if oldErr == nil && newErr == nil {
+			return fmt.Sprintf("%s: %s → %s (%+g)", change.Field, change.OldValue, change.NewValue, newNumber-oldNumber)
+		}
+	}
+
+	if utf8.RuneCountInString(change.OldValue) > maxSummaryValue || utf8.RuneCountInString(change.NewValue) > maxSummaryValue {
+		return change.Field + " changed"
+	}
+	return fmt.Sprintf("%s: %q → %q", change.Field, change.OldValue, change.NewValue)
+}
+
+// arrayLength returns the number of elements of a JSON array change value; "" is an empty array
+func arrayLength(value string) int {
+	var elements []json.RawMessage
+	if json.Unmarshal([]byte(value), &elements) != nil {
+		return 0
+	}
+	return len(elements)
+}
+
+// UpsertIfChanged stores a scraped listing only when it differs from the latest stored version,
+// logging every changed column to listing_changes with its old and new value, and its prices to
+// price_history when they changed. A listing seen for the first time is inserted without change
+// entries, starting its price history. It returns the changed columns and
+// whether a row was written; an unchanged listing keeps its previous last_scraped.
+func (a *Adapter) UpsertIfChanged(ctx context.Context, listing *listing.Listing, sourceURL string) ([]FieldChange, bool, error) {
+	return a.UpsertFlattenedIfChanged(ctx, a.FlattenListing(listing, sourceURL))
+}
+
+// UpsertFlattenedIfChanged works like UpsertIfChanged for a listing that is already flattened.
+// Like DetectChanges, it carries the stored created_at over to flattened.
+func (a *Adapter) UpsertFlattenedIfChanged(ctx context.Context, flattened *FlattenedListing) ([]FieldChange, bool, error) {
+	changes, write, err := a.DetectChanges(ctx, flattened)
+	if err != nil || !write {
+		return nil, false, err
+	}
+
+	if err := a.InsertFlattenedListing(ctx, flattened); err != nil {
+		return nil, false, err
+	}
+	a.logFieldChanges(ctx, flattened.ID, changes)
+	a.logPriceHistory(ctx, []*FlattenedListing{flattened}, [][]FieldChange{changes})
+	return changes, true, nil
+}
+
+// DetectChanges compares a listing with its latest stored version. It returns the changed columns
+// and whether the listing has to be written: new listings are written without changes, like
+// InsertListing does, soft-deleted ones scraped again with the change of their status back to
+// active, and unchanged ones are not written. The stored created_at is carried over to flattened.
+func (a *Adapter) DetectChanges(ctx context.Context, flattened *FlattenedListing) ([]FieldChange, bool, error) {
+	previous, err := a.latestVersion(ctx, flattened.ID, Scope{})
+	if errors.Is(err, ErrListingNotFound) {
+		return nil, true, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get previous version: %w", err)
+	}
+	if previous.IsDeleted {
+		reactivated := []FieldChange{{Field: "status", OldValue: statusOf(previous), NewValue: StatusActive}}
+		return a.schema().dropMissing(reactivated), true, nil
+	}
+
+	changes := a.schema().dropMissing(DiffListings(previous, flattened))
+	if len(changes) == 0 {
+		return nil, false, nil
+	}
+
+	flattened.CreatedAt = previous.CreatedAt
+	return changes, true, nil
+}
+
+// logFieldChanges writes one update entry per changed column. The new version is already stored,
+// so a failed entry is only reported and never makes the caller retry the insert.
+func (a *Adapter) logFieldChanges(ctx context.Context, listingID string, changes []FieldChange) {
+	if err := a.LogChanges(ctx, changeRecords(listingID, changes)); err != nil {
+		log.WarnContext(ctx, "Failed to log listing changes", "listing_id", listingID, "error", err)
+	}
+}
+
+// changeRecords converts the changed columns of a listing into update entries of the change log
+func changeRecords(listingID string, changes []FieldChange) []ChangeRecord {
+	now := clock.Now()
+	records := make([]ChangeRecord, len(changes))
+	for i, change := range changes {
+		records[i] = ChangeRecord{
+			ListingID:  listingID,
+			ChangedAt:  now,
+			ChangeType: "update",
+			FieldName:  change.Field,
+			OldValue:   change.OldValue,
+			NewValue:   change.NewValue,
+			Source:     ChangeSourceScraper,
+		}
+	}
+	return records
+}
+
+// ChangeSourceScraper is the source of change log entries written by the scraping pipeline
+const ChangeSourceScraper = "scraper"
+
+// ChangeRecord is one entry of the listing_changes log
+type ChangeRecord struct {
+	ListingID  string    `json:"listing_id"`
+	ChangedAt  time.Time `json:"changed_at"` // zero means now
+	ChangeType string    `json:"change_type"`
+	FieldName  string    `json:"field_name"`
+	OldValue   string    `json:"old_value"`
+	NewValue   string    `json:"new_value"`
+	Source     string    `json:"source"`
+}
+
+// LogChanges writes change log entries to the listing_changes table in one batch
+func (a *Adapter) LogChanges(ctx context.Context, records []ChangeRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	ctx, cancel := a.begin(ctx, OperationInsert)
+	defer cancel()
+
+	batch, err := a.conn.PrepareBatch(ctx, `
+		INSERT INTO listing_changes (
+			listing_id, change_timestamp, change_type, field_name, old_value, new_value, source
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare change log batch: %w", a.queryError(ctx, OperationInsert, err))
+	}
+
+	now := clock.Now()
+	for _, record := range records {
+		changedAt := record.ChangedAt
+		if changedAt.IsZero() {
+			changedAt = now
+		}
+		err := batch.Append(record.ListingID, changedAt, record.ChangeType, record.FieldName, record.OldValue, record.NewValue, record.Source)
+		if err != nil {
+			return fmt.Errorf("failed to append change for listing %s: %w", record.ListingID, err)
+		}
+	}
+
+	if err := batch.Send(); err != nil {
+		return fmt.Errorf("failed to send change log batch: %w", a.queryError(ctx, OperationInsert, err))
+	}
+
+	return nil
+}
+
+// GetRecentChanges returns up to limit change log entries made at or after since, newest first
+func (a *Adapter) GetRecentChanges(ctx context.Context, since time.Time, limit int) ([]ChangeRecord, error) {
+	query := `
+		SELECT listing_id, change_timestamp, change_type, field_name, old_value, new_value, source
+		FROM listing_changes
+		WHERE change_timestamp >= ?
+		ORDER BY change_timestamp DESC
+		LIMIT ?
+	`
+
+	ctx, cancel := a.begin(ctx, OperationAnalytics)
+	defer cancel()
+
+	rows, err := a.conn.Query(ctx, query, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recent changes: %w", a.queryError(ctx, OperationAnalytics, err))
+	}
+	defer rows.Close()
+
+	var records []ChangeRecord
+	for rows.Next() {
+		var record ChangeRecord
+		err := rows.Scan(&record.ListingID, &record.ChangedAt, &record.ChangeType, &record.FieldName,
+			&record.OldValue, &record.NewValue, &record.Source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan change: %w", err)
+		}
+		record.ChangedAt = record.ChangedAt.UTC()
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recent changes: %w", a.queryError(ctx, OperationAnalytics, err))
+	}
+
+	return records, nil
+}
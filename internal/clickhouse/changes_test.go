@@ -0,0 +1,69 @@
+package clickhouse
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffListings(t *testing.T) {
+	previous := &FlattenedListing{
+		ID:                    "intimcity.gold:123",
+		LastScraped:           time.Now().Add(-time.Hour),
+		PersonalAge:           25,
+		PriceHour:             7000,
+		LocationMetroStations: []string{"Арбатская"},
+		ServiceAvailable:      nil,
+		Completeness:          0.6,
+	}
+	current := &FlattenedListing{
+		ID:                    "intimcity.gold:123",
+		LastScraped:           time.Now(),
+		PersonalAge:           25,
+		PriceHour:             8000,
+		LocationMetroStations: []string{"Арбатская", "Смоленская"},
+		ServiceAvailable:      []string{},
+		Completeness:          0.8,
+	}
+
+	changes := DiffListings(previous, current)
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %d: %+v", len(changes), changes)
+	}
+
+	if changes[0].Field != "price_hour" || changes[0].OldValue != "7000" || changes[0].NewValue != "8000" {
+		t.Errorf("Expected price_hour 7000 -> 8000, got %+v", changes[0])
+	}
+
+	metro := changes[1]
+	if metro.Field != "location_metro_stations" || metro.OldValue != `["Арбатская"]` || metro.NewValue != `["Арбатская","Смоленская"]` {
+		t.Errorf("Expected metro stations change, got %+v", metro)
+	}
+}
+
+func TestDiffListingsUnchanged(t *testing.T) {
+	previous := &FlattenedListing{Description: "text", PricingDurationPrices: map[string]uint32{}}
+	current := &FlattenedListing{Description: "text", UpdatedAt: time.Now()}
+
+	if changes := DiffListings(previous, current); len(changes) != 0 {
+		t.Errorf("Expected no changes, got %+v", changes)
+	}
+}
+
+func TestSummarizeChange(t *testing.T) {
+	cases := []struct {
+		change   FieldChange
+		expected string
+	}{
+		{FieldChange{Field: "price_hour", OldValue: "7000", NewValue: "8000"}, "price_hour: 7000 → 8000 (+1000)"},
+		{FieldChange{Field: "photos", OldValue: `["a.jpg"]`, NewValue: `["a.jpg","b.jpg","c.jpg"]`}, "photos: 1 → 3 (+2)"},
+		{FieldChange{Field: "photos", OldValue: `["a.jpg"]`, NewValue: ""}, "photos: 1 → 0 (-1)"},
+		{FieldChange{Field: "contact_phone", OldValue: "", NewValue: "+79990000000"}, `contact_phone: "" → "+79990000000"`},
+		{FieldChange{Field: "description", OldValue: "short", NewValue: strings.Repeat("long ", 20)}, "description changed"},
+	}
+	for _, c := range cases {
+		if got := SummarizeChange(c.change); got != c.expected {
+			t.Errorf("Expected %q, got %q", c.expected, got)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package clickhouse
+
+// Key fields counted by CompletenessScore and tracked by parser coverage alerts
+const (
+	FieldAge    = "age"
+	FieldPrice  = "price"
+	FieldPhotos = "photos"
+	FieldMetro  = "metro"
+	FieldPhone  = "phone"
+)
+
+// KeyFields reports which key fields of a listing are populated
+func KeyFields(f *FlattenedListing) map[string]bool {
+	return map[string]bool{
+		FieldAge:    f.PersonalAge > 0,
+		FieldPrice:  f.PriceHour > 0 || f.Price2Hours > 0 || f.PriceNight > 0 || f.PriceDay > 0 || f.PriceBase > 0,
+		FieldPhotos: len(f.Photos) > 0,
+		FieldMetro:  len(f.LocationMetroStations) > 0,
+		FieldPhone:  f.ContactPhone != "",
+	}
+}
+
+// CompletenessScore returns the fraction of key fields populated: age, price, photos, metro and phone.
+// The default expression of the completeness column computes the same value and must be kept in sync.
+func CompletenessScore(f *FlattenedListing) float32 {
+	fields := KeyFields(f)
+	populated := 0
+	for _, ok := range fields {
+		if ok {
+			populated++
+		}
+	}
+	return float32(populated) / float32(len(fields))
+}
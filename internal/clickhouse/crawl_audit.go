@@ -0,0 +1,64 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// CrawlAudit is one index page request with the politeness delay that preceded it
+type CrawlAudit struct {
+	SourceSite     string
+	Cycle          uint32
+	CycleStartedAt time.Time
+	Page           uint32
+	URL            string
+	BaseDelay      time.Duration // schedule in force for the cycle
+	MaxJitter      time.Duration
+	PlannedDelay   time.Duration // delay drawn from the schedule for this request
+	Jitter         time.Duration
+	Slept          time.Duration // measured wait before the request
+	RequestedAt    time.Time
+	CompletedAt    time.Time
+	Links          uint32
+	Error          string
+}
+
+// InsertCrawlAudit writes index page request records to the crawl_audit table
+func (a *Adapter) InsertCrawlAudit(ctx context.Context, entries []CrawlAudit) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	ctx, cancel := a.begin(ctx, OperationInsert)
+	defer cancel()
+
+	batch, err := a.conn.PrepareBatch(ctx, `
+		INSERT INTO crawl_audit (
+			source_site, cycle, cycle_started_at, page, url,
+			base_delay_ms, max_jitter_ms, planned_delay_ms, jitter_ms, slept_ms,
+			requested_at, completed_at, links, error
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare crawl audit batch: %w", a.queryError(ctx, OperationInsert, err))
+	}
+
+	for _, entry := range entries {
+		err := batch.Append(
+			entry.SourceSite, entry.Cycle, entry.CycleStartedAt, entry.Page, entry.URL,
+			uint32(entry.BaseDelay.Milliseconds()), uint32(entry.MaxJitter.Milliseconds()),
+			uint32(entry.PlannedDelay.Milliseconds()), uint32(entry.Jitter.Milliseconds()), uint32(entry.Slept.Milliseconds()),
+			entry.RequestedAt, entry.CompletedAt, entry.Links, entry.Error,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to append crawl audit entry for %s: %w", entry.URL, err)
+		}
+	}
+
+	if err := batch.Send(); err != nil {
+		return fmt.Errorf("failed to send crawl audit batch: %w", a.queryError(ctx, OperationInsert, err))
+	}
+
+	return nil
+}
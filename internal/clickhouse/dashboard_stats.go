@@ -0,0 +1,133 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+)
+
+// DashboardStats is the precomputed set of numbers shown by the dashboard
+type DashboardStats struct {
+	ComputedAt          time.Time `json:"computed_at"`
+	TotalListings       uint64    `json:"total_listings"`
+	NewListingsToday    uint64    `json:"new_listings_today"` // listings first stored since midnight (server time)
+	ActiveProxies       uint32    `json:"active_proxies"`
+	TotalProxies        uint32    `json:"total_proxies"`
+	LastCycleDuration   float64   `json:"last_cycle_duration_seconds"` // most recent complete crawl cycle
+	LastCycleFinishedAt time.Time `json:"last_cycle_finished_at"`
+}
+
+// ProxyCounter reports the number of active and configured proxies
+type ProxyCounter func() (active, total int)
+
+// ComputeDashboardStats runs the aggregations behind the dashboard numbers. It scans the
+// listings table and should only be called from the background refresh job.
+func (a *Adapter) ComputeDashboardStats(ctx context.Context, proxies ProxyCounter) (*DashboardStats, error) {
+	ctx, cancel := a.begin(ctx, OperationAnalytics)
+	defer cancel()
+
+	stats := &DashboardStats{ComputedAt: clock.Now()}
+
+	err := a.conn.QueryRow(ctx, `
+		SELECT
+			countIf(NOT is_deleted),
+			countIf(NOT is_deleted AND toDate(first_seen, 'UTC') = toDate(now(), 'UTC'))
+		FROM (
+			SELECT id, argMax(`+a.schema().column("is_deleted")+`, updated_at) AS is_deleted, min(created_at) AS first_seen
+			FROM listings
+			GROUP BY id
+		)
+	`).Scan(&stats.TotalListings, &stats.NewListingsToday)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count listings: %w", a.queryError(ctx, OperationAnalytics, err))
+	}
+
+	// The newest cycle is usually still running, so the last complete one is the second newest
+	var finishedAt time.Time
+	var durationMs int64
+	err = a.conn.QueryRow(ctx, `
+		SELECT max(completed_at) AS finished_at, dateDiff('millisecond', min(requested_at), max(completed_at))
+		FROM crawl_audit
+		WHERE requested_at > now() - INTERVAL 1 DAY
+		GROUP BY source_site, cycle_started_at
+		ORDER BY cycle_started_at DESC
+		LIMIT 1 OFFSET 1
+	`).Scan(&finishedAt, &durationMs)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to get last crawl cycle: %w", a.queryError(ctx, OperationAnalytics, err))
+	}
+	stats.LastCycleFinishedAt = finishedAt.UTC()
+	stats.LastCycleDuration = float64(durationMs) / 1000
+
+	if proxies != nil {
+		active, total := proxies()
+		stats.ActiveProxies = uint32(active)
+		stats.TotalProxies = uint32(total)
+	}
+
+	return stats, nil
+}
+
+// InsertDashboardStats stores a computed snapshot in dashboard_stats
+func (a *Adapter) InsertDashboardStats(ctx context.Context, stats *DashboardStats) error {
+	ctx, cancel := a.begin(ctx, OperationInsert)
+	defer cancel()
+
+	err := a.conn.Exec(ctx, `
+		INSERT INTO dashboard_stats (
+			computed_at, total_listings, new_listings_today, active_proxies, total_proxies,
+			last_cycle_duration_seconds, last_cycle_finished_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, stats.ComputedAt, stats.TotalListings, stats.NewListingsToday, stats.ActiveProxies, stats.TotalProxies,
+		stats.LastCycleDuration, stats.LastCycleFinishedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert dashboard stats: %w", a.queryError(ctx, OperationInsert, err))
+	}
+
+	return nil
+}
+
+// RefreshDashboardStats computes and stores a new dashboard snapshot
+func (a *Adapter) RefreshDashboardStats(ctx context.Context, proxies ProxyCounter) (*DashboardStats, error) {
+	stats, err := a.ComputeDashboardStats(ctx, proxies)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.InsertDashboardStats(ctx, stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// ErrNoDashboardStats is returned before the first dashboard snapshot has been stored
+var ErrNoDashboardStats = errors.New("dashboard stats not computed yet")
+
+// GetDashboardStats returns the latest stored dashboard snapshot. It reads a single row.
+func (a *Adapter) GetDashboardStats(ctx context.Context) (*DashboardStats, error) {
+	ctx, cancel := a.begin(ctx, OperationQuery)
+	defer cancel()
+
+	var stats DashboardStats
+	err := a.conn.QueryRow(ctx, `
+		SELECT computed_at, total_listings, new_listings_today, active_proxies, total_proxies,
+			last_cycle_duration_seconds, last_cycle_finished_at
+		FROM dashboard_stats
+		ORDER BY computed_at DESC
+		LIMIT 1
+	`).Scan(&stats.ComputedAt, &stats.TotalListings, &stats.NewListingsToday, &stats.ActiveProxies, &stats.TotalProxies,
+		&stats.LastCycleDuration, &stats.LastCycleFinishedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoDashboardStats
+		}
+		return nil, fmt.Errorf("failed to get dashboard stats: %w", a.queryError(ctx, OperationQuery, err))
+	}
+	stats.ComputedAt = stats.ComputedAt.UTC()
+	stats.LastCycleFinishedAt = stats.LastCycleFinishedAt.UTC()
+
+	return &stats, nil
+}
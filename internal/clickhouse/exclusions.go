@@ -0,0 +1,176 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+)
+
+// ErrListingExcluded is returned when writing a listing that is on the exclusion list
+var ErrListingExcluded = errors.New("listing is excluded")
+
+// Exclusion marks a listing that must never be re-ingested (spam, takedown requests)
+type Exclusion struct {
+	ListingID string    `json:"listing_id"`
+	Reason    string    `json:"reason"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AddExclusion puts a listing on the exclusion list and soft-deletes its stored versions with status banned
+func (a *Adapter) AddExclusion(ctx context.Context, exclusion Exclusion) error {
+	if exclusion.CreatedAt.IsZero() {
+		exclusion.CreatedAt = clock.Now()
+	}
+
+	if err := a.writeExclusion(ctx, exclusion, true); err != nil {
+		return err
+	}
+
+	a.exclusionsMutex.Lock()
+	a.exclusions[exclusion.ListingID] = true
+	a.exclusionsMutex.Unlock()
+
+	previous, banned, err := a.softDelete(ctx, exclusion.ListingID, StatusBanned)
+	if err != nil && !errors.Is(err, ErrListingNotFound) {
+		return err
+	}
+	if banned {
+		a.logStatusChange(ctx, exclusion.ListingID, previous, StatusBanned, exclusion.CreatedBy)
+	}
+
+	if err := a.LogChange(ctx, exclusion.ListingID, "exclude", "", exclusion.Reason, "is_deleted", exclusion.CreatedBy); err != nil {
+		log.WarnContext(ctx, "Failed to log exclusion change", "listing_id", exclusion.ListingID, "error", err)
+	}
+
+	return nil
+}
+
+// RemoveExclusion takes a listing off the exclusion list. Its soft-deleted versions stay hidden
+// until the listing is scraped again.
+func (a *Adapter) RemoveExclusion(ctx context.Context, listingID, removedBy string) error {
+	exclusion := Exclusion{ListingID: listingID, CreatedBy: removedBy, CreatedAt: clock.Now()}
+	if err := a.writeExclusion(ctx, exclusion, false); err != nil {
+		return err
+	}
+
+	a.exclusionsMutex.Lock()
+	delete(a.exclusions, listingID)
+	a.exclusionsMutex.Unlock()
+
+	return nil
+}
+
+// writeExclusion inserts a new version of an exclusion row
+func (a *Adapter) writeExclusion(ctx context.Context, exclusion Exclusion, active bool) error {
+	query := `
+		INSERT INTO listing_exclusions (listing_id, reason, created_by, created_at, active)
+		VALUES (?, ?, ?, ?, ?)
+	`
+
+	ctx, cancel := a.begin(ctx, OperationInsert)
+	defer cancel()
+
+	err := a.conn.Exec(ctx, query,
+		exclusion.ListingID, exclusion.Reason, exclusion.CreatedBy, exclusion.CreatedAt, active)
+	if err != nil {
+		return fmt.Errorf("failed to write exclusion for listing %s: %w", exclusion.ListingID, a.queryError(ctx, OperationInsert, err))
+	}
+
+	return nil
+}
+
+// ListExclusions returns every active exclusion
+func (a *Adapter) ListExclusions(ctx context.Context) ([]Exclusion, error) {
+	query := `
+		SELECT listing_id, reason, created_by, created_at
+		FROM listing_exclusions
+		FINAL
+		WHERE active
+		ORDER BY created_at DESC
+	`
+
+	ctx, cancel := a.begin(ctx, OperationQuery)
+	defer cancel()
+
+	rows, err := a.conn.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query exclusions: %w", a.queryError(ctx, OperationQuery, err))
+	}
+	defer rows.Close()
+
+	var exclusions []Exclusion
+	for rows.Next() {
+		var exclusion Exclusion
+		if err := rows.Scan(&exclusion.ListingID, &exclusion.Reason, &exclusion.CreatedBy, &exclusion.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan exclusion: %w", err)
+		}
+		exclusion.CreatedAt = exclusion.CreatedAt.UTC()
+		exclusions = append(exclusions, exclusion)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate exclusions: %w", a.queryError(ctx, OperationQuery, err))
+	}
+
+	return exclusions, nil
+}
+
+// RefreshExclusions reloads the cached exclusion list consulted by IsExcluded
+func (a *Adapter) RefreshExclusions(ctx context.Context) error {
+	exclusions, err := a.ListExclusions(ctx)
+	if err != nil {
+		return err
+	}
+
+	excluded := make(map[string]bool, len(exclusions))
+	for _, exclusion := range exclusions {
+		excluded[exclusion.ListingID] = true
+	}
+
+	a.exclusionsMutex.Lock()
+	a.exclusions = excluded
+	a.exclusionsMutex.Unlock()
+
+	return nil
+}
+
+// IsExcluded reports whether a listing ID is on the cached exclusion list
+func (a *Adapter) IsExcluded(listingID string) bool {
+	a.exclusionsMutex.RLock()
+	defer a.exclusionsMutex.RUnlock()
+	return a.exclusions[listingID]
+}
+
+// SoftDeleteListing writes a new version of the listing flagged as deleted with status removed,
+// hiding it from all read paths
+func (a *Adapter) SoftDeleteListing(ctx context.Context, listingID string) error {
+	_, _, err := a.softDelete(ctx, listingID, StatusRemoved)
+	return err
+}
+
+// softDelete soft-deletes a listing with status. It returns the previous status and false when
+// the listing already was deleted with that status.
+func (a *Adapter) softDelete(ctx context.Context, listingID, status string) (string, bool, error) {
+	flattened, err := a.latestVersion(ctx, listingID, Scope{})
+	if err != nil {
+		return "", false, err
+	}
+
+	previous := statusOf(flattened)
+	if flattened.IsDeleted && previous == status {
+		return previous, false, nil
+	}
+
+	flattened.IsDeleted = true
+	flattened.Status = status
+	flattened.UpdatedAt = clock.Now()
+
+	if err := a.InsertFlattenedListing(ctx, flattened); err != nil {
+		return "", false, err
+	}
+	return previous, true, nil
+}
@@ -0,0 +1,239 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ListingIDs returns up to limit distinct listing IDs greater than after, in ascending order, for
+// walking every listing in batches
+func (a *Adapter) ListingIDs(ctx context.Context, after string, limit int) ([]string, error) {
+	query := `
+		SELECT DISTINCT id
+		FROM listings
+		WHERE id > ?
+		ORDER BY id
+		LIMIT ?
+	`
+
+	ctx, cancel := a.begin(ctx, OperationAnalytics)
+	defer cancel()
+
+	rows, err := a.conn.Query(ctx, query, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query listing ids: %w", a.queryError(ctx, OperationAnalytics, err))
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan listing id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read listing ids: %w", a.queryError(ctx, OperationAnalytics, err))
+	}
+
+	return ids, nil
+}
+
+// ListingVersions returns every stored version of the given listings, oldest first per listing.
+// Versions replaced by a background merge are gone; the rest are read without FINAL.
+func (a *Adapter) ListingVersions(ctx context.Context, ids []string) (map[string][]*FlattenedListing, error) {
+	versions := make(map[string][]*FlattenedListing, len(ids))
+	if len(ids) == 0 {
+		return versions, nil
+	}
+
+	all, err := a.queryVersions(ctx, OperationAnalytics, "WHERE id IN (?)", ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, flattened := range all {
+		versions[flattened.ID] = append(versions[flattened.ID], flattened)
+	}
+	return versions, nil
+}
+
+// queryVersions returns the listing versions matching where, ordered by id and then oldest first
+func (a *Adapter) queryVersions(ctx context.Context, op Operation, where string, args ...any) ([]*FlattenedListing, error) {
+	query := `
+		SELECT ` + a.schema().columns + `
+		FROM listings
+		` + where + `
+		ORDER BY id, updated_at
+	`
+
+	ctx, cancel := a.begin(ctx, op)
+	defer cancel()
+
+	rows, err := a.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query listing versions: %w", a.queryError(ctx, op, err))
+	}
+	defer rows.Close()
+
+	var versions []*FlattenedListing
+	for rows.Next() {
+		flattened, err := a.schema().scan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan listing version: %w", err)
+		}
+		versions = append(versions, flattened)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read listing versions: %w", a.queryError(ctx, op, err))
+	}
+
+	return versions, nil
+}
+
+// ListingHistory is every stored version of a listing together with its change log
+type ListingHistory struct {
+	ID       string              `json:"id"`
+	Versions []*FlattenedListing `json:"versions"` // oldest first
+	Changes  []ChangeRecord      `json:"changes"`  // oldest first
+}
+
+// GetListingHistory returns the stored versions and change log entries of a listing. Soft-deleted
+// listings are reported as not found, like GetListingByID does.
+func (a *Adapter) GetListingHistory(ctx context.Context, id string) (*ListingHistory, error) {
+	return a.listingHistory(ctx, id, Scope{})
+}
+
+// GetListingHistory returns the history of a listing in the scope, without the versions outside
+// it and without the changes of hidden field groups
+func (s *ScopedAdapter) GetListingHistory(ctx context.Context, id string) (*ListingHistory, error) {
+	return s.adapter.listingHistory(ctx, id, s.scope)
+}
+
+// listingHistory returns the history of a listing visible in scope
+func (a *Adapter) listingHistory(ctx context.Context, id string, scope Scope) (*ListingHistory, error) {
+	where, args := scope.where("id = ?", id)
+	versions, err := a.queryVersions(ctx, OperationQuery, where, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 || versions[len(versions)-1].IsDeleted {
+		return nil, fmt.Errorf("%w: %s", ErrListingNotFound, id)
+	}
+	for _, version := range versions {
+		scope.Apply(version)
+	}
+
+	changes, err := a.listingChanges(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &ListingHistory{ID: id, Versions: versions, Changes: []ChangeRecord{}}
+	for _, change := range changes {
+		if !scope.hidesColumn(change.FieldName) {
+			history.Changes = append(history.Changes, change)
+		}
+	}
+	return history, nil
+}
+
+// listingChanges returns the change log entries of a listing, oldest first
+func (a *Adapter) listingChanges(ctx context.Context, id string) ([]ChangeRecord, error) {
+	query := `
+		SELECT listing_id, change_timestamp, change_type, field_name, old_value, new_value, source
+		FROM listing_changes
+		WHERE listing_id = ?
+		ORDER BY change_timestamp
+	`
+
+	ctx, cancel := a.begin(ctx, OperationQuery)
+	defer cancel()
+
+	rows, err := a.conn.Query(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query changes of listing %s: %w", id, a.queryError(ctx, OperationQuery, err))
+	}
+	defer rows.Close()
+
+	var records []ChangeRecord
+	for rows.Next() {
+		var record ChangeRecord
+		err := rows.Scan(&record.ListingID, &record.ChangedAt, &record.ChangeType, &record.FieldName,
+			&record.OldValue, &record.NewValue, &record.Source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan change: %w", err)
+		}
+		record.ChangedAt = record.ChangedAt.UTC()
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read changes of listing %s: %w", id, a.queryError(ctx, OperationQuery, err))
+	}
+
+	return records, nil
+}
+
+// VersionChangeRecords reconstructs the change log of a listing from its versions, oldest first:
+// one update entry per column changed between consecutive versions, timestamped with the
+// updated_at of the newer version, and a delete entry when a version soft-deletes the listing.
+// Like DetectChanges, a version following a deleted one starts over without entries.
+func VersionChangeRecords(versions []*FlattenedListing, source string) []ChangeRecord {
+	var records []ChangeRecord
+	for i := 1; i < len(versions); i++ {
+		previous, current := versions[i-1], versions[i]
+
+		switch {
+		case previous.IsDeleted:
+			continue
+		case current.IsDeleted:
+			records = append(records, ChangeRecord{
+				ListingID:  current.ID,
+				ChangedAt:  current.UpdatedAt,
+				ChangeType: "delete",
+				FieldName:  "is_deleted",
+				OldValue:   "false",
+				NewValue:   "true",
+				Source:     source,
+			})
+			continue
+		}
+
+		for _, change := range DiffListings(previous, current) {
+			records = append(records, ChangeRecord{
+				ListingID:  current.ID,
+				ChangedAt:  current.UpdatedAt,
+				ChangeType: "update",
+				FieldName:  change.Field,
+				OldValue:   change.OldValue,
+				NewValue:   change.NewValue,
+				Source:     source,
+			})
+		}
+	}
+	return records
+}
+
+// ChangeLogRange returns the time of the earliest change log entry written by source and the
+// number of entries it wrote; the time is zero when there are none
+func (a *Adapter) ChangeLogRange(ctx context.Context, source string) (time.Time, uint64, error) {
+	query := `
+		SELECT min(change_timestamp), count()
+		FROM listing_changes
+		WHERE source = ?
+	`
+
+	ctx, cancel := a.begin(ctx, OperationAnalytics)
+	defer cancel()
+
+	var earliest time.Time
+	var count uint64
+	if err := a.conn.QueryRow(ctx, query, source).Scan(&earliest, &count); err != nil {
+		return time.Time{}, 0, fmt.Errorf("failed to query change log range: %w", a.queryError(ctx, OperationAnalytics, err))
+	}
+	if count == 0 {
+		return time.Time{}, 0, nil
+	}
+	return earliest.UTC(), count, nil
+}
@@ -0,0 +1,35 @@
+package clickhouse
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVersionChangeRecords(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	versions := []*FlattenedListing{
+		{ID: "site:1", UpdatedAt: start, PriceHour: 5000},
+		{ID: "site:1", UpdatedAt: start.Add(time.Hour), PriceHour: 5000},
+		{ID: "site:1", UpdatedAt: start.Add(2 * time.Hour), PriceHour: 6000, Description: "new"},
+		{ID: "site:1", UpdatedAt: start.Add(3 * time.Hour), PriceHour: 6000, Description: "new", IsDeleted: true},
+		{ID: "site:1", UpdatedAt: start.Add(4 * time.Hour), PriceHour: 7000},
+	}
+
+	records := VersionChangeRecords(versions, "test")
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records, got %d: %+v", len(records), records)
+	}
+
+	if records[0].FieldName != "price_hour" || records[0].OldValue != "5000" || records[0].NewValue != "6000" {
+		t.Errorf("Expected price_hour 5000 -> 6000, got %+v", records[0])
+	}
+	if !records[0].ChangedAt.Equal(start.Add(2*time.Hour)) || records[0].Source != "test" {
+		t.Errorf("Expected the change timestamped with the newer version, got %+v", records[0])
+	}
+	if records[1].FieldName != "description" {
+		t.Errorf("Expected a description change, got %+v", records[1])
+	}
+	if records[2].ChangeType != "delete" || !records[2].ChangedAt.Equal(start.Add(3*time.Hour)) {
+		t.Errorf("Expected a delete entry, got %+v", records[2])
+	}
+}
@@ -0,0 +1,42 @@
+package clickhouse
+
+import (
+	"net/url"
+	"strings"
+)
+
+// idSeparator separates the source site from the source-local ID in composite listing IDs
+const idSeparator = ":"
+
+// SourceSiteFromURL returns the site a listing URL belongs to, reduced to its last two host labels
+// so that mirrors and subdomains (b.intimcity.gold, m.intimcity.gold) share one namespace
+func SourceSiteFromURL(rawURL string) string {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return ""
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	labels := strings.Split(host, ".")
+	if len(labels) > 2 {
+		labels = labels[len(labels)-2:]
+	}
+	return strings.Join(labels, ".")
+}
+
+// CompositeID builds the storage ID of a listing from its source site and source-local ID
+func CompositeID(sourceSite, sourceID string) string {
+	if sourceSite == "" {
+		return sourceID
+	}
+	return sourceSite + idSeparator + sourceID
+}
+
+// SplitCompositeID returns the source site and source-local ID of a composite ID.
+// IDs without a site prefix are returned with an empty site.
+func SplitCompositeID(id string) (string, string) {
+	if idx := strings.LastIndex(id, idSeparator); idx >= 0 {
+		return id[:idx], id[idx+1:]
+	}
+	return "", id
+}
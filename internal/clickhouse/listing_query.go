@@ -0,0 +1,155 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ListingQuery selects listings for QueryListings. Zero values leave a filter out.
+type ListingQuery struct {
+	MinCompleteness float32 // skip listings with a lower completeness score
+	MinQuality      float32 // skip listings with a lower quality score
+	City            string  // location_city equals
+	Metro           string  // location_metro_stations contains
+	MinPriceHour    uint32
+	MaxPriceHour    uint32
+	MinAge          uint8
+	MaxAge          uint8
+	HasPhotos       *bool  // ignored for scopes hiding photos
+	Sort            string // one of ListingSorts, "-" prefixed for descending; defaults to -last_scraped
+	Limit           int    // defaults to 100
+	Offset          int
+}
+
+// ListingSorts are the columns QueryListings can sort by
+var ListingSorts = []string{"last_scraped", "created_at", "price_hour", "personal_age", "completeness"}
+
+// defaultQueryLimit is used when a ListingQuery has no limit
+const defaultQueryLimit = 100
+
+// IsListingSort reports whether sort is a valid ListingQuery.Sort value
+func IsListingSort(sort string) bool {
+	column := strings.TrimPrefix(sort, "-")
+	for _, allowed := range ListingSorts {
+		if column == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// conditions returns the SQL filter of the query (joined with AND) and its arguments on the
+// columns of schema. Filters on field groups the scope hides are dropped, so they cannot reveal
+// the hidden values.
+func (q ListingQuery) conditions(schema *listingSchema, scope Scope) (string, []any) {
+	clauses := []string{schema.live(), schema.column("completeness") + " >= ?"}
+	args := []any{q.MinCompleteness}
+
+	if q.MinQuality > 0 {
+		clauses = append(clauses, schema.column("quality_score")+" >= ?")
+		args = append(args, q.MinQuality)
+	}
+	if q.City != "" {
+		clauses = append(clauses, "location_city = ?")
+		args = append(args, q.City)
+	}
+	if q.Metro != "" {
+		clauses = append(clauses, "has(location_metro_stations, ?)")
+		args = append(args, q.Metro)
+	}
+	if q.MinPriceHour > 0 {
+		clauses = append(clauses, "price_hour >= ?")
+		args = append(args, q.MinPriceHour)
+	}
+	if q.MaxPriceHour > 0 {
+		clauses = append(clauses, "price_hour > 0 AND price_hour <= ?")
+		args = append(args, q.MaxPriceHour)
+	}
+	if q.MinAge > 0 {
+		clauses = append(clauses, "personal_age >= ?")
+		args = append(args, q.MinAge)
+	}
+	if q.MaxAge > 0 {
+		clauses = append(clauses, "personal_age > 0 AND personal_age <= ?")
+		args = append(args, q.MaxAge)
+	}
+	if q.HasPhotos != nil && !scope.hides(FieldGroupPhotos) {
+		if *q.HasPhotos {
+			clauses = append(clauses, "notEmpty(photos)")
+		} else {
+			clauses = append(clauses, "empty(photos)")
+		}
+	}
+
+	return strings.Join(clauses, " AND "), args
+}
+
+// orderBy returns the ORDER BY expression of the query on the columns of schema; sorts on a
+// column the schema lacks use the default order. Ties are broken by id so pages are stable.
+func (q ListingQuery) orderBy(schema *listingSchema) string {
+	column, descending := strings.CutPrefix(q.Sort, "-")
+	if !IsListingSort(q.Sort) || schema.missing[column] {
+		return "last_scraped DESC, id"
+	}
+	if descending {
+		return column + " DESC, id"
+	}
+	return column + " ASC, id"
+}
+
+// QueryListings returns the latest versions of listings matching q, most recently scraped first
+// unless q.Sort says otherwise
+func (a *Adapter) QueryListings(ctx context.Context, q ListingQuery) ([]*FlattenedListing, error) {
+	return a.queryListings(ctx, q, Scope{})
+}
+
+// QueryListings returns the latest versions of listings in the scope matching q
+func (s *ScopedAdapter) QueryListings(ctx context.Context, q ListingQuery) ([]*FlattenedListing, error) {
+	return s.adapter.queryListings(ctx, q, s.scope)
+}
+
+// queryListings returns the latest versions of listings in scope matching q
+func (a *Adapter) queryListings(ctx context.Context, q ListingQuery, scope Scope) ([]*FlattenedListing, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+
+	conditions, conditionArgs := q.conditions(a.schema(), scope)
+	where, args := scope.where(conditions, conditionArgs...)
+	query := `
+		SELECT ` + a.schema().columns + `
+		FROM listings
+		FINAL
+		` + where + `
+		ORDER BY ` + q.orderBy(a.schema()) + `
+		LIMIT ? OFFSET ?
+	`
+	args = append(args, limit, q.Offset)
+
+	ctx, cancel := a.begin(ctx, OperationAnalytics)
+	defer cancel()
+
+	rows, err := a.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query listings: %w", a.queryError(ctx, OperationAnalytics, err))
+	}
+	defer rows.Close()
+
+	var listings []*FlattenedListing
+	for rows.Next() {
+		flattened, err := a.schema().scan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan listing: %w", err)
+		}
+		scope.Apply(flattened)
+		listings = append(listings, flattened)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate listings: %w", a.queryError(ctx, OperationAnalytics, err))
+	}
+
+	return listings, nil
+}
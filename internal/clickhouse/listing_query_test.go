@@ -0,0 +1,40 @@
+package clickhouse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestListingQueryConditions(t *testing.T) {
+	hasPhotos := true
+	query := ListingQuery{City: "Москва", Metro: "Арбатская", MinPriceHour: 5000, MaxAge: 30, HasPhotos: &hasPhotos}
+
+	conditions, args := query.conditions(fullListingSchema, Scope{})
+	expected := "NOT is_deleted AND completeness >= ? AND location_city = ? AND has(location_metro_stations, ?)" +
+		" AND price_hour >= ? AND personal_age > 0 AND personal_age <= ? AND notEmpty(photos)"
+	if conditions != expected {
+		t.Errorf("Expected %q, got %q", expected, conditions)
+	}
+	if !reflect.DeepEqual(args, []any{float32(0), "Москва", "Арбатская", uint32(5000), uint8(30)}) {
+		t.Errorf("Unexpected args: %v", args)
+	}
+
+	conditions, _ = query.conditions(fullListingSchema, Scope{HiddenFields: []string{FieldGroupPhotos}})
+	if conditions != expected[:len(expected)-len(" AND notEmpty(photos)")] {
+		t.Errorf("Expected the photos filter dropped for a scope hiding photos, got %q", conditions)
+	}
+}
+
+func TestListingQueryOrderBy(t *testing.T) {
+	cases := map[string]string{
+		"":                  "last_scraped DESC, id",
+		"price_hour":        "price_hour ASC, id",
+		"-completeness":     "completeness DESC, id",
+		"description; DROP": "last_scraped DESC, id",
+	}
+	for sort, expected := range cases {
+		if got := (ListingQuery{Sort: sort}).orderBy(fullListingSchema); got != expected {
+			t.Errorf("Expected %q for sort %q, got %q", expected, sort, got)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse/migrations"
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+)
+
+// ErrUntrackedSchema is returned by Migrate for a database whose listings table was created by
+// hand before migrations were tracked, when no migration baseline is configured
+var ErrUntrackedSchema = errors.New("listings table predates migration tracking")
+
+// Migrate brings the schema up to date: it creates the tables on an empty database and applies
+// the migrations not recorded in schema_migrations, in order. Migrations are not locked, so run
+// it from one process at a time; every statement is idempotent DDL or a guarded backfill.
+func (a *Adapter) Migrate(ctx context.Context) error {
+	all, err := migrations.Load()
+	if err != nil {
+		return err
+	}
+
+	if err := a.conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version UInt32,
+			name String,
+			applied_at DateTime64(3)
+		) ENGINE = ReplacingMergeTree(applied_at)
+		ORDER BY version
+	`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	applied, err := a.appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(applied) == 0 {
+		baseline, err := a.migrationBaseline(ctx)
+		if err != nil {
+			return err
+		}
+		if baseline >= 0 {
+			// The tables already exist: record what they contain without running it
+			for _, migration := range all {
+				if migration.Version <= baseline {
+					if err := a.recordMigration(ctx, migration); err != nil {
+						return err
+					}
+					applied[migration.Version] = true
+				}
+			}
+			log.Info("Recorded existing ClickHouse schema as migrated", "version", baseline)
+		}
+	}
+
+	for _, migration := range all {
+		if applied[migration.Version] {
+			continue
+		}
+		if err := a.applyMigration(ctx, migration); err != nil {
+			return err
+		}
+		applied[migration.Version] = true
+
+		if migration.Version == 0 {
+			// The baseline creates the schema of the migrations it covers
+			for _, covered := range all {
+				if covered.Version > 0 && covered.Version <= migrations.BaselineVersion {
+					if err := a.recordMigration(ctx, covered); err != nil {
+						return err
+					}
+					applied[covered.Version] = true
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// appliedMigrations returns the versions recorded in schema_migrations
+func (a *Adapter) appliedMigrations(ctx context.Context) (map[int]bool, error) {
+	rows, err := a.conn.Query(ctx, "SELECT DISTINCT version FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version uint32
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("failed to scan applied migration: %w", err)
+		}
+		applied[int(version)] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate applied migrations: %w", err)
+	}
+	return applied, nil
+}
+
+// migrationBaseline returns the last migration already contained in an untracked database: -1
+// when it has no listings table yet, the configured MigrateBaseline when it has one
+func (a *Adapter) migrationBaseline(ctx context.Context) (int, error) {
+	var tables uint64
+	if err := a.conn.QueryRow(ctx, `
+		SELECT count()
+		FROM system.tables
+		WHERE database = currentDatabase() AND name = 'listings'
+	`).Scan(&tables); err != nil {
+		return 0, fmt.Errorf("failed to check for listings table: %w", err)
+	}
+	if tables == 0 {
+		return -1, nil
+	}
+	if a.config.MigrateBaseline <= 0 {
+		return 0, fmt.Errorf("%w: set the last migration applied by hand as the migration baseline", ErrUntrackedSchema)
+	}
+	return a.config.MigrateBaseline, nil
+}
+
+// applyMigration runs the statements of a migration and records it
+func (a *Adapter) applyMigration(ctx context.Context, migration migrations.Migration) error {
+	for i, statement := range migration.Statements {
+		if err := a.conn.Exec(ctx, statement); err != nil {
+			return fmt.Errorf("failed to apply migration %03d_%s (statement %d): %w", migration.Version, migration.Name, i+1, err)
+		}
+	}
+	if err := a.recordMigration(ctx, migration); err != nil {
+		return err
+	}
+	log.Info("Applied ClickHouse migration", "version", migration.Version, "name", migration.Name)
+	return nil
+}
+
+// recordMigration marks a migration as applied
+func (a *Adapter) recordMigration(ctx context.Context, migration migrations.Migration) error {
+	err := a.conn.Exec(ctx, "INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+		uint32(migration.Version), migration.Name, clock.Now())
+	if err != nil {
+		return fmt.Errorf("failed to record migration %03d_%s: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+-- Baseline schema: every table the adapter uses, as of 014_listing_status.sql. A database without
+-- a listings table gets this schema and 001 to 014 are recorded as applied without running them.
+-- Later schema changes go into new migrations, this file stays as it is.
+
+-- Main listings table with comprehensive pricing structure
+CREATE TABLE IF NOT EXISTS listings (
+    -- Primary identification
+    id String, -- composite "<source_site>:<source_id>", unique across sites
+    source_site String DEFAULT '',
+    source_id String DEFAULT '',
+    created_at DateTime64(3),
+    updated_at DateTime64(3),
+    last_scraped DateTime64(3),
+    source_url String,
+
+    -- Personal information
+    personal_name String DEFAULT '',
+    personal_age UInt8 DEFAULT 0,
+    personal_height UInt16 DEFAULT 0,
+    personal_weight UInt16 DEFAULT 0,
+    personal_breast_size UInt8 DEFAULT 0,
+    personal_hair_color String DEFAULT '',
+    personal_eye_color String DEFAULT '',
+    personal_body_type String DEFAULT '',
+    personal_gender String DEFAULT '',
+    personal_orientation String DEFAULT '',
+
+    -- Contact information
+    contact_phone String DEFAULT '',
+    contact_phone_normalized String DEFAULT '', -- E.164, see clickhouse.NormalizePhone
+    contact_telegram String DEFAULT '',
+    contact_telegram_candidates Array(String) DEFAULT [],
+    contact_telegram_confidence Float32 DEFAULT 0,
+    contact_email String DEFAULT '',
+
+    -- Pricing currency
+    pricing_currency String DEFAULT 'RUB',
+
+    -- Structured pricing - Apartments/Incall Day rates
+    price_apartments_day_hour UInt32 DEFAULT 0,
+    price_apartments_day_2hour UInt32 DEFAULT 0,
+
+    -- Structured pricing - Apartments/Incall Night rates
+    price_apartments_night_hour UInt32 DEFAULT 0,
+    price_apartments_night_2hour UInt32 DEFAULT 0,
+
+    -- Structured pricing - Outcall Day rates
+    price_outcall_day_hour UInt32 DEFAULT 0,
+    price_outcall_day_2hour UInt32 DEFAULT 0,
+
+    -- Structured pricing - Outcall Night rates
+    price_outcall_night_hour UInt32 DEFAULT 0,
+    price_outcall_night_2hour UInt32 DEFAULT 0,
+
+    -- Legacy/computed pricing fields for compatibility
+    price_hour UInt32 DEFAULT 0,
+    price_2_hours UInt32 DEFAULT 0,
+    price_night UInt32 DEFAULT 0,
+    price_day UInt32 DEFAULT 0,
+    price_base UInt32 DEFAULT 0,
+
+    -- Additional pricing data (for any other price types)
+    pricing_duration_prices Map(String, UInt32) DEFAULT map(),
+    pricing_service_prices Map(String, UInt32) DEFAULT map(),
+
+    -- Service information
+    service_available Array(String) DEFAULT [],
+    service_additional Array(String) DEFAULT [],
+    service_restrictions Array(String) DEFAULT [],
+    service_meeting_type String DEFAULT '',
+
+    -- Location information
+    location_metro_stations Array(String) DEFAULT [],
+    location_district String DEFAULT '',
+    location_city String DEFAULT 'Unknown',
+    location_outcall_available Bool DEFAULT false,
+    location_incall_available Bool DEFAULT false,
+    location_availability_source LowCardinality(String) DEFAULT '', -- pricing_table or page_text
+    location_service_area Array(String) DEFAULT [],
+    location_works_in_salon Bool DEFAULT false,
+    location_salon_address String DEFAULT '',
+
+    -- Content information
+    description String DEFAULT '',
+    description_en String DEFAULT '', -- English translation, filled when TRANSLATION_BACKEND is set
+    last_updated String DEFAULT '',
+    photos Array(String) DEFAULT [],
+    photos_count UInt16 DEFAULT 0,
+    -- Fraction of key fields populated, mirrors clickhouse.CompletenessScore
+    completeness Float32 DEFAULT toFloat32(
+        (personal_age > 0)
+        + (greatest(price_hour, price_2_hours, price_night, price_day, price_base) > 0)
+        + (length(photos) > 0)
+        + (length(location_metro_stations) > 0)
+        + (length(contact_phone) > 0)
+    ) / 5,
+    is_deleted Bool DEFAULT false, -- soft delete, the latest version wins
+    status LowCardinality(String) DEFAULT 'active' -- lifecycle: active, removed (page gone) or banned (excluded)
+) ENGINE = ReplacingMergeTree(updated_at)
+ORDER BY (id, location_city)
+PARTITION BY toYYYYMM(created_at)
+SETTINGS index_granularity = 8192;
+
+-- Listing changes log table for tracking modifications
+CREATE TABLE IF NOT EXISTS listing_changes (
+    listing_id String,
+    change_timestamp DateTime64(3) DEFAULT now64(),
+    change_type String,
+    field_name String,
+    old_value String,
+    new_value String,
+    source String DEFAULT ''
+) ENGINE = MergeTree()
+ORDER BY (listing_id, change_timestamp)
+PARTITION BY toYYYYMM(change_timestamp)
+SETTINGS index_granularity = 8192;
+
+-- Card-level price observations from index pages (index-only ingestion mode)
+CREATE TABLE IF NOT EXISTS price_observations (
+    listing_id String,
+    observed_at DateTime64(3),
+    price UInt32,
+    currency String DEFAULT 'RUB',
+    page UInt16 DEFAULT 0,
+    source_url String DEFAULT ''
+) ENGINE = MergeTree()
+ORDER BY (listing_id, observed_at)
+PARTITION BY toYYYYMM(observed_at)
+SETTINGS index_granularity = 8192;
+
+-- Listings that must never be re-ingested; the latest row per listing_id decides
+CREATE TABLE IF NOT EXISTS listing_exclusions (
+    listing_id String,
+    reason String DEFAULT '',
+    created_by String DEFAULT '',
+    created_at DateTime64(3),
+    active Bool DEFAULT true
+) ENGINE = ReplacingMergeTree(created_at)
+ORDER BY listing_id
+SETTINGS index_granularity = 8192;
+
+-- Per-listing pipeline latency (discovery -> scraped -> stored) used for the freshness SLO
+CREATE TABLE IF NOT EXISTS scrape_attempts (
+    listing_id String,
+    source_url String,
+    discovered_at DateTime64(3),
+    scraped_at DateTime64(3),
+    stored_at DateTime64(3),
+    time_to_scraped_ms UInt64 DEFAULT 0,
+    time_to_stored_ms UInt64 DEFAULT 0,
+    status LowCardinality(String),
+    error String DEFAULT ''
+) ENGINE = MergeTree()
+ORDER BY (discovered_at, listing_id)
+PARTITION BY toYYYYMM(discovered_at)
+TTL toDateTime(discovered_at) + INTERVAL 90 DAY
+SETTINGS index_granularity = 8192;
+
+-- Politeness audit: every index page request with the randomized delay slept before it
+CREATE TABLE IF NOT EXISTS crawl_audit (
+    source_site LowCardinality(String),
+    cycle UInt32,
+    cycle_started_at DateTime64(3),
+    page UInt32,
+    url String,
+    base_delay_ms UInt32,
+    max_jitter_ms UInt32,
+    planned_delay_ms UInt32,
+    jitter_ms UInt32,
+    slept_ms UInt32,
+    requested_at DateTime64(3),
+    completed_at DateTime64(3),
+    links UInt32 DEFAULT 0,
+    error String DEFAULT ''
+) ENGINE = MergeTree()
+ORDER BY (source_site, requested_at)
+PARTITION BY toYYYYMM(requested_at)
+TTL toDateTime(requested_at) + INTERVAL 90 DAY
+SETTINGS index_granularity = 8192;
+
+-- Precomputed dashboard numbers; the API reads the newest row
+CREATE TABLE IF NOT EXISTS dashboard_stats (
+    computed_at DateTime64(3),
+    total_listings UInt64,
+    new_listings_today UInt64,
+    active_proxies UInt32,
+    total_proxies UInt32,
+    last_cycle_duration_seconds Float64,
+    last_cycle_finished_at DateTime64(3)
+) ENGINE = MergeTree()
+ORDER BY computed_at
+TTL toDateTime(computed_at) + INTERVAL 30 DAY
+SETTINGS index_granularity = 8192;
+
+-- Perceptual hashes of listing photos for duplicate profile detection (PHOTO_HASH_ENABLED)
+CREATE TABLE IF NOT EXISTS photo_hashes (
+    listing_id String,
+    photo_url String,
+    phash UInt64,
+    dhash UInt64,
+    hashed_at DateTime64(3)
+) ENGINE = ReplacingMergeTree(hashed_at)
+ORDER BY (listing_id, photo_url)
+SETTINGS index_granularity = 8192;
+
+ALTER TABLE listings ADD INDEX IF NOT EXISTS idx_personal_age (personal_age) TYPE minmax GRANULARITY 1;
+ALTER TABLE listings ADD INDEX IF NOT EXISTS idx_price_hour (price_hour) TYPE minmax GRANULARITY 1;
+ALTER TABLE listings ADD INDEX IF NOT EXISTS idx_location_city (location_city) TYPE bloom_filter(0.01) GRANULARITY 1;
+ALTER TABLE listings ADD INDEX IF NOT EXISTS idx_created_at (created_at) TYPE minmax GRANULARITY 1;
+ALTER TABLE listings ADD INDEX IF NOT EXISTS idx_completeness (completeness) TYPE minmax GRANULARITY 1;
+ALTER TABLE listings ADD INDEX IF NOT EXISTS idx_contact_phone_normalized (contact_phone_normalized) TYPE bloom_filter(0.01) GRANULARITY 1;
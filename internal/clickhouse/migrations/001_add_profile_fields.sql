@@ -0,0 +1,8 @@
+-- Adds gender, orientation, outcall service area and salon fields to existing listings tables.
+-- All columns have defaults, so rows written by older binaries remain valid.
+
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS personal_gender String DEFAULT '' AFTER personal_body_type;
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS personal_orientation String DEFAULT '' AFTER personal_gender;
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS location_service_area Array(String) DEFAULT [] AFTER location_incall_available;
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS location_works_in_salon Bool DEFAULT false AFTER location_service_area;
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS location_salon_address String DEFAULT '' AFTER location_works_in_salon;
@@ -0,0 +1,13 @@
+-- Lightweight card-level price series collected by the index-only ingestion mode.
+
+CREATE TABLE IF NOT EXISTS price_observations (
+    listing_id String,
+    observed_at DateTime64(3),
+    price UInt32,
+    currency String DEFAULT 'RUB',
+    page UInt16 DEFAULT 0,
+    source_url String DEFAULT ''
+) ENGINE = MergeTree()
+ORDER BY (listing_id, observed_at)
+PARTITION BY toYYYYMM(observed_at)
+SETTINGS index_granularity = 8192;
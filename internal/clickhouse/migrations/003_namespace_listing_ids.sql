@@ -0,0 +1,34 @@
+-- Namespaces listing IDs by source site so that two sites yielding the same numeric ID no longer collide.
+-- New rows are written with id = '<source_site>:<source_id>'. Because id is part of the sorting key it
+-- cannot be updated in place, so legacy rows are copied under their namespaced ID and the originals deleted.
+--
+-- Legacy rows without a usable source_url (and all legacy change log / price rows, which carry no URL)
+-- are assumed to come from intimcity.gold, the only source scraped before this migration.
+
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS source_site String DEFAULT '' AFTER id;
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS source_id String DEFAULT '' AFTER source_site;
+
+INSERT INTO listings
+SELECT * REPLACE (
+    concat(if(cutToFirstSignificantSubdomain(source_url) = '', 'intimcity.gold', cutToFirstSignificantSubdomain(source_url)), ':', id) AS id,
+    if(cutToFirstSignificantSubdomain(source_url) = '', 'intimcity.gold', cutToFirstSignificantSubdomain(source_url)) AS source_site,
+    id AS source_id
+)
+FROM listings
+WHERE source_site = '' AND position(id, ':') = 0;
+
+ALTER TABLE listings DELETE WHERE source_site = '' AND position(id, ':') = 0;
+
+INSERT INTO listing_changes
+SELECT * REPLACE (concat('intimcity.gold:', listing_id) AS listing_id)
+FROM listing_changes
+WHERE position(listing_id, ':') = 0;
+
+ALTER TABLE listing_changes DELETE WHERE position(listing_id, ':') = 0;
+
+INSERT INTO price_observations
+SELECT * REPLACE (concat('intimcity.gold:', listing_id) AS listing_id)
+FROM price_observations
+WHERE position(listing_id, ':') = 0;
+
+ALTER TABLE price_observations DELETE WHERE position(listing_id, ':') = 0;
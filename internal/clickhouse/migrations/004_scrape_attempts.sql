@@ -0,0 +1,17 @@
+-- Per-listing pipeline latency (discovery -> scraped -> stored) used for the freshness SLO.
+
+CREATE TABLE IF NOT EXISTS scrape_attempts (
+    listing_id String,
+    source_url String,
+    discovered_at DateTime64(3),
+    scraped_at DateTime64(3),
+    stored_at DateTime64(3),
+    time_to_scraped_ms UInt64 DEFAULT 0,
+    time_to_stored_ms UInt64 DEFAULT 0,
+    status LowCardinality(String),
+    error String DEFAULT ''
+) ENGINE = MergeTree()
+ORDER BY (discovered_at, listing_id)
+PARTITION BY toYYYYMM(discovered_at)
+TTL toDateTime(discovered_at) + INTERVAL 90 DAY
+SETTINGS index_granularity = 8192;
@@ -0,0 +1,13 @@
+-- Soft-delete flag for listings and the exclusion list of listings that must never be re-ingested.
+
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS is_deleted Bool DEFAULT false AFTER photos_count;
+
+CREATE TABLE IF NOT EXISTS listing_exclusions (
+    listing_id String,
+    reason String DEFAULT '',
+    created_by String DEFAULT '',
+    created_at DateTime64(3),
+    active Bool DEFAULT true
+) ENGINE = ReplacingMergeTree(created_at)
+ORDER BY listing_id
+SETTINGS index_granularity = 8192;
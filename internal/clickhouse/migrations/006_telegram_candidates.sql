@@ -0,0 +1,4 @@
+-- Raw Telegram handle candidates and the confidence of the validated contact_telegram handle.
+
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS contact_telegram_candidates Array(String) DEFAULT [] AFTER contact_telegram;
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS contact_telegram_confidence Float32 DEFAULT 0 AFTER contact_telegram_candidates;
@@ -0,0 +1,3 @@
+-- English translation of the listing description, written by the optional translation enricher.
+
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS description_en String DEFAULT '' AFTER description;
@@ -0,0 +1,22 @@
+-- Politeness audit: every index page request with the randomized delay slept before it.
+
+CREATE TABLE IF NOT EXISTS crawl_audit (
+    source_site LowCardinality(String),
+    cycle UInt32,
+    cycle_started_at DateTime64(3),
+    page UInt32,
+    url String,
+    base_delay_ms UInt32,
+    max_jitter_ms UInt32,
+    planned_delay_ms UInt32,
+    jitter_ms UInt32,
+    slept_ms UInt32,
+    requested_at DateTime64(3),
+    completed_at DateTime64(3),
+    links UInt32 DEFAULT 0,
+    error String DEFAULT ''
+) ENGINE = MergeTree()
+ORDER BY (source_site, requested_at)
+PARTITION BY toYYYYMM(requested_at)
+TTL toDateTime(requested_at) + INTERVAL 90 DAY
+SETTINGS index_granularity = 8192;
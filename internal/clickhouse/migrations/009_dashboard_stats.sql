@@ -0,0 +1,14 @@
+-- Precomputed dashboard numbers, refreshed every DASHBOARD_STATS_INTERVAL; the API reads the newest row.
+
+CREATE TABLE IF NOT EXISTS dashboard_stats (
+    computed_at DateTime64(3),
+    total_listings UInt64,
+    new_listings_today UInt64,
+    active_proxies UInt32,
+    total_proxies UInt32,
+    last_cycle_duration_seconds Float64,
+    last_cycle_finished_at DateTime64(3)
+) ENGINE = MergeTree()
+ORDER BY computed_at
+TTL toDateTime(computed_at) + INTERVAL 30 DAY
+SETTINGS index_granularity = 8192;
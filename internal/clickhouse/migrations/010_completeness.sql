@@ -0,0 +1,15 @@
+-- Completeness score: fraction of key fields populated (age, price, photos, metro, phone).
+-- The default expression mirrors clickhouse.CompletenessScore so existing rows are scored too.
+
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS completeness Float32 DEFAULT toFloat32(
+    (personal_age > 0)
+    + (greatest(price_hour, price_2_hours, price_night, price_day, price_base) > 0)
+    + (length(photos) > 0)
+    + (length(location_metro_stations) > 0)
+    + (length(contact_phone) > 0)
+) / 5 AFTER photos_count;
+
+ALTER TABLE listings MATERIALIZE COLUMN completeness;
+
+ALTER TABLE listings ADD INDEX IF NOT EXISTS idx_completeness (completeness) TYPE minmax GRANULARITY 1;
+ALTER TABLE listings MATERIALIZE INDEX idx_completeness;
@@ -0,0 +1,4 @@
+-- How outcall/incall availability was determined: from the Апартаменты/Выезд rows of the pricing
+-- table, or from page text keywords for pages without one. Rows stored before are left empty.
+
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS location_availability_source LowCardinality(String) DEFAULT '' AFTER location_incall_available;
@@ -0,0 +1,12 @@
+-- Perceptual hashes of listing photos, written when PHOTO_HASH_ENABLED is set. A photo hashed
+-- again replaces its row; FindDuplicateListings compares the hashes across listings.
+
+CREATE TABLE IF NOT EXISTS photo_hashes (
+    listing_id String,
+    photo_url String,
+    phash UInt64,
+    dhash UInt64,
+    hashed_at DateTime64(3)
+) ENGINE = ReplacingMergeTree(hashed_at)
+ORDER BY (listing_id, photo_url)
+SETTINGS index_granularity = 8192;
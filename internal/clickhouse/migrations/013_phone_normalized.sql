@@ -0,0 +1,22 @@
+-- Contact phone in E.164 form next to the number as written on the page, indexed so the profiles
+-- of one person can be linked (clickhouse.Adapter.GetListingsByPhone).
+
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS contact_phone_normalized String DEFAULT '' AFTER contact_phone;
+
+-- Existing rows get the Russian forms of clickhouse.NormalizePhone: +7, 7 or 8 followed by ten
+-- digits, or ten digits alone. Other numbers and numbers with an extension are normalized when
+-- the listing is scraped again.
+ALTER TABLE listings UPDATE contact_phone_normalized = multiIf(
+    length(replaceRegexpAll(contact_phone, '[^0-9]', '')) = 11
+        AND (match(replaceRegexpAll(contact_phone, '[^0-9]', ''), '^7[3-9]|^89')
+            OR (match(replaceRegexpAll(contact_phone, '[^0-9]', ''), '^8[3-9]') AND NOT startsWith(trimLeft(contact_phone), '+'))),
+    concat('+7', substring(replaceRegexpAll(contact_phone, '[^0-9]', ''), 2)),
+    length(replaceRegexpAll(contact_phone, '[^0-9]', '')) = 10
+        AND match(replaceRegexpAll(contact_phone, '[^0-9]', ''), '^[3-9]')
+        AND NOT startsWith(trimLeft(contact_phone), '+'),
+    concat('+7', replaceRegexpAll(contact_phone, '[^0-9]', '')),
+    ''
+) WHERE contact_phone != '';
+
+ALTER TABLE listings ADD INDEX IF NOT EXISTS idx_contact_phone_normalized (contact_phone_normalized) TYPE bloom_filter(0.01) GRANULARITY 1;
+ALTER TABLE listings MATERIALIZE INDEX idx_contact_phone_normalized;
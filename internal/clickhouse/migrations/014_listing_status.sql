@@ -0,0 +1,11 @@
+-- Lifecycle status of a listing next to the soft-delete flag: active, removed when its page
+-- returned 404 or 410 or redirected to the home page, banned when it is on the exclusion list.
+
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS status LowCardinality(String) DEFAULT 'active' AFTER is_deleted;
+
+-- Soft-deleted rows are banned when their listing is excluded and removed otherwise
+ALTER TABLE listings UPDATE status = if(
+    id IN (SELECT listing_id FROM listing_exclusions FINAL WHERE active),
+    'banned',
+    'removed'
+) WHERE is_deleted;
@@ -0,0 +1,4 @@
+-- Status banner of a listing that stays listed: active, temporarily_unavailable ("временно не
+-- работает") or vacation. Rows stored before read as active until their listing is scraped again.
+
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS availability_status LowCardinality(String) DEFAULT 'active' AFTER status;
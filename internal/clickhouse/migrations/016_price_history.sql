@@ -0,0 +1,18 @@
+-- Price history of every listing: a point when a listing is first stored with prices and whenever
+-- a price column changes, for price trends per listing and per city.
+
+CREATE TABLE IF NOT EXISTS price_history (
+    listing_id String,
+    recorded_at DateTime64(3),
+    location_city LowCardinality(String),
+    currency LowCardinality(String) DEFAULT 'RUB',
+    price_hour UInt32 DEFAULT 0,
+    price_2_hours UInt32 DEFAULT 0,
+    price_night UInt32 DEFAULT 0,
+    price_day UInt32 DEFAULT 0,
+    price_base UInt32 DEFAULT 0,
+    duration_prices Map(String, UInt32)
+) ENGINE = MergeTree()
+ORDER BY (listing_id, recorded_at)
+PARTITION BY toYYYYMM(recorded_at)
+SETTINGS index_granularity = 8192;
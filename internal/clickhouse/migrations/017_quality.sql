@@ -0,0 +1,6 @@
+-- Parse quality of a listing: the flags of the failed validation checks (missing_phone,
+-- age_out_of_range, zero_prices, empty_description, repeated_prices, shared_phone) and a score of 1
+-- minus their penalties. Rows stored before score 1 without flags until their listing is scraped again.
+
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS quality_score Float32 DEFAULT 1 AFTER availability_status;
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS quality_flags Array(LowCardinality(String)) DEFAULT [] AFTER quality_score;
@@ -0,0 +1,121 @@
+// Package migrations holds the versioned ClickHouse schema migrations applied by
+// clickhouse.Adapter.Migrate. Each migration is a NNN_name.sql file of statements separated by
+// semicolons; the files are embedded into the binary.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BaselineVersion is the last migration covered by 000_baseline.sql. A database created from the
+// baseline records the migrations up to it as applied without running them.
+const BaselineVersion = 14
+
+//go:embed *.sql
+var files embed.FS
+
+// Migration is one versioned schema change
+type Migration struct {
+	Version    int
+	Name       string   // the file name without the version and extension, e.g. "listing_status"
+	Statements []string // run one by one, in order
+}
+
+// Load returns the embedded migrations ordered by version
+func Load() ([]Migration, error) {
+	entries, err := files.ReadDir(".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	migrations := make([]Migration, 0, len(entries))
+	versions := make(map[int]string, len(entries))
+	for _, entry := range entries {
+		version, name, err := parseFileName(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		if existing, ok := versions[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", existing, entry.Name(), version)
+		}
+		versions[version] = entry.Name()
+
+		content, err := files.ReadFile(entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+		statements := SplitStatements(string(content))
+		if len(statements) == 0 {
+			return nil, fmt.Errorf("migration %s has no statements", entry.Name())
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, Statements: statements})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// parseFileName splits a migration file name like "014_listing_status.sql" into its version and name
+func parseFileName(fileName string) (int, string, error) {
+	base, ok := strings.CutSuffix(fileName, ".sql")
+	if !ok {
+		return 0, "", fmt.Errorf("migration %s is not an .sql file", fileName)
+	}
+	prefix, name, ok := strings.Cut(base, "_")
+	if !ok || name == "" {
+		return 0, "", fmt.Errorf("migration %s is not named NNN_name.sql", fileName)
+	}
+	version, err := strconv.Atoi(prefix)
+	if err != nil || version < 0 {
+		return 0, "", fmt.Errorf("migration %s has an invalid version %q", fileName, prefix)
+	}
+	return version, name, nil
+}
+
+// SplitStatements splits SQL into its statements at semicolons, dropping "--" comments and
+// blank statements. Semicolons and dashes inside single-quoted strings are kept.
+func SplitStatements(sql string) []string {
+	var statements []string
+	var current strings.Builder
+	flush := func() {
+		if statement := strings.TrimSpace(current.String()); statement != "" {
+			statements = append(statements, statement)
+		}
+		current.Reset()
+	}
+
+	inString := false
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case inString:
+			current.WriteByte(c)
+			if c == '\\' && i+1 < len(sql) {
+				i++
+				current.WriteByte(sql[i])
+			} else if c == '\'' {
+				inString = false
+			}
+		case c == '\'':
+			inString = true
+			current.WriteByte(c)
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+			current.WriteByte('\n')
+		case c == ';':
+			flush()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+	return statements
+}
@@ -0,0 +1,77 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	sql := `-- Header comment; not a statement
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS a String DEFAULT ';' AFTER id; -- trailing comment
+
+ALTER TABLE listings UPDATE a = replaceRegexpAll(a, '--', '') WHERE a != 'it\'s';
+;
+`
+	statements := SplitStatements(sql)
+	expected := []string{
+		"ALTER TABLE listings ADD COLUMN IF NOT EXISTS a String DEFAULT ';' AFTER id",
+		"ALTER TABLE listings UPDATE a = replaceRegexpAll(a, '--', '') WHERE a != 'it\\'s'",
+	}
+	if len(statements) != len(expected) {
+		t.Fatalf("Expected %d statements, got %d: %q", len(expected), len(statements), statements)
+	}
+	for i := range expected {
+		if statements[i] != expected[i] {
+			t.Errorf("Expected statement %d to be %q, got %q", i, expected[i], statements[i])
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	all, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	for i, migration := range all {
+		if migration.Version != i {
+			t.Errorf("Expected migration %d at position %d, got version %d", i, i, migration.Version)
+		}
+		for _, statement := range migration.Statements {
+			if strings.Contains(statement, "--") {
+				t.Errorf("Expected comments to be stripped from %03d_%s, got %q", migration.Version, migration.Name, statement)
+			}
+		}
+	}
+	if len(all) <= BaselineVersion {
+		t.Fatalf("Expected migrations up to the baseline version %d, got %d", BaselineVersion, len(all))
+	}
+
+	baseline := all[0]
+	if baseline.Name != "baseline" {
+		t.Errorf("Expected migration 0 to be the baseline, got %s", baseline.Name)
+	}
+	for _, table := range []string{"listings", "listing_changes", "listing_exclusions"} {
+		found := false
+		for _, statement := range baseline.Statements {
+			if strings.HasPrefix(statement, "CREATE TABLE IF NOT EXISTS "+table+" (") {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected the baseline to create %s", table)
+		}
+	}
+}
+
+func TestParseFileName(t *testing.T) {
+	version, name, err := parseFileName("014_listing_status.sql")
+	if err != nil || version != 14 || name != "listing_status" {
+		t.Errorf("Expected version 14 named listing_status, got %d %q %v", version, name, err)
+	}
+	for _, fileName := range []string{"listing_status.sql", "014.sql", "x14_status.sql", "014_status.txt"} {
+		if _, _, err := parseFileName(fileName); err == nil {
+			t.Errorf("%s: expected an error", fileName)
+		}
+	}
+}
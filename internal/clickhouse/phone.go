@@ -0,0 +1,118 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidPhone is returned when a phone number cannot be normalized
+var ErrInvalidPhone = errors.New("invalid phone number")
+
+// maxPhoneListings caps the listings returned for one phone number
+const maxPhoneListings = 1000
+
+// phoneExtensionMarkers start the extension part of a phone number, which is dropped
+var phoneExtensionMarkers = []string{"доб", "ext", "x", "#"}
+
+// NormalizePhone returns a phone number in E.164 form ("+79991234567"), or "" when it is not a
+// valid number. Numbers without a country code are taken as Russian, the way they are dialled
+// there: with the trunk prefix 8 ("8 (999) 123-45-67"), with the country code and no plus
+// ("79991234567"), as ten national digits ("999 123-45-67") or with the 810 international prefix;
+// "+8 999 ..." is read as a Russian mobile number too. Punctuation and an extension ("доб. 12")
+// are ignored.
+func NormalizePhone(raw string) string {
+	lower := strings.ToLower(raw)
+	for _, marker := range phoneExtensionMarkers {
+		if i := strings.Index(lower, marker); i > 0 {
+			lower = lower[:i]
+		}
+	}
+
+	international := false
+	var digits strings.Builder
+	for _, r := range lower {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' && digits.Len() == 0:
+			international = true
+		}
+	}
+	number := digits.String()
+
+	switch {
+	case international && len(number) == 11 && strings.HasPrefix(number, "89"):
+		// A Russian mobile number with the trunk prefix mistaken for a country code; no country code starts with 89
+		number = "7" + number[1:]
+	case !international:
+		switch {
+		case len(number) > 11 && strings.HasPrefix(number, "810"):
+			number = number[3:]
+		case len(number) == 11 && (number[0] == '8' || number[0] == '7'):
+			number = "7" + number[1:]
+		case len(number) == 10:
+			number = "7" + number
+		default:
+			return ""
+		}
+	}
+
+	if strings.HasPrefix(number, "7") {
+		// Russia and Kazakhstan: ten national digits, area codes never start with 0, 1 or 2
+		if len(number) != 11 || number[1] < '3' {
+			return ""
+		}
+		return "+" + number
+	}
+
+	// Other countries: E.164 allows up to 15 digits and country codes never start with 0
+	if len(number) < 8 || len(number) > 15 || number[0] == '0' {
+		return ""
+	}
+	return "+" + number
+}
+
+// GetListingsByPhone returns the live listings advertising a phone number, most recently scraped
+// first. The number may be given in any form NormalizePhone accepts, so the profiles of one person
+// are linked whatever way each of them writes the number.
+func (a *Adapter) GetListingsByPhone(ctx context.Context, phone string) ([]*FlattenedListing, error) {
+	normalized := NormalizePhone(phone)
+	if normalized == "" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidPhone, phone)
+	}
+
+	query := `
+		SELECT ` + a.schema().columns + `
+		FROM listings
+		FINAL
+		WHERE ` + a.schema().column("contact_phone_normalized") + ` = ? AND ` + a.schema().live() + `
+		ORDER BY last_scraped DESC
+		LIMIT ?
+	`
+
+	ctx, cancel := a.begin(ctx, OperationQuery)
+	defer cancel()
+
+	rows, err := a.conn.Query(ctx, query, normalized, maxPhoneListings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get listings by phone: %w", a.queryError(ctx, OperationQuery, err))
+	}
+	defer rows.Close()
+
+	var listings []*FlattenedListing
+	for rows.Next() {
+		flattened, err := a.schema().scan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan listing: %w", err)
+		}
+		listings = append(listings, flattened)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate listings: %w", a.queryError(ctx, OperationQuery, err))
+	}
+
+	return listings, nil
+}
@@ -0,0 +1,49 @@
+package clickhouse
+
+import (
+	"testing"
+
+	listing "github.com/gregor-tokarev/hoe_parser/proto"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		raw      string
+		expected string
+	}{
+		{"+79991234567", "+79991234567"},
+		{"+7 (999) 123-45-67", "+79991234567"},
+		{"8 (999) 123-45-67", "+79991234567"},
+		{"79991234567", "+79991234567"},
+		{"999 123 45 67", "+79991234567"},
+		{"8-812-123-45-67", "+78121234567"},
+		{"+8 999 123-45-67", "+79991234567"},
+		{"8 495 123-45-67 доб. 12", "+74951234567"},
+		{"+7 999 123-45-67 ext 5", "+79991234567"},
+		{"8107 701 234 5678", "+77012345678"},
+		{"+375 29 123-45-67", "+375291234567"},
+		{"+7 199 123-45-67", ""},
+		{"123-45-67", ""},
+		{"+12", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := NormalizePhone(test.raw); got != test.expected {
+			t.Errorf("NormalizePhone(%q): expected %q, got %q", test.raw, test.expected, got)
+		}
+	}
+}
+
+func TestFlattenKeepsRawAndNormalizedPhone(t *testing.T) {
+	flattened := Flatten(&listing.Listing{
+		Id:          "123",
+		ContactInfo: &listing.ContactInfo{Phone: "8 (999) 123-45-67"},
+	}, "https://example.com/anketa123.htm")
+
+	if flattened.ContactPhone != "8 (999) 123-45-67" {
+		t.Errorf("Expected the raw phone to be kept, got %q", flattened.ContactPhone)
+	}
+	if flattened.ContactPhoneNormalized != "+79991234567" {
+		t.Errorf("Expected normalized phone +79991234567, got %q", flattened.ContactPhoneNormalized)
+	}
+}
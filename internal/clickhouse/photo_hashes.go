@@ -0,0 +1,179 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DuplicatePhotoDistance is the largest number of bits the pHash and the dHash of two photos may
+// each differ in for the photos to count as the same picture
+const DuplicatePhotoDistance = 10
+
+// maxDuplicateListings caps the duplicates returned for one listing
+const maxDuplicateListings = 100
+
+// PhotoHash holds the perceptual hashes of one listing photo
+type PhotoHash struct {
+	ListingID string
+	PhotoURL  string
+	PHash     uint64
+	DHash     uint64
+	HashedAt  time.Time
+}
+
+// DuplicateListing is a listing sharing near-identical photos with the listing it was found for
+type DuplicateListing struct {
+	ListingID      string `json:"listing_id"`
+	MatchingPhotos uint64 `json:"matching_photos"` // photos of the queried listing with a near-identical copy here
+	Distance       uint8  `json:"distance"`        // smallest pHash distance between matching photos, 0 for identical
+}
+
+// InsertPhotoHashes writes photo hashes to the photo_hashes table; a photo hashed again replaces its row
+func (a *Adapter) InsertPhotoHashes(ctx context.Context, hashes []PhotoHash) error {
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	ctx, cancel := a.begin(ctx, OperationInsert)
+	defer cancel()
+
+	batch, err := a.conn.PrepareBatch(ctx, `
+		INSERT INTO photo_hashes (
+			listing_id, photo_url, phash, dhash, hashed_at
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare photo hash batch: %w", a.queryError(ctx, OperationInsert, err))
+	}
+
+	for _, hash := range hashes {
+		if err := batch.Append(hash.ListingID, hash.PhotoURL, hash.PHash, hash.DHash, hash.HashedAt); err != nil {
+			return fmt.Errorf("failed to append photo hash for listing %s: %w", hash.ListingID, err)
+		}
+	}
+
+	if err := batch.Send(); err != nil {
+		return fmt.Errorf("failed to send photo hash batch: %w", a.queryError(ctx, OperationInsert, err))
+	}
+
+	return nil
+}
+
+// FindDuplicateListings returns the live listings having a photo within DuplicatePhotoDistance of
+// a photo of listing id, those with the most matching photos first. Listings whose photos were not
+// hashed yet have no duplicates.
+func (a *Adapter) FindDuplicateListings(ctx context.Context, id string) ([]DuplicateListing, error) {
+	return a.findDuplicateListings(ctx, id, Scope{})
+}
+
+// FindDuplicateListings returns the duplicates of a listing in the scope; duplicates outside it are left out
+func (s *ScopedAdapter) FindDuplicateListings(ctx context.Context, id string) ([]DuplicateListing, error) {
+	return s.adapter.findDuplicateListings(ctx, id, s.scope)
+}
+
+// findDuplicateListings returns the duplicates of a listing visible in scope
+func (a *Adapter) findDuplicateListings(ctx context.Context, id string, scope Scope) ([]DuplicateListing, error) {
+	if _, err := a.getListing(ctx, id, scope); err != nil {
+		return nil, err
+	}
+
+	candidates, err := a.photoMatches(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return []DuplicateListing{}, nil
+	}
+
+	ids := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		ids[i] = candidate.ListingID
+	}
+	visible, err := a.liveListingIDs(ctx, ids, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	duplicates := []DuplicateListing{}
+	for _, candidate := range candidates {
+		if visible[candidate.ListingID] {
+			duplicates = append(duplicates, candidate)
+		}
+	}
+	return duplicates, nil
+}
+
+// photoMatches compares the photo hashes of listing id with those of every other listing
+func (a *Adapter) photoMatches(ctx context.Context, id string) ([]DuplicateListing, error) {
+	query := `
+		SELECT
+			other.listing_id,
+			uniqExact(mine.photo_url) AS matching_photos,
+			min(bitCount(bitXor(mine.phash, other.phash))) AS distance
+		FROM (SELECT photo_url, phash, dhash FROM photo_hashes FINAL WHERE listing_id = ?) AS mine
+		CROSS JOIN (SELECT listing_id, phash, dhash FROM photo_hashes FINAL WHERE listing_id != ?) AS other
+		WHERE bitCount(bitXor(mine.phash, other.phash)) <= ? AND bitCount(bitXor(mine.dhash, other.dhash)) <= ?
+		GROUP BY other.listing_id
+		ORDER BY matching_photos DESC, distance, other.listing_id
+		LIMIT ?
+	`
+
+	ctx, cancel := a.begin(ctx, OperationAnalytics)
+	defer cancel()
+
+	rows, err := a.conn.Query(ctx, query, id, id, DuplicatePhotoDistance, DuplicatePhotoDistance, maxDuplicateListings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find duplicate listings: %w", a.queryError(ctx, OperationAnalytics, err))
+	}
+	defer rows.Close()
+
+	var matches []DuplicateListing
+	for rows.Next() {
+		var match DuplicateListing
+		if err := rows.Scan(&match.ListingID, &match.MatchingPhotos, &match.Distance); err != nil {
+			return nil, fmt.Errorf("failed to scan duplicate listing: %w", err)
+		}
+		matches = append(matches, match)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate duplicate listings: %w", a.queryError(ctx, OperationAnalytics, err))
+	}
+
+	return matches, nil
+}
+
+// liveListingIDs returns which of ids are listings visible in scope and not deleted
+func (a *Adapter) liveListingIDs(ctx context.Context, ids []string, scope Scope) (map[string]bool, error) {
+	where, args := scope.where("id IN (?) AND "+a.schema().live(), ids)
+	query := `
+		SELECT id
+		FROM listings
+		FINAL
+		` + where
+
+	ctx, cancel := a.begin(ctx, OperationQuery)
+	defer cancel()
+
+	rows, err := a.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check duplicate listings: %w", a.queryError(ctx, OperationQuery, err))
+	}
+	defer rows.Close()
+
+	live := make(map[string]bool, len(ids))
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan listing id: %w", err)
+		}
+		live[id] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate listing ids: %w", a.queryError(ctx, OperationQuery, err))
+	}
+
+	return live, nil
+}
@@ -0,0 +1,169 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PricePoint is the prices of a listing from one scrape, a point of its price history
+type PricePoint struct {
+	ListingID      string            `json:"listing_id"`
+	RecordedAt     time.Time         `json:"recorded_at"`
+	City           string            `json:"city"`
+	Currency       string            `json:"currency"`
+	PriceHour      uint32            `json:"price_hour"`
+	Price2Hours    uint32            `json:"price_2_hours"`
+	PriceNight     uint32            `json:"price_night"`
+	PriceDay       uint32            `json:"price_day"`
+	PriceBase      uint32            `json:"price_base"`
+	DurationPrices map[string]uint32 `json:"duration_prices"`
+}
+
+// NewPricePoint returns the prices of a stored listing version, recorded at its last scrape
+func NewPricePoint(f *FlattenedListing) PricePoint {
+	return PricePoint{
+		ListingID:      f.ID,
+		RecordedAt:     f.LastScraped,
+		City:           f.LocationCity,
+		Currency:       f.PricingCurrency,
+		PriceHour:      f.PriceHour,
+		Price2Hours:    f.Price2Hours,
+		PriceNight:     f.PriceNight,
+		PriceDay:       f.PriceDay,
+		PriceBase:      f.PriceBase,
+		DurationPrices: f.PricingDurationPrices,
+	}
+}
+
+// isPriceColumn reports whether a change of a listings column changes the price history
+func isPriceColumn(column string) bool {
+	return strings.HasPrefix(column, "price_") || column == "pricing_duration_prices" || column == "pricing_currency"
+}
+
+// hasPrices reports whether a listing has at least one duration price
+func hasPrices(f *FlattenedListing) bool {
+	for _, price := range f.PricingDurationPrices {
+		if price > 0 {
+			return true
+		}
+	}
+	return f.PriceHour > 0 || f.Price2Hours > 0 || f.PriceNight > 0 || f.PriceDay > 0 || f.PriceBase > 0
+}
+
+// pricePoints returns the price history points of stored listing versions: a point for every new
+// listing with prices, as the first of its series, and for every listing whose prices changed.
+// changes holds the changed columns of each listing, nil for a listing stored for the first time.
+func pricePoints(listings []*FlattenedListing, changes [][]FieldChange) []PricePoint {
+	var points []PricePoint
+	for i, listing := range listings {
+		record := changes[i] == nil && hasPrices(listing)
+		for _, change := range changes[i] {
+			record = record || isPriceColumn(change.Field)
+		}
+		if record {
+			points = append(points, NewPricePoint(listing))
+		}
+	}
+	return points
+}
+
+// logPriceHistory writes the price history points of stored listing versions. The versions are
+// already stored, so a failure is only reported.
+func (a *Adapter) logPriceHistory(ctx context.Context, listings []*FlattenedListing, changes [][]FieldChange) {
+	points := pricePoints(listings, changes)
+	if err := a.InsertPriceHistory(ctx, points); err != nil {
+		log.WarnContext(ctx, "Failed to record price history", "points", len(points), "error", err)
+	}
+}
+
+// InsertPriceHistory writes price history points to the price_history table in one batch
+func (a *Adapter) InsertPriceHistory(ctx context.Context, points []PricePoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	ctx, cancel := a.begin(ctx, OperationInsert)
+	defer cancel()
+
+	batch, err := a.conn.PrepareBatch(ctx, `
+		INSERT INTO price_history (
+			listing_id, recorded_at, location_city, currency,
+			price_hour, price_2_hours, price_night, price_day, price_base, duration_prices
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare price history batch: %w", a.queryError(ctx, OperationInsert, err))
+	}
+
+	for _, point := range points {
+		durationPrices := point.DurationPrices
+		if durationPrices == nil {
+			durationPrices = map[string]uint32{}
+		}
+		err := batch.Append(point.ListingID, point.RecordedAt, point.City, point.Currency,
+			point.PriceHour, point.Price2Hours, point.PriceNight, point.PriceDay, point.PriceBase, durationPrices)
+		if err != nil {
+			return fmt.Errorf("failed to append price point for listing %s: %w", point.ListingID, err)
+		}
+	}
+
+	if err := batch.Send(); err != nil {
+		return fmt.Errorf("failed to send price history batch: %w", a.queryError(ctx, OperationInsert, err))
+	}
+
+	return nil
+}
+
+// GetPriceHistory returns the price history of a listing, oldest first. Soft-deleted listings are
+// reported as not found, like GetListingByID does.
+func (a *Adapter) GetPriceHistory(ctx context.Context, id string) ([]PricePoint, error) {
+	return a.priceHistory(ctx, id, Scope{})
+}
+
+// GetPriceHistory returns the price history of a listing in the scope
+func (s *ScopedAdapter) GetPriceHistory(ctx context.Context, id string) ([]PricePoint, error) {
+	return s.adapter.priceHistory(ctx, id, s.scope)
+}
+
+// priceHistory returns the price history of a listing visible in scope
+func (a *Adapter) priceHistory(ctx context.Context, id string, scope Scope) ([]PricePoint, error) {
+	if _, err := a.getListing(ctx, id, scope); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT listing_id, recorded_at, location_city, currency,
+			price_hour, price_2_hours, price_night, price_day, price_base, duration_prices
+		FROM price_history
+		WHERE listing_id = ?
+		ORDER BY recorded_at
+	`
+
+	ctx, cancel := a.begin(ctx, OperationQuery)
+	defer cancel()
+
+	rows, err := a.conn.Query(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query price history: %w", a.queryError(ctx, OperationQuery, err))
+	}
+	defer rows.Close()
+
+	points := []PricePoint{}
+	for rows.Next() {
+		var point PricePoint
+		err := rows.Scan(&point.ListingID, &point.RecordedAt, &point.City, &point.Currency,
+			&point.PriceHour, &point.Price2Hours, &point.PriceNight, &point.PriceDay, &point.PriceBase, &point.DurationPrices)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan price point: %w", err)
+		}
+		point.RecordedAt = point.RecordedAt.UTC()
+		points = append(points, point)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read price history: %w", a.queryError(ctx, OperationQuery, err))
+	}
+
+	return points, nil
+}
@@ -0,0 +1,35 @@
+package clickhouse
+
+import "testing"
+
+func TestPricePoints(t *testing.T) {
+	priced := &FlattenedListing{ID: "site:1", PriceHour: 7000, PricingDurationPrices: map[string]uint32{"apartments_day_hour": 7000}}
+	unpriced := &FlattenedListing{ID: "site:2"}
+	repriced := &FlattenedListing{ID: "site:3", PriceHour: 8000}
+	described := &FlattenedListing{ID: "site:4", PriceHour: 9000}
+	dropped := &FlattenedListing{ID: "site:5"}
+
+	points := pricePoints(
+		[]*FlattenedListing{priced, unpriced, repriced, described, dropped},
+		[][]FieldChange{
+			nil,
+			nil,
+			{{Field: "price_hour", OldValue: "7000", NewValue: "8000"}},
+			{{Field: "description", OldValue: "a", NewValue: "b"}},
+			{{Field: "pricing_duration_prices", OldValue: `{"hour":5000}`, NewValue: ""}},
+		},
+	)
+
+	expected := []string{"site:1", "site:3", "site:5"}
+	if len(points) != len(expected) {
+		t.Fatalf("Expected %d price points, got %d: %+v", len(expected), len(points), points)
+	}
+	for i, id := range expected {
+		if points[i].ListingID != id {
+			t.Errorf("Expected point %d for %s, got %s", i, id, points[i].ListingID)
+		}
+	}
+	if points[1].PriceHour != 8000 {
+		t.Errorf("Expected the new hourly price 8000, got %d", points[1].PriceHour)
+	}
+}
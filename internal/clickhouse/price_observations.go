@@ -0,0 +1,49 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PriceObservation is a single card-level price seen on an index page
+type PriceObservation struct {
+	ListingID  string
+	SourceURL  string
+	Price      uint32
+	Currency   string
+	Page       uint16
+	ObservedAt time.Time
+}
+
+// InsertPriceObservations writes index-page price observations to the price_observations table
+func (a *Adapter) InsertPriceObservations(ctx context.Context, observations []PriceObservation) error {
+	if len(observations) == 0 {
+		return nil
+	}
+
+	ctx, cancel := a.begin(ctx, OperationInsert)
+	defer cancel()
+
+	batch, err := a.conn.PrepareBatch(ctx, `
+		INSERT INTO price_observations (
+			listing_id, observed_at, price, currency, page, source_url
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare price observation batch: %w", a.queryError(ctx, OperationInsert, err))
+	}
+
+	for _, obs := range observations {
+		err := batch.Append(obs.ListingID, obs.ObservedAt, obs.Price, obs.Currency, obs.Page, obs.SourceURL)
+		if err != nil {
+			return fmt.Errorf("failed to append price observation for listing %s: %w", obs.ListingID, err)
+		}
+	}
+
+	if err := batch.Send(); err != nil {
+		return fmt.Errorf("failed to send price observation batch: %w", a.queryError(ctx, OperationInsert, err))
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+)
+
+// Quality flags of a listing, stored in the quality_flags column
+const (
+	FlagMissingPhone     = "missing_phone"
+	FlagAgeOutOfRange    = "age_out_of_range"
+	FlagZeroPrices       = "zero_prices"
+	FlagEmptyDescription = "empty_description"
+	FlagRepeatedPrices   = "repeated_prices" // every duration price is the same, usually a misread price table
+	FlagSharedPhone      = "shared_phone"    // the phone is on more live listings than the limit of FlagSharedPhoneIfCommon
+)
+
+// QualityFlags lists every quality flag in the order ValidateListing reports them
+var QualityFlags = []string{FlagMissingPhone, FlagAgeOutOfRange, FlagZeroPrices, FlagEmptyDescription, FlagRepeatedPrices, FlagSharedPhone}
+
+// qualityPenalties is what each flag takes off a perfect quality score of 1
+var qualityPenalties = map[string]float32{
+	FlagMissingPhone:     0.3,
+	FlagAgeOutOfRange:    0.2,
+	FlagZeroPrices:       0.3,
+	FlagEmptyDescription: 0.1,
+	FlagRepeatedPrices:   0.2,
+	FlagSharedPhone:      0.2,
+}
+
+// Plausible advertised ages; a parsed age outside them is most likely another number of the page
+const (
+	MinPlausibleAge = 18
+	MaxPlausibleAge = 70
+)
+
+// minRepeatedPrices is the number of equal duration prices from which they count as repeated
+const minRepeatedPrices = 3
+
+// FieldQuality is the pseudo key field reporting whether a listing passed validation, tracked by
+// parser coverage alerts like the key fields of KeyFields
+const FieldQuality = "quality"
+
+// QualityPassScore is the lowest quality score of a listing that passed validation
+const QualityPassScore float32 = 0.7
+
+// ValidateListing returns the quality flags of a parsed listing, in QualityFlags order.
+// FlagSharedPhone needs the stored listings and is added by FlagSharedPhoneIfCommon.
+func ValidateListing(f *FlattenedListing) []string {
+	var flags []string
+	if f.ContactPhone == "" {
+		flags = append(flags, FlagMissingPhone)
+	}
+	if f.PersonalAge > 0 && (f.PersonalAge < MinPlausibleAge || f.PersonalAge > MaxPlausibleAge) {
+		flags = append(flags, FlagAgeOutOfRange)
+	}
+	if !KeyFields(f)[FieldPrice] {
+		flags = append(flags, FlagZeroPrices)
+	}
+	if f.Description == "" {
+		flags = append(flags, FlagEmptyDescription)
+	}
+	if repeatedPrices(f.PricingDurationPrices) {
+		flags = append(flags, FlagRepeatedPrices)
+	}
+	return flags
+}
+
+// repeatedPrices reports whether at least minRepeatedPrices duration prices are set and all equal
+func repeatedPrices(prices map[string]uint32) bool {
+	var first uint32
+	count := 0
+	for _, price := range prices {
+		if price == 0 {
+			continue
+		}
+		if count > 0 && price != first {
+			return false
+		}
+		first = price
+		count++
+	}
+	return count >= minRepeatedPrices
+}
+
+// QualityScore returns 1 minus the penalties of flags, at least 0
+func QualityScore(flags []string) float32 {
+	score := float32(1)
+	for _, flag := range flags {
+		score -= qualityPenalties[flag]
+	}
+	if score < 0 {
+		return 0
+	}
+	return score
+}
+
+// setQuality stores flags and their score on the listing
+func (f *FlattenedListing) setQuality(flags []string) {
+	f.QualityFlags = flags
+	f.QualityScore = QualityScore(flags)
+}
+
+// FlagSharedPhoneIfCommon adds FlagSharedPhone to a listing whose phone is advertised by more than
+// limit other live listings, which points at a phone of the site or an agency read as the
+// listing's own. A limit of 0 disables the check.
+func (a *Adapter) FlagSharedPhoneIfCommon(ctx context.Context, f *FlattenedListing, limit int) error {
+	if limit <= 0 || f.ContactPhoneNormalized == "" {
+		return nil
+	}
+
+	query := `
+		SELECT count()
+		FROM listings
+		FINAL
+		WHERE ` + a.schema().column("contact_phone_normalized") + ` = ? AND id != ? AND ` + a.schema().live() + `
+	`
+
+	ctx, cancel := a.begin(ctx, OperationQuery)
+	defer cancel()
+
+	var others uint64
+	if err := a.conn.QueryRow(ctx, query, f.ContactPhoneNormalized, f.ID).Scan(&others); err != nil {
+		return fmt.Errorf("failed to count listings by phone: %w", a.queryError(ctx, OperationQuery, err))
+	}
+	if others > uint64(limit) {
+		f.setQuality(append(f.QualityFlags, FlagSharedPhone))
+	}
+	return nil
+}
+
+// countByQualityFlag counts the live listings carrying each quality flag, 0 for flags no listing has
+func (a *Adapter) countByQualityFlag(ctx context.Context, scope Scope) (map[string]uint64, error) {
+	counts := make(map[string]uint64, len(QualityFlags))
+	for _, flag := range QualityFlags {
+		counts[flag] = 0
+	}
+	if a.schema().missing["quality_flags"] {
+		return counts, nil
+	}
+
+	where, args := scope.where(a.schema().live())
+	query := `
+		SELECT flag, count()
+		FROM listings
+		FINAL
+		ARRAY JOIN quality_flags AS flag
+		` + where + `
+		GROUP BY flag
+	`
+
+	ctx, cancel := a.begin(ctx, OperationAnalytics)
+	defer cancel()
+
+	rows, err := a.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count listings by quality flag: %w", a.queryError(ctx, OperationAnalytics, err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var flag string
+		var count uint64
+		if err := rows.Scan(&flag, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan quality flag count: %w", err)
+		}
+		counts[flag] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate quality flag counts: %w", a.queryError(ctx, OperationAnalytics, err))
+	}
+	return counts, nil
+}
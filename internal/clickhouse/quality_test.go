@@ -0,0 +1,53 @@
+package clickhouse
+
+import (
+	"reflect"
+	"testing"
+
+	listing "github.com/gregor-tokarev/hoe_parser/proto"
+)
+
+func TestValidateListing(t *testing.T) {
+	adapter := &Adapter{}
+
+	good := adapter.FlattenListing(&listing.Listing{
+		Id:           "1",
+		Description:  "Привет",
+		PersonalInfo: &listing.PersonalInfo{Age: 25},
+		ContactInfo:  &listing.ContactInfo{Phone: "+79990000000"},
+		PricingInfo:  &listing.PricingInfo{DurationPrices: map[string]int32{"apartments_day_hour": 5000, "apartments_day_2hour": 9000}},
+	}, "https://example.com/anketa1.htm")
+	if len(good.QualityFlags) != 0 || good.QualityScore != 1 {
+		t.Errorf("Expected no flags and score 1, got %v and %v", good.QualityFlags, good.QualityScore)
+	}
+
+	bad := adapter.FlattenListing(&listing.Listing{
+		Id:           "2",
+		PersonalInfo: &listing.PersonalInfo{Age: 170},
+	}, "https://example.com/anketa2.htm")
+	expected := []string{FlagMissingPhone, FlagAgeOutOfRange, FlagZeroPrices, FlagEmptyDescription}
+	if !reflect.DeepEqual(bad.QualityFlags, expected) {
+		t.Errorf("Expected flags %v, got %v", expected, bad.QualityFlags)
+	}
+	if bad.QualityScore < 0.09 || bad.QualityScore > 0.11 {
+		t.Errorf("Expected score 0.1, got %v", bad.QualityScore)
+	}
+}
+
+func TestRepeatedPrices(t *testing.T) {
+	if !repeatedPrices(map[string]uint32{"a": 3000, "b": 3000, "c": 3000, "d": 0}) {
+		t.Errorf("Expected three equal prices to count as repeated")
+	}
+	if repeatedPrices(map[string]uint32{"a": 3000, "b": 3000}) {
+		t.Errorf("Expected two equal prices not to count as repeated")
+	}
+	if repeatedPrices(map[string]uint32{"a": 3000, "b": 3000, "c": 6000}) {
+		t.Errorf("Expected different prices not to count as repeated")
+	}
+}
+
+func TestQualityScoreFloor(t *testing.T) {
+	if score := QualityScore(QualityFlags); score != 0 {
+		t.Errorf("Expected score 0 with every flag, got %v", score)
+	}
+}
@@ -0,0 +1,199 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// listingSchema is the part of listingColumns an adapter reads and writes. It is every column
+// unless the adapter runs in schema compatibility mode against a listings table that lacks some.
+type listingSchema struct {
+	present      []bool          // by position in listingColumns
+	missing      map[string]bool // columns left out of column lists and replaced in expressions, see column
+	columns      string          // the present columns, for SELECT and INSERT column lists
+	placeholders string          // a "?" per present column
+}
+
+// fullListingSchema reads and writes every listing column
+var fullListingSchema = newListingSchema(nil)
+
+// listingColumnNames returns the columns of listingColumns in order
+func listingColumnNames() []string {
+	names := strings.Split(listingColumns, ",")
+	for i, name := range names {
+		names[i] = strings.TrimSpace(name)
+	}
+	return names
+}
+
+// newListingSchema creates a schema leaving out the missing columns
+func newListingSchema(missing map[string]bool) *listingSchema {
+	names := listingColumnNames()
+	schema := &listingSchema{present: make([]bool, len(names)), missing: missing}
+
+	var columns []string
+	for i, name := range names {
+		if missing[name] {
+			continue
+		}
+		schema.present[i] = true
+		columns = append(columns, name)
+	}
+	schema.columns = strings.Join(columns, ", ")
+	schema.placeholders = strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	return schema
+}
+
+// filter returns the entries of a listingColumns ordered list that belong to present columns
+func (s *listingSchema) filter(all []any) []any {
+	kept := make([]any, 0, len(all))
+	for i, value := range all {
+		if s.present[i] {
+			kept = append(kept, value)
+		}
+	}
+	return kept
+}
+
+// values returns the fields of a listing for the present columns, for INSERT statements
+func (s *listingSchema) values(f *FlattenedListing) []any {
+	return s.filter(f.values())
+}
+
+// scan scans a row selected with the present columns into a FlattenedListing; missing columns
+// are left at their zero value
+func (s *listingSchema) scan(row rowScanner) (*FlattenedListing, error) {
+	var flattened FlattenedListing
+	if err := row.Scan(s.filter(flattened.pointers())...); err != nil {
+		return nil, err
+	}
+
+	// The driver returns times in the server's time zone; everything past the adapter is UTC
+	flattened.CreatedAt = flattened.CreatedAt.UTC()
+	flattened.UpdatedAt = flattened.UpdatedAt.UTC()
+	flattened.LastScraped = flattened.LastScraped.UTC()
+	return &flattened, nil
+}
+
+// dropMissing removes the changes to columns the schema leaves out: a missing column always
+// reads as its zero value, so it would otherwise differ from every scraped listing
+func (s *listingSchema) dropMissing(changes []FieldChange) []FieldChange {
+	if len(s.missing) == 0 {
+		return changes
+	}
+
+	kept := changes[:0]
+	for _, change := range changes {
+		if !s.missing[change.Field] {
+			kept = append(kept, change)
+		}
+	}
+	return kept
+}
+
+// missingLiterals are the values missing columns stand for in WHERE clauses, aggregates and
+// ORDER BY: the zero value scan reads for them, or the column default the statistics rely on
+var missingLiterals = map[string]string{
+	"is_deleted":               "false",
+	"completeness":             "toFloat32(0)",
+	"contact_phone_normalized": "''",
+	"quality_score":            "toFloat32(1)",
+}
+
+// requiredListingColumns are the columns no statement works without: listings are keyed by id,
+// and scopes and per-site queries filter on source_site
+var requiredListingColumns = []string{"id", "source_site"}
+
+// column returns name for use in an expression, or the literal a missing column stands for, so
+// queries filtering or aggregating on it still run against a table that lacks it
+func (s *listingSchema) column(name string) string {
+	if literal, ok := missingLiterals[name]; ok && s.missing[name] {
+		return literal
+	}
+	return name
+}
+
+// live returns the condition matching listings that are not soft-deleted; every listing is live in
+// a table without is_deleted
+func (s *listingSchema) live() string {
+	return "NOT " + s.column("is_deleted")
+}
+
+// schema returns the listing columns the adapter reads and writes
+func (a *Adapter) schema() *listingSchema {
+	if a.listingSchema == nil {
+		return fullListingSchema
+	}
+	return a.listingSchema
+}
+
+// loadListingSchema reads the columns of the listings table and leaves the listing columns it
+// lacks out of column lists, and replaces them in expressions, so a new binary runs against a table
+// whose migrations are not applied yet. The missing columns are logged once.
+func (a *Adapter) loadListingSchema(ctx context.Context) error {
+	query := `
+		SELECT name
+		FROM system.columns
+		WHERE database = currentDatabase() AND table = 'listings'
+	`
+
+	ctx, cancel := a.begin(ctx, OperationQuery)
+	defer cancel()
+
+	rows, err := a.conn.Query(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to read listings columns: %w", a.queryError(ctx, OperationQuery, err))
+	}
+	defer rows.Close()
+
+	available := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("failed to scan listings column: %w", err)
+		}
+		available[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate listings columns: %w", a.queryError(ctx, OperationQuery, err))
+	}
+
+	missing, err := missingListingColumns(available)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for _, name := range listingColumnNames() {
+			if missing[name] {
+				names = append(names, name)
+			}
+		}
+		log.Warn("Listings table lacks columns, leaving them out of reads and writes until its migrations are applied",
+			"missing", strings.Join(names, ","))
+	}
+	a.listingSchema = newListingSchema(missing)
+	return nil
+}
+
+// missingListingColumns returns the listing columns not in available. The table has to exist
+// and have the requiredListingColumns.
+func missingListingColumns(available map[string]bool) (map[string]bool, error) {
+	if len(available) == 0 {
+		return nil, fmt.Errorf("listings table not found")
+	}
+	for _, name := range requiredListingColumns {
+		if !available[name] {
+			return nil, fmt.Errorf("listings table has no %s column", name)
+		}
+	}
+
+	missing := make(map[string]bool)
+	for _, name := range listingColumnNames() {
+		if !available[name] {
+			missing[name] = true
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,138 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// fakeRow scans fixed values into string and bool destinations
+type fakeRow struct {
+	values []any
+}
+
+func (r *fakeRow) Scan(dest ...any) error {
+	for i, value := range r.values {
+		switch target := dest[i].(type) {
+		case *string:
+			*target = value.(string)
+		case *bool:
+			*target = value.(bool)
+		}
+	}
+	return nil
+}
+
+func TestListingSchemaLeavesOutMissingColumns(t *testing.T) {
+	available := make(map[string]bool)
+	for _, name := range listingColumnNames() {
+		available[name] = true
+	}
+	delete(available, "description_en")
+	delete(available, "contact_phone_normalized")
+
+	missing, err := missingListingColumns(available)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	schema := newListingSchema(missing)
+
+	if strings.Contains(schema.columns, "description_en") || strings.Contains(schema.columns, "contact_phone_normalized") {
+		t.Errorf("Expected missing columns left out, got %s", schema.columns)
+	}
+	columns := strings.Split(schema.columns, ",")
+	if len(columns) != len(listingColumnNames())-2 {
+		t.Errorf("Expected %d columns, got %d", len(listingColumnNames())-2, len(columns))
+	}
+	if placeholders := strings.Split(schema.placeholders, ","); len(placeholders) != len(columns) {
+		t.Errorf("Expected %d placeholders, got %d", len(columns), len(placeholders))
+	}
+
+	flattened := &FlattenedListing{ID: "intimcity.gold:1", DescriptionEn: "text"}
+	values := schema.values(flattened)
+	if len(values) != len(columns) || values[0] != "intimcity.gold:1" {
+		t.Errorf("Expected %d values starting with the ID, got %v", len(columns), values)
+	}
+
+	// Only the present columns are scanned, in order
+	row := &fakeRow{values: []any{"intimcity.gold:2", "intimcity.gold", "2"}}
+	scanned, err := schema.scan(row)
+	if err != nil || scanned.ID != "intimcity.gold:2" || scanned.SourceID != "2" {
+		t.Errorf("Expected the scanned ID and source ID, got %+v, %v", scanned, err)
+	}
+
+	changes := schema.dropMissing([]FieldChange{{Field: "description_en"}, {Field: "price_hour"}})
+	if len(changes) != 1 || changes[0].Field != "price_hour" {
+		t.Errorf("Expected only the price_hour change, got %+v", changes)
+	}
+
+	if _, err := missingListingColumns(map[string]bool{}); err == nil {
+		t.Errorf("Expected an error for a missing listings table")
+	}
+	if _, err := missingListingColumns(map[string]bool{"source_url": true}); err == nil {
+		t.Errorf("Expected an error for a table without an id column")
+	}
+	if _, err := missingListingColumns(map[string]bool{"id": true, "source_url": true}); err == nil {
+		t.Errorf("Expected an error for a table without a source_site column")
+	}
+}
+
+// errRecorded fails every statement of recordingConn
+var errRecorded = errors.New("recorded")
+
+// recordingConn records the statements sent to it and fails them
+type recordingConn struct {
+	clickhouse.Conn
+	queries []string
+}
+
+func (c *recordingConn) Query(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+	c.queries = append(c.queries, query)
+	return nil, errRecorded
+}
+
+func (c *recordingConn) QueryRow(ctx context.Context, query string, args ...any) driver.Row {
+	c.queries = append(c.queries, query)
+	return failedRow{}
+}
+
+// failedRow is a row whose query failed
+type failedRow struct{}
+
+func (failedRow) Err() error             { return errRecorded }
+func (failedRow) Scan(dest ...any) error { return errRecorded }
+func (failedRow) ScanStruct(any) error   { return errRecorded }
+
+func TestListingSchemaReadPathsLeaveOutMissingColumns(t *testing.T) {
+	missing := map[string]bool{"is_deleted": true, "completeness": true, "contact_phone_normalized": true,
+		"quality_score": true, "quality_flags": true}
+	conn := &recordingConn{}
+	adapter := &Adapter{conn: conn, listingSchema: newListingSchema(missing)}
+	ctx := context.Background()
+
+	adapter.GetStats(ctx)
+	adapter.countByStatus(ctx, Scope{})
+	adapter.QueryListings(ctx, ListingQuery{MinQuality: 0.5, Sort: "-completeness"})
+	adapter.GetStaleListings(ctx, "intimcity.gold", time.Now(), 10)
+	adapter.GetListingsWithoutPhotos(ctx, 10)
+	adapter.GetListingsByPhone(ctx, "+7 999 123-45-67")
+	adapter.FlagSharedPhoneIfCommon(ctx, &FlattenedListing{ID: "intimcity.gold:1", ContactPhoneNormalized: "+79991234567"}, 3)
+
+	if len(conn.queries) != 7 {
+		t.Fatalf("Expected 7 statements, got %d", len(conn.queries))
+	}
+	for name := range missing {
+		column := regexp.MustCompile(`\b` + name + `\b`)
+		for _, query := range conn.queries {
+			if column.MatchString(query) {
+				t.Errorf("Expected %s left out, got %s", name, query)
+			}
+		}
+	}
+}
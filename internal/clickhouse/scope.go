@@ -0,0 +1,158 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Field groups that can be hidden from a scoped reader
+const (
+	FieldGroupContact     = "contact"     // phone, telegram, email
+	FieldGroupPhotos      = "photos"      // photo URLs
+	FieldGroupDescription = "description" // free-text description
+	FieldGroupSourceURL   = "source_url"  // link back to the original listing
+)
+
+// Scope restricts which listings and fields a reader may see.
+// The zero value allows everything.
+type Scope struct {
+	Cities       []string // allowed location_city values, empty means all
+	Sites        []string // allowed source_site values, empty means all
+	HiddenFields []string // field groups blanked in results
+}
+
+// IsUnrestricted reports whether the scope allows every listing and field
+func (s Scope) IsUnrestricted() bool {
+	return len(s.Cities) == 0 && len(s.Sites) == 0 && len(s.HiddenFields) == 0
+}
+
+// Validate checks that all hidden field groups are known
+func (s Scope) Validate() error {
+	for _, group := range s.HiddenFields {
+		switch group {
+		case FieldGroupContact, FieldGroupPhotos, FieldGroupDescription, FieldGroupSourceURL:
+		default:
+			return fmt.Errorf("unknown field group %q", group)
+		}
+	}
+	return nil
+}
+
+// conditions returns SQL conditions (joined with AND, without a leading keyword) and their arguments
+func (s Scope) conditions() (string, []any) {
+	var clauses []string
+	var args []any
+
+	if len(s.Cities) > 0 {
+		clauses = append(clauses, "location_city IN (?)")
+		args = append(args, s.Cities)
+	}
+	if len(s.Sites) > 0 {
+		clauses = append(clauses, "source_site IN (?)")
+		args = append(args, s.Sites)
+	}
+
+	return strings.Join(clauses, " AND "), args
+}
+
+// Allows reports whether a listing is visible in the scope, like the scope conditions do in queries
+func (s Scope) Allows(f *FlattenedListing) bool {
+	return (len(s.Cities) == 0 || slices.Contains(s.Cities, f.LocationCity)) &&
+		(len(s.Sites) == 0 || slices.Contains(s.Sites, f.SourceSite))
+}
+
+// where returns a WHERE clause combining base with the scope conditions
+func (s Scope) where(base string, baseArgs ...any) (string, []any) {
+	conditions, args := s.conditions()
+
+	switch {
+	case base == "" && conditions == "":
+		return "", baseArgs
+	case base == "":
+		return "WHERE " + conditions, args
+	case conditions == "":
+		return "WHERE " + base, baseArgs
+	default:
+		return "WHERE " + base + " AND " + conditions, append(baseArgs, args...)
+	}
+}
+
+// hides reports whether a field group is hidden by the scope
+func (s Scope) hides(group string) bool {
+	for _, hidden := range s.HiddenFields {
+		if hidden == group {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldGroupColumns are the listings columns of each field group
+var fieldGroupColumns = map[string][]string{
+	FieldGroupContact: {"contact_phone", "contact_phone_normalized", "contact_telegram", "contact_telegram_candidates",
+		"contact_telegram_confidence", "contact_email"},
+	FieldGroupPhotos:      {"photos"},
+	FieldGroupDescription: {"description", "description_en"},
+	FieldGroupSourceURL:   {"source_url"},
+}
+
+// hidesColumn reports whether a listings column belongs to a field group hidden by the scope
+func (s Scope) hidesColumn(column string) bool {
+	for group, columns := range fieldGroupColumns {
+		if s.hides(group) && slices.Contains(columns, column) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply blanks the fields hidden by the scope
+func (s Scope) Apply(f *FlattenedListing) {
+	if s.hides(FieldGroupContact) {
+		f.ContactPhone = ""
+		f.ContactPhoneNormalized = ""
+		f.ContactTelegram = ""
+		f.ContactTelegramCandidates = nil
+		f.ContactTelegramConfidence = 0
+		f.ContactEmail = ""
+	}
+	if s.hides(FieldGroupPhotos) {
+		f.Photos = nil
+	}
+	if s.hides(FieldGroupDescription) {
+		f.Description = ""
+		f.DescriptionEn = ""
+	}
+	if s.hides(FieldGroupSourceURL) {
+		f.SourceURL = ""
+	}
+}
+
+// ScopedAdapter is a read-only view of the adapter restricted to a Scope.
+// All externally exposed reads go through it so restrictions are enforced in one place.
+type ScopedAdapter struct {
+	adapter *Adapter
+	scope   Scope
+}
+
+// WithScope returns a read-only view of the adapter restricted to scope
+func (a *Adapter) WithScope(scope Scope) *ScopedAdapter {
+	return &ScopedAdapter{adapter: a, scope: scope}
+}
+
+// Scope returns the restrictions applied by the view
+func (s *ScopedAdapter) Scope() Scope {
+	return s.scope
+}
+
+// GetListingByID retrieves a listing by ID; listings outside the scope are reported as not found
+func (s *ScopedAdapter) GetListingByID(ctx context.Context, id string) (*FlattenedListing, error) {
+	return s.adapter.getListing(ctx, id, s.scope)
+}
+
+// GetStats returns statistics over the listings visible in the scope
+func (s *ScopedAdapter) GetStats(ctx context.Context) (map[string]interface{}, error) {
+	return s.adapter.getStats(ctx, s.scope)
+}
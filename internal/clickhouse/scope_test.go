@@ -0,0 +1,82 @@
+package clickhouse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScopeWhere(t *testing.T) {
+	where, args := Scope{}.where("id = ?", "a:1")
+	if where != "WHERE id = ?" || len(args) != 1 {
+		t.Errorf("Expected unscoped id filter, got %q %v", where, args)
+	}
+
+	scope := Scope{Cities: []string{"Москва"}, Sites: []string{"intimcity.gold"}}
+	where, args = scope.where("id = ?", "a:1")
+	expected := "WHERE id = ? AND location_city IN (?) AND source_site IN (?)"
+	if where != expected {
+		t.Errorf("Expected %q, got %q", expected, where)
+	}
+	if !reflect.DeepEqual(args, []any{"a:1", []string{"Москва"}, []string{"intimcity.gold"}}) {
+		t.Errorf("Unexpected args: %v", args)
+	}
+
+	where, _ = scope.where("")
+	if where != "WHERE location_city IN (?) AND source_site IN (?)" {
+		t.Errorf("Expected scope-only filter, got %q", where)
+	}
+}
+
+func TestScopeAllows(t *testing.T) {
+	scope := Scope{Cities: []string{"Москва"}, Sites: []string{"intimcity.gold"}}
+	if !scope.Allows(&FlattenedListing{LocationCity: "Москва", SourceSite: "intimcity.gold"}) {
+		t.Errorf("Expected a listing in the scope to be allowed")
+	}
+	if scope.Allows(&FlattenedListing{LocationCity: "Казань", SourceSite: "intimcity.gold"}) {
+		t.Errorf("Expected a listing in another city to be refused")
+	}
+	if !(Scope{}).Allows(&FlattenedListing{LocationCity: "Казань"}) {
+		t.Errorf("Expected the empty scope to allow every listing")
+	}
+}
+
+func TestScopeApplyHidesFieldGroups(t *testing.T) {
+	listing := &FlattenedListing{
+		ContactPhone:    "+79990000000",
+		ContactTelegram: "@name",
+		Photos:          []string{"a.jpg"},
+		Description:     "text",
+		PersonalName:    "Name",
+	}
+
+	Scope{HiddenFields: []string{FieldGroupContact, FieldGroupPhotos}}.Apply(listing)
+
+	if listing.ContactPhone != "" || listing.ContactTelegram != "" {
+		t.Errorf("Expected contact fields to be hidden, got %q %q", listing.ContactPhone, listing.ContactTelegram)
+	}
+	if listing.Photos != nil {
+		t.Errorf("Expected photos to be hidden, got %v", listing.Photos)
+	}
+	if listing.Description != "text" || listing.PersonalName != "Name" {
+		t.Errorf("Expected other fields to be kept")
+	}
+
+	if err := (Scope{HiddenFields: []string{"passwords"}}).Validate(); err == nil {
+		t.Errorf("Expected unknown field group to be rejected")
+	}
+}
+
+func TestScopeHidesColumn(t *testing.T) {
+	scope := Scope{HiddenFields: []string{FieldGroupContact, FieldGroupDescription}}
+
+	for _, column := range []string{"contact_phone", "contact_telegram_candidates", "description_en"} {
+		if !scope.hidesColumn(column) {
+			t.Errorf("Expected %s to be hidden", column)
+		}
+	}
+	for _, column := range []string{"photos", "source_url", "price_hour"} {
+		if scope.hidesColumn(column) {
+			t.Errorf("Expected %s to be visible", column)
+		}
+	}
+}
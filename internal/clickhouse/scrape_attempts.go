@@ -0,0 +1,67 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Scrape attempt outcomes
+const (
+	AttemptStored       = "stored"
+	AttemptScrapeFailed = "scrape_failed"
+	AttemptInsertFailed = "insert_failed"
+	AttemptRemoved      = "removed" // the listing page no longer exists
+)
+
+// ScrapeAttempt records the timing of one listing through the pipeline, from discovery on an
+// index page to being stored. ScrapedAt and StoredAt are zero when the stage was not reached.
+type ScrapeAttempt struct {
+	ListingID    string
+	SourceURL    string
+	DiscoveredAt time.Time
+	ScrapedAt    time.Time
+	StoredAt     time.Time
+	Status       string
+	Error        string
+}
+
+// TimeToScraped returns the discovery to scraped latency, or 0 if the listing was not scraped
+func (s *ScrapeAttempt) TimeToScraped() time.Duration {
+	if s.ScrapedAt.IsZero() {
+		return 0
+	}
+	return s.ScrapedAt.Sub(s.DiscoveredAt)
+}
+
+// TimeToStored returns the discovery to stored latency, or 0 if the listing was not stored
+func (s *ScrapeAttempt) TimeToStored() time.Duration {
+	if s.StoredAt.IsZero() {
+		return 0
+	}
+	return s.StoredAt.Sub(s.DiscoveredAt)
+}
+
+// InsertScrapeAttempt writes a scrape attempt with its per-stage latencies to scrape_attempts
+func (a *Adapter) InsertScrapeAttempt(ctx context.Context, attempt *ScrapeAttempt) error {
+	query := `
+		INSERT INTO scrape_attempts (
+			listing_id, source_url, discovered_at, scraped_at, stored_at,
+			time_to_scraped_ms, time_to_stored_ms, status, error
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	ctx, cancel := a.begin(ctx, OperationInsert)
+	defer cancel()
+
+	err := a.conn.Exec(ctx, query,
+		attempt.ListingID, attempt.SourceURL, attempt.DiscoveredAt, attempt.ScrapedAt, attempt.StoredAt,
+		uint64(attempt.TimeToScraped().Milliseconds()), uint64(attempt.TimeToStored().Milliseconds()),
+		attempt.Status, attempt.Error,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert scrape attempt for %s: %w", attempt.SourceURL, a.queryError(ctx, OperationInsert, err))
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// StaleListing is a live listing that has not been scraped since a threshold
+type StaleListing struct {
+	ID          string
+	SourceURL   string
+	LastScraped time.Time
+}
+
+// GetStaleListings returns up to limit live listings of a site that were not scraped since
+// scrapedBefore, least recently scraped first. An unchanged listing is not rewritten when it is
+// scraped again, so a successful scrape attempt since scrapedBefore counts as a scrape too.
+func (a *Adapter) GetStaleListings(ctx context.Context, sourceSite string, scrapedBefore time.Time, limit int) ([]StaleListing, error) {
+	query := `
+		SELECT id, source_url, last_scraped
+		FROM listings
+		FINAL
+		WHERE source_site = ? AND ` + a.schema().live() + ` AND last_scraped < ?
+			AND id NOT IN (
+				SELECT listing_id
+				FROM scrape_attempts
+				WHERE scraped_at >= ? AND status = ?
+			)
+		ORDER BY last_scraped ASC
+		LIMIT ?
+	`
+
+	ctx, cancel := a.begin(ctx, OperationQuery)
+	defer cancel()
+
+	rows, err := a.conn.Query(ctx, query, sourceSite, scrapedBefore, scrapedBefore, AttemptStored, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stale listings: %w", a.queryError(ctx, OperationQuery, err))
+	}
+	defer rows.Close()
+
+	var listings []StaleListing
+	for rows.Next() {
+		var stale StaleListing
+		if err := rows.Scan(&stale.ID, &stale.SourceURL, &stale.LastScraped); err != nil {
+			return nil, fmt.Errorf("failed to scan stale listing: %w", err)
+		}
+		listings = append(listings, stale)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate stale listings: %w", a.queryError(ctx, OperationQuery, err))
+	}
+
+	return listings, nil
+}
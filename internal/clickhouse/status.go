@@ -0,0 +1,71 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+)
+
+// Listing lifecycle statuses, stored in the status column
+const (
+	StatusActive  = "active"  // listed on its site
+	StatusRemoved = "removed" // its page is gone: 404, 410 or a redirect to the home page
+	StatusBanned  = "banned"  // on the exclusion list
+)
+
+// Availability statuses, stored in the availability_status column. They are read from the status
+// banner of a listing that stays listed, so an unavailable listing keeps its lifecycle status.
+const (
+	AvailabilityActive                 = "active"
+	AvailabilityTemporarilyUnavailable = "temporarily_unavailable" // "временно не работает"
+	AvailabilityVacation               = "vacation"
+)
+
+// ReportedStatuses are the keys of the listings_by_status statistic in the order they are
+// reported: the lifecycle statuses, with the active listings behind a status banner split out
+var ReportedStatuses = []string{StatusActive, AvailabilityTemporarilyUnavailable, AvailabilityVacation, StatusRemoved, StatusBanned}
+
+// ReportedStatus returns the listings_by_status key a listing counts under. An active listing
+// that is temporarily unavailable or on vacation is not counted as active.
+func ReportedStatus(status, availability string) string {
+	if status == StatusActive && (availability == AvailabilityTemporarilyUnavailable || availability == AvailabilityVacation) {
+		return availability
+	}
+	return status
+}
+
+// statusOf returns the status of a stored listing version. Versions written before the status
+// column existed read as active, or removed when they are soft-deleted.
+func statusOf(f *FlattenedListing) string {
+	switch {
+	case f.Status != "":
+		return f.Status
+	case f.IsDeleted:
+		return StatusRemoved
+	default:
+		return StatusActive
+	}
+}
+
+// MarkListingRemoved soft-deletes a listing whose page no longer exists on its site and logs the
+// status change. It reports false when the listing was never stored or is already removed. Like
+// any soft-deleted listing, it becomes active again if its page is scraped again.
+func (a *Adapter) MarkListingRemoved(ctx context.Context, listingID string) (bool, error) {
+	previous, removed, err := a.softDelete(ctx, listingID, StatusRemoved)
+	if errors.Is(err, ErrListingNotFound) {
+		return false, nil
+	}
+	if err != nil || !removed {
+		return false, err
+	}
+
+	a.logStatusChange(ctx, listingID, previous, StatusRemoved, ChangeSourceScraper)
+	return true, nil
+}
+
+// logStatusChange writes the update entry of a status transition to the change log. The new
+// version is already stored, so a failed entry is only reported.
+func (a *Adapter) logStatusChange(ctx context.Context, listingID, from, to, source string) {
+	if err := a.LogChange(ctx, listingID, "update", from, to, "status", source); err != nil {
+		log.WarnContext(ctx, "Failed to log listing status change", "listing_id", listingID, "from", from, "to", to, "error", err)
+	}
+}
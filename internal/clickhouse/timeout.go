@@ -0,0 +1,130 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/gregor-tokarev/hoe_parser/internal/metrics"
+)
+
+// Operation classifies adapter queries by their timeout budget
+type Operation string
+
+const (
+	OperationInsert    Operation = "insert"    // writes
+	OperationQuery     Operation = "query"     // point lookups by key
+	OperationAnalytics Operation = "analytics" // scans and aggregations
+)
+
+// Default per-operation timeouts, used when the Config leaves them at zero
+const (
+	DefaultInsertTimeout    = 30 * time.Second
+	DefaultQueryTimeout     = 10 * time.Second
+	DefaultAnalyticsTimeout = 60 * time.Second
+)
+
+// ClickHouse exception codes reported when a query exceeds max_execution_time
+const (
+	codeTimeoutExceeded = 159 // TIMEOUT_EXCEEDED
+	codeTooSlow         = 160 // TOO_SLOW, estimated execution time exceeds the limit
+)
+
+// ErrQueryTimeout is matched by every error caused by a query running out of time
+var ErrQueryTimeout = errors.New("clickhouse query timed out")
+
+// QueryTimeoutError is returned when a query exceeds its deadline, either client side or via max_execution_time
+type QueryTimeoutError struct {
+	Operation Operation
+	Timeout   time.Duration // configured timeout of the operation; a caller deadline may have been shorter
+	Err       error
+}
+
+func (e *QueryTimeoutError) Error() string {
+	return fmt.Sprintf("clickhouse %s query timed out (timeout %s): %v", e.Operation, e.Timeout, e.Err)
+}
+
+func (e *QueryTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Is makes errors.Is(err, ErrQueryTimeout) match
+func (e *QueryTimeoutError) Is(target error) bool {
+	return target == ErrQueryTimeout
+}
+
+// timeout returns the configured timeout for an operation
+func (a *Adapter) timeout(op Operation) time.Duration {
+	var configured time.Duration
+	switch op {
+	case OperationInsert:
+		configured = a.config.InsertTimeout
+	case OperationQuery:
+		configured = a.config.QueryTimeout
+	case OperationAnalytics:
+		configured = a.config.AnalyticsTimeout
+	}
+
+	if configured > 0 {
+		return configured
+	}
+
+	switch op {
+	case OperationInsert:
+		return DefaultInsertTimeout
+	case OperationQuery:
+		return DefaultQueryTimeout
+	default:
+		return DefaultAnalyticsTimeout
+	}
+}
+
+// begin bounds ctx by the operation timeout (a shorter caller deadline wins) and attaches the
+// per-query settings: max_execution_time derived from the remaining time, plus insert tuning for writes.
+// Releasing the context records the duration of the operation.
+func (a *Adapter) begin(ctx context.Context, op Operation) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout(op))
+
+	settings := clickhouse.Settings{}
+	if op == OperationInsert {
+		settings = a.insertSettings()
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		settings["max_execution_time"] = max(1, int(math.Ceil(time.Until(deadline).Seconds())))
+	}
+
+	// The operation lasts until the caller releases its context
+	start := time.Now()
+	done := func() {
+		metrics.ObserveClickHouse(string(op), time.Since(start))
+		cancel()
+	}
+	return clickhouse.Context(ctx, clickhouse.WithSettings(settings)), done
+}
+
+// queryError converts deadline and max_execution_time failures of an operation into a QueryTimeoutError
+func (a *Adapter) queryError(ctx context.Context, op Operation, err error) error {
+	if err == nil || !isTimeout(ctx, err) {
+		return err
+	}
+	return &QueryTimeoutError{Operation: op, Timeout: a.timeout(op), Err: err}
+}
+
+// isTimeout reports whether err was caused by the query running out of time
+func isTimeout(ctx context.Context, err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return true
+	}
+
+	var exception *clickhouse.Exception
+	if errors.As(err, &exception) && (exception.Code == codeTimeoutExceeded || exception.Code == codeTooSlow) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
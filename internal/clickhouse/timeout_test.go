@@ -0,0 +1,66 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+func TestBeginUsesShorterDeadline(t *testing.T) {
+	a := &Adapter{config: Config{QueryTimeout: time.Minute}}
+
+	ctx, cancel := a.begin(context.Background(), OperationQuery)
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > time.Minute {
+		t.Errorf("Expected the configured query timeout as deadline, got %v", deadline)
+	}
+
+	callerCtx, callerCancel := context.WithTimeout(context.Background(), time.Second)
+	defer callerCancel()
+	ctx, cancel = a.begin(callerCtx, OperationQuery)
+	defer cancel()
+	if deadline, _ := ctx.Deadline(); time.Until(deadline) > time.Second {
+		t.Errorf("Expected the caller deadline to win, got %v", time.Until(deadline))
+	}
+}
+
+func TestTimeoutDefaults(t *testing.T) {
+	a := &Adapter{config: Config{InsertTimeout: 5 * time.Second}}
+
+	if got := a.timeout(OperationInsert); got != 5*time.Second {
+		t.Errorf("Expected configured insert timeout 5s, got %v", got)
+	}
+	if got := a.timeout(OperationAnalytics); got != DefaultAnalyticsTimeout {
+		t.Errorf("Expected default analytics timeout, got %v", got)
+	}
+}
+
+func TestQueryErrorTyping(t *testing.T) {
+	a := &Adapter{}
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		err     error
+		timeout bool
+	}{
+		{"deadline", fmt.Errorf("read: %w", context.DeadlineExceeded), true},
+		{"server timeout", &clickhouse.Exception{Code: codeTimeoutExceeded, Message: "Timeout exceeded"}, true},
+		{"syntax error", &clickhouse.Exception{Code: 62, Message: "Syntax error"}, false},
+		{"plain", errors.New("connection refused"), false},
+	}
+
+	for _, tt := range tests {
+		err := a.queryError(ctx, OperationAnalytics, tt.err)
+		if errors.Is(err, ErrQueryTimeout) != tt.timeout {
+			t.Errorf("%s: expected timeout=%v, got %v", tt.name, tt.timeout, err)
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected the original error to be wrapped, got %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package clickhouse
+
+import listing "github.com/gregor-tokarev/hoe_parser/proto"
+
+// Unflatten returns the listing message of a stored listing, the inverse of Flatten. The message
+// carries the composite ID the API addresses listings by. Columns without a message field, such as
+// the derived prices, completeness and quality, are left out.
+func Unflatten(f *FlattenedListing) *listing.Listing {
+	durationPrices := make(map[string]int32, len(f.PricingDurationPrices))
+	for k, v := range f.PricingDurationPrices {
+		durationPrices[k] = int32(v)
+	}
+	servicePrices := make(map[string]int32, len(f.PricingServicePrices))
+	for k, v := range f.PricingServicePrices {
+		servicePrices[k] = int32(v)
+	}
+
+	return &listing.Listing{
+		Id: f.ID,
+		PersonalInfo: &listing.PersonalInfo{
+			Name:        f.PersonalName,
+			Age:         int32(f.PersonalAge),
+			Height:      int32(f.PersonalHeight),
+			Weight:      int32(f.PersonalWeight),
+			BreastSize:  int32(f.PersonalBreastSize),
+			HairColor:   f.PersonalHairColor,
+			EyeColor:    f.PersonalEyeColor,
+			BodyType:    f.PersonalBodyType,
+			Gender:      f.PersonalGender,
+			Orientation: f.PersonalOrientation,
+		},
+		ContactInfo: &listing.ContactInfo{
+			Phone:              f.ContactPhone,
+			Telegram:           f.ContactTelegram,
+			Email:              f.ContactEmail,
+			TelegramCandidates: f.ContactTelegramCandidates,
+			TelegramConfidence: f.ContactTelegramConfidence,
+		},
+		PricingInfo: &listing.PricingInfo{
+			DurationPrices: durationPrices,
+			ServicePrices:  servicePrices,
+			Currency:       f.PricingCurrency,
+		},
+		ServiceInfo: &listing.ServiceInfo{
+			AvailableServices:  f.ServiceAvailable,
+			AdditionalServices: f.ServiceAdditional,
+			Restrictions:       f.ServiceRestrictions,
+			MeetingType:        f.ServiceMeetingType,
+		},
+		LocationInfo: &listing.LocationInfo{
+			MetroStations:      f.LocationMetroStations,
+			District:           f.LocationDistrict,
+			City:               f.LocationCity,
+			OutcallAvailable:   f.LocationOutcallAvailable,
+			IncallAvailable:    f.LocationIncallAvailable,
+			ServiceArea:        f.LocationServiceArea,
+			WorksInSalon:       f.LocationWorksInSalon,
+			SalonAddress:       f.LocationSalonAddress,
+			AvailabilitySource: f.LocationAvailabilitySource,
+		},
+		Description:        f.Description,
+		LastUpdated:        f.LastUpdated,
+		Photos:             f.Photos,
+		DescriptionEn:      f.DescriptionEn,
+		AvailabilityStatus: f.AvailabilityStatus,
+	}
+}
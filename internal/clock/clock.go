@@ -0,0 +1,82 @@
+// Package clock is the source of the timestamps the pipeline records. Every timestamp is in UTC,
+// whatever the server's local time zone, and tests can fix the time with SetClock.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the current time
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock reads the system time
+type systemClock struct{}
+
+// Now returns the current system time in UTC
+func (systemClock) Now() time.Time {
+	return time.Now().UTC()
+}
+
+// System is the clock used unless SetClock replaces it
+var System Clock = systemClock{}
+
+var (
+	mutex   sync.RWMutex
+	current = System
+)
+
+// SetClock replaces the clock behind Now and returns a function restoring the previous one
+func SetClock(clock Clock) (restore func()) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	previous := current
+	current = clock
+	return func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+		current = previous
+	}
+}
+
+// Now returns the current time of the configured clock in UTC
+func Now() time.Time {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return current.Now().UTC()
+}
+
+// Fixed is a clock that only moves when told to, for deterministic tests
+type Fixed struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+// NewFixed creates a clock standing at now
+func NewFixed(now time.Time) *Fixed {
+	return &Fixed{now: now}
+}
+
+// Now returns the time the clock stands at
+func (f *Fixed) Now() time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.now
+}
+
+// Set moves the clock to now
+func (f *Fixed) Set(now time.Time) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.now = now
+}
+
+// Advance moves the clock forward by d
+func (f *Fixed) Advance(d time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.now = f.now.Add(d)
+}
@@ -0,0 +1,30 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetClock(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	fixed := NewFixed(time.Date(2024, 3, 1, 2, 30, 0, 0, moscow))
+
+	restore := SetClock(fixed)
+	now := Now()
+	if now.Location() != time.UTC {
+		t.Errorf("Expected Now to be in UTC, got %v", now.Location())
+	}
+	if !now.Equal(time.Date(2024, 2, 29, 23, 30, 0, 0, time.UTC)) {
+		t.Errorf("Expected the fixed time, got %v", now)
+	}
+
+	fixed.Advance(time.Hour)
+	if got := Now(); got.Hour() != 0 || got.Day() != 1 {
+		t.Errorf("Expected the clock to move by an hour, got %v", got)
+	}
+
+	restore()
+	if got := Now(); got.Sub(time.Now()) > time.Minute || got.Location() != time.UTC {
+		t.Errorf("Expected the system clock in UTC after restore, got %v", got)
+	}
+}
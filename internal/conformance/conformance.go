@@ -0,0 +1,253 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/scraper"
+)
+
+// goldenSuffix is the file name suffix of the expected values next to a fixture page
+const goldenSuffix = ".golden.json"
+
+// volatileFields are listings columns set at flatten time, never compared or written to golden files
+var volatileFields = map[string]bool{
+	"created_at":   true,
+	"updated_at":   true,
+	"last_scraped": true,
+}
+
+// Golden holds the URL a fixture page was stored from and the field values its listing must have
+type Golden struct {
+	URL    string         `json:"url"`
+	Fields map[string]any `json:"fields"` // listings columns by FlattenedListing JSON name
+}
+
+// Fixture is a stored listing page with its golden file
+type Fixture struct {
+	Name       string
+	PagePath   string
+	GoldenPath string
+	Golden     Golden
+}
+
+// Mismatch is a field whose extracted value differs from the golden value
+type Mismatch struct {
+	Field    string
+	Expected any
+	Got      any
+}
+
+// Result is the outcome of one fixture
+type Result struct {
+	Fixture    string
+	Checked    int // golden fields compared
+	Mismatches []Mismatch
+	Err        error // the page could not be read or parsed
+}
+
+// Passed reports whether the fixture parsed and every golden field matched
+func (r Result) Passed() bool {
+	return r.Err == nil && len(r.Mismatches) == 0
+}
+
+// Report is the conformance of one site's extractor to its fixtures
+type Report struct {
+	Site    string
+	Results []Result
+}
+
+// Passed reports whether every fixture passed; a site without fixtures does not pass
+func (r Report) Passed() bool {
+	if len(r.Results) == 0 {
+		return false
+	}
+	for _, result := range r.Results {
+		if !result.Passed() {
+			return false
+		}
+	}
+	return true
+}
+
+// LoadFixtures reads every <name>.html page in dir together with its <name>.golden.json file
+func LoadFixtures(dir string) ([]Fixture, error) {
+	pages, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fixtures: %w", err)
+	}
+	sort.Strings(pages)
+
+	fixtures := make([]Fixture, 0, len(pages))
+	for _, page := range pages {
+		name := strings.TrimSuffix(filepath.Base(page), ".html")
+		fixture := Fixture{Name: name, PagePath: page, GoldenPath: filepath.Join(dir, name+goldenSuffix)}
+
+		data, err := os.ReadFile(fixture.GoldenPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read golden file of fixture %s: %w", name, err)
+		}
+		if err := json.Unmarshal(data, &fixture.Golden); err != nil {
+			return nil, fmt.Errorf("failed to parse golden file of fixture %s: %w", name, err)
+		}
+		if fixture.Golden.URL == "" {
+			return nil, fmt.Errorf("golden file of fixture %s has no url", name)
+		}
+		fixtures = append(fixtures, fixture)
+	}
+	return fixtures, nil
+}
+
+// Run parses every fixture page with parser and compares the listing with the golden fields
+func Run(ctx context.Context, site string, parser scraper.PageParser, fixtures []Fixture) Report {
+	report := Report{Site: site}
+	for _, fixture := range fixtures {
+		result := Result{Fixture: fixture.Name}
+
+		fields, err := extract(ctx, parser, fixture)
+		if err != nil {
+			result.Err = err
+			report.Results = append(report.Results, result)
+			continue
+		}
+
+		for _, field := range sortedKeys(fixture.Golden.Fields) {
+			expected := fixture.Golden.Fields[field]
+			result.Checked++
+			if got := fields[field]; !reflect.DeepEqual(expected, got) {
+				result.Mismatches = append(result.Mismatches, Mismatch{Field: field, Expected: expected, Got: got})
+			}
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report
+}
+
+// Update rewrites the golden files with every non-empty field the parser currently extracts,
+// keeping their URLs. Review the diff before committing it.
+func Update(ctx context.Context, parser scraper.PageParser, fixtures []Fixture) error {
+	for _, fixture := range fixtures {
+		fields, err := extract(ctx, parser, fixture)
+		if err != nil {
+			return err
+		}
+
+		golden := Golden{URL: fixture.Golden.URL, Fields: make(map[string]any)}
+		for field, value := range fields {
+			if !volatileFields[field] && !isEmpty(value) {
+				golden.Fields[field] = value
+			}
+		}
+
+		data, err := json.MarshalIndent(golden, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal golden file of fixture %s: %w", fixture.Name, err)
+		}
+		if err := os.WriteFile(fixture.GoldenPath, append(data, '\n'), 0o644); err != nil {
+			return fmt.Errorf("failed to write golden file of fixture %s: %w", fixture.Name, err)
+		}
+	}
+	return nil
+}
+
+// extract parses a fixture page and returns its listings columns as decoded JSON values, so they
+// compare equal to the values read from golden files
+func extract(ctx context.Context, parser scraper.PageParser, fixture Fixture) (map[string]any, error) {
+	page, err := os.ReadFile(fixture.PagePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture %s: %w", fixture.Name, err)
+	}
+
+	parsed, err := parser.ParseListingPage(ctx, fixture.Golden.URL, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %w", fixture.Name, err)
+	}
+
+	data, err := json.Marshal(clickhouse.Flatten(parsed, fixture.Golden.URL))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal listing of fixture %s: %w", fixture.Name, err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed to decode listing of fixture %s: %w", fixture.Name, err)
+	}
+	for field := range volatileFields {
+		delete(fields, field)
+	}
+	return fields, nil
+}
+
+// isEmpty reports whether a decoded JSON value is a zero value not worth asserting
+func isEmpty(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case float64:
+		return v == 0
+	case bool:
+		return !v
+	case []any:
+		return len(v) == 0
+	case map[string]any:
+		return len(v) == 0
+	}
+	return false
+}
+
+// sortedKeys returns the keys of fields in alphabetical order
+func sortedKeys(fields map[string]any) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Write prints the report: one line per fixture, every mismatch, and the totals
+func (r Report) Write(w io.Writer) {
+	fmt.Fprintf(w, "Conformance of %s\n\n", r.Site)
+
+	var passed, checked, matched int
+	for _, result := range r.Results {
+		switch {
+		case result.Err != nil:
+			fmt.Fprintf(w, "ERROR  %s: %v\n", result.Fixture, result.Err)
+			continue
+		case result.Passed():
+			passed++
+			fmt.Fprintf(w, "PASS   %s (%d fields)\n", result.Fixture, result.Checked)
+		default:
+			fmt.Fprintf(w, "FAIL   %s (%d/%d fields)\n", result.Fixture, result.Checked-len(result.Mismatches), result.Checked)
+		}
+		for _, mismatch := range result.Mismatches {
+			fmt.Fprintf(w, "       %s: expected %s, got %s\n", mismatch.Field, formatValue(mismatch.Expected), formatValue(mismatch.Got))
+		}
+		checked += result.Checked
+		matched += result.Checked - len(result.Mismatches)
+	}
+
+	fmt.Fprintf(w, "\n%d/%d fixtures passed, %d/%d fields matched\n", passed, len(r.Results), matched, checked)
+}
+
+// formatValue renders a field value as compact JSON
+func formatValue(value any) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return fmt.Sprint(value)
+	}
+	return strings.TrimSpace(buf.String())
+}
@@ -0,0 +1,85 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/scraper"
+)
+
+// fixturesDir holds the fixture directories of every site
+const fixturesDir = "../scraper/testdata/conformance"
+
+func TestSiteFixtures(t *testing.T) {
+	for _, adapter := range scraper.DefaultRegistry.Adapters() {
+		parser, ok := adapter.(scraper.PageParser)
+		if !ok {
+			continue
+		}
+
+		fixtures, err := LoadFixtures(filepath.Join(fixturesDir, adapter.Name()))
+		if err != nil {
+			t.Fatalf("Failed to load fixtures of %s: %v", adapter.Name(), err)
+		}
+
+		report := Run(context.Background(), adapter.Name(), parser, fixtures)
+		if !report.Passed() {
+			var out bytes.Buffer
+			report.Write(&out)
+			t.Errorf("Expected %s to conform to its fixtures, got:\n%s", adapter.Name(), out.String())
+		}
+	}
+}
+
+func TestRunReportsMismatches(t *testing.T) {
+	dir := t.TempDir()
+	page, err := os.ReadFile(filepath.Join(fixturesDir, "intimcity.gold", "apartments_moscow.html"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture page: %v", err)
+	}
+	golden := `{"url": "https://a.intimcity.gold/anketa1001.htm", "fields": {"personal_age": 30, "location_city": "Москва"}}`
+	if err := os.WriteFile(filepath.Join(dir, "page.html"), page, 0o644); err != nil {
+		t.Fatalf("Failed to write fixture page: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "page.golden.json"), []byte(golden), 0o644); err != nil {
+		t.Fatalf("Failed to write golden file: %v", err)
+	}
+
+	fixtures, err := LoadFixtures(dir)
+	if err != nil {
+		t.Fatalf("Failed to load fixtures: %v", err)
+	}
+	report := Run(context.Background(), "intimcity.gold", scraper.NewIntimcityAdapter(), fixtures)
+
+	if report.Passed() {
+		t.Fatal("Expected the report to fail")
+	}
+	result := report.Results[0]
+	if result.Checked != 2 {
+		t.Errorf("Expected 2 checked fields, got %d", result.Checked)
+	}
+	if len(result.Mismatches) != 1 || result.Mismatches[0].Field != "personal_age" {
+		t.Fatalf("Expected a personal_age mismatch, got %+v", result.Mismatches)
+	}
+
+	var out bytes.Buffer
+	report.Write(&out)
+	if !strings.Contains(out.String(), "personal_age: expected 30, got 25") {
+		t.Errorf("Expected the mismatch in the report, got:\n%s", out.String())
+	}
+}
+
+func TestLoadFixturesRequiresGolden(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "page.html"), []byte("<html></html>"), 0o644); err != nil {
+		t.Fatalf("Failed to write fixture page: %v", err)
+	}
+
+	if _, err := LoadFixtures(dir); err == nil {
+		t.Error("Expected an error for a fixture without golden file")
+	}
+}
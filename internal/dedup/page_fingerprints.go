@@ -0,0 +1,90 @@
+package dedup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisPageFingerprints remembers the fingerprint of every index page in Redis, keyed by page
+// number, so several monitor instances share them. Each expires after the TTL, after which the
+// page is read again even if it did not change.
+type RedisPageFingerprints struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+// NewRedisPageFingerprints creates index page fingerprints on an existing Redis client
+func NewRedisPageFingerprints(client *redis.Client, prefix string, ttl time.Duration) *RedisPageFingerprints {
+	return &RedisPageFingerprints{client: client, prefix: prefix, ttl: ttl}
+}
+
+// Fingerprint returns the fingerprint stored for an index page, "" when there is none
+func (f *RedisPageFingerprints) Fingerprint(ctx context.Context, page int) (string, error) {
+	fingerprint, err := f.client.Get(ctx, f.prefix+strconv.Itoa(page)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get fingerprint of page %d: %w", page, err)
+	}
+	return fingerprint, nil
+}
+
+// SetFingerprint stores the fingerprint of an index page
+func (f *RedisPageFingerprints) SetFingerprint(ctx context.Context, page int, fingerprint string) error {
+	if err := f.client.Set(ctx, f.prefix+strconv.Itoa(page), fingerprint, f.ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set fingerprint of page %d: %w", page, err)
+	}
+	return nil
+}
+
+// Close closes the Redis client
+func (f *RedisPageFingerprints) Close() error {
+	return f.client.Close()
+}
+
+// MemoryPageFingerprints keeps index page fingerprints in process, used when Redis is not available
+type MemoryPageFingerprints struct {
+	mu           sync.Mutex
+	ttl          time.Duration
+	fingerprints map[int]pageFingerprint
+	now          func() time.Time
+}
+
+// pageFingerprint is a stored fingerprint and when it expires
+type pageFingerprint struct {
+	value  string
+	expiry time.Time
+}
+
+// NewMemoryPageFingerprints creates in-process index page fingerprints
+func NewMemoryPageFingerprints(ttl time.Duration) *MemoryPageFingerprints {
+	return &MemoryPageFingerprints{ttl: ttl, fingerprints: make(map[int]pageFingerprint), now: time.Now}
+}
+
+// Fingerprint returns the fingerprint stored for an index page, "" when there is none or it expired
+func (f *MemoryPageFingerprints) Fingerprint(ctx context.Context, page int) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stored, exists := f.fingerprints[page]
+	if !exists || (f.ttl > 0 && !f.now().Before(stored.expiry)) {
+		return "", nil
+	}
+	return stored.value, nil
+}
+
+// SetFingerprint stores the fingerprint of an index page
+func (f *MemoryPageFingerprints) SetFingerprint(ctx context.Context, page int, fingerprint string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fingerprints[page] = pageFingerprint{value: fingerprint, expiry: f.now().Add(f.ttl)}
+	return nil
+}
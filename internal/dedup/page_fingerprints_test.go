@@ -0,0 +1,27 @@
+package dedup
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryPageFingerprintsExpire(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	fingerprints := NewMemoryPageFingerprints(time.Hour)
+	fingerprints.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	fingerprints.SetFingerprint(ctx, 3, "abc")
+	if fingerprint, _ := fingerprints.Fingerprint(ctx, 3); fingerprint != "abc" {
+		t.Errorf("Expected fingerprint abc, got %q", fingerprint)
+	}
+	if fingerprint, _ := fingerprints.Fingerprint(ctx, 4); fingerprint != "" {
+		t.Errorf("Expected no fingerprint for another page, got %q", fingerprint)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if fingerprint, _ := fingerprints.Fingerprint(ctx, 3); fingerprint != "" {
+		t.Errorf("Expected the fingerprint to expire after the TTL, got %q", fingerprint)
+	}
+}
@@ -0,0 +1,132 @@
+package dedup
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"  // register GIF decoding
+	_ "image/jpeg" // register JPEG decoding
+	_ "image/png"  // register PNG decoding
+	"math"
+	"math/bits"
+	"slices"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // register WebP decoding
+)
+
+// PhotoHashes are the 64-bit perceptual hashes of a photo. Re-encoded, resized or slightly edited
+// copies of a photo have hashes a few bits apart, see HammingDistance.
+type PhotoHashes struct {
+	PHash uint64 // low DCT frequencies, robust to compression and small edits
+	DHash uint64 // horizontal brightness gradients, cheap and robust to brightness changes
+}
+
+// pHashSize is the side of the grayscale image the DCT of PHash runs on
+const pHashSize = 32
+
+// HashPhoto decodes a JPEG, PNG, GIF or WebP photo and computes its hashes
+func HashPhoto(data []byte) (PhotoHashes, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return PhotoHashes{}, fmt.Errorf("failed to decode photo: %w", err)
+	}
+	return HashImage(img), nil
+}
+
+// HashImage computes the hashes of a decoded photo
+func HashImage(img image.Image) PhotoHashes {
+	return PhotoHashes{PHash: PHash(img), DHash: DHash(img)}
+}
+
+// HammingDistance returns the number of bits two hashes differ in
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// DHash scales the image to 9x8 grayscale pixels and sets one bit per pixel brighter than its right neighbour
+func DHash(img image.Image) uint64 {
+	pixels := grayscale(img, 9, 8)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if pixels[y*9+x] > pixels[y*9+x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// PHash scales the image to 32x32 grayscale pixels and takes their DCT. Each of the 8x8 lowest
+// frequencies, without the constant term, sets a bit when it is above their median.
+func PHash(img image.Image) uint64 {
+	coefficients := dct2D(grayscale(img, pHashSize, pHashSize), pHashSize)
+
+	low := make([]float64, 0, 64)
+	for v := 1; v <= 8; v++ {
+		for u := 1; u <= 8; u++ {
+			low = append(low, coefficients[v*pHashSize+u])
+		}
+	}
+
+	sorted := slices.Clone(low)
+	slices.Sort(sorted)
+	median := (sorted[31] + sorted[32]) / 2
+
+	var hash uint64
+	for _, coefficient := range low {
+		hash <<= 1
+		if coefficient > median {
+			hash |= 1
+		}
+	}
+	return hash
+}
+
+// grayscale scales img to width x height and returns its luma values row by row
+func grayscale(img image.Image, width, height int) []float64 {
+	scaled := image.NewGray(image.Rect(0, 0, width, height))
+	draw.BiLinear.Scale(scaled, scaled.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	pixels := make([]float64, width*height)
+	for i, value := range scaled.Pix[:width*height] {
+		pixels[i] = float64(value)
+	}
+	return pixels
+}
+
+// dct2D returns the two-dimensional DCT-II of an n x n matrix, computed row then column wise
+func dct2D(pixels []float64, n int) []float64 {
+	cosines := make([]float64, n*n)
+	for k := 0; k < n; k++ {
+		for i := 0; i < n; i++ {
+			cosines[k*n+i] = math.Cos(math.Pi / float64(n) * (float64(i) + 0.5) * float64(k))
+		}
+	}
+
+	rows := make([]float64, n*n)
+	for y := 0; y < n; y++ {
+		for k := 0; k < n; k++ {
+			var sum float64
+			for x := 0; x < n; x++ {
+				sum += pixels[y*n+x] * cosines[k*n+x]
+			}
+			rows[y*n+k] = sum
+		}
+	}
+
+	result := make([]float64, n*n)
+	for x := 0; x < n; x++ {
+		for k := 0; k < n; k++ {
+			var sum float64
+			for y := 0; y < n; y++ {
+				sum += rows[y*n+x] * cosines[k*n+y]
+			}
+			result[k*n+x] = sum
+		}
+	}
+	return result
+}
@@ -0,0 +1,185 @@
+package dedup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/events"
+)
+
+// testPhoto draws a width x height picture of soft blobs; seeds give unrelated pictures
+func testPhoto(width, height int, seed float64) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			u, v := float64(x)/float64(width), float64(y)/float64(height)
+			value := 128 + 60*math.Sin(seed*3*u+seed) + 60*math.Cos(seed*5*v*u+2*seed)
+			img.Set(x, y, color.RGBA{uint8(value), uint8(255 - value), uint8(value / 2), 255})
+		}
+	}
+	return img
+}
+
+// encodeJPEG encodes img at the given quality
+func encodeJPEG(t *testing.T, img image.Image, quality int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatalf("Failed to encode JPEG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestHashPhotoMatchesCopies(t *testing.T) {
+	original := testPhoto(640, 480, 2.1)
+	var pngData bytes.Buffer
+	if err := png.Encode(&pngData, original); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+
+	reference, err := HashPhoto(pngData.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to hash photo: %v", err)
+	}
+
+	copies := map[string][]byte{
+		"low quality JPEG": encodeJPEG(t, original, 50),
+		"downscaled":       encodeJPEG(t, testPhoto(320, 240, 2.1), 90),
+	}
+	for name, data := range copies {
+		hashes, err := HashPhoto(data)
+		if err != nil {
+			t.Fatalf("%s: failed to hash photo: %v", name, err)
+		}
+		if distance := HammingDistance(reference.PHash, hashes.PHash); distance > clickhouse.DuplicatePhotoDistance {
+			t.Errorf("%s: expected pHash within %d bits, got %d", name, clickhouse.DuplicatePhotoDistance, distance)
+		}
+		if distance := HammingDistance(reference.DHash, hashes.DHash); distance > clickhouse.DuplicatePhotoDistance {
+			t.Errorf("%s: expected dHash within %d bits, got %d", name, clickhouse.DuplicatePhotoDistance, distance)
+		}
+	}
+
+	other, err := HashPhoto(encodeJPEG(t, testPhoto(640, 480, 4.7), 90))
+	if err != nil {
+		t.Fatalf("Failed to hash photo: %v", err)
+	}
+	if distance := HammingDistance(reference.PHash, other.PHash); distance <= clickhouse.DuplicatePhotoDistance {
+		t.Errorf("Expected an unrelated photo to be more than %d bits away, got %d", clickhouse.DuplicatePhotoDistance, distance)
+	}
+
+	if _, err := HashPhoto([]byte("<html>not a photo</html>")); err == nil {
+		t.Errorf("Expected an error for data that is not a photo")
+	}
+}
+
+// memoryHashStore keeps inserted photo hashes
+type memoryHashStore struct {
+	hashes []clickhouse.PhotoHash
+}
+
+func (s *memoryHashStore) InsertPhotoHashes(ctx context.Context, hashes []clickhouse.PhotoHash) error {
+	s.hashes = append(s.hashes, hashes...)
+	return nil
+}
+
+func TestPhotoHasherHashesChangedPhotos(t *testing.T) {
+	photo := encodeJPEG(t, testPhoto(200, 200, 3.3), 85)
+	var fetched []string
+	fetch := func(ctx context.Context, url string) ([]byte, error) {
+		fetched = append(fetched, url)
+		if url == "https://a.intimcity.gold/missing.jpg" {
+			return nil, errors.New("photo request returned status 404")
+		}
+		return photo, nil
+	}
+
+	store := &memoryHashStore{}
+	hasher := NewPhotoHasher(store, fetch, 2, 0)
+	bus := events.NewBus()
+	hasher.Subscribe(bus, 0)
+
+	listing := &clickhouse.FlattenedListing{
+		ID:     "intimcity.gold:1001",
+		Photos: []string{"https://a.intimcity.gold/missing.jpg", "https://a.intimcity.gold/1.jpg", "https://a.intimcity.gold/2.jpg"},
+	}
+	bus.Publish(events.ListingInserted{ListingID: listing.ID, Rows: 1, Listing: listing})
+	// Unchanged photos and listings that were not rewritten are not hashed again
+	bus.Publish(events.ListingInserted{ListingID: listing.ID, Rows: 1, ChangedFields: []string{"price_hour"}, Listing: listing})
+	bus.Publish(events.ListingInserted{ListingID: listing.ID, Rows: 0, Listing: listing})
+	hasher.Close()
+
+	if len(fetched) != 2 {
+		t.Errorf("Expected the first 2 photos to be fetched once, got %v", fetched)
+	}
+	if len(store.hashes) != 1 || store.hashes[0].PhotoURL != "https://a.intimcity.gold/1.jpg" || store.hashes[0].ListingID != listing.ID {
+		t.Fatalf("Expected the hash of the downloaded photo, got %+v", store.hashes)
+	}
+	if store.hashes[0].PHash == 0 && store.hashes[0].DHash == 0 {
+		t.Errorf("Expected non-zero hashes")
+	}
+}
+
+// batchHashStore keeps each insert of photo hashes as a batch
+type batchHashStore struct {
+	mu      sync.Mutex
+	batches [][]clickhouse.PhotoHash
+}
+
+func (s *batchHashStore) InsertPhotoHashes(ctx context.Context, hashes []clickhouse.PhotoHash) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, hashes)
+	return nil
+}
+
+func TestPhotoHasherParallelPriorityPhotos(t *testing.T) {
+	photo := encodeJPEG(t, testPhoto(200, 200, 3.3), 85)
+	var inFlight, peak atomic.Int32
+	fetch := func(ctx context.Context, url string) ([]byte, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			current := peak.Load()
+			if n <= current || peak.CompareAndSwap(current, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return photo, nil
+	}
+
+	store := &batchHashStore{}
+	hasher := NewPhotoHasher(store, fetch, 0, 0)
+	hasher.SetParallelism(2)
+	hasher.SetPriorityPhotos(1)
+
+	listing := &clickhouse.FlattenedListing{ID: "intimcity.gold:1002"}
+	for i := range 5 {
+		listing.Photos = append(listing.Photos, fmt.Sprintf("https://a.intimcity.gold/%d.jpg", i))
+	}
+	if err := hasher.HashListing(context.Background(), listing); err != nil {
+		t.Fatalf("Failed to hash listing: %v", err)
+	}
+
+	if peak.Load() != 2 {
+		t.Errorf("Expected 2 photos downloaded at the same time, got %d", peak.Load())
+	}
+	if len(store.batches) != 2 || len(store.batches[0]) != 1 || len(store.batches[1]) != 4 {
+		t.Fatalf("Expected the priority photo stored before the other 4, got %d batches", len(store.batches))
+	}
+	if store.batches[0][0].PhotoURL != listing.Photos[0] || store.batches[1][3].PhotoURL != listing.Photos[4] {
+		t.Errorf("Expected the hashes in photo order, got %s and %s", store.batches[0][0].PhotoURL, store.batches[1][3].PhotoURL)
+	}
+}
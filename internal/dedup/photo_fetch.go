@@ -0,0 +1,119 @@
+package dedup
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+	"github.com/gregor-tokarev/hoe_parser/internal/metrics"
+	"golang.org/x/time/rate"
+)
+
+// NewPhotoHasherFromConfig creates a hasher with the photo settings of the main application
+// config, downloading through the proxy client within the configured bandwidth
+func NewPhotoHasherFromConfig(store PhotoHashStore, cfg *config.Config) *PhotoHasher {
+	fetch := ThrottlePhotos(FetchPhoto, cfg.PhotoHash.Bandwidth)
+	hasher := NewPhotoHasher(store, fetch, cfg.PhotoHash.MaxPhotos, cfg.PhotoHash.Timeout)
+	hasher.SetParallelism(cfg.PhotoHash.Parallelism)
+	hasher.SetPriorityPhotos(cfg.PhotoHash.PriorityPhotos)
+	return hasher
+}
+
+// ThrottlePhotos limits the photos downloaded through fetch to bytesPerSecond, shared by every
+// caller of the returned fetcher (0 means no limit). A photo is charged once it is downloaded, so
+// a large photo delays the downloads after it rather than itself. Requests still go through the
+// per-host rate limits of the proxy client, so photos stay within the crawl budget.
+func ThrottlePhotos(fetch PhotoFetcher, bytesPerSecond int) PhotoFetcher {
+	if bytesPerSecond <= 0 {
+		return fetch
+	}
+
+	limiter := rate.NewLimiter(rate.Limit(bytesPerSecond), bytesPerSecond)
+	return func(ctx context.Context, url string) ([]byte, error) {
+		data, err := fetch(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		for remaining := len(data); remaining > 0; remaining -= limiter.Burst() {
+			if err := limiter.WaitN(ctx, min(remaining, limiter.Burst())); err != nil {
+				return nil, fmt.Errorf("failed to wait for photo bandwidth: %w", err)
+			}
+		}
+		return data, nil
+	}
+}
+
+// SetParallelism sets how many photos of one listing are downloaded at the same time, 1 by default
+func (h *PhotoHasher) SetParallelism(photos int) {
+	h.parallelism = max(photos, 1)
+}
+
+// SetPriorityPhotos makes HashListing store the hashes of the first photos of a listing before
+// downloading the rest, so the listing can be matched early; 0 stores all hashes at once
+func (h *PhotoHasher) SetPriorityPhotos(photos int) {
+	h.priorityPhotos = max(photos, 0)
+}
+
+// photoResult is the outcome of hashing one photo: its hash, or nil when it could not be
+// downloaded or decoded, with the download error
+type photoResult struct {
+	hash     *clickhouse.PhotoHash
+	fetchErr error
+}
+
+// hashPhotos downloads and hashes photos, up to the parallelism of the hasher at the same time,
+// and returns the results in the order of urls. Photos not started before ctx is done have no result.
+func (h *PhotoHasher) hashPhotos(ctx context.Context, listingID string, urls []string) []photoResult {
+	results := make([]photoResult, len(urls))
+	slots := make(chan struct{}, max(h.parallelism, 1))
+
+	var wg sync.WaitGroup
+	for i, url := range urls {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return results
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			results[i] = h.hashPhoto(ctx, listingID, url)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// hashPhoto downloads and hashes one photo
+func (h *PhotoHasher) hashPhoto(ctx context.Context, listingID, url string) photoResult {
+	data, err := h.fetch(ctx, url)
+	if err != nil {
+		if ctx.Err() == nil {
+			metrics.PhotosHashed.WithLabelValues("fetch_failed").Inc()
+			log.DebugContext(ctx, "Failed to download photo", "listing_id", listingID, "url", url, "error", err)
+		}
+		return photoResult{fetchErr: err}
+	}
+
+	photoHashes, err := HashPhoto(data)
+	if err != nil {
+		metrics.PhotosHashed.WithLabelValues("decode_failed").Inc()
+		log.DebugContext(ctx, "Failed to hash photo", "listing_id", listingID, "url", url, "error", err)
+		return photoResult{}
+	}
+
+	metrics.PhotosHashed.WithLabelValues("hashed").Inc()
+	return photoResult{hash: &clickhouse.PhotoHash{
+		ListingID: listingID,
+		PhotoURL:  url,
+		PHash:     photoHashes.PHash,
+		DHash:     photoHashes.DHash,
+		HashedAt:  clock.Now(),
+	}}
+}
@@ -0,0 +1,276 @@
+package dedup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+	"github.com/gregor-tokarev/hoe_parser/internal/metrics"
+	"github.com/redis/go-redis/v9"
+)
+
+// PhotoJob is a listing whose photos await download and hashing, with its retry state
+type PhotoJob struct {
+	ListingID string    `json:"listing_id"`
+	Photos    []string  `json:"photos"`
+	QueuedAt  time.Time `json:"queued_at"`
+	Attempts  int       `json:"attempts"` // failed attempts so far
+	LastError string    `json:"last_error,omitempty"`
+
+	raw string // the job as stored, identifying the claimed version of a replaced job
+}
+
+// PhotoQueue is a persistent queue of photo jobs that any number of processes drain
+type PhotoQueue interface {
+	// Push queues a job, replacing a job queued for the same listing
+	Push(ctx context.Context, job PhotoJob) error
+	// Claim leases the next due job to the caller until lease passes, after which it is due
+	// again; ok is false when no job is due
+	Claim(ctx context.Context, lease time.Duration) (job PhotoJob, ok bool, err error)
+	// Complete removes a claimed job, unless it was replaced since it was claimed
+	Complete(ctx context.Context, job PhotoJob) error
+	// Retry stores the retry state of a claimed job and makes it due at retryAt, unless it was
+	// replaced since it was claimed
+	Retry(ctx context.Context, job PhotoJob, retryAt time.Time) error
+	// Len returns the number of queued jobs, claimed ones included
+	Len(ctx context.Context) (int64, error)
+}
+
+// RedisPhotoQueue keeps photo jobs in Redis: a hash of jobs by listing ID and a sorted set of
+// listing IDs scored by the time each job is due
+type RedisPhotoQueue struct {
+	client *redis.Client
+	jobs   string
+	due    string
+}
+
+// NewRedisPhotoQueue creates a queue under the keys starting with prefix
+func NewRedisPhotoQueue(client *redis.Client, prefix string) *RedisPhotoQueue {
+	return &RedisPhotoQueue{client: client, jobs: prefix + ":jobs", due: prefix + ":due"}
+}
+
+// claimScript moves the first due job to the end of its lease and returns it
+var claimScript = redis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, 1)
+if #ids == 0 then
+	return false
+end
+local job = redis.call('HGET', KEYS[2], ids[1])
+if not job then
+	redis.call('ZREM', KEYS[1], ids[1])
+	return false
+end
+redis.call('ZADD', KEYS[1], ARGV[2], ids[1])
+return job
+`)
+
+// updateScript replaces or, without a new job, removes a job still stored as claimed
+var updateScript = redis.NewScript(`
+if redis.call('HGET', KEYS[2], ARGV[1]) ~= ARGV[2] then
+	return 0
+end
+if ARGV[3] == '' then
+	redis.call('HDEL', KEYS[2], ARGV[1])
+	redis.call('ZREM', KEYS[1], ARGV[1])
+else
+	redis.call('HSET', KEYS[2], ARGV[1], ARGV[3])
+	redis.call('ZADD', KEYS[1], ARGV[4], ARGV[1])
+end
+return 1
+`)
+
+// Push queues a job, due at once
+func (q *RedisPhotoQueue) Push(ctx context.Context, job PhotoJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal photo job: %w", err)
+	}
+
+	_, err = q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, q.jobs, job.ListingID, data)
+		pipe.ZAdd(ctx, q.due, redis.Z{Score: float64(clock.Now().UnixMilli()), Member: job.ListingID})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to queue photo job: %w", err)
+	}
+	return nil
+}
+
+// Claim leases the next due job
+func (q *RedisPhotoQueue) Claim(ctx context.Context, lease time.Duration) (PhotoJob, bool, error) {
+	now := clock.Now()
+	raw, err := claimScript.Run(ctx, q.client, []string{q.due, q.jobs}, now.UnixMilli(), now.Add(lease).UnixMilli()).Text()
+	if errors.Is(err, redis.Nil) {
+		return PhotoJob{}, false, nil
+	}
+	if err != nil {
+		return PhotoJob{}, false, fmt.Errorf("failed to claim photo job: %w", err)
+	}
+
+	var job PhotoJob
+	if err := json.Unmarshal([]byte(raw), &job); err != nil {
+		return PhotoJob{}, false, fmt.Errorf("failed to unmarshal photo job: %w", err)
+	}
+	job.raw = raw
+	return job, true, nil
+}
+
+// Complete removes a claimed job
+func (q *RedisPhotoQueue) Complete(ctx context.Context, job PhotoJob) error {
+	if err := updateScript.Run(ctx, q.client, []string{q.due, q.jobs}, job.ListingID, job.raw, "", 0).Err(); err != nil {
+		return fmt.Errorf("failed to complete photo job: %w", err)
+	}
+	return nil
+}
+
+// Retry stores the retry state of a claimed job and makes it due at retryAt
+func (q *RedisPhotoQueue) Retry(ctx context.Context, job PhotoJob, retryAt time.Time) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal photo job: %w", err)
+	}
+	if err := updateScript.Run(ctx, q.client, []string{q.due, q.jobs}, job.ListingID, job.raw, string(data), retryAt.UnixMilli()).Err(); err != nil {
+		return fmt.Errorf("failed to retry photo job: %w", err)
+	}
+	return nil
+}
+
+// Len returns the number of queued jobs
+func (q *RedisPhotoQueue) Len(ctx context.Context) (int64, error) {
+	n, err := q.client.ZCard(ctx, q.due).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count photo jobs: %w", err)
+	}
+	return n, nil
+}
+
+// PhotoQueueOptions control how queued photo jobs are drained and retried
+type PhotoQueueOptions struct {
+	Lease       time.Duration // how long a worker may take for a job before it is due again
+	MaxAttempts int           // failed attempts after which a job is dropped
+	RetryDelay  time.Duration // delay before the first retry, doubled on every retry
+	Poll        time.Duration // wait after finding no due job
+}
+
+// PhotoQueueOptionsFromConfig returns the queue options of the main application config
+func PhotoQueueOptionsFromConfig(cfg *config.Config) PhotoQueueOptions {
+	return PhotoQueueOptions{
+		Lease:       cfg.PhotoHash.QueueLease,
+		MaxAttempts: cfg.PhotoHash.MaxAttempts,
+		RetryDelay:  cfg.PhotoHash.RetryDelay,
+	}
+}
+
+// SetQueue makes Subscribe queue the listings on queue instead of hashing them at once; Drain
+// hashes the queued listings
+func (h *PhotoHasher) SetQueue(queue PhotoQueue, options PhotoQueueOptions) {
+	if options.Lease <= 0 {
+		options.Lease = 5 * time.Minute
+	}
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = 5
+	}
+	if options.RetryDelay <= 0 {
+		options.RetryDelay = time.Minute
+	}
+	if options.Poll <= 0 {
+		options.Poll = time.Second
+	}
+	h.queue = queue
+	h.queueOptions = options
+}
+
+// enqueue queues the photos of a listing, reporting false when the queue cannot be reached
+func (h *PhotoHasher) enqueue(listing *clickhouse.FlattenedListing) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	job := PhotoJob{ListingID: listing.ID, Photos: listing.Photos, QueuedAt: clock.Now()}
+	if err := h.queue.Push(ctx, job); err != nil {
+		log.Warn("Failed to queue listing photos, hashing them now", "listing_id", listing.ID, "error", err)
+		return false
+	}
+	metrics.PhotoQueueJobs.WithLabelValues("queued").Inc()
+	return true
+}
+
+// Drain runs workers hashing the queued listings until ctx is done. A job cut short by ctx is
+// due again at once, without counting as an attempt.
+func (h *PhotoHasher) Drain(ctx context.Context, workers int) {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.drain(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// drain claims and processes jobs one at a time
+func (h *PhotoHasher) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, ok, err := h.queue.Claim(ctx, h.queueOptions.Lease)
+		if err != nil && ctx.Err() == nil {
+			log.Warn("Failed to claim photo job", "error", err)
+		}
+		if err != nil || !ok {
+			select {
+			case <-time.After(h.queueOptions.Poll):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		h.processJob(ctx, job)
+	}
+}
+
+// processJob hashes the photos of a claimed job, then completes, retries or drops it
+func (h *PhotoHasher) processJob(ctx context.Context, job PhotoJob) {
+	jobCtx := ctx
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		jobCtx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+	hashErr := h.HashListing(jobCtx, &clickhouse.FlattenedListing{ID: job.ListingID, Photos: job.Photos})
+
+	// The queue is updated even when ctx is done, so the job is not left leased
+	queueCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	var err error
+	switch {
+	case hashErr == nil:
+		err = h.queue.Complete(queueCtx, job)
+		metrics.PhotoQueueJobs.WithLabelValues("done").Inc()
+	case ctx.Err() != nil:
+		err = h.queue.Retry(queueCtx, job, clock.Now())
+	case job.Attempts+1 >= h.queueOptions.MaxAttempts:
+		err = h.queue.Complete(queueCtx, job)
+		metrics.PhotoQueueJobs.WithLabelValues("dropped").Inc()
+		log.Warn("Dropping photo job after its last attempt", "listing_id", job.ListingID, "attempts", job.Attempts+1, "error", hashErr)
+	default:
+		delay := h.queueOptions.RetryDelay << job.Attempts
+		job.Attempts++
+		job.LastError = hashErr.Error()
+		err = h.queue.Retry(queueCtx, job, clock.Now().Add(delay))
+		metrics.PhotoQueueJobs.WithLabelValues("retried").Inc()
+		log.Debug("Retrying photo job", "listing_id", job.ListingID, "attempts", job.Attempts, "delay", delay, "error", hashErr)
+	}
+	if err != nil {
+		log.Warn("Failed to update photo job, it is due again once its lease passes", "listing_id", job.ListingID, "error", err)
+	}
+}
@@ -0,0 +1,118 @@
+package dedup
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+	"github.com/gregor-tokarev/hoe_parser/internal/events"
+)
+
+// memoryPhotoQueue is an in-process PhotoQueue recording the last update of each job
+type memoryPhotoQueue struct {
+	jobs     map[string]PhotoJob
+	due      map[string]time.Time
+	complete []string
+}
+
+func newMemoryPhotoQueue() *memoryPhotoQueue {
+	return &memoryPhotoQueue{jobs: make(map[string]PhotoJob), due: make(map[string]time.Time)}
+}
+
+func (q *memoryPhotoQueue) Push(ctx context.Context, job PhotoJob) error {
+	q.jobs[job.ListingID] = job
+	q.due[job.ListingID] = clock.Now()
+	return nil
+}
+
+func (q *memoryPhotoQueue) Claim(ctx context.Context, lease time.Duration) (PhotoJob, bool, error) {
+	for id, due := range q.due {
+		if !due.After(clock.Now()) {
+			q.due[id] = clock.Now().Add(lease)
+			return q.jobs[id], true, nil
+		}
+	}
+	return PhotoJob{}, false, nil
+}
+
+func (q *memoryPhotoQueue) Complete(ctx context.Context, job PhotoJob) error {
+	delete(q.jobs, job.ListingID)
+	delete(q.due, job.ListingID)
+	q.complete = append(q.complete, job.ListingID)
+	return nil
+}
+
+func (q *memoryPhotoQueue) Retry(ctx context.Context, job PhotoJob, retryAt time.Time) error {
+	q.jobs[job.ListingID] = job
+	q.due[job.ListingID] = retryAt
+	return nil
+}
+
+func (q *memoryPhotoQueue) Len(ctx context.Context) (int64, error) {
+	return int64(len(q.jobs)), nil
+}
+
+func TestPhotoQueueRetriesAndDropsJobs(t *testing.T) {
+	fixed := clock.NewFixed(time.Date(2025, 3, 14, 12, 0, 0, 0, time.UTC))
+	defer clock.SetClock(fixed)()
+
+	photo := encodeJPEG(t, testPhoto(200, 200, 3.3), 85)
+	failures := 1
+	fetch := func(ctx context.Context, url string) ([]byte, error) {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if url == "https://a.intimcity.gold/gone.jpg" || failures > 0 {
+			failures--
+			return nil, errors.New("photo request returned status 503")
+		}
+		return photo, nil
+	}
+
+	store := &memoryHashStore{}
+	queue := newMemoryPhotoQueue()
+	hasher := NewPhotoHasher(store, fetch, 0, 0)
+	hasher.SetQueue(queue, PhotoQueueOptions{Lease: time.Minute, MaxAttempts: 2, RetryDelay: time.Minute})
+
+	// Stored listings are queued instead of hashed
+	bus := events.NewBus()
+	hasher.Subscribe(bus, 0)
+	ok := &clickhouse.FlattenedListing{ID: "intimcity.gold:1", Photos: []string{"https://a.intimcity.gold/1.jpg"}}
+	gone := &clickhouse.FlattenedListing{ID: "intimcity.gold:2", Photos: []string{"https://a.intimcity.gold/gone.jpg"}}
+	bus.Publish(events.ListingInserted{ListingID: ok.ID, Rows: 1, Listing: ok})
+	bus.Publish(events.ListingInserted{ListingID: gone.ID, Rows: 1, Listing: gone})
+	hasher.Close()
+	if len(queue.jobs) != 2 || len(store.hashes) != 0 {
+		t.Fatalf("Expected 2 queued jobs and no hashes, got %d jobs and %d hashes", len(queue.jobs), len(store.hashes))
+	}
+
+	// A failed job is due again after the retry delay
+	job := queue.jobs[ok.ID]
+	hasher.processJob(context.Background(), job)
+	if retried := queue.jobs[ok.ID]; retried.Attempts != 1 || retried.LastError == "" || !queue.due[ok.ID].Equal(fixed.Now().Add(time.Minute)) {
+		t.Errorf("Expected a retry in a minute after 1 attempt, got %+v due %s", retried, queue.due[ok.ID])
+	}
+	hasher.processJob(context.Background(), queue.jobs[ok.ID])
+	if len(store.hashes) != 1 || queue.jobs[ok.ID].ListingID != "" {
+		t.Errorf("Expected the retried job to be hashed and completed, got %d hashes", len(store.hashes))
+	}
+
+	// A job failing MaxAttempts times is dropped
+	hasher.processJob(context.Background(), queue.jobs[gone.ID])
+	hasher.processJob(context.Background(), queue.jobs[gone.ID])
+	if length, _ := queue.Len(context.Background()); length != 0 || len(queue.complete) != 2 {
+		t.Errorf("Expected the failing job to be dropped, got %d queued jobs", length)
+	}
+
+	// A job cut short by shutdown is due again at once without counting as an attempt
+	queue.Push(context.Background(), PhotoJob{ListingID: ok.ID, Photos: ok.Photos})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	hasher.processJob(ctx, queue.jobs[ok.ID])
+	if job, queued := queue.jobs[ok.ID]; !queued || job.Attempts != 0 || queue.due[ok.ID].After(fixed.Now()) {
+		t.Errorf("Expected the interrupted job to be due at once without an attempt, got %+v", job)
+	}
+}
@@ -0,0 +1,162 @@
+package dedup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/events"
+	"github.com/gregor-tokarev/hoe_parser/internal/logger"
+	"github.com/gregor-tokarev/hoe_parser/internal/modules/request_client"
+)
+
+// log is the component logger of the package
+var log = logger.Component("dedup")
+
+// maxPhotoSize caps downloaded photos; larger responses are not listing photos
+const maxPhotoSize = 20 << 20
+
+// PhotoHashStore stores the hashes of listing photos
+type PhotoHashStore interface {
+	InsertPhotoHashes(ctx context.Context, hashes []clickhouse.PhotoHash) error
+}
+
+// PhotoFetcher downloads the photo at url
+type PhotoFetcher func(ctx context.Context, url string) ([]byte, error)
+
+// FetchPhoto downloads a photo through the proxy client
+func FetchPhoto(ctx context.Context, url string) ([]byte, error) {
+	resp, err := request_client.GetGlobalClient().GetCtx(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch photo: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("photo request returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPhotoSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read photo: %w", err)
+	}
+	if len(data) > maxPhotoSize {
+		return nil, fmt.Errorf("photo larger than %d bytes", maxPhotoSize)
+	}
+	return data, nil
+}
+
+// PhotoHasher downloads the photos of stored listings and stores their perceptual hashes, which
+// FindDuplicateListings compares to find profiles reposting the same photos
+type PhotoHasher struct {
+	store       PhotoHashStore
+	fetch       PhotoFetcher
+	maxPhotos   int
+	timeout     time.Duration
+	unsubscribe func()
+
+	parallelism    int // photos of one listing downloaded at the same time
+	priorityPhotos int // photos stored before the rest of a listing is downloaded, 0 means none
+
+	queue        PhotoQueue // nil hashes the listings as they are stored
+	queueOptions PhotoQueueOptions
+}
+
+// NewPhotoHasher creates a hasher storing the hashes of at most maxPhotos photos per listing
+// (0 means all), giving each listing at most timeout (0 means no limit)
+func NewPhotoHasher(store PhotoHashStore, fetch PhotoFetcher, maxPhotos int, timeout time.Duration) *PhotoHasher {
+	return &PhotoHasher{store: store, fetch: fetch, maxPhotos: maxPhotos, timeout: timeout, parallelism: 1}
+}
+
+// Subscribe starts hashing the photos of listings from ListingInserted events on bus, queueing
+// up to buffer listings. Only new listings and listings whose photos changed are hashed. With a
+// queue set the listings are pushed to it instead, for Drain to hash.
+func (h *PhotoHasher) Subscribe(bus *events.Bus, buffer int) {
+	h.unsubscribe = bus.Subscribe("photo_hashes", buffer, events.On(func(event events.ListingInserted) {
+		if !photosChanged(event) {
+			return
+		}
+		if h.queue != nil && h.enqueue(event.Listing) {
+			return
+		}
+
+		ctx := context.Background()
+		if h.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, h.timeout)
+			defer cancel()
+		}
+		if err := h.HashListing(ctx, event.Listing); err != nil {
+			log.Warn("Failed to hash listing photos", "listing_id", event.ListingID, "error", err)
+		}
+	}))
+}
+
+// photosChanged reports whether an insert stored photos that were not hashed before
+func photosChanged(event events.ListingInserted) bool {
+	if event.Listing == nil || event.Rows == 0 || len(event.Listing.Photos) == 0 {
+		return false
+	}
+	// New and restored listings come without changed fields
+	return len(event.ChangedFields) == 0 || slices.Contains(event.ChangedFields, "photos")
+}
+
+// HashListing downloads and hashes the photos of a listing and stores the hashes. Photos that
+// cannot be downloaded or decoded are skipped; it fails when no photo could be downloaded. The
+// priority photos are stored before the rest are downloaded.
+func (h *PhotoHasher) HashListing(ctx context.Context, listing *clickhouse.FlattenedListing) error {
+	// With sticky sessions, photos go through the proxy the listing was scraped through while its
+	// session lasts
+	ctx = request_client.WithSessionKey(ctx, listing.ID)
+
+	photos := listing.Photos
+	if h.maxPhotos > 0 && len(photos) > h.maxPhotos {
+		photos = photos[:h.maxPhotos]
+	}
+
+	batches := [][]string{photos}
+	if h.priorityPhotos > 0 && len(photos) > h.priorityPhotos {
+		batches = [][]string{photos[:h.priorityPhotos], photos[h.priorityPhotos:]}
+	}
+
+	var fetchErr error
+	fetched := 0
+	for _, batch := range batches {
+		var hashes []clickhouse.PhotoHash
+		for _, result := range h.hashPhotos(ctx, listing.ID, batch) {
+			if result.fetchErr != nil {
+				fetchErr = result.fetchErr
+				continue
+			}
+			fetched++
+			if result.hash != nil {
+				hashes = append(hashes, *result.hash)
+			}
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("failed to hash photos: %w", ctx.Err())
+		}
+
+		if err := h.store.InsertPhotoHashes(ctx, hashes); err != nil {
+			return err
+		}
+	}
+
+	if fetched == 0 && fetchErr != nil {
+		return fmt.Errorf("failed to download any of %d photos: %w", len(photos), fetchErr)
+	}
+	return nil
+}
+
+// Close waits until the queued listings are hashed
+func (h *PhotoHasher) Close() error {
+	if h.unsubscribe != nil {
+		h.unsubscribe()
+		h.unsubscribe = nil
+	}
+	return nil
+}
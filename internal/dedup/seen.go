@@ -0,0 +1,98 @@
+package dedup
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisSeenSet remembers keys in Redis, each expiring after the TTL so it is emitted again later
+type RedisSeenSet struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+// NewRedisSeenSet creates a seen-set on an existing Redis client
+func NewRedisSeenSet(client *redis.Client, prefix string, ttl time.Duration) *RedisSeenSet {
+	return &RedisSeenSet{client: client, prefix: prefix, ttl: ttl}
+}
+
+// NewRedisClient connects to the configured Redis and checks the connection
+func NewRedisClient(ctx context.Context, cfg *config.Config) (*redis.Client, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     net.JoinHostPort(cfg.Redis.Host, strconv.Itoa(cfg.Redis.Port)),
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+	})
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+	return client, nil
+}
+
+// FromConfig connects to the configured Redis and creates the seen-set on it
+func FromConfig(ctx context.Context, cfg *config.Config) (*RedisSeenSet, error) {
+	client, err := NewRedisClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewRedisSeenSet(client, cfg.Dedup.KeyPrefix, cfg.Dedup.TTL), nil
+}
+
+// MarkSeen records key and reports whether it was new. SET NX EX makes the check and the
+// write a single atomic step, so concurrent scrapers never both treat a key as new.
+func (s *RedisSeenSet) MarkSeen(ctx context.Context, key string) (bool, error) {
+	added, err := s.client.SetNX(ctx, s.prefix+key, 1, s.ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to mark %s as seen: %w", key, err)
+	}
+	return added, nil
+}
+
+// Close closes the Redis client
+func (s *RedisSeenSet) Close() error {
+	return s.client.Close()
+}
+
+// MemorySeenSet is an in-process seen-set, used when Redis is not available
+type MemorySeenSet struct {
+	mu   sync.Mutex
+	ttl  time.Duration
+	seen map[string]time.Time // key -> expiry
+	now  func() time.Time
+}
+
+// NewMemorySeenSet creates an in-process seen-set
+func NewMemorySeenSet(ttl time.Duration) *MemorySeenSet {
+	return &MemorySeenSet{ttl: ttl, seen: make(map[string]time.Time), now: time.Now}
+}
+
+// MarkSeen records key and reports whether it was new; expired keys are dropped as they are found
+func (s *MemorySeenSet) MarkSeen(ctx context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if expiry, exists := s.seen[key]; exists && now.Before(expiry) {
+		return false, nil
+	}
+
+	s.seen[key] = now.Add(s.ttl)
+	if len(s.seen)%1000 == 0 {
+		for k, expiry := range s.seen {
+			if !now.Before(expiry) {
+				delete(s.seen, k)
+			}
+		}
+	}
+	return true, nil
+}
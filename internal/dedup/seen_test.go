@@ -0,0 +1,26 @@
+package dedup
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemorySeenSetExpires(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	seen := NewMemorySeenSet(time.Hour)
+	seen.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if isNew, _ := seen.MarkSeen(ctx, "https://b.intimcity.gold/anketa1.htm"); !isNew {
+		t.Errorf("Expected the first sighting to be new")
+	}
+	if isNew, _ := seen.MarkSeen(ctx, "https://b.intimcity.gold/anketa1.htm"); isNew {
+		t.Errorf("Expected a repeated link within the TTL to be skipped")
+	}
+
+	now = now.Add(2 * time.Hour)
+	if isNew, _ := seen.MarkSeen(ctx, "https://b.intimcity.gold/anketa1.htm"); !isNew {
+		t.Errorf("Expected the link to be new again after the TTL")
+	}
+}
@@ -0,0 +1,221 @@
+package diagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+	"github.com/redis/go-redis/v9"
+)
+
+// FleetPath is the HTTP path serving the combined progress of every instance
+const FleetPath = "/debug/fleet"
+
+// InstanceProgress is the crawl progress one instance reports to the fleet
+type InstanceProgress struct {
+	Instance   string         `json:"instance"`
+	ReportedAt time.Time      `json:"reported_at"`
+	Sites      []SiteProgress `json:"sites"`
+	Listings   ListingStats   `json:"listings"`
+}
+
+// FleetProgress is the crawl progress of the current cycle across every reporting instance.
+// A page counts as done once the crawl has moved past it.
+type FleetProgress struct {
+	Instances       []InstanceProgress `json:"instances"`
+	PagesDone       int                `json:"pages_done"`
+	TotalPages      int                `json:"total_pages"`
+	Completion      float64            `json:"completion"` // share of the cycle's pages done
+	ListingsScraped uint64             `json:"listings_scraped"`
+	ETA             string             `json:"eta,omitempty"`         // until the slowest instance finishes its cycle
+	FinishesAt      time.Time          `json:"finishes_at,omitempty"` // zero until every instance has crawled a page
+}
+
+// SetFleetStore makes Serve expose the combined progress of the fleet at FleetPath
+func (t *Tracker) SetFleetStore(store *RedisFleetStore) {
+	t.fleet = store
+}
+
+// InstanceProgress returns the progress of the tracker as reported by instance
+func (t *Tracker) InstanceProgress(instance string) InstanceProgress {
+	snapshot := t.Snapshot()
+	return InstanceProgress{
+		Instance:   instance,
+		ReportedAt: clock.Now(),
+		Sites:      snapshot.Sites,
+		Listings:   snapshot.Listings,
+	}
+}
+
+// CombineProgress adds up the progress of the instances. The ETA extrapolates the pace of each
+// instance and site since its cycle started, and the cycle is done when the slowest one is.
+func CombineProgress(instances []InstanceProgress, now time.Time) FleetProgress {
+	fleet := FleetProgress{Instances: instances}
+
+	paced := true
+	for _, instance := range instances {
+		fleet.ListingsScraped += instance.Listings.Scraped
+
+		for _, site := range instance.Sites {
+			done := min(max(site.Page-1, 0), site.TotalPages)
+			fleet.PagesDone += done
+			fleet.TotalPages += site.TotalPages
+
+			elapsed := instance.ReportedAt.Sub(site.CycleStartedAt)
+			if done == 0 || site.CycleStartedAt.IsZero() || elapsed <= 0 {
+				paced = paced && done == site.TotalPages
+				continue
+			}
+			perPage := elapsed / time.Duration(done)
+			finishesAt := instance.ReportedAt.Add(perPage * time.Duration(site.TotalPages-done))
+			if finishesAt.After(fleet.FinishesAt) {
+				fleet.FinishesAt = finishesAt
+			}
+		}
+	}
+
+	if fleet.TotalPages > 0 {
+		fleet.Completion = float64(fleet.PagesDone) / float64(fleet.TotalPages)
+	}
+	if !paced {
+		fleet.FinishesAt = time.Time{}
+	}
+	if !fleet.FinishesAt.IsZero() {
+		fleet.ETA = max(fleet.FinishesAt.Sub(now), 0).Round(time.Second).String()
+	}
+	return fleet
+}
+
+// RedisFleetStore keeps the progress of every instance in a Redis hash, one field per instance.
+// Instances that stopped reporting are dropped after staleAfter.
+type RedisFleetStore struct {
+	client     *redis.Client
+	key        string
+	staleAfter time.Duration
+}
+
+// NewRedisFleetStore creates a fleet store on an existing Redis client
+func NewRedisFleetStore(client *redis.Client, key string, staleAfter time.Duration) *RedisFleetStore {
+	return &RedisFleetStore{client: client, key: key, staleAfter: staleAfter}
+}
+
+// Report replaces the stored progress of an instance
+func (s *RedisFleetStore) Report(ctx context.Context, progress InstanceProgress) error {
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return fmt.Errorf("failed to encode instance progress: %w", err)
+	}
+	if err := s.client.HSet(ctx, s.key, progress.Instance, data).Err(); err != nil {
+		return fmt.Errorf("failed to report instance progress: %w", err)
+	}
+	return nil
+}
+
+// Remove drops the progress of an instance, for an instance shutting down
+func (s *RedisFleetStore) Remove(ctx context.Context, instance string) error {
+	if err := s.client.HDel(ctx, s.key, instance).Err(); err != nil {
+		return fmt.Errorf("failed to remove instance progress: %w", err)
+	}
+	return nil
+}
+
+// Instances returns the progress of every instance that reported within staleAfter, by name.
+// Stale entries are removed.
+func (s *RedisFleetStore) Instances(ctx context.Context) ([]InstanceProgress, error) {
+	entries, err := s.client.HGetAll(ctx, s.key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load fleet progress: %w", err)
+	}
+
+	cutoff := clock.Now().Add(-s.staleAfter)
+	var instances []InstanceProgress
+	for name, data := range entries {
+		var progress InstanceProgress
+		if err := json.Unmarshal([]byte(data), &progress); err != nil || progress.ReportedAt.Before(cutoff) {
+			s.client.HDel(ctx, s.key, name)
+			continue
+		}
+		instances = append(instances, progress)
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].Instance < instances[j].Instance })
+	return instances, nil
+}
+
+// Progress returns the combined progress of the fleet
+func (s *RedisFleetStore) Progress(ctx context.Context) (*FleetProgress, error) {
+	instances, err := s.Instances(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fleet := CombineProgress(instances, clock.Now())
+	return &fleet, nil
+}
+
+// FleetHandler returns an HTTP handler serving the combined fleet progress as JSON
+func (s *RedisFleetStore) FleetHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fleet, err := s.Progress(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(fleet)
+	})
+}
+
+// RunFleetReporter reports the tracker's progress as instance every interval. When ctx is
+// cancelled the instance is removed, so the fleet view does not wait for it to go stale.
+func RunFleetReporter(ctx context.Context, tracker *Tracker, store *RedisFleetStore, instance string, interval time.Duration) {
+	report := func() {
+		if err := store.Report(ctx, tracker.InstanceProgress(instance)); err != nil {
+			log.WarnContext(ctx, "Failed to report fleet progress", "error", err)
+		}
+	}
+	report()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			report()
+		case <-ctx.Done():
+			removeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := store.Remove(removeCtx, instance); err != nil {
+				log.Warn("Failed to remove fleet progress", "error", err)
+			}
+			cancel()
+			return
+		}
+	}
+}
+
+// FetchFleetProgress reads the combined fleet progress from a running diagnostics endpoint
+func FetchFleetProgress(ctx context.Context, baseURL string) (*FleetProgress, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+FleetPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fleet progress request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach diagnostics endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fleet progress endpoint returned status %d", resp.StatusCode)
+	}
+
+	var fleet FleetProgress
+	if err := json.NewDecoder(resp.Body).Decode(&fleet); err != nil {
+		return nil, fmt.Errorf("failed to decode fleet progress: %w", err)
+	}
+	return &fleet, nil
+}
@@ -0,0 +1,72 @@
+package diagnostics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCombineProgress(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	instances := []InstanceProgress{
+		{
+			// 5 pages done in 50s, 5 to go: finishes in 50s
+			Instance:   "crawler-a",
+			ReportedAt: now,
+			Sites:      []SiteProgress{{Site: "intimcity.gold", Page: 6, TotalPages: 10, CycleStartedAt: now.Add(-50 * time.Second)}},
+			Listings:   ListingStats{Scraped: 40},
+		},
+		{
+			// 10 pages done in 20s, 10 to go: finishes in 20s
+			Instance:   "crawler-b",
+			ReportedAt: now,
+			Sites:      []SiteProgress{{Site: "intimcity.gold", Page: 11, TotalPages: 20, CycleStartedAt: now.Add(-20 * time.Second)}},
+			Listings:   ListingStats{Scraped: 60},
+		},
+	}
+
+	fleet := CombineProgress(instances, now)
+	if fleet.PagesDone != 15 || fleet.TotalPages != 30 || fleet.Completion != 0.5 {
+		t.Errorf("Expected 15/30 pages at 0.5, got %d/%d at %v", fleet.PagesDone, fleet.TotalPages, fleet.Completion)
+	}
+	if fleet.ListingsScraped != 100 {
+		t.Errorf("Expected 100 listings scraped, got %d", fleet.ListingsScraped)
+	}
+	if fleet.ETA != "50s" || !fleet.FinishesAt.Equal(now.Add(50*time.Second)) {
+		t.Errorf("Expected the slowest instance to set the ETA at 50s, got %q at %v", fleet.ETA, fleet.FinishesAt)
+	}
+
+	// An instance that has not finished a page yet has no pace, so the ETA is unknown
+	instances = append(instances, InstanceProgress{
+		Instance:   "crawler-c",
+		ReportedAt: now,
+		Sites:      []SiteProgress{{Site: "intimcity.gold", Page: 1, TotalPages: 10, CycleStartedAt: now}},
+	})
+	fleet = CombineProgress(instances, now)
+	if fleet.ETA != "" || !fleet.FinishesAt.IsZero() {
+		t.Errorf("Expected no ETA while an instance has no pace, got %q", fleet.ETA)
+	}
+	if fleet.TotalPages != 40 {
+		t.Errorf("Expected 40 pages, got %d", fleet.TotalPages)
+	}
+}
+
+func TestTrackerCycleStart(t *testing.T) {
+	tracker := NewTracker(0)
+
+	tracker.SetSiteProgress("intimcity.gold", 1, 1, 10)
+	started := tracker.Snapshot().Sites[0].CycleStartedAt
+	if started.IsZero() {
+		t.Fatalf("Expected the cycle start to be recorded")
+	}
+
+	tracker.SetSiteProgress("intimcity.gold", 1, 2, 10)
+	if got := tracker.Snapshot().Sites[0].CycleStartedAt; !got.Equal(started) {
+		t.Errorf("Expected the cycle start to stay %v within a cycle, got %v", started, got)
+	}
+
+	time.Sleep(time.Millisecond)
+	tracker.SetSiteProgress("intimcity.gold", 2, 1, 10)
+	if got := tracker.Snapshot().Sites[0].CycleStartedAt; !got.After(started) {
+		t.Errorf("Expected a new cycle to restart the clock, got %v", got)
+	}
+}
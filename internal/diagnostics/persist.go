@@ -0,0 +1,203 @@
+package diagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+	"github.com/gregor-tokarev/hoe_parser/internal/logger"
+	"github.com/gregor-tokarev/hoe_parser/internal/metrics"
+	"github.com/redis/go-redis/v9"
+)
+
+// log is the component logger of the package
+var log = logger.Component("diagnostics")
+
+// PersistedState is the part of the pipeline state carried over restarts: monotonic counters
+// and the last crawl position of each site
+type PersistedState struct {
+	SavedAt  time.Time               `json:"saved_at"`
+	Counters []metrics.CounterSample `json:"counters"`
+	Listings ListingStats            `json:"listings"`
+	Sites    []SiteProgress          `json:"sites"`
+}
+
+// StateStore persists the pipeline state between runs
+type StateStore interface {
+	// Save replaces the stored state
+	Save(ctx context.Context, state *PersistedState) error
+	// Load returns the stored state, or nil when nothing was saved yet
+	Load(ctx context.Context) (*PersistedState, error)
+}
+
+// FileStateStore keeps the state in a JSON file
+type FileStateStore struct {
+	path string
+}
+
+// NewFileStateStore creates a store writing to path
+func NewFileStateStore(path string) *FileStateStore {
+	return &FileStateStore{path: path}
+}
+
+// Save writes the state to a temporary file and renames it over the old one, so a crash
+// mid-write never leaves a truncated snapshot
+func (s *FileStateStore) Save(ctx context.Context, state *PersistedState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to replace state: %w", err)
+	}
+	return nil
+}
+
+// Load reads the state file
+func (s *FileStateStore) Load(ctx context.Context) (*PersistedState, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state: %w", err)
+	}
+
+	var state PersistedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse state: %w", err)
+	}
+	return &state, nil
+}
+
+// RedisStateStore keeps the state under a Redis key, for deployments without a persistent disk
+type RedisStateStore struct {
+	client *redis.Client
+	key    string
+}
+
+// NewRedisStateStore creates a store on an existing Redis client
+func NewRedisStateStore(client *redis.Client, key string) *RedisStateStore {
+	return &RedisStateStore{client: client, key: key}
+}
+
+// Save stores the state as JSON
+func (s *RedisStateStore) Save(ctx context.Context, state *PersistedState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+	if err := s.client.Set(ctx, s.key, data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	return nil
+}
+
+// Load reads the state
+func (s *RedisStateStore) Load(ctx context.Context) (*PersistedState, error) {
+	data, err := s.client.Get(ctx, s.key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+
+	var state PersistedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse state: %w", err)
+	}
+	return &state, nil
+}
+
+// CaptureState returns the tracker counters and site progress together with the Prometheus counters
+func (t *Tracker) CaptureState() (*PersistedState, error) {
+	counters, err := metrics.CaptureCounters()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := t.Snapshot()
+	listings := snapshot.Listings
+	listings.InsertsPerMinute = 0
+
+	return &PersistedState{
+		SavedAt:  clock.Now(),
+		Counters: counters,
+		Listings: listings,
+		Sites:    snapshot.Sites,
+	}, nil
+}
+
+// RestoreState carries a persisted state over: counters continue from their saved values and
+// crawl cycles are numbered on from the last saved cycle. Call it once, before the pipeline starts.
+func (t *Tracker) RestoreState(state *PersistedState) error {
+	if state == nil {
+		return nil
+	}
+
+	if err := metrics.RestoreCounters(state.Counters); err != nil {
+		return err
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.listings.Scraped += state.Listings.Scraped
+	t.listings.ScrapeFailed += state.Listings.ScrapeFailed
+	t.listings.Inserted += state.Listings.Inserted
+	t.listings.InsertFailed += state.Listings.InsertFailed
+
+	for _, saved := range state.Sites {
+		progress := t.siteFor(saved.Site)
+		progress.Cycle = saved.Cycle
+		progress.Page = saved.Page
+		progress.TotalPages = saved.TotalPages
+		progress.LinksDiscovered += saved.LinksDiscovered
+		progress.UpdatedAt = saved.UpdatedAt
+		t.cycleOffsets[saved.Site] = saved.Cycle
+	}
+	return nil
+}
+
+// RunStatePersistence saves the state every interval and once more when ctx is cancelled
+func RunStatePersistence(ctx context.Context, tracker *Tracker, store StateStore, interval time.Duration) {
+	save := func(ctx context.Context) {
+		state, err := tracker.CaptureState()
+		if err == nil {
+			err = store.Save(ctx, state)
+		}
+		if err != nil {
+			log.WarnContext(ctx, "Failed to persist metrics snapshot", "error", err)
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			save(ctx)
+		case <-ctx.Done():
+			// ctx is already cancelled; the final save gets its own deadline
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			save(shutdownCtx)
+			cancel()
+			return
+		}
+	}
+}
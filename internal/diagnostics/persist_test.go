@@ -0,0 +1,62 @@
+package diagnostics
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestStateSurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	store := NewFileStateStore(filepath.Join(t.TempDir(), "state", "snapshot.json"))
+
+	if state, err := store.Load(ctx); err != nil || state != nil {
+		t.Fatalf("Expected no state before the first save, got %v, %v", state, err)
+	}
+
+	before := NewTracker(4)
+	before.ListingScraped(nil)
+	before.ListingScraped(nil)
+	before.RowsInserted(2, nil)
+	before.SetSiteProgress("intimcity.gold", 7, 12, 145)
+	before.AddLinksDiscovered("intimcity.gold", 30)
+	metrics.ObserveScrape(nil)
+	okScrapes := testutil.ToFloat64(metrics.ListingsScraped.WithLabelValues("ok"))
+
+	state, err := before.CaptureState()
+	if err != nil {
+		t.Fatalf("Failed to capture state: %v", err)
+	}
+	if err := store.Save(ctx, state); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+
+	// Simulate the restart: metrics start from zero again
+	metrics.ListingsScraped.Reset()
+
+	loaded, err := store.Load(ctx)
+	if err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+	after := NewTracker(4)
+	if err := after.RestoreState(loaded); err != nil {
+		t.Fatalf("Failed to restore state: %v", err)
+	}
+
+	after.ListingScraped(nil)
+	after.SetSiteProgress("intimcity.gold", 1, 1, 145)
+
+	snapshot := after.Snapshot()
+	if snapshot.Listings.Scraped != 3 || snapshot.Listings.Inserted != 2 {
+		t.Errorf("Expected counters to continue from the saved state, got %+v", snapshot.Listings)
+	}
+	if len(snapshot.Sites) != 1 || snapshot.Sites[0].Cycle != 8 || snapshot.Sites[0].LinksDiscovered != 30 {
+		t.Errorf("Expected cycle 8 after the restart with 30 links, got %+v", snapshot.Sites)
+	}
+	if got := testutil.ToFloat64(metrics.ListingsScraped.WithLabelValues("ok")); got != okScrapes {
+		t.Errorf("Expected the scrape counter restored to %v, got %v", okScrapes, got)
+	}
+}
@@ -0,0 +1,74 @@
+package diagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// PipelinePath is the HTTP path serving the pipeline snapshot
+const PipelinePath = "/debug/pipeline"
+
+// Handler returns an HTTP handler serving the tracker snapshot as JSON
+func (t *Tracker) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(t.Snapshot()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// Serve starts the diagnostics HTTP server and blocks until ctx is cancelled
+func (t *Tracker) Serve(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle(PipelinePath, t.Handler())
+	if t.fleet != nil {
+		mux.Handle(FleetPath, t.fleet.FleetHandler())
+	}
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("diagnostics server failed: %w", err)
+	}
+	return nil
+}
+
+// FetchSnapshot reads a snapshot from a running diagnostics endpoint
+func FetchSnapshot(ctx context.Context, baseURL string) (*Snapshot, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+PipelinePath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create diagnostics request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach diagnostics endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("diagnostics endpoint returned status %d", resp.StatusCode)
+	}
+
+	var snapshot Snapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode diagnostics snapshot: %w", err)
+	}
+
+	return &snapshot, nil
+}
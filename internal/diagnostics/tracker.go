@@ -0,0 +1,248 @@
+package diagnostics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxRecentErrors is the number of errors kept for the snapshot
+const maxRecentErrors = 20
+
+// rateWindow is the window over which the insert rate is computed
+const rateWindow = time.Minute
+
+// SiteProgress describes how far the crawl of one site has progressed in the current cycle
+type SiteProgress struct {
+	Site            string    `json:"site"`
+	State           string    `json:"state"`
+	Cycle           int       `json:"cycle"`
+	Page            int       `json:"page"`
+	TotalPages      int       `json:"total_pages"`
+	LinksDiscovered uint64    `json:"links_discovered"`
+	CycleStartedAt  time.Time `json:"cycle_started_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// QueueDepth is the fill level of an internal channel
+type QueueDepth struct {
+	Name     string `json:"name"`
+	Depth    int    `json:"depth"`
+	Capacity int    `json:"capacity"`
+}
+
+// WorkerStats describes scrape worker utilization
+type WorkerStats struct {
+	Active      int     `json:"active"`
+	Max         int     `json:"max"`
+	Utilization float64 `json:"utilization"`
+}
+
+// ListingStats holds pipeline throughput counters (rows for inserts)
+type ListingStats struct {
+	Scraped          uint64  `json:"scraped"`
+	ScrapeFailed     uint64  `json:"scrape_failed"`
+	Inserted         uint64  `json:"inserted"`
+	InsertFailed     uint64  `json:"insert_failed"`
+	InsertsPerMinute float64 `json:"inserts_per_minute"`
+}
+
+// ErrorEntry is a recent pipeline error
+type ErrorEntry struct {
+	Time    time.Time `json:"time"`
+	Stage   string    `json:"stage"`
+	Message string    `json:"message"`
+}
+
+// Snapshot is the pipeline state served by the diagnostics endpoint
+type Snapshot struct {
+	StartedAt    time.Time      `json:"started_at"`
+	Uptime       string         `json:"uptime"`
+	Sites        []SiteProgress `json:"sites"`
+	Queues       []QueueDepth   `json:"queues"`
+	Workers      WorkerStats    `json:"workers"`
+	Listings     ListingStats   `json:"listings"`
+	RecentErrors []ErrorEntry   `json:"recent_errors"`
+}
+
+// Tracker collects live pipeline state from the running stages
+type Tracker struct {
+	mutex     sync.Mutex
+	startedAt time.Time
+	sites     map[string]*SiteProgress
+	queues    map[string]func() (int, int)
+	workers   WorkerStats
+	listings  ListingStats
+	inserts   []insertSample
+	errors    []ErrorEntry
+
+	// cycleOffsets continue the cycle numbering of each site from the state restored at startup
+	cycleOffsets map[string]int
+
+	fleet *RedisFleetStore // serves FleetPath when set, see fleet.go
+}
+
+// insertSample is a successful insert used to compute the insert rate
+type insertSample struct {
+	at    time.Time
+	count int
+}
+
+// NewTracker creates a tracker; maxWorkers is used to compute utilization (0 means unbounded)
+func NewTracker(maxWorkers int) *Tracker {
+	return &Tracker{
+		startedAt: time.Now(),
+		sites:     make(map[string]*SiteProgress),
+		queues:    make(map[string]func() (int, int)),
+		workers:   WorkerStats{Max: maxWorkers},
+
+		cycleOffsets: make(map[string]int),
+	}
+}
+
+// SetSiteProgress records the page currently being crawled for a site
+func (t *Tracker) SetSiteProgress(site string, cycle, page, totalPages int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := time.Now()
+	progress := t.siteFor(site)
+	if cycle := t.cycleOffsets[site] + cycle; cycle != progress.Cycle || progress.CycleStartedAt.IsZero() {
+		progress.Cycle = cycle
+		progress.CycleStartedAt = now
+	}
+	progress.Page = page
+	progress.TotalPages = totalPages
+	progress.UpdatedAt = now
+}
+
+// SetSiteState records the crawl state of a site (active, paused, probing)
+func (t *Tracker) SetSiteState(site, state string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.siteFor(site).State = state
+}
+
+// AddLinksDiscovered increments the number of listing links found for a site
+func (t *Tracker) AddLinksDiscovered(site string, count int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.siteFor(site).LinksDiscovered += uint64(count)
+}
+
+// RegisterQueue registers a function reporting the depth and capacity of a queue
+func (t *Tracker) RegisterQueue(name string, depth func() (int, int)) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.queues[name] = depth
+}
+
+// WorkerStarted marks a scrape worker as busy
+func (t *Tracker) WorkerStarted() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.workers.Active++
+}
+
+// WorkerFinished marks a scrape worker as idle
+func (t *Tracker) WorkerFinished() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.workers.Active > 0 {
+		t.workers.Active--
+	}
+}
+
+// ListingScraped records the outcome of a listing scrape
+func (t *Tracker) ListingScraped(err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if err != nil {
+		t.listings.ScrapeFailed++
+		return
+	}
+	t.listings.Scraped++
+}
+
+// RowsInserted records the outcome of a storage insert of count rows
+func (t *Tracker) RowsInserted(count int, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if err != nil {
+		t.listings.InsertFailed += uint64(count)
+		return
+	}
+	t.listings.Inserted += uint64(count)
+	t.inserts = append(t.inserts, insertSample{at: time.Now(), count: count})
+}
+
+// RecordError appends an error to the recent errors list
+func (t *Tracker) RecordError(stage string, err error) {
+	if err == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.errors = append(t.errors, ErrorEntry{Time: time.Now(), Stage: stage, Message: err.Error()})
+	if len(t.errors) > maxRecentErrors {
+		t.errors = t.errors[len(t.errors)-maxRecentErrors:]
+	}
+}
+
+// Snapshot returns a consistent copy of the current pipeline state
+func (t *Tracker) Snapshot() Snapshot {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := time.Now()
+
+	// Drop inserts that fell out of the rate window
+	cutoff := now.Add(-rateWindow)
+	kept := t.inserts[:0]
+	inserted := 0
+	for _, sample := range t.inserts {
+		if sample.at.After(cutoff) {
+			kept = append(kept, sample)
+			inserted += sample.count
+		}
+	}
+	t.inserts = kept
+
+	snapshot := Snapshot{
+		StartedAt:    t.startedAt,
+		Uptime:       now.Sub(t.startedAt).Round(time.Second).String(),
+		Workers:      t.workers,
+		Listings:     t.listings,
+		RecentErrors: append([]ErrorEntry(nil), t.errors...),
+	}
+	snapshot.Listings.InsertsPerMinute = float64(inserted) / rateWindow.Minutes()
+
+	if t.workers.Max > 0 {
+		snapshot.Workers.Utilization = float64(t.workers.Active) / float64(t.workers.Max)
+	}
+
+	for _, progress := range t.sites {
+		snapshot.Sites = append(snapshot.Sites, *progress)
+	}
+	sort.Slice(snapshot.Sites, func(i, j int) bool { return snapshot.Sites[i].Site < snapshot.Sites[j].Site })
+
+	for name, depth := range t.queues {
+		current, capacity := depth()
+		snapshot.Queues = append(snapshot.Queues, QueueDepth{Name: name, Depth: current, Capacity: capacity})
+	}
+	sort.Slice(snapshot.Queues, func(i, j int) bool { return snapshot.Queues[i].Name < snapshot.Queues[j].Name })
+
+	return snapshot
+}
+
+// siteFor returns the progress entry for a site, creating it if needed. Must be called with the mutex held.
+func (t *Tracker) siteFor(site string) *SiteProgress {
+	progress, exists := t.sites[site]
+	if !exists {
+		progress = &SiteProgress{Site: site, State: "active"}
+		t.sites[site] = progress
+	}
+	return progress
+}
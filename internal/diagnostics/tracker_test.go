@@ -0,0 +1,53 @@
+package diagnostics
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTrackerSnapshot(t *testing.T) {
+	tracker := NewTracker(4)
+
+	tracker.SetSiteProgress("intimcity.gold", 2, 5, 10)
+	tracker.AddLinksDiscovered("intimcity.gold", 7)
+	tracker.RegisterQueue("links", func() (int, int) { return 3, 25 })
+	tracker.WorkerStarted()
+	tracker.WorkerStarted()
+	tracker.WorkerFinished()
+	tracker.RowsInserted(5, nil)
+	tracker.RowsInserted(2, errors.New("boom"))
+	tracker.RecordError("insert", errors.New("boom"))
+	tracker.RecordError("insert", nil)
+
+	snapshot := tracker.Snapshot()
+
+	if len(snapshot.Sites) != 1 || snapshot.Sites[0].Page != 5 || snapshot.Sites[0].LinksDiscovered != 7 {
+		t.Errorf("Expected site progress page 5 with 7 links, got %+v", snapshot.Sites)
+	}
+	if len(snapshot.Queues) != 1 || snapshot.Queues[0].Depth != 3 || snapshot.Queues[0].Capacity != 25 {
+		t.Errorf("Expected links queue 3/25, got %+v", snapshot.Queues)
+	}
+	if snapshot.Workers.Active != 1 || snapshot.Workers.Utilization != 0.25 {
+		t.Errorf("Expected 1 active worker at 0.25 utilization, got %+v", snapshot.Workers)
+	}
+	if snapshot.Listings.Inserted != 5 || snapshot.Listings.InsertFailed != 2 {
+		t.Errorf("Expected 5 inserted and 2 failed, got %+v", snapshot.Listings)
+	}
+	if snapshot.Listings.InsertsPerMinute != 5 {
+		t.Errorf("Expected 5 rows/min, got %v", snapshot.Listings.InsertsPerMinute)
+	}
+	if len(snapshot.RecentErrors) != 1 {
+		t.Errorf("Expected 1 recent error, got %d", len(snapshot.RecentErrors))
+	}
+}
+
+func TestRecentErrorsBounded(t *testing.T) {
+	tracker := NewTracker(0)
+	for i := 0; i < maxRecentErrors+5; i++ {
+		tracker.RecordError("scrape", errors.New("failure"))
+	}
+
+	if got := len(tracker.Snapshot().RecentErrors); got != maxRecentErrors {
+		t.Errorf("Expected %d recent errors, got %d", maxRecentErrors, got)
+	}
+}
@@ -0,0 +1,149 @@
+package events
+
+import "sync"
+
+// DefaultBuffer is the number of events queued per subscriber when Subscribe is given 0
+const DefaultBuffer = 256
+
+// Handler consumes the events of one subscription
+type Handler func(Event)
+
+// Bus delivers published events to every subscriber. Each subscriber has its own queue and
+// goroutine, so a slow consumer never blocks the pipeline: events for a full queue are dropped
+// and reported to the drop handler.
+type Bus struct {
+	mutex       sync.RWMutex
+	subscribers []*subscription
+	closed      bool
+	onDrop      func(subscriber string, event Event)
+}
+
+// subscription is one subscriber's queue
+type subscription struct {
+	name    string
+	events  chan Event
+	handler Handler
+	done    chan struct{}
+}
+
+// NewBus creates an event bus without subscribers
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// SetDropHandler sets a callback receiving every event dropped because a subscriber's queue was full
+func (b *Bus) SetDropHandler(handler func(subscriber string, event Event)) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.onDrop = handler
+}
+
+// Subscribe delivers every event published from now on to handler, in publish order, holding up
+// to buffer undelivered events. name identifies the subscriber in drop reports. The returned
+// function unsubscribes and waits for the queued events to be handled.
+func (b *Bus) Subscribe(name string, buffer int, handler Handler) func() {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	sub := &subscription{
+		name:    name,
+		events:  make(chan Event, buffer),
+		handler: handler,
+		done:    make(chan struct{}),
+	}
+
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		close(sub.done)
+		return func() {}
+	}
+	b.subscribers = append(b.subscribers, sub)
+	b.mutex.Unlock()
+
+	go sub.run()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			if b.remove(sub) {
+				close(sub.events)
+			}
+			<-sub.done
+		})
+	}
+}
+
+// Publish queues event for every subscriber without blocking. Publishing to a nil or closed bus
+// does nothing, so stages can publish unconditionally.
+func (b *Bus) Publish(event Event) {
+	if b == nil {
+		return
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	if b.closed {
+		return
+	}
+
+	for _, sub := range b.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			if b.onDrop != nil {
+				b.onDrop(sub.name, event)
+			}
+		}
+	}
+}
+
+// Close stops accepting events and waits until every subscriber has handled its queued events
+func (b *Bus) Close() {
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		return
+	}
+	b.closed = true
+	subscribers := b.subscribers
+	b.subscribers = nil
+	b.mutex.Unlock()
+
+	for _, sub := range subscribers {
+		close(sub.events)
+	}
+	for _, sub := range subscribers {
+		<-sub.done
+	}
+}
+
+// remove drops sub from the subscribers and reports whether it was still subscribed
+func (b *Bus) remove(sub *subscription) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for i, s := range b.subscribers {
+		if s == sub {
+			b.subscribers = append(b.subscribers[:i:i], b.subscribers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// run handles queued events until the queue is closed
+func (s *subscription) run() {
+	defer close(s.done)
+	for event := range s.events {
+		s.handler(event)
+	}
+}
+
+// On adapts a handler for one event type, ignoring events of other types
+func On[T Event](handler func(T)) Handler {
+	return func(event Event) {
+		if typed, ok := event.(T); ok {
+			handler(typed)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package events
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestBusDeliversInOrder(t *testing.T) {
+	bus := NewBus()
+
+	var urls []string
+	bus.Subscribe("test", 0, On(func(event LinkDiscovered) {
+		urls = append(urls, event.URL)
+	}))
+
+	bus.Publish(LinkDiscovered{URL: "a"})
+	bus.Publish(NewScrapeFailed("site:1", "b", StageScrape, errors.New("timeout")))
+	bus.Publish(LinkDiscovered{URL: "c"})
+	bus.Close()
+
+	if len(urls) != 2 || urls[0] != "a" || urls[1] != "c" {
+		t.Errorf("Expected [a c], got %v", urls)
+	}
+}
+
+func TestBusDropsForFullSubscriber(t *testing.T) {
+	bus := NewBus()
+
+	release := make(chan struct{})
+	bus.Subscribe("slow", 1, func(Event) { <-release })
+
+	var mutex sync.Mutex
+	var dropped []string
+	bus.SetDropHandler(func(subscriber string, event Event) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		dropped = append(dropped, subscriber)
+	})
+
+	// The first event may be taken by the handler or stay queued; either way a third one
+	// finds the queue full
+	for i := 0; i < 3; i++ {
+		bus.Publish(ListingScraped{ListingID: "site:1"})
+	}
+	close(release)
+	bus.Close()
+
+	if len(dropped) == 0 || dropped[0] != "slow" {
+		t.Errorf("Expected events dropped for slow, got %v", dropped)
+	}
+}
+
+func TestBusUnsubscribe(t *testing.T) {
+	bus := NewBus()
+
+	count := 0
+	unsubscribe := bus.Subscribe("test", 0, func(Event) { count++ })
+	bus.Publish(ListingInserted{Rows: 1})
+	unsubscribe()
+	bus.Publish(ListingInserted{Rows: 1})
+	bus.Close()
+
+	if count != 1 {
+		t.Errorf("Expected 1 event before unsubscribing, got %d", count)
+	}
+
+	var nilBus *Bus
+	nilBus.Publish(ListingInserted{}) // must not panic
+}
@@ -0,0 +1,134 @@
+package events
+
+import (
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+)
+
+// Event types, as returned by Event.Type
+const (
+	TypeLinkDiscovered  = "link.discovered"
+	TypeListingScraped  = "listing.scraped"
+	TypeListingInserted = "listing.inserted"
+	TypeListingUpdated  = "listing.updated"
+	TypeListingRemoved  = "listing.removed"
+	TypeScrapeFailed    = "scrape.failed"
+)
+
+// Stages a ScrapeFailed event can come from
+const (
+	StageScrape = "scrape"
+	StageInsert = "insert"
+)
+
+// Event is a typed pipeline event. Consumers switch on the concrete type.
+type Event interface {
+	Type() string
+}
+
+// LinkDiscovered is published when an index page yields a listing link not emitted before
+type LinkDiscovered struct {
+	URL          string    `json:"url"`
+	SourceID     string    `json:"source_id"`
+	DiscoveredAt time.Time `json:"discovered_at"`
+}
+
+// Type implements Event
+func (LinkDiscovered) Type() string { return TypeLinkDiscovered }
+
+// ListingScraped is published when a listing page was scraped and parsed
+type ListingScraped struct {
+	ListingID string          `json:"listing_id"`
+	URL       string          `json:"url"`
+	ScrapedAt time.Time       `json:"scraped_at"`
+	Fields    map[string]bool `json:"fields"` // whether each key field was parsed
+
+	// Parse quality of the listing, see clickhouse.ValidateListing
+	QualityScore float32  `json:"quality_score"`
+	QualityFlags []string `json:"quality_flags,omitempty"`
+}
+
+// Type implements Event
+func (ListingScraped) Type() string { return TypeListingScraped }
+
+// ListingInserted is published when a scraped listing was stored. Rows is 0 when the listing was
+// unchanged and not rewritten. Listing is the stored version, for the storage sinks; it is left
+// out of webhook payloads.
+type ListingInserted struct {
+	ListingID     string                       `json:"listing_id"`
+	URL           string                       `json:"url"`
+	Rows          int                          `json:"rows"`
+	ChangedFields []string                     `json:"changed_fields,omitempty"`
+	StoredAt      time.Time                    `json:"stored_at"`
+	Listing       *clickhouse.FlattenedListing `json:"-"`
+}
+
+// Type implements Event
+func (ListingInserted) Type() string { return TypeListingInserted }
+
+// ListingUpdated is published when a stored listing changed since its previous version. Summary
+// has one human readable line per change, e.g. "price_hour: 7000 → 8000 (+1000)".
+type ListingUpdated struct {
+	ListingID string                       `json:"listing_id"`
+	URL       string                       `json:"url"`
+	Changes   []clickhouse.FieldChange     `json:"changes"`
+	Summary   []string                     `json:"summary"`
+	Listing   *clickhouse.FlattenedListing `json:"listing"`
+	UpdatedAt time.Time                    `json:"updated_at"`
+}
+
+// Type implements Event
+func (ListingUpdated) Type() string { return TypeListingUpdated }
+
+// NewListingUpdated creates a ListingUpdated event for the changes of a stored listing
+func NewListingUpdated(listing *clickhouse.FlattenedListing, changes []clickhouse.FieldChange) ListingUpdated {
+	summary := make([]string, len(changes))
+	for i, change := range changes {
+		summary[i] = clickhouse.SummarizeChange(change)
+	}
+	return ListingUpdated{
+		ListingID: listing.ID,
+		URL:       listing.SourceURL,
+		Changes:   changes,
+		Summary:   summary,
+		Listing:   listing,
+		UpdatedAt: clock.Now(),
+	}
+}
+
+// ListingRemoved is published when a stored listing was soft-deleted because its page no longer exists
+type ListingRemoved struct {
+	ListingID string    `json:"listing_id"`
+	URL       string    `json:"url"`
+	RemovedAt time.Time `json:"removed_at"`
+}
+
+// Type implements Event
+func (ListingRemoved) Type() string { return TypeListingRemoved }
+
+// ScrapeFailed is published when a listing could not be scraped (StageScrape) or stored (StageInsert)
+type ScrapeFailed struct {
+	ListingID string    `json:"listing_id"`
+	URL       string    `json:"url"`
+	Stage     string    `json:"stage"`
+	Err       error     `json:"-"`
+	Error     string    `json:"error"`
+	FailedAt  time.Time `json:"failed_at"`
+}
+
+// Type implements Event
+func (ScrapeFailed) Type() string { return TypeScrapeFailed }
+
+// NewScrapeFailed creates a ScrapeFailed event for err
+func NewScrapeFailed(listingID, url, stage string, err error) ScrapeFailed {
+	return ScrapeFailed{
+		ListingID: listingID,
+		URL:       url,
+		Stage:     stage,
+		Err:       err,
+		Error:     err.Error(),
+		FailedAt:  clock.Now(),
+	}
+}
@@ -0,0 +1,72 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+)
+
+// Event is the envelope written to Kafka topics, matching the webhook payload
+type Event struct {
+	Type      string      `json:"type"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// Producer publishes JSON events to a single topic
+type Producer struct {
+	writer *kafkago.Writer
+}
+
+// NewProducer creates a producer for topic on the comma-separated broker list
+func NewProducer(brokers, topic string) *Producer {
+	var addrs []string
+	for _, broker := range strings.Split(brokers, ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			addrs = append(addrs, broker)
+		}
+	}
+
+	return &Producer{
+		writer: &kafkago.Writer{
+			Addr:                   kafkago.TCP(addrs...),
+			Topic:                  topic,
+			Balancer:               &kafkago.Hash{},
+			RequiredAcks:           kafkago.RequireOne,
+			AllowAutoTopicCreation: true,
+		},
+	}
+}
+
+// Send writes an event keyed by its type
+func (p *Producer) Send(ctx context.Context, eventType string, data interface{}) error {
+	return p.SendKeyed(ctx, eventType, eventType, data)
+}
+
+// SendKeyed writes an event with a message key, so events with the same key keep their order
+func (p *Producer) SendKeyed(ctx context.Context, key, eventType string, data interface{}) error {
+	body, err := json.Marshal(Event{
+		Type:      eventType,
+		Timestamp: clock.Now(),
+		Data:      data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal kafka event: %w", err)
+	}
+
+	if err := p.writer.WriteMessages(ctx, kafkago.Message{Key: []byte(key), Value: body}); err != nil {
+		return fmt.Errorf("failed to write event to %s: %w", p.writer.Topic, err)
+	}
+	return nil
+}
+
+// Close flushes pending writes and closes the producer
+func (p *Producer) Close() error {
+	return p.writer.Close()
+}
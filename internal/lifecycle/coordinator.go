@@ -0,0 +1,137 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/logger"
+)
+
+// log is the component logger of the package
+var log = logger.Component("lifecycle")
+
+// Stage orders shutdown steps: every step of a stage finishes before the next stage starts
+type Stage int
+
+// Shutdown stages, in the order they run
+const (
+	StageIntake     Stage = iota // stop producing work: discovery and monitoring loops
+	StageDrain                   // finish queued work: link channel and worker pool
+	StageFlush                   // write buffered data: insert buffer
+	StageNotify                  // deliver queued events to subscribers and webhooks
+	StageBackground              // stop servers and background jobs, save the metrics snapshot
+	StageClose                   // close connections: Kafka, Redis, ClickHouse
+)
+
+// StopFunc stops one component. It should return once the component has stopped or ctx is done.
+type StopFunc func(ctx context.Context) error
+
+// step is one registered StopFunc
+type step struct {
+	stage Stage
+	name  string
+	stop  StopFunc
+}
+
+// Coordinator stops the application's components stage by stage within one deadline
+type Coordinator struct {
+	timeout time.Duration
+
+	mutex   sync.Mutex
+	steps   []step
+	stopped bool
+}
+
+// NewCoordinator creates a coordinator giving the whole shutdown at most timeout; 0 means no deadline
+func NewCoordinator(timeout time.Duration) *Coordinator {
+	return &Coordinator{timeout: timeout}
+}
+
+// Register adds a shutdown step. Steps of one stage run in registration order.
+func (c *Coordinator) Register(stage Stage, name string, stop StopFunc) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.steps = append(c.steps, step{stage: stage, name: name, stop: stop})
+}
+
+// Shutdown runs the registered steps stage by stage. A step still running at the deadline is
+// abandoned and the remaining steps are skipped; the returned error names them. Only the first
+// call runs the steps.
+func (c *Coordinator) Shutdown(ctx context.Context) error {
+	c.mutex.Lock()
+	if c.stopped {
+		c.mutex.Unlock()
+		return nil
+	}
+	c.stopped = true
+	steps := append([]step(nil), c.steps...)
+	c.mutex.Unlock()
+
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].stage < steps[j].stage })
+
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	var errs []error
+	for i, step := range steps {
+		start := time.Now()
+		if err := run(ctx, step); err != nil {
+			log.Warn("Shutdown step failed", "step", step.name, "duration", time.Since(start), "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", step.name, err))
+		} else {
+			log.Debug("Shutdown step complete", "step", step.name, "duration", time.Since(start))
+		}
+
+		if ctx.Err() != nil && i < len(steps)-1 {
+			skipped := make([]string, 0, len(steps)-i-1)
+			for _, rest := range steps[i+1:] {
+				skipped = append(skipped, rest.name)
+			}
+			errs = append(errs, fmt.Errorf("shutdown deadline exceeded, skipped %s", strings.Join(skipped, ", ")))
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// run calls the step, returning early with ctx's error when ctx is done first
+func run(ctx context.Context, step step) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- step.stop(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WaitFor returns a StopFunc waiting until done is closed
+func WaitFor(done <-chan struct{}) StopFunc {
+	return func(ctx context.Context) error {
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Close returns a StopFunc calling close, for connections that close without a context
+func Close(close func() error) StopFunc {
+	return func(context.Context) error {
+		return close()
+	}
+}
@@ -0,0 +1,81 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShutdownRunsStagesInOrder(t *testing.T) {
+	var order []string
+	record := func(name string) StopFunc {
+		return func(context.Context) error {
+			order = append(order, name)
+			return nil
+		}
+	}
+
+	coordinator := NewCoordinator(time.Second)
+	coordinator.Register(StageClose, "clickhouse", record("clickhouse"))
+	coordinator.Register(StageFlush, "insert buffer", record("insert buffer"))
+	coordinator.Register(StageIntake, "discovery", record("discovery"))
+	coordinator.Register(StageClose, "kafka", record("kafka"))
+	coordinator.Register(StageDrain, "workers", record("workers"))
+
+	if err := coordinator.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []string{"discovery", "workers", "insert buffer", "clickhouse", "kafka"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected %v, got %v", expected, order)
+	}
+
+	if err := coordinator.Shutdown(context.Background()); err != nil || len(order) != len(expected) {
+		t.Errorf("Expected a second shutdown to do nothing, got %v %v", err, order)
+	}
+}
+
+func TestShutdownSkipsStepsAfterDeadline(t *testing.T) {
+	closed := false
+	coordinator := NewCoordinator(20 * time.Millisecond)
+	coordinator.Register(StageDrain, "workers", WaitFor(make(chan struct{})))
+	coordinator.Register(StageClose, "clickhouse", Close(func() error {
+		closed = true
+		return nil
+	}))
+
+	err := coordinator.Shutdown(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the hanging step to hit the deadline, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "skipped clickhouse") {
+		t.Errorf("Expected the skipped step to be reported, got %v", err)
+	}
+	if closed {
+		t.Errorf("Expected steps after the deadline to be skipped")
+	}
+}
+
+func TestShutdownReportsFailedSteps(t *testing.T) {
+	errFlush := errors.New("flush failed")
+	closed := false
+
+	coordinator := NewCoordinator(0)
+	coordinator.Register(StageFlush, "insert buffer", func(context.Context) error { return errFlush })
+	coordinator.Register(StageClose, "clickhouse", Close(func() error {
+		closed = true
+		return nil
+	}))
+
+	err := coordinator.Shutdown(context.Background())
+	if !errors.Is(err, errFlush) {
+		t.Errorf("Expected the flush error, got %v", err)
+	}
+	if !closed {
+		t.Errorf("Expected later steps to run after a failed step")
+	}
+}
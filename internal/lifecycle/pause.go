@@ -0,0 +1,80 @@
+package lifecycle
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+)
+
+// PauseState describes whether work is paused, and by whom and why
+type PauseState struct {
+	Paused bool      `json:"paused"`
+	Reason string    `json:"reason,omitempty"`
+	By     string    `json:"by,omitempty"`    // who paused or last resumed
+	Since  time.Time `json:"since,omitempty"` // when the work was paused or last resumed
+}
+
+// Pause holds work back at runtime without stopping the process, e.g. the crawl while the target
+// site is in maintenance. Loops call Wait before each unit of work, which blocks while paused, so
+// work in progress finishes and nothing new starts.
+type Pause struct {
+	mutex   sync.Mutex
+	state   PauseState
+	resumed chan struct{} // closed while not paused
+}
+
+// NewPause creates a pause that lets work run
+func NewPause() *Pause {
+	resumed := make(chan struct{})
+	close(resumed)
+	return &Pause{resumed: resumed}
+}
+
+// Pause holds back work from now on and returns the new state. Pausing again only updates the reason.
+func (p *Pause) Pause(reason, by string) PauseState {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if !p.state.Paused {
+		p.resumed = make(chan struct{})
+		p.state = PauseState{Paused: true, Since: clock.Now()}
+	}
+	p.state.Reason = reason
+	p.state.By = by
+	return p.state
+}
+
+// Resume releases the work waiting in Wait and returns the new state
+func (p *Pause) Resume(by string) PauseState {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.state.Paused {
+		close(p.resumed)
+		p.state = PauseState{By: by, Since: clock.Now()}
+	}
+	return p.state
+}
+
+// State returns whether work is paused
+func (p *Pause) State() PauseState {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.state
+}
+
+// Wait blocks while work is paused and returns ctx.Err() if ctx is done first
+func (p *Pause) Wait(ctx context.Context) error {
+	p.mutex.Lock()
+	resumed := p.resumed
+	p.mutex.Unlock()
+
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,52 @@
+package lifecycle
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPauseHoldsWaitersUntilResumed(t *testing.T) {
+	pause := NewPause()
+	ctx := context.Background()
+
+	if err := pause.Wait(ctx); err != nil {
+		t.Fatalf("Expected Wait to return at once while running, got %v", err)
+	}
+
+	state := pause.Pause("site maintenance", "ops")
+	if !state.Paused || state.Reason != "site maintenance" || state.By != "ops" {
+		t.Errorf("Expected a paused state with reason and operator, got %+v", state)
+	}
+
+	waited := make(chan error, 1)
+	go func() { waited <- pause.Wait(ctx) }()
+	select {
+	case <-waited:
+		t.Fatalf("Expected Wait to block while paused")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if state := pause.Resume("ops"); state.Paused {
+		t.Errorf("Expected the state to be running after Resume")
+	}
+	select {
+	case err := <-waited:
+		if err != nil {
+			t.Errorf("Expected Wait to return nil once resumed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected Wait to return once resumed")
+	}
+}
+
+func TestPauseWaitReturnsWhenContextDone(t *testing.T) {
+	pause := NewPause()
+	pause.Pause("", "ops")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := pause.Wait(ctx); err != context.Canceled {
+		t.Errorf("Expected %v, got %v", context.Canceled, err)
+	}
+}
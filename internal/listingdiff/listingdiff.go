@@ -0,0 +1,142 @@
+// Package listingdiff compares two scraped Listing messages field by field. Unlike
+// clickhouse.DiffListings, which compares stored listings columns, it works on the protobuf the
+// scrapers return, so parsers and tools can see exactly which scraped values moved.
+package listingdiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	listing "github.com/gregor-tokarev/hoe_parser/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Change is one field whose value differs between two versions of a listing. Path names the
+// field by its proto names, e.g. "personal_info.age", "photos" or
+// "pricing_info.duration_prices[hour]". Old and New hold the Go values of the field: strings,
+// numbers and bools as they are, repeated fields as []any, and nil for a map key that is missing.
+type Change struct {
+	Path string `json:"path"`
+	Old  any    `json:"old"`
+	New  any    `json:"new"`
+}
+
+// OldValue renders Old the way the listing_changes log stores values
+func (c Change) OldValue() string { return FormatValue(c.Old) }
+
+// NewValue renders New the way the listing_changes log stores values
+func (c Change) NewValue() string { return FormatValue(c.New) }
+
+// Diff compares two versions of a listing and returns the changed fields in proto field order,
+// map keys sorted. A nil listing or a missing nested message compares like an empty one, and
+// repeated fields are compared as a whole.
+func Diff(previous, current *listing.Listing) []Change {
+	if previous == nil {
+		previous = &listing.Listing{}
+	}
+	if current == nil {
+		current = &listing.Listing{}
+	}
+	return diffMessages("", previous.ProtoReflect(), current.ProtoReflect())
+}
+
+// diffMessages compares the fields of two messages of the same type, prefixing their paths
+func diffMessages(prefix string, previous, current protoreflect.Message) []Change {
+	var changes []Change
+	fields := previous.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		path := prefix + string(field.Name())
+		oldValue, newValue := previous.Get(field), current.Get(field)
+
+		switch {
+		case field.IsMap():
+			changes = append(changes, diffMaps(path, field, oldValue.Map(), newValue.Map())...)
+		case field.IsList():
+			oldList, newList := listValues(oldValue.List()), listValues(newValue.List())
+			if FormatValue(oldList) != FormatValue(newList) {
+				changes = append(changes, Change{Path: path, Old: oldList, New: newList})
+			}
+		case field.Message() != nil:
+			changes = append(changes, diffMessages(path+".", oldValue.Message(), newValue.Message())...)
+		default:
+			if !oldValue.Equal(newValue) {
+				changes = append(changes, Change{Path: path, Old: oldValue.Interface(), New: newValue.Interface()})
+			}
+		}
+	}
+	return changes
+}
+
+// diffMaps compares two maps key by key, reporting an added or removed key with a nil value
+func diffMaps(path string, field protoreflect.FieldDescriptor, previous, current protoreflect.Map) []Change {
+	keys := make(map[string]protoreflect.MapKey)
+	collect := func(key protoreflect.MapKey, _ protoreflect.Value) bool {
+		keys[key.String()] = key
+		return true
+	}
+	previous.Range(collect)
+	current.Range(collect)
+
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var changes []Change
+	for _, name := range names {
+		key := keys[name]
+		oldValue, newValue := mapValue(field, previous, key), mapValue(field, current, key)
+		if FormatValue(oldValue) != FormatValue(newValue) || (oldValue == nil) != (newValue == nil) {
+			changes = append(changes, Change{Path: fmt.Sprintf("%s[%s]", path, name), Old: oldValue, New: newValue})
+		}
+	}
+	return changes
+}
+
+// mapValue returns the Go value of key in m, or nil when m has no such key
+func mapValue(field protoreflect.FieldDescriptor, m protoreflect.Map, key protoreflect.MapKey) any {
+	if !m.Has(key) {
+		return nil
+	}
+	value := m.Get(key)
+	if field.MapValue().Message() != nil {
+		return value.Message().Interface()
+	}
+	return value.Interface()
+}
+
+// listValues converts a repeated field into a slice of its Go values; an empty field is nil
+func listValues(list protoreflect.List) []any {
+	if list.Len() == 0 {
+		return nil
+	}
+	values := make([]any, list.Len())
+	for i := range values {
+		values[i] = list.Get(i).Interface()
+	}
+	return values
+}
+
+// FormatValue renders a field value as stored in listing_changes: strings as is, nil and empty
+// repeated fields as "", everything else as JSON
+func FormatValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []any:
+		if len(v) == 0 {
+			return ""
+		}
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
@@ -0,0 +1,65 @@
+package listingdiff
+
+import (
+	"testing"
+
+	listing "github.com/gregor-tokarev/hoe_parser/proto"
+)
+
+func TestDiff(t *testing.T) {
+	previous := &listing.Listing{
+		Id:           "123",
+		PersonalInfo: &listing.PersonalInfo{Name: "Анна", Age: 25},
+		PricingInfo: &listing.PricingInfo{
+			DurationPrices: map[string]int32{"apartments_day_hour": 7000, "outcall_day_hour": 9000},
+		},
+		Photos: []string{"a.jpg"},
+	}
+	current := &listing.Listing{
+		Id:           "123",
+		PersonalInfo: &listing.PersonalInfo{Name: "Анна", Age: 26},
+		PricingInfo: &listing.PricingInfo{
+			DurationPrices: map[string]int32{"apartments_day_hour": 8000, "apartments_night_hour": 20000},
+		},
+		Photos: []string{"a.jpg", "b.jpg"},
+	}
+
+	changes := Diff(previous, current)
+	expected := []struct{ path, old, new string }{
+		{"personal_info.age", "25", "26"},
+		{"pricing_info.duration_prices[apartments_day_hour]", "7000", "8000"},
+		{"pricing_info.duration_prices[apartments_night_hour]", "", "20000"},
+		{"pricing_info.duration_prices[outcall_day_hour]", "9000", ""},
+		{"photos", `["a.jpg"]`, `["a.jpg","b.jpg"]`},
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected %d changes, got %d: %+v", len(expected), len(changes), changes)
+	}
+	for i, want := range expected {
+		change := changes[i]
+		if change.Path != want.path || change.OldValue() != want.old || change.NewValue() != want.new {
+			t.Errorf("Expected %s %q -> %q, got %+v", want.path, want.old, want.new, change)
+		}
+	}
+
+	if age, ok := changes[0].New.(int32); !ok || age != 26 {
+		t.Errorf("Expected typed int32 26, got %#v", changes[0].New)
+	}
+	if changes[3].New != nil {
+		t.Errorf("Expected nil for a removed map key, got %#v", changes[3].New)
+	}
+}
+
+func TestDiffMissingMessages(t *testing.T) {
+	previous := &listing.Listing{Id: "123"}
+	current := &listing.Listing{Id: "123", ContactInfo: &listing.ContactInfo{}, ServiceInfo: &listing.ServiceInfo{AvailableServices: []string{}}}
+
+	if changes := Diff(previous, current); len(changes) != 0 {
+		t.Errorf("Expected no changes, got %+v", changes)
+	}
+
+	changes := Diff(nil, &listing.Listing{LocationInfo: &listing.LocationInfo{City: "Москва"}})
+	if len(changes) != 1 || changes[0].Path != "location_info.city" || changes[0].Old != "" || changes[0].New != "Москва" {
+		t.Errorf("Expected location_info.city set, got %+v", changes)
+	}
+}
@@ -0,0 +1,161 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+)
+
+// Output formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// root is the handler every component logger writes through, replaced by Setup
+var root atomic.Pointer[slog.Handler]
+
+func init() {
+	var handler slog.Handler = slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo})
+	root.Store(&handler)
+}
+
+// Setup installs the handler for level ("debug", "info", "warn" or "error") and format
+// ("text" or "json") writing to w. It also becomes the slog and standard log default.
+func Setup(level, format string, w io.Writer) error {
+	var slogLevel slog.Level
+	if err := slogLevel.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+
+	options := &slog.HandlerOptions{Level: slogLevel}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "", FormatText:
+		handler = slog.NewTextHandler(w, options)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, options)
+	default:
+		return fmt.Errorf("invalid log format %q: use %s or %s", format, FormatText, FormatJSON)
+	}
+
+	root.Store(&handler)
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// FromConfig sets up logging from LOG_LEVEL and LOG_FORMAT, writing to stderr
+func FromConfig(cfg *config.Config) error {
+	return Setup(cfg.LogLevel, cfg.LogFormat, os.Stderr)
+}
+
+// Component returns a logger tagging every record with component=name. It can be created in a
+// package variable: records go through whatever handler Setup installed when they are logged.
+func Component(name string) *slog.Logger {
+	return slog.New(&handler{ops: []handlerOp{{attrs: []slog.Attr{slog.String("component", name)}}}})
+}
+
+// contextKey is the type of the context keys holding log fields
+type contextKey string
+
+// Context keys of the IDs added to every record logged with that context
+const (
+	listingIDKey contextKey = "listing_id"
+	requestIDKey contextKey = "request_id"
+)
+
+// WithListingID returns a context whose log records carry listing_id
+func WithListingID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, listingIDKey, id)
+}
+
+// WithRequestID returns a context whose log records carry request_id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request ID of ctx, or ""
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// NewRequestID returns a random ID for correlating the log records of one request
+func NewRequestID() string {
+	var b [6]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// handler resolves the root handler on every record and adds the IDs found in the context
+type handler struct {
+	ops []handlerOp // WithAttrs and WithGroup calls, replayed in order on the root handler
+}
+
+// handlerOp is one WithAttrs (attrs set) or WithGroup (group set) call
+type handlerOp struct {
+	attrs []slog.Attr
+	group string
+}
+
+// Enabled implements slog.Handler
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return (*root.Load()).Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler
+func (h *handler) Handle(ctx context.Context, record slog.Record) error {
+	target := *root.Load()
+	for _, op := range h.ops {
+		if op.group != "" {
+			target = target.WithGroup(op.group)
+		} else {
+			target = target.WithAttrs(op.attrs)
+		}
+	}
+
+	for _, key := range []contextKey{listingIDKey, requestIDKey} {
+		if value, ok := ctx.Value(key).(string); ok && value != "" && !hasAttr(record, string(key)) {
+			record.AddAttrs(slog.String(string(key), value))
+		}
+	}
+	return target.Handle(ctx, record)
+}
+
+// hasAttr reports whether the record already carries a top-level attribute named key
+func hasAttr(record slog.Record, key string) bool {
+	found := false
+	record.Attrs(func(attr slog.Attr) bool {
+		found = attr.Key == key
+		return !found
+	})
+	return found
+}
+
+// WithAttrs implements slog.Handler
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(handlerOp{attrs: attrs})
+}
+
+// WithGroup implements slog.Handler
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return h.with(handlerOp{group: name})
+}
+
+// with returns a copy of h with op appended
+func (h *handler) with(op handlerOp) *handler {
+	ops := make([]handlerOp, len(h.ops), len(h.ops)+1)
+	copy(ops, h.ops)
+	return &handler{ops: append(ops, op)}
+}
@@ -0,0 +1,59 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestComponentLoggerUsesSetup(t *testing.T) {
+	// Created before Setup, like a package variable
+	log := Component("scraper")
+
+	var buf bytes.Buffer
+	if err := Setup("debug", FormatJSON, &buf); err != nil {
+		t.Fatalf("Failed to set up logging: %v", err)
+	}
+
+	ctx := WithRequestID(WithListingID(context.Background(), "intimcity.gold:123"), "abc")
+	log.DebugContext(ctx, "Scraped listing", "photos", 3)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Expected a JSON record, got %q: %v", buf.String(), err)
+	}
+
+	expected := map[string]any{
+		"msg":        "Scraped listing",
+		"level":      "DEBUG",
+		"component":  "scraper",
+		"listing_id": "intimcity.gold:123",
+		"request_id": "abc",
+		"photos":     float64(3),
+	}
+	for key, value := range expected {
+		if record[key] != value {
+			t.Errorf("Expected %s=%v, got %v", key, value, record[key])
+		}
+	}
+}
+
+func TestSetupLevel(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Setup("warn", FormatText, &buf); err != nil {
+		t.Fatalf("Failed to set up logging: %v", err)
+	}
+
+	Component("clickhouse").Info("hidden")
+	if buf.Len() != 0 {
+		t.Errorf("Expected info records to be dropped at warn level, got %q", buf.String())
+	}
+
+	if err := Setup("loud", FormatText, &buf); err == nil {
+		t.Errorf("Expected an error for an unknown level")
+	}
+	if err := Setup("info", "xml", &buf); err == nil {
+		t.Errorf("Expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,553 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Pipeline stages measured from the moment a listing is discovered on an index page
+const (
+	StageScraped = "scraped"
+	StageStored  = "stored"
+)
+
+// latencyBuckets cover a few seconds up to several hours, so P50/P95/P99 of the
+// discovery to stored latency can be computed with histogram_quantile
+var latencyBuckets = []float64{1, 2, 5, 10, 30, 60, 120, 300, 600, 900, 1800, 3600, 7200, 14400}
+
+var (
+	// Registry holds every hoe_parser metric
+	Registry = prometheus.NewRegistry()
+
+	// ListingLatency is the time from discovery to reaching each pipeline stage
+	ListingLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "hoe_parser",
+		Name:      "listing_latency_seconds",
+		Help:      "Time from discovering a listing link to the listing reaching a pipeline stage.",
+		Buckets:   latencyBuckets,
+	}, []string{"stage"})
+
+	// ListingsScraped counts listing page scrapes by result (ok or failed)
+	ListingsScraped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "listings_scraped_total",
+		Help:      "Listing page scrapes by result (ok or failed).",
+	}, []string{"result"})
+
+	// RowsInserted counts rows written to storage by result (ok or failed)
+	RowsInserted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "rows_inserted_total",
+		Help:      "Rows written to storage by result (ok or failed).",
+	}, []string{"result"})
+
+	// FreshnessSLOBreaches counts listings stored later than the freshness SLO allows
+	FreshnessSLOBreaches = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "freshness_slo_breaches_total",
+		Help:      "Listings whose discovery to stored latency exceeded the freshness SLO.",
+	})
+
+	// FieldsParsed counts scraped listings by key field and whether the parser found it
+	FieldsParsed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "fields_parsed_total",
+		Help:      "Scraped listings by key field and whether the field was populated.",
+	}, []string{"field", "populated"})
+
+	// QualityFlags counts scraped listings by the quality flags validation gave them
+	QualityFlags = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "listing_quality_flags_total",
+		Help:      "Scraped listings by quality flag.",
+	}, []string{"flag"})
+
+	// QualityScore is the distribution of the quality scores of scraped listings
+	QualityScore = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "hoe_parser",
+		Name:      "listing_quality_score",
+		Help:      "Quality score of scraped listings.",
+		Buckets:   []float64{0.2, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 1},
+	})
+
+	// ListingIDMax is the highest numeric listing ID seen per site
+	ListingIDMax = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "hoe_parser",
+		Name:      "listing_id_max",
+		Help:      "Highest numeric listing ID seen on the site.",
+	}, []string{"site"})
+
+	// ListingIDGapRatio is the share of IDs below the highest one never seen, per site
+	ListingIDGapRatio = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "hoe_parser",
+		Name:      "listing_id_gap_ratio",
+		Help:      "Share of the IDs within ID_GAP_SPAN below the highest listing ID that were never seen.",
+	}, []string{"site"})
+
+	// FieldCoverage is the share of listings scraped within the alert window that have a field populated
+	FieldCoverage = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "hoe_parser",
+		Name:      "field_coverage_ratio",
+		Help:      "Share of listings scraped within the coverage alert window with the field populated.",
+	}, []string{"field"})
+
+	// ProxyGeoProxies counts configured and active proxies per country
+	ProxyGeoProxies = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "hoe_parser",
+		Name:      "proxy_geo_proxies",
+		Help:      "Proxies per country, by state (total or active).",
+	}, []string{"geo", "state"})
+
+	// ProxyGeoFailureRatio is the share of failed requests through the proxies of each country
+	ProxyGeoFailureRatio = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "hoe_parser",
+		Name:      "proxy_geo_failure_ratio",
+		Help:      "Share of failed requests through the proxies of a country.",
+	}, []string{"geo"})
+
+	// ProxyBurns counts (proxy, site) pairs taken out of rotation after a block response
+	ProxyBurns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "proxy_burns_total",
+		Help:      "Block responses that burned a proxy for a site, by site and status code.",
+	}, []string{"site", "status"})
+
+	// ProxyQuarantines counts proxies taken out of rotation after consecutive failures
+	ProxyQuarantines = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "proxy_quarantines_total",
+		Help:      "Times a proxy was quarantined after consecutive failed requests.",
+	})
+
+	// PageRetries counts pages fetched again after a block or overload status
+	PageRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "page_retries_total",
+		Help:      "Page fetches retried by the fetch retry policy, by the status code that caused the retry.",
+	}, []string{"status"})
+
+	// PageFetchesShared counts page requests served by joining a fetch of the same page in flight
+	PageFetchesShared = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "page_fetches_shared_total",
+		Help:      "Page requests that joined a concurrent fetch of the same page instead of fetching it again.",
+	})
+
+	// RetryBudgetTrips counts how often the exhausted retry budget paused all requests
+	RetryBudgetTrips = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "retry_budget_trips_total",
+		Help:      "Times the retry budget was exhausted and all outgoing requests were paused.",
+	})
+
+	// InsertBufferRows is the number of rows waiting in the insert buffer
+	InsertBufferRows = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "hoe_parser",
+		Name:      "insert_buffer_rows",
+		Help:      "Rows waiting in the insert buffer, including a flush in progress.",
+	})
+
+	// InsertBufferFlushedRows counts rows written to storage by insert buffer flushes
+	InsertBufferFlushedRows = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "insert_buffer_flushed_rows_total",
+		Help:      "Rows written to storage by insert buffer flushes.",
+	})
+
+	// InsertBufferDroppedRows counts rows the insert buffer gave up on, by reason (full or flush_failed)
+	InsertBufferDroppedRows = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "insert_buffer_dropped_rows_total",
+		Help:      "Rows dropped by the insert buffer, by reason (full or flush_failed).",
+	}, []string{"reason"})
+
+	// APIPanics counts API requests whose handler panicked
+	APIPanics = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "api_panics_total",
+		Help:      "API requests whose handler panicked and were answered with 500.",
+	})
+
+	// APIRateLimited counts API requests refused because their client was over the rate limit
+	APIRateLimited = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "api_rate_limited_total",
+		Help:      "API requests refused with 429 because their client was over the rate limit.",
+	})
+
+	// PagesFetched counts page fetches by kind (index or listing) and result (ok or failed)
+	PagesFetched = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "pages_fetched_total",
+		Help:      "Page fetches by kind (index or listing) and result (ok or failed).",
+	}, []string{"kind", "result"})
+
+	// IndexPageFingerprints counts index page fingerprint checks by result (changed, unchanged or error)
+	IndexPageFingerprints = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "index_page_fingerprints_total",
+		Help:      "Index page fingerprint checks by result (changed, unchanged or error); unchanged pages skip link extraction.",
+	}, []string{"result"})
+
+	// PagesArchived counts listing page snapshots by result (ok or failed)
+	PagesArchived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "pages_archived_total",
+		Help:      "Raw HTML snapshots of listing pages stored for re-parsing, by result (ok or failed).",
+	}, []string{"result"})
+
+	// ExtractionReloads counts reloads of the extraction config file after it changed
+	ExtractionReloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "extraction_config_reloads_total",
+		Help:      "Reloads of the extraction config file after a change, by result (ok or failed).",
+	}, []string{"result"})
+
+	// BlockPages counts pages served with a 200 that were block pages
+	BlockPages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "block_pages_total",
+		Help:      "Pages served with a 200 that were block pages instead of content, by site and reason (challenge, captcha or small_body).",
+	}, []string{"site", "reason"})
+
+	// SitePauses counts sites paused by the site guard
+	SitePauses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "site_pauses_total",
+		Help:      "Sites paused for a cool-down after blocking every proxy or tripping the block rate breaker, by site.",
+	}, []string{"site"})
+
+	// BrowserFetches counts pages rendered in the headless browser fallback
+	BrowserFetches = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "browser_fetches_total",
+		Help:      "Pages rendered in the headless browser after the plain fetch, by reason (empty, interstitial or blocked) and result (ok or failed).",
+	}, []string{"reason", "result"})
+
+	// ParseErrors counts responses that could not be decoded or parsed, by stage
+	ParseErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "parse_errors_total",
+		Help:      "Responses that could not be decoded or parsed, by stage (gzip, encoding, html or json).",
+	}, []string{"stage"})
+
+	// ProxyAttempts counts requests sent through a proxy by result (ok, error or blocked)
+	ProxyAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "proxy_attempts_total",
+		Help:      "Requests sent through a proxy by result (ok, error or blocked).",
+	}, []string{"result"})
+
+	// ClickHouseDuration is the duration of ClickHouse operations by kind (insert, query or analytics)
+	ClickHouseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "hoe_parser",
+		Name:      "clickhouse_operation_duration_seconds",
+		Help:      "Duration of ClickHouse operations by kind (insert, query or analytics).",
+		Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"operation"})
+
+	// SinkWrites counts listings written to the storage sinks by sink and result
+	SinkWrites = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "sink_writes_total",
+		Help:      "Listings written to a storage sink by sink and result.",
+	}, []string{"sink", "result"})
+
+	// PhotosHashed counts listing photos processed for duplicate detection by result
+	PhotosHashed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "photos_hashed_total",
+		Help:      "Listing photos downloaded and hashed for duplicate detection by result.",
+	}, []string{"result"})
+
+	// PhotoQueueJobs counts the jobs of the persistent photo queue by result
+	PhotoQueueJobs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "photo_queue_jobs_total",
+		Help:      "Jobs of the persistent photo download queue by result (queued, done, retried, dropped).",
+	}, []string{"result"})
+
+	// ScheduledRuns counts the runs of scheduled jobs by job and result
+	ScheduledRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "scheduled_runs_total",
+		Help:      "Runs of scheduled jobs by job and result (ok, failed, cancelled).",
+	}, []string{"job", "result"})
+
+	// ListingsRemoved counts listings soft-deleted because their page no longer exists, by site
+	ListingsRemoved = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "listings_removed_total",
+		Help:      "Listings marked removed because their page returned 404 or 410, by site.",
+	}, []string{"site"})
+
+	// StaleListingsQueued counts stale listings queued to be scraped again, by site
+	StaleListingsQueued = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "stale_listings_queued_total",
+		Help:      "Listings not scraped within the staleness threshold queued to be scraped again, by site.",
+	}, []string{"site"})
+
+	// SinkDuration is the duration of storage sink writes by sink
+	SinkDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "hoe_parser",
+		Name:      "sink_write_duration_seconds",
+		Help:      "Duration of storage sink writes by sink.",
+		Buckets:   []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"sink"})
+
+	// EventsDropped counts pipeline events dropped because a subscriber fell behind
+	EventsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "events_dropped_total",
+		Help:      "Pipeline events dropped because the subscriber's queue was full, by subscriber.",
+	}, []string{"subscriber"})
+
+	// StreamClients is the number of clients connected to the API event stream
+	StreamClients = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "hoe_parser",
+		Name:      "stream_clients",
+		Help:      "Clients connected to the server-sent event stream of the API.",
+	})
+
+	// StreamEventsDropped counts events dropped from the buffers of slow stream clients
+	StreamEventsDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "stream_events_dropped_total",
+		Help:      "Events dropped from the buffer of a stream client that fell behind.",
+	})
+
+	// StreamDisconnects counts ended stream connections by reason
+	StreamDisconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "stream_disconnects_total",
+		Help:      "Ended event stream connections by reason (client_closed, write_failed, overflow, slow).",
+	}, []string{"reason"})
+
+	// ScrapeWorkers is the current size of the autoscaled scrape worker pool
+	ScrapeWorkers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "hoe_parser",
+		Name:      "scrape_workers",
+		Help:      "Current number of scrape workers.",
+	})
+
+	// ScrapeWorkerScaling counts pool size changes by direction and reason
+	ScrapeWorkerScaling = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hoe_parser",
+		Name:      "scrape_worker_scaling_events_total",
+		Help:      "Scrape worker pool size changes by direction (up or down) and reason.",
+	}, []string{"direction", "reason"})
+)
+
+func init() {
+	Registry.MustRegister(ListingLatency, ListingsScraped, RowsInserted, FreshnessSLOBreaches,
+		FieldsParsed, FieldCoverage, QualityFlags, QualityScore, ListingIDMax, ListingIDGapRatio, ProxyGeoProxies, ProxyGeoFailureRatio, ProxyBurns, ProxyQuarantines, PageRetries, PageFetchesShared, RetryBudgetTrips,
+		InsertBufferRows, InsertBufferFlushedRows, InsertBufferDroppedRows, EventsDropped,
+		StreamClients, StreamEventsDropped, StreamDisconnects, APIPanics, APIRateLimited,
+		PagesFetched, IndexPageFingerprints, PagesArchived, ExtractionReloads, BlockPages, SitePauses, BrowserFetches, ParseErrors, ProxyAttempts, ClickHouseDuration, SinkWrites, SinkDuration, PhotosHashed, PhotoQueueJobs, ScheduledRuns,
+		ListingsRemoved, StaleListingsQueued,
+		ScrapeWorkers, ScrapeWorkerScaling, queues)
+}
+
+// queues exports the registered queue lengths whenever the registry is scraped
+var queues = &queueCollector{
+	depth: prometheus.NewDesc("hoe_parser_queue_depth", "Items waiting in a pipeline queue.",
+		[]string{"queue"}, nil),
+	capacity: prometheus.NewDesc("hoe_parser_queue_capacity", "Capacity of a pipeline queue.",
+		[]string{"queue"}, nil),
+	lengths: make(map[string]func() (int, int)),
+}
+
+// queueCollector reports the depth and capacity of every registered queue at scrape time
+type queueCollector struct {
+	depth    *prometheus.Desc
+	capacity *prometheus.Desc
+
+	mutex   sync.Mutex
+	lengths map[string]func() (int, int)
+}
+
+// Describe implements prometheus.Collector
+func (c *queueCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- c.depth
+	descs <- c.capacity
+}
+
+// Collect implements prometheus.Collector
+func (c *queueCollector) Collect(metrics chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for name, length := range c.lengths {
+		depth, capacity := length()
+		metrics <- prometheus.MustNewConstMetric(c.depth, prometheus.GaugeValue, float64(depth), name)
+		metrics <- prometheus.MustNewConstMetric(c.capacity, prometheus.GaugeValue, float64(capacity), name)
+	}
+}
+
+// RegisterQueue exports the depth and capacity of a queue, read by length on every scrape
+func RegisterQueue(name string, length func() (depth, capacity int)) {
+	queues.mutex.Lock()
+	defer queues.mutex.Unlock()
+	queues.lengths[name] = length
+}
+
+// ObserveListingLatency records the latency of a listing reaching a stage
+func ObserveListingLatency(stage string, latency time.Duration) {
+	ListingLatency.WithLabelValues(stage).Observe(latency.Seconds())
+}
+
+// ObserveStored records the discovery to stored latency and counts SLO breaches (slo 0 disables the check)
+func ObserveStored(latency, slo time.Duration) {
+	ObserveListingLatency(StageStored, latency)
+	if slo > 0 && latency > slo {
+		FreshnessSLOBreaches.Inc()
+	}
+}
+
+// ObserveScrape counts a listing page scrape by its outcome
+func ObserveScrape(err error) {
+	ListingsScraped.WithLabelValues(result(err)).Inc()
+}
+
+// ObserveInsert counts count rows written to storage by the outcome of the write
+func ObserveInsert(count int, err error) {
+	RowsInserted.WithLabelValues(result(err)).Add(float64(count))
+}
+
+// ObservePage counts a page fetch of kind (index or listing) by its outcome
+func ObservePage(kind string, err error) {
+	PagesFetched.WithLabelValues(kind, result(err)).Inc()
+}
+
+// ObservePageArchived counts a listing page snapshot by its result
+func ObservePageArchived(err error) {
+	PagesArchived.WithLabelValues(result(err)).Inc()
+}
+
+// ObserveExtractionReload counts a reload of the extraction config file by its result
+func ObserveExtractionReload(err error) {
+	ExtractionReloads.WithLabelValues(result(err)).Inc()
+}
+
+// ObserveBlockPage counts a block page of site by the reason it was detected
+func ObserveBlockPage(site, reason string) {
+	BlockPages.WithLabelValues(site, reason).Inc()
+}
+
+// ObserveBrowserFetch counts a page rendered in the browser fallback for reason by its result
+func ObserveBrowserFetch(reason string, err error) {
+	BrowserFetches.WithLabelValues(reason, result(err)).Inc()
+}
+
+// ObserveParseError counts a response that failed to decode or parse at stage
+func ObserveParseError(stage string) {
+	ParseErrors.WithLabelValues(stage).Inc()
+}
+
+// ObservePageRetry counts a page fetched again after a response with statusCode
+func ObservePageRetry(statusCode int) {
+	PageRetries.WithLabelValues(strconv.Itoa(statusCode)).Inc()
+}
+
+// ObserveProxyAttempt counts a request sent through a proxy by its result (ok, error or blocked)
+func ObserveProxyAttempt(result string) {
+	ProxyAttempts.WithLabelValues(result).Inc()
+}
+
+// ObserveClickHouse records the duration of a ClickHouse operation
+func ObserveClickHouse(operation string, duration time.Duration) {
+	ClickHouseDuration.WithLabelValues(operation).Observe(duration.Seconds())
+}
+
+// ObserveSinkWrite records a listing write to a storage sink by its outcome and duration
+func ObserveSinkWrite(sink string, duration time.Duration, err error) {
+	SinkWrites.WithLabelValues(sink, result(err)).Inc()
+	SinkDuration.WithLabelValues(sink).Observe(duration.Seconds())
+}
+
+// ObserveBufferFlush records a flush of the insert buffer: its rows are flushed, or dropped when err is set
+func ObserveBufferFlush(rows, buffered int, err error) {
+	if err != nil {
+		InsertBufferDroppedRows.WithLabelValues("flush_failed").Add(float64(rows))
+	} else {
+		InsertBufferFlushedRows.Add(float64(rows))
+	}
+	InsertBufferRows.Set(float64(buffered))
+}
+
+// result returns the result label of an outcome
+func result(err error) string {
+	if err != nil {
+		return "failed"
+	}
+	return "ok"
+}
+
+// ObserveFields counts which key fields of a scraped listing were populated
+func ObserveFields(fields map[string]bool) {
+	for field, populated := range fields {
+		FieldsParsed.WithLabelValues(field, strconv.FormatBool(populated)).Inc()
+	}
+}
+
+// ObserveQuality records the quality score and flags of a scraped listing
+func ObserveQuality(score float32, flags []string) {
+	QualityScore.Observe(float64(score))
+	for _, flag := range flags {
+		QualityFlags.WithLabelValues(flag).Inc()
+	}
+}
+
+// SetProxyGeoHealth exports the proxy health of one country
+func SetProxyGeoHealth(geo string, total, active int, failureRatio float64) {
+	ProxyGeoProxies.WithLabelValues(geo, "total").Set(float64(total))
+	ProxyGeoProxies.WithLabelValues(geo, "active").Set(float64(active))
+	ProxyGeoFailureRatio.WithLabelValues(geo).Set(failureRatio)
+}
+
+// ObserveWorkerScaling records a change of the scrape worker pool size from one size to another
+func ObserveWorkerScaling(from, to int, reason string) {
+	ScrapeWorkers.Set(float64(to))
+	switch {
+	case to > from:
+		ScrapeWorkerScaling.WithLabelValues("up", reason).Inc()
+	case to < from:
+		ScrapeWorkerScaling.WithLabelValues("down", reason).Inc()
+	}
+}
+
+// Handler returns the Prometheus scrape handler for the registry
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Serve exposes /metrics on addr and blocks until ctx is cancelled
+func Serve(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("metrics server failed: %w", err)
+	}
+	return nil
+}
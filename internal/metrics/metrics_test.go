@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObserveStoredCountsSLOBreaches(t *testing.T) {
+	before := testutil.ToFloat64(FreshnessSLOBreaches)
+
+	ObserveStored(5*time.Minute, 10*time.Minute)
+	ObserveStored(20*time.Minute, 10*time.Minute)
+	ObserveStored(20*time.Minute, 0)
+
+	if got := testutil.ToFloat64(FreshnessSLOBreaches) - before; got != 1 {
+		t.Errorf("Expected 1 SLO breach, got %v", got)
+	}
+
+	if count := testutil.CollectAndCount(ListingLatency, "hoe_parser_listing_latency_seconds"); count != 1 {
+		t.Errorf("Expected one stored latency series, got %d", count)
+	}
+}
+
+func TestRegisterQueueExportsDepth(t *testing.T) {
+	RegisterQueue("test", func() (int, int) { return 3, 10 })
+
+	expected := `
+# HELP hoe_parser_queue_depth Items waiting in a pipeline queue.
+# TYPE hoe_parser_queue_depth gauge
+hoe_parser_queue_depth{queue="test"} 3
+`
+	if err := testutil.GatherAndCompare(Registry, strings.NewReader(expected), "hoe_parser_queue_depth"); err != nil {
+		t.Errorf("Expected queue depth 3: %v", err)
+	}
+}
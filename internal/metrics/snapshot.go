@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// CounterSample is the value of one counter series, persisted across restarts
+type CounterSample struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+}
+
+// restorableCounters are the counters carried over restarts, by fully qualified name. Gauges and
+// histograms describe the running process and start from scratch.
+var restorableCounters = map[string]prometheus.Collector{
+	"hoe_parser_listings_scraped_total":             ListingsScraped,
+	"hoe_parser_rows_inserted_total":                RowsInserted,
+	"hoe_parser_freshness_slo_breaches_total":       FreshnessSLOBreaches,
+	"hoe_parser_fields_parsed_total":                FieldsParsed,
+	"hoe_parser_proxy_burns_total":                  ProxyBurns,
+	"hoe_parser_proxy_quarantines_total":            ProxyQuarantines,
+	"hoe_parser_page_retries_total":                 PageRetries,
+	"hoe_parser_page_fetches_shared_total":          PageFetchesShared,
+	"hoe_parser_photos_hashed_total":                PhotosHashed,
+	"hoe_parser_photo_queue_jobs_total":             PhotoQueueJobs,
+	"hoe_parser_scheduled_runs_total":               ScheduledRuns,
+	"hoe_parser_listings_removed_total":             ListingsRemoved,
+	"hoe_parser_stale_listings_queued_total":        StaleListingsQueued,
+	"hoe_parser_retry_budget_trips_total":           RetryBudgetTrips,
+	"hoe_parser_insert_buffer_flushed_rows_total":   InsertBufferFlushedRows,
+	"hoe_parser_insert_buffer_dropped_rows_total":   InsertBufferDroppedRows,
+	"hoe_parser_scrape_worker_scaling_events_total": ScrapeWorkerScaling,
+	"hoe_parser_pages_fetched_total":                PagesFetched,
+	"hoe_parser_parse_errors_total":                 ParseErrors,
+	"hoe_parser_proxy_attempts_total":               ProxyAttempts,
+	"hoe_parser_sink_writes_total":                  SinkWrites,
+}
+
+// CaptureCounters returns the current value of every restorable counter series
+func CaptureCounters() ([]CounterSample, error) {
+	families, err := Registry.Gather()
+	if err != nil {
+		return nil, fmt.Errorf("failed to gather metrics: %w", err)
+	}
+
+	var samples []CounterSample
+	for _, family := range families {
+		if family.GetType() != dto.MetricType_COUNTER || restorableCounters[family.GetName()] == nil {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			sample := CounterSample{Name: family.GetName(), Value: metric.GetCounter().GetValue()}
+			if pairs := metric.GetLabel(); len(pairs) > 0 {
+				sample.Labels = make(map[string]string, len(pairs))
+				for _, pair := range pairs {
+					sample.Labels[pair.GetName()] = pair.GetValue()
+				}
+			}
+			samples = append(samples, sample)
+		}
+	}
+	return samples, nil
+}
+
+// RestoreCounters adds persisted values to the counters. Counters only ever go up, so it must be
+// called once at startup, before the pipeline increments anything. A value restored below the last
+// scrape (after a crash) looks like any other counter reset to Prometheus. Unknown series are skipped.
+func RestoreCounters(samples []CounterSample) error {
+	for _, sample := range samples {
+		if sample.Value <= 0 {
+			continue
+		}
+
+		switch counter := restorableCounters[sample.Name].(type) {
+		case prometheus.Counter:
+			counter.Add(sample.Value)
+		case *prometheus.CounterVec:
+			series, err := counter.GetMetricWith(sample.Labels)
+			if err != nil {
+				return fmt.Errorf("failed to restore counter %s: %w", sample.Name, err)
+			}
+			series.Add(sample.Value)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package request_client
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrProxiesBurned is matched by errors returned when every usable proxy is burned for a site
+var ErrProxiesBurned = errors.New("all proxies burned for site")
+
+// ProxiesBurnedError is returned instead of reusing a proxy the site has just blocked
+type ProxiesBurnedError struct {
+	Site    string
+	RetryAt time.Time // when the first burn expires
+}
+
+// Error implements the error interface
+func (e *ProxiesBurnedError) Error() string {
+	return fmt.Sprintf("all proxies burned for %s until %s", e.Site, e.RetryAt.Format(time.RFC3339))
+}
+
+// Is makes errors.Is(err, ErrProxiesBurned) match
+func (e *ProxiesBurnedError) Is(target error) bool {
+	return target == ErrProxiesBurned
+}
+
+// BurnEvent records a (proxy, site) pair taken out of rotation after a block response
+type BurnEvent struct {
+	Proxy      string    `json:"proxy"`
+	Site       string    `json:"site"`
+	StatusCode int       `json:"status_code"`
+	UserAgent  string    `json:"user_agent"` // user agent of the burned session
+	At         time.Time `json:"at"`
+	Until      time.Time `json:"until"`
+}
+
+// pairKey identifies a (proxy, site) session
+type pairKey struct {
+	proxy string
+	site  string
+}
+
+// session is the identity a proxy presents to a site
+type session struct {
+	userAgent int  // index into the user agent pool
+	language  int  // index into acceptLanguages, -1 keeps the default headers
+	dnt       bool // whether the randomized headers send DNT
+}
+
+// SetBurnCooldown sets how long a (proxy, site) pair is skipped after a block response; 0 disables burning
+func (pc *ProxyClient) SetBurnCooldown(cooldown time.Duration) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	pc.burnCooldown = cooldown
+}
+
+// SetBurnHandler sets a callback receiving every burn event
+func (pc *ProxyClient) SetBurnHandler(handler func(BurnEvent)) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	pc.onBurn = handler
+}
+
+// sessionFor returns the session of a pair, starting a new one if needed.
+// Must be called with the mutex held.
+func (pc *ProxyClient) sessionFor(key pairKey) *session {
+	s, exists := pc.sessions[key]
+	if !exists {
+		s = pc.newSession()
+		pc.sessions[key] = s
+	}
+	return s
+}
+
+// rotateSession replaces the session of a pair with a new one that never reuses its user agent,
+// unless the pool has only one. Must be called with the mutex held.
+func (pc *ProxyClient) rotateSession(key pairKey) {
+	burned := pc.sessionFor(key).userAgent
+	s := pc.newSession()
+	for s.userAgent == burned && len(pc.userAgents) > 1 {
+		s = pc.newSession()
+	}
+	pc.sessions[key] = s
+}
+
+// burnedUntil returns when the burn of a pair expires, or the zero time when it is usable.
+// Must be called with the mutex held.
+func (pc *ProxyClient) burnedUntil(key pairKey, now time.Time) time.Time {
+	until, exists := pc.burned[key]
+	if !exists {
+		return time.Time{}
+	}
+	if !now.Before(until) {
+		delete(pc.burned, key)
+		return time.Time{}
+	}
+	return until
+}
+
+// withoutBurned removes proxies burned for site from order. When every proxy in order is burned
+// it returns a ProxiesBurnedError.
+func (pc *ProxyClient) withoutBurned(order []int, site string) ([]int, error) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	if pc.burnCooldown <= 0 || len(order) == 0 {
+		return order, nil
+	}
+
+	now := time.Now()
+	var retryAt time.Time
+	usable := make([]int, 0, len(order))
+	for _, idx := range order {
+		until := pc.burnedUntil(pairKey{pc.proxies[idx], site}, now)
+		if until.IsZero() {
+			usable = append(usable, idx)
+			continue
+		}
+		if retryAt.IsZero() || until.Before(retryAt) {
+			retryAt = until
+		}
+	}
+
+	if len(usable) == 0 {
+		return nil, &ProxiesBurnedError{Site: site, RetryAt: retryAt}
+	}
+	return usable, nil
+}
+
+// burn takes a (proxy, site) pair out of rotation after a block response and rotates its session,
+// so the pair comes back with a fresh user agent. It reports whether the pair was burned.
+func (pc *ProxyClient) burn(proxy, site string, statusCode int) bool {
+	if proxy == "" || !blockStatusCodes[statusCode] {
+		return false
+	}
+
+	pc.mutex.Lock()
+	if pc.burnCooldown <= 0 {
+		pc.mutex.Unlock()
+		return false
+	}
+
+	key := pairKey{proxy, site}
+	now := time.Now()
+	event := BurnEvent{
+		Proxy:      proxy,
+		Site:       site,
+		StatusCode: statusCode,
+		UserAgent:  pc.userAgents[pc.sessionFor(key).userAgent],
+		At:         now,
+		Until:      now.Add(pc.burnCooldown),
+	}
+
+	pc.burned[key] = event.Until
+	pc.rotateSession(key)
+	pc.stateFor(proxy).burns++
+	handler := pc.onBurn
+	pc.mutex.Unlock()
+
+	if handler != nil {
+		handler(event)
+	}
+	return true
+}
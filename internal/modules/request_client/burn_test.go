@@ -0,0 +1,91 @@
+package request_client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// proxyServer is a fake forward proxy answering every request with status and recording user agents
+func proxyServer(t *testing.T, status int) (*httptest.Server, *[]string) {
+	var mu sync.Mutex
+	var agents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		agents = append(agents, r.Header.Get("User-Agent"))
+		mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &agents
+}
+
+func TestBlockedProxyIsBurnedAndSkipped(t *testing.T) {
+	blocking, blockedAgents := proxyServer(t, http.StatusForbidden)
+	healthy, _ := proxyServer(t, http.StatusOK)
+
+	client := NewProxyClient([]string{blocking.URL, healthy.URL}, 5*time.Second)
+	client.SetMaxRetries(1)
+	client.SetBurnCooldown(time.Minute)
+
+	var events []BurnEvent
+	client.SetBurnHandler(func(event BurnEvent) { events = append(events, event) })
+
+	resp, err := client.Get("http://listings.example/anketa1.htm")
+	if err != nil {
+		t.Fatalf("Expected the healthy proxy to answer, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 through the healthy proxy, got %d", resp.StatusCode)
+	}
+
+	if len(events) != 1 || events[0].Proxy != blocking.URL || events[0].Site != "listings.example" || events[0].StatusCode != http.StatusForbidden {
+		t.Fatalf("Expected one burn event for the blocking proxy, got %+v", events)
+	}
+	if events[0].UserAgent != (*blockedAgents)[0] {
+		t.Errorf("Expected the burn event to name the burned user agent")
+	}
+
+	// The burned pair is skipped for the site but still used for other sites
+	resp, err = client.Get("http://listings.example/anketa2.htm")
+	if err != nil {
+		t.Fatalf("Expected a second request to succeed, got %v", err)
+	}
+	resp.Body.Close()
+	if len(*blockedAgents) != 1 {
+		t.Errorf("Expected the burned proxy to be skipped, it got %d requests", len(*blockedAgents))
+	}
+
+	client.Get("http://other.example/")
+	if len(*blockedAgents) != 2 {
+		t.Fatalf("Expected the proxy to stay usable for other sites")
+	}
+
+	if stats := client.GetProxyStats(); stats[0].Burns != 2 {
+		t.Errorf("Expected 2 burns recorded for the proxy, got %d", stats[0].Burns)
+	}
+}
+
+func TestBurnRotatesUserAgent(t *testing.T) {
+	client := NewProxyClient([]string{"http://proxy1:8080"}, 5*time.Second)
+	client.SetBurnCooldown(time.Minute)
+
+	before, _ := client.identityFor("http://proxy1:8080", "listings.example")
+	if !client.burn("http://proxy1:8080", "listings.example", http.StatusTooManyRequests) {
+		t.Fatalf("Expected a 429 to burn the pair")
+	}
+	if after, _ := client.identityFor("http://proxy1:8080", "listings.example"); after == before {
+		t.Errorf("Expected a fresh user agent after the burn, still %s", after)
+	}
+
+	if _, err := client.withoutBurned([]int{0}, "listings.example"); !errors.Is(err, ErrProxiesBurned) {
+		t.Errorf("Expected ErrProxiesBurned with every proxy burned, got %v", err)
+	}
+	if client.burn("http://proxy1:8080", "listings.example", http.StatusNotFound) {
+		t.Errorf("Expected a 404 not to burn the pair")
+	}
+}
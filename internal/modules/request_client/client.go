@@ -1,16 +1,26 @@
 package request_client
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/logger"
+	"github.com/gregor-tokarev/hoe_parser/internal/metrics"
+	"golang.org/x/time/rate"
 )
 
-// ProxyClient represents an HTTP client with round-robin proxy support
+// log is the component logger of the package
+var log = logger.Component("request_client")
+
+// ProxyClient represents an HTTP client with pluggable proxy selection (round-robin by default)
 type ProxyClient struct {
 	proxies    []string
 	currentIdx int
@@ -18,6 +28,48 @@ type ProxyClient struct {
 	timeout    time.Duration
 	maxRetries int
 	fallbackOK bool // whether to allow requests without proxy if all proxies fail
+	strategy   Strategy
+	weights    []int
+	stats      map[string]*proxyState
+	guard      *SiteGuard
+	geos       []string // per-proxy country codes, matched by position
+	geoRules   []GeoRule
+
+	headerProfiles []HeaderProfile // per-site headers, see headers.go
+	retryBudget    *RetryBudget    // caps the share of retries, see retry_budget.go
+
+	// Per-host token buckets, see rate_limit.go
+	rateLimit RateLimit
+	hostRates map[string]float64
+	limiters  map[string]*rate.Limiter
+
+	// Burned (proxy, site) pairs and the per-pair sessions they rotate, see burn.go
+	burnCooldown  time.Duration
+	burned        map[pairKey]time.Time
+	sessions      map[pairKey]*session
+	nextUserAgent int
+	onBurn        func(BurnEvent)
+
+	// Quarantine of proxies failing repeatedly, see health.go
+	health       HealthPolicy
+	onQuarantine func(QuarantineEvent)
+
+	// User agent pool and header fingerprints of the sessions, see useragent.go
+	userAgents       []string
+	userAgentMode    UserAgentMode
+	randomizeHeaders bool
+
+	// transport replaces the proxies when set, see SetTransport
+	transport http.RoundTripper
+
+	// Sticky sessions by the key of the request context, see sticky.go
+	stickyTTL      time.Duration
+	stickySessions map[string]*StickySession
+	stickyPruned   time.Time
+
+	// Cookie jars of the requests outside sticky sessions by proxy, see cookies.go
+	cookieJars bool
+	proxyJars  map[string]*CookieJar
 }
 
 // NewProxyClient creates a new proxy client with round-robin selection
@@ -27,11 +79,17 @@ func NewProxyClient(proxies []string, timeout time.Duration) *ProxyClient {
 	}
 
 	return &ProxyClient{
-		proxies:    proxies,
-		currentIdx: 0,
-		timeout:    timeout,
-		maxRetries: 3,
-		fallbackOK: false, // Allow fallback to no proxy if all proxies fail
+		proxies:       proxies,
+		currentIdx:    0,
+		timeout:       timeout,
+		maxRetries:    3,
+		fallbackOK:    false, // Allow fallback to no proxy if all proxies fail
+		strategy:      StrategyRoundRobin,
+		stats:         make(map[string]*proxyState),
+		burned:        make(map[pairKey]time.Time),
+		sessions:      make(map[pairKey]*session),
+		userAgents:    DefaultUserAgents,
+		userAgentMode: UserAgentSticky,
 	}
 }
 
@@ -45,6 +103,33 @@ func (pc *ProxyClient) SetFallbackAllowed(allowed bool) {
 	pc.fallbackOK = allowed
 }
 
+// SetSiteGuard enables pausing of sites that block every proxy
+func (pc *ProxyClient) SetSiteGuard(guard *SiteGuard) {
+	pc.guard = guard
+}
+
+// SiteGuard returns the configured site guard, or nil
+func (pc *ProxyClient) SiteGuard() *SiteGuard {
+	return pc.guard
+}
+
+// SetTransport sends every request through transport instead of the proxies, for deployments
+// whose traffic leaves through an egress service. Retries, the retry budget, rate limits,
+// headers, the site guard and metrics still apply; proxy selection, geo routing, burns and
+// quarantine do not. Call it before the client is used; nil restores the proxies.
+func (pc *ProxyClient) SetTransport(transport http.RoundTripper) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	pc.transport = transport
+}
+
+// customTransport returns the transport set with SetTransport, or nil
+func (pc *ProxyClient) customTransport() http.RoundTripper {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	return pc.transport
+}
+
 // getNextProxy returns the next proxy in round-robin fashion
 func (pc *ProxyClient) getNextProxy() string {
 	pc.mutex.Lock()
@@ -73,98 +158,257 @@ func (pc *ProxyClient) getNextProxyIndex() int {
 	return idx
 }
 
-// createClient creates an HTTP client with the specified proxy
-func (pc *ProxyClient) createClient(proxyURL string) (*http.Client, error) {
+// createClient creates an HTTP client with the specified proxy, keeping cookies in jar (nil for none)
+func (pc *ProxyClient) createClient(proxyURL string, jar http.CookieJar) (*http.Client, error) {
+	if transport := pc.customTransport(); transport != nil {
+		return &http.Client{
+			Transport: transport,
+			Jar:       jar,
+			Timeout:   pc.timeout,
+		}, nil
+	}
+
 	if proxyURL == "" {
 		// No proxy
 		return &http.Client{
+			Jar:     jar,
 			Timeout: pc.timeout,
 		}, nil
 	}
 
-	proxyParsed, err := url.Parse(proxyURL)
+	transport, err := newProxyTransport(proxyURL)
 	if err != nil {
-		return nil, fmt.Errorf("invalid proxy URL %s: %w", proxyURL, err)
-	}
-
-	transport := &http.Transport{
-		Proxy: http.ProxyURL(proxyParsed),
+		return nil, err
 	}
 
 	return &http.Client{
 		Transport: transport,
+		Jar:       jar,
 		Timeout:   pc.timeout,
 	}, nil
 }
 
 // Get performs a GET request with proxy round-robin
 func (pc *ProxyClient) Get(url string) (*http.Response, error) {
-	return pc.Do("GET", url, nil, nil)
+	return pc.GetCtx(context.Background(), url)
+}
+
+// GetCtx performs a GET request that stops retrying and fails once ctx is done
+func (pc *ProxyClient) GetCtx(ctx context.Context, url string) (*http.Response, error) {
+	return pc.DoCtx(ctx, "GET", url, nil, nil)
 }
 
 // Post performs a POST request with proxy round-robin
 func (pc *ProxyClient) Post(url, contentType string, body io.Reader) (*http.Response, error) {
+	return pc.PostCtx(context.Background(), url, contentType, body)
+}
+
+// PostCtx performs a POST request that stops retrying and fails once ctx is done
+func (pc *ProxyClient) PostCtx(ctx context.Context, url, contentType string, body io.Reader) (*http.Response, error) {
 	headers := map[string]string{
 		"Content-Type": contentType,
 	}
-	return pc.Do("POST", url, body, headers)
+	return pc.DoCtx(ctx, "POST", url, body, headers)
 }
 
-// Do performs an HTTP request with proxy round-robin and retry logic
+// Do performs an HTTP request with proxy round-robin and retry logic. headers override the
+// default headers and the site's header profile for this request only.
 func (pc *ProxyClient) Do(method, url string, body io.Reader, headers map[string]string) (*http.Response, error) {
+	return pc.DoCtx(context.Background(), method, url, body, headers)
+}
+
+// DoCtx works like Do but binds every attempt to ctx: cancelling it aborts the request in flight,
+// skips the remaining retries and proxies, and returns an error matching ctx.Err(). A request whose
+// ctx carries a session key goes through the sticky session of the key, see WithSessionKey.
+func (pc *ProxyClient) DoCtx(ctx context.Context, method, url string, body io.Reader, headers map[string]string) (*http.Response, error) {
+	if session := pc.sessionFromContext(ctx); session != nil {
+		return session.DoCtx(ctx, method, url, body, headers)
+	}
+	resp, _, err := pc.do(ctx, method, url, body, headers, route{})
+	return resp, err
+}
+
+// route is how a request is sent: through any proxy by the strategy, or pinned to one proxy for a
+// sticky session, and with the cookies of jar
+type route struct {
+	jar    http.CookieJar // the session jar, nil for the proxy jars of SetCookieJars
+	pinned bool
+	proxy  string // the pinned proxy, empty for a direct connection
+}
+
+// do sends a request along r and returns the response with the proxy that answered it
+func (pc *ProxyClient) do(ctx context.Context, method, url string, body io.Reader, headers map[string]string, r route) (*http.Response, string, error) {
 	var lastErr error
 
-	headers = map[string]string{
-		"User-Agent":                "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/138.0.0.0 Safari/537.36",
-		"Accept":                    "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7",
-		"Accept-Language":           "en-US,en;q=0.9,ru;q=0.8",
-		"Accept-Encoding":           "gzip, deflate, br",
-		"Connection":                "keep-alive",
-		"Upgrade-Insecure-Requests": "1",
-		"Sec-Fetch-Dest":            "document",
-		"Sec-Fetch-Mode":            "navigate",
-		"Sec-Fetch-Site":            "none",
-		"Sec-Fetch-User":            "?1",
-		"Dnt":                       "1",
-	}
-
-	// Try with proxies first - try each proxy exactly once without skipping any
-	if len(pc.proxies) > 0 {
-		// Get starting index for this request (advances round-robin for next request)
-		startIdx := pc.getNextProxyIndex()
-
-		// Try all proxies starting from the selected index
-		for i := 0; i < len(pc.proxies); i++ {
-			proxyIdx := (startIdx + i) % len(pc.proxies)
-			proxy := pc.proxies[proxyIdx]
-
-			resp, err := pc.doRequestWithProxy(method, url, body, headers, proxy)
-			if err == nil {
-				return resp, nil
-			}
+	// Log records of every attempt carry the same request ID
+	if logger.RequestID(ctx) == "" {
+		ctx = logger.WithRequestID(ctx, logger.NewRequestID())
+	}
+
+	// Defaults, then the site header profile, then the caller's headers
+	headers = pc.requestHeaders(url, headers)
+
+	// Refuse requests to sites paused after a site-wide ban
+	if pc.guard != nil {
+		if err := pc.guard.Allow(siteKey(url)); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// A custom transport replaces the proxies, so none of them is selected
+	if pc.customTransport() != nil {
+		resp, err := pc.doWithTransport(ctx, method, url, body, headers, pc.jarFor(r, ""))
+		return resp, "", err
+	}
+
+	// Every attempt after the first, on any proxy, is a retry and must fit in the retry budget
+	gate := pc.attemptGate(ctx)
+
+	// A session pinned to a direct connection keeps it
+	site := siteKey(url)
+	if r.pinned && r.proxy == "" {
+		resp, err := pc.doRequestWithProxy(ctx, gate, method, url, body, headers, "", pc.jarFor(r, ""))
+		if ctx.Err() != nil {
+			return nil, "", fmt.Errorf("request cancelled: %w", ctx.Err())
+		}
+		return resp, "", err
+	}
+
+	order, geoRestricted, err := pc.routeOrder(site, r)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Try with proxies first - try each proxy exactly once without skipping any.
+	// The strategy decides which proxy goes first; the rest are fallbacks
+	for i, proxyIdx := range order {
+		proxy := pc.proxies[proxyIdx]
+
+		resp, err := pc.doRequestWithProxy(ctx, gate, method, url, body, headers, proxy, pc.jarFor(r, proxy))
+		if ctx.Err() != nil {
+			return nil, "", fmt.Errorf("request cancelled: %w", ctx.Err())
+		}
+		if errors.Is(err, ErrRetryBudgetExhausted) {
+			log.WarnContext(ctx, "Retry budget exhausted", "url", url, "error", err)
+			return nil, "", err
+		}
+		if err != nil {
+			metrics.ObserveProxyAttempt("error")
+			log.DebugContext(ctx, "Proxy attempt failed", "url", url, "proxy", RedactProxy(proxy), "error", err)
 			lastErr = err
+			continue
 		}
+
+		// A blocked response burns the pair and the cookies the proxy got from the site; move on
+		// to the next proxy while there is one
+		blocked := pc.burn(proxy, site, resp.StatusCode)
+		if r.jar == nil && blockStatusCodes[resp.StatusCode] {
+			pc.forgetCookies(proxy, site)
+		}
+		if blocked {
+			metrics.ObserveProxyAttempt("blocked")
+		} else {
+			metrics.ObserveProxyAttempt("ok")
+		}
+		if blocked && i < len(order)-1 {
+			resp.Body.Close()
+			log.DebugContext(ctx, "Proxy blocked", "url", url, "proxy", RedactProxy(proxy), "status", resp.StatusCode)
+			lastErr = fmt.Errorf("blocked with status %d through proxy %s", resp.StatusCode, RedactProxy(proxy))
+			continue
+		}
+		return resp, proxy, nil
 	}
 
-	// If all proxies failed and fallback is allowed, try without proxy
-	if pc.fallbackOK {
-		resp, err := pc.doRequestWithProxy(method, url, body, headers, "")
+	// If all proxies failed and fallback is allowed, try without proxy (never for geo-restricted
+	// hosts, nor for a session pinned to a proxy)
+	if pc.fallbackOK && !geoRestricted && !r.pinned {
+		resp, err := pc.doRequestWithProxy(ctx, gate, method, url, body, headers, "", pc.jarFor(r, ""))
+		if ctx.Err() != nil {
+			return nil, "", fmt.Errorf("request cancelled: %w", ctx.Err())
+		}
 		if err == nil {
-			return resp, nil
+			return resp, "", nil
 		}
 		lastErr = err
 	}
 
 	if lastErr != nil {
-		return nil, fmt.Errorf("all proxy attempts failed, last error: %w", lastErr)
+		return nil, "", fmt.Errorf("all proxy attempts failed, last error: %w", lastErr)
+	}
+
+	return nil, "", fmt.Errorf("no working proxy found and fallback disabled")
+}
+
+// routeOrder returns the proxy indexes a request to site tries in order, and whether the site is
+// geo-restricted. A pinned route only tries its proxy, as long as the site has not burned it.
+func (pc *ProxyClient) routeOrder(site string, r route) ([]int, bool, error) {
+	if r.pinned {
+		index := slices.Index(pc.proxies, r.proxy)
+		if index < 0 {
+			return nil, false, fmt.Errorf("session proxy %s is not configured", RedactProxy(r.proxy))
+		}
+		order, err := pc.withoutBurned([]int{index}, site)
+		return order, false, err
+	}
+
+	// Geo-restricted hosts only go through proxies in the required countries
+	order, geoRestricted, err := pc.geoOrder(site)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Proxies the site blocked recently are skipped until their burn expires
+	order, err = pc.withoutBurned(order, site)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Proxies failing repeatedly are skipped until their quarantine ends; with every proxy
+	// quarantined the request only goes out when it may fall back to a direct connection
+	order, retryAt := pc.withoutQuarantined(order)
+	if len(order) == 0 && !retryAt.IsZero() && (!pc.fallbackOK || geoRestricted) {
+		return nil, false, &ProxiesQuarantinedError{RetryAt: retryAt}
+	}
+	return order, geoRestricted, nil
+}
+
+// doWithTransport performs a request through the transport set with SetTransport, with the
+// retries and block reporting of a request through a proxy
+func (pc *ProxyClient) doWithTransport(ctx context.Context, method, url string, body io.Reader, headers map[string]string, jar http.CookieJar) (*http.Response, error) {
+	resp, err := pc.doRequestWithProxy(ctx, pc.attemptGate(ctx), method, url, body, headers, "", jar)
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("request cancelled: %w", ctx.Err())
+	}
+	if errors.Is(err, ErrRetryBudgetExhausted) {
+		log.WarnContext(ctx, "Retry budget exhausted", "url", url, "error", err)
+		return nil, err
+	}
+	if err != nil {
+		metrics.ObserveProxyAttempt("error")
+		return nil, err
 	}
 
-	return nil, fmt.Errorf("no working proxy found and fallback disabled")
+	if blockStatusCodes[resp.StatusCode] {
+		metrics.ObserveProxyAttempt("blocked")
+	} else {
+		metrics.ObserveProxyAttempt("ok")
+	}
+	return resp, nil
 }
 
-// doRequestWithProxy performs a single HTTP request with the specified proxy
-func (pc *ProxyClient) doRequestWithProxy(method, url string, body io.Reader, headers map[string]string, proxyURL string) (*http.Response, error) {
-	client, err := pc.createClient(proxyURL)
+// RedactProxy returns the proxy URL with its password masked, for logs and errors
+func RedactProxy(proxy string) string {
+	parsed, err := url.Parse(proxy)
+	if err != nil {
+		return proxy
+	}
+	return parsed.Redacted()
+}
+
+// doRequestWithProxy performs a single HTTP request with the specified proxy and the cookies of jar
+// (nil for none), calling gate before every attempt
+func (pc *ProxyClient) doRequestWithProxy(ctx context.Context, gate func() error, method, url string, body io.Reader, headers map[string]string, proxyURL string, jar http.CookieJar) (*http.Response, error) {
+	client, err := pc.createClient(proxyURL, jar)
 	if err != nil {
 		return nil, err
 	}
@@ -184,17 +428,45 @@ func (pc *ProxyClient) doRequestWithProxy(method, url string, body io.Reader, he
 			reqBody = strings.NewReader(string(bodyBytes))
 		}
 
-		req, err := http.NewRequest(method, url, reqBody)
+		req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create request: %w", err)
 		}
 
-		// Add headers
+		if err := gate(); err != nil {
+			return nil, err
+		}
+
+		// Every attempt reaches the host, so every attempt waits for its rate limit
+		if err := pc.waitForHost(ctx, url); err != nil {
+			return nil, fmt.Errorf("failed to wait for rate limit: %w", err)
+		}
+
+		// Add headers; the session's fingerprint only replaces headers left at their default
+		userAgent, fingerprint := pc.identityFor(proxyURL, siteKey(url))
 		for key, value := range headers {
-			req.Header.Set(key, value)
+			if replacement, ok := fingerprint[key]; ok && value == defaultHeaders[key] {
+				value = replacement
+			}
+			if value != "" {
+				req.Header.Set(key, value)
+			}
+		}
+		if req.Header.Get("User-Agent") == "" {
+			req.Header.Set("User-Agent", userAgent)
 		}
 
+		started := time.Now()
 		resp, err := client.Do(req)
+		if ctx.Err() != nil {
+			// A cancelled request says nothing about the proxy or the site
+			if err == nil {
+				resp.Body.Close()
+			}
+			return nil, ctx.Err()
+		}
+		pc.recordResult(proxyURL, time.Since(started), err)
+		pc.observeSite(url, proxyURL, resp, err)
 		if err == nil {
 			return resp, nil
 		}
@@ -212,6 +484,35 @@ func (pc *ProxyClient) doRequestWithProxy(method, url string, body io.Reader, he
 	return nil, fmt.Errorf("unexpected end of retry loop")
 }
 
+// observeSite reports a request outcome to the site guard
+func (pc *ProxyClient) observeSite(url, proxyURL string, resp *http.Response, err error) {
+	if pc.guard == nil {
+		return
+	}
+
+	statusCode := 0
+	var retryAfter time.Duration
+	if err == nil {
+		statusCode = resp.StatusCode
+		retryAfter = ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+
+	// Through a custom transport every request takes the same route, so one block pauses the site
+	totalProxies := len(pc.proxies)
+	if pc.customTransport() != nil {
+		totalProxies = 1
+	}
+	pc.guard.Observe(siteKey(url), proxyURL, totalProxies, statusCode, retryAfter)
+}
+
+// ReportBlockPage tells the site guard that the 200 response to url was a block page, e.g. an
+// anti-bot challenge or a CAPTCHA, which the client cannot tell from a real page
+func (pc *ProxyClient) ReportBlockPage(url, reason string) {
+	if pc.guard != nil {
+		pc.guard.ObserveBlockPage(siteKey(url), reason)
+	}
+}
+
 // GetProxyCount returns the number of configured proxies
 func (pc *ProxyClient) GetProxyCount() int {
 	return len(pc.proxies)
@@ -1,6 +1,11 @@
 package request_client
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -128,7 +133,7 @@ func TestGlobalClient(t *testing.T) {
 func TestInvalidProxyURL(t *testing.T) {
 	client := NewProxyClient([]string{"://invalid"}, 10*time.Second)
 
-	_, err := client.createClient("://invalid")
+	_, err := client.createClient("://invalid", nil)
 	if err == nil {
 		t.Error("Expected error for invalid proxy URL")
 		return
@@ -163,3 +168,157 @@ func TestProxyTriedOnceOnly(t *testing.T) {
 		}
 	}
 }
+
+func TestParseStrategy(t *testing.T) {
+	cases := map[string]Strategy{
+		"":              StrategyRoundRobin,
+		"round_robin":   StrategyRoundRobin,
+		"LEAST_LATENCY": StrategyLeastLatency,
+		"least_errors":  StrategyLeastErrors,
+		"random":        StrategyRandom,
+		"weighted":      StrategyWeighted,
+	}
+
+	for input, expected := range cases {
+		strategy, err := ParseStrategy(input)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", input, err)
+			continue
+		}
+		if strategy != expected {
+			t.Errorf("Expected %s for %q, got %s", expected, input, strategy)
+		}
+	}
+
+	if _, err := ParseStrategy("fastest"); err == nil {
+		t.Error("Expected error for unknown strategy")
+	}
+}
+
+func TestLeastLatencyOrder(t *testing.T) {
+	proxies := []string{
+		"http://proxy1:8080",
+		"http://proxy2:3128",
+		"http://proxy3:1080",
+	}
+
+	client := NewProxyClient(proxies, 10*time.Second)
+	client.SetStrategy(StrategyLeastLatency)
+
+	client.recordResult(proxies[0], 300*time.Millisecond, nil)
+	client.recordResult(proxies[1], 50*time.Millisecond, nil)
+	client.recordResult(proxies[2], 120*time.Millisecond, nil)
+
+	order := client.proxyOrder()
+	expected := []int{1, 2, 0}
+	for i, idx := range expected {
+		if order[i] != idx {
+			t.Errorf("Expected index %d at position %d, got %d", idx, i, order[i])
+		}
+	}
+
+	stats := client.GetProxyStats()
+	if stats[1].Selections != 1 {
+		t.Errorf("Expected fastest proxy to be selected once, got %d", stats[1].Selections)
+	}
+}
+
+func TestLeastErrorsOrder(t *testing.T) {
+	proxies := []string{
+		"http://proxy1:8080",
+		"http://proxy2:3128",
+	}
+
+	client := NewProxyClient(proxies, 10*time.Second)
+	client.SetStrategy(StrategyLeastErrors)
+
+	client.recordResult(proxies[0], time.Millisecond, fmt.Errorf("connection refused"))
+	client.recordResult(proxies[0], time.Millisecond, nil)
+	client.recordResult(proxies[1], time.Millisecond, nil)
+
+	order := client.proxyOrder()
+	if order[0] != 1 {
+		t.Errorf("Expected proxy without errors first, got index %d", order[0])
+	}
+
+	stats := client.GetProxyStats()
+	if stats[0].FailureRatio != 0.5 {
+		t.Errorf("Expected failure ratio 0.5, got %f", stats[0].FailureRatio)
+	}
+}
+
+func TestWeightedOrderSkipsToHeavyProxy(t *testing.T) {
+	proxies := []string{
+		"http://proxy1:8080",
+		"http://proxy2:3128",
+	}
+
+	client := NewProxyClient(proxies, 10*time.Second)
+	client.SetStrategy(StrategyWeighted)
+	client.SetWeights([]int{1, 1000})
+
+	heavy := 0
+	for i := 0; i < 100; i++ {
+		order := client.proxyOrder()
+		if len(order) != 2 {
+			t.Fatalf("Expected all proxies in order, got %v", order)
+		}
+		if order[0] == 1 {
+			heavy++
+		}
+	}
+
+	if heavy < 90 {
+		t.Errorf("Expected heavy proxy to be picked most of the time, got %d/100", heavy)
+	}
+}
+
+func TestActiveProxyCount(t *testing.T) {
+	proxies := []string{"http://proxy1:8080", "http://proxy2:8080", "http://proxy3:8080"}
+	client := NewProxyClient(proxies, 5*time.Second)
+
+	client.recordResult(proxies[0], time.Millisecond, fmt.Errorf("connection refused"))
+	client.recordResult(proxies[0], time.Millisecond, fmt.Errorf("connection refused"))
+	client.recordResult(proxies[1], time.Millisecond, fmt.Errorf("connection refused"))
+	client.recordResult(proxies[1], time.Millisecond, nil)
+	client.recordResult(proxies[1], time.Millisecond, nil)
+
+	active, total := client.ActiveProxyCount()
+	if active != 2 || total != 3 {
+		t.Errorf("Expected 2 of 3 proxies active, got %d of %d", active, total)
+	}
+}
+
+func TestDoCtxCancelStopsRetries(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer slow.Close()
+	next, agents := proxyServer(t, http.StatusOK)
+
+	client := NewProxyClient([]string{slow.URL, next.URL}, 5*time.Second)
+	client.SetMaxRetries(3)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	_, err := client.GetCtx(ctx, "http://listings.example/anketa1.htm")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the deadline error, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("Expected the request to stop at the deadline, took %v", elapsed)
+	}
+	if len(*agents) != 0 {
+		t.Errorf("Expected no fallback to the next proxy after cancellation, got %d requests", len(*agents))
+	}
+	if stats := client.GetProxyStats(); stats[0].Failures != 0 {
+		t.Errorf("Expected a cancelled request not to count as a proxy failure, got %d", stats[0].Failures)
+	}
+}
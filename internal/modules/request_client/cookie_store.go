@@ -0,0 +1,137 @@
+package request_client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// CookieStore persists the cookies of the proxy jars between runs
+type CookieStore interface {
+	// Save replaces the stored cookies
+	Save(ctx context.Context, state *CookieState) error
+	// Load returns the stored cookies, or nil when nothing was saved yet
+	Load(ctx context.Context) (*CookieState, error)
+}
+
+// FileCookieStore keeps the cookies in a JSON file
+type FileCookieStore struct {
+	path string
+}
+
+// NewFileCookieStore creates a store writing to path
+func NewFileCookieStore(path string) *FileCookieStore {
+	return &FileCookieStore{path: path}
+}
+
+// Save writes the cookies to a temporary file and renames it over the old one, so a crash
+// mid-write never leaves a truncated file. The file is only readable by its owner, since the
+// cookies identify the crawler to the sites.
+func (s *FileCookieStore) Save(ctx context.Context, state *CookieState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode cookies: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cookie directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write cookies: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to replace cookies: %w", err)
+	}
+	return nil
+}
+
+// Load reads the cookie file
+func (s *FileCookieStore) Load(ctx context.Context) (*CookieState, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cookies: %w", err)
+	}
+
+	var state CookieState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse cookies: %w", err)
+	}
+	return &state, nil
+}
+
+// RedisCookieStore keeps the cookies under a Redis key, shared by the instances of a fleet that
+// use the same proxies
+type RedisCookieStore struct {
+	client *redis.Client
+	key    string
+}
+
+// NewRedisCookieStore creates a store on an existing Redis client
+func NewRedisCookieStore(client *redis.Client, key string) *RedisCookieStore {
+	return &RedisCookieStore{client: client, key: key}
+}
+
+// Save stores the cookies as JSON
+func (s *RedisCookieStore) Save(ctx context.Context, state *CookieState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode cookies: %w", err)
+	}
+	if err := s.client.Set(ctx, s.key, data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save cookies: %w", err)
+	}
+	return nil
+}
+
+// Load reads the cookies
+func (s *RedisCookieStore) Load(ctx context.Context) (*CookieState, error) {
+	data, err := s.client.Get(ctx, s.key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cookies: %w", err)
+	}
+
+	var state CookieState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse cookies: %w", err)
+	}
+	return &state, nil
+}
+
+// RunCookiePersistence saves the cookies of the proxy jars every interval and once more when ctx
+// is cancelled
+func RunCookiePersistence(ctx context.Context, pc *ProxyClient, store CookieStore, interval time.Duration) {
+	save := func(ctx context.Context) {
+		if err := store.Save(ctx, pc.CookieState()); err != nil {
+			log.WarnContext(ctx, "Failed to persist cookies", "error", err)
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			save(ctx)
+		case <-ctx.Done():
+			// ctx is already cancelled; the final save gets its own deadline
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			save(shutdownCtx)
+			cancel()
+			return
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package request_client
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CookieJar is an in-memory cookie jar that can export its cookies and import them again, so the
+// cookies of the proxy jars survive restarts
+type CookieJar struct {
+	mutex   sync.Mutex
+	jar     *cookiejar.Jar
+	cookies map[string]SavedCookie // by host, domain, path and name
+}
+
+// SavedCookie is a cookie of a CookieJar together with the URL that set it
+type SavedCookie struct {
+	URL      string    `json:"url"`
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Expires  time.Time `json:"expires"` // zero for session cookies
+	Secure   bool      `json:"secure,omitempty"`
+	HttpOnly bool      `json:"http_only,omitempty"`
+}
+
+// NewCookieJar returns an empty cookie jar
+func NewCookieJar() *CookieJar {
+	// cookiejar.New only fails on invalid options
+	jar, _ := cookiejar.New(nil)
+	return &CookieJar{jar: jar, cookies: make(map[string]SavedCookie)}
+}
+
+// SetCookies implements http.CookieJar
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.setCookies(u, cookies)
+}
+
+// setCookies stores cookies set by u; the caller holds the mutex
+func (j *CookieJar) setCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+
+	now := time.Now()
+	origin := (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String()
+	for _, cookie := range cookies {
+		key := strings.Join([]string{strings.ToLower(u.Hostname()), cookie.Domain, cookiePath(u, cookie.Path), cookie.Name}, ";")
+
+		expires := cookie.Expires
+		if cookie.MaxAge > 0 {
+			expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+		}
+		if cookie.MaxAge < 0 || (!expires.IsZero() && !expires.After(now)) {
+			delete(j.cookies, key)
+			continue
+		}
+
+		j.cookies[key] = SavedCookie{
+			URL:      origin,
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Domain:   cookie.Domain,
+			Path:     cookie.Path,
+			Expires:  expires,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HttpOnly,
+		}
+	}
+}
+
+// Cookies implements http.CookieJar
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.jar.Cookies(u)
+}
+
+// Export returns the cookies of the jar that have not expired, sorted by the URL that set them
+func (j *CookieJar) Export() []SavedCookie {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	now := time.Now()
+	cookies := make([]SavedCookie, 0, len(j.cookies))
+	for key, cookie := range j.cookies {
+		if !cookie.Expires.IsZero() && !cookie.Expires.After(now) {
+			delete(j.cookies, key)
+			continue
+		}
+		cookies = append(cookies, cookie)
+	}
+	slices.SortFunc(cookies, func(a, b SavedCookie) int {
+		return strings.Compare(a.URL+";"+a.Name, b.URL+";"+b.Name)
+	})
+	return cookies
+}
+
+// Import adds exported cookies to the jar, skipping the expired ones and those with an invalid URL
+func (j *CookieJar) Import(cookies []SavedCookie) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.importCookies(cookies)
+}
+
+// importCookies adds exported cookies like Import; the caller holds the mutex
+func (j *CookieJar) importCookies(cookies []SavedCookie) {
+	now := time.Now()
+	for _, saved := range cookies {
+		if !saved.Expires.IsZero() && !saved.Expires.After(now) {
+			continue
+		}
+		u, err := url.Parse(saved.URL)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		j.setCookies(u, []*http.Cookie{{
+			Name:     saved.Name,
+			Value:    saved.Value,
+			Domain:   saved.Domain,
+			Path:     saved.Path,
+			Expires:  saved.Expires,
+			Secure:   saved.Secure,
+			HttpOnly: saved.HttpOnly,
+		}})
+	}
+}
+
+// ForgetSite drops the cookies the jar sends to site, a lowercased host as siteKey returns it. The
+// jar is rebuilt from the other cookies under the mutex, so no request sees it half restored.
+func (j *CookieJar) ForgetSite(site string) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	kept := make([]SavedCookie, 0, len(j.cookies))
+	for _, cookie := range j.cookies {
+		if !cookieFor(cookie, site) {
+			kept = append(kept, cookie)
+		}
+	}
+	j.jar, _ = cookiejar.New(nil)
+	j.cookies = make(map[string]SavedCookie)
+	j.importCookies(kept)
+}
+
+// cookieFor reports whether a saved cookie is sent to site: it was set by site, or for a domain
+// site is part of
+func cookieFor(cookie SavedCookie, site string) bool {
+	if u, err := url.Parse(cookie.URL); err == nil && strings.EqualFold(u.Hostname(), site) {
+		return true
+	}
+	domain := strings.ToLower(strings.TrimPrefix(cookie.Domain, "."))
+	return domain != "" && (site == domain || strings.HasSuffix(site, "."+domain))
+}
+
+// cookiePath returns the path a cookie set by u applies to: its own, else the directory of u
+func cookiePath(u *url.URL, path string) string {
+	if strings.HasPrefix(path, "/") {
+		return path
+	}
+	if i := strings.LastIndex(u.Path, "/"); i > 0 {
+		return u.Path[:i]
+	}
+	return "/"
+}
+
+// SetCookieJars makes requests outside sticky sessions keep cookies, in one jar per proxy (and one
+// for direct requests), so a site that sets anti-bot or locale cookies on the first visit sees a
+// returning visitor on every proxy. A jar drops the cookies of a site that blocks its proxy. Sticky
+// sessions keep their own in-memory jars, see NewSession.
+func (pc *ProxyClient) SetCookieJars(enabled bool) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	pc.cookieJars = enabled
+	pc.proxyJars = make(map[string]*CookieJar)
+}
+
+// proxyJar returns the cookie jar of requests through proxy (empty for direct requests), nil when
+// cookie jars are disabled
+func (pc *ProxyClient) proxyJar(proxy string) http.CookieJar {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	if !pc.cookieJars {
+		return nil
+	}
+	jar, exists := pc.proxyJars[proxy]
+	if !exists {
+		jar = NewCookieJar()
+		pc.proxyJars[proxy] = jar
+	}
+	return jar
+}
+
+// jarFor returns the cookie jar of a request along r through proxy: the jar of the sticky session,
+// else the jar of the proxy
+func (pc *ProxyClient) jarFor(r route, proxy string) http.CookieJar {
+	if r.jar != nil {
+		return r.jar
+	}
+	return pc.proxyJar(proxy)
+}
+
+// forgetCookies drops the cookies the jar of proxy keeps for site
+func (pc *ProxyClient) forgetCookies(proxy, site string) {
+	pc.mutex.Lock()
+	jar := pc.proxyJars[proxy]
+	pc.mutex.Unlock()
+
+	if jar != nil {
+		jar.ForgetSite(site)
+	}
+}
+
+// directJarKey names the jar of direct requests in a CookieState
+const directJarKey = "direct"
+
+// CookieState is the content of the proxy cookie jars carried over restarts. The jars of sticky
+// sessions are not part of it.
+type CookieState struct {
+	SavedAt time.Time                `json:"saved_at"`
+	Jars    map[string][]SavedCookie `json:"jars"` // by redacted proxy URL, directJarKey for direct requests
+}
+
+// CookieState returns the cookies of the proxy jars
+func (pc *ProxyClient) CookieState() *CookieState {
+	pc.mutex.Lock()
+	jars := make(map[string]*CookieJar, len(pc.proxyJars))
+	for proxy, jar := range pc.proxyJars {
+		jars[proxy] = jar
+	}
+	pc.mutex.Unlock()
+
+	state := &CookieState{SavedAt: time.Now(), Jars: make(map[string][]SavedCookie, len(jars))}
+	for proxy, jar := range jars {
+		if cookies := jar.Export(); len(cookies) > 0 {
+			state.Jars[jarKey(proxy)] = cookies
+		}
+	}
+	return state
+}
+
+// RestoreCookies fills the proxy jars from a saved state. Jars of proxies no longer configured are
+// dropped. Call it once, after SetCookieJars and before the first request.
+func (pc *ProxyClient) RestoreCookies(state *CookieState) {
+	if state == nil {
+		return
+	}
+
+	for _, proxy := range append([]string{""}, pc.proxies...) {
+		cookies := state.Jars[jarKey(proxy)]
+		if len(cookies) == 0 {
+			continue
+		}
+		if jar, ok := pc.proxyJar(proxy).(*CookieJar); ok {
+			jar.Import(cookies)
+		}
+	}
+}
+
+// jarKey returns the key of the jar of proxy in a CookieState; the proxy password is not saved
+func jarKey(proxy string) string {
+	if proxy == "" {
+		return directJarKey
+	}
+	return RedactProxy(proxy)
+}
@@ -0,0 +1,127 @@
+package request_client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCookieJarExportImport(t *testing.T) {
+	jar := NewCookieJar()
+	page, _ := url.Parse("https://www.example.com/anketa/1.htm")
+	jar.SetCookies(page, []*http.Cookie{
+		{Name: "visitor", Value: "1"},
+		{Name: "locale", Value: "ru", Domain: "example.com", Path: "/", MaxAge: 3600},
+		{Name: "gone", Value: "x", Expires: time.Now().Add(-time.Hour)},
+	})
+
+	saved := jar.Export()
+	if len(saved) != 2 {
+		t.Fatalf("Expected 2 cookies without the expired one, got %v", saved)
+	}
+
+	restored := NewCookieJar()
+	restored.Import(saved)
+	if got := restored.Cookies(page); len(got) != 2 {
+		t.Errorf("Expected 2 cookies after the import, got %v", got)
+	}
+	other, _ := url.Parse("https://m.example.com/")
+	if got := restored.Cookies(other); len(got) != 1 || got[0].Name != "locale" {
+		t.Errorf("Expected the domain cookie on the other host, got %v", got)
+	}
+
+	restored.ForgetSite("m.example.com")
+	if got := restored.Cookies(page); len(got) != 1 || got[0].Name != "visitor" {
+		t.Errorf("Expected only the host cookie after forgetting the site, got %v", got)
+	}
+}
+
+func TestCookieJarForgetSiteWhileReading(t *testing.T) {
+	jar := NewCookieJar()
+	page, _ := url.Parse("https://www.example.com/")
+	jar.SetCookies(page, []*http.Cookie{{Name: "visitor", Value: "1"}})
+
+	// Run with -race: requests read the jar while a block drops the cookies of the site
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				jar.Cookies(page)
+			}
+		}()
+	}
+	for j := 0; j < 100; j++ {
+		jar.ForgetSite("www.example.com")
+		jar.SetCookies(page, []*http.Cookie{{Name: "visitor", Value: "1"}})
+	}
+	wg.Wait()
+
+	if got := jar.Cookies(page); len(got) != 1 {
+		t.Errorf("Expected the cookie set last, got %v", got)
+	}
+}
+
+func TestProxyCookieJars(t *testing.T) {
+	statusA, statusB := http.StatusOK, http.StatusOK
+	proxyA, visitorsA := visitorProxy(t, "a", &statusA)
+	proxyB, visitorsB := visitorProxy(t, "b", &statusB)
+
+	client := NewProxyClient([]string{proxyA.URL, proxyB.URL}, 5*time.Second)
+	client.SetMaxRetries(1)
+	client.SetCookieJars(true)
+
+	get := func(client *ProxyClient) {
+		t.Helper()
+		resp, err := client.GetCtx(context.Background(), "http://listings.example/anketa1.htm")
+		if err != nil {
+			t.Fatalf("Expected the request to succeed, got %v", err)
+		}
+		resp.Body.Close()
+	}
+	for i := 0; i < 4; i++ {
+		get(client)
+	}
+
+	// Each proxy keeps the cookie it got on its first visit
+	if got := visitorsA(); len(got) != 2 || got[0] != "" || got[1] != "a" {
+		t.Errorf("Expected proxy a to send its own cookie back, got %v", got)
+	}
+	if got := visitorsB(); len(got) != 2 || got[0] != "" || got[1] != "b" {
+		t.Errorf("Expected proxy b to send its own cookie back, got %v", got)
+	}
+
+	// The cookies survive a restart through the store
+	store := NewFileCookieStore(filepath.Join(t.TempDir(), "cookies.json"))
+	if err := store.Save(context.Background(), client.CookieState()); err != nil {
+		t.Fatalf("Failed to save cookies: %v", err)
+	}
+	state, err := store.Load(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to load cookies: %v", err)
+	}
+
+	restarted := NewProxyClient([]string{proxyA.URL}, 5*time.Second)
+	restarted.SetMaxRetries(1)
+	restarted.SetCookieJars(true)
+	restarted.RestoreCookies(state)
+	get(restarted)
+	if got := visitorsA(); len(got) != 3 || got[2] != "a" {
+		t.Errorf("Expected the restored cookie of proxy a, got %v", got)
+	}
+
+	// A block drops the cookies the proxy got from the site
+	statusA = http.StatusForbidden
+	resp, err := restarted.GetCtx(context.Background(), "http://listings.example/anketa1.htm")
+	if err == nil {
+		resp.Body.Close()
+	}
+	if cookies := restarted.CookieState().Jars[jarKey(proxyA.URL)]; len(cookies) != 0 {
+		t.Errorf("Expected no cookies after the block, got %v", cookies)
+	}
+}
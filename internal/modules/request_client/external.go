@@ -0,0 +1,138 @@
+package request_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/logger"
+	"github.com/gregor-tokarev/hoe_parser/internal/metrics"
+)
+
+// ErrExternalTransport is returned by DoExternal when a custom transport is set: fetches made
+// outside the client cannot be sent through a RoundTripper
+var ErrExternalTransport = errors.New("external fetches cannot use the custom transport")
+
+// ExternalAttempt is one try of a fetch made outside the client, e.g. by a headless browser
+type ExternalAttempt struct {
+	Proxy     string // proxy URL with its credentials, empty for a direct connection
+	UserAgent string // User-Agent of the proxy's identity on the site
+}
+
+// ExternalFetch makes one attempt of an external fetch and returns the status of the response
+type ExternalFetch func(ctx context.Context, attempt ExternalAttempt) (statusCode int, err error)
+
+// DoExternal routes a fetch of url the client does not send itself like its own requests: the
+// proxies are tried in the order of the strategy, geo rules, burns and quarantine, every attempt
+// waits for the site's rate limit and counts against the retry budget, and the site guard sees
+// every response. A block status burns the proxy and moves on to the next one; the fetch of the
+// last proxy tried is left as it is.
+func (pc *ProxyClient) DoExternal(ctx context.Context, url string, fetch ExternalFetch) error {
+	var lastErr error
+
+	if logger.RequestID(ctx) == "" {
+		ctx = logger.WithRequestID(ctx, logger.NewRequestID())
+	}
+
+	site := siteKey(url)
+	if pc.guard != nil {
+		if err := pc.guard.Allow(site); err != nil {
+			return err
+		}
+	}
+	if pc.customTransport() != nil {
+		return ErrExternalTransport
+	}
+
+	gate := pc.attemptGate(ctx)
+	order, geoRestricted, err := pc.routeOrder(site, route{})
+	if err != nil {
+		return err
+	}
+
+	for i, proxyIdx := range order {
+		proxy := pc.proxies[proxyIdx]
+
+		statusCode, err := pc.fetchExternalWithProxy(ctx, gate, url, proxy, fetch)
+		if ctx.Err() != nil {
+			return fmt.Errorf("fetch cancelled: %w", ctx.Err())
+		}
+		if errors.Is(err, ErrRetryBudgetExhausted) {
+			log.WarnContext(ctx, "Retry budget exhausted", "url", url, "error", err)
+			return err
+		}
+		if err != nil {
+			metrics.ObserveProxyAttempt("error")
+			log.DebugContext(ctx, "Proxy attempt failed", "url", url, "proxy", RedactProxy(proxy), "error", err)
+			lastErr = err
+			continue
+		}
+
+		blocked := pc.burn(proxy, site, statusCode)
+		if blocked {
+			metrics.ObserveProxyAttempt("blocked")
+		} else {
+			metrics.ObserveProxyAttempt("ok")
+		}
+		if blocked && i < len(order)-1 {
+			log.DebugContext(ctx, "Proxy blocked", "url", url, "proxy", RedactProxy(proxy), "status", statusCode)
+			continue
+		}
+		return nil
+	}
+
+	// Direct connections follow the rules of the client's own requests
+	if pc.fallbackOK && !geoRestricted {
+		_, err := pc.fetchExternalWithProxy(ctx, gate, url, "", fetch)
+		if ctx.Err() != nil {
+			return fmt.Errorf("fetch cancelled: %w", ctx.Err())
+		}
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+	}
+
+	if lastErr != nil {
+		return fmt.Errorf("all proxy attempts failed, last error: %w", lastErr)
+	}
+	return fmt.Errorf("no working proxy found and fallback disabled")
+}
+
+// fetchExternalWithProxy makes the attempts of an external fetch through proxyURL, like
+// doRequestWithProxy
+func (pc *ProxyClient) fetchExternalWithProxy(ctx context.Context, gate func() error, url, proxyURL string, fetch ExternalFetch) (int, error) {
+	for attempt := 0; attempt < pc.maxRetries; attempt++ {
+		if err := gate(); err != nil {
+			return 0, err
+		}
+		if err := pc.waitForHost(ctx, url); err != nil {
+			return 0, fmt.Errorf("failed to wait for rate limit: %w", err)
+		}
+
+		userAgent, _ := pc.identityFor(proxyURL, siteKey(url))
+		started := time.Now()
+		statusCode, err := fetch(ctx, ExternalAttempt{Proxy: proxyURL, UserAgent: userAgent})
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		pc.recordResult(proxyURL, time.Since(started), err)
+		if pc.guard != nil {
+			pc.guard.Observe(siteKey(url), proxyURL, len(pc.proxies), statusCode, 0)
+		}
+		if err == nil {
+			return statusCode, nil
+		}
+
+		if attempt == pc.maxRetries-1 {
+			proxyInfo := "no proxy"
+			if proxyURL != "" {
+				proxyInfo = fmt.Sprintf("proxy %s", RedactProxy(proxyURL))
+			}
+			return 0, fmt.Errorf("fetch failed with %s after %d attempts: %w", proxyInfo, pc.maxRetries, err)
+		}
+	}
+
+	return 0, fmt.Errorf("unexpected end of retry loop")
+}
@@ -0,0 +1,285 @@
+package request_client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GeoUnknown labels proxies whose country is not configured or detected
+const GeoUnknown = "unknown"
+
+// ErrNoGeoProxy is matched by errors returned when no proxy satisfies a host's geo rule
+var ErrNoGeoProxy = errors.New("no proxy in required geo")
+
+// NoGeoProxyError is returned instead of sending a geo-restricted request through an unsuitable proxy
+type NoGeoProxyError struct {
+	Host string
+	Geos []string
+}
+
+// Error implements the error interface
+func (e *NoGeoProxyError) Error() string {
+	return fmt.Sprintf("no proxy available for %s in %s", e.Host, strings.Join(e.Geos, "/"))
+}
+
+// Is makes errors.Is(err, ErrNoGeoProxy) match
+func (e *NoGeoProxyError) Is(target error) bool {
+	return target == ErrNoGeoProxy
+}
+
+// GeoRule restricts requests to a host and its subdomains to proxies in the listed countries
+type GeoRule struct {
+	Host string
+	Geos []string
+}
+
+// matches reports whether the rule applies to host
+func (r GeoRule) matches(host string) bool {
+	return host == r.Host || strings.HasSuffix(host, "."+r.Host)
+}
+
+// ParseGeoRules parses rules of the form host=RU|BY
+func ParseGeoRules(entries []string) ([]GeoRule, error) {
+	rules := make([]GeoRule, 0, len(entries))
+	for _, entry := range entries {
+		host, geos, ok := strings.Cut(entry, "=")
+		host = strings.ToLower(strings.TrimSpace(host))
+		if !ok || host == "" {
+			return nil, fmt.Errorf("invalid geo rule %q, expected host=GEO|GEO", entry)
+		}
+
+		rule := GeoRule{Host: host}
+		for _, geo := range strings.Split(geos, "|") {
+			if geo = normalizeGeo(geo); geo != "" {
+				rule.Geos = append(rule.Geos, geo)
+			}
+		}
+		if len(rule.Geos) == 0 {
+			return nil, fmt.Errorf("geo rule %q lists no countries", entry)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// normalizeGeo upper-cases a country code
+func normalizeGeo(geo string) string {
+	return strings.ToUpper(strings.TrimSpace(geo))
+}
+
+// SetProxyGeos tags proxies with their country codes, matched to proxies by position; empty means unknown
+func (pc *ProxyClient) SetProxyGeos(geos []string) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	pc.geos = make([]string, len(pc.proxies))
+	for i := range pc.proxies {
+		if i < len(geos) {
+			pc.geos[i] = normalizeGeo(geos[i])
+		}
+	}
+}
+
+// SetGeoRules sets the hosts that may only be requested through proxies in specific countries
+func (pc *ProxyClient) SetGeoRules(rules []GeoRule) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	pc.geoRules = rules
+}
+
+// geoAt returns the country code of the proxy at index i. Must be called with the mutex held.
+func (pc *ProxyClient) geoAt(i int) string {
+	if i < len(pc.geos) {
+		return pc.geos[i]
+	}
+	return ""
+}
+
+// requiredGeos returns the countries requests to host must go through, or nil when unrestricted
+func (pc *ProxyClient) requiredGeos(host string) []string {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	for _, rule := range pc.geoRules {
+		if rule.matches(host) {
+			return rule.Geos
+		}
+	}
+	return nil
+}
+
+// geoOrder returns the proxies to try for host, keeping only those allowed by its geo rule.
+// restricted reports whether a rule applied, in which case the direct fallback must not be used.
+func (pc *ProxyClient) geoOrder(host string) (order []int, restricted bool, err error) {
+	geos := pc.requiredGeos(host)
+	if geos == nil {
+		return pc.proxyOrder(), false, nil
+	}
+
+	allowed := make(map[string]bool, len(geos))
+	for _, geo := range geos {
+		allowed[geo] = true
+	}
+
+	pc.mutex.Lock()
+	proxyGeos := make([]string, len(pc.proxies))
+	for i := range pc.proxies {
+		proxyGeos[i] = pc.geoAt(i)
+	}
+	pc.mutex.Unlock()
+
+	for _, idx := range pc.strategyOrder() {
+		if allowed[proxyGeos[idx]] {
+			order = append(order, idx)
+		}
+	}
+	if len(order) == 0 {
+		return nil, true, &NoGeoProxyError{Host: host, Geos: geos}
+	}
+	return pc.selectOrder(order), true, nil
+}
+
+// GeoStats aggregates proxy health per country
+type GeoStats struct {
+	Geo          string
+	Proxies      int
+	Active       int // proxies failing less than half of their requests
+	Requests     uint64
+	Failures     uint64
+	FailureRatio float64
+}
+
+// GetGeoStats returns proxy health aggregated per country, sorted by country code
+func (pc *ProxyClient) GetGeoStats() []GeoStats {
+	byGeo := make(map[string]*GeoStats)
+	for _, proxy := range pc.GetProxyStats() {
+		geo := proxy.Geo
+		if geo == "" {
+			geo = GeoUnknown
+		}
+
+		stats, exists := byGeo[geo]
+		if !exists {
+			stats = &GeoStats{Geo: geo}
+			byGeo[geo] = stats
+		}
+		stats.Proxies++
+		if proxy.FailureRatio < activeFailureRatio {
+			stats.Active++
+		}
+		stats.Requests += proxy.Requests
+		stats.Failures += proxy.Failures
+	}
+
+	result := make([]GeoStats, 0, len(byGeo))
+	for _, stats := range byGeo {
+		if stats.Requests > 0 {
+			stats.FailureRatio = float64(stats.Failures) / float64(stats.Requests)
+		}
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Geo < result[j].Geo })
+	return result
+}
+
+// GeoLocator resolves the country a proxy exits from
+type GeoLocator interface {
+	Locate(ctx context.Context, proxyURL string) (string, error)
+}
+
+// DetectGeos looks up the country of every proxy without a configured geo
+func (pc *ProxyClient) DetectGeos(ctx context.Context, locator GeoLocator) error {
+	var lastErr error
+	failed := 0
+
+	for i, proxy := range pc.ListProxies() {
+		pc.mutex.Lock()
+		known := pc.geoAt(i) != ""
+		pc.mutex.Unlock()
+		if known {
+			continue
+		}
+
+		geo, err := locator.Locate(ctx, proxy)
+		if err != nil {
+			failed++
+			lastErr = err
+			continue
+		}
+
+		pc.mutex.Lock()
+		if len(pc.geos) < len(pc.proxies) {
+			pc.geos = append(pc.geos, make([]string, len(pc.proxies)-len(pc.geos))...)
+		}
+		pc.geos[i] = normalizeGeo(geo)
+		pc.mutex.Unlock()
+	}
+
+	if lastErr != nil {
+		return fmt.Errorf("failed to locate %d proxies: %w", failed, lastErr)
+	}
+	return nil
+}
+
+// DefaultGeoLookupURL is the ip-api.com endpoint returning the country of the caller's IP
+const DefaultGeoLookupURL = "http://ip-api.com/json/?fields=status,message,countryCode"
+
+// IPLookupLocator finds a proxy's country by asking an IP geolocation service through the proxy
+type IPLookupLocator struct {
+	lookupURL string
+	timeout   time.Duration
+}
+
+// NewIPLookupLocator creates a locator for an ip-api.com compatible endpoint
+func NewIPLookupLocator(lookupURL string, timeout time.Duration) *IPLookupLocator {
+	if lookupURL == "" {
+		lookupURL = DefaultGeoLookupURL
+	}
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	return &IPLookupLocator{lookupURL: lookupURL, timeout: timeout}
+}
+
+// Locate returns the country code of the proxy's exit IP
+func (l *IPLookupLocator) Locate(ctx context.Context, proxyURL string) (string, error) {
+	transport, err := newProxyTransport(proxyURL)
+	if err != nil {
+		return "", err
+	}
+
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   l.timeout,
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.lookupURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create geo lookup request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("geo lookup through %s failed: %w", proxyURL, err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Status      string `json:"status"`
+		Message     string `json:"message"`
+		CountryCode string `json:"countryCode"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode geo lookup response: %w", err)
+	}
+	if result.Status != "success" || result.CountryCode == "" {
+		return "", fmt.Errorf("geo lookup through %s failed: %s", proxyURL, result.Message)
+	}
+	return result.CountryCode, nil
+}
@@ -0,0 +1,88 @@
+package request_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseGeoRules(t *testing.T) {
+	rules, err := ParseGeoRules([]string{"Intimcity.gold=ru|by", "example.com=DE"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(rules) != 2 || rules[0].Host != "intimcity.gold" || fmt.Sprint(rules[0].Geos) != "[RU BY]" {
+		t.Errorf("Unexpected rules: %+v", rules)
+	}
+
+	for _, invalid := range []string{"intimcity.gold", "=RU", "intimcity.gold="} {
+		if _, err := ParseGeoRules([]string{invalid}); err == nil {
+			t.Errorf("Expected error for rule %q", invalid)
+		}
+	}
+}
+
+func TestGeoOrderKeepsOnlyAllowedProxies(t *testing.T) {
+	proxies := []string{"http://de:8080", "http://ru1:8080", "http://ru2:8080"}
+	client := NewProxyClient(proxies, 5*time.Second)
+	client.SetProxyGeos([]string{"de", "RU", "ru"})
+	client.SetGeoRules([]GeoRule{{Host: "intimcity.gold", Geos: []string{"RU"}}})
+
+	order, restricted, err := client.geoOrder("www.intimcity.gold")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !restricted || len(order) != 2 {
+		t.Fatalf("Expected the 2 RU proxies for a restricted host, got %v", order)
+	}
+	for _, idx := range order {
+		if idx == 0 {
+			t.Errorf("Expected the DE proxy to be excluded, got %v", order)
+		}
+	}
+
+	if order, restricted, _ := client.geoOrder("example.com"); restricted || len(order) != 3 {
+		t.Errorf("Expected every proxy for an unrestricted host, got %v", order)
+	}
+}
+
+func TestDoFailsWithoutGeoProxy(t *testing.T) {
+	client := NewProxyClient([]string{"http://de:8080"}, 5*time.Second)
+	client.SetProxyGeos([]string{"DE"})
+	client.SetGeoRules([]GeoRule{{Host: "intimcity.gold", Geos: []string{"RU"}}})
+	client.SetFallbackAllowed(true)
+
+	_, err := client.Get("https://intimcity.gold/")
+	if !errors.Is(err, ErrNoGeoProxy) {
+		t.Errorf("Expected ErrNoGeoProxy, got %v", err)
+	}
+}
+
+type fakeLocator map[string]string
+
+func (f fakeLocator) Locate(ctx context.Context, proxyURL string) (string, error) {
+	if geo, ok := f[proxyURL]; ok {
+		return geo, nil
+	}
+	return "", fmt.Errorf("lookup failed")
+}
+
+func TestDetectGeosAndGeoStats(t *testing.T) {
+	proxies := []string{"http://a:8080", "http://b:8080", "http://c:8080"}
+	client := NewProxyClient(proxies, 5*time.Second)
+	client.SetProxyGeos([]string{"DE"})
+
+	err := client.DetectGeos(context.Background(), fakeLocator{"http://a:8080": "FR", "http://b:8080": "ru"})
+	if err == nil {
+		t.Errorf("Expected an error for the proxy that could not be located")
+	}
+
+	client.recordResult(proxies[1], time.Millisecond, fmt.Errorf("connection refused"))
+	stats := client.GetGeoStats()
+	expected := "[{DE 1 1 0 0 0} {RU 1 0 1 1 1} {unknown 1 1 0 0 0}]"
+	if got := fmt.Sprint(stats); got != expected {
+		t.Errorf("Expected geo stats %s, got %s", expected, got)
+	}
+}
@@ -1,6 +1,7 @@
 package request_client
 
 import (
+	"context"
 	"sync"
 	"time"
 
@@ -16,6 +17,76 @@ var (
 func InitGlobalClient(cfg *config.Config) {
 	once.Do(func() {
 		globalClient = NewProxyClient(cfg.Proxies, 10*time.Second)
+
+		strategy, err := ParseStrategy(cfg.Proxy.Strategy)
+		if err != nil {
+			log.Warn("Invalid proxy strategy, falling back", "fallback", StrategyRoundRobin, "error", err)
+			strategy = StrategyRoundRobin
+		}
+		globalClient.SetStrategy(strategy)
+		globalClient.SetWeights(cfg.Proxy.Weights)
+
+		globalClient.SetBurnCooldown(cfg.Proxy.BurnCooldown)
+		globalClient.SetHealthPolicy(HealthPolicy{
+			FailureThreshold: cfg.Proxy.QuarantineThreshold,
+			BaseQuarantine:   cfg.Proxy.QuarantineBase,
+			MaxQuarantine:    cfg.Proxy.QuarantineMax,
+		})
+		globalClient.SetRateLimit(RateLimit{
+			PerSecond: cfg.RateLimit.RequestsPerSecond,
+			Burst:     cfg.RateLimit.Burst,
+			Jitter:    cfg.RateLimit.Jitter,
+		})
+		globalClient.SetHostRateLimits(cfg.RateLimit.Hosts)
+		if cfg.Retry.Enabled {
+			globalClient.SetRetryBudget(NewRetryBudget(cfg.Retry.MaxRatio, cfg.Retry.Window,
+				cfg.Retry.MinRequests, cfg.Retry.Pause))
+		}
+
+		profiles, err := LoadHeaderProfiles(cfg.Proxy.HeaderProfilesFile)
+		if err != nil {
+			log.Warn("Failed to load header profiles, using default headers", "error", err)
+		}
+		globalClient.SetHeaderProfiles(profiles)
+
+		agents := cfg.Proxy.UserAgents
+		if len(agents) == 0 {
+			agents, err = LoadUserAgents(cfg.Proxy.UserAgentsFile)
+			if err != nil {
+				log.Warn("Failed to load user agents, using the built-in pool", "error", err)
+			}
+		}
+		globalClient.SetUserAgents(agents)
+		mode, err := ParseUserAgentMode(cfg.Proxy.UserAgentMode)
+		if err != nil {
+			log.Warn("Invalid user agent mode, falling back", "fallback", UserAgentSticky, "error", err)
+			mode = UserAgentSticky
+		}
+		globalClient.SetUserAgentMode(mode)
+		globalClient.SetRandomizeHeaders(cfg.Proxy.RandomizeHeaders)
+		if cfg.Proxy.StickySessionTTL > 0 {
+			globalClient.SetStickySessions(cfg.Proxy.StickySessionTTL)
+		}
+		globalClient.SetCookieJars(cfg.Proxy.CookieJars)
+
+		globalClient.SetProxyGeos(cfg.Proxy.Geos)
+		rules, err := ParseGeoRules(cfg.Proxy.GeoRules)
+		if err != nil {
+			log.Warn("Invalid geo rules, geo routing disabled", "error", err)
+		}
+		globalClient.SetGeoRules(rules)
+		if cfg.Proxy.GeoLookup {
+			if err := globalClient.DetectGeos(context.Background(), NewIPLookupLocator("", 10*time.Second)); err != nil {
+				log.Warn("Failed to detect proxy geos", "error", err)
+			}
+		}
+
+		if cfg.SiteBan.Enabled {
+			guard := NewSiteGuard(cfg.SiteBan.Cooldown, cfg.SiteBan.Window,
+				cfg.SiteBan.ProbeInterval, cfg.SiteBan.ProbeSuccesses)
+			guard.SetBlockRate(cfg.SiteBan.BlockRate, cfg.SiteBan.MinRequests)
+			globalClient.SetSiteGuard(guard)
+		}
 	})
 }
 
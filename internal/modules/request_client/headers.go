@@ -0,0 +1,128 @@
+package request_client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// defaultHeaders are sent with every request unless a site profile or the caller overrides them.
+// The User-Agent is set per (proxy, site) session in doRequestWithProxy.
+var defaultHeaders = map[string]string{
+	"Accept":                    "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7",
+	"Accept-Language":           "en-US,en;q=0.9,ru;q=0.8",
+	"Accept-Encoding":           "gzip, deflate, br",
+	"Connection":                "keep-alive",
+	"Upgrade-Insecure-Requests": "1",
+	"Sec-Fetch-Dest":            "document",
+	"Sec-Fetch-Mode":            "navigate",
+	"Sec-Fetch-Site":            "none",
+	"Sec-Fetch-User":            "?1",
+	"Dnt":                       "1",
+}
+
+// HeaderProfile holds the headers a site needs to serve full content. Values may use the
+// template variables {{url}}, {{origin}}, {{host}} and {{path}} of the requested URL, and an
+// empty value removes a default header.
+type HeaderProfile struct {
+	Site    string            `json:"site"` // host; also matches its subdomains
+	Headers map[string]string `json:"headers"`
+}
+
+// LoadHeaderProfiles reads header profiles from a JSON file; an empty path loads none
+func LoadHeaderProfiles(path string) ([]HeaderProfile, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header profiles file: %w", err)
+	}
+
+	var profiles []HeaderProfile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("failed to parse header profiles file: %w", err)
+	}
+	for i, profile := range profiles {
+		if strings.TrimSpace(profile.Site) == "" {
+			return nil, fmt.Errorf("header profile %d has no site", i)
+		}
+	}
+	return profiles, nil
+}
+
+// SetHeaderProfiles sets the per-site header profiles applied to every request
+func (pc *ProxyClient) SetHeaderProfiles(profiles []HeaderProfile) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	pc.headerProfiles = profiles
+}
+
+// profileFor returns the profile of the most specific site matching host, or nil
+func (pc *ProxyClient) profileFor(host string) *HeaderProfile {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	var best *HeaderProfile
+	for i := range pc.headerProfiles {
+		site := strings.ToLower(pc.headerProfiles[i].Site)
+		if host != site && !strings.HasSuffix(host, "."+site) {
+			continue
+		}
+		if best == nil || len(site) > len(best.Site) {
+			best = &pc.headerProfiles[i]
+		}
+	}
+	return best
+}
+
+// requestHeaders merges the default headers, the site profile and the per-request overrides,
+// in increasing order of precedence, and expands the template variables against rawURL
+func (pc *ProxyClient) requestHeaders(rawURL string, overrides map[string]string) map[string]string {
+	headers := make(map[string]string, len(defaultHeaders))
+	merge := func(values map[string]string) {
+		for key, value := range values {
+			key = http.CanonicalHeaderKey(key)
+			if value == "" {
+				delete(headers, key)
+				continue
+			}
+			headers[key] = value
+		}
+	}
+
+	merge(defaultHeaders)
+	if profile := pc.profileFor(siteKey(rawURL)); profile != nil {
+		merge(profile.Headers)
+	}
+	merge(overrides)
+
+	vars := templateVars(rawURL)
+	for key, value := range headers {
+		if strings.Contains(value, "{{") {
+			headers[key] = vars.Replace(value)
+		}
+	}
+	return headers
+}
+
+// templateVars returns the replacer expanding header template variables for rawURL
+func templateVars(rawURL string) *strings.Replacer {
+	var origin, host, path string
+	if parsed, err := url.Parse(rawURL); err == nil {
+		origin = parsed.Scheme + "://" + parsed.Host
+		host = parsed.Host
+		path = parsed.EscapedPath()
+	}
+
+	return strings.NewReplacer(
+		"{{url}}", rawURL,
+		"{{origin}}", origin,
+		"{{host}}", host,
+		"{{path}}", path,
+	)
+}
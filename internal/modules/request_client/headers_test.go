@@ -0,0 +1,100 @@
+package request_client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRequestHeadersPrecedence(t *testing.T) {
+	client := NewProxyClient([]string{}, 10*time.Second)
+	client.SetHeaderProfiles([]HeaderProfile{
+		{Site: "intimcity.gold", Headers: map[string]string{
+			"Referer":         "{{origin}}/",
+			"Accept-Language": "ru-RU,ru;q=0.9",
+		}},
+		{Site: "b.intimcity.gold", Headers: map[string]string{
+			"referer":        "{{url}}",
+			"Sec-Fetch-User": "",
+		}},
+	})
+
+	headers := client.requestHeaders("https://a.intimcity.gold/anketa1.htm", nil)
+	if headers["Referer"] != "https://a.intimcity.gold/" {
+		t.Errorf("Expected the origin as Referer, got %q", headers["Referer"])
+	}
+	if headers["Accept-Language"] != "ru-RU,ru;q=0.9" {
+		t.Errorf("Expected the profile Accept-Language, got %q", headers["Accept-Language"])
+	}
+	if headers["Sec-Fetch-User"] != "?1" {
+		t.Errorf("Expected default headers to be kept, got %q", headers["Sec-Fetch-User"])
+	}
+
+	headers = client.requestHeaders("https://b.intimcity.gold/anketa1.htm", nil)
+	if headers["Referer"] != "https://b.intimcity.gold/anketa1.htm" {
+		t.Errorf("Expected the most specific profile to win, got %q", headers["Referer"])
+	}
+	if _, exists := headers["Sec-Fetch-User"]; exists {
+		t.Errorf("Expected an empty profile value to remove the header")
+	}
+
+	headers = client.requestHeaders("https://b.intimcity.gold/anketa1.htm", map[string]string{"Referer": "https://b.intimcity.gold/"})
+	if headers["Referer"] != "https://b.intimcity.gold/" {
+		t.Errorf("Expected the per-request header to override the profile, got %q", headers["Referer"])
+	}
+
+	headers = client.requestHeaders("https://example.com/", nil)
+	if _, exists := headers["Referer"]; exists {
+		t.Errorf("Expected no Referer for a site without a profile")
+	}
+}
+
+func TestDoSendsProfileHeaders(t *testing.T) {
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	client := NewProxyClient([]string{}, 10*time.Second)
+	client.SetFallbackAllowed(true)
+	client.SetHeaderProfiles([]HeaderProfile{{Site: "127.0.0.1", Headers: map[string]string{"Referer": "{{url}}"}}})
+
+	resp, err := client.Post(server.URL+"/search", "application/x-www-form-urlencoded", nil)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if received.Get("Referer") != server.URL+"/search" {
+		t.Errorf("Expected the page URL as Referer, got %q", received.Get("Referer"))
+	}
+	if received.Get("Content-Type") != "application/x-www-form-urlencoded" {
+		t.Errorf("Expected the caller's Content-Type, got %q", received.Get("Content-Type"))
+	}
+}
+
+func TestLoadHeaderProfiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiles.json")
+	if err := os.WriteFile(path, []byte(`[{"site": "intimcity.gold", "headers": {"Referer": "{{origin}}/"}}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	profiles, err := LoadHeaderProfiles(path)
+	if err != nil {
+		t.Fatalf("Failed to load profiles: %v", err)
+	}
+	if len(profiles) != 1 || profiles[0].Headers["Referer"] != "{{origin}}/" {
+		t.Errorf("Expected one intimcity.gold profile, got %v", profiles)
+	}
+
+	if err := os.WriteFile(path, []byte(`[{"headers": {}}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadHeaderProfiles(path); err == nil {
+		t.Errorf("Expected an error for a profile without a site")
+	}
+}
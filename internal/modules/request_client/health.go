@@ -0,0 +1,125 @@
+package request_client
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrProxiesQuarantined is matched by errors returned when every usable proxy is quarantined
+var ErrProxiesQuarantined = errors.New("all proxies quarantined")
+
+// ProxiesQuarantinedError is returned instead of sending a request through a proxy that keeps failing
+type ProxiesQuarantinedError struct {
+	RetryAt time.Time // when the first quarantine ends
+}
+
+// Error implements the error interface
+func (e *ProxiesQuarantinedError) Error() string {
+	return fmt.Sprintf("all proxies quarantined until %s", e.RetryAt.Format(time.RFC3339))
+}
+
+// Is makes errors.Is(err, ErrProxiesQuarantined) match
+func (e *ProxiesQuarantinedError) Is(target error) bool {
+	return target == ErrProxiesQuarantined
+}
+
+// HealthPolicy decides when a failing proxy is taken out of rotation. A proxy failing
+// FailureThreshold attempts in a row is quarantined for BaseQuarantine. Once re-admitted it is
+// on probation: its next failure quarantines it again for twice as long, up to MaxQuarantine,
+// and its first success clears the backoff.
+type HealthPolicy struct {
+	FailureThreshold int // consecutive failed attempts that quarantine a proxy, 0 disables quarantine
+	BaseQuarantine   time.Duration
+	MaxQuarantine    time.Duration
+}
+
+// QuarantineEvent records a proxy taken out of rotation after consecutive failures
+type QuarantineEvent struct {
+	Proxy    string        `json:"proxy"`
+	Failures int           `json:"failures"` // consecutive failed attempts
+	Duration time.Duration `json:"duration"`
+	Until    time.Time     `json:"until"`
+}
+
+// SetHealthPolicy sets when failing proxies are quarantined
+func (pc *ProxyClient) SetHealthPolicy(policy HealthPolicy) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	pc.health = policy
+}
+
+// SetQuarantineHandler sets a callback receiving every quarantine event
+func (pc *ProxyClient) SetQuarantineHandler(handler func(QuarantineEvent)) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	pc.onQuarantine = handler
+}
+
+// quarantined reports whether a proxy is out of rotation at now
+func (s *proxyState) quarantined(now time.Time) bool {
+	return now.Before(s.quarantinedUntil)
+}
+
+// recordHealth updates the consecutive failures of a proxy after an attempt and returns the
+// quarantine it started, if any. Must be called with the mutex held.
+func (pc *ProxyClient) recordHealth(proxy string, state *proxyState, failed bool, now time.Time) *QuarantineEvent {
+	if !failed {
+		state.consecutiveFailures = 0
+		state.backoff = 0
+		return nil
+	}
+
+	state.consecutiveFailures++
+	if pc.health.FailureThreshold <= 0 || state.quarantined(now) {
+		return nil
+	}
+	// A re-admitted proxy gets no second chance before its backoff is cleared
+	if state.consecutiveFailures < pc.health.FailureThreshold && state.backoff == 0 {
+		return nil
+	}
+
+	duration := pc.health.BaseQuarantine << state.backoff
+	if pc.health.MaxQuarantine > 0 && (duration > pc.health.MaxQuarantine || duration <= 0) {
+		duration = pc.health.MaxQuarantine
+	} else {
+		state.backoff++
+	}
+
+	event := &QuarantineEvent{
+		Proxy:    proxy,
+		Failures: state.consecutiveFailures,
+		Duration: duration,
+		Until:    now.Add(duration),
+	}
+	state.quarantinedUntil = event.Until
+	state.quarantines++
+	state.consecutiveFailures = 0
+	return event
+}
+
+// withoutQuarantined removes quarantined proxies from order and returns when the first of the
+// removed proxies is re-admitted, or the zero time when none was removed
+func (pc *ProxyClient) withoutQuarantined(order []int) ([]int, time.Time) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	if pc.health.FailureThreshold <= 0 {
+		return order, time.Time{}
+	}
+
+	now := time.Now()
+	var retryAt time.Time
+	usable := make([]int, 0, len(order))
+	for _, idx := range order {
+		state := pc.stateFor(pc.proxies[idx])
+		if !state.quarantined(now) {
+			usable = append(usable, idx)
+			continue
+		}
+		if retryAt.IsZero() || state.quarantinedUntil.Before(retryAt) {
+			retryAt = state.quarantinedUntil
+		}
+	}
+	return usable, retryAt
+}
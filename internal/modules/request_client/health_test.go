@@ -0,0 +1,102 @@
+package request_client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// deadProxy returns the URL of a proxy refusing every connection
+func deadProxy() string {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	return server.URL
+}
+
+func TestFailingProxyIsQuarantined(t *testing.T) {
+	dead := deadProxy()
+	healthy, _ := proxyServer(t, http.StatusOK)
+
+	client := NewProxyClient([]string{dead, healthy.URL}, 5*time.Second)
+	client.SetMaxRetries(1)
+	client.SetHealthPolicy(HealthPolicy{FailureThreshold: 2, BaseQuarantine: time.Minute, MaxQuarantine: 10 * time.Minute})
+
+	var events []QuarantineEvent
+	client.SetQuarantineHandler(func(event QuarantineEvent) { events = append(events, event) })
+
+	for i := 0; i < 6; i++ {
+		resp, err := client.Get("http://listings.example/anketa1.htm")
+		if err != nil {
+			t.Fatalf("Expected the healthy proxy to answer request %d, got %v", i, err)
+		}
+		resp.Body.Close()
+	}
+
+	if len(events) != 1 || events[0].Proxy != dead || events[0].Failures != 2 || events[0].Duration != time.Minute {
+		t.Fatalf("Expected one quarantine event for the dead proxy, got %+v", events)
+	}
+
+	stats := client.GetProxyStats()
+	if stats[0].Requests != 2 {
+		t.Errorf("Expected the dead proxy to be skipped once quarantined, got %d requests", stats[0].Requests)
+	}
+	if stats[0].QuarantinedUntil.IsZero() || stats[0].Quarantines != 1 {
+		t.Errorf("Expected the dead proxy to be reported as quarantined, got %+v", stats[0])
+	}
+	if !stats[1].QuarantinedUntil.IsZero() {
+		t.Errorf("Expected the healthy proxy to stay in rotation")
+	}
+	if active, total := client.ActiveProxyCount(); active != 1 || total != 2 {
+		t.Errorf("Expected 1/2 active proxies, got %d/%d", active, total)
+	}
+}
+
+func TestQuarantineBackoff(t *testing.T) {
+	client := NewProxyClient([]string{"http://proxy:8080"}, time.Second)
+	client.SetHealthPolicy(HealthPolicy{FailureThreshold: 2, BaseQuarantine: time.Minute, MaxQuarantine: 3 * time.Minute})
+	state := &proxyState{}
+	now := time.Now()
+
+	if event := client.recordHealth("p", state, true, now); event != nil {
+		t.Fatalf("Expected no quarantine below the threshold, got %+v", event)
+	}
+
+	// Every failure after re-admission doubles the quarantine, up to the maximum
+	for i, expected := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		event := client.recordHealth("p", state, true, now)
+		if event == nil || event.Duration != expected {
+			t.Fatalf("Expected quarantine %d to last %v, got %+v", i, expected, event)
+		}
+		if event := client.recordHealth("p", state, true, now.Add(time.Second)); event != nil {
+			t.Fatalf("Expected failures during the quarantine not to extend it, got %+v", event)
+		}
+		now = event.Until
+	}
+
+	// A success clears the backoff, so a single failure is tolerated again
+	client.recordHealth("p", state, false, now)
+	if event := client.recordHealth("p", state, true, now); event != nil {
+		t.Errorf("Expected no quarantine after a success, got %+v", event)
+	}
+}
+
+func TestAllProxiesQuarantined(t *testing.T) {
+	client := NewProxyClient([]string{deadProxy()}, 5*time.Second)
+	client.SetMaxRetries(1)
+	client.SetHealthPolicy(HealthPolicy{FailureThreshold: 1, BaseQuarantine: time.Minute})
+
+	if _, err := client.Get("http://listings.example/anketa1.htm"); err == nil || errors.Is(err, ErrProxiesQuarantined) {
+		t.Fatalf("Expected the first request to fail on the proxy, got %v", err)
+	}
+
+	_, err := client.Get("http://listings.example/anketa1.htm")
+	var quarantined *ProxiesQuarantinedError
+	if !errors.As(err, &quarantined) || !errors.Is(err, ErrProxiesQuarantined) {
+		t.Fatalf("Expected a ProxiesQuarantinedError, got %v", err)
+	}
+	if quarantined.RetryAt.Before(time.Now()) {
+		t.Errorf("Expected RetryAt in the future, got %v", quarantined.RetryAt)
+	}
+}
@@ -0,0 +1,95 @@
+package request_client
+
+import (
+	"context"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimit throttles requests to each host with a token bucket
+type RateLimit struct {
+	PerSecond float64       // sustained requests per second to one host, 0 disables limiting
+	Burst     int           // requests allowed at once before throttling
+	Jitter    time.Duration // upper bound of a random pause added after each token
+}
+
+// SetRateLimit sets the per-host rate limit applied to every request, including retries
+func (pc *ProxyClient) SetRateLimit(limit RateLimit) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	pc.rateLimit = limit
+	pc.limiters = make(map[string]*rate.Limiter)
+}
+
+// SetHostRateLimits sets requests per second for specific hosts (and their subdomains),
+// overriding the default rate
+func (pc *ProxyClient) SetHostRateLimits(hosts map[string]float64) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	pc.hostRates = make(map[string]float64, len(hosts))
+	for host, perSecond := range hosts {
+		pc.hostRates[strings.ToLower(host)] = perSecond
+	}
+	pc.limiters = make(map[string]*rate.Limiter)
+}
+
+// limiterFor returns the limiter of host, or nil when requests to it are not limited
+func (pc *ProxyClient) limiterFor(host string) *rate.Limiter {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	if limiter, exists := pc.limiters[host]; exists {
+		return limiter
+	}
+
+	perSecond := pc.rateLimit.PerSecond
+	var matched string
+	for site, siteRate := range pc.hostRates {
+		if (host == site || strings.HasSuffix(host, "."+site)) && len(site) > len(matched) {
+			matched, perSecond = site, siteRate
+		}
+	}
+
+	var limiter *rate.Limiter
+	if perSecond > 0 {
+		burst := pc.rateLimit.Burst
+		if burst < 1 {
+			burst = 1
+		}
+		limiter = rate.NewLimiter(rate.Limit(perSecond), burst)
+	}
+	if pc.limiters == nil {
+		pc.limiters = make(map[string]*rate.Limiter)
+	}
+	pc.limiters[host] = limiter
+	return limiter
+}
+
+// waitForHost blocks until the host's rate limit allows another request, plus a random jitter
+func (pc *ProxyClient) waitForHost(ctx context.Context, rawURL string) error {
+	limiter := pc.limiterFor(siteKey(rawURL))
+	if limiter == nil {
+		return nil
+	}
+
+	if err := limiter.Wait(ctx); err != nil {
+		return err
+	}
+
+	pc.mutex.Lock()
+	jitter := pc.rateLimit.Jitter
+	pc.mutex.Unlock()
+	if jitter > 0 {
+		timer := time.NewTimer(rand.N(jitter + 1))
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
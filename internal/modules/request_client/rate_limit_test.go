@@ -0,0 +1,57 @@
+package request_client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimitThrottlesPerHost(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client := NewProxyClient([]string{}, 10*time.Second)
+	client.SetFallbackAllowed(true)
+	client.SetRateLimit(RateLimit{PerSecond: 20, Burst: 1})
+
+	started := time.Now()
+	for i := 0; i < 4; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	// The first request uses the burst, the other three wait 50ms each
+	if elapsed := time.Since(started); elapsed < 140*time.Millisecond {
+		t.Errorf("Expected requests to be throttled to 20/s, took %v", elapsed)
+	}
+}
+
+func TestHostRateLimits(t *testing.T) {
+	client := NewProxyClient([]string{}, 10*time.Second)
+	client.SetRateLimit(RateLimit{PerSecond: 2, Burst: 3})
+	client.SetHostRateLimits(map[string]float64{"intimcity.gold": 0.5, "example.com": 0})
+
+	if limiter := client.limiterFor("b.intimcity.gold"); limiter == nil || limiter.Limit() != 0.5 || limiter.Burst() != 3 {
+		t.Errorf("Expected the intimcity.gold rate for its subdomain, got %v", limiter)
+	}
+	if limiter := client.limiterFor("other.org"); limiter == nil || limiter.Limit() != 2 {
+		t.Errorf("Expected the default rate for other hosts, got %v", limiter)
+	}
+	if limiter := client.limiterFor("example.com"); limiter != nil {
+		t.Errorf("Expected a zero host rate to disable limiting")
+	}
+	if client.limiterFor("other.org") != client.limiterFor("other.org") {
+		t.Errorf("Expected one limiter per host")
+	}
+}
+
+func TestNoRateLimitByDefault(t *testing.T) {
+	client := NewProxyClient([]string{}, 10*time.Second)
+	if limiter := client.limiterFor("intimcity.gold"); limiter != nil {
+		t.Errorf("Expected no limiter without a configured rate")
+	}
+}
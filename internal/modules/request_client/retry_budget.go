@@ -0,0 +1,233 @@
+package request_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrRetryBudgetExhausted is matched by errors returned when a retry would exceed the retry budget
+var ErrRetryBudgetExhausted = errors.New("retry budget exhausted")
+
+// RetryBudgetError is returned for a request whose retry was refused by the retry budget
+type RetryBudgetError struct {
+	Ratio       float64   // share of retries in the window when the budget tripped
+	PausedUntil time.Time // when requests resume
+}
+
+// Error implements the error interface
+func (e *RetryBudgetError) Error() string {
+	return fmt.Sprintf("retry budget exhausted (%.0f%% retries), requests paused until %s",
+		e.Ratio*100, e.PausedUntil.Format(time.RFC3339))
+}
+
+// Is makes errors.Is(err, ErrRetryBudgetExhausted) match
+func (e *RetryBudgetError) Is(target error) bool {
+	return target == ErrRetryBudgetExhausted
+}
+
+// RetryBudgetTrip records the kill-switch pausing all requests
+type RetryBudgetTrip struct {
+	Requests int       `json:"requests"` // requests and retries in the window
+	Retries  int       `json:"retries"`
+	Ratio    float64   `json:"ratio"`
+	At       time.Time `json:"at"`
+	Until    time.Time `json:"until"`
+}
+
+// RetryBudgetStats is a snapshot of the retry budget
+type RetryBudgetStats struct {
+	Requests    int
+	Retries     int
+	Ratio       float64
+	Trips       int
+	PausedUntil time.Time // zero when requests are not paused
+}
+
+// attemptEvent is one request attempt in the rolling window
+type attemptEvent struct {
+	at    time.Time
+	retry bool
+}
+
+// RetryBudget caps the share of attempts that are retries over a rolling window. When a retry
+// would push the share above the budget it is refused and every request is paused, so retry
+// storms do not amplify an outage of the site or the proxy pool.
+type RetryBudget struct {
+	mu          sync.Mutex
+	maxRatio    float64
+	window      time.Duration
+	minRequests int // attempts needed in the window before the budget is enforced
+	pause       time.Duration
+	events      []attemptEvent
+	pausedUntil time.Time
+	trips       int
+	onTrip      func(RetryBudgetTrip)
+	now         func() time.Time
+}
+
+// NewRetryBudget creates a budget allowing maxRatio of the attempts in window to be retries
+func NewRetryBudget(maxRatio float64, window time.Duration, minRequests int, pause time.Duration) *RetryBudget {
+	return &RetryBudget{
+		maxRatio:    maxRatio,
+		window:      window,
+		minRequests: minRequests,
+		pause:       pause,
+		now:         time.Now,
+	}
+}
+
+// SetTripHandler sets a callback receiving every kill-switch trip
+func (b *RetryBudget) SetTripHandler(handler func(RetryBudgetTrip)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onTrip = handler
+}
+
+// Wait blocks while requests are paused, returning early with ctx.Err() when ctx is done
+func (b *RetryBudget) Wait(ctx context.Context) error {
+	b.mu.Lock()
+	wait := b.pausedUntil.Sub(b.now())
+	b.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RecordRequest counts the first attempt of a request
+func (b *RetryBudget) RecordRequest() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	b.prune(now)
+	b.events = append(b.events, attemptEvent{at: now})
+}
+
+// AllowRetry counts a retry if the budget has room for it. Otherwise it trips the kill-switch
+// and returns a RetryBudgetError.
+func (b *RetryBudget) AllowRetry() error {
+	b.mu.Lock()
+
+	now := b.now()
+	if now.Before(b.pausedUntil) {
+		err := &RetryBudgetError{Ratio: b.ratio(), PausedUntil: b.pausedUntil}
+		b.mu.Unlock()
+		return err
+	}
+
+	b.prune(now)
+	requests, retries := b.counts()
+	requests++
+	retries++
+	ratio := float64(retries) / float64(requests)
+	if requests < b.minRequests || ratio <= b.maxRatio {
+		b.events = append(b.events, attemptEvent{at: now, retry: true})
+		b.mu.Unlock()
+		return nil
+	}
+
+	// Trip: pause every request and start the next window from scratch
+	b.pausedUntil = now.Add(b.pause)
+	b.trips++
+	b.events = nil
+	trip := RetryBudgetTrip{Requests: requests, Retries: retries, Ratio: ratio, At: now, Until: b.pausedUntil}
+	handler := b.onTrip
+	b.mu.Unlock()
+
+	if handler != nil {
+		handler(trip)
+	}
+	return &RetryBudgetError{Ratio: ratio, PausedUntil: trip.Until}
+}
+
+// Stats returns a snapshot of the budget
+func (b *RetryBudget) Stats() RetryBudgetStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.prune(now)
+	requests, retries := b.counts()
+	stats := RetryBudgetStats{Requests: requests, Retries: retries, Ratio: b.ratio(), Trips: b.trips}
+	if now.Before(b.pausedUntil) {
+		stats.PausedUntil = b.pausedUntil
+	}
+	return stats
+}
+
+// prune drops attempts older than the window. Must be called with the mutex held.
+func (b *RetryBudget) prune(now time.Time) {
+	cutoff := now.Add(-b.window)
+	i := 0
+	for i < len(b.events) && b.events[i].at.Before(cutoff) {
+		i++
+	}
+	b.events = b.events[i:]
+}
+
+// counts returns the attempts and retries in the window. Must be called with the mutex held.
+func (b *RetryBudget) counts() (int, int) {
+	var retries int
+	for _, event := range b.events {
+		if event.retry {
+			retries++
+		}
+	}
+	return len(b.events), retries
+}
+
+// ratio returns the share of retries in the window. Must be called with the mutex held.
+func (b *RetryBudget) ratio() float64 {
+	requests, retries := b.counts()
+	if requests == 0 {
+		return 0
+	}
+	return float64(retries) / float64(requests)
+}
+
+// SetRetryBudget enables the retry budget and kill-switch; nil disables it
+func (pc *ProxyClient) SetRetryBudget(budget *RetryBudget) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	pc.retryBudget = budget
+}
+
+// RetryBudget returns the configured retry budget, or nil
+func (pc *ProxyClient) RetryBudget() *RetryBudget {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	return pc.retryBudget
+}
+
+// attemptGate returns the function called before every attempt of one request: the first attempt
+// waits out a paused budget and is counted as a request, later ones must fit in the budget
+func (pc *ProxyClient) attemptGate(ctx context.Context) func() error {
+	budget := pc.RetryBudget()
+	attempts := 0
+	return func() error {
+		attempts++
+		if budget == nil {
+			return nil
+		}
+		if attempts > 1 {
+			return budget.AllowRetry()
+		}
+		if err := budget.Wait(ctx); err != nil {
+			return err
+		}
+		budget.RecordRequest()
+		return nil
+	}
+}
@@ -0,0 +1,96 @@
+package request_client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryBudgetTripsAboveRatio(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	budget := NewRetryBudget(0.2, time.Minute, 10, 2*time.Minute)
+	budget.now = func() time.Time { return now }
+
+	var trips []RetryBudgetTrip
+	budget.SetTripHandler(func(trip RetryBudgetTrip) { trips = append(trips, trip) })
+
+	for i := 0; i < 8; i++ {
+		budget.RecordRequest()
+	}
+	// 2 retries out of 10 attempts is exactly the budget
+	for i := 0; i < 2; i++ {
+		if err := budget.AllowRetry(); err != nil {
+			t.Fatalf("Expected retry %d to fit in the budget, got %v", i+1, err)
+		}
+	}
+
+	err := budget.AllowRetry()
+	if !errors.Is(err, ErrRetryBudgetExhausted) {
+		t.Fatalf("Expected the retry budget to be exhausted, got %v", err)
+	}
+	if len(trips) != 1 || trips[0].Retries != 3 || trips[0].Requests != 11 {
+		t.Errorf("Expected one trip with 3 of 11 attempts retried, got %+v", trips)
+	}
+	if stats := budget.Stats(); !stats.PausedUntil.Equal(now.Add(2*time.Minute)) || stats.Trips != 1 {
+		t.Errorf("Expected requests paused for 2m, got %+v", stats)
+	}
+	if err := budget.AllowRetry(); !errors.Is(err, ErrRetryBudgetExhausted) {
+		t.Errorf("Expected retries to be refused while paused, got %v", err)
+	}
+
+	now = now.Add(3 * time.Minute)
+	if err := budget.Wait(context.Background()); err != nil {
+		t.Errorf("Expected no wait after the pause, got %v", err)
+	}
+	if err := budget.AllowRetry(); err != nil {
+		t.Errorf("Expected retries to be allowed after the pause, got %v", err)
+	}
+}
+
+func TestRetryBudgetNeedsMinRequests(t *testing.T) {
+	budget := NewRetryBudget(0.1, time.Minute, 20, time.Minute)
+
+	budget.RecordRequest()
+	for i := 0; i < 5; i++ {
+		if err := budget.AllowRetry(); err != nil {
+			t.Fatalf("Expected retries below the minimum volume to be allowed, got %v", err)
+		}
+	}
+}
+
+func TestRetryBudgetStopsRetryStorm(t *testing.T) {
+	var hits atomic.Int32
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		// Drop the connection so the client retries
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer failing.Close()
+
+	client := NewProxyClient([]string{failing.URL}, 5*time.Second)
+	client.SetMaxRetries(10)
+	client.SetRetryBudget(NewRetryBudget(0.5, time.Minute, 2, time.Minute))
+
+	_, err := client.Get("http://listings.example/anketa1.htm")
+	if !errors.Is(err, ErrRetryBudgetExhausted) {
+		t.Fatalf("Expected the retry budget to stop the request, got %v", err)
+	}
+	// The first attempt and one retry fit in a 50% budget; the second retry trips it
+	if got := hits.Load(); got != 2 {
+		t.Errorf("Expected 2 attempts to reach the proxy, got %d", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.GetCtx(ctx, "http://listings.example/anketa2.htm"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected new requests to wait out the pause, got %v", err)
+	}
+	if got := hits.Load(); got != 2 {
+		t.Errorf("Expected no attempts while paused, got %d", got)
+	}
+}
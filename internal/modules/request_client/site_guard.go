@@ -0,0 +1,375 @@
+package request_client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SiteState is the crawl state of a target site
+type SiteState string
+
+const (
+	// SiteActive means requests to the site are sent normally
+	SiteActive SiteState = "active"
+	// SitePaused means every proxy was blocked and requests are refused until the cool-down ends
+	SitePaused SiteState = "paused"
+	// SiteProbing means the cool-down ended and single probe requests are let through to test the ban
+	SiteProbing SiteState = "probing"
+)
+
+// ErrSitePaused is matched by errors returned while a site is paused or probing
+var ErrSitePaused = errors.New("site crawling paused")
+
+// SitePausedError is returned instead of sending a request to a banned site
+type SitePausedError struct {
+	Site    string
+	State   SiteState
+	RetryAt time.Time
+}
+
+// Error implements the error interface
+func (e *SitePausedError) Error() string {
+	return fmt.Sprintf("site %s is %s until %s", e.Site, e.State, e.RetryAt.Format(time.RFC3339))
+}
+
+// Is makes errors.Is(err, ErrSitePaused) match
+func (e *SitePausedError) Is(target error) bool {
+	return target == ErrSitePaused
+}
+
+// SiteBanEvent describes a site state transition, delivered to the change handler
+type SiteBanEvent struct {
+	Site   string    `json:"site"`
+	State  SiteState `json:"state"`
+	Until  time.Time `json:"until,omitempty"`
+	Reason string    `json:"reason"`
+}
+
+// blockStatusCodes are responses treated as the site refusing a proxy
+var blockStatusCodes = map[int]bool{
+	http.StatusForbidden:          true,
+	http.StatusTooManyRequests:    true,
+	http.StatusServiceUnavailable: true,
+}
+
+// siteOutcome is a response of a site, kept for its block rate
+type siteOutcome struct {
+	at      time.Time
+	blocked bool
+}
+
+// siteBan holds the ban bookkeeping of one site
+type siteBan struct {
+	state         SiteState
+	blocked       map[string]time.Time // proxy -> last block response
+	outcomes      []siteOutcome        // responses within the window, oldest first
+	pausedUntil   time.Time
+	nextProbe     time.Time
+	probeInFlight bool
+	probeOK       int
+}
+
+// SiteGuard pauses crawling of a site once all proxies are blocked by it, or once the share of
+// blocked responses trips the block rate breaker, then resumes through a trickle of probe requests
+// so that proxy budget is not burned against a site-wide ban
+type SiteGuard struct {
+	mutex          sync.Mutex
+	cooldown       time.Duration
+	window         time.Duration
+	probeInterval  time.Duration
+	probeSuccesses int
+	blockRate      float64 // share of blocked responses within the window that pauses the site, 0 disables
+	minRequests    int     // responses within the window before the block rate counts
+	sites          map[string]*siteBan
+	onChange       func(SiteBanEvent)
+	now            func() time.Time
+}
+
+// NewSiteGuard creates a guard. A site is paused for cooldown (or the site's Retry-After, if longer)
+// when every proxy got a block response within window; after that one probe is allowed every
+// probeInterval and probeSuccesses consecutive unblocked probes resume normal crawling.
+func NewSiteGuard(cooldown, window, probeInterval time.Duration, probeSuccesses int) *SiteGuard {
+	if probeSuccesses < 1 {
+		probeSuccesses = 1
+	}
+
+	return &SiteGuard{
+		cooldown:       cooldown,
+		window:         window,
+		probeInterval:  probeInterval,
+		probeSuccesses: probeSuccesses,
+		sites:          make(map[string]*siteBan),
+		now:            time.Now,
+	}
+}
+
+// SetBlockRate pauses a site once at least rate of its responses within the window were blocked,
+// counting block statuses and the block pages reported with ObserveBlockPage. The rate is only
+// checked once the window holds minRequests responses, so a few early blocks do not pause a site.
+// A rate of 0 disables the breaker.
+func (g *SiteGuard) SetBlockRate(rate float64, minRequests int) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if minRequests < 1 {
+		minRequests = 1
+	}
+	g.blockRate = rate
+	g.minRequests = minRequests
+}
+
+// SetChangeHandler sets a callback invoked on every site state transition
+func (g *SiteGuard) SetChangeHandler(handler func(SiteBanEvent)) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.onChange = handler
+}
+
+// State returns the current crawl state of a site
+func (g *SiteGuard) State(site string) SiteState {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if ban, exists := g.sites[site]; exists {
+		return ban.state
+	}
+	return SiteActive
+}
+
+// Allow returns a *SitePausedError if a request to the site must not be sent now
+func (g *SiteGuard) Allow(site string) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	ban := g.siteFor(site)
+	now := g.now()
+
+	if ban.state == SitePaused {
+		if now.Before(ban.pausedUntil) {
+			return &SitePausedError{Site: site, State: SitePaused, RetryAt: ban.pausedUntil}
+		}
+		ban.state = SiteProbing
+		ban.nextProbe = now
+		ban.probeOK = 0
+	}
+
+	if ban.state == SiteProbing {
+		if ban.probeInFlight || now.Before(ban.nextProbe) {
+			return &SitePausedError{Site: site, State: SiteProbing, RetryAt: ban.nextProbe}
+		}
+		ban.probeInFlight = true
+		ban.nextProbe = now.Add(g.probeInterval)
+	}
+
+	return nil
+}
+
+// Observe records the outcome of a request sent through proxy. statusCode is 0 for transport
+// errors, totalProxies is the number of proxies that could have been used for the site.
+func (g *SiteGuard) Observe(site, proxy string, totalProxies, statusCode int, retryAfter time.Duration) {
+	g.notify(g.observe(site, proxy, totalProxies, statusCode, retryAfter))
+}
+
+// ObserveBlockPage records that the last response of a site, already observed with a 200, was a
+// block page such as a challenge or a CAPTCHA. A probe answered with one pauses the site again.
+func (g *SiteGuard) ObserveBlockPage(site, reason string) {
+	g.notify(g.observeBlockPage(site, reason))
+}
+
+// notify passes a transition event, if any, to the change handler
+func (g *SiteGuard) notify(event *SiteBanEvent) {
+	if event == nil {
+		return
+	}
+
+	g.mutex.Lock()
+	handler := g.onChange
+	g.mutex.Unlock()
+
+	if handler != nil {
+		handler(*event)
+	}
+}
+
+// observe updates the site state and returns the transition event, if any
+func (g *SiteGuard) observe(site, proxy string, totalProxies, statusCode int, retryAfter time.Duration) *SiteBanEvent {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	ban := g.siteFor(site)
+	now := g.now()
+	blocked := blockStatusCodes[statusCode]
+
+	switch ban.state {
+	case SiteProbing:
+		ban.probeInFlight = false
+		if blocked {
+			return g.pause(site, ban, now, retryAfter, fmt.Sprintf("probe blocked with status %d", statusCode))
+		}
+		if statusCode == 0 {
+			return nil
+		}
+
+		ban.probeOK++
+		if ban.probeOK < g.probeSuccesses {
+			return nil
+		}
+
+		ban.state = SiteActive
+		ban.blocked = make(map[string]time.Time)
+		return &SiteBanEvent{Site: site, State: SiteActive, Reason: fmt.Sprintf("%d probes succeeded", ban.probeOK)}
+
+	case SiteActive:
+		if statusCode != 0 {
+			g.recordOutcome(ban, now, blocked)
+		}
+		if !blocked {
+			if statusCode != 0 {
+				delete(ban.blocked, proxy)
+			}
+			return nil
+		}
+
+		ban.blocked[proxy] = now
+		for p, at := range ban.blocked {
+			if now.Sub(at) > g.window {
+				delete(ban.blocked, p)
+			}
+		}
+
+		if totalProxies < 1 {
+			totalProxies = 1
+		}
+		if len(ban.blocked) >= totalProxies {
+			return g.pause(site, ban, now, retryAfter, fmt.Sprintf("all %d proxies blocked, last status %d", totalProxies, statusCode))
+		}
+		return g.checkBlockRate(site, ban, now, retryAfter)
+	}
+
+	return nil
+}
+
+// observeBlockPage updates the site state after a block page and returns the transition event, if any
+func (g *SiteGuard) observeBlockPage(site, reason string) *SiteBanEvent {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	ban := g.siteFor(site)
+	now := g.now()
+
+	switch ban.state {
+	case SiteProbing:
+		return g.pause(site, ban, now, 0, fmt.Sprintf("probe got a %s page", reason))
+
+	case SiteActive:
+		// The response was recorded as unblocked when its status came in, unless it was the probe
+		// that resumed the site
+		flipped := false
+		for i := len(ban.outcomes) - 1; i >= 0 && !flipped; i-- {
+			if !ban.outcomes[i].blocked {
+				ban.outcomes[i].blocked = true
+				flipped = true
+			}
+		}
+		if !flipped {
+			g.recordOutcome(ban, now, true)
+		}
+		return g.checkBlockRate(site, ban, now, 0)
+	}
+
+	return nil
+}
+
+// recordOutcome adds a response to the block rate window of a site and forgets the responses that
+// left it. Must be called with the mutex held.
+func (g *SiteGuard) recordOutcome(ban *siteBan, now time.Time, blocked bool) {
+	if g.blockRate <= 0 {
+		return
+	}
+
+	ban.outcomes = append(ban.outcomes, siteOutcome{at: now, blocked: blocked})
+	expired := 0
+	for expired < len(ban.outcomes) && now.Sub(ban.outcomes[expired].at) > g.window {
+		expired++
+	}
+	ban.outcomes = ban.outcomes[expired:]
+}
+
+// checkBlockRate pauses a site whose share of blocked responses within the window reached the
+// block rate. Must be called with the mutex held.
+func (g *SiteGuard) checkBlockRate(site string, ban *siteBan, now time.Time, retryAfter time.Duration) *SiteBanEvent {
+	if g.blockRate <= 0 || len(ban.outcomes) < g.minRequests {
+		return nil
+	}
+
+	blocked := 0
+	for _, outcome := range ban.outcomes {
+		if outcome.blocked {
+			blocked++
+		}
+	}
+	rate := float64(blocked) / float64(len(ban.outcomes))
+	if rate < g.blockRate {
+		return nil
+	}
+	return g.pause(site, ban, now, retryAfter, fmt.Sprintf("block rate %.0f%% of %d responses", rate*100, len(ban.outcomes)))
+}
+
+// pause moves a site into the paused state. Must be called with the mutex held.
+func (g *SiteGuard) pause(site string, ban *siteBan, now time.Time, retryAfter time.Duration, reason string) *SiteBanEvent {
+	duration := g.cooldown
+	if retryAfter > duration {
+		duration = retryAfter
+	}
+
+	ban.state = SitePaused
+	ban.pausedUntil = now.Add(duration)
+	ban.probeInFlight = false
+	ban.probeOK = 0
+	ban.outcomes = nil
+
+	return &SiteBanEvent{Site: site, State: SitePaused, Until: ban.pausedUntil, Reason: reason}
+}
+
+// siteFor returns the ban bookkeeping of a site, creating it if needed. Must be called with the mutex held.
+func (g *SiteGuard) siteFor(site string) *siteBan {
+	ban, exists := g.sites[site]
+	if !exists {
+		ban = &siteBan{state: SiteActive, blocked: make(map[string]time.Time)}
+		g.sites[site] = ban
+	}
+	return ban
+}
+
+// siteKey returns the host a request URL targets
+func siteKey(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+// ParseRetryAfter parses a Retry-After header given in seconds or as an HTTP date, 0 when missing or invalid
+func ParseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+
+	return 0
+}
@@ -0,0 +1,151 @@
+package request_client
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSiteGuardPausesWhenAllProxiesBlocked(t *testing.T) {
+	now := time.Now()
+	guard := NewSiteGuard(10*time.Minute, 5*time.Minute, time.Minute, 2)
+	guard.now = func() time.Time { return now }
+
+	var events []SiteBanEvent
+	guard.SetChangeHandler(func(event SiteBanEvent) { events = append(events, event) })
+
+	guard.Observe("example.com", "http://p1", 2, 403, 0)
+	if guard.State("example.com") != SiteActive {
+		t.Errorf("Expected site to stay active with one blocked proxy, got %s", guard.State("example.com"))
+	}
+
+	guard.Observe("example.com", "http://p2", 2, 429, 20*time.Minute)
+	if guard.State("example.com") != SitePaused {
+		t.Fatalf("Expected site to be paused, got %s", guard.State("example.com"))
+	}
+	if len(events) != 1 || !events[0].Until.Equal(now.Add(20*time.Minute)) {
+		t.Errorf("Expected one pause event honoring Retry-After, got %+v", events)
+	}
+
+	if err := guard.Allow("example.com"); !errors.Is(err, ErrSitePaused) {
+		t.Errorf("Expected ErrSitePaused while paused, got %v", err)
+	}
+	if err := guard.Allow("other.com"); err != nil {
+		t.Errorf("Expected other sites to be unaffected, got %v", err)
+	}
+
+	// After the cool-down a single probe per interval is let through
+	now = now.Add(21 * time.Minute)
+	if err := guard.Allow("example.com"); err != nil {
+		t.Fatalf("Expected probe to be allowed, got %v", err)
+	}
+	if err := guard.Allow("example.com"); !errors.Is(err, ErrSitePaused) {
+		t.Errorf("Expected concurrent probe to be refused, got %v", err)
+	}
+	guard.Observe("example.com", "http://p1", 2, 200, 0)
+
+	now = now.Add(time.Minute)
+	if err := guard.Allow("example.com"); err != nil {
+		t.Fatalf("Expected second probe to be allowed, got %v", err)
+	}
+	guard.Observe("example.com", "http://p2", 2, 200, 0)
+
+	if guard.State("example.com") != SiteActive {
+		t.Errorf("Expected site to resume after 2 probes, got %s", guard.State("example.com"))
+	}
+	if len(events) != 2 || events[1].State != SiteActive {
+		t.Errorf("Expected resume event, got %+v", events)
+	}
+}
+
+func TestSiteGuardBlockedProbeRepauses(t *testing.T) {
+	now := time.Now()
+	guard := NewSiteGuard(time.Minute, time.Minute, time.Second, 1)
+	guard.now = func() time.Time { return now }
+
+	guard.Observe("example.com", "", 0, 503, 0)
+	now = now.Add(2 * time.Minute)
+	if err := guard.Allow("example.com"); err != nil {
+		t.Fatalf("Expected probe to be allowed, got %v", err)
+	}
+	guard.Observe("example.com", "", 0, 403, 0)
+
+	if guard.State("example.com") != SitePaused {
+		t.Errorf("Expected blocked probe to pause the site again, got %s", guard.State("example.com"))
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if got := ParseRetryAfter("120", now); got != 2*time.Minute {
+		t.Errorf("Expected 2m, got %s", got)
+	}
+	if got := ParseRetryAfter("Mon, 01 Jan 2024 12:05:00 GMT", now); got != 5*time.Minute {
+		t.Errorf("Expected 5m, got %s", got)
+	}
+	if got := ParseRetryAfter("soon", now); got != 0 {
+		t.Errorf("Expected 0 for invalid value, got %s", got)
+	}
+}
+
+func TestSiteGuardBlockRate(t *testing.T) {
+	now := time.Now()
+	guard := NewSiteGuard(10*time.Minute, time.Minute, time.Second, 1)
+	guard.SetBlockRate(0.5, 4)
+	guard.now = func() time.Time { return now }
+
+	var events []SiteBanEvent
+	guard.SetChangeHandler(func(event SiteBanEvent) { events = append(events, event) })
+
+	// Ten proxies, so the all-proxies check never trips
+	guard.Observe("example.com", "http://p1", 10, 200, 0)
+	guard.Observe("example.com", "http://p2", 10, 403, 0)
+	guard.Observe("example.com", "http://p3", 10, 200, 0)
+	if guard.State("example.com") != SiteActive {
+		t.Fatalf("Expected site to stay active below the minimum requests, got %s", guard.State("example.com"))
+	}
+
+	// Responses that left the window no longer count
+	now = now.Add(2 * time.Minute)
+	guard.Observe("example.com", "http://p4", 10, 200, 0)
+	guard.Observe("example.com", "http://p5", 10, 200, 0)
+	guard.Observe("example.com", "http://p6", 10, 200, 0)
+	guard.Observe("example.com", "http://p7", 10, 200, 0)
+	if guard.State("example.com") != SiteActive {
+		t.Fatalf("Expected site to stay active without blocks, got %s", guard.State("example.com"))
+	}
+
+	// Block pages served with a 200 count as blocked
+	guard.ObserveBlockPage("example.com", "captcha")
+	if guard.State("example.com") != SiteActive {
+		t.Fatalf("Expected site to stay active at 1 of 4 blocked, got %s", guard.State("example.com"))
+	}
+	guard.Observe("example.com", "http://p8", 10, 200, 0)
+	guard.ObserveBlockPage("example.com", "captcha")
+	guard.Observe("example.com", "http://p9", 10, 429, 0)
+	if guard.State("example.com") != SitePaused {
+		t.Fatalf("Expected site to be paused at 3 of 6 blocked, got %s", guard.State("example.com"))
+	}
+	if len(events) != 1 || !events[0].Until.Equal(now.Add(10*time.Minute)) {
+		t.Errorf("Expected one pause event, got %+v", events)
+	}
+}
+
+func TestSiteGuardBlockPageProbeRepauses(t *testing.T) {
+	now := time.Now()
+	guard := NewSiteGuard(time.Minute, time.Minute, time.Second, 2)
+	guard.now = func() time.Time { return now }
+
+	guard.Observe("example.com", "", 0, 403, 0)
+	now = now.Add(2 * time.Minute)
+	if err := guard.Allow("example.com"); err != nil {
+		t.Fatalf("Expected probe to be allowed, got %v", err)
+	}
+	guard.Observe("example.com", "", 0, 200, 0)
+	guard.ObserveBlockPage("example.com", "challenge")
+
+	if guard.State("example.com") != SitePaused {
+		t.Errorf("Expected a probe answered with a block page to pause the site again, got %s", guard.State("example.com"))
+	}
+}
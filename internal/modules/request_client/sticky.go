@@ -0,0 +1,143 @@
+package request_client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"sync"
+	"time"
+)
+
+// StickySession is a client that sends all its requests through the same proxy with the same
+// cookies and, unless user agents rotate per request, the same user agent, so a site sees one
+// visitor where the rotation of ProxyClient would show several. The first answered request pins
+// the proxy, chosen by the strategy of the client. A session whose proxy fails or is blocked starts
+// over at its next request, on another proxy with fresh cookies, rather than carrying its cookies
+// to a new IP.
+type StickySession struct {
+	client *ProxyClient
+
+	mutex    sync.Mutex
+	jar      http.CookieJar
+	proxy    string
+	pinned   bool
+	lastUsed time.Time
+}
+
+// NewSession returns a sticky session sending its requests through the proxies of the client. Its
+// cookies are kept in memory only and are not part of CookieState: a session lasts for a listing
+// and starts over with fresh cookies on every new proxy, so there is nothing to resume after a
+// restart.
+func (pc *ProxyClient) NewSession() *StickySession {
+	return &StickySession{client: pc, jar: newCookieJar(), lastUsed: time.Now()}
+}
+
+// newCookieJar returns an empty in-memory cookie jar
+func newCookieJar() http.CookieJar {
+	// cookiejar.New only fails on invalid options
+	jar, _ := cookiejar.New(nil)
+	return jar
+}
+
+// Proxy returns the proxy the session is pinned to, empty for a direct connection, and whether it
+// is pinned yet
+func (s *StickySession) Proxy() (string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.proxy, s.pinned
+}
+
+// GetCtx performs a GET request through the session
+func (s *StickySession) GetCtx(ctx context.Context, url string) (*http.Response, error) {
+	return s.DoCtx(ctx, "GET", url, nil, nil)
+}
+
+// PostCtx performs a POST request through the session
+func (s *StickySession) PostCtx(ctx context.Context, url, contentType string, body io.Reader) (*http.Response, error) {
+	return s.DoCtx(ctx, "POST", url, body, map[string]string{"Content-Type": contentType})
+}
+
+// DoCtx performs a request through the session like ProxyClient.DoCtx does through the rotation
+func (s *StickySession) DoCtx(ctx context.Context, method, url string, body io.Reader, headers map[string]string) (*http.Response, error) {
+	s.mutex.Lock()
+	r := route{jar: s.jar, pinned: s.pinned, proxy: s.proxy}
+	s.lastUsed = time.Now()
+	s.mutex.Unlock()
+
+	resp, proxy, err := s.client.do(ctx, method, url, body, headers, r)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch {
+	case ctx.Err() != nil:
+		// A cancelled request says nothing about the proxy
+	case err != nil || blockStatusCodes[resp.StatusCode]:
+		if r.pinned && s.jar == r.jar {
+			s.proxy, s.pinned, s.jar = "", false, newCookieJar()
+		}
+	case !s.pinned:
+		s.proxy, s.pinned = proxy, true
+	}
+	return resp, err
+}
+
+// sessionKey is the context key of the sticky session key
+type sessionKey struct{}
+
+// WithSessionKey returns a context whose requests through a ProxyClient with sticky sessions
+// enabled all go through the session of key, e.g. the page, image list and photos of one listing
+func WithSessionKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, sessionKey{}, key)
+}
+
+// SetStickySessions enables the sessions of WithSessionKey: every key gets a StickySession, dropped
+// once it had no request for ttl. A ttl of 0 disables them and requests ignore their session key.
+func (pc *ProxyClient) SetStickySessions(ttl time.Duration) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	pc.stickyTTL = ttl
+	pc.stickySessions = make(map[string]*StickySession)
+}
+
+// sessionFromContext returns the sticky session of the session key of ctx, nil when ctx has none
+// or sticky sessions are disabled
+func (pc *ProxyClient) sessionFromContext(ctx context.Context) *StickySession {
+	key, _ := ctx.Value(sessionKey{}).(string)
+	if key == "" {
+		return nil
+	}
+
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	if pc.stickyTTL <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	if now.Sub(pc.stickyPruned) > pc.stickyTTL {
+		pc.pruneSessions(now)
+	}
+
+	session, exists := pc.stickySessions[key]
+	if !exists {
+		session = pc.NewSession()
+		pc.stickySessions[key] = session
+	}
+	return session
+}
+
+// pruneSessions drops the sticky sessions idle for longer than their ttl. Must be called with the
+// mutex held.
+func (pc *ProxyClient) pruneSessions(now time.Time) {
+	for key, session := range pc.stickySessions {
+		session.mutex.Lock()
+		idle := now.Sub(session.lastUsed)
+		session.mutex.Unlock()
+		if idle > pc.stickyTTL {
+			delete(pc.stickySessions, key)
+		}
+	}
+	pc.stickyPruned = now
+}
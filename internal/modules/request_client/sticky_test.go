@@ -0,0 +1,119 @@
+package request_client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// visitorProxy is a fake forward proxy that sets a visitor cookie naming itself and records the
+// visitor cookie of every request it gets, "" for none. It answers with the status of *status.
+func visitorProxy(t *testing.T, name string, status *int) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var visitors []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		visitor := ""
+		if cookie, err := r.Cookie("visitor"); err == nil {
+			visitor = cookie.Value
+		}
+		visitors = append(visitors, visitor)
+		http.SetCookie(w, &http.Cookie{Name: "visitor", Value: name, Path: "/"})
+		w.WriteHeader(*status)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), visitors...)
+	}
+}
+
+func TestStickySessionKeepsProxyAndCookies(t *testing.T) {
+	statusA, statusB := http.StatusOK, http.StatusOK
+	proxyA, visitorsA := visitorProxy(t, "a", &statusA)
+	proxyB, visitorsB := visitorProxy(t, "b", &statusB)
+
+	client := NewProxyClient([]string{proxyA.URL, proxyB.URL}, 5*time.Second)
+	client.SetMaxRetries(1)
+	session := client.NewSession()
+
+	for _, path := range []string{"/anketa1.htm", "/anketa1.htm", "/images"} {
+		resp, err := session.GetCtx(context.Background(), "http://listings.example"+path)
+		if err != nil {
+			t.Fatalf("Expected %s to succeed, got %v", path, err)
+		}
+		resp.Body.Close()
+	}
+
+	if got := visitorsA(); len(got) != 3 || got[0] != "" || got[1] != "a" || got[2] != "a" {
+		t.Errorf("Expected every request through proxy a, with its cookie after the first, got %v", got)
+	}
+	if got := visitorsB(); len(got) != 0 {
+		t.Errorf("Expected no request through proxy b, got %v", got)
+	}
+	if proxy, pinned := session.Proxy(); !pinned || proxy != proxyA.URL {
+		t.Errorf("Expected the session pinned to proxy a, got %q", proxy)
+	}
+
+	// A blocked session starts over on another proxy with fresh cookies
+	statusA = http.StatusForbidden
+	resp, err := session.GetCtx(context.Background(), "http://listings.example/anketa2.htm")
+	if err != nil {
+		t.Fatalf("Expected the blocked response, got %v", err)
+	}
+	resp.Body.Close()
+	if _, pinned := session.Proxy(); pinned {
+		t.Errorf("Expected a blocked session to unpin")
+	}
+
+	resp, err = session.GetCtx(context.Background(), "http://listings.example/anketa2.htm")
+	if err != nil {
+		t.Fatalf("Expected the next request to succeed, got %v", err)
+	}
+	resp.Body.Close()
+	if got := visitorsB(); len(got) != 1 || got[0] != "" {
+		t.Errorf("Expected one request through proxy b without cookies, got %v", got)
+	}
+}
+
+func TestSessionKeyRoutesThroughStickySession(t *testing.T) {
+	status := http.StatusOK
+	proxyA, visitorsA := visitorProxy(t, "a", &status)
+	proxyB, visitorsB := visitorProxy(t, "b", &status)
+
+	client := NewProxyClient([]string{proxyA.URL, proxyB.URL}, 5*time.Second)
+	client.SetMaxRetries(1)
+
+	get := func(ctx context.Context) {
+		t.Helper()
+		resp, err := client.GetCtx(ctx, "http://listings.example/anketa1.htm")
+		if err != nil {
+			t.Fatalf("Expected the request to succeed, got %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	// Session keys are ignored until sticky sessions are enabled
+	keyed := WithSessionKey(context.Background(), "listing-1")
+	get(keyed)
+	get(keyed)
+	if len(visitorsA()) != 1 || len(visitorsB()) != 1 {
+		t.Fatalf("Expected round robin without sticky sessions, got %v and %v", visitorsA(), visitorsB())
+	}
+
+	client.SetStickySessions(time.Minute)
+	get(keyed)
+	get(keyed)
+	get(context.Background())
+	if got := visitorsA(); len(got) != 3 || got[1] != "" || got[2] != "a" {
+		t.Errorf("Expected the keyed requests through proxy a with its cookie, got %v", got)
+	}
+	if got := visitorsB(); len(got) != 2 {
+		t.Errorf("Expected the unkeyed request through proxy b, got %v", got)
+	}
+}
@@ -0,0 +1,306 @@
+package request_client
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Strategy determines the order in which proxies are tried for a request
+type Strategy string
+
+const (
+	// StrategyRoundRobin cycles through proxies in configuration order
+	StrategyRoundRobin Strategy = "round_robin"
+	// StrategyLeastLatency prefers the proxy with the lowest EWMA response time
+	StrategyLeastLatency Strategy = "least_latency"
+	// StrategyLeastErrors prefers the proxy with the lowest failure ratio
+	StrategyLeastErrors Strategy = "least_errors"
+	// StrategyRandom picks a uniformly random proxy
+	StrategyRandom Strategy = "random"
+	// StrategyWeighted picks a random proxy proportionally to its configured weight
+	StrategyWeighted Strategy = "weighted"
+)
+
+// latencyAlpha is the smoothing factor of the latency EWMA; higher values favour recent samples
+const latencyAlpha = 0.3
+
+// ParseStrategy converts a configuration value into a Strategy
+func ParseStrategy(value string) (Strategy, error) {
+	switch Strategy(strings.ToLower(strings.TrimSpace(value))) {
+	case "", StrategyRoundRobin:
+		return StrategyRoundRobin, nil
+	case StrategyLeastLatency:
+		return StrategyLeastLatency, nil
+	case StrategyLeastErrors:
+		return StrategyLeastErrors, nil
+	case StrategyRandom:
+		return StrategyRandom, nil
+	case StrategyWeighted:
+		return StrategyWeighted, nil
+	default:
+		return "", fmt.Errorf("unknown proxy strategy %q", value)
+	}
+}
+
+// proxyState holds the runtime statistics used by the selection strategies
+type proxyState struct {
+	requests    uint64
+	failures    uint64
+	selections  uint64
+	burns       uint64 // block responses that took the proxy out of rotation for a site
+	latencyEWMA time.Duration
+
+	// Quarantine after consecutive failures, see health.go
+	consecutiveFailures int
+	backoff             uint // quarantines since the last success, doubling the next one
+	quarantines         uint64
+	quarantinedUntil    time.Time
+}
+
+// failureRatio returns the fraction of failed requests through the proxy
+func (s *proxyState) failureRatio() float64 {
+	if s.requests == 0 {
+		return 0
+	}
+	return float64(s.failures) / float64(s.requests)
+}
+
+// ProxyStats is a snapshot of the statistics collected for a single proxy
+type ProxyStats struct {
+	Proxy        string
+	Geo          string // country code, empty when unknown
+	Strategy     Strategy
+	Weight       int
+	Requests     uint64
+	Failures     uint64
+	Selections   uint64
+	Burns        uint64
+	FailureRatio float64
+	LatencyEWMA  time.Duration
+
+	ConsecutiveFailures int
+	Quarantines         uint64
+	QuarantinedUntil    time.Time // zero when the proxy is in rotation
+}
+
+// SetStrategy sets the proxy selection strategy
+func (pc *ProxyClient) SetStrategy(strategy Strategy) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	pc.strategy = strategy
+}
+
+// GetStrategy returns the active proxy selection strategy
+func (pc *ProxyClient) GetStrategy() Strategy {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	return pc.strategy
+}
+
+// SetWeights sets per-proxy weights used by the weighted strategy.
+// Weights are matched to proxies by position; missing or non-positive weights default to 1.
+func (pc *ProxyClient) SetWeights(weights []int) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	pc.weights = make([]int, len(pc.proxies))
+	for i := range pc.proxies {
+		pc.weights[i] = 1
+		if i < len(weights) && weights[i] > 0 {
+			pc.weights[i] = weights[i]
+		}
+	}
+}
+
+// GetProxyStats returns a snapshot of per-proxy statistics in configuration order
+func (pc *ProxyClient) GetProxyStats() []ProxyStats {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	now := time.Now()
+	result := make([]ProxyStats, len(pc.proxies))
+	for i, proxy := range pc.proxies {
+		state := pc.stateFor(proxy)
+		result[i] = ProxyStats{
+			Proxy:        proxy,
+			Geo:          pc.geoAt(i),
+			Strategy:     pc.strategy,
+			Weight:       pc.weightAt(i),
+			Requests:     state.requests,
+			Failures:     state.failures,
+			Selections:   state.selections,
+			Burns:        state.burns,
+			FailureRatio: state.failureRatio(),
+			LatencyEWMA:  state.latencyEWMA,
+
+			ConsecutiveFailures: state.consecutiveFailures,
+			Quarantines:         state.quarantines,
+		}
+		if state.quarantined(now) {
+			result[i].QuarantinedUntil = state.quarantinedUntil
+		}
+	}
+	return result
+}
+
+// activeFailureRatio is the failure ratio at or above which a proxy no longer counts as active
+const activeFailureRatio = 0.5
+
+// ActiveProxyCount returns how many proxies are active, i.e. are not quarantined and fail less
+// than half of their requests, and the total number of configured proxies
+func (pc *ProxyClient) ActiveProxyCount() (int, int) {
+	active := 0
+	for _, stats := range pc.GetProxyStats() {
+		if stats.QuarantinedUntil.IsZero() && stats.FailureRatio < activeFailureRatio {
+			active++
+		}
+	}
+	return active, pc.GetProxyCount()
+}
+
+// proxyOrder returns proxy indices in the order they should be tried for the next request.
+// The first index is the strategy's pick; the remaining ones are fallbacks.
+func (pc *ProxyClient) proxyOrder() []int {
+	return pc.selectOrder(pc.strategyOrder())
+}
+
+// selectOrder counts the first proxy of order as selected and returns order
+func (pc *ProxyClient) selectOrder(order []int) []int {
+	if len(order) == 0 {
+		return order
+	}
+
+	pc.mutex.Lock()
+	pc.stateFor(pc.proxies[order[0]]).selections++
+	pc.mutex.Unlock()
+
+	return order
+}
+
+// strategyOrder returns every proxy index ordered by the active strategy
+func (pc *ProxyClient) strategyOrder() []int {
+	n := len(pc.proxies)
+	if n == 0 {
+		return nil
+	}
+
+	var order []int
+	switch pc.GetStrategy() {
+	case StrategyLeastLatency:
+		order = pc.sortedOrder(func(a, b *proxyState) bool {
+			// Unmeasured proxies go first so every proxy gets a latency sample
+			if (a.requests == 0) != (b.requests == 0) {
+				return a.requests == 0
+			}
+			return a.latencyEWMA < b.latencyEWMA
+		})
+	case StrategyLeastErrors:
+		order = pc.sortedOrder(func(a, b *proxyState) bool {
+			return a.failureRatio() < b.failureRatio()
+		})
+	case StrategyRandom:
+		order = rand.Perm(n)
+	case StrategyWeighted:
+		order = pc.rotatedOrder(pc.weightedPick())
+	default:
+		order = pc.rotatedOrder(pc.getNextProxyIndex())
+	}
+	return order
+}
+
+// rotatedOrder returns all proxy indices starting at start and wrapping around
+func (pc *ProxyClient) rotatedOrder(start int) []int {
+	n := len(pc.proxies)
+	order := make([]int, n)
+	for i := 0; i < n; i++ {
+		order[i] = (start + i) % n
+	}
+	return order
+}
+
+// sortedOrder returns proxy indices stably sorted by the given comparison of their stats
+func (pc *ProxyClient) sortedOrder(less func(a, b *proxyState) bool) []int {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	order := make([]int, len(pc.proxies))
+	states := make([]proxyState, len(pc.proxies))
+	for i, proxy := range pc.proxies {
+		order[i] = i
+		states[i] = *pc.stateFor(proxy)
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return less(&states[order[i]], &states[order[j]])
+	})
+	return order
+}
+
+// weightedPick selects a proxy index with probability proportional to its weight
+func (pc *ProxyClient) weightedPick() int {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	total := 0
+	for i := range pc.proxies {
+		total += pc.weightAt(i)
+	}
+
+	target := rand.Intn(total)
+	for i := range pc.proxies {
+		target -= pc.weightAt(i)
+		if target < 0 {
+			return i
+		}
+	}
+	return 0
+}
+
+// weightAt returns the weight of the proxy at index i. Must be called with the mutex held.
+func (pc *ProxyClient) weightAt(i int) int {
+	if i < len(pc.weights) && pc.weights[i] > 0 {
+		return pc.weights[i]
+	}
+	return 1
+}
+
+// stateFor returns the stats entry for a proxy, creating it if needed. Must be called with the mutex held.
+func (pc *ProxyClient) stateFor(proxy string) *proxyState {
+	state, exists := pc.stats[proxy]
+	if !exists {
+		state = &proxyState{}
+		pc.stats[proxy] = state
+	}
+	return state
+}
+
+// recordResult updates the statistics and health of a proxy after a single attempt
+func (pc *ProxyClient) recordResult(proxy string, latency time.Duration, err error) {
+	if proxy == "" {
+		return
+	}
+
+	pc.mutex.Lock()
+	state := pc.stateFor(proxy)
+	state.requests++
+	if err != nil {
+		state.failures++
+	} else if state.latencyEWMA == 0 {
+		state.latencyEWMA = latency
+	} else {
+		state.latencyEWMA = time.Duration(latencyAlpha*float64(latency) + (1-latencyAlpha)*float64(state.latencyEWMA))
+	}
+	event := pc.recordHealth(proxy, state, err != nil, time.Now())
+	handler := pc.onQuarantine
+	pc.mutex.Unlock()
+
+	if event != nil {
+		log.Warn("Proxy quarantined", "proxy", RedactProxy(proxy), "failures", event.Failures, "until", event.Until.Format(time.RFC3339))
+		if handler != nil {
+			handler(*event)
+		}
+	}
+}
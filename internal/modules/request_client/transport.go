@@ -0,0 +1,111 @@
+package request_client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	xproxy "golang.org/x/net/proxy"
+)
+
+// Proxy URL schemes the client dials through. socks5 resolves target hosts locally and
+// socks5h lets the proxy resolve them, like curl.
+const (
+	SchemeHTTP    = "http"
+	SchemeHTTPS   = "https"
+	SchemeSOCKS5  = "socks5"
+	SchemeSOCKS5H = "socks5h"
+)
+
+// defaultSOCKSPort is used for SOCKS5 proxies given without a port
+const defaultSOCKSPort = "1080"
+
+// proxyDialTimeout bounds connecting to a SOCKS5 proxy
+const proxyDialTimeout = 10 * time.Second
+
+// ParseProxyURL parses a proxy list entry. Entries without a scheme (host:port) are HTTP proxies;
+// schemes other than http, https, socks5 and socks5h are rejected.
+func ParseProxyURL(proxy string) (*url.URL, error) {
+	if !strings.Contains(proxy, "://") {
+		proxy = SchemeHTTP + "://" + proxy
+	}
+
+	parsed, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL %s: %w", RedactProxy(proxy), err)
+	}
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+
+	switch parsed.Scheme {
+	case SchemeHTTP, SchemeHTTPS, SchemeSOCKS5, SchemeSOCKS5H:
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q in %s", parsed.Scheme, RedactProxy(proxy))
+	}
+	if parsed.Hostname() == "" {
+		return nil, fmt.Errorf("proxy URL %s has no host", RedactProxy(proxy))
+	}
+	return parsed, nil
+}
+
+// newProxyTransport returns a transport sending requests through proxy: HTTP(S) proxies through
+// the standard CONNECT support, SOCKS5 proxies through a SOCKS5 dialer authenticating with the
+// URL's user and password
+func newProxyTransport(proxy string) (*http.Transport, error) {
+	parsed, err := ParseProxyURL(proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	switch parsed.Scheme {
+	case SchemeSOCKS5, SchemeSOCKS5H:
+		dial, err := socksDialer(parsed)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Transport{DialContext: dial}, nil
+	default:
+		return &http.Transport{Proxy: http.ProxyURL(parsed)}, nil
+	}
+}
+
+// socksDialer returns a dial function connecting through a SOCKS5 proxy
+func socksDialer(proxy *url.URL) (func(ctx context.Context, network, addr string) (net.Conn, error), error) {
+	var auth *xproxy.Auth
+	if proxy.User != nil {
+		password, _ := proxy.User.Password()
+		auth = &xproxy.Auth{User: proxy.User.Username(), Password: password}
+	}
+
+	address := proxy.Host
+	if proxy.Port() == "" {
+		address = net.JoinHostPort(proxy.Hostname(), defaultSOCKSPort)
+	}
+
+	dialer, err := xproxy.SOCKS5("tcp", address, auth, &net.Dialer{Timeout: proxyDialTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create socks5 dialer for %s: %w", RedactProxy(proxy.String()), err)
+	}
+	contextDialer := dialer.(xproxy.ContextDialer)
+
+	if proxy.Scheme == SchemeSOCKS5H {
+		return contextDialer.DialContext, nil
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if len(ips) == 0 {
+			return nil, fmt.Errorf("no addresses found for %s", host)
+		}
+		return contextDialer.DialContext(ctx, network, net.JoinHostPort(ips[0].IP.String(), port))
+	}, nil
+}
@@ -0,0 +1,154 @@
+package request_client
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strings"
+)
+
+// UserAgentMode decides how long a (proxy, site) session keeps its user agent
+type UserAgentMode string
+
+const (
+	// UserAgentSticky keeps one user agent per (proxy, site) session until the pair is burned
+	UserAgentSticky UserAgentMode = "sticky"
+	// UserAgentRotate takes the next user agent of the pool on every request
+	UserAgentRotate UserAgentMode = "rotate"
+)
+
+// ParseUserAgentMode converts a configuration value into a UserAgentMode
+func ParseUserAgentMode(value string) (UserAgentMode, error) {
+	switch UserAgentMode(strings.ToLower(strings.TrimSpace(value))) {
+	case "", UserAgentSticky:
+		return UserAgentSticky, nil
+	case UserAgentRotate:
+		return UserAgentRotate, nil
+	default:
+		return "", fmt.Errorf("unknown user agent mode %q", value)
+	}
+}
+
+// DefaultUserAgents is the built-in user agent pool, used unless SetUserAgents replaces it
+var DefaultUserAgents = []string{
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/138.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/138.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:140.0) Gecko/20100101 Firefox/140.0",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.5 Safari/605.1.15",
+	"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/137.0.0.0 Safari/537.36",
+}
+
+// acceptLanguages are the Accept-Language values randomized sessions pick from
+var acceptLanguages = []string{
+	"en-US,en;q=0.9,ru;q=0.8",
+	"ru-RU,ru;q=0.9,en-US;q=0.8,en;q=0.7",
+	"ru,en;q=0.9",
+	"en-GB,en;q=0.9,ru;q=0.8",
+	"ru-RU,ru;q=0.9",
+}
+
+// acceptNonChromium is the Accept header of Firefox and Safari, which do not list the image formats
+// of the Chromium default
+const acceptNonChromium = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+
+// LoadUserAgents reads a user agent pool with one user agent per line, skipping blank lines and
+// # comments; an empty path loads none
+func LoadUserAgents(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open user agents file: %w", err)
+	}
+	defer file.Close()
+
+	var agents []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			agents = append(agents, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read user agents file: %w", err)
+	}
+	if len(agents) == 0 {
+		return nil, fmt.Errorf("user agents file %s has no user agents", path)
+	}
+	return agents, nil
+}
+
+// SetUserAgents replaces the user agent pool; an empty pool restores DefaultUserAgents. Existing
+// sessions start over with the new pool.
+func (pc *ProxyClient) SetUserAgents(agents []string) {
+	if len(agents) == 0 {
+		agents = DefaultUserAgents
+	}
+
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	pc.userAgents = append([]string(nil), agents...)
+	pc.sessions = make(map[pairKey]*session)
+	pc.nextUserAgent = 0
+}
+
+// SetUserAgentMode sets whether sessions keep their user agent or rotate it on every request
+func (pc *ProxyClient) SetUserAgentMode(mode UserAgentMode) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	pc.userAgentMode = mode
+}
+
+// SetRandomizeHeaders sets whether every session also picks a random Accept-Language, drops DNT
+// at random and sends the Accept header of its user agent's browser
+func (pc *ProxyClient) SetRandomizeHeaders(randomize bool) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	pc.randomizeHeaders = randomize
+}
+
+// identityFor returns the user agent of the (proxy, site) session and the header values replacing
+// the defaults for it; an empty value drops the header. In rotate mode every call starts a new
+// session.
+func (pc *ProxyClient) identityFor(proxy, site string) (string, map[string]string) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	key := pairKey{proxy, site}
+	if pc.userAgentMode == UserAgentRotate {
+		delete(pc.sessions, key)
+	}
+	s := pc.sessionFor(key)
+	userAgent := pc.userAgents[s.userAgent]
+	if s.language < 0 {
+		return userAgent, nil
+	}
+
+	headers := map[string]string{
+		"Accept-Language": acceptLanguages[s.language],
+		"Dnt":             "",
+	}
+	if s.dnt {
+		headers["Dnt"] = "1"
+	}
+	if !strings.Contains(userAgent, "Chrome/") {
+		headers["Accept"] = acceptNonChromium
+	}
+	return userAgent, headers
+}
+
+// newSession starts a session with the next user agent in rotation and, when randomizing headers,
+// a random header fingerprint. Must be called with the mutex held.
+func (pc *ProxyClient) newSession() *session {
+	s := &session{userAgent: pc.nextUserAgent, language: -1}
+	pc.nextUserAgent = (pc.nextUserAgent + 1) % len(pc.userAgents)
+	if pc.randomizeHeaders {
+		s.language = rand.IntN(len(acceptLanguages))
+		s.dnt = rand.IntN(2) == 0
+	}
+	return s
+}
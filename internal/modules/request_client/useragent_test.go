@@ -0,0 +1,110 @@
+package request_client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// headerServer is a fake forward proxy recording the headers of every request
+func headerServer(t *testing.T) (*httptest.Server, func() []http.Header) {
+	var mu sync.Mutex
+	var seen []http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.Header.Clone())
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []http.Header {
+		mu.Lock()
+		defer mu.Unlock()
+		return seen
+	}
+}
+
+func TestUserAgentModes(t *testing.T) {
+	server, seen := headerServer(t)
+	agents := []string{"agent-a", "agent-b", "agent-c"}
+
+	sticky := NewProxyClient([]string{server.URL}, 5*time.Second)
+	sticky.SetUserAgents(agents)
+	rotating := NewProxyClient([]string{server.URL}, 5*time.Second)
+	rotating.SetUserAgents(agents)
+	rotating.SetUserAgentMode(UserAgentRotate)
+
+	for _, client := range []*ProxyClient{sticky, rotating} {
+		for i := 0; i < 3; i++ {
+			resp, err := client.Get("http://listings.example/")
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			resp.Body.Close()
+		}
+	}
+
+	headers := seen()
+	for i, expected := range []string{"agent-a", "agent-a", "agent-a", "agent-a", "agent-b", "agent-c"} {
+		if got := headers[i].Get("User-Agent"); got != expected {
+			t.Errorf("Request %d: expected %s, got %s", i, expected, got)
+		}
+	}
+}
+
+func TestRandomizedHeadersKeepOverrides(t *testing.T) {
+	server, seen := headerServer(t)
+
+	client := NewProxyClient([]string{server.URL}, 5*time.Second)
+	client.SetUserAgents([]string{"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:140.0) Gecko/20100101 Firefox/140.0"})
+	client.SetRandomizeHeaders(true)
+	client.SetHeaderProfiles([]HeaderProfile{{Site: "pinned.example", Headers: map[string]string{"Accept-Language": "de-DE"}}})
+
+	for _, url := range []string{"http://listings.example/", "http://pinned.example/"} {
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	headers := seen()
+	if headers[0].Get("Accept") != acceptNonChromium {
+		t.Errorf("Expected the Firefox Accept header, got %q", headers[0].Get("Accept"))
+	}
+	language := headers[0].Get("Accept-Language")
+	known := false
+	for _, value := range acceptLanguages {
+		known = known || value == language
+	}
+	if !known {
+		t.Errorf("Expected a randomized Accept-Language, got %q", language)
+	}
+	if headers[1].Get("Accept-Language") != "de-DE" {
+		t.Errorf("Expected the profile to win over the fingerprint, got %q", headers[1].Get("Accept-Language"))
+	}
+}
+
+func TestLoadUserAgents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agents.txt")
+	os.WriteFile(path, []byte("# desktop\nagent-a\n\n  agent-b  \n"), 0o644)
+
+	agents, err := LoadUserAgents(path)
+	if err != nil {
+		t.Fatalf("Failed to load user agents: %v", err)
+	}
+	if len(agents) != 2 || agents[0] != "agent-a" || agents[1] != "agent-b" {
+		t.Errorf("Expected 2 user agents, got %q", agents)
+	}
+
+	os.WriteFile(path, []byte("# nothing\n"), 0o644)
+	if _, err := LoadUserAgents(path); err == nil {
+		t.Errorf("Expected an empty file to be rejected")
+	}
+	if _, err := ParseUserAgentMode("sometimes"); err == nil {
+		t.Errorf("Expected an unknown mode to be rejected")
+	}
+}
@@ -0,0 +1,226 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule decides when a job runs
+type Schedule interface {
+	// Next returns when a job that last started at last is due again; last is zero when the job
+	// never ran. A time before now means the job is due at once, the zero time that it is never due.
+	Next(last, now time.Time) time.Time
+	// String returns the specification the schedule was parsed from
+	String() string
+}
+
+// cronShortcuts are the named schedules accepted in place of a cron expression
+var cronShortcuts = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+// ParseSchedule parses a schedule specification: an interval ("10m", "@every 10m"), a named
+// schedule (@hourly, @daily, @weekly, @monthly) or a five-field cron expression
+// ("minute hour day-of-month month day-of-week", e.g. "30 3 * * *"). Cron times are UTC.
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("empty schedule")
+	}
+
+	if every, ok := strings.CutPrefix(spec, "@every "); ok {
+		return parseInterval(spec, strings.TrimSpace(every))
+	}
+	if expression, ok := cronShortcuts[spec]; ok {
+		return parseCron(spec, expression)
+	}
+	if len(strings.Fields(spec)) == 1 {
+		return parseInterval(spec, spec)
+	}
+	return parseCron(spec, spec)
+}
+
+// intervalSchedule runs a job a fixed time after its last run started; a job that never ran is due at once
+type intervalSchedule struct {
+	spec     string
+	interval time.Duration
+}
+
+// parseInterval parses the duration value of an interval schedule
+func parseInterval(spec, value string) (Schedule, error) {
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid schedule %q: interval must be positive", spec)
+	}
+	return &intervalSchedule{spec: spec, interval: interval}, nil
+}
+
+// Next returns last plus the interval, or now when the job never ran
+func (s *intervalSchedule) Next(last, now time.Time) time.Time {
+	if last.IsZero() {
+		return now
+	}
+	return last.Add(s.interval)
+}
+
+// String returns the specification of the schedule
+func (s *intervalSchedule) String() string {
+	return s.spec
+}
+
+// cronSchedule runs a job at the minutes matching a cron expression. A job that never ran waits
+// for the next match, so a daily job does not run on every fresh start; a run missed while the
+// process was down is caught up once.
+type cronSchedule struct {
+	spec                                   string
+	minutes, hours, days, months, weekdays uint64 // bit i set when value i matches
+	anyDay, anyWeekday                     bool
+}
+
+// cronFields are the bounds of the five cron fields, in order
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// parseCron parses a five-field cron expression
+func parseCron(spec, expression string) (Schedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("invalid schedule %q: expected %d cron fields, got %d", spec, len(cronFields), len(fields))
+	}
+
+	sets := make([]uint64, len(fields))
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %s: %w", spec, cronFields[i].name, err)
+		}
+		sets[i] = set
+	}
+
+	// Sunday is both 0 and 7
+	weekdays := sets[4]
+	if weekdays&(1<<7) != 0 {
+		weekdays |= 1
+	}
+
+	return &cronSchedule{
+		spec:       spec,
+		minutes:    sets[0],
+		hours:      sets[1],
+		days:       sets[2],
+		months:     sets[3],
+		weekdays:   weekdays,
+		anyDay:     fields[2] == "*",
+		anyWeekday: fields[4] == "*",
+	}, nil
+}
+
+// parseCronField parses a comma-separated list of values, ranges ("1-5") and steps ("*/15", "0-30/10")
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			parsed, err := strconv.Atoi(stepPart)
+			if err != nil || parsed <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepPart)
+			}
+			step = parsed
+		}
+
+		low, high := min, max
+		if rangePart != "*" {
+			lowPart, highPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = strconv.Atoi(lowPart); err != nil {
+				return 0, fmt.Errorf("invalid value %q", lowPart)
+			}
+			high = low
+			if isRange {
+				if high, err = strconv.Atoi(highPart); err != nil {
+					return 0, fmt.Errorf("invalid value %q", highPart)
+				}
+			} else if hasStep {
+				high = max
+			}
+		}
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("%q outside %d-%d", part, min, max)
+		}
+
+		for value := low; value <= high; value += step {
+			set |= 1 << value
+		}
+	}
+	return set, nil
+}
+
+// maxCronSearch bounds the search for the next match of expressions that never match, e.g. February 30
+const maxCronSearch = 5 * 366 * 24 * time.Hour
+
+// Next returns the first matching minute after last, or after now when the job never ran; zero when
+// no minute matches
+func (s *cronSchedule) Next(last, now time.Time) time.Time {
+	after := last
+	if after.IsZero() {
+		after = now
+	}
+
+	t := after.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxCronSearch)
+	for t.Before(limit) {
+		switch {
+		case s.months&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case s.hours&(1<<t.Hour()) == 0:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case s.minutes&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// matchesDay applies the cron day rule: when both the day of month and the day of week are
+// restricted, a day matching either runs the job
+func (s *cronSchedule) matchesDay(t time.Time) bool {
+	day := s.days&(1<<t.Day()) != 0
+	weekday := s.weekdays&(1<<int(t.Weekday())) != 0
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return weekday
+	case s.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+// String returns the specification of the schedule
+func (s *cronSchedule) String() string {
+	return s.spec
+}
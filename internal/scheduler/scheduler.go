@@ -0,0 +1,346 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+	"github.com/gregor-tokarev/hoe_parser/internal/logger"
+	"github.com/gregor-tokarev/hoe_parser/internal/metrics"
+)
+
+// log is the component logger of the package
+var log = logger.Component("scheduler")
+
+// ErrJobNotFound is returned for job names no job was added under
+var ErrJobNotFound = errors.New("scheduled job not found")
+
+// Job is a task run on a schedule
+type Job struct {
+	Name     string
+	Group    string // jobs of one group never run at the same time, e.g. the crawls of one site
+	Schedule Schedule
+	Run      func(ctx context.Context) error
+}
+
+// JobState is the part of a job's state carried over restarts
+type JobState struct {
+	LastStarted  time.Time `json:"last_started"`
+	LastFinished time.Time `json:"last_finished"`
+	LastError    string    `json:"last_error,omitempty"`
+	Paused       bool      `json:"paused"`
+}
+
+// JobStatus describes a job for the API
+type JobStatus struct {
+	Name     string    `json:"name"`
+	Group    string    `json:"group,omitempty"`
+	Schedule string    `json:"schedule"`
+	Running  bool      `json:"running"`
+	NextRun  time.Time `json:"next_run"` // zero while paused
+	JobState
+}
+
+// Scheduler runs jobs on their schedules. A job never overlaps itself: a run that takes longer
+// than the interval delays the next one.
+type Scheduler struct {
+	store StateStore // nil when the state is not persisted
+
+	mutex  sync.Mutex
+	jobs   []*scheduledJob
+	groups map[string]chan struct{} // held by the running job of each group
+
+	saveMutex sync.Mutex // keeps an older state from overwriting a newer one
+}
+
+// scheduledJob is a job and its state
+type scheduledJob struct {
+	job     Job
+	state   JobState
+	running bool
+	cancel  context.CancelFunc // cancels the current run
+	wake    chan struct{}      // signalled when the job is paused or resumed
+}
+
+// NewScheduler creates a scheduler persisting job state to store (nil keeps it in memory only)
+func NewScheduler(store StateStore) *Scheduler {
+	return &Scheduler{store: store, groups: make(map[string]chan struct{})}
+}
+
+// Add registers a job; add every job before calling Run
+func (s *Scheduler) Add(job Job) error {
+	if job.Name == "" || job.Schedule == nil || job.Run == nil {
+		return fmt.Errorf("scheduled job needs a name, a schedule and a run function")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, existing := range s.jobs {
+		if existing.job.Name == job.Name {
+			return fmt.Errorf("scheduled job %s already added", job.Name)
+		}
+	}
+	if job.Group != "" && s.groups[job.Group] == nil {
+		s.groups[job.Group] = make(chan struct{}, 1)
+	}
+	s.jobs = append(s.jobs, &scheduledJob{job: job, wake: make(chan struct{}, 1)})
+	return nil
+}
+
+// Run restores the persisted job state and runs the jobs until ctx is done, then waits for the
+// running jobs to return
+func (s *Scheduler) Run(ctx context.Context) {
+	s.restore(ctx)
+
+	s.mutex.Lock()
+	jobs := append([]*scheduledJob(nil), s.jobs...)
+	s.mutex.Unlock()
+
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.loop(ctx, job)
+		}()
+	}
+	wg.Wait()
+}
+
+// restore applies the persisted state to the jobs with the same name
+func (s *Scheduler) restore(ctx context.Context) {
+	if s.store == nil {
+		return
+	}
+
+	states, err := s.store.Load(ctx)
+	if err != nil {
+		log.WarnContext(ctx, "Failed to load scheduler state", "error", err)
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, job := range s.jobs {
+		if state, ok := states[job.job.Name]; ok {
+			job.state = state
+		}
+	}
+}
+
+// loop waits for each due time of a job and runs it
+func (s *Scheduler) loop(ctx context.Context, job *scheduledJob) {
+	for {
+		s.mutex.Lock()
+		due := s.nextRun(job)
+		s.mutex.Unlock()
+
+		fired, err := s.wait(ctx, job, due)
+		if err != nil {
+			return
+		}
+		if !fired {
+			continue
+		}
+		if !s.acquire(ctx, job.job.Group) {
+			return
+		}
+		s.execute(ctx, job)
+		s.release(job.job.Group)
+	}
+}
+
+// nextRun returns when job is due, zero while it is paused or never due. Call with the mutex held.
+func (s *Scheduler) nextRun(job *scheduledJob) time.Time {
+	if job.state.Paused {
+		return time.Time{}
+	}
+	return job.job.Schedule.Next(job.state.LastStarted, clock.Now())
+}
+
+// wait blocks until due and reports true, or until the job is paused or resumed and reports
+// false so its due time is computed again. A zero due time waits for a pause or resume only.
+func (s *Scheduler) wait(ctx context.Context, job *scheduledJob, due time.Time) (bool, error) {
+	var fire <-chan time.Time
+	if !due.IsZero() {
+		timer := time.NewTimer(due.Sub(clock.Now()))
+		defer timer.Stop()
+		fire = timer.C
+	}
+
+	select {
+	case <-fire:
+		return true, nil
+	case <-job.wake:
+		return false, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// acquire waits until no other job of group runs, reporting false when ctx is done first
+func (s *Scheduler) acquire(ctx context.Context, group string) bool {
+	if group == "" {
+		return true
+	}
+	select {
+	case s.groups[group] <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// release lets the next job of group run
+func (s *Scheduler) release(group string) {
+	if group != "" {
+		<-s.groups[group]
+	}
+}
+
+// execute runs job once and records the outcome. A run cut short by Pause or by shutdown does
+// not count, so the job is due again as soon as it is resumed or the process restarts.
+func (s *Scheduler) execute(ctx context.Context, job *scheduledJob) {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.mutex.Lock()
+	if job.state.Paused || ctx.Err() != nil {
+		// Paused or shut down while waiting for its due time or for its group
+		s.mutex.Unlock()
+		return
+	}
+	previous := job.state
+	started := clock.Now()
+	job.running = true
+	job.cancel = cancel
+	job.state.LastStarted = started
+	s.mutex.Unlock()
+	s.save()
+
+	log.InfoContext(ctx, "Starting scheduled job", "job", job.job.Name)
+	err := job.job.Run(runCtx)
+
+	s.mutex.Lock()
+	job.running = false
+	job.cancel = nil
+	result := "ok"
+	switch {
+	case err != nil && runCtx.Err() != nil:
+		result = "cancelled"
+		paused := job.state.Paused
+		job.state = previous
+		job.state.Paused = paused
+	case err != nil:
+		result = "failed"
+		job.state.LastFinished = clock.Now()
+		job.state.LastError = err.Error()
+	default:
+		job.state.LastFinished = clock.Now()
+		job.state.LastError = ""
+	}
+	s.mutex.Unlock()
+	s.save()
+
+	metrics.ScheduledRuns.WithLabelValues(job.job.Name, result).Inc()
+	if result == "failed" {
+		log.WarnContext(ctx, "Scheduled job failed", "job", job.job.Name, "error", err)
+		return
+	}
+	log.InfoContext(ctx, "Scheduled job finished", "job", job.job.Name, "result", result, "duration", clock.Now().Sub(started).Round(time.Second))
+}
+
+// Pause stops running a job until it is resumed, cancelling its current run. The paused state
+// is persisted, so the job stays paused over restarts.
+func (s *Scheduler) Pause(name string) error {
+	return s.setPaused(name, true)
+}
+
+// Resume runs a paused job on its schedule again; a job that became due while paused runs at once
+func (s *Scheduler) Resume(name string) error {
+	return s.setPaused(name, false)
+}
+
+// setPaused pauses or resumes the named job
+func (s *Scheduler) setPaused(name string, paused bool) error {
+	s.mutex.Lock()
+	job := s.find(name)
+	if job == nil {
+		s.mutex.Unlock()
+		return fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+	job.state.Paused = paused
+	if paused && job.cancel != nil {
+		job.cancel()
+	}
+	s.mutex.Unlock()
+
+	select {
+	case job.wake <- struct{}{}:
+	default:
+	}
+	s.save()
+
+	if paused {
+		log.Info("Paused scheduled job", "job", name)
+	} else {
+		log.Info("Resumed scheduled job", "job", name)
+	}
+	return nil
+}
+
+// find returns the named job, or nil. Call with the mutex held.
+func (s *Scheduler) find(name string) *scheduledJob {
+	for _, job := range s.jobs {
+		if job.job.Name == name {
+			return job
+		}
+	}
+	return nil
+}
+
+// Jobs returns the status of every job in the order they were added
+func (s *Scheduler) Jobs() []JobStatus {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	statuses := make([]JobStatus, len(s.jobs))
+	for i, job := range s.jobs {
+		statuses[i] = JobStatus{
+			Name:     job.job.Name,
+			Group:    job.job.Group,
+			Schedule: job.job.Schedule.String(),
+			Running:  job.running,
+			NextRun:  s.nextRun(job),
+			JobState: job.state,
+		}
+	}
+	return statuses
+}
+
+// save persists the state of every job; failures are logged, the jobs keep running
+func (s *Scheduler) save() {
+	if s.store == nil {
+		return
+	}
+
+	s.saveMutex.Lock()
+	defer s.saveMutex.Unlock()
+
+	s.mutex.Lock()
+	states := make(map[string]JobState, len(s.jobs))
+	for _, job := range s.jobs {
+		states[job.job.Name] = job.state
+	}
+	s.mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.store.Save(ctx, states); err != nil {
+		log.Warn("Failed to save scheduler state", "error", err)
+	}
+}
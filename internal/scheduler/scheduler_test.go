@@ -0,0 +1,178 @@
+package scheduler
+
+import (
+	"context"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseScheduleNext(t *testing.T) {
+	now := time.Date(2025, 3, 14, 15, 30, 20, 0, time.UTC) // a Friday
+	last := time.Date(2025, 3, 14, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		spec     string
+		last     time.Time
+		expected time.Time
+	}{
+		{"10m", time.Time{}, now},
+		{"10m", last, last.Add(10 * time.Minute)},
+		{"@every 1h30m", last, last.Add(90 * time.Minute)},
+		{"@daily", time.Time{}, time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"@daily", last, time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Time{}, time.Date(2025, 3, 14, 15, 45, 0, 0, time.UTC)},
+		{"30 3 * * *", last, time.Date(2025, 3, 15, 3, 30, 0, 0, time.UTC)},
+		{"0 9-17/4 * * 1-5", last, time.Date(2025, 3, 14, 13, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Time{}, time.Date(2025, 3, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * 1", time.Time{}, time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		schedule, err := ParseSchedule(tt.spec)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.spec, err)
+			continue
+		}
+		if next := schedule.Next(tt.last, now); !next.Equal(tt.expected) {
+			t.Errorf("%s: expected next run %s, got %s", tt.spec, tt.expected, next)
+		}
+	}
+
+	for _, spec := range []string{"", "0s", "-5m", "soon", "* * * *", "60 * * * *", "0 0 0 * *", "*/0 * * * *"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
+
+func TestSchedulerPauseResumePersists(t *testing.T) {
+	store := NewFileStateStore(filepath.Join(t.TempDir(), "scheduler.json"))
+	schedule, _ := ParseSchedule("1h")
+
+	var runs atomic.Int32
+	started := make(chan struct{}, 1)
+	run := func(ctx context.Context) error {
+		runs.Add(1)
+		started <- struct{}{}
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	crawls := NewScheduler(store)
+	if err := crawls.Add(Job{Name: "site:index", Group: "site", Schedule: schedule, Run: run}); err != nil {
+		t.Fatalf("Failed to add job: %v", err)
+	}
+	if err := crawls.Add(Job{Name: "site:index", Schedule: schedule, Run: run}); err == nil {
+		t.Errorf("Expected an error for a duplicate job name")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		crawls.Run(ctx)
+	}()
+
+	// A job that never ran is due at once
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Expected the job to start")
+	}
+	if status := crawls.Jobs()[0]; !status.Running {
+		t.Errorf("Expected the job to be running")
+	}
+
+	// Pausing cancels the run, which does not count as a run
+	if err := crawls.Pause("site:index"); err != nil {
+		t.Fatalf("Failed to pause job: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for crawls.Jobs()[0].Running && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	status := crawls.Jobs()[0]
+	if status.Running || !status.Paused || !status.NextRun.IsZero() || !status.LastStarted.IsZero() {
+		t.Errorf("Expected a paused job without runs, got %+v", status)
+	}
+	if err := crawls.Pause("site:missing"); err == nil {
+		t.Errorf("Expected an error for an unknown job")
+	}
+
+	cancel()
+	<-done
+
+	// The paused state survives a restart, and resuming runs the overdue job at once
+	restarted := NewScheduler(store)
+	restarted.Add(Job{Name: "site:index", Schedule: schedule, Run: run})
+	ctx, cancel = context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		restarted.Run(ctx)
+	}()
+	// The state is saved once more when the run is cancelled, before the temp dir is removed
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	select {
+	case <-started:
+		t.Fatalf("Expected the paused job not to run after a restart")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if err := restarted.Resume("site:index"); err != nil {
+		t.Fatalf("Failed to resume job: %v", err)
+	}
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Expected the resumed job to start")
+	}
+	if runs.Load() != 2 {
+		t.Errorf("Expected 2 runs, got %d", runs.Load())
+	}
+}
+
+func TestSchedulerGroupRunsOneJobAtATime(t *testing.T) {
+	schedule, _ := ParseSchedule("1h")
+
+	var running, overlaps, finished atomic.Int32
+	run := func(ctx context.Context) error {
+		if running.Add(1) > 1 {
+			overlaps.Add(1)
+		}
+		time.Sleep(50 * time.Millisecond)
+		running.Add(-1)
+		finished.Add(1)
+		return nil
+	}
+
+	crawls := NewScheduler(nil)
+	crawls.Add(Job{Name: "site:index", Group: "site", Schedule: schedule, Run: run})
+	crawls.Add(Job{Name: "site:rescrape", Group: "site", Schedule: schedule, Run: run})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go crawls.Run(ctx)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for finished.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if finished.Load() != 2 {
+		t.Fatalf("Expected both jobs to run, got %d runs", finished.Load())
+	}
+	if overlaps.Load() != 0 {
+		t.Errorf("Expected jobs of one group not to overlap")
+	}
+	for _, status := range crawls.Jobs() {
+		if status.LastFinished.IsZero() || !status.NextRun.Equal(status.LastStarted.Add(time.Hour)) {
+			t.Errorf("%s: expected the next run an hour after the last, got %+v", status.Name, status)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// StateStore persists the state of the scheduled jobs between runs
+type StateStore interface {
+	// Save replaces the stored state
+	Save(ctx context.Context, states map[string]JobState) error
+	// Load returns the stored state by job name, or nil when nothing was saved yet
+	Load(ctx context.Context) (map[string]JobState, error)
+}
+
+// FileStateStore keeps the job state in a JSON file
+type FileStateStore struct {
+	path string
+}
+
+// NewFileStateStore creates a store writing to path
+func NewFileStateStore(path string) *FileStateStore {
+	return &FileStateStore{path: path}
+}
+
+// Save writes the state to a temporary file and renames it over the old one, so a crash
+// mid-write never leaves a truncated file
+func (s *FileStateStore) Save(ctx context.Context, states map[string]JobState) error {
+	data, err := json.Marshal(states)
+	if err != nil {
+		return fmt.Errorf("failed to encode scheduler state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create scheduler state directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write scheduler state: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to replace scheduler state: %w", err)
+	}
+	return nil
+}
+
+// Load reads the state file
+func (s *FileStateStore) Load(ctx context.Context) (map[string]JobState, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scheduler state: %w", err)
+	}
+
+	var states map[string]JobState
+	if err := json.Unmarshal(data, &states); err != nil {
+		return nil, fmt.Errorf("failed to parse scheduler state: %w", err)
+	}
+	return states, nil
+}
@@ -0,0 +1,273 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/gregor-tokarev/hoe_parser/internal/metrics"
+	"gopkg.in/yaml.v3"
+)
+
+// Extraction holds the CSS selectors and label strings a listing page is parsed with. The
+// defaults match the intimcity markup; a site profile of the extraction config file overrides
+// any of them. The generic fallbacks scanning every table row stay built in.
+type Extraction struct {
+	// Selectors of single values, read from the text of the first match
+	Name       string `yaml:"name"`
+	Age        string `yaml:"age"`
+	Height     string `yaml:"height"`
+	Weight     string `yaml:"weight"`
+	BreastSize string `yaml:"breast_size"`
+	BodyType   string `yaml:"body_type"`
+	HairColor  string `yaml:"hair_color"`
+	City       string `yaml:"city"`
+
+	Phone         string `yaml:"phone"`          // phone link, read from a tel: href or its text
+	PricingTable  string `yaml:"pricing_table"`  // price table with one row per meeting type
+	ApartmentsRow int    `yaml:"apartments_row"` // row of the apartments prices, from 0
+	OutcallRow    int    `yaml:"outcall_row"`    // row of the outcall prices, from 0
+	Services      string `yaml:"services"`       // table whose links are the offered services
+	MetroLinks    string `yaml:"metro_links"`    // links naming a metro station each
+	DistrictLinks string `yaml:"district_links"` // links naming the district
+	Description   string `yaml:"description"`
+	LastUpdated   string `yaml:"last_updated"` // cells holding the update date, the last one is read
+
+	Labels ExtractionLabels `yaml:"labels"`
+}
+
+// ExtractionLabels are the strings that identify a value by its label or by page text. Labels of
+// two-column table rows are lowercased before matching unless noted otherwise.
+type ExtractionLabels struct {
+	Gender      []string `yaml:"gender"`       // whole label
+	Orientation []string `yaml:"orientation"`  // part of the label
+	Metro       []string `yaml:"metro"`        // part of the label, as written on the page
+	District    []string `yaml:"district"`     // part of the label, as written on the page
+	Salon       []string `yaml:"salon"`        // part of the label
+	ServiceArea []string `yaml:"service_area"` // part of a label that also names outcall
+
+	// Meeting types, matched in price table row labels and in the page text
+	Incall  []string `yaml:"incall"`
+	Outcall []string `yaml:"outcall"`
+	// IncallText also means incall in the page text of a page without a price table
+	IncallText []string `yaml:"incall_text"`
+
+	// Status banner phrases, in lowercase; vacation is checked first
+	Vacation    []string `yaml:"vacation"`
+	Unavailable []string `yaml:"unavailable"`
+}
+
+// DefaultExtraction returns the selectors and labels of the intimcity markup
+func DefaultExtraction() Extraction {
+	return Extraction{
+		Name:       "h1.breadcrumbs > span",
+		Age:        "#tdankage",
+		Height:     "#tdankhei",
+		Weight:     "#tdankwei",
+		BreastSize: "#tdankbre",
+		BodyType:   "#tdankcloth",
+		HairColor:  "#tdankinhc",
+		City:       "#tdankcity",
+
+		Phone:         "#tdmobphone a",
+		PricingTable:  "table.table-price table.table-price-inner",
+		ApartmentsRow: 2,
+		OutcallRow:    3,
+		Services:      "table.uslugi_block",
+		MetroLinks:    "a[href*='metro']",
+		DistrictLinks: "a[href*='district']",
+		Description:   "p.pnletter",
+		LastUpdated:   "tr.noprint td",
+
+		Labels: ExtractionLabels{
+			Gender:      []string{"пол"},
+			Orientation: []string{"ориентац"},
+			Metro:       []string{"Метро"},
+			District:    []string{"Район"},
+			Salon:       []string{"салон"},
+			ServiceArea: []string{"район", "зона"},
+
+			Incall:     []string{"апартаменты"},
+			Outcall:    []string{"выезд"},
+			IncallText: []string{"принимаю"},
+
+			Vacation:    []string{"в отпуске", "ушла в отпуск", "отпуск до", "на каникулах"},
+			Unavailable: []string{"временно не работа", "временно не принима", "временно недоступ", "временно неактив"},
+		},
+	}
+}
+
+// selectors returns the selectors of the extraction by their config key
+func (e Extraction) selectors() map[string]string {
+	selectors := make(map[string]string)
+	value := reflect.ValueOf(e)
+	for i := 0; i < value.NumField(); i++ {
+		if field := value.Field(i); field.Kind() == reflect.String {
+			selectors[value.Type().Field(i).Tag.Get("yaml")] = field.String()
+		}
+	}
+	return selectors
+}
+
+// validate checks that every selector is set and compiles
+func (e Extraction) validate() error {
+	for key, selector := range e.selectors() {
+		if selector == "" {
+			return fmt.Errorf("%s selector is empty", key)
+		}
+		if _, err := cascadia.Compile(selector); err != nil {
+			return fmt.Errorf("invalid %s selector %q: %w", key, selector, err)
+		}
+	}
+	if e.ApartmentsRow < 0 || e.OutcallRow < 0 {
+		return fmt.Errorf("price table rows must not be negative")
+	}
+	return nil
+}
+
+// extractionFile is the layout of the extraction config file, in YAML or JSON:
+//
+//	sites:
+//	  intimcity.gold:
+//	    age: "#tdankage"
+//	    labels:
+//	      orientation: [ориентац]
+type extractionFile struct {
+	Sites map[string]yaml.Node `yaml:"sites"`
+}
+
+// ParseExtractionConfig parses an extraction config file into one profile per site name. Each
+// profile starts from DefaultExtraction, so it only lists what differs; a label list replaces
+// the default list.
+func ParseExtractionConfig(data []byte) (map[string]Extraction, error) {
+	var file extractionFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse extraction config: %w", err)
+	}
+
+	profiles := make(map[string]Extraction, len(file.Sites))
+	for site, node := range file.Sites {
+		profile := DefaultExtraction()
+		if err := node.Decode(&profile); err != nil {
+			return nil, fmt.Errorf("failed to parse extraction profile of %s: %w", site, err)
+		}
+		if err := profile.validate(); err != nil {
+			return nil, fmt.Errorf("extraction profile of %s: %w", site, err)
+		}
+		profiles[site] = profile
+	}
+	return profiles, nil
+}
+
+// ExtractionProfiles holds the extraction profiles loaded from a config file and reloads them
+// when the file changes, so selector fixes apply without a rebuild or restart
+type ExtractionProfiles struct {
+	path string
+
+	mutex    sync.RWMutex
+	profiles map[string]Extraction
+	modTime  time.Time
+}
+
+// LoadExtractionProfiles reads the extraction config file at path
+func LoadExtractionProfiles(path string) (*ExtractionProfiles, error) {
+	profiles := &ExtractionProfiles{path: path}
+	if _, err := profiles.Reload(); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+// Profile returns the extraction of a site, DefaultExtraction when the site has no profile or
+// profiles is nil
+func (p *ExtractionProfiles) Profile(site string) Extraction {
+	if p == nil {
+		return DefaultExtraction()
+	}
+
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if profile, ok := p.profiles[site]; ok {
+		return profile
+	}
+	return DefaultExtraction()
+}
+
+// Reload reads the config file again when it was modified since the last load and reports
+// whether the profiles changed. A file that fails to parse leaves the current profiles in place.
+func (p *ExtractionProfiles) Reload() (bool, error) {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read extraction config: %w", err)
+	}
+
+	p.mutex.RLock()
+	unchanged := p.profiles != nil && info.ModTime().Equal(p.modTime)
+	p.mutex.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read extraction config: %w", err)
+	}
+	profiles, err := ParseExtractionConfig(data)
+	if err != nil {
+		return false, err
+	}
+
+	p.mutex.Lock()
+	p.profiles = profiles
+	p.modTime = info.ModTime()
+	p.mutex.Unlock()
+	return true, nil
+}
+
+// Watch checks the config file for changes every interval until ctx is done
+func (p *ExtractionProfiles) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := p.Reload()
+			if err != nil {
+				log.Warn("Failed to reload extraction config, keeping the current profiles", "path", p.path, "error", err)
+			} else if reloaded {
+				log.Info("Reloaded extraction config", "path", p.path)
+			}
+			if err != nil || reloaded {
+				metrics.ObserveExtractionReload(err)
+			}
+		}
+	}
+}
+
+// ExtractionConfigurable is implemented by adapters whose listing extraction can be configured
+// through an extraction config file
+type ExtractionConfigurable interface {
+	SetExtraction(profiles *ExtractionProfiles)
+}
+
+// ConfigureExtraction loads the extraction config file at path and hands it to every registered
+// adapter that supports it
+func ConfigureExtraction(path string) (*ExtractionProfiles, error) {
+	profiles, err := LoadExtractionProfiles(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, adapter := range DefaultRegistry.Adapters() {
+		if configurable, ok := adapter.(ExtractionConfigurable); ok {
+			configurable.SetExtraction(profiles)
+		}
+	}
+	return profiles, nil
+}
@@ -0,0 +1,114 @@
+package scraper
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestParseExtractionConfigOverlaysDefaults(t *testing.T) {
+	profiles, err := ParseExtractionConfig([]byte(`
+sites:
+  intimcity.gold:
+    age: ".age"
+    labels:
+      outcall: [выезд, выезжаю]
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+
+	profile := profiles["intimcity.gold"]
+	if profile.Age != ".age" {
+		t.Errorf("Expected the age selector from the file, got %q", profile.Age)
+	}
+	if profile.Height != DefaultExtraction().Height {
+		t.Errorf("Expected the default height selector, got %q", profile.Height)
+	}
+	if !reflect.DeepEqual(profile.Labels.Outcall, []string{"выезд", "выезжаю"}) {
+		t.Errorf("Expected the outcall labels from the file, got %v", profile.Labels.Outcall)
+	}
+	if !reflect.DeepEqual(profile.Labels.Incall, DefaultExtraction().Labels.Incall) {
+		t.Errorf("Expected the default incall labels, got %v", profile.Labels.Incall)
+	}
+}
+
+func TestParseExtractionConfigRejectsInvalidSelectors(t *testing.T) {
+	if _, err := ParseExtractionConfig([]byte(`{"sites": {"intimcity.gold": {"phone": "a[href"}}}`)); err == nil {
+		t.Errorf("Expected an invalid selector to be rejected")
+	}
+	if _, err := ParseExtractionConfig([]byte(`{"sites": {"intimcity.gold": {"city": ""}}}`)); err == nil {
+		t.Errorf("Expected an empty selector to be rejected")
+	}
+}
+
+func TestExampleExtractionConfig(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "deployments", "scraper", "extraction.example.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to read example config: %v", err)
+	}
+	profiles, err := ParseExtractionConfig(data)
+	if err != nil {
+		t.Fatalf("Failed to parse example config: %v", err)
+	}
+	if !reflect.DeepEqual(profiles["intimcity.gold"], DefaultExtraction()) {
+		t.Errorf("Expected the example config to list the built-in selectors and labels")
+	}
+}
+
+func TestExtractionProfilesReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "extraction.yaml")
+	os.WriteFile(path, []byte("sites:\n  intimcity.gold:\n    age: \".age\"\n"), 0o644)
+
+	profiles, err := LoadExtractionProfiles(path)
+	if err != nil {
+		t.Fatalf("Failed to load profiles: %v", err)
+	}
+	if reloaded, _ := profiles.Reload(); reloaded {
+		t.Errorf("Expected an unchanged file not to be reloaded")
+	}
+
+	// A broken edit keeps the loaded profiles
+	os.WriteFile(path, []byte("sites:\n  intimcity.gold:\n    age: \"[\"\n"), 0o644)
+	os.Chtimes(path, time.Now(), time.Now().Add(time.Minute))
+	if _, err := profiles.Reload(); err == nil {
+		t.Errorf("Expected an invalid file to fail")
+	}
+	if age := profiles.Profile("intimcity.gold").Age; age != ".age" {
+		t.Errorf("Expected the loaded profile to stay, got age selector %q", age)
+	}
+
+	os.WriteFile(path, []byte("sites:\n  intimcity.gold:\n    age: \".years\"\n"), 0o644)
+	os.Chtimes(path, time.Now(), time.Now().Add(2*time.Minute))
+	if reloaded, err := profiles.Reload(); !reloaded || err != nil {
+		t.Fatalf("Expected the fixed file to be reloaded, got %v", err)
+	}
+	if age := profiles.Profile("intimcity.gold").Age; age != ".years" {
+		t.Errorf("Expected the reloaded age selector, got %q", age)
+	}
+	if profile := profiles.Profile("other.site"); profile.Age != DefaultExtraction().Age {
+		t.Errorf("Expected the default profile for a site without one, got age selector %q", profile.Age)
+	}
+}
+
+func TestListingScraperUsesExtraction(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><body><span class="age">27</span></body></html>`))
+	if err != nil {
+		t.Fatalf("Failed to parse page: %v", err)
+	}
+
+	extraction := DefaultExtraction()
+	extraction.Age = "span.age"
+	listingScraper := NewListingScraper("https://b.intimcity.gold/anketa1.htm")
+	listingScraper.SetExtraction(extraction)
+
+	if age := listingScraper.ParseDocument(context.Background(), doc).PersonalInfo.Age; age != 27 {
+		t.Errorf("Expected age 27 from the configured selector, got %d", age)
+	}
+}
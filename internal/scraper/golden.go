@@ -0,0 +1,164 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/listingdiff"
+	"github.com/gregor-tokarev/hoe_parser/internal/service"
+	listing "github.com/gregor-tokarev/hoe_parser/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// goldenListingSuffix is the file name suffix of the expected listing next to a fixture page
+const goldenListingSuffix = ".listing.json"
+
+// GoldenListing is the full Listing a fixture page must parse to, with the URL it was saved from
+type GoldenListing struct {
+	URL     string          `json:"url"`
+	Listing json.RawMessage `json:"listing"` // protojson of the Listing
+}
+
+// GoldenFixture is a stored listing page with its golden listing
+type GoldenFixture struct {
+	Name       string
+	PagePath   string
+	GoldenPath string
+	Golden     GoldenListing
+}
+
+// LoadGoldenFixtures reads every <name>.html page in dir that has a <name>.listing.json file
+func LoadGoldenFixtures(dir string) ([]GoldenFixture, error) {
+	pages, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fixtures: %w", err)
+	}
+	sort.Strings(pages)
+
+	var fixtures []GoldenFixture
+	for _, page := range pages {
+		name := strings.TrimSuffix(filepath.Base(page), ".html")
+		fixture := GoldenFixture{Name: name, PagePath: page, GoldenPath: filepath.Join(dir, name+goldenListingSuffix)}
+
+		data, err := os.ReadFile(fixture.GoldenPath)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read golden listing of fixture %s: %w", name, err)
+		}
+		if err := json.Unmarshal(data, &fixture.Golden); err != nil {
+			return nil, fmt.Errorf("failed to parse golden listing of fixture %s: %w", name, err)
+		}
+		if fixture.Golden.URL == "" {
+			return nil, fmt.Errorf("golden listing of fixture %s has no url", name)
+		}
+		fixtures = append(fixtures, fixture)
+	}
+	return fixtures, nil
+}
+
+// CheckGolden parses the fixture page with parser and returns the fields that differ from the
+// golden listing, none when the whole Listing matches
+func CheckGolden(ctx context.Context, parser PageParser, fixture GoldenFixture) ([]listingdiff.Change, error) {
+	expected := &listing.Listing{}
+	if err := protojson.Unmarshal(fixture.Golden.Listing, expected); err != nil {
+		return nil, fmt.Errorf("failed to decode golden listing of fixture %s: %w", fixture.Name, err)
+	}
+
+	got, err := parseFixture(ctx, parser, fixture.PagePath, fixture.Golden.URL)
+	if err != nil {
+		return nil, err
+	}
+	return listingdiff.Diff(expected, got), nil
+}
+
+// UpdateGolden rewrites the golden listing of the fixture from the current extractor output.
+// Review the diff before committing it.
+func UpdateGolden(ctx context.Context, parser PageParser, fixture GoldenFixture) error {
+	return writeGolden(ctx, parser, fixture.PagePath, fixture.GoldenPath, fixture.Golden.URL)
+}
+
+// RecordFixture fetches a live listing page and saves it as the fixture <name>.html in dir, with
+// a golden listing parsed by the current extractor of its site. The page is stored as served, so
+// the fixture goes through the same decoding as a scrape. Review the golden listing against the
+// page before committing it.
+func RecordFixture(ctx context.Context, dir, name, rawURL string) (GoldenFixture, error) {
+	adapter, err := AdapterForURL(rawURL)
+	if err != nil {
+		return GoldenFixture{}, err
+	}
+	parser, ok := adapter.(PageParser)
+	if !ok {
+		return GoldenFixture{}, fmt.Errorf("site %s cannot parse stored pages", adapter.Name())
+	}
+
+	// The fixture is parsed without the response headers, like archived pages
+	page, _, err := service.FetchPage(ctx, rawURL)
+	if err != nil {
+		return GoldenFixture{}, fmt.Errorf("failed to fetch %s: %w", rawURL, err)
+	}
+
+	fixture := GoldenFixture{
+		Name:       name,
+		PagePath:   filepath.Join(dir, name+".html"),
+		GoldenPath: filepath.Join(dir, name+goldenListingSuffix),
+		Golden:     GoldenListing{URL: rawURL},
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return GoldenFixture{}, fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+	if err := os.WriteFile(fixture.PagePath, page, 0o644); err != nil {
+		return GoldenFixture{}, fmt.Errorf("failed to write fixture %s: %w", name, err)
+	}
+	if err := writeGolden(ctx, parser, fixture.PagePath, fixture.GoldenPath, rawURL); err != nil {
+		return GoldenFixture{}, err
+	}
+	return fixture, nil
+}
+
+// writeGolden parses the page at pagePath and writes its listing as golden file
+func writeGolden(ctx context.Context, parser PageParser, pagePath, goldenPath, rawURL string) error {
+	parsed, err := parseFixture(ctx, parser, pagePath, rawURL)
+	if err != nil {
+		return err
+	}
+
+	// protojson varies its whitespace on purpose, so the output is indented again for stable diffs
+	data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(parsed)
+	if err != nil {
+		return fmt.Errorf("failed to marshal listing of %s: %w", pagePath, err)
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		return fmt.Errorf("failed to marshal listing of %s: %w", pagePath, err)
+	}
+
+	golden, err := json.MarshalIndent(GoldenListing{URL: rawURL, Listing: compact.Bytes()}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal golden listing of %s: %w", pagePath, err)
+	}
+	if err := os.WriteFile(goldenPath, append(golden, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write golden listing %s: %w", goldenPath, err)
+	}
+	return nil
+}
+
+// parseFixture parses a stored page as the listing at rawURL
+func parseFixture(ctx context.Context, parser PageParser, pagePath, rawURL string) (*listing.Listing, error) {
+	page, err := os.ReadFile(pagePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture %s: %w", pagePath, err)
+	}
+	parsed, err := parser.ParseListingPage(ctx, rawURL, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %w", pagePath, err)
+	}
+	return parsed, nil
+}
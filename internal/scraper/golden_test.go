@@ -0,0 +1,62 @@
+package scraper
+
+import (
+	"context"
+	"flag"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden listings from the current extractor output")
+
+// TestGoldenListings parses every fixture page under testdata/conformance/<site> that has a
+// golden listing and requires the whole Listing to match it. Run with -update after an intended
+// extractor change and review the diff.
+func TestGoldenListings(t *testing.T) {
+	dirs, err := filepath.Glob(filepath.Join("testdata", "conformance", "*"))
+	if err != nil {
+		t.Fatalf("Failed to list fixture sites: %v", err)
+	}
+
+	checked := 0
+	for _, dir := range dirs {
+		site := filepath.Base(dir)
+		adapter, ok := AdapterByName(site)
+		if !ok {
+			t.Errorf("Expected an adapter for fixture site %s", site)
+			continue
+		}
+		parser, ok := adapter.(PageParser)
+		if !ok {
+			t.Errorf("Expected site %s to parse stored pages", site)
+			continue
+		}
+
+		fixtures, err := LoadGoldenFixtures(dir)
+		if err != nil {
+			t.Fatalf("Failed to load fixtures of %s: %v", site, err)
+		}
+		for _, fixture := range fixtures {
+			t.Run(site+"/"+fixture.Name, func(t *testing.T) {
+				if *updateGolden {
+					if err := UpdateGolden(context.Background(), parser, fixture); err != nil {
+						t.Fatalf("Failed to update golden listing: %v", err)
+					}
+					return
+				}
+
+				changes, err := CheckGolden(context.Background(), parser, fixture)
+				if err != nil {
+					t.Fatalf("Failed to check fixture: %v", err)
+				}
+				for _, change := range changes {
+					t.Errorf("Expected %s to be %q, got %q", change.Path, change.OldValue(), change.NewValue())
+				}
+			})
+			checked++
+		}
+	}
+	if checked == 0 {
+		t.Fatalf("Expected fixture pages with golden listings")
+	}
+}
@@ -1,25 +1,70 @@
 package scraper
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+	"github.com/gregor-tokarev/hoe_parser/internal/events"
+	"github.com/gregor-tokarev/hoe_parser/internal/logger"
+	"github.com/gregor-tokarev/hoe_parser/internal/metrics"
+	"github.com/gregor-tokarev/hoe_parser/internal/modules/request_client"
 	"github.com/gregor-tokarev/hoe_parser/internal/service"
 )
 
-// HomePageScraper handles scraping of intimcity.gold listings
+// log is the component logger of the package
+var log = logger.Component("scraper")
+
+// HomePageScraper crawls the index pages of a site for listing links: the pages of
+// intimcity.gold it reads itself, or any site's pages read through its adapter, see NewDiscovery
 type HomePageScraper struct {
-	baseURL string
+	baseURL  string
+	adapter  SiteAdapter // reads the index pages when set
+	progress ProgressFunc
+	delay    DelaySchedule
+	audit    AuditFunc
+	seen     SeenSet
+	bus      *events.Bus
+	cycles   atomic.Int64 // index crawl cycles started, numbering the cycles in progress reports
+
+	fingerprints        PageFingerprints // index page fingerprints of the previous cycle, see SetPageFingerprints
+	fingerprintSelector string
+	gate                Gate // holds index requests back while the crawl is paused
+
+	pageMutex  sync.Mutex
+	pagination Pagination     // how index page URLs are found, see SetPagination
+	nextPages  map[int]string // page URLs learned from next links, under PaginationNextLink
+}
+
+// Gate holds the crawl back while it is paused
+type Gate interface {
+	// Wait blocks while the crawl is paused and returns ctx.Err() if ctx is done first
+	Wait(ctx context.Context) error
+}
+
+// SeenSet remembers links already emitted by the monitoring loops
+type SeenSet interface {
+	// MarkSeen records key and reports whether it had not been seen before
+	MarkSeen(ctx context.Context, key string) (bool, error)
 }
 
+// ProgressFunc is called after each index page is processed during monitoring
+type ProgressFunc func(cycle, page, totalPages, links int, err error)
+
 // ListingLink represents a listing link with metadata
 type ListingLink struct {
-	URL   string
-	Title string
-	ID    string
+	URL          string
+	Title        string
+	ID           string
+	DiscoveredAt time.Time // when the link was seen on an index page
 }
 
 // NewHomePageScraper creates a new intimcity home page scraper
@@ -29,38 +74,137 @@ func NewHomePageScraper() *HomePageScraper {
 	}
 }
 
+// NewDiscovery creates an index crawl reading the index pages of adapter's site through
+// ScrapeIndex. Adapters implementing IndexPager bound full cycles to their page count, others are
+// read until a page without links; index fingerprints need an IndexDocumentScraper.
+func NewDiscovery(adapter SiteAdapter) *HomePageScraper {
+	scraper := NewHomePageScraper()
+	scraper.adapter = adapter
+	return scraper
+}
+
+// Site returns the source site key of the crawled index, e.g. intimcity.gold
+func (s *HomePageScraper) Site() string {
+	if s.adapter != nil {
+		return s.adapter.Name()
+	}
+	return intimcityDomain
+}
+
+// SetProgressFunc sets a callback reporting monitoring progress
+func (s *HomePageScraper) SetProgressFunc(progress ProgressFunc) {
+	s.progress = progress
+}
+
+// SetEventBus publishes a LinkDiscovered event for every link the monitoring loops emit
+func (s *HomePageScraper) SetEventBus(bus *events.Bus) {
+	s.bus = bus
+}
+
+// SetSeenSet makes the monitoring loops skip links already emitted and still remembered by seen
+func (s *HomePageScraper) SetSeenSet(seen SeenSet) {
+	s.seen = seen
+}
+
+// SetGate makes the index crawl wait for gate before every index page request
+func (s *HomePageScraper) SetGate(gate Gate) {
+	s.gate = gate
+}
+
+// waitForGate blocks while the crawl is paused, or until ctx is done
+func (s *HomePageScraper) waitForGate(ctx context.Context) {
+	if s.gate != nil {
+		s.gate.Wait(ctx)
+	}
+}
+
+// isNewLink reports whether a link should be emitted. Seen-set errors let the link through,
+// since a duplicate is cheaper than a missed listing.
+func (s *HomePageScraper) isNewLink(link ListingLink) bool {
+	if s.seen == nil {
+		return true
+	}
+
+	isNew, err := s.seen.MarkSeen(context.Background(), link.URL)
+	if err != nil {
+		log.Warn("Link dedup failed, emitting link", "url", link.URL, "error", err)
+		return true
+	}
+	return isNew
+}
+
+// BaseURL returns the site root being scraped
+func (s *HomePageScraper) BaseURL() string {
+	return s.baseURL
+}
+
+// ListingLink returns the link of the anketa with a numeric ID, for listings known by ID only
+func (s *HomePageScraper) ListingLink(id string) ListingLink {
+	if linker, ok := s.adapter.(ListingLinker); ok {
+		return linker.ListingLink(id)
+	}
+	return ListingLink{URL: s.baseURL + "/anketa" + id + ".htm", ID: id, DiscoveredAt: clock.Now()}
+}
+
+// reportProgress invokes the progress callback if one is set
+func (s *HomePageScraper) reportProgress(cycle, page, totalPages, links int, err error) {
+	if s.progress != nil {
+		s.progress(cycle, page, totalPages, links, err)
+	}
+}
+
+// waitIfSitePaused sleeps until a paused site may be retried, or until ctx is done, and reports
+// whether err was a pause
+func waitIfSitePaused(ctx context.Context, err error) bool {
+	var paused *request_client.SitePausedError
+	if !errors.As(err, &paused) {
+		return false
+	}
+
+	wait := time.Until(paused.RetryAt)
+	if wait < time.Second {
+		wait = time.Second
+	}
+	log.InfoContext(ctx, "Site paused, waiting before continuing", "site", paused.Site, "state", paused.State, "wait", wait.Round(time.Second))
+	sleepCtx(ctx, wait)
+	return true
+}
+
 // ScrapeAllListingLinks scrapes all pages and returns all listing links
-func (s *HomePageScraper) ScrapeAllListingLinks() ([]ListingLink, error) {
+func (s *HomePageScraper) ScrapeAllListingLinks(ctx context.Context) ([]ListingLink, error) {
 	var allLinks []ListingLink
 
 	// First, get the total number of pages
-	totalPages, err := s.getTotalPages()
+	totalPages, err := s.indexPages(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get total pages: %w", err)
 	}
 
-	fmt.Printf("Found %d total pages to scrape\n", totalPages)
+	log.InfoContext(ctx, "Found index pages to scrape", "pages", totalPages)
 
 	// Loop through all pages
-	for page := 1; page <= totalPages; page++ {
-		fmt.Printf("Scraping page %d/%d\n", page, totalPages)
+	for page := 1; page <= totalPages && !s.pastLastPage(page); page++ {
+		log.DebugContext(ctx, "Scraping index page", "page", page, "pages", totalPages)
 
-		links, err := s.scrapePageLinks(page)
+		links, err := s.scrapePageLinks(ctx, page)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		if err != nil {
-			fmt.Printf("Warning: failed to scrape page %d: %v\n", page, err)
+			log.WarnContext(ctx, "Failed to scrape index page", "page", page, "error", err)
 			continue
 		}
 
 		allLinks = append(allLinks, links...)
 	}
 
-	fmt.Printf("Total listing links collected: %d\n", len(allLinks))
+	log.InfoContext(ctx, "Collected listing links", "links", len(allLinks))
 	return allLinks, nil
 }
 
 // getTotalPages extracts the total number of pages from the main page
-func (s *HomePageScraper) getTotalPages() (int, error) {
-	doc, err := service.FetchAndParsePage(s.baseURL)
+func (s *HomePageScraper) getTotalPages(ctx context.Context) (int, error) {
+	doc, err := service.FetchAndParsePage(ctx, s.baseURL)
 	if err != nil {
 		return 0, err
 	}
@@ -112,48 +256,71 @@ func (s *HomePageScraper) getTotalPages() (int, error) {
 	return maxPage, nil
 }
 
-// scrapePageLinks extracts listing links from a specific page
-func (s *HomePageScraper) scrapePageLinks(pageNum int) ([]ListingLink, error) {
-	var pageURL string
-	if pageNum == 1 {
-		pageURL = s.baseURL
-	} else {
-		// Try different pagination URL patterns
-		pageURL = fmt.Sprintf("%s/?page=%d", s.baseURL, pageNum)
+// fetchIndexPage fetches and parses a specific index page. Under auto pagination it falls back
+// to the /pN format; it returns ErrNoMorePages past the last page of a next-link crawl.
+func (s *HomePageScraper) fetchIndexPage(ctx context.Context, pageNum int) (*goquery.Document, error) {
+	pageURL := s.pageURL(pageNum)
+	if pageURL == "" {
+		return nil, ErrNoMorePages
 	}
 
-	doc, err := service.FetchAndParsePage(pageURL)
-	if err != nil {
+	doc, err := service.FetchAndParsePage(ctx, pageURL)
+	if err != nil && ctx.Err() == nil && s.paginationSettings().Strategy == PaginationAuto {
 		// Try alternative pagination format
-		pageURL = fmt.Sprintf("%s/p%d", s.baseURL, pageNum)
-		doc, err = service.FetchAndParsePage(pageURL)
-		if err != nil {
-			return nil, err
-		}
+		doc, err = service.FetchAndParsePage(ctx, fmt.Sprintf("%s/p%d", s.baseURL, pageNum))
+	}
+	if ctx.Err() == nil {
+		metrics.ObservePage("index", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.learnNextPage(pageNum, doc)
+	return doc, nil
+}
+
+// normalizeHref converts a raw href into an absolute URL, returning "" for empty hrefs
+func (s *HomePageScraper) normalizeHref(href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" {
+		return ""
 	}
 
+	if strings.HasPrefix(href, "/") {
+		return s.baseURL + href
+	} else if !strings.HasPrefix(href, "http") {
+		return s.baseURL + "/" + href
+	}
+	return href
+}
+
+// scrapePageLinks extracts listing links from a specific page
+func (s *HomePageScraper) scrapePageLinks(ctx context.Context, pageNum int) ([]ListingLink, error) {
+	doc, err := s.fetchIndexPage(ctx, pageNum)
+	if err != nil {
+		return nil, err
+	}
+	return s.extractPageLinks(doc), nil
+}
+
+// extractPageLinks extracts listing links from a fetched index page
+func (s *HomePageScraper) extractPageLinks(doc *goquery.Document) []ListingLink {
 	var links []ListingLink
 
 	// Look for listing links - these typically contain profile/listing information
 	doc.Find("a").Each(func(i int, sel *goquery.Selection) {
-		href, exists := sel.Attr("href")
+		rawHref, exists := sel.Attr("href")
 		if !exists {
 			return
 		}
 
-		// Clean up the href
-		href = strings.TrimSpace(href)
+		// Clean up the href and make sure it's a full URL
+		href := s.normalizeHref(rawHref)
 		if href == "" {
 			return
 		}
 
-		// Make sure it's a full URL
-		if strings.HasPrefix(href, "/") {
-			href = s.baseURL + href
-		} else if !strings.HasPrefix(href, "http") {
-			href = s.baseURL + "/" + href
-		}
-
 		// Filter for listing links - look for patterns that indicate individual listings
 		if s.isListingLink(href) {
 			title := strings.TrimSpace(sel.Text())
@@ -166,9 +333,10 @@ func (s *HomePageScraper) scrapePageLinks(pageNum int) ([]ListingLink, error) {
 			id := s.extractIDFromURL(href)
 
 			link := ListingLink{
-				URL:   href,
-				Title: title,
-				ID:    id,
+				URL:          CanonicalListingURL(href),
+				Title:        title,
+				ID:           id,
+				DiscoveredAt: clock.Now(),
 			}
 
 			links = append(links, link)
@@ -176,9 +344,7 @@ func (s *HomePageScraper) scrapePageLinks(pageNum int) ([]ListingLink, error) {
 	})
 
 	// Remove duplicates
-	links = s.removeDuplicateLinks(links)
-
-	return links, nil
+	return s.removeDuplicateLinks(links)
 }
 
 // isListingLink determines if a URL is likely a listing page
@@ -257,43 +423,195 @@ func (s *HomePageScraper) removeDuplicateLinks(links []ListingLink) []ListingLin
 }
 
 // StartContinuousMonitoring starts continuous monitoring of all pages, sending new links to the channel
-// It loops through all pages, and when it reaches the last page, it starts over from the first page
-func (s *HomePageScraper) StartContinuousMonitoring(linkChan chan<- string) error {
-	// Get total pages once at the start
-	totalPages, err := s.getTotalPages()
-	if err != nil {
-		return fmt.Errorf("failed to get total pages: %w", err)
-	}
+// It loops through all pages, and when it reaches the last page, it starts over from the first page.
+// It returns ctx.Err() once ctx is done.
+func (s *HomePageScraper) StartContinuousMonitoring(ctx context.Context, linkChan chan<- string) error {
+	return s.monitorLinks(ctx, func(link ListingLink) {
+		select {
+		case linkChan <- link.URL:
+		case <-ctx.Done():
+		}
+	})
+}
 
-	fmt.Printf("Starting continuous monitoring of %d pages...\n", totalPages)
+// StartDiscoveryMonitoring works like StartContinuousMonitoring but sends full links,
+// including the time each link was discovered, so downstream stages can measure latency
+func (s *HomePageScraper) StartDiscoveryMonitoring(ctx context.Context, linkChan chan<- ListingLink) error {
+	return s.monitorLinks(ctx, sendLink(ctx, linkChan))
+}
 
-	cycleCount := 0
+// monitorLinks loops through all index pages until ctx is done, passing every new link to emit
+func (s *HomePageScraper) monitorLinks(ctx context.Context, emit func(ListingLink)) error {
+	log.InfoContext(ctx, "Starting continuous monitoring")
 
-	// Infinite loop through all pages
 	for {
-		cycleCount++
-		fmt.Printf("\n=== Starting cycle %d ===\n", cycleCount)
+		if err := s.discoveryCycle(ctx, emit, false, nil); err != nil {
+			return err
+		}
+	}
+}
 
-		// Loop through all pages in this cycle
-		for page := 1; page <= totalPages; page++ {
-			fmt.Printf("Monitoring page %d/%d (cycle %d)\n", page, totalPages, cycleCount)
+// RunDiscoveryCycle goes through every index page once, sending the links not emitted before to
+// the channel. It is the index crawl run by the scheduler.
+func (s *HomePageScraper) RunDiscoveryCycle(ctx context.Context, linkChan chan<- ListingLink) error {
+	return s.discoveryCycle(ctx, sendLink(ctx, linkChan), false, nil)
+}
 
-			links, err := s.scrapePageLinks(page)
-			if err != nil {
-				fmt.Printf("Warning: failed to scrape page %d: %v\n", page, err)
-				continue
-			}
+// RunRescrapeCycle goes through every index page once, sending every listed link to the channel,
+// including links emitted before, so every listing is scraped again
+func (s *HomePageScraper) RunRescrapeCycle(ctx context.Context, linkChan chan<- ListingLink) error {
+	return s.discoveryCycle(ctx, sendLink(ctx, linkChan), true, nil)
+}
+
+// RunFreshCycle goes through the first pages of the index only, where new listings appear,
+// sending the links not emitted before, so new listings are found without reading the whole
+// catalog. It reads past pages while the last page read had new links, since a burst of new
+// listings pushes others further down, but never past maxPages.
+func (s *HomePageScraper) RunFreshCycle(ctx context.Context, linkChan chan<- ListingLink, pages, maxPages int) error {
+	pages = max(pages, 1)
+	return s.discoveryCycle(ctx, sendLink(ctx, linkChan), false, &freshScan{pages: pages, maxPages: max(maxPages, pages)})
+}
+
+// freshScan limits a discovery cycle to the first pages of the index, see RunFreshCycle
+type freshScan struct {
+	pages    int // pages always read
+	maxPages int // pages read at most
+}
+
+// done reports whether a fresh cycle stops after page, which had newLinks links not emitted
+// before. A full cycle, with a nil scan, never stops early.
+func (f *freshScan) done(page, newLinks int) bool {
+	return f != nil && page >= f.pages && (newLinks == 0 || page >= f.maxPages)
+}
+
+// sendLink returns an emit function sending links to linkChan until ctx is done
+func sendLink(ctx context.Context, linkChan chan<- ListingLink) func(ListingLink) {
+	return func(link ListingLink) {
+		select {
+		case linkChan <- link:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// discoveryCycle goes through every index page once, or the first pages when fresh is set,
+// passing the links to emit: every link when all is set, otherwise only links not emitted before.
+// It returns ctx.Err() once ctx is done.
+func (s *HomePageScraper) discoveryCycle(ctx context.Context, emit func(ListingLink), all bool, fresh *freshScan) error {
+	s.waitForGate(ctx)
+
+	// A fresh cycle never reads as far as the last page, so it does not look for it. Without a
+	// page count, 0, the cycle reads until a page has no links.
+	var totalPages int
+	if fresh != nil {
+		totalPages = fresh.maxPages
+	} else {
+		var err error
+		if totalPages, err = s.crawlPages(ctx); err != nil {
+			return fmt.Errorf("failed to get total pages: %w", err)
+		}
+	}
 
-			// Send new links to channel
-			for _, link := range links {
-				linkChan <- link.URL
+	cycle := int(s.cycles.Add(1))
+	cycleStartedAt := clock.Now()
+	log.InfoContext(ctx, "Starting cycle", "cycle", cycle, "pages", totalPages, "all_links", all, "fresh", fresh != nil)
+
+	stop := false
+	for page := 1; (totalPages == 0 || page <= totalPages) && !stop && !s.pastLastPage(page); page++ {
+		log.DebugContext(ctx, "Monitoring index page", "page", page, "pages", totalPages, "cycle", cycle)
+
+		request := s.politeWait(ctx, cycle, cycleStartedAt, page)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		links, fingerprint, err := s.readIndexPage(ctx, page)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// Pages that did not change since the previous cycle only hold links already emitted
+		unchanged := err == nil && !all && s.pageUnchanged(ctx, page, fingerprint)
+		if unchanged {
+			links = nil
+		}
+
+		s.finishRequest(request, len(links), err)
+		if waitIfSitePaused(ctx, err) {
+			page-- // retry the same page once the site is reachable again
+			continue
+		}
+		if errors.Is(err, ErrNoMorePages) {
+			break
+		}
+		s.reportProgress(cycle, page, totalPages, len(links), err)
+		if err != nil {
+			log.WarnContext(ctx, "Failed to scrape index page", "page", page, "cycle", cycle, "error", err)
+			stop = fresh.done(page, 0) || totalPages == 0
+			continue
+		}
+		if unchanged {
+			log.DebugContext(ctx, "Index page unchanged, skipping link extraction", "page", page, "cycle", cycle)
+			stop = fresh.done(page, 0)
+			continue
+		}
+
+		// Send links downstream, skipping links emitted in earlier cycles unless all are wanted;
+		// re-sent links stay remembered so the index crawl keeps skipping them
+		newLinks := 0
+		for _, link := range links {
+			isNew := s.isNewLink(link)
+			if isNew {
+				newLinks++
 			}
+			if isNew || all {
+				s.bus.Publish(events.LinkDiscovered{URL: link.URL, SourceID: link.ID, DiscoveredAt: link.DiscoveredAt})
+				emit(link)
+			}
+		}
+		stop = fresh.done(page, newLinks) || (totalPages == 0 && len(links) == 0)
+		// A cycle cancelled while emitting reads the page again next time
+		if ctx.Err() == nil {
+			s.storeFingerprint(ctx, page, fingerprint)
+		}
+	}
+	return nil
+}
+
+// readIndexPage reads the listing links of an index page, with the fingerprint of the page when
+// fingerprints are kept and the page is an HTML document
+func (s *HomePageScraper) readIndexPage(ctx context.Context, page int) ([]ListingLink, string, error) {
+	if s.adapter == nil {
+		doc, err := s.fetchIndexPage(ctx, page)
+		if err != nil {
+			return nil, "", err
 		}
+		return s.extractPageLinks(doc), s.fingerprint(doc), nil
+	}
+
+	if documents, ok := s.adapter.(IndexDocumentScraper); ok && s.fingerprints != nil {
+		doc, links, err := documents.ScrapeIndexDocument(ctx, page)
+		if err != nil {
+			return nil, "", err
+		}
+		return links, s.fingerprint(doc), nil
+	}
+	links, err := s.adapter.ScrapeIndex(ctx, page)
+	return links, "", err
+}
+
+// crawlPages returns how many index pages a full cycle reads, 0 when the adapter cannot tell
+func (s *HomePageScraper) crawlPages(ctx context.Context) (int, error) {
+	if s.adapter == nil {
+		return s.indexPages(ctx)
+	}
+	if pager, ok := s.adapter.(IndexPager); ok {
+		return pager.IndexPages(ctx)
 	}
+	return 0, nil
 }
 
 // StartContinuousMonitoringWithCallback starts continuous monitoring with a callback function for each new link
-func (s *HomePageScraper) StartContinuousMonitoringWithCallback(callback func(string)) error {
+func (s *HomePageScraper) StartContinuousMonitoringWithCallback(ctx context.Context, callback func(string)) error {
 	linkChan := make(chan string, 25) // Buffered channel
 
 	// Start a goroutine to handle incoming links
@@ -304,12 +622,13 @@ func (s *HomePageScraper) StartContinuousMonitoringWithCallback(callback func(st
 	}()
 
 	// Start the monitoring (this will block)
-	return s.StartContinuousMonitoring(linkChan)
+	defer close(linkChan)
+	return s.StartContinuousMonitoring(ctx, linkChan)
 }
 
 // GetListingLinks is a convenience method that returns just the URLs
-func (s *HomePageScraper) GetListingLinks() ([]string, error) {
-	links, err := s.ScrapeAllListingLinks()
+func (s *HomePageScraper) GetListingLinks(ctx context.Context) ([]string, error) {
+	links, err := s.ScrapeAllListingLinks(ctx)
 	if err != nil {
 		return nil, err
 	}
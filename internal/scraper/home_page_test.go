@@ -0,0 +1,121 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type fakeSeenSet struct {
+	seen map[string]bool
+	err  error
+}
+
+func (f *fakeSeenSet) MarkSeen(ctx context.Context, key string) (bool, error) {
+	if f.err != nil {
+		return false, f.err
+	}
+	if f.seen[key] {
+		return false, nil
+	}
+	f.seen[key] = true
+	return true, nil
+}
+
+func TestIsNewLinkSkipsSeenLinks(t *testing.T) {
+	s := NewHomePageScraper()
+	link := ListingLink{URL: "https://b.intimcity.gold/anketa1.htm", ID: "1"}
+
+	if !s.isNewLink(link) {
+		t.Errorf("Expected every link to be new without a seen-set")
+	}
+
+	s.SetSeenSet(&fakeSeenSet{seen: map[string]bool{}})
+	if !s.isNewLink(link) || s.isNewLink(link) {
+		t.Errorf("Expected the link to be emitted once")
+	}
+
+	s.SetSeenSet(&fakeSeenSet{err: errors.New("connection refused")})
+	if !s.isNewLink(link) {
+		t.Errorf("Expected links to be emitted when the seen-set fails")
+	}
+}
+
+func TestListingLinkFromID(t *testing.T) {
+	link := NewHomePageScraper().ListingLink("123")
+	if link.URL != "https://b.intimcity.gold/anketa123.htm" || link.ID != "123" {
+		t.Errorf("Expected the anketa123 link, got %+v", link)
+	}
+	if id := NewHomePageScraper().extractIDFromURL(link.URL); id != "123" {
+		t.Errorf("Expected the ID to round-trip, got %q", id)
+	}
+}
+
+func TestFreshScanStops(t *testing.T) {
+	fresh := &freshScan{pages: 3, maxPages: 5}
+
+	if fresh.done(2, 0) {
+		t.Errorf("Expected the first pages to be read even without new links")
+	}
+	if !fresh.done(3, 0) {
+		t.Errorf("Expected the scan to stop after the first pages without new links")
+	}
+	if fresh.done(4, 2) {
+		t.Errorf("Expected the scan to read on while pages have new links")
+	}
+	if !fresh.done(5, 2) {
+		t.Errorf("Expected the scan to stop at the page limit")
+	}
+
+	var full *freshScan
+	if full.done(100, 0) {
+		t.Errorf("Expected a full cycle never to stop early")
+	}
+}
+
+// indexAdapter is a site adapter whose index pages list the links of pages
+type indexAdapter struct {
+	fakeAdapter
+	pages [][]ListingLink
+	reads []int
+}
+
+func (a *indexAdapter) ScrapeIndex(ctx context.Context, page int) ([]ListingLink, error) {
+	a.reads = append(a.reads, page)
+	if page > len(a.pages) {
+		return nil, nil
+	}
+	return a.pages[page-1], nil
+}
+
+func TestDiscoveryReadsIndexThroughAdapter(t *testing.T) {
+	adapter := &indexAdapter{
+		fakeAdapter: fakeAdapter{name: "other.example"},
+		pages: [][]ListingLink{
+			{{URL: "https://other.example/1", ID: "1"}, {URL: "https://other.example/2", ID: "2"}},
+			{{URL: "https://other.example/3", ID: "3"}},
+		},
+	}
+	discovery := NewDiscovery(adapter)
+	discovery.SetSeenSet(&fakeSeenSet{seen: map[string]bool{}})
+	if discovery.Site() != "other.example" {
+		t.Errorf("Expected the site of the adapter, got %q", discovery.Site())
+	}
+
+	linkChan := make(chan ListingLink, 10)
+	if err := discovery.RunDiscoveryCycle(context.Background(), linkChan); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Without a page count the cycle stops at the first page without links
+	if len(linkChan) != 3 || len(adapter.reads) != 3 {
+		t.Errorf("Expected 3 links from 3 page reads, got %d links from %v", len(linkChan), adapter.reads)
+	}
+
+	adapter.reads = nil
+	if err := discovery.RunFreshCycle(context.Background(), linkChan, 1, 5); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(linkChan) != 3 || len(adapter.reads) != 1 {
+		t.Errorf("Expected the fresh cycle to read the first page only and send nothing new, got %d links from %v", len(linkChan), adapter.reads)
+	}
+}
@@ -0,0 +1,97 @@
+package scraper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gregor-tokarev/hoe_parser/internal/metrics"
+)
+
+// PageFingerprints remembers the fingerprint of every index page between discovery cycles
+type PageFingerprints interface {
+	// Fingerprint returns the fingerprint stored for an index page, "" when there is none
+	Fingerprint(ctx context.Context, page int) (string, error)
+	// SetFingerprint stores the fingerprint of an index page
+	SetFingerprint(ctx context.Context, page int, fingerprint string) error
+}
+
+// DefaultFingerprintSelector is the region of an index page fingerprinted when none is configured
+const DefaultFingerprintSelector = "body"
+
+// fingerprintIgnored are the elements left out of a fingerprint, since they change on every
+// request without changing the listings
+const fingerprintIgnored = "script, style, noscript, iframe"
+
+// whitespaceBetweenTags matches the indentation between tags, which a re-rendered page may change
+var whitespaceBetweenTags = regexp.MustCompile(`>\s+<`)
+
+// SetPageFingerprints makes the discovery cycle skip link extraction on index pages whose region
+// matched by selector is the same as in the previous cycle. Rescrape cycles still read every page.
+func (s *HomePageScraper) SetPageFingerprints(fingerprints PageFingerprints, selector string) {
+	if selector == "" {
+		selector = DefaultFingerprintSelector
+	}
+	s.fingerprints = fingerprints
+	s.fingerprintSelector = selector
+}
+
+// pageFingerprint hashes the HTML of the region of an index page matched by selector, without
+// scripts and styles and with whitespace normalized; "" when the region is missing
+func pageFingerprint(doc *goquery.Document, selector string) string {
+	region := doc.Find(selector).Clone()
+	if region.Length() == 0 {
+		return ""
+	}
+	region.Find(fingerprintIgnored).Remove()
+
+	hash := sha256.New()
+	region.Each(func(_ int, sel *goquery.Selection) {
+		html, _ := goquery.OuterHtml(sel)
+		html = whitespaceBetweenTags.ReplaceAllString(html, "><")
+		hash.Write([]byte(strings.Join(strings.Fields(html), " ")))
+	})
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// fingerprint returns the fingerprint of an index page, "" when fingerprints are not kept
+func (s *HomePageScraper) fingerprint(doc *goquery.Document) string {
+	if s.fingerprints == nil {
+		return ""
+	}
+	return pageFingerprint(doc, s.fingerprintSelector)
+}
+
+// pageUnchanged reports whether an index page has the fingerprint it had in the previous cycle.
+// Without fingerprints, or when they cannot be read, every page counts as changed.
+func (s *HomePageScraper) pageUnchanged(ctx context.Context, page int, fingerprint string) bool {
+	if s.fingerprints == nil || fingerprint == "" {
+		return false
+	}
+
+	previous, err := s.fingerprints.Fingerprint(ctx, page)
+	if err != nil {
+		metrics.IndexPageFingerprints.WithLabelValues("error").Inc()
+		log.WarnContext(ctx, "Failed to read index page fingerprint, extracting links", "page", page, "error", err)
+		return false
+	}
+	if previous == fingerprint {
+		metrics.IndexPageFingerprints.WithLabelValues("unchanged").Inc()
+		return true
+	}
+	metrics.IndexPageFingerprints.WithLabelValues("changed").Inc()
+	return false
+}
+
+// storeFingerprint remembers the fingerprint of an index page once its links were emitted
+func (s *HomePageScraper) storeFingerprint(ctx context.Context, page int, fingerprint string) {
+	if s.fingerprints == nil || fingerprint == "" {
+		return
+	}
+	if err := s.fingerprints.SetFingerprint(ctx, page, fingerprint); err != nil {
+		log.WarnContext(ctx, "Failed to store index page fingerprint", "page", page, "error", err)
+	}
+}
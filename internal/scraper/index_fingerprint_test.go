@@ -0,0 +1,70 @@
+package scraper
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+type fakePageFingerprints map[int]string
+
+func (f fakePageFingerprints) Fingerprint(ctx context.Context, page int) (string, error) {
+	return f[page], nil
+}
+
+func (f fakePageFingerprints) SetFingerprint(ctx context.Context, page int, fingerprint string) error {
+	f[page] = fingerprint
+	return nil
+}
+
+func parseHTML(t *testing.T, html string) *goquery.Document {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+	return doc
+}
+
+func TestPageFingerprintIgnoresScriptsAndWhitespace(t *testing.T) {
+	page := `<html><body><div id="list"><a href="/anketa1.htm">Anna</a></div><script>var now = 1;</script></body></html>`
+	reloaded := `<html><body><div id="list">
+		<a href="/anketa1.htm">Anna</a>
+	</div><script>var now = 2;</script></body></html>`
+	changed := `<html><body><div id="list"><a href="/anketa2.htm">Maria</a></div></body></html>`
+
+	fingerprint := pageFingerprint(parseHTML(t, page), "body")
+	if fingerprint == "" {
+		t.Fatalf("Expected a fingerprint of the body")
+	}
+	if other := pageFingerprint(parseHTML(t, reloaded), "body"); other != fingerprint {
+		t.Errorf("Expected scripts and whitespace not to change the fingerprint")
+	}
+	if other := pageFingerprint(parseHTML(t, changed), "body"); other == fingerprint {
+		t.Errorf("Expected a different listing to change the fingerprint")
+	}
+	if other := pageFingerprint(parseHTML(t, page), "#missing"); other != "" {
+		t.Errorf("Expected no fingerprint for a missing region, got %q", other)
+	}
+}
+
+func TestPageUnchanged(t *testing.T) {
+	s := NewHomePageScraper()
+	ctx := context.Background()
+	if s.pageUnchanged(ctx, 1, "abc") {
+		t.Errorf("Expected every page to count as changed without fingerprints")
+	}
+
+	s.SetPageFingerprints(fakePageFingerprints{}, "")
+	if s.pageUnchanged(ctx, 1, "abc") {
+		t.Errorf("Expected a page seen for the first time to count as changed")
+	}
+	s.storeFingerprint(ctx, 1, "abc")
+	if !s.pageUnchanged(ctx, 1, "abc") {
+		t.Errorf("Expected a page with the stored fingerprint to be unchanged")
+	}
+	if s.pageUnchanged(ctx, 1, "def") || s.pageUnchanged(ctx, 2, "abc") {
+		t.Errorf("Expected other fingerprints and pages to count as changed")
+	}
+}
@@ -0,0 +1,191 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+)
+
+// CardObservation is a price seen on an index page card, without fetching the listing itself
+type CardObservation struct {
+	ListingID  string
+	URL        string
+	Price      int32
+	Currency   string
+	Page       int
+	ObservedAt time.Time
+}
+
+// cardPriceRegex matches a price with an explicit currency marker, e.g. "5 000 ₽" or "7000 руб"
+var cardPriceRegex = regexp.MustCompile(`(\d{1,3}(?:[ \x{00a0}\x{2009}]?\d{3})*)\s*(₽|руб|р\.|\$|€)`)
+
+// ScrapePageCards extracts card-level price observations from a specific index page
+func (s *HomePageScraper) ScrapePageCards(ctx context.Context, pageNum int) ([]CardObservation, error) {
+	doc, err := s.fetchIndexPage(ctx, pageNum)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.extractCardObservations(doc, pageNum), nil
+}
+
+// extractCardObservations finds every listing card on the page and parses the price shown on it
+func (s *HomePageScraper) extractCardObservations(doc *goquery.Document, pageNum int) []CardObservation {
+	var observations []CardObservation
+	seen := make(map[string]bool)
+	now := clock.Now()
+
+	doc.Find("a").Each(func(i int, sel *goquery.Selection) {
+		rawHref, exists := sel.Attr("href")
+		if !exists {
+			return
+		}
+
+		href := s.normalizeHref(rawHref)
+		if href == "" || !s.isListingLink(href) {
+			return
+		}
+
+		id := s.extractIDFromURL(href)
+		if id == "" || seen[id] {
+			return
+		}
+
+		card := s.cardContainer(sel, id)
+		price, currency := parseCardPrice(card.Text())
+		if price == 0 {
+			return
+		}
+
+		seen[id] = true
+		observations = append(observations, CardObservation{
+			ListingID:  id,
+			URL:        href,
+			Price:      price,
+			Currency:   currency,
+			Page:       pageNum,
+			ObservedAt: now,
+		})
+	})
+
+	return observations
+}
+
+// cardContainer returns the largest ancestor of a listing link that contains no other listing
+func (s *HomePageScraper) cardContainer(link *goquery.Selection, id string) *goquery.Selection {
+	card := link
+	for parent := link.Parent(); parent.Length() > 0 && !parent.Is("body"); parent = parent.Parent() {
+		if s.containsOtherListing(parent, id) {
+			break
+		}
+		card = parent
+	}
+	return card
+}
+
+// containsOtherListing reports whether the selection links to any listing other than id
+func (s *HomePageScraper) containsOtherListing(sel *goquery.Selection, id string) bool {
+	found := false
+	sel.Find("a[href]").EachWithBreak(func(i int, link *goquery.Selection) bool {
+		href := s.normalizeHref(link.AttrOr("href", ""))
+		if href != "" && s.isListingLink(href) && s.extractIDFromURL(href) != id {
+			found = true
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// parseCardPrice returns the first currency-marked price in the card text
+func parseCardPrice(text string) (int32, string) {
+	matches := cardPriceRegex.FindStringSubmatch(text)
+	if len(matches) < 3 {
+		return 0, ""
+	}
+
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, matches[1])
+
+	price, err := strconv.Atoi(digits)
+	if err != nil || price <= 0 || price > 10000000 {
+		return 0, ""
+	}
+
+	currency := "RUB"
+	switch matches[2] {
+	case "$":
+		currency = "USD"
+	case "€":
+		currency = "EUR"
+	}
+
+	return int32(price), currency
+}
+
+// StartPriceObservationMonitoring loops through all index pages until ctx is done, sending the card
+// price observations of each page to the channel. Listing pages are never fetched.
+func (s *HomePageScraper) StartPriceObservationMonitoring(ctx context.Context, observationChan chan<- []CardObservation) error {
+	log.InfoContext(ctx, "Starting index-only price monitoring")
+
+	for {
+		if err := s.RunPriceObservationCycle(ctx, observationChan); err != nil {
+			return err
+		}
+	}
+}
+
+// RunPriceObservationCycle goes through every index page once, sending the card price
+// observations of each page to the channel. It returns ctx.Err() once ctx is done.
+func (s *HomePageScraper) RunPriceObservationCycle(ctx context.Context, observationChan chan<- []CardObservation) error {
+	s.waitForGate(ctx)
+	totalPages, err := s.indexPages(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get total pages: %w", err)
+	}
+
+	cycle := int(s.cycles.Add(1))
+	cycleStartedAt := clock.Now()
+	log.InfoContext(ctx, "Starting price observation cycle", "cycle", cycle, "pages", totalPages)
+
+	for page := 1; page <= totalPages && !s.pastLastPage(page); page++ {
+		request := s.politeWait(ctx, cycle, cycleStartedAt, page)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		observations, err := s.ScrapePageCards(ctx, page)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		s.finishRequest(request, len(observations), err)
+		if waitIfSitePaused(ctx, err) {
+			page-- // retry the same page once the site is reachable again
+			continue
+		}
+		s.reportProgress(cycle, page, totalPages, len(observations), err)
+		if err != nil {
+			log.WarnContext(ctx, "Failed to scrape index cards", "page", page, "cycle", cycle, "error", err)
+			continue
+		}
+
+		if len(observations) > 0 {
+			select {
+			case observationChan <- observations:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package scraper
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestParseCardPrice(t *testing.T) {
+	cases := []struct {
+		text     string
+		price    int32
+		currency string
+	}{
+		{"Анна, 25 лет 5 000 ₽", 5000, "RUB"},
+		{"1 час: 7000 руб", 7000, "RUB"},
+		{"до 10:00 12 000 ₽/час", 12000, "RUB"},
+		{"$150 / hour 200$", 200, "USD"},
+		{"без цены", 0, ""},
+	}
+
+	for _, c := range cases {
+		price, currency := parseCardPrice(c.text)
+		if price != c.price || currency != c.currency {
+			t.Errorf("parseCardPrice(%q) = %d %s, expected %d %s", c.text, price, currency, c.price, c.currency)
+		}
+	}
+}
+
+func TestExtractCardObservations(t *testing.T) {
+	html := `<html><body><div class="list">
+		<div class="card"><a href="/anketa101.htm">Анна</a><span>5 000 ₽</span></div>
+		<div class="card"><a href="/anketa102.htm">Мария</a><span>8000 руб</span></div>
+		<div class="card"><a href="/anketa103.htm">Без цены</a></div>
+	</div></body></html>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+
+	scraper := NewHomePageScraper()
+	observations := scraper.extractCardObservations(doc, 3)
+
+	if len(observations) != 2 {
+		t.Fatalf("Expected 2 observations, got %d", len(observations))
+	}
+
+	if observations[0].ListingID != "101" || observations[0].Price != 5000 {
+		t.Errorf("Expected listing 101 at 5000, got %s at %d", observations[0].ListingID, observations[0].Price)
+	}
+
+	if observations[1].ListingID != "102" || observations[1].Price != 8000 {
+		t.Errorf("Expected listing 102 at 8000, got %s at %d", observations[1].ListingID, observations[1].Price)
+	}
+
+	if observations[0].Page != 3 {
+		t.Errorf("Expected page 3, got %d", observations[0].Page)
+	}
+}
@@ -0,0 +1,165 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gregor-tokarev/hoe_parser/internal/archive"
+	"github.com/gregor-tokarev/hoe_parser/internal/service"
+	listing "github.com/gregor-tokarev/hoe_parser/proto"
+)
+
+func init() {
+	Register(NewIntimcityAdapter())
+}
+
+// intimcityDomain is the registrable domain of every intimcity mirror
+const intimcityDomain = "intimcity.gold"
+
+// IntimcityAdapter is the SiteAdapter for intimcity.gold, built on HomePageScraper and ListingScraper
+type IntimcityAdapter struct {
+	home                  *HomePageScraper
+	telegramResolver      TelegramResolver
+	telegramMinConfidence float64
+	mobileFallback        bool
+	pageArchive           archive.Store
+	extraction            *ExtractionProfiles
+}
+
+// NewIntimcityAdapter creates the intimcity.gold adapter
+func NewIntimcityAdapter() *IntimcityAdapter {
+	return &IntimcityAdapter{
+		home:                  NewHomePageScraper(),
+		telegramMinConfidence: DefaultTelegramMinConfidence,
+	}
+}
+
+// Name returns the source site key
+func (a *IntimcityAdapter) Name() string {
+	return intimcityDomain
+}
+
+// MatchesURL reports whether rawURL is on intimcity.gold or one of its subdomains
+func (a *IntimcityAdapter) MatchesURL(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	return host == intimcityDomain || strings.HasSuffix(host, "."+intimcityDomain)
+}
+
+// SetTelegramResolver sets the resolver used to confirm extracted Telegram handles (nil disables lookups)
+func (a *IntimcityAdapter) SetTelegramResolver(resolver TelegramResolver) {
+	a.telegramResolver = resolver
+}
+
+// SetTelegramMinConfidence sets the confidence a Telegram candidate needs to be stored as the handle
+func (a *IntimcityAdapter) SetTelegramMinConfidence(minConfidence float64) {
+	a.telegramMinConfidence = minConfidence
+}
+
+// SetMobileFallback sets whether a listing blocked on the desktop site is fetched from m.intimcity.gold
+func (a *IntimcityAdapter) SetMobileFallback(enabled bool) {
+	a.mobileFallback = enabled
+}
+
+// SetPageArchive stores the raw HTML of every fetched listing page in store (nil disables archiving)
+func (a *IntimcityAdapter) SetPageArchive(store archive.Store) {
+	a.pageArchive = store
+}
+
+// SetExtraction parses listing pages with the intimcity.gold profile of profiles, reloaded as
+// the config file changes (nil uses DefaultExtraction)
+func (a *IntimcityAdapter) SetExtraction(profiles *ExtractionProfiles) {
+	a.extraction = profiles
+}
+
+// SetPagination sets how the URLs of the index pages are found
+func (a *IntimcityAdapter) SetPagination(pagination Pagination) {
+	a.home.SetPagination(pagination)
+}
+
+// ScrapeListing scrapes a single anketa page from its desktop URL, falling back to the mobile
+// variant when the desktop site blocks the request
+func (a *IntimcityAdapter) ScrapeListing(ctx context.Context, rawURL string) (*listing.Listing, error) {
+	canonical := CanonicalListingURL(rawURL)
+	result, err := a.newListingScraper(canonical).ScrapeListing(ctx)
+	if err == nil || !a.mobileFallback || !service.IsBlocked(err) {
+		return result, err
+	}
+
+	mobileURL, ok := MobileListingURL(canonical)
+	if !ok {
+		return nil, err
+	}
+	log.InfoContext(ctx, "Desktop page blocked, fetching mobile variant", "url", canonical, "mobile_url", mobileURL)
+
+	mobileScraper := a.newListingScraper(canonical)
+	mobileScraper.SetFetchURL(mobileURL)
+	result, mobileErr := mobileScraper.ScrapeListing(ctx)
+	if mobileErr != nil {
+		return nil, fmt.Errorf("%w (mobile variant: %v)", err, mobileErr)
+	}
+	return result, nil
+}
+
+// ParseListingPage extracts a listing from a stored anketa page. Telegram handles are not looked
+// up, and photos, which come from a separate endpoint, are left empty.
+func (a *IntimcityAdapter) ParseListingPage(ctx context.Context, rawURL string, page []byte) (*listing.Listing, error) {
+	doc, err := service.ParsePage(page)
+	if err != nil {
+		return nil, err
+	}
+	return a.ParseListingDocument(ctx, rawURL, doc)
+}
+
+// ParseListingDocument extracts a listing from a parsed anketa page, like ParseListingPage
+func (a *IntimcityAdapter) ParseListingDocument(ctx context.Context, rawURL string, doc *goquery.Document) (*listing.Listing, error) {
+	listingScraper := NewListingScraper(CanonicalListingURL(rawURL))
+	listingScraper.SetTelegramMinConfidence(a.telegramMinConfidence)
+	listingScraper.SetExtraction(a.extraction.Profile(a.Name()))
+	return listingScraper.ParseDocument(ctx, doc), nil
+}
+
+// newListingScraper creates a listing scraper with the adapter's Telegram, archive and extraction settings
+func (a *IntimcityAdapter) newListingScraper(rawURL string) *ListingScraper {
+	listingScraper := NewListingScraper(rawURL)
+	listingScraper.SetTelegramResolver(a.telegramResolver)
+	listingScraper.SetTelegramMinConfidence(a.telegramMinConfidence)
+	listingScraper.SetPageArchive(a.pageArchive)
+	listingScraper.SetExtraction(a.extraction.Profile(a.Name()))
+	return listingScraper
+}
+
+// ScrapeIndex returns the listing links on an index page
+func (a *IntimcityAdapter) ScrapeIndex(ctx context.Context, page int) ([]ListingLink, error) {
+	return a.home.scrapePageLinks(ctx, page)
+}
+
+// ScrapeIndexDocument returns an index page with the listing links on it
+func (a *IntimcityAdapter) ScrapeIndexDocument(ctx context.Context, page int) (*goquery.Document, []ListingLink, error) {
+	doc, err := a.home.fetchIndexPage(ctx, page)
+	if err != nil {
+		return nil, nil, err
+	}
+	return doc, a.home.extractPageLinks(doc), nil
+}
+
+// IndexPages returns how many index pages a full crawl reads under the configured pagination
+func (a *IntimcityAdapter) IndexPages(ctx context.Context) (int, error) {
+	return a.home.indexPages(ctx)
+}
+
+// IndexPageURL returns the URL of an index page under the configured pagination
+func (a *IntimcityAdapter) IndexPageURL(page int) string {
+	return a.home.pageURL(page)
+}
+
+// ListingLink returns the link of the anketa with a numeric ID
+func (a *IntimcityAdapter) ListingLink(id string) ListingLink {
+	return a.home.ListingLink(id)
+}
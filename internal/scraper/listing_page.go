@@ -1,6 +1,7 @@
 package scraper
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -9,45 +10,120 @@ import (
 	"unicode/utf8"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/gregor-tokarev/hoe_parser/internal/archive"
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+	"github.com/gregor-tokarev/hoe_parser/internal/metrics"
 	"github.com/gregor-tokarev/hoe_parser/internal/service"
 	listing "github.com/gregor-tokarev/hoe_parser/proto"
 )
 
 // ListingScraper handles scraping of intimcity listings
 type ListingScraper struct {
-	Url string
+	Url      string // canonical listing URL, the listing's identity
+	fetchURL string // URL actually fetched when it differs from Url, e.g. the mobile variant
+
+	telegramResolver      TelegramResolver
+	telegramMinConfidence float64
+	archive               archive.Store // keeps the raw page of every scrape, see SetPageArchive
+	extraction            Extraction
 }
 
 // NewListingScraper creates a new intimcity scraper
 func NewListingScraper(url string) *ListingScraper {
-	return &ListingScraper{Url: url}
+	return &ListingScraper{Url: url, telegramMinConfidence: DefaultTelegramMinConfidence, extraction: DefaultExtraction()}
 }
 
-// ScrapeListing scrapes a single listing from intimcity and returns protobuf model
-func (s *ListingScraper) ScrapeListing() (*listing.Listing, error) {
-	doc, err := service.FetchAndParsePage(s.Url)
+// SetFetchURL fetches the page and photos from fetchURL instead of Url, e.g. the mobile variant
+// when the desktop site is blocked; the listing ID still comes from Url
+func (s *ListingScraper) SetFetchURL(fetchURL string) {
+	s.fetchURL = fetchURL
+}
+
+// pageURL returns the URL the page and photos are fetched from
+func (s *ListingScraper) pageURL() string {
+	if s.fetchURL != "" {
+		return s.fetchURL
+	}
+	return s.Url
+}
+
+// SetTelegramResolver sets the resolver used to confirm extracted Telegram handles (nil disables lookups)
+func (s *ListingScraper) SetTelegramResolver(resolver TelegramResolver) {
+	s.telegramResolver = resolver
+}
 
+// SetTelegramMinConfidence sets the confidence a Telegram candidate needs to be stored as the handle
+func (s *ListingScraper) SetTelegramMinConfidence(minConfidence float64) {
+	s.telegramMinConfidence = minConfidence
+}
+
+// SetPageArchive stores the raw HTML of every fetched page in store (nil disables archiving)
+func (s *ListingScraper) SetPageArchive(store archive.Store) {
+	s.archive = store
+}
+
+// SetExtraction sets the selectors and labels the page is parsed with
+func (s *ListingScraper) SetExtraction(extraction Extraction) {
+	s.extraction = extraction
+}
+
+// ScrapeListing scrapes a single listing from intimcity and returns protobuf model
+func (s *ListingScraper) ScrapeListing(ctx context.Context) (*listing.Listing, error) {
+	body, contentType, err := service.FetchPage(ctx, s.pageURL())
+	var doc *goquery.Document
+	if err == nil {
+		s.archivePage(ctx, body)
+		doc, err = service.ParsePageWithContentType(body, contentType)
+	}
+	if ctx.Err() == nil {
+		metrics.ObservePage("listing", err)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse HTML: %w", err)
 	}
 
-	// Extract listing ID from URL
-	listingID := s.extractListingID()
+	listingObj := s.ParseDocument(ctx, doc)
+	listingObj.Photos = s.extractPhotos(ctx, doc)
+
+	return listingObj, nil
+}
+
+// archivePage stores the page as served, so it can be parsed again offline. A failed snapshot is
+// logged and does not fail the scrape.
+func (s *ListingScraper) archivePage(ctx context.Context, body []byte) {
+	if s.archive == nil {
+		return
+	}
+
+	snapshot := archive.Snapshot{
+		Site:      intimcityDomain,
+		ListingID: s.extractListingID(),
+		URL:       s.pageURL(),
+		FetchedAt: clock.Now(),
+		Body:      body,
+	}
+	err := s.archive.Put(ctx, snapshot)
+	if err != nil {
+		log.WarnContext(ctx, "Failed to archive listing page", "url", snapshot.URL, "error", err)
+	}
+	metrics.ObservePageArchived(err)
+}
 
-	// Create the listing object
-	listingObj := &listing.Listing{
-		Id:           listingID,
+// ParseDocument extracts a listing from an already fetched page. Photos come from a separate
+// endpoint and are left empty.
+func (s *ListingScraper) ParseDocument(ctx context.Context, doc *goquery.Document) *listing.Listing {
+	return &listing.Listing{
+		Id:           s.extractListingID(),
 		PersonalInfo: s.extractPersonalInfo(doc),
-		ContactInfo:  s.extractContactInfo(doc),
+		ContactInfo:  s.extractContactInfo(ctx, doc),
 		PricingInfo:  s.extractPricingInfo(doc),
 		ServiceInfo:  s.extractServiceInfo(doc),
 		LocationInfo: s.extractLocationInfo(doc),
 		Description:  s.extractDescription(doc),
 		LastUpdated:  s.extractLastUpdated(doc),
-		Photos:       s.extractPhotos(doc),
-	}
 
-	return listingObj, nil
+		AvailabilityStatus: extractAvailabilityStatus(doc, s.extraction),
+	}
 }
 
 // Helper function for min
@@ -79,54 +155,55 @@ func (s *ListingScraper) extractListingID() string {
 // extractPersonalInfo extracts personal information from the page
 func (s *ListingScraper) extractPersonalInfo(doc *goquery.Document) *listing.PersonalInfo {
 	info := &listing.PersonalInfo{}
+	labels := s.extraction.Labels
 
 	// Extract name from page title
-	if title := doc.Find("h1.breadcrumbs > span").Text(); title != "" {
-		info.Name = strings.TrimSpace(title)
+	if title := doc.Find(s.extraction.Name).Text(); title != "" {
+		info.Name = cleanString(title)
 	}
 
 	// Extract using specific element IDs where available
-	if age := doc.Find("#tdankage").Text(); age != "" {
-		if ageVal, err := strconv.Atoi(strings.TrimSpace(age)); err == nil {
-			info.Age = int32(ageVal)
-		}
+	if age := doc.Find(s.extraction.Age).Text(); age != "" {
+		info.Age = parseMeasurement(age)
 	}
 
-	if height := doc.Find("#tdankhei").Text(); height != "" {
-		if heightVal, err := strconv.Atoi(strings.TrimSpace(height)); err == nil {
-			info.Height = int32(heightVal)
-		}
+	if height := doc.Find(s.extraction.Height).Text(); height != "" {
+		info.Height = parseMeasurement(height)
 	}
 
-	if weight := doc.Find("#tdankwei").Text(); weight != "" {
-		if weightVal, err := strconv.Atoi(strings.TrimSpace(weight)); err == nil {
-			info.Weight = int32(weightVal)
-		}
+	if weight := doc.Find(s.extraction.Weight).Text(); weight != "" {
+		info.Weight = parseMeasurement(weight)
 	}
 
-	if breast := doc.Find("#tdankbre").Text(); breast != "" {
-		if breastVal, err := strconv.Atoi(strings.TrimSpace(breast)); err == nil {
-			info.BreastSize = int32(breastVal)
-		}
+	if breast := doc.Find(s.extraction.BreastSize).Text(); breast != "" {
+		info.BreastSize = parseMeasurement(breast)
+	}
+
+	if clothSize := doc.Find(s.extraction.BodyType).Text(); clothSize != "" {
+		info.BodyType = cleanString(clothSize)
 	}
 
-	if clothSize := doc.Find("#tdankcloth").Text(); clothSize != "" {
-		info.BodyType = strings.TrimSpace(clothSize)
+	if haircut := doc.Find(s.extraction.HairColor).Text(); haircut != "" {
+		info.HairColor = cleanString(haircut)
 	}
 
-	if haircut := doc.Find("#tdankinhc").Text(); haircut != "" {
-		info.HairColor = strings.TrimSpace(haircut)
+	if gender := findLabeledCell(doc, func(label string) bool { return contains(labels.Gender, label) }); gender != nil {
+		info.Gender = cleanString(gender.Text())
+	}
+
+	if orientation := findLabeledCell(doc, func(label string) bool { return containsAny(label, labels.Orientation) }); orientation != nil {
+		info.Orientation = cleanString(orientation.Text())
 	}
 
 	return info
 }
 
 // extractContactInfo extracts contact information
-func (s *ListingScraper) extractContactInfo(doc *goquery.Document) *listing.ContactInfo {
+func (s *ListingScraper) extractContactInfo(ctx context.Context, doc *goquery.Document) *listing.ContactInfo {
 	info := &listing.ContactInfo{}
 
 	// Extract phone using specific ID first
-	if phone := doc.Find("#tdmobphone a").First(); phone.Length() > 0 {
+	if phone := doc.Find(s.extraction.Phone).First(); phone.Length() > 0 {
 		if href, exists := phone.Attr("href"); exists && strings.HasPrefix(href, "tel:") {
 			info.Phone = cleanString(strings.TrimPrefix(href, "tel:"))
 		} else {
@@ -143,24 +220,14 @@ func (s *ListingScraper) extractContactInfo(doc *goquery.Document) *listing.Cont
 		})
 	}
 
-	// Extract telegram from text patterns
-	// pageText := doc.Text()
-	// telegramPatterns := []string{
-	// 	`@([a-zA-Z0-9_]+)`,
-	// 	`[Tt]елеграм[мь]?\s*[@:]?\s*([a-zA-Z0-9_]+)`,
-	// 	`[Tt]г\s*[@:]?\s*([a-zA-Z0-9_]+)`,
-	// }
-
-	// for _, pattern := range telegramPatterns {
-	// 	re := regexp.MustCompile(pattern)
-	// 	if matches := re.FindStringSubmatch(pageText); len(matches) > 1 {
-	// 		username := strings.TrimSpace(matches[1])
-	// 		if len(username) > 2 && len(username) < 50 {
-	// 			info.Telegram = "@" + username
-	// 			break
-	// 		}
-	// 	}
-	// }
+	// Telegram: keep every candidate, store a handle only when it is confident enough
+	candidates := extractTelegramCandidates(doc)
+	for _, candidate := range candidates {
+		info.TelegramCandidates = append(info.TelegramCandidates, "@"+candidate.Handle)
+	}
+	telegram, confidence := validateTelegram(ctx, candidates, s.telegramResolver, s.telegramMinConfidence)
+	info.Telegram = telegram
+	info.TelegramConfidence = float32(confidence)
 
 	// Check for messaging app availability
 	// if doc.Find("a[href*='whatsapp'], .sWhatsApp").Length() > 0 {
@@ -186,33 +253,34 @@ func (s *ListingScraper) extractPricingInfo(doc *goquery.Document) *listing.Pric
 		Currency:       "RUB",
 	}
 
-	// Use table.table-price class for pricing table
-	pricingTable := doc.Find("table.table-price table.table-price-inner tbody")
-
-	trs := pricingTable.Find("tr")
-
-	apartments := trs.Eq(2)
-
-	apartmentsDayHour := apartments.Find("td").Eq(1).Text()
-	apartmentsDay2Hour := apartments.Find("td").Eq(2).Text()
-	apartmentsNightHour := apartments.Find("td").Eq(3).Text()
-	apartmentsNight2Hour := apartments.Find("td").Eq(4).Text()
-
-	outcall := trs.Eq(3)
-	outcallDayHour := outcall.Find("td").Eq(1).Text()
-	outcallDay2Hour := outcall.Find("td").Eq(2).Text()
-	outcallNightHour := outcall.Find("td").Eq(3).Text()
-	outcallNight2Hour := outcall.Find("td").Eq(4).Text()
-
-	info.DurationPrices["apartments_day_hour"] = extractPrice(apartmentsDayHour)
-	info.DurationPrices["apartments_day_2hour"] = extractPrice(apartmentsDay2Hour)
-	info.DurationPrices["apartments_night_hour"] = extractPrice(apartmentsNightHour)
-	info.DurationPrices["apartments_night_2hour"] = extractPrice(apartmentsNight2Hour)
+	trs := doc.Find(s.extraction.PricingTable).Find("tbody tr")
+
+	apartments := trs.Eq(s.extraction.ApartmentsRow).Find("td")
+	outcall := trs.Eq(s.extraction.OutcallRow).Find("td")
+	cells := []struct {
+		key  string
+		text string
+	}{
+		{"apartments_day_hour", apartments.Eq(1).Text()},
+		{"apartments_day_2hour", apartments.Eq(2).Text()},
+		{"apartments_night_hour", apartments.Eq(3).Text()},
+		{"apartments_night_2hour", apartments.Eq(4).Text()},
+		{"outcall_day_hour", outcall.Eq(1).Text()},
+		{"outcall_day_2hour", outcall.Eq(2).Text()},
+		{"outcall_night_hour", outcall.Eq(3).Text()},
+		{"outcall_night_2hour", outcall.Eq(4).Text()},
+	}
 
-	info.DurationPrices["outcall_day_hour"] = extractPrice(outcallDayHour)
-	info.DurationPrices["outcall_day_2hour"] = extractPrice(outcallDay2Hour)
-	info.DurationPrices["outcall_night_hour"] = extractPrice(outcallNightHour)
-	info.DurationPrices["outcall_night_2hour"] = extractPrice(outcallNight2Hour)
+	// The first currency a cell names is the currency of the table; unmarked prices are rubles
+	currency := ""
+	for _, cell := range cells {
+		if cellCurrency := setDurationPrice(info, cell.key, cell.text); currency == "" {
+			currency = cellCurrency
+		}
+	}
+	if currency != "" {
+		info.Currency = currency
+	}
 
 	// pricingTable.Find("tr").Each(func(i int, row *goquery.Selection) {
 	// 	tds := row.Find("td")
@@ -225,18 +293,35 @@ func (s *ListingScraper) extractPricingInfo(doc *goquery.Document) *listing.Pric
 	return info
 }
 
-// extractPrice helper function to parse price from text
-func extractPrice(text string) int32 {
-	numStr := strings.ReplaceAll(text, " ", "")
-	numStr = strings.ReplaceAll(text, " ", "")
-	numStr = strings.ReplaceAll(numStr, "\u2009", "")
-	numStr = strings.ReplaceAll(numStr, "₽", "")
+// setDurationPrice stores the price of a pricing table cell under key, 0 when the cell holds no
+// price; a range stores its lower end under key and its upper end under key + "_max". It returns
+// the currency the cell names, if any.
+func setDurationPrice(info *listing.PricingInfo, key, text string) string {
+	price, ok := ParsePrice(text)
+	info.DurationPrices[key] = price.Min
+	if ok && price.Max != price.Min {
+		info.DurationPrices[key+"_max"] = price.Max
+	}
+	return price.Currency
+}
 
-	if price, err := strconv.Atoi(numStr); err == nil {
-		return int32(price)
+// extractPrice returns the price in a pricing table cell, the lower end for a range, or 0 when
+// the cell holds no price
+func extractPrice(text string) int32 {
+	price, ok := ParsePrice(text)
+	if !ok {
+		return 0
 	}
+	return price.Min
+}
 
-	return 0
+// parseMeasurement parses a non-negative integer cell such as age or height, returning 0 when invalid
+func parseMeasurement(text string) int32 {
+	value, err := strconv.ParseInt(strings.TrimSpace(text), 10, 32)
+	if err != nil || value < 0 {
+		return 0
+	}
+	return int32(value)
 }
 
 // extractServiceInfo extracts available services
@@ -247,8 +332,7 @@ func (s *ListingScraper) extractServiceInfo(doc *goquery.Document) *listing.Serv
 		Restrictions:       []string{},
 	}
 
-	// Use table.uslugi_block class for services table
-	servicesTable := doc.Find("table.uslugi_block")
+	servicesTable := doc.Find(s.extraction.Services)
 	servicesTable.Find("a[href]").Each(func(i int, link *goquery.Selection) {
 		service := strings.TrimSpace(link.Text())
 		if service != "" && !link.HasClass("noservice") {
@@ -258,10 +342,10 @@ func (s *ListingScraper) extractServiceInfo(doc *goquery.Document) *listing.Serv
 
 	// Determine meeting type
 	pageText := strings.ToLower(doc.Text())
-	if strings.Contains(pageText, "апартаменты") {
+	if containsAny(pageText, s.extraction.Labels.Incall) {
 		info.MeetingType = "apartment"
 	}
-	if strings.Contains(pageText, "выезд") {
+	if containsAny(pageText, s.extraction.Labels.Outcall) {
 		if info.MeetingType != "" {
 			info.MeetingType = "both"
 		} else {
@@ -278,14 +362,15 @@ func (s *ListingScraper) extractLocationInfo(doc *goquery.Document) *listing.Loc
 		MetroStations: []string{},
 		City:          "Moscow", // Default for intimcity
 	}
+	labels := s.extraction.Labels
 
 	// Extract city using specific ID
-	if city := doc.Find("#tdankcity").Text(); city != "" {
+	if city := doc.Find(s.extraction.City).Text(); city != "" {
 		info.City = strings.TrimSpace(city)
 	}
 
 	// Extract metro stations from links with metro in href
-	doc.Find("a[href*='metro']").Each(func(i int, link *goquery.Selection) {
+	doc.Find(s.extraction.MetroLinks).Each(func(i int, link *goquery.Selection) {
 		station := strings.TrimSpace(link.Text())
 		if station != "" && len(station) > 2 {
 			info.MetroStations = append(info.MetroStations, station)
@@ -293,7 +378,7 @@ func (s *ListingScraper) extractLocationInfo(doc *goquery.Document) *listing.Loc
 	})
 
 	// Extract district from links with district in href
-	doc.Find("a[href*='district']").Each(func(i int, link *goquery.Selection) {
+	doc.Find(s.extraction.DistrictLinks).Each(func(i int, link *goquery.Selection) {
 		district := strings.TrimSpace(link.Text())
 		if district != "" {
 			info.District = district
@@ -308,7 +393,7 @@ func (s *ListingScraper) extractLocationInfo(doc *goquery.Document) *listing.Loc
 			if cells.Length() >= 2 {
 				label := strings.TrimSpace(cells.Eq(0).Text())
 
-				if strings.Contains(label, "Метро") && len(info.MetroStations) == 0 {
+				if containsAny(label, labels.Metro) && len(info.MetroStations) == 0 {
 					cells.Eq(1).Find("a").Each(func(j int, link *goquery.Selection) {
 						station := strings.TrimSpace(link.Text())
 						if station != "" && len(station) > 2 {
@@ -317,29 +402,132 @@ func (s *ListingScraper) extractLocationInfo(doc *goquery.Document) *listing.Loc
 					})
 				}
 
-				if strings.Contains(label, "Район") && info.District == "" {
+				if containsAny(label, labels.District) && info.District == "" {
 					info.District = strings.TrimSpace(cells.Eq(1).Text())
 				}
 			}
 		})
 	}
 
-	// Check availability from pricing table
-	pageText := strings.ToLower(doc.Text())
-	if strings.Contains(pageText, "выезд") {
-		info.OutcallAvailable = true
+	// Extract neighborhoods served for outcall
+	serviceArea := findLabeledCell(doc, func(label string) bool {
+		return containsAny(label, labels.Outcall) && containsAny(label, labels.ServiceArea)
+	})
+	if serviceArea != nil {
+		info.ServiceArea = splitCellValues(serviceArea)
+	}
+
+	// Extract salon information
+	salon := findLabeledCell(doc, func(label string) bool { return containsAny(label, labels.Salon) })
+	if salon != nil {
+		value := cleanString(salon.Text())
+		lowerValue := strings.ToLower(value)
+		if value != "" && lowerValue != "нет" && lowerValue != "no" {
+			info.WorksInSalon = true
+			if lowerValue != "да" && lowerValue != "yes" {
+				info.SalonAddress = value
+			}
+		}
 	}
-	if strings.Contains(pageText, "апартаменты") || strings.Contains(pageText, "принимаю") {
-		info.IncallAvailable = true
+
+	// Check availability from the pricing table, falling back to page text keywords without one
+	if incall, outcall, found := pricingTableAvailability(doc, s.extraction); found {
+		info.IncallAvailable = incall
+		info.OutcallAvailable = outcall
+		info.AvailabilitySource = AvailabilityFromPricingTable
+	} else {
+		pageText := strings.ToLower(doc.Text())
+		info.OutcallAvailable = containsAny(pageText, labels.Outcall)
+		info.IncallAvailable = containsAny(pageText, labels.Incall) || containsAny(pageText, labels.IncallText)
+		info.AvailabilitySource = AvailabilityFromPageText
 	}
 
 	return info
 }
 
+// Methods recorded in LocationInfo.AvailabilitySource
+const (
+	AvailabilityFromPricingTable = "pricing_table" // Апартаменты/Выезд rows of the pricing table
+	AvailabilityFromPageText     = "page_text"     // keywords anywhere on the page
+)
+
+// pricingTableAvailability derives incall and outcall availability from the Апартаменты and Выезд
+// rows of the pricing table: a row with at least one price means the meeting type is offered.
+// found is false when the table has neither row.
+func pricingTableAvailability(doc *goquery.Document, extraction Extraction) (incall, outcall, found bool) {
+	doc.Find(extraction.PricingTable).Find("tr").Each(func(i int, row *goquery.Selection) {
+		cells := row.ChildrenFiltered("td")
+		if cells.Length() < 2 {
+			return
+		}
+
+		label := strings.ToLower(cleanString(cells.Eq(0).Text()))
+		isIncall := containsAny(label, extraction.Labels.Incall)
+		isOutcall := containsAny(label, extraction.Labels.Outcall)
+		if !isIncall && !isOutcall {
+			return
+		}
+		found = true
+
+		priced := false
+		cells.Slice(1, cells.Length()).Each(func(j int, cell *goquery.Selection) {
+			if extractPrice(cell.Text()) > 0 {
+				priced = true
+			}
+		})
+		incall = incall || (isIncall && priced)
+		outcall = outcall || (isOutcall && priced)
+	})
+	return incall, outcall, found
+}
+
+// findLabeledCell returns the value cell of the first two-column table row whose label satisfies match.
+// Labels are lowercased and stripped of surrounding whitespace and trailing colons before matching.
+func findLabeledCell(doc *goquery.Document, match func(label string) bool) *goquery.Selection {
+	var result *goquery.Selection
+
+	doc.Find("table tr").EachWithBreak(func(i int, row *goquery.Selection) bool {
+		cells := row.ChildrenFiltered("td")
+		if cells.Length() < 2 {
+			return true
+		}
+
+		label := strings.ToLower(cleanString(cells.Eq(0).Text()))
+		label = strings.TrimSpace(strings.TrimSuffix(label, ":"))
+		if label != "" && match(label) {
+			result = cells.Eq(1)
+			return false
+		}
+		return true
+	})
+
+	return result
+}
+
+// splitCellValues returns link texts of a cell, or its comma-separated text when it has no links
+func splitCellValues(cell *goquery.Selection) []string {
+	var values []string
+
+	cell.Find("a").Each(func(i int, link *goquery.Selection) {
+		if text := cleanString(link.Text()); text != "" {
+			values = append(values, text)
+		}
+	})
+
+	if len(values) == 0 {
+		for _, part := range strings.Split(cell.Text(), ",") {
+			if text := cleanString(part); text != "" {
+				values = append(values, text)
+			}
+		}
+	}
+
+	return values
+}
+
 // extractDescription extracts the main description
 func (s *ListingScraper) extractDescription(doc *goquery.Document) string {
-	// Use p.pnletter class for description
-	if desc := doc.Find("p.pnletter").First(); desc.Length() > 0 {
+	if desc := doc.Find(s.extraction.Description).First(); desc.Length() > 0 {
 		return cleanString(desc.Text())
 	}
 
@@ -366,10 +554,35 @@ func (s *ListingScraper) extractDescription(doc *goquery.Document) string {
 	return ""
 }
 
+// Statuses recorded in Listing.AvailabilityStatus. A listing with a status banner stays listed,
+// so it is not removed; it only stops counting as active.
+const (
+	AvailabilityActive                 = "active"
+	AvailabilityTemporarilyUnavailable = "temporarily_unavailable"
+	AvailabilityVacation               = "vacation"
+)
+
+// extractAvailabilityStatus reads the status banner of a page. The description is left out, so
+// text such as "работаю без отпусков" never marks a listing unavailable. Vacation is checked
+// first, as its banners often say "временно" as well.
+func extractAvailabilityStatus(doc *goquery.Document, extraction Extraction) string {
+	page := doc.Selection.Clone()
+	page.Find(extraction.Description).Remove()
+	text := strings.ToLower(strings.Join(strings.Fields(page.Text()), " "))
+
+	switch {
+	case containsAny(text, extraction.Labels.Vacation):
+		return AvailabilityVacation
+	case containsAny(text, extraction.Labels.Unavailable):
+		return AvailabilityTemporarilyUnavailable
+	}
+	return AvailabilityActive
+}
+
 // extractLastUpdated extracts the last updated date
 func (s *ListingScraper) extractLastUpdated(doc *goquery.Document) string {
 	// Look for update date in table with noprint class
-	updateText := doc.Find("tr.noprint td").Last().Text()
+	updateText := doc.Find(s.extraction.LastUpdated).Last().Text()
 	if updateText != "" {
 		re := regexp.MustCompile(`(\d{2}\.\d{2}\.\d{4})`)
 		if matches := re.FindStringSubmatch(updateText); len(matches) > 1 {
@@ -391,12 +604,23 @@ func (s *ListingScraper) extractLastUpdated(doc *goquery.Document) string {
 }
 
 // extractPhotos extracts photo URLs
-func (s *ListingScraper) extractPhotos(doc *goquery.Document) []string {
+func (s *ListingScraper) extractPhotos(ctx context.Context, doc *goquery.Document) []string {
+	photos, err := FetchPhotoURLs(ctx, s.pageURL())
+	if err != nil {
+		log.WarnContext(ctx, "Failed to fetch photos", "url", s.pageURL(), "error", err)
+		return nil
+	}
+
+	return photos
+}
+
+// FetchPhotoURLs requests the image JSON endpoint of a listing page and returns absolute photo URLs
+func FetchPhotoURLs(ctx context.Context, listingURL string) ([]string, error) {
 	var photos []string
 
-	imageData, err := service.FetchJsonImgs(s.Url)
+	imageData, err := service.FetchJsonImgs(ctx, listingURL)
 	if err != nil {
-		return photos
+		return nil, err
 	}
 
 	for _, img := range imageData {
@@ -404,7 +628,7 @@ func (s *ListingScraper) extractPhotos(doc *goquery.Document) []string {
 		photos = append(photos, href)
 	}
 
-	return photos
+	return photos, nil
 }
 
 // Helper function to check if slice contains string
@@ -416,3 +640,13 @@ func contains(slice []string, item string) bool {
 	}
 	return false
 }
+
+// containsAny reports whether text contains one of parts
+func containsAny(text string, parts []string) bool {
+	for _, part := range parts {
+		if part != "" && strings.Contains(text, part) {
+			return true
+		}
+	}
+	return false
+}
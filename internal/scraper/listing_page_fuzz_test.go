@@ -0,0 +1,144 @@
+package scraper
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"google.golang.org/protobuf/proto"
+)
+
+// fuzzSeedHTML returns the fixture listing page and a few truncated/garbled variants as fuzz seeds
+func fuzzSeedHTML(f *testing.F) []string {
+	f.Helper()
+
+	fixture, err := os.ReadFile("testdata/listing.html")
+	if err != nil {
+		f.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	page := string(fixture)
+	return []string{
+		page,
+		page[:len(page)/2],
+		strings.ReplaceAll(page, "</td>", ""),
+		strings.ReplaceAll(page, "<table", "<div"),
+		"<table><tr><td>Пол:</td><td>\xff\xfe</td></tr></table>",
+		"",
+	}
+}
+
+// parseFuzzDocument parses fuzz input, skipping inputs goquery cannot parse
+func parseFuzzDocument(t *testing.T, html string) *goquery.Document {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Skip()
+	}
+	return doc
+}
+
+// assertMarshals fails when a message cannot be serialized, e.g. because of invalid UTF-8 strings
+func assertMarshals(t *testing.T, msg proto.Message) {
+	if _, err := proto.Marshal(msg); err != nil {
+		t.Errorf("Extracted message does not marshal: %v", err)
+	}
+}
+
+func TestExtractPrice(t *testing.T) {
+	cases := map[string]int32{
+		"5 000 ₽":           5000,
+		"12\u00a0000 ₽":     12000,
+		"7\u202f000₽":       7000,
+		"3 500 руб.":        3500,
+		"-300":              0,
+		"99999999999":       0,
+		"по договорённости": 0,
+	}
+
+	for text, expected := range cases {
+		if price := extractPrice(text); price != expected {
+			t.Errorf("extractPrice(%q) = %d, expected %d", text, price, expected)
+		}
+	}
+}
+
+func TestExtractPersonalInfoFixture(t *testing.T) {
+	fixture, err := os.ReadFile("testdata/listing.html")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	s := NewListingScraper("https://b.intimcity.gold/anketa1.htm")
+	info := s.extractPersonalInfo(parseFuzzDocument(t, string(fixture)))
+	if info.Name != "Анна" || info.Age != 25 || info.Height != 168 || info.Gender != "Женский" {
+		t.Errorf("Unexpected personal info: %+v", info)
+	}
+
+	pricing := s.extractPricingInfo(parseFuzzDocument(t, string(fixture)))
+	if pricing.DurationPrices["apartments_day_hour"] != 5000 || pricing.DurationPrices["outcall_night_2hour"] != 15000 {
+		t.Errorf("Unexpected prices: %v", pricing.DurationPrices)
+	}
+}
+
+func FuzzExtractPrice(f *testing.F) {
+	for _, seed := range []string{"5 000 ₽", "12 000", "7 000₽", "-300", "99999999999", "", "₽"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, text string) {
+		if price := extractPrice(text); price < 0 || price > maxListingPrice {
+			t.Errorf("extractPrice(%q) = %d, expected a price within [0, %d]", text, price, maxListingPrice)
+		}
+	})
+}
+
+func FuzzExtractPricingInfo(f *testing.F) {
+	for _, seed := range fuzzSeedHTML(f) {
+		f.Add(seed)
+	}
+
+	s := NewListingScraper("https://b.intimcity.gold/anketa1.htm")
+	f.Fuzz(func(t *testing.T, html string) {
+		info := s.extractPricingInfo(parseFuzzDocument(t, html))
+		for key, price := range info.DurationPrices {
+			if price < 0 {
+				t.Errorf("Negative price %d for %s", price, key)
+			}
+		}
+		assertMarshals(t, info)
+	})
+}
+
+func FuzzExtractPersonalInfo(f *testing.F) {
+	for _, seed := range fuzzSeedHTML(f) {
+		f.Add(seed)
+	}
+
+	s := NewListingScraper("https://b.intimcity.gold/anketa1.htm")
+	f.Fuzz(func(t *testing.T, html string) {
+		info := s.extractPersonalInfo(parseFuzzDocument(t, html))
+		if info.Age < 0 || info.Height < 0 || info.Weight < 0 || info.BreastSize < 0 {
+			t.Errorf("Negative measurement in %+v", info)
+		}
+		for _, value := range []string{info.Name, info.BodyType, info.HairColor, info.Gender, info.Orientation} {
+			if !utf8.ValidString(value) {
+				t.Errorf("Invalid UTF-8 in extracted value %q", value)
+			}
+		}
+		assertMarshals(t, info)
+	})
+}
+
+func FuzzExtractContactInfo(f *testing.F) {
+	for _, seed := range fuzzSeedHTML(f) {
+		f.Add(seed)
+	}
+
+	s := NewListingScraper("https://b.intimcity.gold/anketa1.htm")
+	f.Fuzz(func(t *testing.T, html string) {
+		assertMarshals(t, s.extractContactInfo(context.Background(), parseFuzzDocument(t, html)))
+	})
+}
@@ -0,0 +1,120 @@
+package scraper
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// pricingPage returns a listing page with the given pricing table rows and description
+func pricingPage(rows, description string) string {
+	return `<html><body>
+<table class="table-price"><tr><td><table class="table-price-inner"><tbody>
+<tr><th></th><th colspan="2">День</th><th colspan="2">Ночь</th></tr>
+<tr><td></td><td>1 час</td><td>2 часа</td><td>1 час</td><td>2 часа</td></tr>
+` + rows + `
+</tbody></table></td></tr></table>
+<p class="pnletter">` + description + `</p>
+</body></html>`
+}
+
+func TestExtractAvailability(t *testing.T) {
+	tests := []struct {
+		name    string
+		html    string
+		incall  bool
+		outcall bool
+		source  string
+	}{
+		{
+			name:    "both rows priced",
+			html:    pricingPage(`<tr><td>Апартаменты</td><td>5 000 ₽</td><td>9 000 ₽</td><td>-</td><td>-</td></tr><tr><td>Выезд</td><td>-</td><td>-</td><td>9 000 ₽</td><td>15 000 ₽</td></tr>`, ""),
+			incall:  true,
+			outcall: true,
+			source:  AvailabilityFromPricingTable,
+		},
+		{
+			name:    "unpriced outcall row ignores description",
+			html:    pricingPage(`<tr><td>Апартаменты</td><td>5 000 ₽</td><td>9 000 ₽</td><td>7 000 ₽</td><td>12 000 ₽</td></tr><tr><td>Выезд</td><td>-</td><td>-</td><td>-</td><td>-</td></tr>`, "Выезд не предлагаю, только апартаменты."),
+			incall:  true,
+			outcall: false,
+			source:  AvailabilityFromPricingTable,
+		},
+		{
+			name:    "missing row is not offered",
+			html:    pricingPage(`<tr><td>Выезд</td><td>7 000 ₽</td><td>12 000 ₽</td><td>9 000 ₽</td><td>15 000 ₽</td></tr>`, "Апартаменты не предоставляю."),
+			incall:  false,
+			outcall: true,
+			source:  AvailabilityFromPricingTable,
+		},
+		{
+			name:    "page text without a pricing table",
+			html:    `<html><body><p class="pnletter">Принимаю у себя, возможен выезд.</p></body></html>`,
+			incall:  true,
+			outcall: true,
+			source:  AvailabilityFromPageText,
+		},
+	}
+
+	scraper := NewListingScraper("https://a.intimcity.gold/anketa1001.htm")
+	for _, test := range tests {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(test.html))
+		if err != nil {
+			t.Fatalf("%s: failed to parse page: %v", test.name, err)
+		}
+
+		info := scraper.extractLocationInfo(doc)
+		if info.IncallAvailable != test.incall || info.OutcallAvailable != test.outcall || info.AvailabilitySource != test.source {
+			t.Errorf("%s: expected incall %v, outcall %v from %s, got %v, %v from %s", test.name,
+				test.incall, test.outcall, test.source, info.IncallAvailable, info.OutcallAvailable, info.AvailabilitySource)
+		}
+	}
+}
+
+func TestExtractAvailabilityStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		expected string
+	}{
+		{
+			name:     "no banner",
+			html:     `<html><body><h1>Анна</h1><p class="pnletter">Работаю без отпусков и выходных.</p></body></html>`,
+			expected: AvailabilityActive,
+		},
+		{
+			name:     "temporarily unavailable banner",
+			html:     `<html><body><div class="alert">Анкета  ВРЕМЕННО не работает</div><p class="pnletter">Жду звонка.</p></body></html>`,
+			expected: AvailabilityTemporarilyUnavailable,
+		},
+		{
+			name:     "vacation banner",
+			html:     `<html><body><div class="alert">В отпуске до 15 июня, временно не принимаю</div></body></html>`,
+			expected: AvailabilityVacation,
+		},
+		{
+			name:     "banner text in the description only",
+			html:     `<html><body><p class="pnletter">Если временно не работаю, пишите в телеграм.</p></body></html>`,
+			expected: AvailabilityActive,
+		},
+	}
+
+	for _, test := range tests {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(test.html))
+		if err != nil {
+			t.Fatalf("%s: failed to parse page: %v", test.name, err)
+		}
+
+		if status := extractAvailabilityStatus(doc, DefaultExtraction()); status != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.name, test.expected, status)
+		}
+	}
+
+	// The description stays on the page after the banner check
+	doc, _ := goquery.NewDocumentFromReader(strings.NewReader(`<html><body><p class="pnletter">Описание анкеты</p></body></html>`))
+	extractAvailabilityStatus(doc, DefaultExtraction())
+	if doc.Find("p.pnletter").Length() != 1 {
+		t.Errorf("Expected the banner check to leave the page unchanged")
+	}
+}
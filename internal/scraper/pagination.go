@@ -0,0 +1,158 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+)
+
+// Pagination strategies of index pages
+const (
+	PaginationAuto     = "auto"     // guess the page count from the home page, ?page=N with /pN as fallback
+	PaginationTemplate = "template" // build every page URL from a template with {page}
+	PaginationNextLink = "next"     // follow the link matched by a CSS selector from page to page
+)
+
+// defaultNextLinkPages bounds a crawl following next links when no page limit is configured
+const defaultNextLinkPages = 1000
+
+// ErrNoMorePages is returned for an index page past the last one, which the next-link strategy
+// finds when a page has no next link
+var ErrNoMorePages = errors.New("no more index pages")
+
+// Pagination says how the URLs of the index pages of a site are found
+type Pagination struct {
+	Strategy     string // PaginationAuto, PaginationTemplate or PaginationNextLink
+	Template     string // page URL with {page}, absolute or relative to the site root
+	NextSelector string // CSS selector of the link to the next page
+	MaxPages     int    // pages crawled at most, 0 for no limit beyond what the strategy finds
+}
+
+// PaginationConfigurable is implemented by adapters whose index pagination can be configured,
+// for when the site changes its URL scheme
+type PaginationConfigurable interface {
+	SetPagination(pagination Pagination)
+}
+
+// ParsePagination reads a pagination spec: "auto", "template:<url with {page}>" or
+// "next:<css selector>"; empty means auto
+func ParsePagination(spec string) (Pagination, error) {
+	strategy, value, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	value = strings.TrimSpace(value)
+
+	switch strategy {
+	case "", PaginationAuto:
+		return Pagination{Strategy: PaginationAuto}, nil
+	case PaginationTemplate:
+		if !strings.Contains(value, "{page}") {
+			return Pagination{}, fmt.Errorf("pagination template %q has no {page}", value)
+		}
+		return Pagination{Strategy: PaginationTemplate, Template: value}, nil
+	case PaginationNextLink:
+		if value == "" {
+			return Pagination{}, fmt.Errorf("next-link pagination needs a css selector")
+		}
+		if _, err := cascadia.Compile(value); err != nil {
+			return Pagination{}, fmt.Errorf("invalid next-link selector %q: %w", value, err)
+		}
+		return Pagination{Strategy: PaginationNextLink, NextSelector: value}, nil
+	}
+	return Pagination{}, fmt.Errorf("unknown pagination strategy %q, expected %s, %s or %s", strategy,
+		PaginationAuto, PaginationTemplate, PaginationNextLink)
+}
+
+// SetPagination sets how the URLs of the index pages are found. A crawl through an adapter passes
+// it on when the adapter is PaginationConfigurable.
+func (s *HomePageScraper) SetPagination(pagination Pagination) {
+	if configurable, ok := s.adapter.(PaginationConfigurable); ok {
+		configurable.SetPagination(pagination)
+	}
+	if pagination.Strategy == "" {
+		pagination.Strategy = PaginationAuto
+	}
+
+	s.pageMutex.Lock()
+	defer s.pageMutex.Unlock()
+	s.pagination = pagination
+	s.nextPages = make(map[int]string)
+}
+
+// indexPages returns how many index pages a full cycle reads. Only the auto and template
+// strategies fetch the home page to find out; a next-link crawl ends at the page without a next
+// link, with ErrNoMorePages.
+func (s *HomePageScraper) indexPages(ctx context.Context) (int, error) {
+	pagination := s.paginationSettings()
+	if pagination.Strategy == PaginationNextLink {
+		if pagination.MaxPages > 0 {
+			return pagination.MaxPages, nil
+		}
+		return defaultNextLinkPages, nil
+	}
+	if pagination.Strategy == PaginationTemplate && pagination.MaxPages > 0 {
+		return pagination.MaxPages, nil
+	}
+
+	totalPages, err := s.getTotalPages(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if pagination.MaxPages > 0 {
+		totalPages = min(totalPages, pagination.MaxPages)
+	}
+	return totalPages, nil
+}
+
+// paginationSettings returns the pagination of the scraper, auto unless set
+func (s *HomePageScraper) paginationSettings() Pagination {
+	s.pageMutex.Lock()
+	defer s.pageMutex.Unlock()
+	pagination := s.pagination
+	if pagination.Strategy == "" {
+		pagination.Strategy = PaginationAuto
+	}
+	return pagination
+}
+
+// pageURL returns the URL of an index page, "" for a page the next-link strategy has not reached
+func (s *HomePageScraper) pageURL(pageNum int) string {
+	s.pageMutex.Lock()
+	defer s.pageMutex.Unlock()
+
+	switch {
+	case pageNum == 1 && s.pagination.Strategy != PaginationTemplate:
+		return s.baseURL
+	case s.pagination.Strategy == PaginationTemplate:
+		return s.normalizeHref(strings.ReplaceAll(s.pagination.Template, "{page}", strconv.Itoa(pageNum)))
+	case s.pagination.Strategy == PaginationNextLink:
+		return s.nextPages[pageNum]
+	}
+	return fmt.Sprintf("%s/?page=%d", s.baseURL, pageNum)
+}
+
+// pastLastPage reports whether a next-link crawl found no link to pageNum on the page before it.
+// Crawls through an adapter learn the end from ErrNoMorePages instead.
+func (s *HomePageScraper) pastLastPage(pageNum int) bool {
+	return s.adapter == nil && s.pageURL(pageNum) == ""
+}
+
+// learnNextPage remembers the URL of the page after pageNum from the next link on doc, under the
+// next-link strategy. A page without one is the last.
+func (s *HomePageScraper) learnNextPage(pageNum int, doc *goquery.Document) {
+	s.pageMutex.Lock()
+	defer s.pageMutex.Unlock()
+	if s.pagination.Strategy != PaginationNextLink {
+		return
+	}
+
+	href, _ := doc.Find(s.pagination.NextSelector).First().Attr("href")
+	if next := s.normalizeHref(href); next != "" {
+		s.nextPages[pageNum+1] = next
+	} else {
+		delete(s.nextPages, pageNum+1)
+	}
+}
@@ -0,0 +1,67 @@
+package scraper
+
+import (
+	"testing"
+)
+
+func TestParsePagination(t *testing.T) {
+	valid := map[string]Pagination{
+		"":                          {Strategy: PaginationAuto},
+		"auto":                      {Strategy: PaginationAuto},
+		"template:/list/{page}.htm": {Strategy: PaginationTemplate, Template: "/list/{page}.htm"},
+		"next: a.next-page":         {Strategy: PaginationNextLink, NextSelector: "a.next-page"},
+	}
+	for spec, expected := range valid {
+		pagination, err := ParsePagination(spec)
+		if err != nil {
+			t.Errorf("Expected %q to parse, got %v", spec, err)
+			continue
+		}
+		if pagination != expected {
+			t.Errorf("Expected %q to parse to %+v, got %+v", spec, expected, pagination)
+		}
+	}
+
+	for _, spec := range []string{"template:/list.htm", "next:", "next:a[", "numbered"} {
+		if _, err := ParsePagination(spec); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+}
+
+func TestPageURLFromTemplate(t *testing.T) {
+	s := &HomePageScraper{baseURL: "https://example.com"}
+	if url := s.pageURL(2); url != "https://example.com/?page=2" {
+		t.Errorf("Expected the auto URL of page 2, got %s", url)
+	}
+
+	s.SetPagination(Pagination{Strategy: PaginationTemplate, Template: "/catalog/page-{page}/"})
+	if url := s.pageURL(1); url != "https://example.com/catalog/page-1/" {
+		t.Errorf("Expected the template URL of page 1, got %s", url)
+	}
+	if url := s.pageURL(12); url != "https://example.com/catalog/page-12/" {
+		t.Errorf("Expected the template URL of page 12, got %s", url)
+	}
+}
+
+func TestPageURLFollowsNextLinks(t *testing.T) {
+	s := &HomePageScraper{baseURL: "https://example.com"}
+	s.SetPagination(Pagination{Strategy: PaginationNextLink, NextSelector: "a.next"})
+
+	if url := s.pageURL(1); url != "https://example.com" {
+		t.Errorf("Expected page 1 to be the home page, got %s", url)
+	}
+	if !s.pastLastPage(2) {
+		t.Errorf("Expected page 2 to be unknown before page 1 is read")
+	}
+
+	s.learnNextPage(1, parseHTML(t, `<html><body><a class="next" href="/list?from=40">Next</a></body></html>`))
+	if url := s.pageURL(2); url != "https://example.com/list?from=40" {
+		t.Errorf("Expected page 2 from the next link, got %s", url)
+	}
+
+	s.learnNextPage(2, parseHTML(t, `<html><body><a href="/list?from=0">Previous</a></body></html>`))
+	if !s.pastLastPage(3) {
+		t.Errorf("Expected page 2 without a next link to be the last")
+	}
+}
@@ -0,0 +1,117 @@
+package scraper
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+)
+
+// DelaySchedule is the randomized pause taken before every index page request
+type DelaySchedule struct {
+	Base   time.Duration // fixed part of every delay
+	Jitter time.Duration // upper bound of the random part added to Base
+}
+
+// Next returns the delay for the next request and the random jitter it contains
+func (d DelaySchedule) Next() (time.Duration, time.Duration) {
+	var jitter time.Duration
+	if d.Jitter > 0 {
+		jitter = rand.N(d.Jitter + 1)
+	}
+	return d.Base + jitter, jitter
+}
+
+// CrawlRequest is the audit record of one index page request: the delay actually slept before it
+// and when it started and finished
+type CrawlRequest struct {
+	Cycle          int
+	CycleStartedAt time.Time
+	Page           int
+	URL            string
+	Schedule       DelaySchedule // schedule in force for the cycle
+	PlannedDelay   time.Duration // delay drawn from the schedule
+	Jitter         time.Duration // random part of PlannedDelay
+	Slept          time.Duration // measured time spent waiting
+	RequestedAt    time.Time
+	CompletedAt    time.Time
+	Links          int
+	Err            error
+}
+
+// AuditFunc receives a CrawlRequest for every index page request made while monitoring
+type AuditFunc func(CrawlRequest)
+
+// SetDelaySchedule sets the randomized delay taken before each index page request
+func (s *HomePageScraper) SetDelaySchedule(schedule DelaySchedule) {
+	s.delay = schedule
+}
+
+// SetAuditFunc sets a callback receiving the audit record of every index page request
+func (s *HomePageScraper) SetAuditFunc(audit AuditFunc) {
+	s.audit = audit
+}
+
+// politeWait waits while the crawl is paused, then sleeps for the next scheduled delay, or until
+// ctx is done, and returns a CrawlRequest with the delay filled in; the caller completes and
+// reports it with finishRequest
+func (s *HomePageScraper) politeWait(ctx context.Context, cycle int, cycleStartedAt time.Time, page int) CrawlRequest {
+	s.waitForGate(ctx)
+	planned, jitter := s.delay.Next()
+
+	started := time.Now()
+	sleepCtx(ctx, planned)
+
+	return CrawlRequest{
+		Cycle:          cycle,
+		CycleStartedAt: cycleStartedAt,
+		Page:           page,
+		URL:            s.crawlPageURL(page),
+		Schedule:       s.delay,
+		PlannedDelay:   planned,
+		Jitter:         jitter,
+		Slept:          time.Since(started),
+		RequestedAt:    clock.Now(),
+	}
+}
+
+// crawlPageURL returns the URL of an index page for the audit record, "" when the adapter reading
+// the index cannot tell
+func (s *HomePageScraper) crawlPageURL(page int) string {
+	if s.adapter == nil {
+		return s.pageURL(page)
+	}
+	if pager, ok := s.adapter.(IndexPager); ok {
+		return pager.IndexPageURL(page)
+	}
+	return ""
+}
+
+// finishRequest stamps the completion time and result on a request and passes it to the audit callback
+func (s *HomePageScraper) finishRequest(request CrawlRequest, links int, err error) {
+	if s.audit == nil {
+		return
+	}
+
+	request.CompletedAt = clock.Now()
+	request.Links = links
+	request.Err = err
+	s.audit(request)
+}
+
+// sleepCtx sleeps for d or until ctx is done and reports whether the full duration elapsed
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
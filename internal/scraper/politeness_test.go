@@ -0,0 +1,54 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDelayScheduleNext(t *testing.T) {
+	schedule := DelaySchedule{Base: time.Second, Jitter: 500 * time.Millisecond}
+
+	for i := 0; i < 100; i++ {
+		delay, jitter := schedule.Next()
+		if jitter < 0 || jitter > schedule.Jitter {
+			t.Fatalf("Expected jitter within [0, %v], got %v", schedule.Jitter, jitter)
+		}
+		if delay != schedule.Base+jitter {
+			t.Fatalf("Expected delay %v, got %v", schedule.Base+jitter, delay)
+		}
+	}
+
+	if delay, jitter := (DelaySchedule{}).Next(); delay != 0 || jitter != 0 {
+		t.Errorf("Expected no delay for an empty schedule, got %v/%v", delay, jitter)
+	}
+}
+
+func TestPoliteWaitAuditsRequest(t *testing.T) {
+	s := NewHomePageScraper()
+	s.SetDelaySchedule(DelaySchedule{Base: 5 * time.Millisecond})
+
+	var audited []CrawlRequest
+	s.SetAuditFunc(func(request CrawlRequest) {
+		audited = append(audited, request)
+	})
+
+	cycleStart := time.Now()
+	request := s.politeWait(context.Background(), 3, cycleStart, 2)
+	s.finishRequest(request, 7, errors.New("boom"))
+
+	if len(audited) != 1 {
+		t.Fatalf("Expected 1 audit record, got %d", len(audited))
+	}
+	got := audited[0]
+	if got.Cycle != 3 || got.Page != 2 || got.Links != 7 || got.Err == nil {
+		t.Errorf("Unexpected audit record %+v", got)
+	}
+	if got.URL != s.BaseURL()+"/?page=2" {
+		t.Errorf("Expected page URL, got %s", got.URL)
+	}
+	if got.Slept < 5*time.Millisecond || got.RequestedAt.Before(cycleStart) || got.CompletedAt.Before(got.RequestedAt) {
+		t.Errorf("Expected measured delay and ordered timestamps, got %+v", got)
+	}
+}
@@ -0,0 +1,213 @@
+package scraper
+
+import (
+	"strings"
+	"unicode"
+)
+
+// maxListingPrice bounds parsed prices; larger values are treated as parse errors
+const maxListingPrice = 10000000
+
+// PriceRange is a price parsed from a pricing table cell; a single price has Min equal to Max
+type PriceRange struct {
+	Min      int32
+	Max      int32
+	Currency string // RUB, USD or EUR when the cell names a currency, empty otherwise
+}
+
+// priceTokenKind is the kind of a token of a price cell
+type priceTokenKind int
+
+const (
+	priceNumber    priceTokenKind = iota
+	priceRange                    // "-", "–", "—" or "до" between two prices
+	priceThousands                // "тыс", "k", "к"
+	priceCurrency
+	pricePhone // "+", only found in phone numbers
+)
+
+// priceToken is one significant token of a price cell; words without meaning are dropped
+type priceToken struct {
+	kind     priceTokenKind
+	value    float64 // priceNumber
+	currency string  // priceCurrency
+}
+
+// priceWords maps the words of price cells to their tokens
+var priceWords = map[string]priceToken{
+	"тыс":  {kind: priceThousands},
+	"k":    {kind: priceThousands},
+	"к":    {kind: priceThousands},
+	"т.р":  {kind: priceThousands},
+	"тр":   {kind: priceThousands},
+	"до":   {kind: priceRange},
+	"руб":  {kind: priceCurrency, currency: "RUB"},
+	"р":    {kind: priceCurrency, currency: "RUB"},
+	"rub":  {kind: priceCurrency, currency: "RUB"},
+	"usd":  {kind: priceCurrency, currency: "USD"},
+	"eur":  {kind: priceCurrency, currency: "EUR"},
+	"евро": {kind: priceCurrency, currency: "EUR"},
+}
+
+// priceSymbols maps the single-rune tokens of price cells to their tokens
+var priceSymbols = map[rune]priceToken{
+	'-': {kind: priceRange}, '–': {kind: priceRange}, '—': {kind: priceRange},
+	'₽': {kind: priceCurrency, currency: "RUB"},
+	'$': {kind: priceCurrency, currency: "USD"},
+	'€': {kind: priceCurrency, currency: "EUR"},
+	'+': {kind: pricePhone},
+}
+
+// ParsePrice parses a pricing table cell holding one price ("5 000 ₽", "3,5 тыс", "$200") or a
+// range ("2000-3000", "3–5k руб"). Spaces, dots and commas before groups of three digits are
+// thousands separators, and a thousands suffix after a range applies to both ends. Cells with
+// any other numbers, such as phone numbers, dates or two prices without a range between them,
+// are rejected rather than guessed at.
+func ParsePrice(text string) (PriceRange, bool) {
+	tokens := tokenizePrice(strings.ToLower(text))
+
+	var numbers []float64
+	var thousands []bool
+	var currency string
+	ranged := false
+	for i, token := range tokens {
+		switch token.kind {
+		case priceNumber:
+			if len(numbers) == 2 || (len(numbers) == 1 && !ranged) {
+				return PriceRange{}, false
+			}
+			numbers = append(numbers, token.value)
+			thousands = append(thousands, false)
+		case priceThousands:
+			if i == 0 || tokens[i-1].kind != priceNumber {
+				return PriceRange{}, false
+			}
+			thousands[len(thousands)-1] = true
+		case priceRange:
+			if len(numbers) != 1 || ranged {
+				return PriceRange{}, false
+			}
+			ranged = true
+		case priceCurrency:
+			if currency != "" && currency != token.currency {
+				return PriceRange{}, false
+			}
+			currency = token.currency
+		case pricePhone:
+			return PriceRange{}, false
+		}
+	}
+	if len(numbers) == 0 || (ranged && len(numbers) != 2) {
+		return PriceRange{}, false
+	}
+
+	// "3-5 тыс" means 3000 to 5000
+	if len(numbers) == 2 && thousands[1] && !thousands[0] && numbers[0] < numbers[1] {
+		thousands[0] = true
+	}
+
+	prices := make([]int32, len(numbers))
+	for i, number := range numbers {
+		if thousands[i] {
+			number *= 1000
+		}
+		if number < 1 || number > maxListingPrice {
+			return PriceRange{}, false
+		}
+		prices[i] = int32(number)
+	}
+
+	result := PriceRange{Min: prices[0], Max: prices[len(prices)-1], Currency: currency}
+	if result.Min > result.Max {
+		result.Min, result.Max = result.Max, result.Min
+	}
+	return result, true
+}
+
+// tokenizePrice splits a lowercased price cell into tokens
+func tokenizePrice(text string) []priceToken {
+	runes := []rune(text)
+	var tokens []priceToken
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case isDigit(r):
+			value, next := scanPriceNumber(runes, i)
+			tokens = append(tokens, priceToken{kind: priceNumber, value: value})
+			i = next
+		case unicode.IsLetter(r):
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			if token, ok := priceWords[string(runes[start:i])]; ok {
+				tokens = append(tokens, token)
+			} else if token, ok := priceWords[strings.TrimSuffix(string(runes[start:i]), ".")]; ok {
+				tokens = append(tokens, token)
+			}
+		default:
+			if token, ok := priceSymbols[r]; ok {
+				tokens = append(tokens, token)
+			}
+			i++
+		}
+	}
+	return tokens
+}
+
+// scanPriceNumber reads the number starting at runes[start] and returns it with the index after
+// it. Groups of exactly three digits after a space, dot or comma continue the number when the
+// first group has at most three digits; one or two digits after a dot or comma are a fraction.
+func scanPriceNumber(runes []rune, start int) (float64, int) {
+	i := start
+	var value float64
+	for i < len(runes) && isDigit(runes[i]) {
+		value = value*10 + float64(runes[i]-'0')
+		i++
+	}
+	grouped := i-start <= 3
+
+	for i < len(runes) && isPriceSeparator(runes[i]) {
+		digits := countDigits(runes, i+1)
+		switch {
+		case grouped && digits == 3:
+			for _, r := range runes[i+1 : i+4] {
+				value = value*10 + float64(r-'0')
+			}
+			i += 4
+			continue
+		case (runes[i] == '.' || runes[i] == ',') && (digits == 1 || digits == 2):
+			scale := 0.1
+			for _, r := range runes[i+1 : i+1+digits] {
+				value += float64(r-'0') * scale
+				scale /= 10
+			}
+			i += 1 + digits
+		}
+		break
+	}
+	return value, i
+}
+
+// isPriceSeparator reports whether r may separate the thousands of a price
+func isPriceSeparator(r rune) bool {
+	switch r {
+	case ' ', '\u00a0', '\u202f', '\u2009', '.', ',', '\'':
+		return true
+	}
+	return false
+}
+
+// countDigits returns the number of consecutive digits starting at runes[start]
+func countDigits(runes []rune, start int) int {
+	n := 0
+	for start+n < len(runes) && isDigit(runes[start+n]) {
+		n++
+	}
+	return n
+}
+
+// isDigit reports whether r is an ASCII digit
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
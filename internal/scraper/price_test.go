@@ -0,0 +1,41 @@
+package scraper
+
+import "testing"
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected PriceRange
+		ok       bool
+	}{
+		{"5000", PriceRange{Min: 5000, Max: 5000}, true},
+		{"5 000 ₽", PriceRange{Min: 5000, Max: 5000, Currency: "RUB"}, true},
+		{"5.000 руб.", PriceRange{Min: 5000, Max: 5000, Currency: "RUB"}, true},
+		{"1,500,000", PriceRange{Min: 1500000, Max: 1500000}, true},
+		{"2000-3000", PriceRange{Min: 2000, Max: 3000}, true},
+		{"от 2 000 до 3 000 р.", PriceRange{Min: 2000, Max: 3000, Currency: "RUB"}, true},
+		{"3–5 тыс", PriceRange{Min: 3000, Max: 5000}, true},
+		{"1 - 1,5 тыс.", PriceRange{Min: 1000, Max: 1500}, true},
+		{"800-1,5k", PriceRange{Min: 800, Max: 1500}, true},
+		{"2,5k", PriceRange{Min: 2500, Max: 2500}, true},
+		{"15 т.р.", PriceRange{Min: 15000, Max: 15000}, true},
+		{"$200", PriceRange{Min: 200, Max: 200, Currency: "USD"}, true},
+		{"150 €", PriceRange{Min: 150, Max: 150, Currency: "EUR"}, true},
+		{"+7 999 123-45-67", PriceRange{}, false},
+		{"8 (999) 123-45-67", PriceRange{}, false},
+		{"3000 4000", PriceRange{}, false},
+		{"$200 ₽", PriceRange{}, false},
+		{"-300", PriceRange{}, false},
+		{"2000-", PriceRange{}, false},
+		{"0", PriceRange{}, false},
+		{"99999999999", PriceRange{}, false},
+		{"по договорённости", PriceRange{}, false},
+	}
+
+	for _, tt := range tests {
+		price, ok := ParsePrice(tt.text)
+		if ok != tt.ok || price != tt.expected {
+			t.Errorf("ParsePrice(%q) = %+v, %v, expected %+v, %v", tt.text, price, ok, tt.expected, tt.ok)
+		}
+	}
+}
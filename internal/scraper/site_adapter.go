@@ -0,0 +1,145 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gregor-tokarev/hoe_parser/internal/archive"
+	listing "github.com/gregor-tokarev/hoe_parser/proto"
+)
+
+// ErrNoSiteAdapter is matched by errors returned for URLs no registered adapter handles
+var ErrNoSiteAdapter = errors.New("no site adapter for url")
+
+// SiteAdapter scrapes one source site. New sources implement it and register themselves
+// with Register from an init function, so the pipeline picks them up without changes.
+type SiteAdapter interface {
+	// Name returns the source site key, e.g. intimcity.gold
+	Name() string
+	// MatchesURL reports whether the adapter handles a listing or index page URL
+	MatchesURL(rawURL string) bool
+	// ScrapeListing scrapes a single listing page
+	ScrapeListing(ctx context.Context, rawURL string) (*listing.Listing, error)
+	// ScrapeIndex returns the listing links found on an index page, starting at 1, and
+	// ErrNoMorePages past the last page when the adapter knows where the index ends
+	ScrapeIndex(ctx context.Context, page int) ([]ListingLink, error)
+}
+
+// IndexPager is implemented by adapters that know the extent of their index, so a full index
+// crawl reads exactly their pages instead of stopping at the first page without links
+type IndexPager interface {
+	// IndexPages returns how many index pages a full crawl reads
+	IndexPages(ctx context.Context) (int, error)
+	// IndexPageURL returns the URL of an index page, "" when it is not known yet
+	IndexPageURL(page int) string
+}
+
+// IndexDocumentScraper is implemented by adapters whose index pages are HTML documents, so the
+// index crawl can fingerprint them and skip the pages that did not change
+type IndexDocumentScraper interface {
+	// ScrapeIndexDocument returns an index page, starting at 1, with its listing links
+	ScrapeIndexDocument(ctx context.Context, page int) (*goquery.Document, []ListingLink, error)
+}
+
+// ListingLinker is implemented by adapters that can build the link of a listing from its ID, so
+// the IDs discovery missed can be scraped
+type ListingLinker interface {
+	ListingLink(id string) ListingLink
+}
+
+// TelegramConfigurable is implemented by adapters that extract Telegram handles
+type TelegramConfigurable interface {
+	SetTelegramResolver(resolver TelegramResolver)
+	SetTelegramMinConfidence(minConfidence float64)
+}
+
+// MobileFallbackConfigurable is implemented by adapters that can fetch a listing from the
+// site's mobile version when the desktop version is blocked
+type MobileFallbackConfigurable interface {
+	SetMobileFallback(enabled bool)
+}
+
+// PageArchiveConfigurable is implemented by adapters that can keep the raw HTML of the listing
+// pages they fetch, for parsing them again offline
+type PageArchiveConfigurable interface {
+	SetPageArchive(store archive.Store)
+}
+
+// PageParser is implemented by adapters that can extract a listing from a stored page without
+// fetching it, as the conformance fixtures of `hoe_parser verify-site` need
+type PageParser interface {
+	ParseListingPage(ctx context.Context, rawURL string, page []byte) (*listing.Listing, error)
+}
+
+// DocumentParser is implemented by adapters that can extract a listing from an already parsed
+// page, so embedders with their own crawler reuse only the extraction logic
+type DocumentParser interface {
+	ParseListingDocument(ctx context.Context, rawURL string, doc *goquery.Document) (*listing.Listing, error)
+}
+
+// Registry dispatches URLs to the site adapter that handles them
+type Registry struct {
+	mu       sync.RWMutex
+	adapters []SiteAdapter
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds an adapter; adapters registered earlier win when several match a URL
+func (r *Registry) Register(adapter SiteAdapter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.adapters = append(r.adapters, adapter)
+}
+
+// ForURL returns the adapter handling rawURL
+func (r *Registry) ForURL(rawURL string) (SiteAdapter, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, adapter := range r.adapters {
+		if adapter.MatchesURL(rawURL) {
+			return adapter, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNoSiteAdapter, rawURL)
+}
+
+// Adapters returns the registered adapters in registration order
+func (r *Registry) Adapters() []SiteAdapter {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]SiteAdapter, len(r.adapters))
+	copy(result, r.adapters)
+	return result
+}
+
+// DefaultRegistry holds the adapters of every supported site
+var DefaultRegistry = NewRegistry()
+
+// Register adds an adapter to the default registry
+func Register(adapter SiteAdapter) {
+	DefaultRegistry.Register(adapter)
+}
+
+// AdapterByName returns the adapter in the default registry for a source site key
+func AdapterByName(name string) (SiteAdapter, bool) {
+	for _, adapter := range DefaultRegistry.Adapters() {
+		if adapter.Name() == name {
+			return adapter, true
+		}
+	}
+	return nil, false
+}
+
+// AdapterForURL returns the adapter in the default registry handling rawURL
+func AdapterForURL(rawURL string) (SiteAdapter, error) {
+	return DefaultRegistry.ForURL(rawURL)
+}
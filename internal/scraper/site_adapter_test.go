@@ -0,0 +1,59 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	listing "github.com/gregor-tokarev/hoe_parser/proto"
+)
+
+type fakeAdapter struct {
+	name string
+	host string
+}
+
+func (f fakeAdapter) Name() string                  { return f.name }
+func (f fakeAdapter) MatchesURL(rawURL string) bool { return rawURL == f.host }
+func (f fakeAdapter) ScrapeListing(ctx context.Context, rawURL string) (*listing.Listing, error) {
+	return &listing.Listing{Id: f.name}, nil
+}
+func (f fakeAdapter) ScrapeIndex(ctx context.Context, page int) ([]ListingLink, error) {
+	return nil, nil
+}
+
+func TestRegistryDispatchesByURL(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(NewIntimcityAdapter())
+	registry.Register(fakeAdapter{name: "other", host: "https://other.example/1"})
+
+	tests := map[string]string{
+		"https://b.intimcity.gold/anketa123.htm": "intimcity.gold",
+		"https://intimcity.gold/":                "intimcity.gold",
+		"https://other.example/1":                "other",
+	}
+	for url, expected := range tests {
+		adapter, err := registry.ForURL(url)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", url, err)
+			continue
+		}
+		if adapter.Name() != expected {
+			t.Errorf("%s: expected adapter %s, got %s", url, expected, adapter.Name())
+		}
+	}
+
+	if _, err := registry.ForURL("https://notintimcity.gold/anketa1.htm"); !errors.Is(err, ErrNoSiteAdapter) {
+		t.Errorf("Expected ErrNoSiteAdapter for an unknown host, got %v", err)
+	}
+}
+
+func TestDefaultRegistryHasIntimcity(t *testing.T) {
+	adapter, err := AdapterForURL("https://b.intimcity.gold/anketa1.htm")
+	if err != nil {
+		t.Fatalf("Expected the intimcity adapter to be registered, got %v", err)
+	}
+	if _, ok := adapter.(TelegramConfigurable); !ok {
+		t.Errorf("Expected the intimcity adapter to accept Telegram settings")
+	}
+}
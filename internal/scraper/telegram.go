@@ -0,0 +1,225 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Telegram candidate sources, from most to least trustworthy
+const (
+	TelegramSourceLink    = "link"    // t.me / telegram.me link
+	TelegramSourceLabel   = "label"   // handle next to a "telegram" / "тг" label
+	TelegramSourceMention = "mention" // bare @mention anywhere in the page text
+)
+
+// telegramSourceConfidence is the base confidence of a candidate by where it was found
+var telegramSourceConfidence = map[string]float64{
+	TelegramSourceLink:    0.9,
+	TelegramSourceLabel:   0.7,
+	TelegramSourceMention: 0.3,
+}
+
+// DefaultTelegramMinConfidence is the confidence a candidate needs to become the validated handle
+const DefaultTelegramMinConfidence = 0.5
+
+var (
+	// telegramUsername follows Telegram's username rules: 5-32 characters of latin letters,
+	// digits and underscores, starting with a letter and not ending with an underscore
+	telegramUsername = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{3,30}[a-zA-Z0-9]$`)
+
+	telegramLinkPattern    = regexp.MustCompile(`(?i)(?:https?://)?(?:t\.me|telegram\.me|telegram\.dog)/@?([a-zA-Z0-9_]+)`)
+	telegramLabelPattern   = regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}_])(?:telegram|телеграмм?|телега|tg|тг)\s*[:\-–]?\s*@?([a-zA-Z][a-zA-Z0-9_]+)`)
+	telegramMentionPattern = regexp.MustCompile(`(?:^|[^a-zA-Z0-9_.@])@([a-zA-Z][a-zA-Z0-9_]+)`)
+)
+
+// telegramIgnoredHandles are t.me paths and mentions that never identify a listing's owner
+var telegramIgnoredHandles = map[string]bool{
+	"share":       true,
+	"joinchat":    true,
+	"addstickers": true,
+	"proxy":       true,
+	"socks":       true,
+	"intimcity":   true,
+	"whatsapp":    true,
+	"viber":       true,
+}
+
+// TelegramCandidate is a possible Telegram handle found on a listing page
+type TelegramCandidate struct {
+	Handle     string  // username without the leading @
+	Source     string  // strongest source the handle was found in
+	Confidence float64 // 0..1
+}
+
+// TelegramResolver checks whether a Telegram handle exists
+type TelegramResolver interface {
+	Exists(ctx context.Context, handle string) (bool, error)
+}
+
+// extractTelegramCandidates collects every plausible handle on the page, scored by source.
+// Handles found by more than one source get a small boost.
+func extractTelegramCandidates(doc *goquery.Document) []TelegramCandidate {
+	found := make(map[string]*TelegramCandidate)
+	sources := make(map[string]map[string]bool)
+
+	add := func(raw, source string) {
+		handle := strings.TrimPrefix(strings.TrimSpace(raw), "@")
+		if !telegramUsername.MatchString(handle) || telegramIgnoredHandles[strings.ToLower(handle)] {
+			return
+		}
+
+		key := strings.ToLower(handle)
+		if sources[key] == nil {
+			sources[key] = make(map[string]bool)
+		}
+		sources[key][source] = true
+
+		confidence := telegramSourceConfidence[source]
+		if existing, ok := found[key]; !ok || confidence > existing.Confidence {
+			found[key] = &TelegramCandidate{Handle: handle, Source: source, Confidence: confidence}
+		}
+	}
+
+	doc.Find("a[href]").Each(func(i int, sel *goquery.Selection) {
+		href, _ := sel.Attr("href")
+		if matches := telegramLinkPattern.FindStringSubmatch(href); len(matches) > 1 {
+			add(matches[1], TelegramSourceLink)
+		}
+	})
+
+	text := doc.Text()
+	for _, matches := range telegramLinkPattern.FindAllStringSubmatch(text, -1) {
+		add(matches[1], TelegramSourceLink)
+	}
+	for _, matches := range telegramLabelPattern.FindAllStringSubmatch(text, -1) {
+		add(matches[1], TelegramSourceLabel)
+	}
+	for _, matches := range telegramMentionPattern.FindAllStringSubmatch(text, -1) {
+		add(matches[1], TelegramSourceMention)
+	}
+
+	candidates := make([]TelegramCandidate, 0, len(found))
+	for key, candidate := range found {
+		if len(sources[key]) > 1 {
+			candidate.Confidence = math.Min(candidate.Confidence+0.1, 1)
+		}
+		candidates = append(candidates, *candidate)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Confidence != candidates[j].Confidence {
+			return candidates[i].Confidence > candidates[j].Confidence
+		}
+		return candidates[i].Handle < candidates[j].Handle
+	})
+	return candidates
+}
+
+// validateTelegram picks the validated handle from candidates. With a resolver, candidates above
+// minConfidence are looked up in order: a confirmed handle gets confidence 1, a handle the lookup
+// cannot find is demoted. Lookup errors leave the confidence unchanged.
+func validateTelegram(ctx context.Context, candidates []TelegramCandidate, resolver TelegramResolver, minConfidence float64) (string, float64) {
+	for i := range candidates {
+		candidate := &candidates[i]
+		if candidate.Confidence < minConfidence {
+			break
+		}
+		if resolver == nil {
+			return "@" + candidate.Handle, candidate.Confidence
+		}
+
+		exists, err := resolver.Exists(ctx, candidate.Handle)
+		if err != nil {
+			return "@" + candidate.Handle, candidate.Confidence
+		}
+		if exists {
+			candidate.Confidence = 1
+			return "@" + candidate.Handle, candidate.Confidence
+		}
+		candidate.Confidence /= 2
+	}
+
+	return "", 0
+}
+
+// BotAPIResolver checks handles with the Telegram Bot API getChat method. getChat only resolves
+// public chats and users the bot has seen, so a miss demotes a candidate rather than dropping it.
+type BotAPIResolver struct {
+	token   string
+	baseURL string
+	client  *http.Client
+
+	cacheMutex sync.Mutex
+	cache      map[string]bool
+}
+
+// NewBotAPIResolver creates a resolver using the given bot token
+func NewBotAPIResolver(token string, timeout time.Duration) *BotAPIResolver {
+	return &BotAPIResolver{
+		token:   token,
+		baseURL: "https://api.telegram.org",
+		client:  &http.Client{Timeout: timeout},
+		cache:   make(map[string]bool),
+	}
+}
+
+// botAPIResponse is the envelope of every Bot API response
+type botAPIResponse struct {
+	OK          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+}
+
+// Exists reports whether the handle resolves to a chat. Results are cached for the resolver's lifetime.
+func (r *BotAPIResolver) Exists(ctx context.Context, handle string) (bool, error) {
+	key := strings.ToLower(handle)
+
+	r.cacheMutex.Lock()
+	exists, cached := r.cache[key]
+	r.cacheMutex.Unlock()
+	if cached {
+		return exists, nil
+	}
+
+	endpoint := fmt.Sprintf("%s/bot%s/getChat?chat_id=%s", r.baseURL, r.token, url.QueryEscape("@"+handle))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create getChat request: %w", err)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to call getChat: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body botAPIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return false, fmt.Errorf("failed to decode getChat response: %w", err)
+	}
+
+	switch {
+	case body.OK:
+		exists = true
+	case body.ErrorCode == http.StatusBadRequest:
+		exists = false
+	default:
+		return false, fmt.Errorf("getChat failed with code %d: %s", body.ErrorCode, body.Description)
+	}
+
+	r.cacheMutex.Lock()
+	r.cache[key] = exists
+	r.cacheMutex.Unlock()
+	return exists, nil
+}
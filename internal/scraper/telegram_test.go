@@ -0,0 +1,110 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func parseTestDocument(t *testing.T, html string) *goquery.Document {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+	return doc
+}
+
+func TestExtractTelegramCandidates(t *testing.T) {
+	doc := parseTestDocument(t, `<html><body>
+		<p>Пишите в Телеграм: @real_handle</p>
+		<a href="https://t.me/real_handle">написать</a>
+		<p>Фото от @photo_studio, почта anna@mail.ru, канал t.me/joinchat</p>
+		<p>@bad_ @abc @1digitstart</p>
+	</body></html>`)
+
+	candidates := extractTelegramCandidates(doc)
+	if len(candidates) != 2 {
+		t.Fatalf("Expected 2 candidates, got %+v", candidates)
+	}
+
+	if candidates[0].Handle != "real_handle" || candidates[0].Source != TelegramSourceLink {
+		t.Errorf("Expected real_handle from a link first, got %+v", candidates[0])
+	}
+	if candidates[0].Confidence != 1 {
+		t.Errorf("Expected confidence boosted to 1 for a handle found by several sources, got %v", candidates[0].Confidence)
+	}
+	if candidates[1].Handle != "photo_studio" || candidates[1].Source != TelegramSourceMention {
+		t.Errorf("Expected photo_studio as a bare mention, got %+v", candidates[1])
+	}
+}
+
+func TestValidateTelegramRejectsMentionsOnly(t *testing.T) {
+	candidates := []TelegramCandidate{{Handle: "photo_studio", Source: TelegramSourceMention, Confidence: 0.3}}
+
+	handle, confidence := validateTelegram(context.Background(), candidates, nil, DefaultTelegramMinConfidence)
+	if handle != "" || confidence != 0 {
+		t.Errorf("Expected no validated handle, got %q (%v)", handle, confidence)
+	}
+}
+
+type fakeResolver map[string]bool
+
+func (f fakeResolver) Exists(ctx context.Context, handle string) (bool, error) {
+	exists, ok := f[handle]
+	if !ok {
+		return false, fmt.Errorf("lookup failed")
+	}
+	return exists, nil
+}
+
+func TestValidateTelegramWithResolver(t *testing.T) {
+	candidates := []TelegramCandidate{
+		{Handle: "gone_handle", Source: TelegramSourceLink, Confidence: 0.9},
+		{Handle: "live_handle", Source: TelegramSourceLabel, Confidence: 0.7},
+	}
+	resolver := fakeResolver{"gone_handle": false, "live_handle": true}
+
+	handle, confidence := validateTelegram(context.Background(), candidates, resolver, DefaultTelegramMinConfidence)
+	if handle != "@live_handle" || confidence != 1 {
+		t.Errorf("Expected @live_handle confirmed with confidence 1, got %q (%v)", handle, confidence)
+	}
+}
+
+func TestBotAPIResolverExists(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Query().Get("chat_id") == "@live_handle" {
+			fmt.Fprint(w, `{"ok":true,"result":{"id":1}}`)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`)
+	}))
+	defer server.Close()
+
+	resolver := NewBotAPIResolver("token", time.Second)
+	resolver.baseURL = server.URL
+
+	for handle, expected := range map[string]bool{"live_handle": true, "gone_handle": false} {
+		exists, err := resolver.Exists(context.Background(), handle)
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %v", handle, err)
+		}
+		if exists != expected {
+			t.Errorf("Expected %v for %s, got %v", expected, handle, exists)
+		}
+	}
+
+	resolver.Exists(context.Background(), "live_handle")
+	if requests != 2 {
+		t.Errorf("Expected cached lookups to skip the API, got %d requests", requests)
+	}
+}
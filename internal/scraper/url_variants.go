@@ -0,0 +1,93 @@
+package scraper
+
+import (
+	"net/url"
+	"strings"
+)
+
+// mobileHostPrefixes are the subdomain labels sites use for their mobile version
+var mobileHostPrefixes = []string{"m.", "mobile.", "pda."}
+
+// desktopHosts maps mobile hosts to the desktop mirror serving the same anketa pages.
+// Mobile hosts not listed here lose their mobile label.
+var desktopHosts = map[string]string{
+	"m.intimcity.gold": "b.intimcity.gold",
+}
+
+// IsMobileURL reports whether rawURL points at the mobile version of a site
+func IsMobileURL(rawURL string) bool {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return false
+	}
+	return mobileHost(strings.ToLower(parsed.Hostname()))
+}
+
+// CanonicalListingURL returns the desktop URL of a listing, used as its identity for storage and
+// dedup: mobile hosts and mobile switches are replaced, the scheme becomes https, the host is
+// lowercased and the fragment is dropped. Unparseable URLs are returned unchanged.
+func CanonicalListingURL(rawURL string) string {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || parsed.Host == "" {
+		return rawURL
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	if desktop, exists := desktopHosts[host]; exists {
+		host = desktop
+	} else {
+		for _, prefix := range mobileHostPrefixes {
+			if strings.HasPrefix(host, prefix) {
+				host = strings.TrimPrefix(host, prefix)
+				break
+			}
+		}
+	}
+	if port := parsed.Port(); port != "" {
+		host += ":" + port
+	}
+
+	// ?mobile=1 and ?version=mobile switch the rendering and never identify a listing
+	query := parsed.Query()
+	query.Del("mobile")
+	if version := strings.ToLower(query.Get("version")); version == "mobile" || version == "m" {
+		query.Del("version")
+	}
+
+	parsed.Scheme = "https"
+	parsed.Host = host
+	parsed.RawQuery = query.Encode()
+	parsed.Fragment = ""
+	return parsed.String()
+}
+
+// MobileListingURL returns the mobile variant of a listing URL, fetched when the desktop site
+// blocks us. It reports false when the site has no known mobile host.
+func MobileListingURL(rawURL string) (string, bool) {
+	canonical, err := url.Parse(CanonicalListingURL(rawURL))
+	if err != nil || canonical.Host == "" {
+		return "", false
+	}
+
+	host := canonical.Hostname()
+	for mobile, desktop := range desktopHosts {
+		if desktop == host {
+			canonical.Host = mobile
+			return canonical.String(), true
+		}
+	}
+	return "", false
+}
+
+// mobileHost reports whether host is a known or conventionally named mobile host
+func mobileHost(host string) bool {
+	if _, exists := desktopHosts[host]; exists {
+		return true
+	}
+	for _, prefix := range mobileHostPrefixes {
+		if strings.HasPrefix(host, prefix) {
+			return true
+		}
+	}
+	return false
+}
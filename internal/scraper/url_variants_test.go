@@ -0,0 +1,52 @@
+package scraper
+
+import "testing"
+
+func TestCanonicalListingURL(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"https://b.intimcity.gold/anketa123.htm", "https://b.intimcity.gold/anketa123.htm"},
+		{"https://m.intimcity.gold/anketa123.htm", "https://b.intimcity.gold/anketa123.htm"},
+		{"http://M.Intimcity.Gold/anketa123.htm#photos", "https://b.intimcity.gold/anketa123.htm"},
+		{"https://b.intimcity.gold/anketa123.htm?mobile=1", "https://b.intimcity.gold/anketa123.htm"},
+		{"https://b.intimcity.gold/anketa123.htm?version=mobile&p=2", "https://b.intimcity.gold/anketa123.htm?p=2"},
+		{"https://m.example.com:8080/girl/5", "https://example.com:8080/girl/5"},
+		{"/anketa123.htm", "/anketa123.htm"},
+	}
+
+	for _, test := range tests {
+		if got := CanonicalListingURL(test.input); got != test.expected {
+			t.Errorf("CanonicalListingURL(%q): expected %q, got %q", test.input, test.expected, got)
+		}
+	}
+}
+
+func TestMobileListingURL(t *testing.T) {
+	got, ok := MobileListingURL("https://b.intimcity.gold/anketa123.htm")
+	if !ok || got != "https://m.intimcity.gold/anketa123.htm" {
+		t.Errorf("Expected mobile variant https://m.intimcity.gold/anketa123.htm, got %q (%v)", got, ok)
+	}
+
+	got, ok = MobileListingURL("https://m.intimcity.gold/anketa123.htm?mobile=1")
+	if !ok || got != "https://m.intimcity.gold/anketa123.htm" {
+		t.Errorf("Expected mobile URL to round-trip, got %q (%v)", got, ok)
+	}
+
+	if _, ok := MobileListingURL("https://example.com/girl/5"); ok {
+		t.Errorf("Expected no mobile variant for a site without a known mobile host")
+	}
+}
+
+func TestIsMobileURL(t *testing.T) {
+	if !IsMobileURL("https://m.intimcity.gold/anketa123.htm") {
+		t.Errorf("Expected m.intimcity.gold to be mobile")
+	}
+	if !IsMobileURL("https://pda.example.com/") {
+		t.Errorf("Expected pda. host to be mobile")
+	}
+	if IsMobileURL("https://b.intimcity.gold/anketa123.htm") {
+		t.Errorf("Expected b.intimcity.gold not to be mobile")
+	}
+}
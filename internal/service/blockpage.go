@@ -0,0 +1,124 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Reasons a page served with a 200 is taken for a block page
+const (
+	BlockChallenge = "challenge"  // an anti-bot JavaScript challenge
+	BlockCaptcha   = "captcha"    // a CAPTCHA form
+	BlockSmallBody = "small_body" // a body shorter than any real page of the site
+)
+
+// challengeMarkers are strings of the JavaScript challenge pages anti-bot services serve instead of
+// the requested page
+var challengeMarkers = []string{
+	"cf-browser-verification",
+	"challenge-platform",
+	"<title>Just a moment...</title>",
+	"Checking your browser before accessing",
+	"DDoS-Guard",
+	"Please enable JavaScript and cookies",
+}
+
+// captchaPageMarkers are strings only found on the CAPTCHA pages sites put in front of their pages
+var captchaPageMarkers = []string{
+	"/showcaptcha",
+}
+
+// captchaWidgetMarkers are strings of CAPTCHA widgets. Real pages embed them too, in contact and
+// login forms, so they only make a block page on a page with next to no text besides the widget.
+var captchaWidgetMarkers = []string{
+	"g-recaptcha",
+	"h-captcha",
+	"cf-turnstile",
+	"smartcaptcha",
+	"Подтвердите, что вы не робот",
+}
+
+// captchaPageMaxText is the visible text, in characters, of the largest page a CAPTCHA widget
+// makes a block page
+const captchaPageMaxText = 500
+
+// ErrBlockPage is matched by errors returned for block pages
+var ErrBlockPage = errors.New("block page served")
+
+// BlockPageError is returned instead of a page the site answered with a block page
+type BlockPageError struct {
+	Reason string // BlockChallenge, BlockCaptcha or BlockSmallBody
+	Size   int    // body size in bytes
+}
+
+// Error implements the error interface
+func (e *BlockPageError) Error() string {
+	return fmt.Sprintf("received a %s block page of %d bytes", e.Reason, e.Size)
+}
+
+// Is makes errors.Is(err, ErrBlockPage) match
+func (e *BlockPageError) Is(target error) bool {
+	return target == ErrBlockPage
+}
+
+// blockPageMinSize is the size in bytes below which a page counts as a block page, 0 disables the check
+var blockPageMinSize atomic.Int64
+
+// SetBlockPageMinSize makes pages shorter than size bytes fail as block pages; 0, the default,
+// disables the size check
+func SetBlockPageMinSize(size int) {
+	blockPageMinSize.Store(int64(size))
+}
+
+// DetectBlockPage returns why a page served with a 200 is a block page, "" when it looks like a
+// real page. The body is given as served; it is decoded from its charset first, so markers in
+// Russian match pages served in windows-1251 too.
+func DetectBlockPage(body []byte, contentType string) string {
+	decoded := DecodePage(body, contentType)
+	for _, marker := range challengeMarkers {
+		if bytes.Contains(decoded, []byte(marker)) {
+			return BlockChallenge
+		}
+	}
+	for _, marker := range captchaPageMarkers {
+		if bytes.Contains(decoded, []byte(marker)) {
+			return BlockCaptcha
+		}
+	}
+	for _, marker := range captchaWidgetMarkers {
+		if bytes.Contains(decoded, []byte(marker)) && visibleTextLength(decoded) <= captchaPageMaxText {
+			return BlockCaptcha
+		}
+	}
+	if minSize := blockPageMinSize.Load(); minSize > 0 && int64(len(bytes.TrimSpace(body))) < minSize {
+		return BlockSmallBody
+	}
+	return ""
+}
+
+// visibleTextLength returns the characters of text a page shows, scripts and styles left out and
+// runs of whitespace counted once
+func visibleTextLength(body []byte) int {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return len(body)
+	}
+	doc.Find("script, style, noscript, template").Remove()
+	return utf8.RuneCountInString(strings.Join(strings.Fields(doc.Text()), " "))
+}
+
+// hostOf returns the lowercased host of a page URL, "" when it does not parse
+func hostOf(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
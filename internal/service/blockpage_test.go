@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+	"github.com/gregor-tokarev/hoe_parser/internal/modules/request_client"
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestDetectBlockPage(t *testing.T) {
+	SetBlockPageMinSize(100)
+	defer SetBlockPageMinSize(0)
+
+	page := "<html><body><h1>Anna</h1>" + strings.Repeat("<p>about</p>", 20) + "</body></html>"
+	listing := "<html><body><h1>Anna</h1>" + strings.Repeat("<p>Elegant and discreet, incall and outcall.</p>", 20) +
+		"<form><div class=\"g-recaptcha\"></div><button>Send</button></form></body></html>"
+	robot := `<html><head><meta charset="windows-1251"></head><body><p>Подтвердите, что вы не робот</p>` +
+		`<div class="smart-captcha"></div></body></html>`
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		want        string
+	}{
+		{"page", []byte(page), "", ""},
+		{"cloudflare", []byte("<html><head><title>Just a moment...</title></head><body>" + page + "</body></html>"), "", BlockChallenge},
+		{"recaptcha", []byte(strings.Replace(page, "<h1>", "<div class=\"g-recaptcha\"></div><h1>", 1)), "", BlockCaptcha},
+		{"recaptcha form", []byte(listing), "", ""},
+		{"windows-1251", encode(t, charmap.Windows1251, robot), "text/html; charset=windows-1251", BlockCaptcha},
+		{"showcaptcha", []byte(`<html><body><form action="/showcaptcha?cc=1"></form>` + page + "</body></html>"), "", BlockCaptcha},
+		{"small", []byte("<html><body></body></html>"), "", BlockSmallBody},
+	}
+	for _, test := range tests {
+		if got := DetectBlockPage(test.body, test.contentType); got != test.want {
+			t.Errorf("%s: expected %q, got %q", test.name, test.want, got)
+		}
+	}
+}
+
+func TestFetchAndParsePageBlockPage(t *testing.T) {
+	// The test server acts as the proxy and answers every page with a CAPTCHA
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><body><form><div class=\"h-captcha\"></div></form></body></html>"))
+	}))
+	defer proxy.Close()
+
+	request_client.ResetGlobalClient()
+	defer request_client.ResetGlobalClient()
+	request_client.InitGlobalClient(&config.Config{Proxies: []string{proxy.URL}})
+
+	_, err := FetchAndParsePage(context.Background(), "http://example.com/captcha")
+	var blockPage *BlockPageError
+	if !errors.As(err, &blockPage) || blockPage.Reason != BlockCaptcha {
+		t.Fatalf("Expected a captcha block page error, got %v", err)
+	}
+	if !IsBlocked(err) {
+		t.Errorf("Expected a block page to count as blocked")
+	}
+}
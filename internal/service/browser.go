@@ -0,0 +1,420 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+	"github.com/gregor-tokarev/hoe_parser/internal/metrics"
+	"github.com/gregor-tokarev/hoe_parser/internal/modules/request_client"
+)
+
+// Reasons a page is rendered in the browser after the plain fetch
+const (
+	BrowserReasonEmpty        = "empty"        // the page has no text, its content is built by scripts
+	BrowserReasonInterstitial = "interstitial" // a JavaScript challenge or a CAPTCHA was served instead of the page
+	BrowserReasonBlocked      = "blocked"      // the plain fetch was refused with a block status
+)
+
+// renderedContentType is the type of the pages the browser returns
+const renderedContentType = "text/html; charset=utf-8"
+
+// browserMaxRenders is how many pages a browser renders before it is restarted, so memory leaked
+// by pages does not build up
+const browserMaxRenders = 100
+
+// browserSettle is how long scripts get after the page loaded to replace an interstitial or
+// fill an empty shell, checked every browserPoll
+const (
+	browserSettle = 10 * time.Second
+	browserPoll   = 500 * time.Millisecond
+)
+
+var (
+	// ErrEmptyRender is returned when the browser rendered no page
+	ErrEmptyRender = errors.New("browser rendered an empty page")
+	// ErrBrowserClosed is returned by fetches after Close
+	ErrBrowserClosed = errors.New("browser fetcher closed")
+)
+
+// externalRouter is implemented by clients routing fetches they do not send themselves, like
+// *request_client.ProxyClient
+type externalRouter interface {
+	DoExternal(ctx context.Context, url string, fetch request_client.ExternalFetch) error
+}
+
+// pooledBrowser is a running browser of the pool
+type pooledBrowser struct {
+	ctx     context.Context    // browser context, pages open tabs under it
+	cancel  context.CancelFunc // stops the browser
+	renders int
+}
+
+// BrowserFetcher renders pages in headless Chrome or Chromium and returns their DOM after
+// scripts ran, for sites answering plain requests with a JavaScript challenge or an empty shell.
+// Browsers are started on demand and reused; at most maxInstances render at once and further
+// fetches wait for a free one. Every page opens in a fresh browser context, so no cookies or
+// cache carry over between listings, with the proxy the HTTP client picked for the attempt.
+type BrowserFetcher struct {
+	binary  string
+	timeout time.Duration
+	slots   chan struct{}
+
+	mutex  sync.Mutex
+	idle   []*pooledBrowser
+	closed bool
+
+	// render renders one attempt, replaced in tests
+	render func(ctx context.Context, rawURL string, attempt request_client.ExternalAttempt) ([]byte, int, error)
+}
+
+// NewBrowserFetcher creates a fetcher running binary, e.g. chromium or google-chrome, with at
+// most maxInstances browsers and at most timeout per page
+func NewBrowserFetcher(binary string, maxInstances int, timeout time.Duration) *BrowserFetcher {
+	if maxInstances < 1 {
+		maxInstances = 1
+	}
+	fetcher := &BrowserFetcher{binary: binary, timeout: timeout, slots: make(chan struct{}, maxInstances)}
+	fetcher.render = fetcher.renderInBrowser
+	return fetcher
+}
+
+// InUse returns the number of pages rendering and the most that may render at once
+func (f *BrowserFetcher) InUse() (running, capacity int) {
+	return len(f.slots), cap(f.slots)
+}
+
+// Close stops the idle browsers; browsers still rendering stop when their page is done
+func (f *BrowserFetcher) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.closed = true
+	for _, browser := range f.idle {
+		browser.cancel()
+	}
+	f.idle = nil
+	return nil
+}
+
+// Fetch renders the page at rawURL and returns its DOM as UTF-8 HTML. The render goes through
+// the HTTP client like a plain fetch: it picks the proxy, waits for the site's rate limit and
+// counts every attempt against the retry budget.
+func (f *BrowserFetcher) Fetch(ctx context.Context, rawURL string) ([]byte, error) {
+	router, ok := currentHTTPClient().(externalRouter)
+	if !ok {
+		return nil, errors.New("http client cannot route browser fetches")
+	}
+
+	var body []byte
+	var statusCode int
+	err := router.DoExternal(ctx, rawURL, func(ctx context.Context, attempt request_client.ExternalAttempt) (int, error) {
+		var err error
+		body, statusCode, err = f.render(ctx, rawURL, attempt)
+		return statusCode, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if statusCode >= http.StatusBadRequest {
+		return nil, &StatusError{StatusCode: statusCode}
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, ErrEmptyRender
+	}
+	return body, nil
+}
+
+// renderInBrowser renders rawURL in a browser of the pool through the proxy of attempt and
+// returns the DOM with the status of the page
+func (f *BrowserFetcher) renderInBrowser(ctx context.Context, rawURL string, attempt request_client.ExternalAttempt) ([]byte, int, error) {
+	select {
+	case f.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, 0, fmt.Errorf("waiting for a browser cancelled: %w", ctx.Err())
+	}
+	defer func() { <-f.slots }()
+
+	browser, err := f.acquire()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.release(browser)
+
+	return renderPage(ctx, browser.ctx, rawURL, attempt, f.timeout)
+}
+
+// acquire returns an idle browser, or starts one. The slots keep the browsers at maxInstances.
+func (f *BrowserFetcher) acquire() (*pooledBrowser, error) {
+	f.mutex.Lock()
+	if f.closed {
+		f.mutex.Unlock()
+		return nil, ErrBrowserClosed
+	}
+	if n := len(f.idle); n > 0 {
+		browser := f.idle[n-1]
+		f.idle = f.idle[:n-1]
+		f.mutex.Unlock()
+		return browser, nil
+	}
+	f.mutex.Unlock()
+
+	return startBrowser(f.binary)
+}
+
+// release returns a browser to the pool, or stops it once it crashed, rendered its share of pages
+// or the fetcher was closed
+func (f *BrowserFetcher) release(browser *pooledBrowser) {
+	browser.renders++
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.closed || browser.renders >= browserMaxRenders || browser.ctx.Err() != nil {
+		browser.cancel()
+		return
+	}
+	f.idle = append(f.idle, browser)
+}
+
+// startBrowser starts a headless browser. The sandbox needs privileges containers usually lack.
+func startBrowser(binary string) (*pooledBrowser, error) {
+	options := append(chromedp.DefaultExecAllocatorOptions[:], chromedp.ExecPath(binary),
+		chromedp.NoSandbox, chromedp.DisableGPU, chromedp.Flag("mute-audio", true), chromedp.Flag("hide-scrollbars", true))
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(context.Background(), options...)
+	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+	cancel := func() {
+		cancelBrowser()
+		cancelAlloc()
+	}
+
+	if err := chromedp.Run(browserCtx); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to start browser: %w", err)
+	}
+	return &pooledBrowser{ctx: browserCtx, cancel: cancel}, nil
+}
+
+// renderPage opens rawURL in a new tab of the browser, in a browser context of its own going
+// through the proxy of attempt, and returns its DOM once scripts settled
+func renderPage(ctx, browserCtx context.Context, rawURL string, attempt request_client.ExternalAttempt, timeout time.Duration) ([]byte, int, error) {
+	server, username, password, err := browserProxy(attempt.Proxy)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	tabCtx, cancelTab := chromedp.NewContext(browserCtx, chromedp.WithNewBrowserContext(
+		func(params *target.CreateBrowserContextParams) *target.CreateBrowserContextParams {
+			if server == "" {
+				return params
+			}
+			return params.WithProxyServer(server)
+		}))
+	defer cancelTab()
+	// The tab lives under the browser, not the fetch, so the fetch's end must close it
+	stop := context.AfterFunc(ctx, cancelTab)
+	defer stop()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		tabCtx, cancel = context.WithTimeout(tabCtx, timeout)
+		defer cancel()
+	}
+
+	answerProxyAuth(tabCtx, username, password)
+	actions := []chromedp.Action{fetch.Enable().WithHandleAuthRequests(true)}
+	if attempt.UserAgent != "" {
+		actions = append(actions, emulation.SetUserAgentOverride(attempt.UserAgent))
+	}
+	actions = append(actions, chromedp.Navigate(rawURL))
+
+	response, err := chromedp.RunResponse(tabCtx, actions...)
+	if err != nil {
+		if tabCtx.Err() != nil {
+			return nil, 0, fmt.Errorf("browser did not finish loading: %w", tabCtx.Err())
+		}
+		return nil, 0, fmt.Errorf("failed to load page in browser: %w", err)
+	}
+
+	page, err := settledPage(tabCtx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read rendered page: %w", err)
+	}
+	return page, int(response.Status), nil
+}
+
+// answerProxyAuth answers the proxy's authentication challenges of the tab with username and
+// password, once per request so wrong credentials fail instead of looping. Challenges of sites
+// are cancelled. Fetch.enable pauses every request, so the others are continued as they are.
+func answerProxyAuth(tabCtx context.Context, username, password string) {
+	var mutex sync.Mutex
+	answered := make(map[fetch.RequestID]bool)
+
+	chromedp.ListenTarget(tabCtx, func(event any) {
+		switch event := event.(type) {
+		case *fetch.EventRequestPaused:
+			go chromedp.Run(tabCtx, fetch.ContinueRequest(event.RequestID))
+		case *fetch.EventAuthRequired:
+			response := &fetch.AuthChallengeResponse{Response: fetch.AuthChallengeResponseResponseCancelAuth}
+			mutex.Lock()
+			if event.AuthChallenge.Source == fetch.AuthChallengeSourceProxy && username != "" && !answered[event.RequestID] {
+				answered[event.RequestID] = true
+				response = &fetch.AuthChallengeResponse{
+					Response: fetch.AuthChallengeResponseResponseProvideCredentials,
+					Username: username,
+					Password: password,
+				}
+			}
+			mutex.Unlock()
+			go chromedp.Run(tabCtx, fetch.ContinueWithAuth(event.RequestID, response))
+		}
+	})
+}
+
+// settledPage returns the DOM of the tab once it is neither a block page nor an empty shell, or
+// as it is after browserSettle
+func settledPage(tabCtx context.Context) ([]byte, error) {
+	deadline := time.Now().Add(browserSettle)
+	for {
+		var html string
+		if err := chromedp.Run(tabCtx, chromedp.OuterHTML("html", &html, chromedp.ByQuery)); err != nil {
+			return nil, err
+		}
+		page := []byte(html)
+		settled := DetectBlockPage(page, renderedContentType) == "" && browserReason(page, nil) == ""
+		if settled || time.Now().After(deadline) {
+			return page, nil
+		}
+		if err := chromedp.Run(tabCtx, chromedp.Sleep(browserPoll)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// browserProxy returns the --proxy-server form of a proxy URL, scheme://host:port, with the
+// credentials the browser answers the proxy's challenge with. Chrome cannot authenticate to
+// SOCKS5 proxies, so those must be open.
+func browserProxy(proxy string) (server, username, password string, err error) {
+	if proxy == "" {
+		return "", "", "", nil
+	}
+	parsed, err := request_client.ParseProxyURL(proxy)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	scheme := parsed.Scheme
+	switch scheme {
+	case request_client.SchemeSOCKS5, request_client.SchemeSOCKS5H:
+		if parsed.User != nil {
+			return "", "", "", fmt.Errorf("browser cannot authenticate to SOCKS5 proxy %s", request_client.RedactProxy(proxy))
+		}
+		// Chrome always lets SOCKS5 proxies resolve hosts
+		scheme = request_client.SchemeSOCKS5
+	}
+	if parsed.User != nil {
+		username = parsed.User.Username()
+		password, _ = parsed.User.Password()
+	}
+	return scheme + "://" + parsed.Host, username, password, nil
+}
+
+var (
+	browsersMutex sync.RWMutex
+	browsers      = make(map[string]*BrowserFetcher)
+)
+
+// SetBrowserFallback renders the pages of site and its subdomains in fetcher when the plain fetch
+// gets an interstitial, an empty page or a block status (nil turns the fallback off)
+func SetBrowserFallback(site string, fetcher *BrowserFetcher) {
+	browsersMutex.Lock()
+	defer browsersMutex.Unlock()
+
+	site = strings.ToLower(site)
+	if fetcher == nil {
+		delete(browsers, site)
+		return
+	}
+	browsers[site] = fetcher
+}
+
+// browserFor returns the browser fallback of the site of rawURL, nil when it has none
+func browserFor(rawURL string) *BrowserFetcher {
+	host := hostOf(rawURL)
+
+	browsersMutex.RLock()
+	defer browsersMutex.RUnlock()
+	for site, fetcher := range browsers {
+		if host == site || strings.HasSuffix(host, "."+site) {
+			return fetcher
+		}
+	}
+	return nil
+}
+
+// browserReason returns why the result of a plain fetch needs the browser, "" when it does not.
+// Pages that are gone and other failures are left as they are.
+func browserReason(body []byte, err error) string {
+	var blockPage *BlockPageError
+	switch {
+	case errors.As(err, &blockPage) && blockPage.Reason == BlockSmallBody:
+		return BrowserReasonEmpty
+	case errors.As(err, &blockPage):
+		return BrowserReasonInterstitial
+	case IsBlocked(err):
+		return BrowserReasonBlocked
+	case err != nil:
+		return ""
+	}
+
+	doc, parseErr := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if parseErr != nil {
+		return ""
+	}
+	doc.Find("script, style, noscript").Remove()
+	if strings.TrimSpace(doc.Find("body").Text()) == "" {
+		return BrowserReasonEmpty
+	}
+	return ""
+}
+
+// fetchRendered fetches a page like fetchPage and renders it in the browser fallback of its site
+// when the plain fetch got a block page, an empty page or a block status
+func fetchRendered(ctx context.Context, rawURL string) (fetchedPage, error) {
+	page, err := fetchPage(ctx, rawURL)
+
+	browser := browserFor(rawURL)
+	if browser == nil || ctx.Err() != nil {
+		return page, err
+	}
+	reason := browserReason(page.body, err)
+	if reason == "" {
+		return page, err
+	}
+
+	log.InfoContext(ctx, "Rendering page in browser", "url", rawURL, "reason", reason)
+	rendered, renderErr := browser.Fetch(ctx, rawURL)
+	metrics.ObserveBrowserFetch(reason, renderErr)
+	if renderErr != nil {
+		if err != nil {
+			// The block status stays visible to callers deciding on other fallbacks
+			return fetchedPage{}, fmt.Errorf("%w (browser: %v)", err, renderErr)
+		}
+		return fetchedPage{}, fmt.Errorf("failed to render page in browser: %w", renderErr)
+	}
+	// The browser may have been shown a CAPTCHA it cannot solve
+	if blockReason := DetectBlockPage(rendered, renderedContentType); blockReason != "" {
+		metrics.ObserveBlockPage(hostOf(rawURL), blockReason)
+		return fetchedPage{}, fmt.Errorf("rendered page: %w", &BlockPageError{Reason: blockReason, Size: len(rendered)})
+	}
+	// The browser prints the DOM in UTF-8 whatever the page was served in
+	return fetchedPage{body: rendered, contentType: renderedContentType}, nil
+}
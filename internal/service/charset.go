@@ -0,0 +1,179 @@
+package service
+
+import (
+	"bytes"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/metrics"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/transform"
+)
+
+// Charset names as DetectCharset returns them, the WHATWG names of the encodings
+const (
+	CharsetUTF8        = "utf-8"
+	CharsetWindows1251 = "windows-1251"
+	CharsetKOI8R       = "koi8-r"
+	CharsetCP866       = "ibm866"
+	CharsetISO88595    = "iso-8859-5"
+)
+
+// sniffCharsets are the single-byte Cyrillic charsets a page without a declared charset is tried in
+var sniffCharsets = []string{CharsetWindows1251, CharsetKOI8R, CharsetCP866, CharsetISO88595}
+
+// letterWeights are the frequencies of the most common Russian letters in running text. Text
+// decoded with the right charset has them in lowercase; a wrong one turns them into capitals,
+// rare letters or symbols.
+var letterWeights = map[rune]float64{
+	'о': 10.97, 'е': 8.45, 'а': 8.01, 'и': 7.35, 'н': 6.70, 'т': 6.26, 'с': 5.47, 'р': 4.73,
+	'в': 4.54, 'л': 4.40, 'к': 3.49, 'м': 3.21, 'д': 2.98, 'п': 2.81, 'у': 2.62, 'я': 2.01,
+}
+
+// byteOrderMarks name the charset a body starting with them is in
+var byteOrderMarks = []struct {
+	mark    []byte
+	charset string
+}{
+	{[]byte{0xEF, 0xBB, 0xBF}, CharsetUTF8},
+	{[]byte{0xFE, 0xFF}, "utf-16be"},
+	{[]byte{0xFF, 0xFE}, "utf-16le"},
+}
+
+// metaCharset matches the charset of <meta charset="..."> and of the Content-Type of
+// <meta http-equiv="Content-Type" content="text/html; charset=...">
+var metaCharset = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?\s*([a-z0-9_:.-]+)`)
+
+// DetectCharset returns the charset of a page body served with contentType (empty when unknown):
+// the byte order mark, else the charset of contentType, else the one of a <meta> tag, else UTF-8
+// when the body is valid UTF-8, else the Cyrillic charset the body reads best in. A declared
+// single-byte charset is ignored for bodies that are valid UTF-8 with non-ASCII text, such as pages
+// decoded by an embedder's crawler that kept the declaration.
+func DetectCharset(body []byte, contentType string) string {
+	for _, bom := range byteOrderMarks {
+		if bytes.HasPrefix(body, bom.mark) {
+			return bom.charset
+		}
+	}
+
+	declared := headerCharset(contentType)
+	if declared == "" {
+		declared = declaredCharset(body)
+	}
+	if declared != "" && !(utf8.Valid(body) && hasNonASCII(body)) {
+		return declared
+	}
+
+	if utf8.Valid(body) {
+		return CharsetUTF8
+	}
+	return sniffCharset(body)
+}
+
+// headerCharset returns the charset parameter of a Content-Type header, "" when it has none or
+// names a charset the decoder does not know
+func headerCharset(contentType string) string {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return canonicalCharset(params["charset"])
+}
+
+// declaredCharset returns the charset a <meta> tag of the page head declares, "" when none does
+func declaredCharset(body []byte) string {
+	head := body
+	if end := bytes.Index(bytes.ToLower(body), []byte("</head")); end >= 0 {
+		head = body[:end]
+	}
+	match := metaCharset.FindSubmatch(head)
+	if match == nil {
+		return ""
+	}
+	return canonicalCharset(string(match[1]))
+}
+
+// canonicalCharset returns the WHATWG name of a charset label, e.g. windows-1251 for cp1251, ""
+// for unknown labels
+func canonicalCharset(label string) string {
+	if label == "" {
+		return ""
+	}
+	encoding, err := htmlindex.Get(label)
+	if err != nil {
+		return ""
+	}
+	name, err := htmlindex.Name(encoding)
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+// sniffCharset returns the charset of sniffCharsets the body reads best in as Russian text
+func sniffCharset(body []byte) string {
+	best, bestScore := CharsetWindows1251, -1.0
+	for _, charset := range sniffCharsets {
+		decoded, err := decodeCharset(body, charset)
+		if err != nil {
+			continue
+		}
+
+		score := 0.0
+		for _, r := range string(decoded) {
+			if weight, ok := letterWeights[r]; ok {
+				score += weight
+			} else if unicode.Is(unicode.Cyrillic, r) && unicode.IsUpper(r) {
+				// Capitals are rare in running text, most come from a wrong charset
+				score -= 1
+			}
+		}
+		if score > bestScore {
+			best, bestScore = charset, score
+		}
+	}
+	return best
+}
+
+// decodeCharset converts body from charset to UTF-8
+func decodeCharset(body []byte, charset string) ([]byte, error) {
+	encoding, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, err
+	}
+	decoded, _, err := transform.Bytes(encoding.NewDecoder(), body)
+	return decoded, err
+}
+
+// hasNonASCII reports whether body has a byte outside ASCII
+func hasNonASCII(body []byte) bool {
+	for _, b := range body {
+		if b >= utf8.RuneSelf {
+			return true
+		}
+	}
+	return false
+}
+
+// DecodePage converts a page body served with contentType (empty when unknown) to valid UTF-8,
+// from the charset DetectCharset finds
+func DecodePage(body []byte, contentType string) []byte {
+	if charset := DetectCharset(body, contentType); charset != CharsetUTF8 {
+		decoded, err := decodeCharset(body, charset)
+		if err != nil {
+			metrics.ObserveParseError("encoding")
+			log.Warn("Failed to convert encoding", "charset", charset, "error", err)
+		} else {
+			body = decoded
+		}
+	}
+
+	// Clean any invalid UTF-8 sequences
+	if !utf8.Valid(body) {
+		body = []byte(strings.ToValidUTF8(string(body), ""))
+	}
+	return body
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+	"github.com/gregor-tokarev/hoe_parser/internal/modules/request_client"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// russianText is running text long enough for the letter frequencies to tell the charsets apart
+const russianText = "Анна, привет. Встречи только в апартаментах, около метро, район тихий и спокойный."
+
+// encode returns html in charset
+func encode(t *testing.T, charset *charmap.Charmap, html string) []byte {
+	t.Helper()
+	encoded, err := charset.NewEncoder().Bytes([]byte(html))
+	if err != nil {
+		t.Fatalf("Failed to encode page: %v", err)
+	}
+	return encoded
+}
+
+func TestDetectCharset(t *testing.T) {
+	page := func(head string) string {
+		return "<html><head>" + head + "</head><body><p>" + russianText + "</p></body></html>"
+	}
+
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		want        string
+	}{
+		{"header", encode(t, charmap.KOI8R, page("")), "text/html; charset=KOI8-R", CharsetKOI8R},
+		{"header over meta", encode(t, charmap.KOI8R, page(`<meta charset="windows-1251">`)), "text/html; charset=koi8-r", CharsetKOI8R},
+		{"meta charset", encode(t, charmap.CodePage866, page(`<meta charset="cp866">`)), "text/html", CharsetCP866},
+		{"meta http-equiv", encode(t, charmap.ISO8859_5, page(`<meta http-equiv="Content-Type" content="text/html; charset=ISO-8859-5">`)), "", CharsetISO88595},
+		{"unknown header charset", encode(t, charmap.Windows1251, page(`<meta charset="cp1251">`)), "text/html; charset=x-unknown", CharsetWindows1251},
+		{"utf-8 with stale meta", []byte(page(`<meta charset="windows-1251">`)), "", CharsetUTF8},
+		{"sniffed windows-1251", encode(t, charmap.Windows1251, page("")), "", CharsetWindows1251},
+		{"sniffed koi8-r", encode(t, charmap.KOI8R, page("")), "", CharsetKOI8R},
+		{"sniffed cp866", encode(t, charmap.CodePage866, page("")), "", CharsetCP866},
+		{"sniffed iso-8859-5", encode(t, charmap.ISO8859_5, page("")), "", CharsetISO88595},
+	}
+	for _, test := range tests {
+		if got := DetectCharset(test.body, test.contentType); got != test.want {
+			t.Errorf("%s: expected %s, got %s", test.name, test.want, got)
+		}
+	}
+}
+
+func TestFetchAndParsePageCharset(t *testing.T) {
+	// The test server acts as the proxy and declares the charset only in the header
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=koi8-r")
+		w.Write(encode(t, charmap.KOI8R, "<html><body><h1>Анна</h1></body></html>"))
+	}))
+	defer proxy.Close()
+
+	request_client.ResetGlobalClient()
+	defer request_client.ResetGlobalClient()
+	request_client.InitGlobalClient(&config.Config{Proxies: []string{proxy.URL}})
+
+	doc, err := FetchAndParsePage(context.Background(), "http://example.com/koi8")
+	if err != nil {
+		t.Fatalf("Expected a page, got %v", err)
+	}
+	if got := doc.Find("h1").Text(); got != "Анна" {
+		t.Errorf("Expected %q, got %q", "Анна", got)
+	}
+}
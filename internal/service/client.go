@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/modules/request_client"
+)
+
+// HTTPClient sends the requests of the fetch layer. *request_client.ProxyClient implements it;
+// embedders and tests can inject any other client with SetHTTPClient.
+type HTTPClient interface {
+	GetCtx(ctx context.Context, url string) (*http.Response, error)
+	PostCtx(ctx context.Context, url, contentType string, body io.Reader) (*http.Response, error)
+}
+
+// blockPageReporter is implemented by clients that track block pages per site, like the site
+// guard of *request_client.ProxyClient
+type blockPageReporter interface {
+	ReportBlockPage(url, reason string)
+}
+
+var (
+	httpClientMutex sync.RWMutex
+	httpClient      HTTPClient
+)
+
+// SetHTTPClient makes FetchAndParsePage, FetchPage and FetchJsonImgs send their requests through
+// client; nil restores the global proxy client
+func SetHTTPClient(client HTTPClient) {
+	httpClientMutex.Lock()
+	defer httpClientMutex.Unlock()
+	httpClient = client
+}
+
+// currentHTTPClient returns the client set with SetHTTPClient, else the global proxy client
+func currentHTTPClient() HTTPClient {
+	httpClientMutex.RLock()
+	defer httpClientMutex.RUnlock()
+	if httpClient != nil {
+		return httpClient
+	}
+	return request_client.GetGlobalClient()
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeClient answers every request with handler instead of the network
+type fakeClient struct {
+	handler  http.HandlerFunc
+	requests []*http.Request
+	reported []string
+}
+
+func (c *fakeClient) do(req *http.Request) (*http.Response, error) {
+	c.requests = append(c.requests, req)
+	recorder := httptest.NewRecorder()
+	c.handler(recorder, req)
+	resp := recorder.Result()
+	resp.Request = req
+	return resp, nil
+}
+
+func (c *fakeClient) GetCtx(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(req)
+}
+
+func (c *fakeClient) PostCtx(ctx context.Context, url, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return c.do(req)
+}
+
+func (c *fakeClient) ReportBlockPage(url, reason string) {
+	c.reported = append(c.reported, reason)
+}
+
+func TestFetchAndParsePageInjectedClient(t *testing.T) {
+	client := &fakeClient{handler: func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/captcha" {
+			w.Write([]byte(`<html><body><div class="g-recaptcha"></div></body></html>`))
+			return
+		}
+		w.Write([]byte("<html><body><h1>injected</h1></body></html>"))
+	}}
+	SetHTTPClient(client)
+	defer SetHTTPClient(nil)
+
+	doc, err := FetchAndParsePage(context.Background(), "http://injected.example/page")
+	if err != nil {
+		t.Fatalf("Expected a page, got %v", err)
+	}
+	if got := doc.Find("h1").Text(); got != "injected" {
+		t.Errorf("Expected the page of the injected client, got %q", got)
+	}
+
+	if _, err := FetchAndParsePage(context.Background(), "http://injected.example/captcha"); !IsBlocked(err) {
+		t.Errorf("Expected a block page error, got %v", err)
+	}
+	if len(client.reported) != 1 || client.reported[0] != BlockCaptcha {
+		t.Errorf("Expected the block page reported to the client, got %v", client.reported)
+	}
+}
+
+func TestFetchJsonImgsInjectedClient(t *testing.T) {
+	client := &fakeClient{handler: func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || !strings.Contains(string(body), "limit=100") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`[{"BIMG": "/photo/1.jpg", "ID": "1"}, {"BIMG": "/photo/2.jpg", "ID": "2"}]`))
+	}}
+	SetHTTPClient(client)
+	defer SetHTTPClient(nil)
+
+	images, err := FetchJsonImgs(context.Background(), "http://injected.example/images")
+	if err != nil {
+		t.Fatalf("Expected images, got %v", err)
+	}
+	if len(images) != 2 || images[1].BIMG != "/photo/2.jpg" {
+		t.Errorf("Expected 2 images, got %+v", images)
+	}
+
+	if _, err := FetchJsonImgs(context.Background(), "http://injected.example/gone"); !IsGone(err) {
+		t.Errorf("Expected a 404 status error, got %v", err)
+	}
+	if len(client.requests) != 2 {
+		t.Errorf("Expected 2 requests through the injected client, got %d", len(client.requests))
+	}
+}
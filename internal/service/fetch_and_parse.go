@@ -3,32 +3,90 @@ package service
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	neturl "net/url"
 	"strings"
-	"unicode/utf8"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/gregor-tokarev/hoe_parser/internal/logger"
+	"github.com/gregor-tokarev/hoe_parser/internal/metrics"
 	"github.com/gregor-tokarev/hoe_parser/internal/models"
 	"github.com/gregor-tokarev/hoe_parser/internal/modules/request_client"
-	"golang.org/x/text/encoding/charmap"
-	"golang.org/x/text/transform"
 )
 
-func FetchJsonImgs(url string) ([]models.ImageData, error) {
-	client := request_client.GetGlobalClient()
+// log is the component logger of the package
+var log = logger.Component("service")
+
+// StatusError is returned when a page responds with a status other than 200
+type StatusError struct {
+	StatusCode int
+	RetryAfter time.Duration // the response's Retry-After, 0 when missing
+}
+
+// Error implements the error interface
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("received non-200 status code: %d", e.StatusCode)
+}
+
+// IsBlocked reports whether err means the site refused the request: a block status, a block page,
+// a paused site or every proxy burned for the site
+func IsBlocked(err error) bool {
+	if errors.Is(err, request_client.ErrSitePaused) || errors.Is(err, request_client.ErrProxiesBurned) || errors.Is(err, ErrBlockPage) {
+		return true
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusForbidden, http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			return true
+		}
+	}
+	return false
+}
+
+// ErrRedirectedToHome is returned when a page redirects to the home page of a site, which is how
+// sites answer for pages they took down
+var ErrRedirectedToHome = errors.New("page redirected to the home page")
+
+// IsGone reports whether err means the page no longer exists: a 404, a 410 or a redirect to the
+// home page
+func IsGone(err error) bool {
+	if errors.Is(err, ErrRedirectedToHome) {
+		return true
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusNotFound || statusErr.StatusCode == http.StatusGone
+	}
+	return false
+}
+
+// FetchJsonImgs requests the image list of a listing page through the HTTP client of the fetch
+// layer; the request is abandoned when ctx is done
+func FetchJsonImgs(ctx context.Context, url string) ([]models.ImageData, error) {
+	client := currentHTTPClient()
 
 	formData := strings.NewReader("limit=100&offset=0")
 
-	resp, err := client.Post(url, "application/x-www-form-urlencoded", formData)
+	resp, err := client.PostCtx(ctx, url, "application/x-www-form-urlencoded", formData)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch page: %w", err)
 	}
 
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response body: %w", err)
@@ -37,66 +95,163 @@ func FetchJsonImgs(url string) ([]models.ImageData, error) {
 	// Parse JSON response into ImageData slice
 	var imageData []models.ImageData
 	if err := json.Unmarshal(body, &imageData); err != nil {
+		metrics.ObserveParseError("json")
 		return nil, fmt.Errorf("failed to parse JSON: %w", err)
 	}
 
 	return imageData, nil
 }
 
-func FetchAndParsePage(url string) (*goquery.Document, error) {
-	client := request_client.GetGlobalClient()
+// FetchAndParsePage fetches a page through the HTTP client of the fetch layer, the global proxy
+// client unless SetHTTPClient replaced it, and parses it as UTF-8 HTML, retrying
+// block and overload statuses as the FetchRetryPolicy says. Concurrent calls for the same page
+// share one fetch, see fetchShared. The caller stops waiting when ctx is done.
+func FetchAndParsePage(ctx context.Context, url string) (*goquery.Document, error) {
+	page, err := fetchShared(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePageWithContentType(page.body, page.contentType)
+}
+
+// FetchPage fetches the body of a page as served, without decoding it, and its Content-Type header,
+// like FetchAndParsePage, for callers that keep the raw page as well
+func FetchPage(ctx context.Context, url string) ([]byte, string, error) {
+	page, err := fetchShared(ctx, url)
+	return page.body, page.contentType, err
+}
+
+// fetchedPage is the body of a page as served and its Content-Type header
+type fetchedPage struct {
+	body        []byte
+	contentType string
+}
+
+// fetchPage fetches the body of a page, retrying as the FetchRetryPolicy says. The request and
+// any wait between attempts are abandoned when ctx is done.
+func fetchPage(ctx context.Context, url string) (fetchedPage, error) {
+	policy := currentFetchRetryPolicy()
+
+	for attempt := 1; ; attempt++ {
+		page, err := fetchPageOnce(ctx, url)
+		if err == nil || ctx.Err() != nil {
+			return page, err
+		}
+
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) {
+			return fetchedPage{}, err
+		}
+		delay, retry := policy.retryDelay(attempt, statusErr)
+		if !retry {
+			if attempt > 1 {
+				return fetchedPage{}, fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+			}
+			return fetchedPage{}, err
+		}
+
+		metrics.ObservePageRetry(statusErr.StatusCode)
+		log.DebugContext(ctx, "Retrying page", "url", url, "status", statusErr.StatusCode, "attempt", attempt, "delay", delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fetchedPage{}, fmt.Errorf("page retry cancelled: %w", ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// fetchPageOnce makes a single attempt at fetching a page and returns its decompressed body
+func fetchPageOnce(ctx context.Context, url string) (fetchedPage, error) {
+	client := currentHTTPClient()
 
 	// Fetch the page
-	resp, err := client.Get(url)
+	resp, err := client.GetCtx(ctx, url)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch page: %w", err)
+		return fetchedPage{}, fmt.Errorf("failed to fetch page: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("received non-200 status code: %d", resp.StatusCode)
+		return fetchedPage{}, &StatusError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: request_client.ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+
+	if redirectedToHome(url, resp.Request) {
+		return fetchedPage{}, fmt.Errorf("%w: %s", ErrRedirectedToHome, resp.Request.URL)
 	}
 
 	// Extract and decompress body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return fetchedPage{}, fmt.Errorf("failed to read response body: %w", err)
 	}
 
 	// Handle gzip compression if present
 	if resp.Header.Get("Content-Encoding") == "gzip" {
 		reader, err := gzip.NewReader(bytes.NewReader(body))
 		if err != nil {
-			return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+			metrics.ObserveParseError("gzip")
+			return fetchedPage{}, fmt.Errorf("failed to create gzip reader: %w", err)
 		}
 		defer reader.Close()
 
 		body, err = io.ReadAll(reader)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decompress gzip content: %w", err)
+			metrics.ObserveParseError("gzip")
+			return fetchedPage{}, fmt.Errorf("failed to decompress gzip content: %w", err)
 		}
 	}
 
-	// Convert from Windows-1251 to UTF-8
-	bodyStr := string(body)
-	if strings.Contains(bodyStr, "windows-1251") || strings.Contains(bodyStr, "charset=windows-1251") {
-		// Convert from Windows-1251 to UTF-8
-		decoder := charmap.Windows1251.NewDecoder()
-		utf8Body, _, err := transform.Bytes(decoder, body)
-		if err != nil {
-			fmt.Printf("Warning: failed to convert encoding: %v\n", err)
-		} else {
-			body = utf8Body
+	// A challenge or CAPTCHA served with a 200 fails like a block status instead of being parsed
+	contentType := resp.Header.Get("Content-Type")
+	if reason := DetectBlockPage(body, contentType); reason != "" {
+		log.WarnContext(ctx, "Received block page", "url", url, "reason", reason, "size", len(body))
+		metrics.ObserveBlockPage(hostOf(url), reason)
+		if reporter, ok := client.(blockPageReporter); ok {
+			reporter.ReportBlockPage(url, reason)
 		}
+		return fetchedPage{}, &BlockPageError{Reason: reason, Size: len(body)}
 	}
 
-	// Clean any invalid UTF-8 sequences
-	bodyStr = string(body)
-	if !utf8.ValidString(bodyStr) {
-		bodyStr = strings.ToValidUTF8(bodyStr, "")
-		body = []byte(bodyStr)
+	return fetchedPage{body: body, contentType: contentType}, nil
+}
+
+// redirectedToHome reports whether the request that answered a fetch of requested, which follows
+// redirects, is for the bare home page while requested is not
+func redirectedToHome(requested string, final *http.Request) bool {
+	if final == nil || final.URL == nil {
+		return false
+	}
+	original, err := neturl.Parse(requested)
+	if err != nil || isHomePage(original) {
+		return false
 	}
+	return isHomePage(final.URL)
+}
 
-	// Parse HTML
-	return goquery.NewDocumentFromReader(bytes.NewReader(body))
+// isHomePage reports whether u is the root of its site without a query
+func isHomePage(u *neturl.URL) bool {
+	return (u.Path == "" || u.Path == "/") && u.RawQuery == ""
+}
+
+// ParsePage parses a page body as served by the site, converting it to UTF-8 from the charset its
+// <meta> tags declare or, without one, the charset sniffed from the body, see DetectCharset
+func ParsePage(body []byte) (*goquery.Document, error) {
+	return ParsePageWithContentType(body, "")
+}
+
+// ParsePageWithContentType parses a page body like ParsePage, preferring the charset of the
+// Content-Type header it was served with
+func ParsePageWithContentType(body []byte, contentType string) (*goquery.Document, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(DecodePage(body, contentType)))
+	if err != nil {
+		metrics.ObserveParseError("html")
+		return nil, fmt.Errorf("failed to build HTML document: %w", err)
+	}
+	return doc, nil
 }
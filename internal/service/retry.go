@@ -0,0 +1,71 @@
+package service
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// FetchRetryPolicy decides how FetchAndParsePage retries a page by the status it got back.
+// 429 and 503 back off exponentially, or for the site's Retry-After when that is longer; 403 is
+// retried at once, as the client has burned the blocking proxy and moves on to another; any other
+// status, 404 included, fails at once.
+type FetchRetryPolicy struct {
+	MaxAttempts int           // attempts per page including the first, 1 disables retries
+	BaseDelay   time.Duration // back-off after the first 429 or 503, doubled after every further one
+	MaxDelay    time.Duration // cap of the back-off; a longer Retry-After gives up on the page instead
+}
+
+// DefaultFetchRetryPolicy is the policy used until SetFetchRetryPolicy is called
+var DefaultFetchRetryPolicy = FetchRetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   2 * time.Second,
+	MaxDelay:    time.Minute,
+}
+
+var (
+	retryPolicyMutex sync.RWMutex
+	retryPolicy      = DefaultFetchRetryPolicy
+)
+
+// SetFetchRetryPolicy replaces the retry policy of FetchAndParsePage
+func SetFetchRetryPolicy(policy FetchRetryPolicy) {
+	retryPolicyMutex.Lock()
+	defer retryPolicyMutex.Unlock()
+	retryPolicy = policy
+}
+
+// currentFetchRetryPolicy returns the retry policy of FetchAndParsePage
+func currentFetchRetryPolicy() FetchRetryPolicy {
+	retryPolicyMutex.RLock()
+	defer retryPolicyMutex.RUnlock()
+	return retryPolicy
+}
+
+// retryDelay returns how long to wait before fetching a page again after attempt number attempt
+// was answered with statusErr, and false when the page should not be fetched again
+func (p FetchRetryPolicy) retryDelay(attempt int, statusErr *StatusError) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts {
+		return 0, false
+	}
+
+	switch statusErr.StatusCode {
+	case http.StatusForbidden:
+		return 0, true
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		delay := p.BaseDelay
+		for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+			delay *= 2
+		}
+		delay = min(delay, p.MaxDelay)
+
+		if statusErr.RetryAfter > delay {
+			if statusErr.RetryAfter > p.MaxDelay {
+				return 0, false
+			}
+			delay = statusErr.RetryAfter
+		}
+		return delay, true
+	}
+	return 0, false
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+	"github.com/gregor-tokarev/hoe_parser/internal/modules/request_client"
+)
+
+func TestRetryDelay(t *testing.T) {
+	policy := FetchRetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+
+	tests := []struct {
+		name       string
+		attempt    int
+		status     int
+		retryAfter time.Duration
+		delay      time.Duration
+		retry      bool
+	}{
+		{"403 rotates at once", 1, http.StatusForbidden, 0, 0, true},
+		{"404 gives up", 1, http.StatusNotFound, 0, 0, false},
+		{"500 gives up", 1, http.StatusInternalServerError, 0, 0, false},
+		{"first 429 backs off", 1, http.StatusTooManyRequests, 0, time.Second, true},
+		{"third 503 doubles twice", 3, http.StatusServiceUnavailable, 0, 4 * time.Second, true},
+		{"back-off is capped", 4, http.StatusServiceUnavailable, 0, 5 * time.Second, true},
+		{"longer Retry-After wins", 1, http.StatusTooManyRequests, 3 * time.Second, 3 * time.Second, true},
+		{"shorter Retry-After is ignored", 3, http.StatusTooManyRequests, time.Second, 4 * time.Second, true},
+		{"Retry-After beyond the cap gives up", 1, http.StatusTooManyRequests, time.Minute, 0, false},
+		{"attempts exhausted", 5, http.StatusForbidden, 0, 0, false},
+	}
+	for _, test := range tests {
+		delay, retry := policy.retryDelay(test.attempt, &StatusError{StatusCode: test.status, RetryAfter: test.retryAfter})
+		if delay != test.delay || retry != test.retry {
+			t.Errorf("%s: expected %v, %v, got %v, %v", test.name, test.delay, test.retry, delay, retry)
+		}
+	}
+}
+
+func TestFetchAndParsePageRetries(t *testing.T) {
+	var requests atomic.Int32
+	var statuses []int
+	// The test server acts as the proxy and answers every request itself
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1))
+		if n <= len(statuses) {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		w.Write([]byte("<html><body><h1>ok</h1></body></html>"))
+	}))
+	defer proxy.Close()
+
+	request_client.ResetGlobalClient()
+	defer request_client.ResetGlobalClient()
+	request_client.InitGlobalClient(&config.Config{Proxies: []string{proxy.URL}})
+
+	SetFetchRetryPolicy(FetchRetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond})
+	defer SetFetchRetryPolicy(DefaultFetchRetryPolicy)
+
+	tests := []struct {
+		statuses []int
+		requests int32
+		ok       bool
+	}{
+		{[]int{http.StatusServiceUnavailable, http.StatusForbidden}, 3, true},
+		{[]int{http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests}, 3, false},
+		{[]int{http.StatusNotFound}, 1, false},
+	}
+	for _, test := range tests {
+		statuses = test.statuses
+		requests.Store(0)
+
+		doc, err := FetchAndParsePage(context.Background(), "http://example.com/page")
+		if test.ok && (err != nil || doc.Find("h1").Text() != "ok") {
+			t.Errorf("%v: expected the page after retries, got %v", test.statuses, err)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("%v: expected an error", test.statuses)
+		}
+		if got := requests.Load(); got != test.requests {
+			t.Errorf("%v: expected %d requests, got %d", test.statuses, test.requests, got)
+		}
+	}
+
+	// The last status stays available to callers deciding on fallbacks
+	statuses = []int{http.StatusForbidden, http.StatusForbidden, http.StatusForbidden}
+	requests.Store(0)
+	if _, err := FetchAndParsePage(context.Background(), "http://example.com/page"); !IsBlocked(err) {
+		t.Errorf("Expected a blocked error after exhausting retries, got %v", err)
+	}
+	statuses = []int{http.StatusNotFound}
+	requests.Store(0)
+	if _, err := FetchAndParsePage(context.Background(), "http://example.com/page"); !IsGone(err) || IsBlocked(err) {
+		t.Errorf("Expected a gone error for a 404, got %v", err)
+	}
+}
+
+func TestFetchAndParsePageRetryCancelled(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", strconv.Itoa(30))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer proxy.Close()
+
+	request_client.ResetGlobalClient()
+	defer request_client.ResetGlobalClient()
+	request_client.InitGlobalClient(&config.Config{Proxies: []string{proxy.URL}})
+
+	SetFetchRetryPolicy(FetchRetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Minute})
+	defer SetFetchRetryPolicy(DefaultFetchRetryPolicy)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	if _, err := FetchAndParsePage(ctx, "http://example.com/page"); err == nil {
+		t.Errorf("Expected an error once the context is done")
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("Expected the back-off to stop with the context, took %v", elapsed)
+	}
+}
+
+func TestFetchAndParsePageRedirectToHome(t *testing.T) {
+	// The test server acts as the proxy: taken down pages redirect to the home page
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone.htm" {
+			http.Redirect(w, r, "http://example.com/", http.StatusFound)
+			return
+		}
+		w.Write([]byte("<html><body><h1>ok</h1></body></html>"))
+	}))
+	defer proxy.Close()
+
+	request_client.ResetGlobalClient()
+	defer request_client.ResetGlobalClient()
+	request_client.InitGlobalClient(&config.Config{Proxies: []string{proxy.URL}})
+
+	if _, err := FetchAndParsePage(context.Background(), "http://example.com/gone.htm"); !IsGone(err) {
+		t.Errorf("Expected a gone error for a redirect to the home page, got %v", err)
+	}
+	if _, err := FetchAndParsePage(context.Background(), "http://example.com/"); err != nil {
+		t.Errorf("Expected the home page itself to load, got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/metrics"
+)
+
+// flight is a page fetch shared by every caller asking for the page while it runs
+type flight struct {
+	done    chan struct{}
+	page    fetchedPage
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+var (
+	flightsMutex sync.Mutex
+	flights      = make(map[string]*flight) // by fetchKey
+)
+
+// fetchShared fetches a page, joining a fetch of the same page already in flight. Callers share
+// the body and each parses its own document, since documents are mutable. The fetch runs on a
+// context of its own carrying the first caller's values: one caller giving up does not fail the
+// others, and the fetch is cancelled once every caller has given up.
+func fetchShared(ctx context.Context, rawURL string) (fetchedPage, error) {
+	key := fetchKey(rawURL)
+
+	flightsMutex.Lock()
+	current, exists := flights[key]
+	if exists {
+		metrics.PageFetchesShared.Inc()
+		log.DebugContext(ctx, "Joining page fetch in flight", "url", rawURL)
+	} else {
+		fetchCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		current = &flight{done: make(chan struct{}), cancel: cancel}
+		flights[key] = current
+
+		go func() {
+			current.page, current.err = fetchRendered(fetchCtx, rawURL)
+			cancel()
+
+			flightsMutex.Lock()
+			if flights[key] == current {
+				delete(flights, key)
+			}
+			flightsMutex.Unlock()
+			close(current.done)
+		}()
+	}
+	current.waiters++
+	flightsMutex.Unlock()
+
+	select {
+	case <-current.done:
+		return current.page, current.err
+	case <-ctx.Done():
+		flightsMutex.Lock()
+		current.waiters--
+		if current.waiters == 0 {
+			// Nobody is left waiting: stop the fetch and let the next caller start afresh
+			current.cancel()
+			if flights[key] == current {
+				delete(flights, key)
+			}
+		}
+		flightsMutex.Unlock()
+		return fetchedPage{}, fmt.Errorf("page fetch cancelled: %w", ctx.Err())
+	}
+}
+
+// fetchKey returns the canonical form of a page URL under which concurrent fetches are shared:
+// scheme and host lowercased, default ports and the fragment dropped, query parameters sorted.
+// Unparseable URLs are their own key.
+func fetchKey(rawURL string) string {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || parsed.Host == "" {
+		return rawURL
+	}
+
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	host := strings.ToLower(parsed.Hostname())
+	if port := parsed.Port(); port != "" && !(parsed.Scheme == "http" && port == "80") && !(parsed.Scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+	parsed.Host = host
+	if parsed.Path == "" {
+		parsed.Path = "/"
+	}
+	parsed.RawQuery = parsed.Query().Encode()
+	parsed.Fragment = ""
+	return parsed.String()
+}
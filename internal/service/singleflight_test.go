@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+	"github.com/gregor-tokarev/hoe_parser/internal/modules/request_client"
+)
+
+func TestFetchKey(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"https://Example.com/page?b=2&a=1", "https://example.com:443/page?a=1&b=2#top", true},
+		{"https://example.com", "https://example.com/", true},
+		{"https://example.com/page", "https://example.com/other", false},
+		{"https://example.com/page", "https://m.example.com/page", false},
+		{"https://example.com/page?a=1", "https://example.com/page?a=2", false},
+	}
+	for _, test := range tests {
+		if same := fetchKey(test.a) == fetchKey(test.b); same != test.same {
+			t.Errorf("%s vs %s: expected same key %v, got %v", test.a, test.b, test.same, same)
+		}
+	}
+}
+
+// waitForWaiters blocks until the flight for url has n callers waiting on it
+func waitForWaiters(t *testing.T, url string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		flightsMutex.Lock()
+		current := flights[fetchKey(url)]
+		waiting := current != nil && current.waiters == n
+		flightsMutex.Unlock()
+		if waiting {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Expected %d callers waiting on %s", n, url)
+}
+
+func TestFetchAndParsePageSharesFetch(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+	// The test server acts as the proxy and holds every response until released
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		w.Write([]byte("<html><body><h1>ok</h1></body></html>"))
+	}))
+	defer proxy.Close()
+
+	request_client.ResetGlobalClient()
+	defer request_client.ResetGlobalClient()
+	request_client.InitGlobalClient(&config.Config{Proxies: []string{proxy.URL}})
+
+	urls := []string{
+		"http://example.com/page?a=1&b=2",
+		"http://EXAMPLE.com/page?b=2&a=1",
+		"http://example.com:80/page?a=1&b=2#listing",
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(urls))
+	for i, url := range urls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			doc, err := FetchAndParsePage(context.Background(), url)
+			if err == nil && doc.Find("h1").Text() != "ok" {
+				t.Errorf("Expected the shared page, got %q", doc.Find("h1").Text())
+			}
+			errs[i] = err
+		}()
+	}
+
+	waitForWaiters(t, urls[0], len(urls))
+	close(release)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Expected caller %d to get the page, got %v", i, err)
+		}
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("Expected 1 upstream request, got %d", got)
+	}
+}
+
+func TestFetchAndParsePageSharedCancel(t *testing.T) {
+	release := make(chan struct{})
+	cancelled := make(chan struct{}, 1)
+	// Pages asked for with ?hold never get an answer
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hold := release
+		if r.URL.Query().Has("hold") {
+			hold = nil
+		}
+		select {
+		case <-hold:
+			w.Write([]byte("<html><body><h1>ok</h1></body></html>"))
+		case <-r.Context().Done():
+			cancelled <- struct{}{}
+		}
+	}))
+	defer proxy.Close()
+
+	request_client.ResetGlobalClient()
+	defer request_client.ResetGlobalClient()
+	request_client.InitGlobalClient(&config.Config{Proxies: []string{proxy.URL}})
+
+	const url = "http://example.com/shared"
+
+	// One caller giving up leaves the fetch running for the other
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := FetchAndParsePage(leaderCtx, url)
+		leaderErr <- err
+	}()
+	waitForWaiters(t, url, 1)
+
+	followerErr := make(chan error, 1)
+	go func() {
+		_, err := FetchAndParsePage(context.Background(), url)
+		followerErr <- err
+	}()
+	waitForWaiters(t, url, 2)
+
+	cancelLeader()
+	if err := <-leaderErr; err == nil {
+		t.Errorf("Expected the cancelled caller to get an error")
+	}
+	close(release)
+	if err := <-followerErr; err != nil {
+		t.Errorf("Expected the remaining caller to get the page, got %v", err)
+	}
+
+	// The fetch stops once every caller has given up
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		FetchAndParsePage(ctx, url+"?hold")
+		close(done)
+	}()
+	waitForWaiters(t, url+"?hold", 1)
+	cancel()
+	<-done
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Errorf("Expected the upstream request to be cancelled")
+	}
+}
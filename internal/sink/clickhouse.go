@@ -0,0 +1,35 @@
+package sink
+
+import (
+	"context"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+)
+
+// ClickHouseSink stores every listing in a secondary ClickHouse database with the same change
+// detection as the primary one, so a staging schema receives the rows and change log entries
+// production would write
+type ClickHouseSink struct {
+	adapter *clickhouse.Adapter
+}
+
+// NewClickHouseSink creates a sink writing through adapter
+func NewClickHouseSink(adapter *clickhouse.Adapter) *ClickHouseSink {
+	return &ClickHouseSink{adapter: adapter}
+}
+
+// Name implements Sink
+func (s *ClickHouseSink) Name() string { return NameClickHouse }
+
+// Write implements Sink
+func (s *ClickHouseSink) Write(ctx context.Context, listing *clickhouse.FlattenedListing) error {
+	// Change detection sets created_at, and the other sinks share the listing
+	copied := *listing
+	_, _, err := s.adapter.UpsertFlattenedIfChanged(ctx, &copied)
+	return err
+}
+
+// Close implements Sink
+func (s *ClickHouseSink) Close() error {
+	return s.adapter.Close()
+}
@@ -0,0 +1,35 @@
+package sink
+
+import (
+	"context"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/kafka"
+)
+
+// EventListingStored is the event type of listings written by the Kafka sink
+const EventListingStored = "listing.stored"
+
+// KafkaSink publishes every listing to a topic, keyed by listing ID so the versions of a listing
+// stay in order
+type KafkaSink struct {
+	producer *kafka.Producer
+}
+
+// NewKafkaSink creates a sink publishing through producer
+func NewKafkaSink(producer *kafka.Producer) *KafkaSink {
+	return &KafkaSink{producer: producer}
+}
+
+// Name implements Sink
+func (s *KafkaSink) Name() string { return NameKafka }
+
+// Write implements Sink
+func (s *KafkaSink) Write(ctx context.Context, listing *clickhouse.FlattenedListing) error {
+	return s.producer.SendKeyed(ctx, listing.ID, EventListingStored, listing)
+}
+
+// Close implements Sink
+func (s *KafkaSink) Close() error {
+	return s.producer.Close()
+}
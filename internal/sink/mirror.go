@@ -0,0 +1,69 @@
+package sink
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+	"github.com/gregor-tokarev/hoe_parser/internal/kafka"
+)
+
+// Mirror forwards a share of the stored listings to a staging sink. Listings are picked by ID, so
+// a mirrored listing is mirrored on every scrape and its versions can be compared in staging.
+type Mirror struct {
+	Sink
+	percent float64
+}
+
+// NewMirror creates a mirror passing percent (0-100) of the listings to sink
+func NewMirror(sink Sink, percent float64) *Mirror {
+	return &Mirror{Sink: sink, percent: percent}
+}
+
+// Name implements Sink, keeping mirrors apart from production sinks of the same kind
+func (m *Mirror) Name() string { return "mirror_" + m.Sink.Name() }
+
+// Accepts implements Filter
+func (m *Mirror) Accepts(listing *clickhouse.FlattenedListing) bool {
+	hash := fnv.New32a()
+	hash.Write([]byte(listing.ID))
+	return float64(hash.Sum32()%10000) < m.percent*100
+}
+
+// MirrorsFromConfig creates the staging mirrors configured in cfg.Mirror; none when
+// cfg.Mirror.Percent is 0
+func MirrorsFromConfig(cfg *config.Config) ([]Sink, error) {
+	mirror := cfg.Mirror
+	if mirror.Percent <= 0 {
+		return nil, nil
+	}
+	if mirror.Percent > 100 {
+		return nil, fmt.Errorf("mirror percent %g is above 100", mirror.Percent)
+	}
+
+	var sinks []Sink
+	if mirror.ClickHouse.Host != "" {
+		chConfig := clickhouse.FromMainConfig(cfg, cfg.Debug)
+		chConfig.Host = mirror.ClickHouse.Host
+		chConfig.Port = mirror.ClickHouse.Port
+		chConfig.Database = mirror.ClickHouse.Database
+		chConfig.User = mirror.ClickHouse.User
+		chConfig.Password = mirror.ClickHouse.Password
+
+		adapter, err := clickhouse.NewAdapter(chConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to mirror clickhouse: %w", err)
+		}
+		sinks = append(sinks, NewMirror(NewClickHouseSink(adapter), mirror.Percent))
+	}
+	if mirror.KafkaBrokers != "" {
+		producer := kafka.NewProducer(mirror.KafkaBrokers, mirror.KafkaTopic)
+		sinks = append(sinks, NewMirror(NewKafkaSink(producer), mirror.Percent))
+	}
+
+	if len(sinks) == 0 {
+		return nil, fmt.Errorf("mirroring %g%% of listings needs a mirror clickhouse host or kafka brokers", mirror.Percent)
+	}
+	return sinks, nil
+}
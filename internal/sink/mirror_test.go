@@ -0,0 +1,73 @@
+package sink
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+	"github.com/gregor-tokarev/hoe_parser/internal/events"
+)
+
+func TestMirrorAcceptsShareOfListings(t *testing.T) {
+	mirror := NewMirror(&memorySink{name: NameKafka}, 25)
+	if mirror.Name() != "mirror_kafka" {
+		t.Errorf("Expected name mirror_kafka, got %s", mirror.Name())
+	}
+
+	accepted := 0
+	for i := 0; i < 10000; i++ {
+		listing := &clickhouse.FlattenedListing{ID: fmt.Sprintf("intimcity.gold:%d", i)}
+		if mirror.Accepts(listing) {
+			accepted++
+		}
+		if mirror.Accepts(listing) != mirror.Accepts(&clickhouse.FlattenedListing{ID: listing.ID}) {
+			t.Fatalf("Expected the same decision for every version of %s", listing.ID)
+		}
+	}
+	if accepted < 2200 || accepted > 2800 {
+		t.Errorf("Expected about 25%% of listings mirrored, got %d of 10000", accepted)
+	}
+
+	listing := &clickhouse.FlattenedListing{ID: "intimcity.gold:1"}
+	if NewMirror(&memorySink{}, 0).Accepts(listing) || !NewMirror(&memorySink{}, 100).Accepts(listing) {
+		t.Errorf("Expected 0%% to mirror nothing and 100%% to mirror everything")
+	}
+}
+
+func TestFanoutSkipsListingsFilteredOut(t *testing.T) {
+	staging := &memorySink{name: NameKafka}
+	bus := events.NewBus()
+	fanout := NewFanout([]Sink{NewMirror(staging, 0)}, 0)
+	fanout.Subscribe(bus, 10)
+
+	bus.Publish(events.ListingInserted{ListingID: "a:1", Listing: &clickhouse.FlattenedListing{ID: "a:1"}})
+	if err := fanout.Close(); err != nil {
+		t.Fatalf("Expected no close error, got %v", err)
+	}
+
+	if len(staging.ids) != 0 {
+		t.Errorf("Expected no listing mirrored, got %v", staging.ids)
+	}
+	if !staging.closed {
+		t.Errorf("Expected the mirrored sink to be closed")
+	}
+}
+
+func TestMirrorsFromConfig(t *testing.T) {
+	if sinks, err := MirrorsFromConfig(&config.Config{}); err != nil || len(sinks) != 0 {
+		t.Errorf("Expected no mirrors by default, got %v, %v", sinks, err)
+	}
+
+	for _, mirror := range []config.MirrorConfig{{Percent: 10}, {Percent: 150, KafkaBrokers: "staging:9092"}} {
+		if _, err := MirrorsFromConfig(&config.Config{Mirror: mirror}); err == nil {
+			t.Errorf("Expected an error for %+v", mirror)
+		}
+	}
+
+	sinks, err := MirrorsFromConfig(&config.Config{Mirror: config.MirrorConfig{Percent: 10, KafkaBrokers: "staging:9092", KafkaTopic: "listings"}})
+	if err != nil || len(sinks) != 1 || sinks[0].Name() != "mirror_kafka" {
+		t.Fatalf("Expected a kafka mirror, got %v, %v", sinks, err)
+	}
+	closeAll(sinks)
+}
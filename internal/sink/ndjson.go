@@ -0,0 +1,58 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+)
+
+// NDJSONSink appends every listing as one JSON line to a file
+type NDJSONSink struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// NewNDJSONSink opens path for appending, creating it and its directory when missing
+func NewNDJSONSink(path string) (*NDJSONSink, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create directory for %s: %w", path, err)
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	return &NDJSONSink{file: file}, nil
+}
+
+// Name implements Sink
+func (s *NDJSONSink) Name() string { return NameNDJSON }
+
+// Write implements Sink
+func (s *NDJSONSink) Write(ctx context.Context, listing *clickhouse.FlattenedListing) error {
+	line, err := json.Marshal(listing)
+	if err != nil {
+		return fmt.Errorf("failed to marshal listing %s: %w", listing.ID, err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to append listing %s: %w", listing.ID, err)
+	}
+	return nil
+}
+
+// Close implements Sink
+func (s *NDJSONSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.file.Close()
+}
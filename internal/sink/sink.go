@@ -0,0 +1,148 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+	"github.com/gregor-tokarev/hoe_parser/internal/events"
+	"github.com/gregor-tokarev/hoe_parser/internal/kafka"
+	"github.com/gregor-tokarev/hoe_parser/internal/logger"
+	"github.com/gregor-tokarev/hoe_parser/internal/metrics"
+	"github.com/gregor-tokarev/hoe_parser/internal/storage"
+)
+
+// log is the component logger of the package
+var log = logger.Component("sink")
+
+// Sink names accepted in STORAGE_SINKS
+const (
+	NameNDJSON   = "ndjson"
+	NameKafka    = "kafka"
+	NamePostgres = "postgres"
+)
+
+// NameClickHouse names the sink writing to a secondary ClickHouse, used for mirroring
+const NameClickHouse = "clickhouse"
+
+// Sink is a store every stored listing is copied to
+type Sink interface {
+	// Name identifies the sink in logs and metrics
+	Name() string
+	// Write stores one listing version
+	Write(ctx context.Context, listing *clickhouse.FlattenedListing) error
+	// Close flushes pending writes and releases the sink
+	Close() error
+}
+
+// FromConfig creates the sinks listed in cfg.Sinks.Enabled, in order
+func FromConfig(cfg *config.Config) ([]Sink, error) {
+	var sinks []Sink
+	for _, name := range cfg.Sinks.Enabled {
+		var sink Sink
+		switch name {
+		case NameNDJSON:
+			ndjson, err := NewNDJSONSink(cfg.Sinks.NDJSONPath)
+			if err != nil {
+				closeAll(sinks)
+				return nil, err
+			}
+			sink = ndjson
+		case NameKafka:
+			sink = NewKafkaSink(kafka.NewProducer(cfg.KafkaBrokers, cfg.Sinks.KafkaTopic))
+		case NamePostgres:
+			store, err := storage.NewPostgresStore(context.Background(), cfg.Storage.PostgresDriver, cfg.Storage.PostgresDSN, cfg.Storage.PostgresMaxConnections)
+			if err != nil {
+				closeAll(sinks)
+				return nil, err
+			}
+			sink = NewStoreSink(NamePostgres, store)
+		default:
+			closeAll(sinks)
+			return nil, fmt.Errorf("unknown storage sink %q", name)
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+// Filter is implemented by sinks that only take some listings; the others are never queued for them
+type Filter interface {
+	Accepts(listing *clickhouse.FlattenedListing) bool
+}
+
+// Fanout copies every listing stored by the pipeline to each sink. Every sink has its own event
+// bus subscription, so a slow or failing sink never delays the pipeline or the other sinks.
+type Fanout struct {
+	sinks        []Sink
+	timeout      time.Duration
+	unsubscribes []func()
+}
+
+// NewFanout creates a fan-out to sinks, giving each write at most timeout (0 means no limit)
+func NewFanout(sinks []Sink, timeout time.Duration) *Fanout {
+	return &Fanout{sinks: sinks, timeout: timeout}
+}
+
+// Subscribe starts copying the listings of ListingInserted events on bus, queueing up to buffer
+// listings per sink. Events for a full queue are dropped and counted per "sink:<name>" subscriber.
+func (f *Fanout) Subscribe(bus *events.Bus, buffer int) {
+	for _, sink := range f.sinks {
+		filter, _ := sink.(Filter)
+		unsubscribe := bus.Subscribe("sink:"+sink.Name(), buffer, events.On(func(event events.ListingInserted) {
+			if event.Listing != nil && (filter == nil || filter.Accepts(event.Listing)) {
+				f.write(sink, event.Listing)
+			}
+		}))
+		f.unsubscribes = append(f.unsubscribes, unsubscribe)
+	}
+}
+
+// write stores a listing in one sink, recording the outcome. A panicking sink is reported like a
+// failed write instead of taking the process down.
+func (f *Fanout) write(sink Sink, listing *clickhouse.FlattenedListing) {
+	ctx := context.Background()
+	if f.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("sink panicked: %v", r)
+			}
+		}()
+		return sink.Write(ctx, listing)
+	}()
+
+	metrics.ObserveSinkWrite(sink.Name(), time.Since(start), err)
+	if err != nil {
+		log.Warn("Failed to write listing to sink", "sink", sink.Name(), "listing_id", listing.ID, "error", err)
+	}
+}
+
+// Close waits until every sink has written its queued listings, then closes the sinks
+func (f *Fanout) Close() error {
+	for _, unsubscribe := range f.unsubscribes {
+		unsubscribe()
+	}
+	f.unsubscribes = nil
+	return closeAll(f.sinks)
+}
+
+// closeAll closes every sink and joins their errors
+func closeAll(sinks []Sink) error {
+	var errs []error
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close %s sink: %w", sink.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
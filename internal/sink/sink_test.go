@@ -0,0 +1,117 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+	"github.com/gregor-tokarev/hoe_parser/internal/events"
+)
+
+// memorySink records written listing IDs and fails or panics on request
+type memorySink struct {
+	name  string
+	err   error
+	panic bool
+
+	mutex  sync.Mutex
+	ids    []string
+	closed bool
+}
+
+func (s *memorySink) Name() string { return s.name }
+
+func (s *memorySink) Write(ctx context.Context, listing *clickhouse.FlattenedListing) error {
+	if s.panic {
+		panic("broken sink")
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.ids = append(s.ids, listing.ID)
+	return s.err
+}
+
+func (s *memorySink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestFanoutIsolatesSinks(t *testing.T) {
+	healthy := &memorySink{name: "healthy"}
+	failing := &memorySink{name: "failing", err: errors.New("disk full")}
+	panicking := &memorySink{name: "panicking", panic: true}
+
+	bus := events.NewBus()
+	fanout := NewFanout([]Sink{failing, panicking, healthy}, 0)
+	fanout.Subscribe(bus, 10)
+
+	bus.Publish(events.ListingInserted{ListingID: "a:1", Listing: &clickhouse.FlattenedListing{ID: "a:1"}})
+	bus.Publish(events.ListingScraped{ListingID: "a:2"})
+	bus.Publish(events.ListingInserted{ListingID: "a:3", Listing: &clickhouse.FlattenedListing{ID: "a:3"}})
+
+	if err := fanout.Close(); err != nil {
+		t.Fatalf("Expected no close error, got %v", err)
+	}
+
+	if len(healthy.ids) != 2 || healthy.ids[0] != "a:1" || healthy.ids[1] != "a:3" {
+		t.Errorf("Expected the healthy sink to get both listings in order, got %v", healthy.ids)
+	}
+	if len(failing.ids) != 2 {
+		t.Errorf("Expected the failing sink to keep receiving listings, got %v", failing.ids)
+	}
+	if !healthy.closed || !failing.closed || !panicking.closed {
+		t.Errorf("Expected every sink to be closed")
+	}
+}
+
+func TestNDJSONSinkAppendsLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out", "listings.ndjson")
+
+	for _, id := range []string{"a:1", "a:2"} {
+		sink, err := NewNDJSONSink(path)
+		if err != nil {
+			t.Fatalf("Failed to open sink: %v", err)
+		}
+		if err := sink.Write(context.Background(), &clickhouse.FlattenedListing{ID: id}); err != nil {
+			t.Fatalf("Failed to write listing: %v", err)
+		}
+		sink.Close()
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open output: %v", err)
+	}
+	defer file.Close()
+
+	var ids []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var listing clickhouse.FlattenedListing
+		if err := json.Unmarshal(scanner.Bytes(), &listing); err != nil {
+			t.Fatalf("Expected a JSON line, got %q: %v", scanner.Text(), err)
+		}
+		ids = append(ids, listing.ID)
+	}
+	if len(ids) != 2 || ids[0] != "a:1" || ids[1] != "a:2" {
+		t.Errorf("Expected both listings appended, got %v", ids)
+	}
+}
+
+func TestFromConfigRejectsUnknownSink(t *testing.T) {
+	cfg := &config.Config{Sinks: config.SinksConfig{
+		Enabled:    []string{NameNDJSON, "s3"},
+		NDJSONPath: filepath.Join(t.TempDir(), "listings.ndjson"),
+	}}
+
+	if _, err := FromConfig(cfg); err == nil {
+		t.Errorf("Expected an unknown sink to be rejected")
+	}
+}
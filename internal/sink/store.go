@@ -0,0 +1,32 @@
+package sink
+
+import (
+	"context"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/storage"
+)
+
+// StoreSink keeps the latest version of every listing in a storage.Store, such as PostgreSQL
+type StoreSink struct {
+	name  string
+	store storage.Store
+}
+
+// NewStoreSink creates a sink named name writing to store
+func NewStoreSink(name string, store storage.Store) *StoreSink {
+	return &StoreSink{name: name, store: store}
+}
+
+// Name implements Sink
+func (s *StoreSink) Name() string { return s.name }
+
+// Write implements Sink
+func (s *StoreSink) Write(ctx context.Context, listing *clickhouse.FlattenedListing) error {
+	return s.store.InsertFlattenedListing(ctx, listing)
+}
+
+// Close implements Sink
+func (s *StoreSink) Close() error {
+	return s.store.Close()
+}
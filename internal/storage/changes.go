@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/logger"
+)
+
+// log is the component logger of the package
+var log = logger.Component("storage")
+
+// UpsertIfChanged stores a listing only when it differs from its latest version in store, like
+// the ClickHouse adapter's UpsertFlattenedIfChanged, and logs one update entry per changed column.
+// New listings are stored without change entries and keep their created_at; changed ones take the
+// stored created_at. It returns the changed columns and whether the listing was written.
+func UpsertIfChanged(ctx context.Context, store Store, flattened *clickhouse.FlattenedListing) ([]clickhouse.FieldChange, bool, error) {
+	return UpsertIfChangedFrom(ctx, store, flattened, clickhouse.ChangeSourceScraper)
+}
+
+// UpsertIfChangedFrom works like UpsertIfChanged, logging the changes under source instead of
+// the scraper, e.g. for tools that parse stored pages again
+func UpsertIfChangedFrom(ctx context.Context, store Store, flattened *clickhouse.FlattenedListing, source string) ([]clickhouse.FieldChange, bool, error) {
+	previous, err := store.GetListingByID(ctx, flattened.ID)
+	if err != nil && !errors.Is(err, clickhouse.ErrListingNotFound) {
+		return nil, false, fmt.Errorf("failed to get previous version: %w", err)
+	}
+
+	var changes []clickhouse.FieldChange
+	if previous != nil {
+		changes = clickhouse.DiffListings(previous, flattened)
+		if len(changes) == 0 {
+			return nil, false, nil
+		}
+		flattened.CreatedAt = previous.CreatedAt
+	}
+
+	if err := store.InsertFlattenedListing(ctx, flattened); err != nil {
+		return nil, false, err
+	}
+
+	// The new version is already stored, so a failed entry is only reported
+	for _, change := range changes {
+		if err := store.LogChange(ctx, flattened.ID, "update", change.OldValue, change.NewValue, change.Field, source); err != nil {
+			log.WarnContext(ctx, "Failed to log listing change", "listing_id", flattened.ID, "field", change.Field, "error", err)
+		}
+	}
+	return changes, true, nil
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+)
+
+// memoryStore keeps the latest version of each listing and the change log in memory
+type memoryStore struct {
+	listings map[string]clickhouse.FlattenedListing
+	changes  []string
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{listings: make(map[string]clickhouse.FlattenedListing)}
+}
+
+func (m *memoryStore) InsertFlattenedListing(ctx context.Context, listing *clickhouse.FlattenedListing) error {
+	m.listings[listing.ID] = *listing
+	return nil
+}
+
+func (m *memoryStore) BatchInsertFlattenedListings(ctx context.Context, listings []*clickhouse.FlattenedListing) error {
+	for _, listing := range listings {
+		m.InsertFlattenedListing(ctx, listing)
+	}
+	return nil
+}
+
+func (m *memoryStore) GetListingByID(ctx context.Context, id string) (*clickhouse.FlattenedListing, error) {
+	listing, ok := m.listings[id]
+	if !ok || listing.IsDeleted {
+		return nil, fmt.Errorf("%w: %s", clickhouse.ErrListingNotFound, id)
+	}
+	return &listing, nil
+}
+
+func (m *memoryStore) QueryListings(ctx context.Context, q clickhouse.ListingQuery) ([]*clickhouse.FlattenedListing, error) {
+	return nil, nil
+}
+
+func (m *memoryStore) LogChange(ctx context.Context, listingID, changeType, oldValue, newValue, fieldName, source string) error {
+	m.changes = append(m.changes, fmt.Sprintf("%s %s %s: %s -> %s", listingID, changeType, fieldName, oldValue, newValue))
+	return nil
+}
+
+func (m *memoryStore) GetStats(ctx context.Context) (map[string]interface{}, error) { return nil, nil }
+
+func (m *memoryStore) Close() error { return nil }
+
+func TestUpsertIfChanged(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	created := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	listing := &clickhouse.FlattenedListing{ID: "site:1", CreatedAt: created, PriceHour: 5000}
+	changes, written, err := UpsertIfChanged(ctx, store, listing)
+	if err != nil || !written || len(changes) != 0 {
+		t.Fatalf("Expected a new listing written without changes, got %v, %v, %v", changes, written, err)
+	}
+
+	rescraped := &clickhouse.FlattenedListing{ID: "site:1", CreatedAt: created.Add(time.Hour), PriceHour: 5000}
+	if _, written, _ := UpsertIfChanged(ctx, store, rescraped); written {
+		t.Errorf("Expected an unchanged listing not to be written")
+	}
+
+	changed := &clickhouse.FlattenedListing{ID: "site:1", CreatedAt: created.Add(2 * time.Hour), PriceHour: 6000}
+	changes, written, err = UpsertIfChanged(ctx, store, changed)
+	if err != nil || !written || len(changes) != 1 || changes[0].Field != "price_hour" {
+		t.Fatalf("Expected the price change written, got %v, %v, %v", changes, written, err)
+	}
+	if stored := store.listings["site:1"]; !stored.CreatedAt.Equal(created) || stored.PriceHour != 6000 {
+		t.Errorf("Expected the new price with the first created_at, got %v at %v", stored.PriceHour, stored.CreatedAt)
+	}
+	if len(store.changes) != 1 || store.changes[0] != "site:1 update price_hour: 5000 -> 6000" {
+		t.Errorf("Unexpected change log: %v", store.changes)
+	}
+}
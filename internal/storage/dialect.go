@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+)
+
+// dialect holds what differs between the SQL databases SQLStore runs on
+type dialect struct {
+	name   string   // database name, also the build tag linking its driver
+	schema []string // statements creating the tables and indexes when missing
+
+	placeholder func(n int) string // the n-th (1-based) statement parameter
+	timestamp   func(t time.Time) any
+
+	keepCreatedAt string                           // upsert expression of the new data keeping the stored created_at
+	jsonText      func(field string) string        // text value of a top-level field of data
+	jsonNumber    func(field string) string        // numeric value of a top-level field of data, NULL when missing
+	jsonLength    func(field string) string        // length of an array field of data, 0 when missing
+	jsonContains  func(field, param string) string // whether an array field of data holds param
+
+	percentile func(fraction float64, column string) string // nil when the database has no percentile function
+}
+
+// listingQuery returns the SELECT statement of q with its arguments, filtering like the
+// ClickHouse adapter does
+func (d dialect) listingQuery(q clickhouse.ListingQuery) (string, []any) {
+	var args []any
+	arg := func(value any) string {
+		args = append(args, value)
+		return d.placeholder(len(args))
+	}
+
+	clauses := []string{"NOT is_deleted", "completeness >= " + arg(q.MinCompleteness)}
+	if q.MinQuality > 0 {
+		clauses = append(clauses, d.qualityScore()+" >= "+arg(q.MinQuality))
+	}
+	if q.City != "" {
+		clauses = append(clauses, "location_city = "+arg(q.City))
+	}
+	if q.Metro != "" {
+		clauses = append(clauses, d.jsonContains("location_metro_stations", arg(q.Metro)))
+	}
+	if q.MinPriceHour > 0 {
+		clauses = append(clauses, "price_hour >= "+arg(int64(q.MinPriceHour)))
+	}
+	if q.MaxPriceHour > 0 {
+		clauses = append(clauses, "price_hour > 0 AND price_hour <= "+arg(int64(q.MaxPriceHour)))
+	}
+	if q.MinAge > 0 {
+		clauses = append(clauses, "personal_age >= "+arg(int16(q.MinAge)))
+	}
+	if q.MaxAge > 0 {
+		clauses = append(clauses, "personal_age > 0 AND personal_age <= "+arg(int16(q.MaxAge)))
+	}
+	if q.HasPhotos != nil {
+		if *q.HasPhotos {
+			clauses = append(clauses, d.jsonLength("photos")+" > 0")
+		} else {
+			clauses = append(clauses, d.jsonLength("photos")+" = 0")
+		}
+	}
+
+	orderBy := "last_scraped DESC, id"
+	if clickhouse.IsListingSort(q.Sort) {
+		if column, descending := strings.CutPrefix(q.Sort, "-"); descending {
+			orderBy = column + " DESC, id"
+		} else {
+			orderBy = q.Sort + " ASC, id"
+		}
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	query := "SELECT data FROM listings WHERE " + strings.Join(clauses, " AND ") +
+		" ORDER BY " + orderBy + " LIMIT " + arg(limit) + " OFFSET " + arg(q.Offset)
+	return query, args
+}
+
+// qualityScore returns the quality score of a listing, 1 for listings stored before it was computed
+// like the default of the ClickHouse column
+func (d dialect) qualityScore() string {
+	return "COALESCE(" + d.jsonNumber("quality_score") + ", 1)"
+}
+
+// numberedPlaceholder returns $n, the placeholder of PostgreSQL
+func numberedPlaceholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
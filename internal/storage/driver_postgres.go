@@ -0,0 +1,7 @@
+//go:build postgres
+
+package storage
+
+// The pgx driver registers itself as "pgx", the default POSTGRES_DRIVER. It is only linked into
+// builds with -tags postgres, so ClickHouse-only deployments do not carry it.
+import _ "github.com/jackc/pgx/v5/stdlib"
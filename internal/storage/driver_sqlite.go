@@ -0,0 +1,7 @@
+//go:build sqlite
+
+package storage
+
+// The pure Go SQLite driver registers itself as "sqlite", the default SQLITE_DRIVER. It is only
+// linked into builds with -tags sqlite, so production deployments do not carry it.
+import _ "modernc.org/sqlite"
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// postgresDialect runs SQLStore on PostgreSQL, keeping the listing documents as JSONB
+var postgresDialect = dialect{
+	name: BackendPostgres,
+	schema: []string{
+		`CREATE TABLE IF NOT EXISTS listings (
+			id TEXT PRIMARY KEY,
+			source_site TEXT NOT NULL DEFAULT '',
+			source_id TEXT NOT NULL DEFAULT '',
+			source_url TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL,
+			last_scraped TIMESTAMPTZ NOT NULL,
+			location_city TEXT NOT NULL DEFAULT '',
+			personal_age SMALLINT NOT NULL DEFAULT 0,
+			price_hour BIGINT NOT NULL DEFAULT 0,
+			completeness REAL NOT NULL DEFAULT 0,
+			is_deleted BOOLEAN NOT NULL DEFAULT false,
+			status TEXT NOT NULL DEFAULT 'active',
+			data JSONB NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS listings_last_scraped_idx ON listings (last_scraped)`,
+		`CREATE INDEX IF NOT EXISTS listings_location_city_idx ON listings (location_city)`,
+		`CREATE TABLE IF NOT EXISTS listing_changes (
+			id BIGSERIAL PRIMARY KEY,
+			listing_id TEXT NOT NULL,
+			change_timestamp TIMESTAMPTZ NOT NULL,
+			change_type TEXT NOT NULL,
+			field_name TEXT NOT NULL DEFAULT '',
+			old_value TEXT NOT NULL DEFAULT '',
+			new_value TEXT NOT NULL DEFAULT '',
+			source TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE INDEX IF NOT EXISTS listing_changes_listing_idx ON listing_changes (listing_id, change_timestamp)`,
+	},
+	placeholder:   numberedPlaceholder,
+	timestamp:     func(t time.Time) any { return t.UTC() },
+	keepCreatedAt: `jsonb_set(excluded.data, '{created_at}', listings.data->'created_at')`,
+	jsonText: func(field string) string {
+		return "COALESCE(data->>'" + field + "', '')"
+	},
+	jsonNumber: func(field string) string {
+		return "CAST(data->>'" + field + "' AS DOUBLE PRECISION)"
+	},
+	jsonLength: func(field string) string {
+		return "jsonb_array_length(COALESCE(data->'" + field + "', '[]'))"
+	},
+	jsonContains: func(field, param string) string {
+		return "data->'" + field + "' ? " + param
+	},
+	percentile: func(fraction float64, column string) string {
+		return "percentile_cont(" + strconv.FormatFloat(fraction, 'f', -1, 64) + ") WITHIN GROUP (ORDER BY " + column + ")"
+	},
+}
+
+// NewPostgresStore connects to PostgreSQL through the named database/sql driver and creates the
+// tables that do not exist yet
+func NewPostgresStore(ctx context.Context, driver, dsn string, maxConnections int) (*SQLStore, error) {
+	if dsn == "" {
+		return nil, fmt.Errorf("postgres storage needs POSTGRES_DSN")
+	}
+	return openSQLStore(ctx, postgresDialect, driver, dsn, maxConnections)
+}
@@ -0,0 +1,417 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+)
+
+// SQLStore keeps listings in a database/sql database, PostgreSQL or SQLite, for deployments too
+// small to run ClickHouse. It stores the latest version of each listing as a JSON document next
+// to the columns queries filter and sort on; the history lives in the change log only.
+type SQLStore struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+// openSQLStore connects to the database through the named database/sql driver and creates the
+// tables that do not exist yet
+func openSQLStore(ctx context.Context, d dialect, driver, dsn string, maxConnections int) (*SQLStore, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s (build with -tags %s to include its driver): %w", d.name, d.name, err)
+	}
+	if maxConnections > 0 {
+		db.SetMaxOpenConns(maxConnections)
+		db.SetMaxIdleConns(maxConnections)
+	}
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping %s: %w", d.name, err)
+	}
+
+	store := &SQLStore{db: db, dialect: d}
+	if err := store.Migrate(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// Migrate creates the tables and indexes that do not exist yet
+func (s *SQLStore) Migrate(ctx context.Context) error {
+	for _, statement := range s.dialect.schema {
+		if _, err := s.db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to create %s schema: %w", s.dialect.name, err)
+		}
+	}
+	return nil
+}
+
+// upsertListing returns the statement writing the latest version of a listing, keeping the
+// created_at of the first one
+func (s *SQLStore) upsertListing() string {
+	placeholders := make([]string, 14)
+	for i := range placeholders {
+		placeholders[i] = s.dialect.placeholder(i + 1)
+	}
+	return `
+		INSERT INTO listings (id, source_site, source_id, source_url, created_at, updated_at, last_scraped,
+			location_city, personal_age, price_hour, completeness, is_deleted, status, data)
+		VALUES (` + strings.Join(placeholders, ", ") + `)
+		ON CONFLICT (id) DO UPDATE SET
+			source_site = excluded.source_site,
+			source_id = excluded.source_id,
+			source_url = excluded.source_url,
+			updated_at = excluded.updated_at,
+			last_scraped = excluded.last_scraped,
+			location_city = excluded.location_city,
+			personal_age = excluded.personal_age,
+			price_hour = excluded.price_hour,
+			completeness = excluded.completeness,
+			is_deleted = excluded.is_deleted,
+			status = excluded.status,
+			data = ` + s.dialect.keepCreatedAt + `
+	`
+}
+
+// listingArgs returns the upsertListing arguments of a listing
+func (s *SQLStore) listingArgs(listing *clickhouse.FlattenedListing) ([]any, error) {
+	status := listing.Status
+	if status == "" {
+		status = clickhouse.StatusActive
+		if listing.IsDeleted {
+			status = clickhouse.StatusRemoved
+		}
+	}
+
+	data, err := json.Marshal(listing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal listing %s: %w", listing.ID, err)
+	}
+
+	return []any{
+		listing.ID, listing.SourceSite, listing.SourceID, listing.SourceURL,
+		s.dialect.timestamp(listing.CreatedAt), s.dialect.timestamp(listing.UpdatedAt), s.dialect.timestamp(listing.LastScraped),
+		listing.LocationCity, int16(listing.PersonalAge), int64(listing.PriceHour), listing.Completeness,
+		listing.IsDeleted, status, string(data),
+	}, nil
+}
+
+// InsertFlattenedListing stores the latest version of a listing
+func (s *SQLStore) InsertFlattenedListing(ctx context.Context, listing *clickhouse.FlattenedListing) error {
+	args, err := s.listingArgs(listing)
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, s.upsertListing(), args...); err != nil {
+		return fmt.Errorf("failed to insert listing: %w", err)
+	}
+	return nil
+}
+
+// BatchInsertFlattenedListings stores listings in one transaction
+func (s *SQLStore) BatchInsertFlattenedListings(ctx context.Context, listings []*clickhouse.FlattenedListing) error {
+	if len(listings) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin batch: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, s.upsertListing())
+	if err != nil {
+		return fmt.Errorf("failed to prepare batch: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, listing := range listings {
+		args, err := s.listingArgs(listing)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return fmt.Errorf("failed to append listing to batch: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to send batch: %w", err)
+	}
+	return nil
+}
+
+// GetListingByID returns a live listing
+func (s *SQLStore) GetListingByID(ctx context.Context, id string) (*clickhouse.FlattenedListing, error) {
+	var data []byte
+	query := "SELECT data FROM listings WHERE id = " + s.dialect.placeholder(1) + " AND NOT is_deleted"
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", clickhouse.ErrListingNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get listing %s: %w", id, err)
+	}
+	return decodeListing(data)
+}
+
+// decodeListing unmarshals the JSON document of a listing
+func decodeListing(data []byte) (*clickhouse.FlattenedListing, error) {
+	var listing clickhouse.FlattenedListing
+	if err := json.Unmarshal(data, &listing); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal listing: %w", err)
+	}
+	listing.CreatedAt = listing.CreatedAt.UTC()
+	listing.UpdatedAt = listing.UpdatedAt.UTC()
+	listing.LastScraped = listing.LastScraped.UTC()
+	return &listing, nil
+}
+
+// QueryListings returns the live listings matching q, most recently scraped first unless q.Sort
+// says otherwise
+func (s *SQLStore) QueryListings(ctx context.Context, q clickhouse.ListingQuery) ([]*clickhouse.FlattenedListing, error) {
+	query, args := s.dialect.listingQuery(q)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query listings: %w", err)
+	}
+	defer rows.Close()
+
+	var listings []*clickhouse.FlattenedListing
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to scan listing: %w", err)
+		}
+		listing, err := decodeListing(data)
+		if err != nil {
+			return nil, err
+		}
+		listings = append(listings, listing)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate listings: %w", err)
+	}
+	return listings, nil
+}
+
+// LogChange records one change of a listing
+func (s *SQLStore) LogChange(ctx context.Context, listingID, changeType, oldValue, newValue, fieldName, source string) error {
+	placeholders := make([]string, 7)
+	for i := range placeholders {
+		placeholders[i] = s.dialect.placeholder(i + 1)
+	}
+	query := `
+		INSERT INTO listing_changes (listing_id, change_timestamp, change_type, old_value, new_value, field_name, source)
+		VALUES (` + strings.Join(placeholders, ", ") + `)
+	`
+	_, err := s.db.ExecContext(ctx, query, listingID, s.dialect.timestamp(clock.Now()), changeType, oldValue, newValue, fieldName, source)
+	if err != nil {
+		return fmt.Errorf("failed to log change: %w", err)
+	}
+	return nil
+}
+
+// completenessQuantiles are the completeness percentiles GetStats reports, as in the ClickHouse adapter
+var completenessQuantiles = []struct {
+	name     string
+	fraction float64
+}{
+	{"completeness_p10", 0.1},
+	{"completeness_p25", 0.25},
+	{"completeness_p50", 0.5},
+	{"completeness_p75", 0.75},
+	{"completeness_p90", 0.9},
+}
+
+// GetStats returns the statistics of the ClickHouse adapter's GetStats over the live listings
+func (s *SQLStore) GetStats(ctx context.Context) (map[string]interface{}, error) {
+	query := `
+		SELECT
+			count(*),
+			count(*) FILTER (WHERE personal_age > 0),
+			count(*) FILTER (WHERE price_hour > 0),
+			count(*) FILTER (WHERE ` + s.dialect.jsonText("contact_phone") + ` <> ''),
+			count(*) FILTER (WHERE ` + s.dialect.jsonLength("photos") + ` > 0),
+			CAST(COALESCE(avg(personal_age), 0) AS DOUBLE PRECISION),
+			CAST(COALESCE(avg(price_hour), 0) AS DOUBLE PRECISION),
+			count(DISTINCT location_city),
+			count(DISTINCT source_site),
+			CAST(COALESCE(avg(completeness), 0) AS DOUBLE PRECISION),
+			CAST(COALESCE(avg(` + s.dialect.qualityScore() + `), 0) AS DOUBLE PRECISION)
+		FROM listings
+		WHERE NOT is_deleted
+	`
+
+	var counts [7]int64
+	var avgAge, avgPriceHour, avgCompleteness, avgQualityScore float64
+	err := s.db.QueryRowContext(ctx, query).Scan(
+		&counts[0], &counts[1], &counts[2], &counts[3], &counts[4], &avgAge, &avgPriceHour, &counts[5], &counts[6], &avgCompleteness, &avgQualityScore,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stats: %w", err)
+	}
+
+	result := map[string]interface{}{
+		"total_listings":       uint64(counts[0]),
+		"listings_with_age":    uint64(counts[1]),
+		"listings_with_price":  uint64(counts[2]),
+		"listings_with_phone":  uint64(counts[3]),
+		"listings_with_photos": uint64(counts[4]),
+		"avg_age":              avgAge,
+		"avg_price_hour":       avgPriceHour,
+		"unique_cities":        uint64(counts[5]),
+		"unique_sites":         uint64(counts[6]),
+		"avg_completeness":     avgCompleteness,
+		"avg_quality_score":    avgQualityScore,
+	}
+
+	quantiles, err := s.completenessQuantiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i, quantile := range completenessQuantiles {
+		result[quantile.name] = quantiles[i]
+	}
+
+	byStatus, err := s.countByStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result["listings_by_status"] = byStatus
+
+	byFlag, err := s.countByQualityFlag(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result["listings_by_quality_flag"] = byFlag
+	return result, nil
+}
+
+// completenessQuantiles returns the completenessQuantiles of the live listings, computed by the
+// database when the dialect has a percentile function and from the sorted scores otherwise
+func (s *SQLStore) completenessQuantiles(ctx context.Context) ([]float64, error) {
+	quantiles := make([]float64, len(completenessQuantiles))
+
+	if s.dialect.percentile != nil {
+		columns := make([]string, len(completenessQuantiles))
+		targets := make([]any, len(completenessQuantiles))
+		for i, quantile := range completenessQuantiles {
+			columns[i] = "CAST(COALESCE(" + s.dialect.percentile(quantile.fraction, "completeness") + ", 0) AS DOUBLE PRECISION)"
+			targets[i] = &quantiles[i]
+		}
+		query := "SELECT " + strings.Join(columns, ", ") + " FROM listings WHERE NOT is_deleted"
+		if err := s.db.QueryRowContext(ctx, query).Scan(targets...); err != nil {
+			return nil, fmt.Errorf("failed to get completeness quantiles: %w", err)
+		}
+		return quantiles, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT completeness FROM listings WHERE NOT is_deleted")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get completeness scores: %w", err)
+	}
+	defer rows.Close()
+
+	var scores []float64
+	for rows.Next() {
+		var score float64
+		if err := rows.Scan(&score); err != nil {
+			return nil, fmt.Errorf("failed to scan completeness score: %w", err)
+		}
+		scores = append(scores, score)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate completeness scores: %w", err)
+	}
+
+	sort.Float64s(scores)
+	for i, quantile := range completenessQuantiles {
+		quantiles[i] = interpolatedQuantile(scores, quantile.fraction)
+	}
+	return quantiles, nil
+}
+
+// interpolatedQuantile returns the quantile of sorted values interpolated between the closest
+// ranks, like PostgreSQL's percentile_cont; 0 for no values
+func interpolatedQuantile(sorted []float64, fraction float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	position := fraction * float64(len(sorted)-1)
+	lower := int(position)
+	if lower+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lower] + (sorted[lower+1]-sorted[lower])*(position-float64(lower))
+}
+
+// countByStatus counts every listing by clickhouse.ReportedStatus, soft-deleted ones included
+func (s *SQLStore) countByStatus(ctx context.Context) (map[string]uint64, error) {
+	availability := s.dialect.jsonText("availability_status")
+	query := "SELECT status, " + availability + ", count(*) FROM listings GROUP BY status, " + availability
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count listings by status: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]uint64, len(clickhouse.ReportedStatuses))
+	for _, status := range clickhouse.ReportedStatuses {
+		counts[status] = 0
+	}
+	for rows.Next() {
+		var status, availability string
+		var count int64
+		if err := rows.Scan(&status, &availability, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan status count: %w", err)
+		}
+		counts[clickhouse.ReportedStatus(status, availability)] += uint64(count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate status counts: %w", err)
+	}
+	return counts, nil
+}
+
+// countByQualityFlag counts the live listings carrying each quality flag, as in the ClickHouse adapter
+func (s *SQLStore) countByQualityFlag(ctx context.Context) (map[string]uint64, error) {
+	columns := make([]string, len(clickhouse.QualityFlags))
+	args := make([]any, len(clickhouse.QualityFlags))
+	for i, flag := range clickhouse.QualityFlags {
+		columns[i] = "count(*) FILTER (WHERE " + s.dialect.jsonContains("quality_flags", s.dialect.placeholder(i+1)) + ")"
+		args[i] = flag
+	}
+
+	counts := make([]int64, len(clickhouse.QualityFlags))
+	targets := make([]any, len(counts))
+	for i := range counts {
+		targets[i] = &counts[i]
+	}
+	query := "SELECT " + strings.Join(columns, ", ") + " FROM listings WHERE NOT is_deleted"
+	if err := s.db.QueryRowContext(ctx, query, args...).Scan(targets...); err != nil {
+		return nil, fmt.Errorf("failed to count listings by quality flag: %w", err)
+	}
+
+	result := make(map[string]uint64, len(counts))
+	for i, flag := range clickhouse.QualityFlags {
+		result[flag] = uint64(counts[i])
+	}
+	return result, nil
+}
+
+// Close closes the connection pool
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+)
+
+func TestPostgresListingQuery(t *testing.T) {
+	hasPhotos := true
+	query, args := postgresDialect.listingQuery(clickhouse.ListingQuery{
+		City: "Москва", Metro: "Арбатская", MinPriceHour: 5000, MaxAge: 30, HasPhotos: &hasPhotos, Sort: "-price_hour",
+	})
+
+	expected := "SELECT data FROM listings WHERE NOT is_deleted AND completeness >= $1 AND location_city = $2" +
+		" AND data->'location_metro_stations' ? $3 AND price_hour >= $4 AND personal_age > 0 AND personal_age <= $5" +
+		" AND jsonb_array_length(COALESCE(data->'photos', '[]')) > 0 ORDER BY price_hour DESC, id LIMIT $6 OFFSET $7"
+	if query != expected {
+		t.Errorf("Expected %q, got %q", expected, query)
+	}
+	if !reflect.DeepEqual(args, []any{float32(0), "Москва", "Арбатская", int64(5000), int16(30), 100, 0}) {
+		t.Errorf("Unexpected args: %v", args)
+	}
+}
+
+func TestListingQueryRejectsUnknownSort(t *testing.T) {
+	query, _ := postgresDialect.listingQuery(clickhouse.ListingQuery{Sort: "description; DROP"})
+	expected := "SELECT data FROM listings WHERE NOT is_deleted AND completeness >= $1 ORDER BY last_scraped DESC, id LIMIT $2 OFFSET $3"
+	if query != expected {
+		t.Errorf("Expected %q, got %q", expected, query)
+	}
+}
+
+func TestSQLiteListingQuery(t *testing.T) {
+	hasPhotos := false
+	query, args := sqliteDialect.listingQuery(clickhouse.ListingQuery{Metro: "Арбатская", HasPhotos: &hasPhotos, Limit: 20, Offset: 40})
+
+	expected := "SELECT data FROM listings WHERE NOT is_deleted AND completeness >= ?" +
+		" AND EXISTS (SELECT 1 FROM json_each(data, '$.location_metro_stations') WHERE value = ?)" +
+		" AND COALESCE(json_array_length(data, '$.photos'), 0) = 0 ORDER BY last_scraped DESC, id LIMIT ? OFFSET ?"
+	if query != expected {
+		t.Errorf("Expected %q, got %q", expected, query)
+	}
+	if !reflect.DeepEqual(args, []any{float32(0), "Арбатская", 20, 40}) {
+		t.Errorf("Unexpected args: %v", args)
+	}
+}
+
+func TestSQLiteListingQueryMinQuality(t *testing.T) {
+	query, args := sqliteDialect.listingQuery(clickhouse.ListingQuery{MinQuality: 0.7})
+
+	expected := "SELECT data FROM listings WHERE NOT is_deleted AND completeness >= ?" +
+		" AND COALESCE(json_extract(data, '$.quality_score'), 1) >= ? ORDER BY last_scraped DESC, id LIMIT ? OFFSET ?"
+	if query != expected {
+		t.Errorf("Expected %q, got %q", expected, query)
+	}
+	if !reflect.DeepEqual(args, []any{float32(0), float32(0.7), 100, 0}) {
+		t.Errorf("Unexpected args: %v", args)
+	}
+}
+
+func TestSQLiteTimestampsSortAsText(t *testing.T) {
+	earlier := sqliteDialect.timestamp(time.Date(2025, 3, 1, 12, 0, 0, 500000000, time.UTC)).(string)
+	later := sqliteDialect.timestamp(time.Date(2025, 3, 1, 12, 0, 0, 0, time.FixedZone("UTC-1", -3600))).(string)
+	if earlier >= later {
+		t.Errorf("Expected %s to sort before %s", earlier, later)
+	}
+}
+
+func TestInterpolatedQuantile(t *testing.T) {
+	sorted := []float64{0.2, 0.4, 0.6, 0.8, 1}
+	cases := map[float64]float64{0: 0.2, 0.5: 0.6, 0.1: 0.28, 0.9: 0.92, 1: 1}
+	for fraction, expected := range cases {
+		if got := interpolatedQuantile(sorted, fraction); math.Abs(got-expected) > 1e-9 {
+			t.Errorf("Expected %v for quantile %v, got %v", expected, fraction, got)
+		}
+	}
+	if got := interpolatedQuantile(nil, 0.5); got != 0 {
+		t.Errorf("Expected 0 without values, got %v", got)
+	}
+}
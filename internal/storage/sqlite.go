@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// sqliteTimeFormat keeps stored times sortable as text: fixed width, UTC
+const sqliteTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// sqliteDialect runs SQLStore on an SQLite file, keeping the listing documents as JSON text
+var sqliteDialect = dialect{
+	name: BackendSQLite,
+	schema: []string{
+		`PRAGMA journal_mode = WAL`,
+		`CREATE TABLE IF NOT EXISTS listings (
+			id TEXT PRIMARY KEY,
+			source_site TEXT NOT NULL DEFAULT '',
+			source_id TEXT NOT NULL DEFAULT '',
+			source_url TEXT NOT NULL DEFAULT '',
+			created_at TEXT NOT NULL,
+			updated_at TEXT NOT NULL,
+			last_scraped TEXT NOT NULL,
+			location_city TEXT NOT NULL DEFAULT '',
+			personal_age INTEGER NOT NULL DEFAULT 0,
+			price_hour INTEGER NOT NULL DEFAULT 0,
+			completeness REAL NOT NULL DEFAULT 0,
+			is_deleted INTEGER NOT NULL DEFAULT 0,
+			status TEXT NOT NULL DEFAULT 'active',
+			data TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS listings_last_scraped_idx ON listings (last_scraped)`,
+		`CREATE INDEX IF NOT EXISTS listings_location_city_idx ON listings (location_city)`,
+		`CREATE TABLE IF NOT EXISTS listing_changes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			listing_id TEXT NOT NULL,
+			change_timestamp TEXT NOT NULL,
+			change_type TEXT NOT NULL,
+			field_name TEXT NOT NULL DEFAULT '',
+			old_value TEXT NOT NULL DEFAULT '',
+			new_value TEXT NOT NULL DEFAULT '',
+			source TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE INDEX IF NOT EXISTS listing_changes_listing_idx ON listing_changes (listing_id, change_timestamp)`,
+	},
+	placeholder:   func(int) string { return "?" },
+	timestamp:     func(t time.Time) any { return t.UTC().Format(sqliteTimeFormat) },
+	keepCreatedAt: `json_set(excluded.data, '$.created_at', json_extract(listings.data, '$.created_at'))`,
+	jsonText: func(field string) string {
+		return "COALESCE(json_extract(data, '$." + field + "'), '')"
+	},
+	jsonNumber: func(field string) string {
+		return "json_extract(data, '$." + field + "')"
+	},
+	jsonLength: func(field string) string {
+		return "COALESCE(json_array_length(data, '$." + field + "'), 0)"
+	},
+	jsonContains: func(field, param string) string {
+		return "EXISTS (SELECT 1 FROM json_each(data, '$." + field + "') WHERE value = " + param + ")"
+	},
+}
+
+// NewSQLiteStore opens the SQLite database at path through the named database/sql driver,
+// creating the file and its tables when missing. SQLite allows one writer at a time, so the
+// store uses a single connection.
+func NewSQLiteStore(ctx context.Context, driver, path string) (*SQLStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("sqlite storage needs SQLITE_PATH")
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create sqlite directory: %w", err)
+		}
+	}
+	return openSQLStore(ctx, sqliteDialect, driver, path, 1)
+}
@@ -0,0 +1,61 @@
+// Package storage abstracts the listing store, so tools, sinks and the local pipeline that only
+// insert, read and count listings run on ClickHouse, PostgreSQL or SQLite alike
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clickhouse"
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+)
+
+// Storage backends accepted in STORAGE_BACKEND
+const (
+	BackendClickHouse = "clickhouse"
+	BackendPostgres   = "postgres"
+	BackendSQLite     = "sqlite"
+)
+
+// Store keeps listings and their change log
+type Store interface {
+	// InsertFlattenedListing stores one listing version
+	InsertFlattenedListing(ctx context.Context, listing *clickhouse.FlattenedListing) error
+	// BatchInsertFlattenedListings stores listing versions in one batch
+	BatchInsertFlattenedListings(ctx context.Context, listings []*clickhouse.FlattenedListing) error
+	// GetListingByID returns the latest version of a live listing, or an error wrapping
+	// clickhouse.ErrListingNotFound
+	GetListingByID(ctx context.Context, id string) (*clickhouse.FlattenedListing, error)
+	// QueryListings returns the latest versions of the live listings matching q
+	QueryListings(ctx context.Context, q clickhouse.ListingQuery) ([]*clickhouse.FlattenedListing, error)
+	// LogChange records one change of a listing in the change log
+	LogChange(ctx context.Context, listingID, changeType, oldValue, newValue, fieldName, source string) error
+	// GetStats returns summary statistics over the live listings
+	GetStats(ctx context.Context) (map[string]interface{}, error)
+	// Close releases the connections of the store
+	Close() error
+}
+
+// The ClickHouse adapter is a Store as it is
+var (
+	_ Store = (*clickhouse.Adapter)(nil)
+	_ Store = (*SQLStore)(nil)
+)
+
+// Open connects to the store selected by cfg.Storage.Backend
+func Open(ctx context.Context, cfg *config.Config) (Store, error) {
+	switch cfg.Storage.Backend {
+	case BackendClickHouse, "":
+		adapter, err := clickhouse.NewAdapter(clickhouse.FromMainConfig(cfg, cfg.Debug))
+		if err != nil {
+			return nil, err
+		}
+		return adapter, nil
+	case BackendPostgres:
+		return NewPostgresStore(ctx, cfg.Storage.PostgresDriver, cfg.Storage.PostgresDSN, cfg.Storage.PostgresMaxConnections)
+	case BackendSQLite:
+		return NewSQLiteStore(ctx, cfg.Storage.SQLiteDriver, cfg.Storage.SQLitePath)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Storage.Backend)
+	}
+}
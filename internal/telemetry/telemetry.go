@@ -0,0 +1,220 @@
+// Package telemetry sends opt-in anonymous usage reports, so the maintainers can see which
+// features self-hosted instances use. A report holds the build version, a coarse listing
+// throughput bucket and the names of the enabled subsystems; never hosts, URLs, credentials,
+// site names or listing data.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+	"github.com/gregor-tokarev/hoe_parser/internal/events"
+	"github.com/gregor-tokarev/hoe_parser/internal/logger"
+)
+
+// log is the component logger of the package
+var log = logger.Component("telemetry")
+
+// Report is the anonymous usage report posted to the endpoint
+type Report struct {
+	Version        string   `json:"version"`
+	OS             string   `json:"os"`
+	Arch           string   `json:"arch"`
+	ParserMode     string   `json:"parser_mode"`
+	ListingsPerDay string   `json:"listings_per_day"` // a ThroughputBucket, never the exact count
+	Subsystems     []string `json:"subsystems"`
+}
+
+// throughputBuckets are the upper bounds of the listings per day buckets
+var throughputBuckets = []struct {
+	max   float64
+	label string
+}{
+	{0, "0"},
+	{100, "1-100"},
+	{1000, "100-1k"},
+	{10000, "1k-10k"},
+	{100000, "10k-100k"},
+}
+
+// ThroughputBucket returns the bucket a number of stored listings per day falls into
+func ThroughputBucket(perDay float64) string {
+	for _, bucket := range throughputBuckets {
+		if perDay <= bucket.max {
+			return bucket.label
+		}
+	}
+	return "100k+"
+}
+
+// Version returns the module version the binary was built from, "dev" for local builds
+func Version() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
+
+// Subsystems returns the names of the optional subsystems enabled in cfg
+func Subsystems(cfg *config.Config) []string {
+	enabled := []struct {
+		name string
+		on   bool
+	}{
+		{"kafka", cfg.KafkaEnabled},
+		{"link_dedup", cfg.Dedup.Enabled},
+		{"metrics", cfg.EnableMetrics},
+		{"metrics_snapshot", cfg.MetricsSnapshot.Enabled},
+		{"fleet_progress", cfg.FleetProgress.Enabled},
+		{"coverage_alert", cfg.CoverageAlert.Enabled},
+		{"id_gap_alert", cfg.GapAlert.Enabled},
+		{"dashboard", cfg.DashboardEnabled},
+		{"webhooks", len(cfg.Webhook.URLs) > 0},
+		{"telegram_lookup", cfg.Telegram.BotToken != ""},
+		{"translation", cfg.Translation.Backend != ""},
+		{"photo_hash", cfg.PhotoHash.Enabled},
+		{"insert_buffer", cfg.ClickHouse.InsertBuffer.Enabled},
+		{"mirror", cfg.Mirror.Percent > 0},
+		{"proxies", len(cfg.Proxies) > 0},
+	}
+
+	var names []string
+	for _, subsystem := range enabled {
+		if subsystem.on {
+			names = append(names, subsystem.name)
+		}
+	}
+	for _, sink := range cfg.Sinks.Enabled {
+		names = append(names, "sink:"+sink)
+	}
+	return names
+}
+
+// Reporter counts the stored listings and posts a report every interval
+type Reporter struct {
+	endpoint string
+	interval time.Duration
+	client   *http.Client
+	base     Report
+
+	listings atomic.Int64 // listings stored since the last report
+
+	mutex sync.Mutex
+	since time.Time // start of the current counting period
+}
+
+// NewReporter creates a reporter posting base, completed with the listing throughput, to endpoint
+func NewReporter(endpoint string, interval, timeout time.Duration, base Report) *Reporter {
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &Reporter{
+		endpoint: endpoint,
+		interval: interval,
+		client:   &http.Client{Timeout: timeout},
+		base:     base,
+		since:    clock.Now(),
+	}
+}
+
+// FromConfig creates a reporter from the main application config, or returns nil when telemetry
+// is not opted in or has no endpoint
+func FromConfig(cfg *config.Config) *Reporter {
+	if !cfg.Telemetry.Enabled {
+		return nil
+	}
+	if cfg.Telemetry.Endpoint == "" {
+		log.Warn("Telemetry enabled without TELEMETRY_ENDPOINT, not sending reports")
+		return nil
+	}
+	return NewReporter(cfg.Telemetry.Endpoint, cfg.Telemetry.Interval, cfg.Telemetry.Timeout, Report{
+		Version:    Version(),
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+		ParserMode: cfg.Parser.Mode,
+		Subsystems: Subsystems(cfg),
+	})
+}
+
+// Subscribe counts the listings stored, from ListingInserted events on bus
+func (r *Reporter) Subscribe(bus *events.Bus) {
+	bus.Subscribe("telemetry", 0, events.On(func(event events.ListingInserted) {
+		if event.Rows > 0 {
+			r.listings.Add(1)
+		}
+	}))
+}
+
+// Run posts a report every interval until ctx is done. Failed reports are logged at debug level
+// and not retried; the next report covers the whole time since the last one that was sent.
+func (r *Reporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.Send(ctx); err != nil {
+				log.Debug("Failed to send usage report", "error", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Send posts a report covering the listings stored since the last report that was sent
+func (r *Reporter) Send(ctx context.Context) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := clock.Now()
+	listings := r.listings.Load()
+	report := r.base
+	report.ListingsPerDay = ThroughputBucket(perDay(listings, now.Sub(r.since)))
+
+	body, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage report: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create usage report request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send usage report: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("usage report endpoint returned status %d", resp.StatusCode)
+	}
+
+	r.listings.Add(-listings)
+	r.since = now
+	return nil
+}
+
+// perDay scales a count over elapsed to a day
+func perDay(count int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(count) * float64(24*time.Hour) / float64(elapsed)
+}
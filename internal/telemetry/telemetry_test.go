@@ -0,0 +1,88 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+)
+
+func TestThroughputBucket(t *testing.T) {
+	tests := []struct {
+		perDay   float64
+		expected string
+	}{
+		{0, "0"},
+		{0.5, "1-100"},
+		{100, "1-100"},
+		{101, "100-1k"},
+		{25000, "10k-100k"},
+		{250000, "100k+"},
+	}
+
+	for _, tt := range tests {
+		if bucket := ThroughputBucket(tt.perDay); bucket != tt.expected {
+			t.Errorf("%v: expected bucket %s, got %s", tt.perDay, tt.expected, bucket)
+		}
+	}
+}
+
+func TestReporterSendsBucketedThroughput(t *testing.T) {
+	fixed := clock.NewFixed(time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC))
+	defer clock.SetClock(fixed)()
+
+	reports := make(chan Report, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var report Report
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+			t.Errorf("Failed to decode report: %v", err)
+		}
+		reports <- report
+	}))
+	defer server.Close()
+
+	reporter := NewReporter(server.URL, time.Hour, time.Second, Report{Version: "v1.2.0", Subsystems: []string{"metrics"}})
+	reporter.listings.Add(300)
+	fixed.Advance(12 * time.Hour)
+
+	if err := reporter.Send(context.Background()); err != nil {
+		t.Fatalf("Failed to send report: %v", err)
+	}
+	report := <-reports
+	if report.ListingsPerDay != "100-1k" || report.Version != "v1.2.0" || len(report.Subsystems) != 1 {
+		t.Errorf("Expected 600 listings per day in bucket 100-1k, got %+v", report)
+	}
+
+	// A sent report starts a new period
+	fixed.Advance(time.Hour)
+	if err := reporter.Send(context.Background()); err != nil {
+		t.Fatalf("Failed to send report: %v", err)
+	}
+	if report := <-reports; report.ListingsPerDay != "0" {
+		t.Errorf("Expected no listings in the second period, got %s", report.ListingsPerDay)
+	}
+}
+
+func TestFromConfigRequiresOptIn(t *testing.T) {
+	cfg := &config.Config{Telemetry: config.TelemetryConfig{Endpoint: "http://localhost/report"}}
+	if FromConfig(cfg) != nil {
+		t.Errorf("Expected no reporter without an opt-in")
+	}
+
+	cfg.Telemetry.Enabled = true
+	cfg.Sinks.Enabled = []string{"ndjson"}
+	cfg.PhotoHash.Enabled = true
+	if FromConfig(cfg) == nil {
+		t.Errorf("Expected a reporter once opted in")
+	}
+
+	subsystems := Subsystems(cfg)
+	if len(subsystems) != 2 || subsystems[0] != "photo_hash" || subsystems[1] != "sink:ndjson" {
+		t.Errorf("Expected subsystems [photo_hash sink:ndjson], got %v", subsystems)
+	}
+}
@@ -0,0 +1,156 @@
+package translate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+)
+
+// Supported translation backends
+const (
+	BackendLibreTranslate = "libretranslate"
+	BackendDeepL          = "deepl"
+)
+
+// DefaultDeepLURL is the DeepL API Free endpoint; paid plans use https://api.deepl.com
+const DefaultDeepLURL = "https://api-free.deepl.com"
+
+// FromConfig creates an enricher for the configured backend, or nil when translation is disabled
+func FromConfig(cfg config.TranslationConfig) (*Enricher, error) {
+	client := &http.Client{Timeout: cfg.Timeout}
+
+	var translator Translator
+	switch cfg.Backend {
+	case "":
+		return nil, nil
+	case BackendLibreTranslate:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("TRANSLATION_URL is required for the %s backend", cfg.Backend)
+		}
+		translator = NewLibreTranslate(cfg.URL, cfg.APIKey, client)
+	case BackendDeepL:
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("TRANSLATION_API_KEY is required for the %s backend", cfg.Backend)
+		}
+		baseURL := cfg.URL
+		if baseURL == "" {
+			baseURL = DefaultDeepLURL
+		}
+		translator = NewDeepL(baseURL, cfg.APIKey, client)
+	default:
+		return nil, fmt.Errorf("unknown translation backend %q", cfg.Backend)
+	}
+
+	return NewEnricher(translator, cfg.SourceLang, cfg.TargetLang, cfg.RatePerSecond, cfg.CacheSize), nil
+}
+
+// LibreTranslate calls a LibreTranslate server (self-hosted or libretranslate.com)
+type LibreTranslate struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+// NewLibreTranslate creates a LibreTranslate backend; apiKey may be empty for self-hosted servers
+func NewLibreTranslate(baseURL, apiKey string, client *http.Client) *LibreTranslate {
+	return &LibreTranslate{baseURL: strings.TrimSuffix(baseURL, "/"), apiKey: apiKey, client: client}
+}
+
+// Translate translates text with the /translate endpoint
+func (l *LibreTranslate) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	payload := map[string]string{
+		"q":      text,
+		"source": strings.ToLower(sourceLang),
+		"target": strings.ToLower(targetLang),
+		"format": "text",
+	}
+	if l.apiKey != "" {
+		payload["api_key"] = l.apiKey
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.baseURL+"/translate", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var result struct {
+		TranslatedText string `json:"translatedText"`
+	}
+	if err := doJSON(l.client, req, &result); err != nil {
+		return "", fmt.Errorf("libretranslate: %w", err)
+	}
+	return result.TranslatedText, nil
+}
+
+// DeepL calls the DeepL v2 API
+type DeepL struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+// NewDeepL creates a DeepL backend
+func NewDeepL(baseURL, apiKey string, client *http.Client) *DeepL {
+	return &DeepL{baseURL: strings.TrimSuffix(baseURL, "/"), apiKey: apiKey, client: client}
+}
+
+// Translate translates text with the /v2/translate endpoint
+func (d *DeepL) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	form := url.Values{
+		"text":        {text},
+		"source_lang": {strings.ToUpper(sourceLang)},
+		"target_lang": {strings.ToUpper(targetLang)},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.baseURL+"/v2/translate", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "DeepL-Auth-Key "+d.apiKey)
+
+	var result struct {
+		Translations []struct {
+			Text string `json:"text"`
+		} `json:"translations"`
+	}
+	if err := doJSON(d.client, req, &result); err != nil {
+		return "", fmt.Errorf("deepl: %w", err)
+	}
+	if len(result.Translations) == 0 {
+		return "", fmt.Errorf("deepl: empty response")
+	}
+	return result.Translations[0].Text, nil
+}
+
+// doJSON performs the request and decodes a successful JSON response into v
+func doJSON(client *http.Client, req *http.Request, v interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
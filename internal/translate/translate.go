@@ -0,0 +1,115 @@
+package translate
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// Translator translates text between languages using an external backend
+type Translator interface {
+	Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error)
+}
+
+// Enricher translates listing descriptions through a Translator, limiting the request rate and
+// caching translations so unchanged descriptions are never sent twice
+type Enricher struct {
+	translator Translator
+	sourceLang string
+	targetLang string
+	limiter    *rate.Limiter
+
+	cacheMutex sync.Mutex
+	cacheSize  int
+	cache      map[[sha256.Size]byte]*list.Element
+	order      *list.List // most recently used first
+}
+
+// cacheEntry is a cached translation
+type cacheEntry struct {
+	key         [sha256.Size]byte
+	translation string
+}
+
+// NewEnricher creates an enricher. ratePerSecond <= 0 disables rate limiting and cacheSize <= 0 disables caching.
+func NewEnricher(translator Translator, sourceLang, targetLang string, ratePerSecond float64, cacheSize int) *Enricher {
+	limit := rate.Inf
+	if ratePerSecond > 0 {
+		limit = rate.Limit(ratePerSecond)
+	}
+
+	return &Enricher{
+		translator: translator,
+		sourceLang: sourceLang,
+		targetLang: targetLang,
+		limiter:    rate.NewLimiter(limit, 1),
+		cacheSize:  cacheSize,
+		cache:      make(map[[sha256.Size]byte]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Translate returns the translation of text, from the cache when possible.
+// Blank text is returned unchanged without calling the backend.
+func (e *Enricher) Translate(ctx context.Context, text string) (string, error) {
+	if strings.TrimSpace(text) == "" {
+		return "", nil
+	}
+
+	key := sha256.Sum256([]byte(text))
+	if translation, ok := e.cached(key); ok {
+		return translation, nil
+	}
+
+	if err := e.limiter.Wait(ctx); err != nil {
+		return "", fmt.Errorf("failed to wait for translation rate limit: %w", err)
+	}
+
+	translation, err := e.translator.Translate(ctx, text, e.sourceLang, e.targetLang)
+	if err != nil {
+		return "", fmt.Errorf("failed to translate text: %w", err)
+	}
+
+	e.store(key, translation)
+	return translation, nil
+}
+
+// cached looks up a translation and marks it as recently used
+func (e *Enricher) cached(key [sha256.Size]byte) (string, bool) {
+	e.cacheMutex.Lock()
+	defer e.cacheMutex.Unlock()
+
+	element, ok := e.cache[key]
+	if !ok {
+		return "", false
+	}
+	e.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).translation, true
+}
+
+// store caches a translation, evicting the least recently used entry when full
+func (e *Enricher) store(key [sha256.Size]byte, translation string) {
+	if e.cacheSize <= 0 {
+		return
+	}
+
+	e.cacheMutex.Lock()
+	defer e.cacheMutex.Unlock()
+
+	if element, ok := e.cache[key]; ok {
+		e.order.MoveToFront(element)
+		return
+	}
+
+	e.cache[key] = e.order.PushFront(&cacheEntry{key: key, translation: translation})
+	if e.order.Len() > e.cacheSize {
+		oldest := e.order.Back()
+		e.order.Remove(oldest)
+		delete(e.cache, oldest.Value.(*cacheEntry).key)
+	}
+}
@@ -0,0 +1,79 @@
+package translate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type countingTranslator struct {
+	calls int
+}
+
+func (c *countingTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	c.calls++
+	return fmt.Sprintf("%s->%s:%s", sourceLang, targetLang, text), nil
+}
+
+func TestEnricherCachesTranslations(t *testing.T) {
+	backend := &countingTranslator{}
+	enricher := NewEnricher(backend, "ru", "en", 0, 2)
+	ctx := context.Background()
+
+	for _, text := range []string{"привет", "привет", "мир", "привет", "", "  "} {
+		if _, err := enricher.Translate(ctx, text); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if backend.calls != 2 {
+		t.Errorf("Expected 2 backend calls, got %d", backend.calls)
+	}
+
+	// "мир" is the least recently used entry and is evicted by a third text
+	enricher.Translate(ctx, "день")
+	enricher.Translate(ctx, "привет")
+	enricher.Translate(ctx, "мир")
+	if backend.calls != 4 {
+		t.Errorf("Expected the least recently used entry to be evicted, got %d backend calls", backend.calls)
+	}
+}
+
+func TestLibreTranslate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		json.NewDecoder(r.Body).Decode(&payload)
+		if r.URL.Path != "/translate" || payload["source"] != "ru" || payload["target"] != "en" {
+			t.Errorf("Unexpected request %s %+v", r.URL.Path, payload)
+		}
+		fmt.Fprint(w, `{"translatedText":"hello"}`)
+	}))
+	defer server.Close()
+
+	translated, err := NewLibreTranslate(server.URL, "", server.Client()).Translate(context.Background(), "привет", "ru", "en")
+	if err != nil || translated != "hello" {
+		t.Errorf("Expected hello, got %q (%v)", translated, err)
+	}
+}
+
+func TestDeepL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "DeepL-Auth-Key secret" || r.FormValue("target_lang") != "EN" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{"translations":[{"detected_source_language":"RU","text":"hello"}]}`)
+	}))
+	defer server.Close()
+
+	translated, err := NewDeepL(server.URL, "secret", server.Client()).Translate(context.Background(), "привет", "ru", "en")
+	if err != nil || translated != "hello" {
+		t.Errorf("Expected hello, got %q (%v)", translated, err)
+	}
+
+	if _, err := NewDeepL(server.URL, "wrong", server.Client()).Translate(context.Background(), "привет", "ru", "en"); err == nil {
+		t.Errorf("Expected an error for a rejected key")
+	}
+}
@@ -0,0 +1,109 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gregor-tokarev/hoe_parser/internal/clock"
+	"github.com/gregor-tokarev/hoe_parser/internal/config"
+	"github.com/gregor-tokarev/hoe_parser/pkg/webhook"
+)
+
+// Event is the envelope delivered to webhook receivers
+type Event struct {
+	Type      string      `json:"type"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// Dispatcher delivers signed events to the configured webhook endpoints
+type Dispatcher struct {
+	urls   []string
+	signer *webhook.Signer
+	client *http.Client
+}
+
+// NewDispatcher creates a dispatcher for the given endpoints.
+// Deliveries are signed when secret is not empty.
+func NewDispatcher(urls []string, secret string, timeout time.Duration) *Dispatcher {
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+
+	var signer *webhook.Signer
+	if secret != "" {
+		signer = webhook.NewSigner(secret)
+	}
+
+	return &Dispatcher{
+		urls:   urls,
+		signer: signer,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// FromConfig creates a dispatcher from the main application config
+func FromConfig(cfg *config.Config) *Dispatcher {
+	return NewDispatcher(cfg.Webhook.URLs, cfg.Webhook.Secret, cfg.Webhook.Timeout)
+}
+
+// Enabled reports whether any endpoints are configured
+func (d *Dispatcher) Enabled() bool {
+	return len(d.urls) > 0
+}
+
+// Send delivers an event to every endpoint and returns the last delivery error, if any
+func (d *Dispatcher) Send(ctx context.Context, eventType string, data interface{}) error {
+	if !d.Enabled() {
+		return nil
+	}
+
+	body, err := json.Marshal(Event{
+		Type:      eventType,
+		Timestamp: clock.Now(),
+		Data:      data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook event: %w", err)
+	}
+
+	var lastErr error
+	for _, url := range d.urls {
+		if err := d.deliver(ctx, url, body); err != nil {
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
+
+// deliver posts a single payload to one endpoint
+func (d *Dispatcher) deliver(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request for %s: %w", url, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if d.signer != nil {
+		if err := d.signer.SignRequest(req, body); err != nil {
+			return fmt.Errorf("failed to sign webhook request for %s: %w", url, err)
+		}
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to deliver webhook to %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s responded with status %d", url, resp.StatusCode)
+	}
+
+	return nil
+}
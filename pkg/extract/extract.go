@@ -0,0 +1,64 @@
+// Package extract runs the listing extraction of hoe_parser on pages fetched elsewhere, for tools
+// that have their own crawler and only need the parsed listings.
+package extract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gregor-tokarev/hoe_parser/internal/scraper"
+	"github.com/gregor-tokarev/hoe_parser/internal/service"
+	listing "github.com/gregor-tokarev/hoe_parser/proto"
+)
+
+// Extraction errors returned by ParseListingFromHTML and ParseListingFromDocument
+var (
+	ErrUnsupportedURL = errors.New("extract: no parser for url")
+	ErrNilDocument    = errors.New("extract: nil document")
+)
+
+// ParseListingFromHTML extracts the listing from the HTML of the listing page at url. The page may
+// be in the encoding the site serves it in or already decoded to UTF-8. Nothing is fetched: photos,
+// which come from a separate endpoint, are left empty and Telegram handles are not looked up.
+func ParseListingFromHTML(ctx context.Context, html []byte, url string) (*listing.Listing, error) {
+	parser, err := documentParser(url)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := service.ParsePage(html)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+	return parser.ParseListingDocument(ctx, url, doc)
+}
+
+// ParseListingFromDocument extracts the listing from the parsed listing page at url, like
+// ParseListingFromHTML. The document must hold UTF-8 text.
+func ParseListingFromDocument(ctx context.Context, doc *goquery.Document, url string) (*listing.Listing, error) {
+	if doc == nil {
+		return nil, ErrNilDocument
+	}
+
+	parser, err := documentParser(url)
+	if err != nil {
+		return nil, err
+	}
+	return parser.ParseListingDocument(ctx, url, doc)
+}
+
+// documentParser returns the parser of the site url is on
+func documentParser(url string) (scraper.DocumentParser, error) {
+	adapter, err := scraper.AdapterForURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedURL, url)
+	}
+
+	parser, ok := adapter.(scraper.DocumentParser)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s does not parse stored pages", ErrUnsupportedURL, adapter.Name())
+	}
+	return parser, nil
+}
@@ -0,0 +1,68 @@
+package extract
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/text/encoding/charmap"
+)
+
+const (
+	fixturePath = "../../internal/scraper/testdata/conformance/intimcity.gold/salon_spb.html"
+	fixtureURL  = "https://a.intimcity.gold/anketa1002.htm"
+)
+
+func TestParseListingFromHTML(t *testing.T) {
+	page, err := os.ReadFile(fixturePath)
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	decoded, err := charmap.Windows1251.NewDecoder().Bytes(page)
+	if err != nil {
+		t.Fatalf("Failed to decode fixture: %v", err)
+	}
+
+	// The page as served and the page decoded by another crawler give the same listing
+	for name, html := range map[string][]byte{"windows-1251": page, "utf-8": decoded} {
+		result, err := ParseListingFromHTML(context.Background(), html, fixtureURL)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", name, err)
+		}
+		if result.Id != "1002" {
+			t.Errorf("%s: expected id 1002, got %s", name, result.Id)
+		}
+		if result.PersonalInfo.GetName() != "Вероника" {
+			t.Errorf("%s: expected name Вероника, got %q", name, result.PersonalInfo.GetName())
+		}
+		if result.LocationInfo.GetCity() != "Санкт-Петербург" {
+			t.Errorf("%s: expected city Санкт-Петербург, got %q", name, result.LocationInfo.GetCity())
+		}
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(decoded))
+	if err != nil {
+		t.Fatalf("Failed to parse fixture: %v", err)
+	}
+	result, err := ParseListingFromDocument(context.Background(), doc, fixtureURL)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if result.PersonalInfo.GetAge() != 31 {
+		t.Errorf("Expected age 31, got %d", result.PersonalInfo.GetAge())
+	}
+}
+
+func TestParseListingRejectsUnknownSite(t *testing.T) {
+	_, err := ParseListingFromHTML(context.Background(), []byte("<html></html>"), "https://other.example/1")
+	if !errors.Is(err, ErrUnsupportedURL) {
+		t.Errorf("Expected ErrUnsupportedURL, got %v", err)
+	}
+
+	if _, err := ParseListingFromDocument(context.Background(), nil, fixtureURL); !errors.Is(err, ErrNilDocument) {
+		t.Errorf("Expected ErrNilDocument, got %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+// Package format renders counts, prices and shares for people to read, with the digit grouping,
+// decimal separator and currency placement of a locale. Machine-readable output such as JSON, CSV
+// and metrics keeps raw numbers.
+package format
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Locale selects the conventions numbers are written in
+type Locale string
+
+// Supported locales
+const (
+	English Locale = "en" // 1,234,567.5 · 12.5% · ₽7,000
+	Russian Locale = "ru" // 1 234 567,5 · 12,5 % · 7 000 ₽
+)
+
+// nbsp is the no-break space Russian uses to group digits and before % and currency signs, so a
+// number is never wrapped across lines
+const nbsp = "\u00a0"
+
+// currencySymbols are the signs written instead of ISO currency codes
+var currencySymbols = map[string]string{
+	"RUB": "₽",
+	"USD": "$",
+	"EUR": "€",
+}
+
+// ParseLocale reads a locale name such as "ru", "ru-RU" or "en_US". Unknown names return an
+// error together with English, so callers may warn and carry on.
+func ParseLocale(name string) (Locale, error) {
+	language, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(name)), "-")
+	language, _, _ = strings.Cut(language, "_")
+	switch Locale(language) {
+	case English, "":
+		return English, nil
+	case Russian:
+		return Russian, nil
+	}
+	return English, fmt.Errorf("unsupported locale %q, expected %s or %s", name, English, Russian)
+}
+
+// separators returns the digit group and decimal separators of the locale
+func (l Locale) separators() (group, decimal string) {
+	if l == Russian {
+		return nbsp, ","
+	}
+	return ",", "."
+}
+
+// Int writes an integer with grouped thousands
+func (l Locale) Int(n int64) string {
+	group, _ := l.separators()
+	if n < 0 {
+		return "-" + groupDigits(strconv.FormatUint(uint64(-n), 10), group)
+	}
+	return groupDigits(strconv.FormatInt(n, 10), group)
+}
+
+// Uint writes an unsigned integer with grouped thousands
+func (l Locale) Uint(n uint64) string {
+	group, _ := l.separators()
+	return groupDigits(strconv.FormatUint(n, 10), group)
+}
+
+// Decimal writes v rounded to decimals places, with grouped thousands
+func (l Locale) Decimal(v float64, decimals int) string {
+	group, decimal := l.separators()
+	text := strconv.FormatFloat(v, 'f', max(decimals, 0), 64)
+
+	sign := ""
+	if strings.HasPrefix(text, "-") {
+		sign, text = "-", text[1:]
+	}
+	whole, fraction, hasFraction := strings.Cut(text, ".")
+	text = sign + groupDigits(whole, group)
+	if hasFraction {
+		text += decimal + fraction
+	}
+	return text
+}
+
+// Percent writes a share, 0.125 for 12.5%, as a percentage rounded to decimals places
+func (l Locale) Percent(share float64, decimals int) string {
+	if l == Russian {
+		return l.Decimal(share*100, decimals) + nbsp + "%"
+	}
+	return l.Decimal(share*100, decimals) + "%"
+}
+
+// Money writes a whole amount in an ISO currency such as "RUB". Known currencies are written
+// with their sign, others with their code; without a currency only the amount is written.
+func (l Locale) Money(amount int64, currency string) string {
+	number := l.Int(amount)
+	if currency == "" {
+		return number
+	}
+
+	symbol, known := currencySymbols[strings.ToUpper(currency)]
+	if !known {
+		symbol = strings.ToUpper(currency)
+	}
+	switch {
+	case l == Russian:
+		return number + nbsp + symbol
+	case known:
+		return symbol + number
+	default:
+		return symbol + " " + number
+	}
+}
+
+// groupDigits inserts group between every three digits of a string of digits, from the right
+func groupDigits(digits, group string) string {
+	if len(digits) <= 3 {
+		return digits
+	}
+
+	var b strings.Builder
+	head := len(digits) % 3
+	if head > 0 {
+		b.WriteString(digits[:head])
+	}
+	for i := head; i < len(digits); i += 3 {
+		if b.Len() > 0 {
+			b.WriteString(group)
+		}
+		b.WriteString(digits[i : i+3])
+	}
+	return b.String()
+}
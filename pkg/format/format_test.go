@@ -0,0 +1,51 @@
+package format
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLocales(t *testing.T) {
+	tests := []struct {
+		name    string
+		got     func(Locale) string
+		english string
+		russian string
+	}{
+		{"small int", func(l Locale) string { return l.Int(999) }, "999", "999"},
+		{"int", func(l Locale) string { return l.Int(1234567) }, "1,234,567", "1 234 567"},
+		{"negative int", func(l Locale) string { return l.Int(-1000) }, "-1,000", "-1 000"},
+		{"uint", func(l Locale) string { return l.Uint(10000) }, "10,000", "10 000"},
+		{"decimal", func(l Locale) string { return l.Decimal(1234.567, 1) }, "1,234.6", "1 234,6"},
+		{"negative decimal", func(l Locale) string { return l.Decimal(-0.25, 2) }, "-0.25", "-0,25"},
+		{"percent", func(l Locale) string { return l.Percent(0.125, 1) }, "12.5%", "12,5 %"},
+		{"whole percent", func(l Locale) string { return l.Percent(0.5, 0) }, "50%", "50 %"},
+		{"rubles", func(l Locale) string { return l.Money(7000, "RUB") }, "₽7,000", "7 000 ₽"},
+		{"other currency", func(l Locale) string { return l.Money(15000, "kzt") }, "KZT 15,000", "15 000 KZT"},
+		{"no currency", func(l Locale) string { return l.Money(5000, "") }, "5,000", "5 000"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.got(English); got != tt.english {
+			t.Errorf("%s: expected %q in English, got %q", tt.name, tt.english, got)
+		}
+		// Russian groups digits and separates signs with a no-break space
+		expected := strings.ReplaceAll(tt.russian, " ", "\u00a0")
+		if got := tt.got(Russian); got != expected {
+			t.Errorf("%s: expected %q in Russian, got %q", tt.name, expected, got)
+		}
+	}
+}
+
+func TestParseLocale(t *testing.T) {
+	for name, expected := range map[string]Locale{"": English, "en": English, "en_US": English, "ru": Russian, "RU-ru": Russian} {
+		locale, err := ParseLocale(name)
+		if err != nil || locale != expected {
+			t.Errorf("%q: expected %s, got %s, %v", name, expected, locale, err)
+		}
+	}
+
+	if locale, err := ParseLocale("de"); err == nil || locale != English {
+		t.Errorf("Expected an error and English for an unsupported locale, got %s, %v", locale, err)
+	}
+}
@@ -0,0 +1,73 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Header names attached to every signed webhook delivery
+const (
+	HeaderSignature = "X-Hoe-Signature"
+	HeaderTimestamp = "X-Hoe-Timestamp"
+	HeaderNonce     = "X-Hoe-Nonce"
+)
+
+// signaturePrefix identifies the signing algorithm in the signature header
+const signaturePrefix = "sha256="
+
+// Signer produces HMAC-SHA256 signatures for webhook payloads
+type Signer struct {
+	secret []byte
+}
+
+// NewSigner creates a new signer using the shared secret
+func NewSigner(secret string) *Signer {
+	return &Signer{secret: []byte(secret)}
+}
+
+// Sign computes the signature for a payload sent at the given timestamp with the given nonce.
+// The signed message is "<unix timestamp>.<nonce>.<body>"
+func (s *Signer) Sign(body []byte, timestamp time.Time, nonce string) string {
+	return signaturePrefix + hex.EncodeToString(computeMAC(s.secret, body, timestamp.Unix(), nonce))
+}
+
+// SignRequest attaches timestamp, nonce and signature headers to the request
+func (s *Signer) SignRequest(req *http.Request, body []byte) error {
+	nonce, err := NewNonce()
+	if err != nil {
+		return err
+	}
+
+	timestamp := time.Now()
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	req.Header.Set(HeaderNonce, nonce)
+	req.Header.Set(HeaderSignature, s.Sign(body, timestamp, nonce))
+
+	return nil
+}
+
+// NewNonce returns a random 128-bit hex-encoded nonce
+func NewNonce() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// computeMAC returns the raw HMAC-SHA256 of the signed message
+func computeMAC(secret, body []byte, unixTimestamp int64, nonce string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(unixTimestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write([]byte(nonce))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
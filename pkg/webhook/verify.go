@@ -0,0 +1,167 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Verification errors returned by Verifier
+var (
+	ErrMissingHeaders   = errors.New("webhook: missing signature headers")
+	ErrInvalidTimestamp = errors.New("webhook: invalid timestamp")
+	ErrTimestampExpired = errors.New("webhook: timestamp outside tolerance window")
+	ErrInvalidSignature = errors.New("webhook: invalid signature")
+	ErrNonceReplayed    = errors.New("webhook: nonce already used")
+	ErrBodyReadFailed   = errors.New("webhook: failed to read body")
+)
+
+const (
+	defaultTolerance     = 5 * time.Minute
+	defaultMaxBodyLength = 10 << 20
+)
+
+// NonceStore remembers nonces that have already been accepted
+type NonceStore interface {
+	// Remember records the nonce until expiresAt and returns false if it was already present
+	Remember(nonce string, expiresAt time.Time) bool
+}
+
+// MemoryNonceStore is an in-process NonceStore suitable for a single receiver instance
+type MemoryNonceStore struct {
+	mutex  sync.Mutex
+	nonces map[string]time.Time
+}
+
+// NewMemoryNonceStore creates an empty in-memory nonce store
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{nonces: make(map[string]time.Time)}
+}
+
+// Remember records the nonce and reports whether it was unseen
+func (m *MemoryNonceStore) Remember(nonce string, expiresAt time.Time) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	for n, exp := range m.nonces {
+		if now.After(exp) {
+			delete(m.nonces, n)
+		}
+	}
+
+	if _, exists := m.nonces[nonce]; exists {
+		return false
+	}
+
+	m.nonces[nonce] = expiresAt
+	return true
+}
+
+// Verifier validates signed webhook deliveries on the receiving side
+type Verifier struct {
+	secret    []byte
+	tolerance time.Duration
+	nonces    NonceStore
+}
+
+// NewVerifier creates a verifier with a 5 minute timestamp tolerance and in-memory replay protection
+func NewVerifier(secret string) *Verifier {
+	return &Verifier{
+		secret:    []byte(secret),
+		tolerance: defaultTolerance,
+		nonces:    NewMemoryNonceStore(),
+	}
+}
+
+// SetTolerance sets the maximum allowed clock skew between sender and receiver
+func (v *Verifier) SetTolerance(tolerance time.Duration) {
+	v.tolerance = tolerance
+}
+
+// SetNonceStore replaces the nonce store, e.g. with a shared store for multiple receivers
+func (v *Verifier) SetNonceStore(store NonceStore) {
+	v.nonces = store
+}
+
+// Verify checks a payload against its signature, timestamp and nonce header values
+func (v *Verifier) Verify(body []byte, signature, timestamp, nonce string) error {
+	if signature == "" || timestamp == "" || nonce == "" {
+		return ErrMissingHeaders
+	}
+
+	unixTimestamp, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+
+	sentAt := time.Unix(unixTimestamp, 0)
+	skew := time.Since(sentAt)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > v.tolerance {
+		return ErrTimestampExpired
+	}
+
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return ErrInvalidSignature
+	}
+	provided, err := hex.DecodeString(strings.TrimPrefix(signature, signaturePrefix))
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	expected := computeMAC(v.secret, body, unixTimestamp, nonce)
+	if !hmac.Equal(provided, expected) {
+		return ErrInvalidSignature
+	}
+
+	// Only remember nonces of authentic deliveries so forged requests can't poison the store
+	if !v.nonces.Remember(nonce, sentAt.Add(v.tolerance)) {
+		return ErrNonceReplayed
+	}
+
+	return nil
+}
+
+// VerifyRequest reads and verifies the request body, then restores it so handlers can read it again.
+// It returns the verified body.
+func (v *Verifier) VerifyRequest(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, defaultMaxBodyLength))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBodyReadFailed, err)
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	err = v.Verify(body,
+		r.Header.Get(HeaderSignature),
+		r.Header.Get(HeaderTimestamp),
+		r.Header.Get(HeaderNonce),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+// Middleware rejects requests that fail verification with 401 before calling next
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := v.VerifyRequest(r); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
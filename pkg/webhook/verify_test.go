@@ -0,0 +1,114 @@
+package webhook
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	signer := NewSigner("secret")
+	verifier := NewVerifier("secret")
+
+	body := []byte(`{"type":"listing.updated"}`)
+	now := time.Now()
+	signature := signer.Sign(body, now, "nonce-1")
+
+	err := verifier.Verify(body, signature, strconv.FormatInt(now.Unix(), 10), "nonce-1")
+	if err != nil {
+		t.Errorf("Expected valid signature, got: %v", err)
+	}
+}
+
+func TestVerifyRejectsTamperedBody(t *testing.T) {
+	signer := NewSigner("secret")
+	verifier := NewVerifier("secret")
+
+	now := time.Now()
+	signature := signer.Sign([]byte("original"), now, "nonce-1")
+
+	err := verifier.Verify([]byte("tampered"), signature, strconv.FormatInt(now.Unix(), 10), "nonce-1")
+	if !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature, got: %v", err)
+	}
+}
+
+func TestVerifyRejectsWrongSecret(t *testing.T) {
+	signer := NewSigner("secret")
+	verifier := NewVerifier("other-secret")
+
+	now := time.Now()
+	body := []byte("payload")
+	signature := signer.Sign(body, now, "nonce-1")
+
+	err := verifier.Verify(body, signature, strconv.FormatInt(now.Unix(), 10), "nonce-1")
+	if !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature, got: %v", err)
+	}
+}
+
+func TestVerifyRejectsExpiredTimestamp(t *testing.T) {
+	signer := NewSigner("secret")
+	verifier := NewVerifier("secret")
+	verifier.SetTolerance(time.Minute)
+
+	sentAt := time.Now().Add(-10 * time.Minute)
+	body := []byte("payload")
+	signature := signer.Sign(body, sentAt, "nonce-1")
+
+	err := verifier.Verify(body, signature, strconv.FormatInt(sentAt.Unix(), 10), "nonce-1")
+	if !errors.Is(err, ErrTimestampExpired) {
+		t.Errorf("Expected ErrTimestampExpired, got: %v", err)
+	}
+}
+
+func TestVerifyRejectsReplayedNonce(t *testing.T) {
+	signer := NewSigner("secret")
+	verifier := NewVerifier("secret")
+
+	now := time.Now()
+	body := []byte("payload")
+	signature := signer.Sign(body, now, "nonce-1")
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	if err := verifier.Verify(body, signature, timestamp, "nonce-1"); err != nil {
+		t.Fatalf("Expected first delivery to pass, got: %v", err)
+	}
+
+	err := verifier.Verify(body, signature, timestamp, "nonce-1")
+	if !errors.Is(err, ErrNonceReplayed) {
+		t.Errorf("Expected ErrNonceReplayed, got: %v", err)
+	}
+}
+
+func TestVerifyRequest(t *testing.T) {
+	signer := NewSigner("secret")
+	verifier := NewVerifier("secret")
+
+	body := []byte(`{"id":"123"}`)
+	req, err := http.NewRequest("POST", "http://example.com/hook", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	if err := signer.SignRequest(req, body); err != nil {
+		t.Fatalf("Failed to sign request: %v", err)
+	}
+
+	verified, err := verifier.VerifyRequest(req)
+	if err != nil {
+		t.Fatalf("Expected request to verify, got: %v", err)
+	}
+
+	if !bytes.Equal(verified, body) {
+		t.Errorf("Expected verified body %s, got %s", body, verified)
+	}
+
+	missing, _ := http.NewRequest("POST", "http://example.com/hook", bytes.NewReader(body))
+	if _, err := verifier.VerifyRequest(missing); !errors.Is(err, ErrMissingHeaders) {
+		t.Errorf("Expected ErrMissingHeaders, got: %v", err)
+	}
+}
@@ -23,18 +23,20 @@ const (
 
 // Main listing information
 type Listing struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	PersonalInfo  *PersonalInfo          `protobuf:"bytes,2,opt,name=personal_info,json=personalInfo,proto3" json:"personal_info,omitempty"`
-	ContactInfo   *ContactInfo           `protobuf:"bytes,3,opt,name=contact_info,json=contactInfo,proto3" json:"contact_info,omitempty"`
-	PricingInfo   *PricingInfo           `protobuf:"bytes,4,opt,name=pricing_info,json=pricingInfo,proto3" json:"pricing_info,omitempty"`
-	ServiceInfo   *ServiceInfo           `protobuf:"bytes,5,opt,name=service_info,json=serviceInfo,proto3" json:"service_info,omitempty"`
-	LocationInfo  *LocationInfo          `protobuf:"bytes,6,opt,name=location_info,json=locationInfo,proto3" json:"location_info,omitempty"`
-	Description   string                 `protobuf:"bytes,7,opt,name=description,proto3" json:"description,omitempty"`
-	LastUpdated   string                 `protobuf:"bytes,8,opt,name=last_updated,json=lastUpdated,proto3" json:"last_updated,omitempty"`
-	Photos        []string               `protobuf:"bytes,9,rep,name=photos,proto3" json:"photos,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Id                 string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	PersonalInfo       *PersonalInfo          `protobuf:"bytes,2,opt,name=personal_info,json=personalInfo,proto3" json:"personal_info,omitempty"`
+	ContactInfo        *ContactInfo           `protobuf:"bytes,3,opt,name=contact_info,json=contactInfo,proto3" json:"contact_info,omitempty"`
+	PricingInfo        *PricingInfo           `protobuf:"bytes,4,opt,name=pricing_info,json=pricingInfo,proto3" json:"pricing_info,omitempty"`
+	ServiceInfo        *ServiceInfo           `protobuf:"bytes,5,opt,name=service_info,json=serviceInfo,proto3" json:"service_info,omitempty"`
+	LocationInfo       *LocationInfo          `protobuf:"bytes,6,opt,name=location_info,json=locationInfo,proto3" json:"location_info,omitempty"`
+	Description        string                 `protobuf:"bytes,7,opt,name=description,proto3" json:"description,omitempty"`
+	LastUpdated        string                 `protobuf:"bytes,8,opt,name=last_updated,json=lastUpdated,proto3" json:"last_updated,omitempty"`
+	Photos             []string               `protobuf:"bytes,9,rep,name=photos,proto3" json:"photos,omitempty"`
+	DescriptionEn      string                 `protobuf:"bytes,10,opt,name=description_en,json=descriptionEn,proto3" json:"description_en,omitempty"`                // English translation of description, empty when translation is disabled
+	AvailabilityStatus string                 `protobuf:"bytes,11,opt,name=availability_status,json=availabilityStatus,proto3" json:"availability_status,omitempty"` // active, temporarily_unavailable or vacation, from the status banner of the page
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
 }
 
 func (x *Listing) Reset() {
@@ -130,6 +132,20 @@ func (x *Listing) GetPhotos() []string {
 	return nil
 }
 
+func (x *Listing) GetDescriptionEn() string {
+	if x != nil {
+		return x.DescriptionEn
+	}
+	return ""
+}
+
+func (x *Listing) GetAvailabilityStatus() string {
+	if x != nil {
+		return x.AvailabilityStatus
+	}
+	return ""
+}
+
 // Personal information
 type PersonalInfo struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -141,6 +157,8 @@ type PersonalInfo struct {
 	HairColor     string                 `protobuf:"bytes,6,opt,name=hair_color,json=hairColor,proto3" json:"hair_color,omitempty"`
 	EyeColor      string                 `protobuf:"bytes,7,opt,name=eye_color,json=eyeColor,proto3" json:"eye_color,omitempty"`
 	BodyType      string                 `protobuf:"bytes,8,opt,name=body_type,json=bodyType,proto3" json:"body_type,omitempty"`
+	Gender        string                 `protobuf:"bytes,9,opt,name=gender,proto3" json:"gender,omitempty"`
+	Orientation   string                 `protobuf:"bytes,10,opt,name=orientation,proto3" json:"orientation,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -231,16 +249,32 @@ func (x *PersonalInfo) GetBodyType() string {
 	return ""
 }
 
+func (x *PersonalInfo) GetGender() string {
+	if x != nil {
+		return x.Gender
+	}
+	return ""
+}
+
+func (x *PersonalInfo) GetOrientation() string {
+	if x != nil {
+		return x.Orientation
+	}
+	return ""
+}
+
 // Contact information
 type ContactInfo struct {
-	state             protoimpl.MessageState `protogen:"open.v1"`
-	Phone             string                 `protobuf:"bytes,1,opt,name=phone,proto3" json:"phone,omitempty"`
-	Telegram          string                 `protobuf:"bytes,2,opt,name=telegram,proto3" json:"telegram,omitempty"`
-	Email             string                 `protobuf:"bytes,3,opt,name=email,proto3" json:"email,omitempty"`
-	WhatsappAvailable bool                   `protobuf:"varint,4,opt,name=whatsapp_available,json=whatsappAvailable,proto3" json:"whatsapp_available,omitempty"`
-	ViberAvailable    bool                   `protobuf:"varint,5,opt,name=viber_available,json=viberAvailable,proto3" json:"viber_available,omitempty"`
-	unknownFields     protoimpl.UnknownFields
-	sizeCache         protoimpl.SizeCache
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Phone              string                 `protobuf:"bytes,1,opt,name=phone,proto3" json:"phone,omitempty"`
+	Telegram           string                 `protobuf:"bytes,2,opt,name=telegram,proto3" json:"telegram,omitempty"`
+	Email              string                 `protobuf:"bytes,3,opt,name=email,proto3" json:"email,omitempty"`
+	WhatsappAvailable  bool                   `protobuf:"varint,4,opt,name=whatsapp_available,json=whatsappAvailable,proto3" json:"whatsapp_available,omitempty"`
+	ViberAvailable     bool                   `protobuf:"varint,5,opt,name=viber_available,json=viberAvailable,proto3" json:"viber_available,omitempty"`
+	TelegramCandidates []string               `protobuf:"bytes,6,rep,name=telegram_candidates,json=telegramCandidates,proto3" json:"telegram_candidates,omitempty"`   // every handle found on the page, before validation
+	TelegramConfidence float32                `protobuf:"fixed32,7,opt,name=telegram_confidence,json=telegramConfidence,proto3" json:"telegram_confidence,omitempty"` // confidence of the validated telegram handle, 0..1
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
 }
 
 func (x *ContactInfo) Reset() {
@@ -308,6 +342,20 @@ func (x *ContactInfo) GetViberAvailable() bool {
 	return false
 }
 
+func (x *ContactInfo) GetTelegramCandidates() []string {
+	if x != nil {
+		return x.TelegramCandidates
+	}
+	return nil
+}
+
+func (x *ContactInfo) GetTelegramConfidence() float32 {
+	if x != nil {
+		return x.TelegramConfidence
+	}
+	return 0
+}
+
 // Pricing information
 type PricingInfo struct {
 	state          protoimpl.MessageState `protogen:"open.v1"`
@@ -440,14 +488,18 @@ func (x *ServiceInfo) GetMeetingType() string {
 
 // Location information
 type LocationInfo struct {
-	state            protoimpl.MessageState `protogen:"open.v1"`
-	MetroStations    []string               `protobuf:"bytes,1,rep,name=metro_stations,json=metroStations,proto3" json:"metro_stations,omitempty"`
-	District         string                 `protobuf:"bytes,2,opt,name=district,proto3" json:"district,omitempty"`
-	City             string                 `protobuf:"bytes,3,opt,name=city,proto3" json:"city,omitempty"`
-	OutcallAvailable bool                   `protobuf:"varint,4,opt,name=outcall_available,json=outcallAvailable,proto3" json:"outcall_available,omitempty"`
-	IncallAvailable  bool                   `protobuf:"varint,5,opt,name=incall_available,json=incallAvailable,proto3" json:"incall_available,omitempty"`
-	unknownFields    protoimpl.UnknownFields
-	sizeCache        protoimpl.SizeCache
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	MetroStations      []string               `protobuf:"bytes,1,rep,name=metro_stations,json=metroStations,proto3" json:"metro_stations,omitempty"`
+	District           string                 `protobuf:"bytes,2,opt,name=district,proto3" json:"district,omitempty"`
+	City               string                 `protobuf:"bytes,3,opt,name=city,proto3" json:"city,omitempty"`
+	OutcallAvailable   bool                   `protobuf:"varint,4,opt,name=outcall_available,json=outcallAvailable,proto3" json:"outcall_available,omitempty"`
+	IncallAvailable    bool                   `protobuf:"varint,5,opt,name=incall_available,json=incallAvailable,proto3" json:"incall_available,omitempty"`
+	ServiceArea        []string               `protobuf:"bytes,6,rep,name=service_area,json=serviceArea,proto3" json:"service_area,omitempty"` // neighborhoods served for outcall
+	WorksInSalon       bool                   `protobuf:"varint,7,opt,name=works_in_salon,json=worksInSalon,proto3" json:"works_in_salon,omitempty"`
+	SalonAddress       string                 `protobuf:"bytes,8,opt,name=salon_address,json=salonAddress,proto3" json:"salon_address,omitempty"`
+	AvailabilitySource string                 `protobuf:"bytes,9,opt,name=availability_source,json=availabilitySource,proto3" json:"availability_source,omitempty"` // how outcall/incall availability was determined: pricing_table or page_text
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
 }
 
 func (x *LocationInfo) Reset() {
@@ -515,11 +567,84 @@ func (x *LocationInfo) GetIncallAvailable() bool {
 	return false
 }
 
+func (x *LocationInfo) GetServiceArea() []string {
+	if x != nil {
+		return x.ServiceArea
+	}
+	return nil
+}
+
+func (x *LocationInfo) GetWorksInSalon() bool {
+	if x != nil {
+		return x.WorksInSalon
+	}
+	return false
+}
+
+func (x *LocationInfo) GetSalonAddress() string {
+	if x != nil {
+		return x.SalonAddress
+	}
+	return ""
+}
+
+func (x *LocationInfo) GetAvailabilitySource() string {
+	if x != nil {
+		return x.AvailabilitySource
+	}
+	return ""
+}
+
+// A page of listings, the protobuf response of the listing query endpoint
+type ListingList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Listings      []*Listing             `protobuf:"bytes,1,rep,name=listings,proto3" json:"listings,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListingList) Reset() {
+	*x = ListingList{}
+	mi := &file_proto_listing_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListingList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListingList) ProtoMessage() {}
+
+func (x *ListingList) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_listing_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListingList.ProtoReflect.Descriptor instead.
+func (*ListingList) Descriptor() ([]byte, []int) {
+	return file_proto_listing_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ListingList) GetListings() []*Listing {
+	if x != nil {
+		return x.Listings
+	}
+	return nil
+}
+
 var File_proto_listing_proto protoreflect.FileDescriptor
 
 const file_proto_listing_proto_rawDesc = "" +
 	"\n" +
-	"\x13proto/listing.proto\x12\alisting\"\x99\x03\n" +
+	"\x13proto/listing.proto\x12\alisting\"\xf1\x03\n" +
 	"\aListing\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12:\n" +
 	"\rpersonal_info\x18\x02 \x01(\v2\x15.listing.PersonalInfoR\fpersonalInfo\x127\n" +
@@ -529,7 +654,10 @@ const file_proto_listing_proto_rawDesc = "" +
 	"\rlocation_info\x18\x06 \x01(\v2\x15.listing.LocationInfoR\flocationInfo\x12 \n" +
 	"\vdescription\x18\a \x01(\tR\vdescription\x12!\n" +
 	"\flast_updated\x18\b \x01(\tR\vlastUpdated\x12\x16\n" +
-	"\x06photos\x18\t \x03(\tR\x06photos\"\xde\x01\n" +
+	"\x06photos\x18\t \x03(\tR\x06photos\x12%\n" +
+	"\x0edescription_en\x18\n" +
+	" \x01(\tR\rdescriptionEn\x12/\n" +
+	"\x13availability_status\x18\v \x01(\tR\x12availabilityStatus\"\x98\x02\n" +
 	"\fPersonalInfo\x12\x12\n" +
 	"\x04name\x18\x01 \x01(\tR\x04name\x12\x10\n" +
 	"\x03age\x18\x02 \x01(\x05R\x03age\x12\x16\n" +
@@ -540,13 +668,18 @@ const file_proto_listing_proto_rawDesc = "" +
 	"\n" +
 	"hair_color\x18\x06 \x01(\tR\thairColor\x12\x1b\n" +
 	"\teye_color\x18\a \x01(\tR\beyeColor\x12\x1b\n" +
-	"\tbody_type\x18\b \x01(\tR\bbodyType\"\xad\x01\n" +
+	"\tbody_type\x18\b \x01(\tR\bbodyType\x12\x16\n" +
+	"\x06gender\x18\t \x01(\tR\x06gender\x12 \n" +
+	"\vorientation\x18\n" +
+	" \x01(\tR\vorientation\"\x8f\x02\n" +
 	"\vContactInfo\x12\x14\n" +
 	"\x05phone\x18\x01 \x01(\tR\x05phone\x12\x1a\n" +
 	"\btelegram\x18\x02 \x01(\tR\btelegram\x12\x14\n" +
 	"\x05email\x18\x03 \x01(\tR\x05email\x12-\n" +
 	"\x12whatsapp_available\x18\x04 \x01(\bR\x11whatsappAvailable\x12'\n" +
-	"\x0fviber_available\x18\x05 \x01(\bR\x0eviberAvailable\"\xd1\x02\n" +
+	"\x0fviber_available\x18\x05 \x01(\bR\x0eviberAvailable\x12/\n" +
+	"\x13telegram_candidates\x18\x06 \x03(\tR\x12telegramCandidates\x12/\n" +
+	"\x13telegram_confidence\x18\a \x01(\x02R\x12telegramConfidence\"\xd1\x02\n" +
 	"\vPricingInfo\x12Q\n" +
 	"\x0fduration_prices\x18\x01 \x03(\v2(.listing.PricingInfo.DurationPricesEntryR\x0edurationPrices\x12N\n" +
 	"\x0eservice_prices\x18\x02 \x03(\v2'.listing.PricingInfo.ServicePricesEntryR\rservicePrices\x12\x1a\n" +
@@ -561,13 +694,19 @@ const file_proto_listing_proto_rawDesc = "" +
 	"\x12available_services\x18\x01 \x03(\tR\x11availableServices\x12/\n" +
 	"\x13additional_services\x18\x02 \x03(\tR\x12additionalServices\x12\"\n" +
 	"\frestrictions\x18\x03 \x03(\tR\frestrictions\x12!\n" +
-	"\fmeeting_type\x18\x04 \x01(\tR\vmeetingType\"\xbd\x01\n" +
+	"\fmeeting_type\x18\x04 \x01(\tR\vmeetingType\"\xdc\x02\n" +
 	"\fLocationInfo\x12%\n" +
 	"\x0emetro_stations\x18\x01 \x03(\tR\rmetroStations\x12\x1a\n" +
 	"\bdistrict\x18\x02 \x01(\tR\bdistrict\x12\x12\n" +
 	"\x04city\x18\x03 \x01(\tR\x04city\x12+\n" +
 	"\x11outcall_available\x18\x04 \x01(\bR\x10outcallAvailable\x12)\n" +
-	"\x10incall_available\x18\x05 \x01(\bR\x0fincallAvailableB4Z2github.com/gregor-tokarev/hoe_parser/proto/listingb\x06proto3"
+	"\x10incall_available\x18\x05 \x01(\bR\x0fincallAvailable\x12!\n" +
+	"\fservice_area\x18\x06 \x03(\tR\vserviceArea\x12$\n" +
+	"\x0eworks_in_salon\x18\a \x01(\bR\fworksInSalon\x12#\n" +
+	"\rsalon_address\x18\b \x01(\tR\fsalonAddress\x12/\n" +
+	"\x13availability_source\x18\t \x01(\tR\x12availabilitySource\";\n" +
+	"\vListingList\x12,\n" +
+	"\blistings\x18\x01 \x03(\v2\x10.listing.ListingR\blistingsB4Z2github.com/gregor-tokarev/hoe_parser/proto/listingb\x06proto3"
 
 var (
 	file_proto_listing_proto_rawDescOnce sync.Once
@@ -581,7 +720,7 @@ func file_proto_listing_proto_rawDescGZIP() []byte {
 	return file_proto_listing_proto_rawDescData
 }
 
-var file_proto_listing_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_proto_listing_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
 var file_proto_listing_proto_goTypes = []any{
 	(*Listing)(nil),      // 0: listing.Listing
 	(*PersonalInfo)(nil), // 1: listing.PersonalInfo
@@ -589,8 +728,9 @@ var file_proto_listing_proto_goTypes = []any{
 	(*PricingInfo)(nil),  // 3: listing.PricingInfo
 	(*ServiceInfo)(nil),  // 4: listing.ServiceInfo
 	(*LocationInfo)(nil), // 5: listing.LocationInfo
-	nil,                  // 6: listing.PricingInfo.DurationPricesEntry
-	nil,                  // 7: listing.PricingInfo.ServicePricesEntry
+	(*ListingList)(nil),  // 6: listing.ListingList
+	nil,                  // 7: listing.PricingInfo.DurationPricesEntry
+	nil,                  // 8: listing.PricingInfo.ServicePricesEntry
 }
 var file_proto_listing_proto_depIdxs = []int32{
 	1, // 0: listing.Listing.personal_info:type_name -> listing.PersonalInfo
@@ -598,13 +738,14 @@ var file_proto_listing_proto_depIdxs = []int32{
 	3, // 2: listing.Listing.pricing_info:type_name -> listing.PricingInfo
 	4, // 3: listing.Listing.service_info:type_name -> listing.ServiceInfo
 	5, // 4: listing.Listing.location_info:type_name -> listing.LocationInfo
-	6, // 5: listing.PricingInfo.duration_prices:type_name -> listing.PricingInfo.DurationPricesEntry
-	7, // 6: listing.PricingInfo.service_prices:type_name -> listing.PricingInfo.ServicePricesEntry
-	7, // [7:7] is the sub-list for method output_type
-	7, // [7:7] is the sub-list for method input_type
-	7, // [7:7] is the sub-list for extension type_name
-	7, // [7:7] is the sub-list for extension extendee
-	0, // [0:7] is the sub-list for field type_name
+	7, // 5: listing.PricingInfo.duration_prices:type_name -> listing.PricingInfo.DurationPricesEntry
+	8, // 6: listing.PricingInfo.service_prices:type_name -> listing.PricingInfo.ServicePricesEntry
+	0, // 7: listing.ListingList.listings:type_name -> listing.Listing
+	8, // [8:8] is the sub-list for method output_type
+	8, // [8:8] is the sub-list for method input_type
+	8, // [8:8] is the sub-list for extension type_name
+	8, // [8:8] is the sub-list for extension extendee
+	0, // [0:8] is the sub-list for field type_name
 }
 
 func init() { file_proto_listing_proto_init() }
@@ -618,7 +759,7 @@ func file_proto_listing_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_listing_proto_rawDesc), len(file_proto_listing_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   8,
+			NumMessages:   9,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
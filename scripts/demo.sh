@@ -58,6 +58,7 @@ case $choice in
             echo "Testing scrape endpoint..."
             curl -X POST http://localhost:8080/api/v1/scrape \
                 -H "Content-Type: application/json" \
+                -H "X-API-Key: ${API_KEY}" \
                 -d '{"url": "https://a.intimcity.gold/indi/anketa675508.htm"}' \
                 | jq .
         else